@@ -0,0 +1,346 @@
+/*
+auth.go - Authentication and role-based authorization
+
+PURPOSE:
+  Decides who is calling (authentication) and what they may do
+  (authorization). Authentication is pluggable: an Authenticator turns a
+  bearer token into a Principal. Two are provided, static API tokens
+  (this file) and signed JWTs verified with a local key (jwt.go), and
+  Authenticators chains them. The principal's actor is put in the request
+  context, so every transaction written by the request records it
+  (Transaction.CreatedBy / CreatedByType, see generic/actor.go).
+
+AUTHENTICATION:
+  Authorization: Bearer <token>
+  - Missing or invalid token -> 401
+  - Handler.Auth == nil      -> authentication disabled; every request is
+                                allowed and no actor is recorded (local
+                                development and tests only)
+  - /api/calendar/{token} is not authenticated: calendar clients cannot
+    send headers, the feed token is the credential (see calendar.go)
+
+ROLES:
+  employee   Reads and submits for themselves only
+  manager    As employee, plus reads and approves for their direct
+             reports (employees whose manager_id is the manager's ID)
+  admin      Everything in their tenant
+  system     Service accounts; same rights as admin
+
+TENANT BINDING:
+  Employee and manager tokens belong to one tenant (the default tenant
+  unless the token names one); X-Tenant-ID may be omitted or must match.
+  Admin and system tokens without a tenant may act in every tenant and
+  are the only ones that may manage tenants.
+
+AUTHORIZATION RULES (see NewRouter):
+  allow(roles...)   Route is limited to the given roles
+  actingFor         Route's {id} employee must be the caller, one of
+                    their reports (managers) or anyone (admin/system)
+  ownerOnly         Route's {id} employee must be the caller or the caller
+                    admin/system; managers do not qualify (calendar feed
+                    tokens are credentials)
+  In handlers:      Cancelling a transaction checks its employee like
+                    actingFor; approving/rejecting requires the manager of
+                    the request's employee (or admin/system); employee
+                    lists and pending requests are filtered to what the
+                    caller may see
+
+TOKEN FILE (LoadStaticTokens):
+  # token              role      subject      tenant (optional, * = all)
+  s3cr3t-admin-token   admin     ops
+  alice-token          employee  alice        acme
+
+SEE ALSO:
+  - jwt.go: JWT verification
+  - tenants.go: tenant resolution (runs after authentication)
+  - generic/actor.go: Actor in context
+  - cmd/server/main.go: -auth-tokens, -jwt-* flags
+*/
+package api
+
+import (
+	"bufio"
+	"context"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/warp/resource-engine/generic"
+)
+
+// ErrInvalidCredentials is returned by authenticators for unknown,
+// malformed or expired tokens.
+var ErrInvalidCredentials = errors.New("invalid credentials")
+
+// Principal is an authenticated caller.
+type Principal struct {
+	generic.Actor
+
+	// Tenant is the tenant the credential belongs to, unless AllTenants.
+	Tenant     generic.TenantID
+	AllTenants bool
+}
+
+// privileged reports whether the principal may act for anyone.
+func (p *Principal) privileged() bool {
+	return p.Type == generic.ActorAdmin || p.Type == generic.ActorSystem
+}
+
+// newPrincipal validates a role and applies the tenant binding rules:
+// without a tenant, admin and system principals may act in every tenant,
+// employees and managers only in the default tenant.
+func newPrincipal(subject, role, tenant string, hasTenant bool) (Principal, error) {
+	switch role {
+	case generic.ActorEmployee, generic.ActorManager, generic.ActorAdmin, generic.ActorSystem:
+	default:
+		return Principal{}, fmt.Errorf("unknown role %q", role)
+	}
+	if subject == "" {
+		return Principal{}, errors.New("missing subject")
+	}
+
+	p := Principal{Actor: generic.Actor{ID: subject, Type: role}}
+	switch {
+	case tenant == "*" || (!hasTenant && p.privileged()):
+		if !p.privileged() {
+			return Principal{}, fmt.Errorf("role %q cannot act in all tenants", role)
+		}
+		p.AllTenants = true
+	case hasTenant:
+		p.Tenant = generic.TenantID(tenant)
+	}
+	return p, nil
+}
+
+// Authenticator turns a bearer token into a principal.
+type Authenticator interface {
+	// Authenticate returns the token's principal, or an error wrapping
+	// ErrInvalidCredentials.
+	Authenticate(token string) (*Principal, error)
+}
+
+// Authenticators tries each authenticator in turn; the first to accept
+// the token wins.
+type Authenticators []Authenticator
+
+// Authenticate implements Authenticator.
+func (as Authenticators) Authenticate(token string) (*Principal, error) {
+	err := ErrInvalidCredentials
+	for _, a := range as {
+		p, aerr := a.Authenticate(token)
+		if aerr == nil {
+			return p, nil
+		}
+		err = aerr
+	}
+	return nil, err
+}
+
+// StaticTokens authenticates fixed API tokens. Tokens are kept as SHA-256
+// hashes, so lookups do not compare secrets byte by byte.
+type StaticTokens struct {
+	principals map[[sha256.Size]byte]Principal
+}
+
+// NewStaticTokens returns an empty token set.
+func NewStaticTokens() *StaticTokens {
+	return &StaticTokens{principals: make(map[[sha256.Size]byte]Principal)}
+}
+
+// Add registers a token for a principal.
+func (st *StaticTokens) Add(token string, p Principal) {
+	st.principals[sha256.Sum256([]byte(token))] = p
+}
+
+// Authenticate implements Authenticator.
+func (st *StaticTokens) Authenticate(token string) (*Principal, error) {
+	p, ok := st.principals[sha256.Sum256([]byte(token))]
+	if !ok {
+		return nil, ErrInvalidCredentials
+	}
+	return &p, nil
+}
+
+// LoadStaticTokens reads a token file: one "token role subject [tenant]"
+// per line, blank lines and lines starting with # ignored.
+func LoadStaticTokens(r io.Reader) (*StaticTokens, error) {
+	st := NewStaticTokens()
+	scanner := bufio.NewScanner(r)
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+		fields := strings.Fields(text)
+		if len(fields) != 3 && len(fields) != 4 {
+			return nil, fmt.Errorf("line %d: expected \"token role subject [tenant]\"", line)
+		}
+		tenant := ""
+		if len(fields) == 4 {
+			tenant = fields[3]
+		}
+		p, err := newPrincipal(fields[2], fields[1], tenant, len(fields) == 4)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", line, err)
+		}
+		st.Add(fields[0], p)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return st, nil
+}
+
+// =============================================================================
+// MIDDLEWARE
+// =============================================================================
+
+type principalKey struct{}
+
+// principalFrom returns the request's principal, or nil when
+// authentication is disabled.
+func principalFrom(ctx context.Context) *Principal {
+	p, _ := ctx.Value(principalKey{}).(*Principal)
+	return p
+}
+
+// Authenticate is middleware that requires a valid bearer token and puts
+// its principal and actor in the request context. It lets every request
+// through when no authenticator is configured.
+func (h *Handler) Authenticate(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if h.Auth == nil {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if !ok || token == "" {
+			w.Header().Set("WWW-Authenticate", `Bearer realm="api"`)
+			writeError(w, http.StatusUnauthorized, "Missing bearer token", nil)
+			return
+		}
+		p, err := h.Auth.Authenticate(token)
+		if err != nil {
+			w.Header().Set("WWW-Authenticate", `Bearer realm="api", error="invalid_token"`)
+			writeError(w, http.StatusUnauthorized, "Invalid token", nil)
+			return
+		}
+
+		ctx := context.WithValue(r.Context(), principalKey{}, p)
+		ctx = generic.WithActor(ctx, p.Actor)
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// allow returns middleware limiting a route to the given roles.
+func (h *Handler) allow(roles ...string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			p := principalFrom(r.Context())
+			if p != nil && !containsRole(roles, p.Type) {
+				writeError(w, http.StatusForbidden, "Not allowed for role "+p.Type, nil)
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// allowAllTenants limits a route to admin/system principals that are not
+// bound to a tenant.
+func (h *Handler) allowAllTenants(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if p := principalFrom(r.Context()); p != nil && !p.AllTenants {
+			writeError(w, http.StatusForbidden, "Requires a token valid for all tenants", nil)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// actingFor is middleware for /employees/{id} routes: the caller must be
+// the employee, their manager, or admin/system.
+func (h *Handler) actingFor(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ok, err := h.canActFor(r.Context(), chi.URLParam(r, "id"))
+		if err != nil {
+			writeError(w, http.StatusInternalServerError, "Failed to authorize request", err)
+			return
+		}
+		if !ok {
+			writeError(w, http.StatusForbidden, "Not allowed to act for this employee", nil)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// ownerOnly is middleware for /employees/{id} routes that handle the
+// employee's credentials: the caller must be the employee, or
+// admin/system. Managers do not act for their reports here.
+func (h *Handler) ownerOnly(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		p := principalFrom(r.Context())
+		if p != nil && !p.privileged() && p.ID != chi.URLParam(r, "id") {
+			writeError(w, http.StatusForbidden, "Only the employee or an admin may do this", nil)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// canActFor reports whether the caller may read and submit for an
+// employee: themselves, their direct reports (managers), or anyone
+// (admin/system, or authentication disabled).
+func (h *Handler) canActFor(ctx context.Context, employeeID string) (bool, error) {
+	p := principalFrom(ctx)
+	if p == nil || p.privileged() || p.ID == employeeID {
+		return true, nil
+	}
+	return h.isManagerOf(ctx, p, employeeID)
+}
+
+// canApprove reports whether the caller may approve or reject an
+// employee's requests: their manager, or admin/system. Nobody approves
+// their own requests unless privileged.
+func (h *Handler) canApprove(ctx context.Context, employeeID string) (bool, error) {
+	p := principalFrom(ctx)
+	if p == nil || p.privileged() {
+		return true, nil
+	}
+	return h.isManagerOf(ctx, p, employeeID)
+}
+
+func (h *Handler) isManagerOf(ctx context.Context, p *Principal, employeeID string) (bool, error) {
+	if p.Type != generic.ActorManager {
+		return false, nil
+	}
+	emp, err := h.Store.GetEmployee(ctx, employeeID)
+	if err != nil {
+		return false, err
+	}
+	return emp != nil && emp.ManagerID == p.ID, nil
+}
+
+// actorID returns the caller's ID for audit fields such as
+// approved_by, falling back when authentication is disabled.
+func actorID(ctx context.Context, fallback string) string {
+	if p := principalFrom(ctx); p != nil {
+		return p.ID
+	}
+	return fallback
+}
+
+func containsRole(roles []string, role string) bool {
+	for _, r := range roles {
+		if r == role {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,154 @@
+/*
+auth_test.go - Unit tests for authentication and roles
+
+Tests for:
+- Authentication and roles (StaticTokens, actingFor, approvals)
+*/
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/warp/resource-engine/generic"
+	"github.com/warp/resource-engine/store/sqlite"
+	"github.com/warp/resource-engine/timeoff"
+)
+
+func TestAuth_RolesLimitWhatCallersCanDo(t *testing.T) {
+	// GIVEN: Employees alice (reports to bob) and carol (reports to dave),
+	//        a manager bob, and static tokens for alice, bob and an admin
+	// WHEN: Each caller reads, submits, cancels and approves
+	// THEN: Employees only act for themselves, managers only approve
+	//       their reports, admin routes need admin, and every
+	//       transaction records who wrote it
+
+	handler := setupTestHandler(t)
+	tokens := NewStaticTokens()
+	tokens.Add("admin-token", Principal{Actor: generic.Actor{ID: "ops", Type: generic.ActorAdmin}, AllTenants: true})
+	tokens.Add("bob-token", Principal{Actor: generic.Actor{ID: "bob", Type: generic.ActorManager}})
+	tokens.Add("alice-token", Principal{Actor: generic.Actor{ID: "alice", Type: generic.ActorEmployee}})
+	handler.Auth = tokens
+	router := NewRouter(handler)
+	ctx := context.Background()
+
+	if err := handler.createPolicyFromJSON(ctx, timeoff.StandardPTOJSON("pto-test", "Test PTO", 24, 5)); err != nil {
+		t.Fatalf("Failed to create policy: %v", err)
+	}
+	hireDate := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	for id, manager := range map[string]string{"alice": "bob", "carol": "dave", "bob": ""} {
+		if err := handler.Store.SaveEmployee(ctx, sqlite.Employee{ID: id, Name: id, ManagerID: manager, HireDate: hireDate}); err != nil {
+			t.Fatalf("Failed to create employee: %v", err)
+		}
+		if err := handler.Store.SaveAssignment(ctx, sqlite.AssignmentRecord{
+			ID: "assign-" + id, EntityID: id, PolicyID: "pto-test",
+			EffectiveFrom: hireDate, ConsumptionPriority: 1,
+		}); err != nil {
+			t.Fatalf("Failed to save assignment: %v", err)
+		}
+	}
+
+	do := func(method, path, token, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, req)
+		return rec
+	}
+	expect := func(rec *httptest.ResponseRecorder, code int, what string) {
+		t.Helper()
+		if rec.Code != code {
+			t.Errorf("%s: expected %d, got %d: %s", what, code, rec.Code, rec.Body.String())
+		}
+	}
+
+	expect(do(http.MethodGet, "/api/employees", "", ""), http.StatusUnauthorized, "no token")
+	expect(do(http.MethodGet, "/api/employees", "nope", ""), http.StatusUnauthorized, "unknown token")
+
+	// Employees act for themselves only
+	expect(do(http.MethodGet, "/api/employees/alice/balance", "alice-token", ""), http.StatusOK, "alice reads own balance")
+	expect(do(http.MethodGet, "/api/employees/carol/balance", "alice-token", ""), http.StatusForbidden, "alice reads carol's balance")
+	expect(do(http.MethodPost, "/api/employees/carol/requests", "alice-token", `{"resource_type":"pto","days":["2025-06-02"]}`), http.StatusForbidden, "alice submits for carol")
+	expect(do(http.MethodPost, "/api/policies", "alice-token", `{}`), http.StatusForbidden, "alice creates a policy")
+	expect(do(http.MethodPost, "/api/scenarios/reset", "alice-token", ""), http.StatusForbidden, "alice resets the database")
+	expect(do(http.MethodPost, "/api/admin/adjustments", "bob-token", `{}`), http.StatusForbidden, "bob adjusts a balance")
+	expect(do(http.MethodPost, "/api/tenants", "bob-token", `{"id":"acme"}`), http.StatusForbidden, "bob creates a tenant")
+
+	var employees []EmployeeDTO
+	json.Unmarshal(do(http.MethodGet, "/api/employees", "alice-token", "").Body.Bytes(), &employees)
+	if len(employees) != 1 || employees[0].ID != "alice" {
+		t.Errorf("Expected alice to list only herself, got %+v", employees)
+	}
+	employees = nil
+	json.Unmarshal(do(http.MethodGet, "/api/employees", "bob-token", "").Body.Bytes(), &employees)
+	if len(employees) != 2 {
+		t.Errorf("Expected bob to list himself and alice, got %+v", employees)
+	}
+	expect(do(http.MethodGet, "/api/employees/alice/transactions", "bob-token", ""), http.StatusOK, "bob reads his report")
+	expect(do(http.MethodGet, "/api/employees/carol/transactions", "bob-token", ""), http.StatusForbidden, "bob reads carol")
+
+	// Submissions record the caller
+	expect(do(http.MethodPost, "/api/employees/alice/requests", "alice-token", `{"resource_type":"pto","days":["2025-06-02"]}`), http.StatusCreated, "alice submits for herself")
+	expect(do(http.MethodPost, "/api/employees/carol/requests", "admin-token", `{"resource_type":"pto","days":["2025-06-03"]}`), http.StatusCreated, "admin submits for carol")
+	txs, err := handler.Store.GetAllTransactions(ctx, 10)
+	if err != nil {
+		t.Fatalf("Failed to load transactions: %v", err)
+	}
+	var carolTx generic.TransactionID
+	for _, tx := range txs {
+		want := generic.Actor{ID: "alice", Type: generic.ActorEmployee}
+		if tx.EntityID == "carol" {
+			want = generic.Actor{ID: "ops", Type: generic.ActorAdmin}
+			carolTx = tx.ID
+		}
+		if tx.CreatedBy != want.ID || tx.CreatedByType != want.Type {
+			t.Errorf("Transaction %s: expected created by %s/%s, got %s/%s", tx.ID, want.ID, want.Type, tx.CreatedBy, tx.CreatedByType)
+		}
+	}
+	if carolTx == "" || len(txs) != 2 {
+		t.Fatalf("Expected one transaction each for alice and carol, got %d", len(txs))
+	}
+	expect(do(http.MethodDelete, "/api/transactions/"+string(carolTx), "alice-token", ""), http.StatusForbidden, "alice cancels carol's day")
+
+	// Managers approve only their reports
+	now := time.Now()
+	for id, entity := range map[string]string{"req-alice": "alice", "req-carol": "carol"} {
+		if err := handler.Store.SaveRequest(ctx, sqlite.Request{
+			ID: id, EntityID: entity, ResourceType: "pto", Amount: 1, Unit: "days",
+			EffectiveAt: time.Date(2025, 7, 1, 0, 0, 0, 0, time.UTC),
+			Status:      "pending", RequiresApproval: true, CreatedAt: now, UpdatedAt: now,
+		}); err != nil {
+			t.Fatalf("Failed to save request: %v", err)
+		}
+	}
+	var pending struct {
+		Requests []struct {
+			ID string `json:"id"`
+		} `json:"requests"`
+	}
+	json.Unmarshal(do(http.MethodGet, "/api/requests/pending", "bob-token", "").Body.Bytes(), &pending)
+	if len(pending.Requests) != 1 || pending.Requests[0].ID != "req-alice" {
+		t.Errorf("Expected bob to see only req-alice, got %+v", pending.Requests)
+	}
+	expect(do(http.MethodPost, "/api/requests/req-alice/approve", "alice-token", ""), http.StatusForbidden, "alice approves her own request")
+	expect(do(http.MethodPost, "/api/requests/req-carol/approve", "bob-token", ""), http.StatusForbidden, "bob approves carol")
+	rec := do(http.MethodPost, "/api/requests/req-alice/approve", "bob-token", `{"approver_id":"someone-else"}`)
+	expect(rec, http.StatusOK, "bob approves alice")
+	if request, _ := handler.Store.GetRequest(ctx, "req-alice"); request == nil || request.ApprovedBy != "bob" {
+		t.Errorf("Expected req-alice approved by bob, got %+v", request)
+	}
+
+	// Calendar feeds stay reachable without a bearer token
+	rec = do(http.MethodPost, "/api/employees/alice/calendar-feeds", "alice-token", `{"scope":"self"}`)
+	expect(rec, http.StatusCreated, "alice creates a feed")
+	var feed CalendarFeedDTO
+	json.Unmarshal(rec.Body.Bytes(), &feed)
+	expect(do(http.MethodGet, feed.URL, "", ""), http.StatusOK, "feed without bearer token")
+}
@@ -0,0 +1,181 @@
+/*
+balance_explain.go - Balance explanation ("why is my balance X?")
+
+PURPOSE:
+  Employees ask how their number was computed. The explain endpoint
+  returns, per policy, the figures of the balance endpoint and how they
+  were derived: every scheduled accrual with its reason, every grant,
+  consumption, pending, reversal, adjustment and reconciliation
+  transaction with the component it feeds, the consumption-mode rule
+  applied, and the policy's constraints evaluated.
+
+KEY CONCEPTS:
+  Same computation as GetBalance:
+    The period containing as_of, the transactions in it known at
+    as_known_at (up to as_of when it is given: asOfBalanceRange), and
+    accruals from the assignment start. The figures come
+    from generic.ExplainBalance, which calls ComputeBalance and
+    AvailableWithMode, so they are those of the balance endpoint.
+
+  Accrued to date:
+    The larger of the scheduled accruals up to as_of and the grants;
+    accrued_basis says which. Without a schedule it is the grants.
+
+  Total entitlement:
+    The schedule alone. The balance endpoint shows it with adjustments
+    added; here adjustments are their own component.
+
+ENDPOINTS:
+  GET /api/employees/{id}/balance/explain?resource_type=pto&as_of=&as_known_at=
+
+SEE ALSO:
+  - handlers.go: GetBalance
+  - generic/balance.go: ComputeBalance, ExplainBalance
+*/
+package api
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/warp/resource-engine/generic"
+	"github.com/warp/resource-engine/timeoff"
+)
+
+// GetBalanceExplanation returns the itemized derivation of an employee's
+// balance per policy.
+// GET /api/employees/{id}/balance/explain?resource_type=pto&as_of=YYYY-MM-DD&as_known_at=
+func (h *Handler) GetBalanceExplanation(w http.ResponseWriter, r *http.Request) {
+	entityID := generic.EntityID(chi.URLParam(r, "id"))
+	resourceType := r.URL.Query().Get("resource_type")
+	if resourceType == "" {
+		resourceType = string(timeoff.ResourcePTO)
+	}
+
+	ctx := r.Context()
+	asOf := generic.Today()
+	asOfOnly := false
+	if s := r.URL.Query().Get("as_of"); s != "" {
+		t, err := time.Parse("2006-01-02", s)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, "Invalid as_of format (use YYYY-MM-DD)", err)
+			return
+		}
+		asOf = generic.TimePoint{Time: t}
+		asOfOnly = true
+	}
+	knownAt, ok := asKnownAt(w, r)
+	if !ok {
+		return
+	}
+
+	assignments, err := h.Store.GetAssignmentsByEntity(ctx, string(entityID))
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "Failed to get assignments", err)
+		return
+	}
+
+	ledger := generic.NewLedger(h.Store).AsKnownAt(knownAt)
+	cache, err := h.cache(ctx)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "Failed to load policies", err)
+		return
+	}
+
+	resp := BalanceExplanationDTO{
+		EntityID:     string(entityID),
+		ResourceType: resourceType,
+		AsOf:         asOf.Time.Format("2006-01-02"),
+		AsKnownAt:    formatKnownAt(knownAt),
+		Policies:     []PolicyBalanceExplanationDTO{},
+	}
+	for _, a := range assignments {
+		policy, ok := cache.policies[generic.PolicyID(a.PolicyID)]
+		if !ok || policy.ResourceType.ResourceID() != resourceType {
+			continue
+		}
+		if asOfOnly && !assignmentActive(a, asOf) {
+			continue
+		}
+
+		period := policy.PeriodConfig.PeriodFor(asOf)
+		to, accrualStart := asOfBalanceRange(period, a, asOf, asOfOnly)
+		txs, err := ledger.TransactionsInRange(ctx, entityID, policy.ID, period.Start, to)
+		if err != nil {
+			writeError(w, http.StatusInternalServerError, "Failed to get transactions", err)
+			return
+		}
+
+		ex := generic.ExplainBalance(txs, period, policy.Unit, cache.accruals[policy.ID], accrualStart, asOf,
+			policy.ConsumptionMode, policy.Constraints)
+		dto := toPolicyBalanceExplanationDTO(policy, ex)
+		resp.Policies = append(resp.Policies, dto)
+		resp.TotalAvailable += dto.Available
+	}
+
+	writeJSON(w, http.StatusOK, resp)
+}
+
+// toPolicyBalanceExplanationDTO converts amounts the way GetBalance does,
+// so the figures match to the last digit.
+func toPolicyBalanceExplanationDTO(policy *generic.Policy, ex generic.BalanceExplanation) PolicyBalanceExplanationDTO {
+	b := ex.Balance
+	dto := PolicyBalanceExplanationDTO{
+		PolicyID:        string(policy.ID),
+		PolicyName:      policy.Name,
+		PeriodStart:     b.Period.Start.Time.Format("2006-01-02"),
+		PeriodEnd:       b.Period.End.Time.Format("2006-01-02"),
+		Unit:            string(policy.Unit),
+		ConsumptionMode: string(policy.ConsumptionMode),
+		Rule:            ex.Rule,
+		AccruedBasis:    "grants",
+		Accruals:        []ExplainedAccrualDTO{},
+		Transactions:    []ExplainedTransactionDTO{},
+		Constraints:     []ConstraintCheckDTO{},
+	}
+	dto.Available, _ = ex.Available.Value.Float64()
+	dto.AccruedToDate, _ = b.AccruedToDate.Value.Float64()
+	dto.TotalEntitlement, _ = b.TotalEntitlement.Value.Float64()
+	dto.Consumed, _ = b.TotalConsumed.Value.Float64()
+	dto.Pending, _ = b.Pending.Value.Float64()
+	dto.Adjustments, _ = b.Adjustments.Value.Float64()
+	dto.Granted, _ = ex.Granted.Value.Float64()
+	if ex.AccruedFromSchedule {
+		dto.AccruedBasis = "schedule"
+	}
+
+	for _, e := range ex.Accruals {
+		amount, _ := e.Amount.Value.Float64()
+		dto.Accruals = append(dto.Accruals, ExplainedAccrualDTO{
+			Date:   e.At.Time.Format("2006-01-02"),
+			Amount: amount,
+			Reason: e.Reason,
+			ToDate: e.ToDate,
+		})
+	}
+	for _, t := range ex.Transactions {
+		delta, _ := t.Delta.Value.Float64()
+		effect, _ := t.Effect.Value.Float64()
+		dto.Transactions = append(dto.Transactions, ExplainedTransactionDTO{
+			ID:          string(t.ID),
+			Type:        string(t.Type),
+			EffectiveAt: t.EffectiveAt.Time.Format("2006-01-02"),
+			Delta:       delta,
+			Component:   t.Component,
+			Effect:      effect,
+			ReferenceID: t.ReferenceID,
+			Reason:      t.Reason,
+		})
+	}
+	for _, c := range ex.Constraints {
+		check := ConstraintCheckDTO{Name: c.Name, Passed: c.Passed, Detail: c.Detail}
+		check.Value, _ = c.Value.Value.Float64()
+		if c.Limit != nil {
+			limit, _ := c.Limit.Value.Float64()
+			check.Limit = &limit
+		}
+		dto.Constraints = append(dto.Constraints, check)
+	}
+	return dto
+}
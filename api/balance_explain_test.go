@@ -0,0 +1,143 @@
+/*
+balance_explain_test.go - Unit tests for balance explanations
+
+Tests for:
+- Balance explanation (GetBalanceExplanation against GetBalance)
+*/
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/warp/resource-engine/generic"
+	"github.com/warp/resource-engine/store/sqlite"
+	"github.com/warp/resource-engine/timeoff"
+)
+
+func TestBalanceExplain_ReproducesBalanceFigures(t *testing.T) {
+	// GIVEN: A consume-ahead and a consume-up-to-accrued policy, each with
+	//        a grant, a consumption, its reversal, a pending day and an
+	//        adjustment
+	// WHEN: The balance and its explanation are read as of Apr 10
+	// THEN: The explanation has the balance's figures, its itemized
+	//       accruals and transactions add up to them, and each mode
+	//       states its rule
+
+	handler := setupTestHandler(t)
+	ctx := context.Background()
+
+	ahead := timeoff.StandardPTOJSON("pto-ahead", "Ahead PTO", 12, 5)
+	accrued := strings.Replace(timeoff.StandardPTOJSON("pto-accrued", "Accrued PTO", 12, 5),
+		`"consume_ahead"`, `"consume_up_to_accrued"`, 1)
+	for _, pj := range []string{ahead, accrued} {
+		if err := handler.createPolicyFromJSON(ctx, pj); err != nil {
+			t.Fatalf("Failed to create policy: %v", err)
+		}
+	}
+	since := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	if err := handler.Store.SaveEmployee(ctx, sqlite.Employee{ID: "emp-why", Name: "Why User", HireDate: since}); err != nil {
+		t.Fatalf("Failed to create employee: %v", err)
+	}
+	for i, policyID := range []string{"pto-ahead", "pto-accrued"} {
+		if err := handler.Store.SaveAssignment(ctx, sqlite.AssignmentRecord{
+			ID: "assign-" + policyID, EntityID: "emp-why", PolicyID: policyID,
+			EffectiveFrom: since, ConsumptionPriority: i + 1,
+		}); err != nil {
+			t.Fatalf("Failed to save assignment: %v", err)
+		}
+		// One day off per resource type and day, so each policy's days differ
+		tx := func(id string, month, day int, delta float64, txType generic.TransactionType) generic.Transaction {
+			id = policyID + "-" + id
+			return generic.Transaction{
+				ID: generic.TransactionID(id), EntityID: "emp-why", PolicyID: generic.PolicyID(policyID),
+				ResourceType: timeoff.ResourcePTO, EffectiveAt: generic.TimePoint{Time: time.Date(2025, time.Month(month), day+i, 0, 0, 0, 0, time.UTC)},
+				Delta: generic.NewAmount(delta, generic.UnitDays), Type: txType, IdempotencyKey: id,
+			}
+		}
+		if err := handler.Store.AppendBatch(ctx, []generic.Transaction{
+			tx("grant", 1, 20, 1, generic.TxGrant),
+			tx("day", 2, 3, -2, generic.TxConsumption),
+			tx("undo", 2, 4, 1, generic.TxReversal),
+			tx("pending", 3, 3, -1, generic.TxPending),
+			tx("adjust", 3, 10, 0.5, generic.TxAdjustment),
+		}); err != nil {
+			t.Fatalf("Failed to append transactions: %v", err)
+		}
+	}
+
+	router := NewRouter(handler)
+	get := func(path string, v any) int {
+		t.Helper()
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, req)
+		json.Unmarshal(rec.Body.Bytes(), v)
+		return rec.Code
+	}
+
+	var balance BalanceDTO
+	var explained BalanceExplanationDTO
+	get("/api/employees/emp-why/balance?as_of=2025-04-10", &balance)
+	if code := get("/api/employees/emp-why/balance/explain?as_of=2025-04-10", &explained); code != http.StatusOK {
+		t.Fatalf("Explain: %d, want 200", code)
+	}
+	if len(balance.Policies) != 2 || len(explained.Policies) != 2 {
+		t.Fatalf("Expected two policies, got %+v and %+v", balance, explained)
+	}
+	if explained.TotalAvailable != balance.TotalAvailable || explained.AsOf != "2025-04-10" {
+		t.Errorf("Total available: explained %.4f, balance %.4f", explained.TotalAvailable, balance.TotalAvailable)
+	}
+
+	for i, p := range explained.Policies {
+		b := balance.Policies[i]
+		if p.PolicyID != b.PolicyID || p.Available != b.Available || p.AccruedToDate != b.AccruedToDate ||
+			p.Consumed != b.Consumed || p.Pending != b.Pending || p.TotalEntitlement+p.Adjustments != b.TotalEntitlement {
+			t.Errorf("%s: explained %+v does not match balance %+v", p.PolicyID, p, b)
+		}
+
+		// The itemized lines add up to the figures
+		toDate, scheduled := 0.0, 0.0
+		for _, a := range p.Accruals {
+			scheduled += a.Amount
+			if a.ToDate {
+				toDate += a.Amount
+			}
+		}
+		if len(p.Accruals) != 12 || scheduled != p.TotalEntitlement || toDate != p.AccruedToDate || p.AccruedBasis != "schedule" {
+			t.Errorf("%s: accruals %+v (to date %.2f, scheduled %.2f) vs accrued %.2f, entitlement %.2f",
+				p.PolicyID, p.Accruals, toDate, scheduled, p.AccruedToDate, p.TotalEntitlement)
+		}
+		effects := make(map[string]float64)
+		for _, tx := range p.Transactions {
+			effects[tx.Component] += tx.Effect
+		}
+		if len(p.Transactions) != 5 || effects["accrued"] != p.Granted || effects["consumed"] != p.Consumed ||
+			effects["pending"] != p.Pending || effects["adjustments"] != p.Adjustments {
+			t.Errorf("%s: transaction effects %v vs %+v", p.PolicyID, effects, p)
+		}
+
+		base := p.TotalEntitlement
+		if p.ConsumptionMode == "consume_up_to_accrued" {
+			base = p.AccruedToDate
+		}
+		if got := base - p.Consumed + p.Adjustments - p.Pending; got != p.Available {
+			t.Errorf("%s: rule %q gives %.2f, available %.2f", p.PolicyID, p.Rule, got, p.Available)
+		}
+		if len(p.Constraints) == 0 || p.Constraints[0].Name != "allow_negative" || !p.Constraints[0].Passed {
+			t.Errorf("%s: expected a passing allow_negative check, got %+v", p.PolicyID, p.Constraints)
+		}
+	}
+	if explained.Policies[0].Rule == explained.Policies[1].Rule {
+		t.Errorf("Expected a different rule per consumption mode, got %q", explained.Policies[0].Rule)
+	}
+
+	if code := get("/api/employees/emp-why/balance/explain?as_of=April", &explained); code != http.StatusBadRequest {
+		t.Errorf("Invalid as_of: %d, want 400", code)
+	}
+}
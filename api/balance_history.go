@@ -0,0 +1,224 @@
+/*
+balance_history.go - Balance time series
+
+PURPOSE:
+  Charts and dispute resolution need to know what an employee's balance
+  was (or will be) on any day, not just today. The history endpoint
+  returns available, accrued, consumed and pending amounts at regular
+  points over a range, per policy and in total.
+
+KEY CONCEPTS:
+  Points:
+    From, then every day, week or month after it, and To. Monthly points
+    keep From's day of month, clamped to shorter months (Jan 31, Feb 28,
+    Mar 31).
+
+  What a point counts:
+    Accruals computed up to the point (from the assignment start, so
+    mid-period hires are prorated) and ledger transactions effective in
+    the point's period on or before it, like the liability report and
+    the balance endpoint with ?as_of= (asOfBalanceRange), so a point
+    equals /balance?as_of= for its day. A past point therefore never
+    changes when later days are booked. Assignments not active on a
+    point's day are left out.
+
+  as_known_at:
+    Leaves out transactions recorded after it, for every point: the
+    series as the system showed it then, before any backdated fix.
+
+ENDPOINTS:
+  GET /api/employees/{id}/balance/history?from=&to=&interval=day|week|month
+      &resource_type=pto&policy_id=&as_known_at=
+
+SEE ALSO:
+  - handlers.go: GetBalance (?as_of=)
+  - generic/assignment.go: ResourceBalanceCalculator (AsOfOnly)
+  - liability.go: Same as-of rule for reports
+*/
+package api
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/shopspring/decimal"
+	"github.com/warp/resource-engine/generic"
+	"github.com/warp/resource-engine/timeoff"
+)
+
+// maxHistoryPoints bounds one history response (a little under three
+// years of daily points).
+const maxHistoryPoints = 1000
+
+// GetBalanceHistory returns an employee's balance at regular points over
+// a range. Defaults: to today, from January 1 of to's year, daily.
+// GET /api/employees/{id}/balance/history
+func (h *Handler) GetBalanceHistory(w http.ResponseWriter, r *http.Request) {
+	entityID := generic.EntityID(chi.URLParam(r, "id"))
+	q := r.URL.Query()
+	resourceType := q.Get("resource_type")
+	if resourceType == "" {
+		resourceType = string(timeoff.ResourcePTO) // Default
+	}
+	policyID := q.Get("policy_id")
+	interval := q.Get("interval")
+	if interval == "" {
+		interval = "day"
+	}
+
+	to := generic.Today()
+	if s := q.Get("to"); s != "" {
+		t, err := time.Parse("2006-01-02", s)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, "Invalid to format (use YYYY-MM-DD)", err)
+			return
+		}
+		to = generic.TimePoint{Time: t}
+	}
+	from := generic.NewTimePoint(to.Year(), time.January, 1)
+	if s := q.Get("from"); s != "" {
+		t, err := time.Parse("2006-01-02", s)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, "Invalid from format (use YYYY-MM-DD)", err)
+			return
+		}
+		from = generic.TimePoint{Time: t}
+	}
+	if to.Before(from) {
+		writeError(w, http.StatusBadRequest, "from must not be after to", nil)
+		return
+	}
+	knownAt, ok := asKnownAt(w, r)
+	if !ok {
+		return
+	}
+
+	points, err := historyPoints(from, to, interval)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error(), nil)
+		return
+	}
+
+	ctx := r.Context()
+	cache, err := h.cache(ctx)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "Failed to load policies", err)
+		return
+	}
+	all, err := h.assignments(cache).GetByEntity(ctx, entityID)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "Failed to get assignments", err)
+		return
+	}
+
+	// Each assignment's transactions are loaded once, from the start of
+	// the period containing From
+	ledger := generic.NewLedger(h.Store).AsKnownAt(knownAt)
+	var assignments []generic.PolicyAssignment
+	txsByAssignment := make(map[string][]generic.Transaction)
+	for _, a := range all {
+		if a.Policy.ResourceType.ResourceID() != resourceType || (policyID != "" && string(a.PolicyID) != policyID) {
+			continue
+		}
+		start := a.Policy.PeriodConfig.PeriodFor(from).Start
+		txs, err := ledger.TransactionsInRange(ctx, entityID, a.PolicyID, start, to)
+		if err != nil {
+			writeError(w, http.StatusInternalServerError, "Failed to get transactions", err)
+			return
+		}
+		assignments = append(assignments, a)
+		txsByAssignment[a.ID] = txs
+	}
+
+	accruals := cache.accruals
+	history := BalanceHistoryDTO{
+		EntityID:     string(entityID),
+		ResourceType: resourceType,
+		From:         from.Time.Format("2006-01-02"),
+		To:           to.Time.Format("2006-01-02"),
+		Interval:     interval,
+		AsKnownAt:    formatKnownAt(knownAt),
+		Points:       make([]BalancePointDTO, 0, len(points)),
+	}
+	for _, at := range points {
+		point := BalancePointDTO{Date: at.Time.Format("2006-01-02"), Policies: []PolicyBalancePointDTO{}}
+		for _, a := range assignments {
+			if !a.IsActive(at) {
+				continue
+			}
+			period := a.Policy.PeriodConfig.PeriodFor(at)
+			var txs []generic.Transaction
+			for _, tx := range txsByAssignment[a.ID] {
+				if !tx.EffectiveAt.Before(period.Start) && !tx.EffectiveAt.After(at) {
+					txs = append(txs, tx)
+				}
+			}
+			balance := generic.ComputeBalance(txs, period, a.Policy.Unit, accruals[a.PolicyID], a.EffectiveFrom, at)
+
+			pb := PolicyBalancePointDTO{
+				PolicyID:  string(a.PolicyID),
+				Available: roundFloat(balance.AvailableWithMode(a.Policy.ConsumptionMode).Value, 4),
+				Accrued:   roundFloat(balance.AccruedToDate.Value, 4),
+				Consumed:  roundFloat(balance.TotalConsumed.Value, 4),
+				Pending:   roundFloat(balance.Pending.Value, 4),
+			}
+			point.Policies = append(point.Policies, pb)
+			point.Available += pb.Available
+			point.Accrued += pb.Accrued
+			point.Consumed += pb.Consumed
+			point.Pending += pb.Pending
+		}
+		point.Available = roundFloat(decimal.NewFromFloat(point.Available), 4)
+		point.Accrued = roundFloat(decimal.NewFromFloat(point.Accrued), 4)
+		point.Consumed = roundFloat(decimal.NewFromFloat(point.Consumed), 4)
+		point.Pending = roundFloat(decimal.NewFromFloat(point.Pending), 4)
+		history.Points = append(history.Points, point)
+	}
+
+	writeJSON(w, http.StatusOK, history)
+}
+
+// historyPoints returns From, every interval after it up to To, and To.
+func historyPoints(from, to generic.TimePoint, interval string) ([]generic.TimePoint, error) {
+	var step func(i int) generic.TimePoint
+	switch interval {
+	case "day":
+		step = func(i int) generic.TimePoint { return from.AddDays(i) }
+	case "week":
+		step = func(i int) generic.TimePoint { return from.AddDays(7 * i) }
+	case "month":
+		step = func(i int) generic.TimePoint { return addMonthsClamped(from, i) }
+	default:
+		return nil, fmt.Errorf("invalid interval %q (use day, week or month)", interval)
+	}
+
+	var points []generic.TimePoint
+	for i := 0; ; i++ {
+		at := step(i)
+		if at.After(to) {
+			break
+		}
+		if len(points) == maxHistoryPoints {
+			return nil, fmt.Errorf("range has more than %d points; use a longer interval", maxHistoryPoints)
+		}
+		points = append(points, at)
+	}
+	if !points[len(points)-1].Equal(to) {
+		points = append(points, to)
+	}
+	return points, nil
+}
+
+// addMonthsClamped adds n months, keeping the day of month where the
+// month has it and using its last day otherwise.
+func addMonthsClamped(tp generic.TimePoint, n int) generic.TimePoint {
+	first := time.Date(tp.Year(), tp.Month()+time.Month(n), 1, 0, 0, 0, 0, time.UTC)
+	lastDay := first.AddDate(0, 1, -1).Day()
+	day := tp.Day()
+	if day > lastDay {
+		day = lastDay
+	}
+	return generic.NewTimePoint(first.Year(), first.Month(), day)
+}
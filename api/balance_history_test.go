@@ -0,0 +1,184 @@
+/*
+balance_history_test.go - Unit tests for balance history
+
+Tests for:
+- Balance history and as_of (GetBalanceHistory, GetBalance)
+*/
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/shopspring/decimal"
+	"github.com/warp/resource-engine/generic"
+	"github.com/warp/resource-engine/store/sqlite"
+	"github.com/warp/resource-engine/timeoff"
+)
+
+func TestBalanceHistory_SeriesAndAsOf(t *testing.T) {
+	// GIVEN: PTO of 12 days a year (monthly accruals) since Jan 1, 2025,
+	//        a day taken on March 10 and a day pending on June 2
+	// WHEN: The monthly history from Jan 31 to Apr 30 and the balance as
+	//       of Feb 28 are requested
+	// THEN: The history accrues month by month and counts the March day
+	//       from March on, never the June one; the balance as of Feb 28
+	//       is the Feb 28 point
+
+	handler := setupTestHandler(t)
+	ctx := context.Background()
+
+	if err := handler.createPolicyFromJSON(ctx, timeoff.StandardPTOJSON("pto-test", "Test PTO", 12, 5)); err != nil {
+		t.Fatalf("Failed to create policy: %v", err)
+	}
+	since := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	if err := handler.Store.SaveEmployee(ctx, sqlite.Employee{ID: "emp-hist", Name: "History User", HireDate: since}); err != nil {
+		t.Fatalf("Failed to create employee: %v", err)
+	}
+	if err := handler.Store.SaveAssignment(ctx, sqlite.AssignmentRecord{
+		ID: "assign-hist", EntityID: "emp-hist", PolicyID: "pto-test",
+		EffectiveFrom: since, ConsumptionPriority: 1,
+	}); err != nil {
+		t.Fatalf("Failed to save assignment: %v", err)
+	}
+	day := func(id string, at time.Time, txType generic.TransactionType) generic.Transaction {
+		return generic.Transaction{
+			ID: generic.TransactionID(id), EntityID: "emp-hist", PolicyID: "pto-test",
+			ResourceType: timeoff.ResourcePTO, EffectiveAt: generic.TimePoint{Time: at},
+			Delta: generic.NewAmount(-1, generic.UnitDays), Type: txType, IdempotencyKey: id,
+		}
+	}
+	if err := handler.Store.AppendBatch(ctx, []generic.Transaction{
+		day("tx-march", time.Date(2025, 3, 10, 0, 0, 0, 0, time.UTC), generic.TxConsumption),
+		day("tx-june", time.Date(2025, 6, 2, 0, 0, 0, 0, time.UTC), generic.TxPending),
+	}); err != nil {
+		t.Fatalf("Failed to append transactions: %v", err)
+	}
+
+	router := NewRouter(handler)
+	get := func(path string, v any) {
+		t.Helper()
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, req)
+		if rec.Code != http.StatusOK {
+			t.Fatalf("GET %s: %d %s", path, rec.Code, rec.Body.String())
+		}
+		json.Unmarshal(rec.Body.Bytes(), v)
+	}
+
+	var history BalanceHistoryDTO
+	get("/api/employees/emp-hist/balance/history?from=2025-01-31&to=2025-04-30&interval=month", &history)
+	want := []struct {
+		date              string
+		accrued, consumed float64
+	}{{"2025-01-31", 1, 0}, {"2025-02-28", 2, 0}, {"2025-03-31", 3, 1}, {"2025-04-30", 4, 1}}
+	if len(history.Points) != len(want) {
+		t.Fatalf("Expected %d points, got %+v", len(want), history.Points)
+	}
+	for i, p := range history.Points {
+		w := want[i]
+		if p.Date != w.date || p.Accrued != w.accrued || p.Consumed != w.consumed || p.Pending != 0 {
+			t.Errorf("Point %d: %s accrued %.0f consumed %.0f pending %.0f; want %s %.0f %.0f 0",
+				i, p.Date, p.Accrued, p.Consumed, p.Pending, w.date, w.accrued, w.consumed)
+		}
+		if len(p.Policies) != 1 || p.Policies[0].PolicyID != "pto-test" {
+			t.Errorf("Point %s: expected pto-test's share, got %+v", p.Date, p.Policies)
+		}
+	}
+
+	var balance BalanceDTO
+	get("/api/employees/emp-hist/balance?as_of=2025-02-28", &balance)
+	if balance.AsOf != "2025-02-28" || len(balance.Policies) != 1 {
+		t.Fatalf("Expected the balance as of 2025-02-28, got %+v", balance)
+	}
+	if p := balance.Policies[0]; p.AccruedToDate != 2 || p.Consumed != 0 || p.Pending != 0 {
+		t.Errorf("As of Feb 28: accrued %.0f consumed %.0f pending %.0f; want 2, 0, 0", p.AccruedToDate, p.Consumed, p.Pending)
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/api/employees/emp-hist/balance/history?from=2020-01-01&to=2025-12-31", nil)
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("Six years of daily points: %d, want 400", rec.Code)
+	}
+}
+
+func TestBalanceAsOf_MatchesHistoryPoints(t *testing.T) {
+	// GIVEN: PTO of 12 days a year assigned from April 15, 2025 (mid
+	//        period), a day taken on June 2 and a day pending on Sep 1
+	// WHEN: The monthly history from Apr 30 to Oct 31 is requested, and
+	//       the balance as of each point's day
+	// THEN: Every balance equals its history point: accruals prorated
+	//       from April 15, and no booking after the day counted
+
+	handler := setupTestHandler(t)
+	ctx := context.Background()
+
+	if err := handler.createPolicyFromJSON(ctx, timeoff.StandardPTOJSON("pto-test", "Test PTO", 12, 5)); err != nil {
+		t.Fatalf("Failed to create policy: %v", err)
+	}
+	since := time.Date(2025, 4, 15, 0, 0, 0, 0, time.UTC)
+	if err := handler.Store.SaveEmployee(ctx, sqlite.Employee{ID: "emp-mid", Name: "Mid-year Hire", HireDate: since}); err != nil {
+		t.Fatalf("Failed to create employee: %v", err)
+	}
+	if err := handler.Store.SaveAssignment(ctx, sqlite.AssignmentRecord{
+		ID: "assign-mid", EntityID: "emp-mid", PolicyID: "pto-test",
+		EffectiveFrom: since, ConsumptionPriority: 1,
+	}); err != nil {
+		t.Fatalf("Failed to save assignment: %v", err)
+	}
+	day := func(id string, d time.Time, txType generic.TransactionType) generic.Transaction {
+		return generic.Transaction{
+			ID: generic.TransactionID(id), EntityID: "emp-mid", PolicyID: "pto-test",
+			ResourceType: timeoff.ResourcePTO, EffectiveAt: generic.TimePoint{Time: d},
+			Delta: generic.NewAmount(-1, generic.UnitDays), Type: txType, IdempotencyKey: id,
+		}
+	}
+	if err := handler.Store.AppendBatch(ctx, []generic.Transaction{
+		day("tx-jun", time.Date(2025, 6, 2, 0, 0, 0, 0, time.UTC), generic.TxConsumption),
+		day("tx-sep", time.Date(2025, 9, 1, 0, 0, 0, 0, time.UTC), generic.TxPending),
+	}); err != nil {
+		t.Fatalf("Failed to append: %v", err)
+	}
+
+	router := NewRouter(handler)
+	get := func(path string, v any) {
+		t.Helper()
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, req)
+		if rec.Code != http.StatusOK {
+			t.Fatalf("%s: expected 200, got %d: %s", path, rec.Code, rec.Body.String())
+		}
+		if err := json.Unmarshal(rec.Body.Bytes(), v); err != nil {
+			t.Fatalf("%s: failed to decode: %v", path, err)
+		}
+	}
+
+	var history BalanceHistoryDTO
+	get("/api/employees/emp-mid/balance/history?from=2025-04-30&to=2025-10-31&interval=month", &history)
+	if len(history.Points) < 7 {
+		t.Fatalf("Expected monthly points, got %d", len(history.Points))
+	}
+	for _, p := range history.Points {
+		var balance BalanceDTO
+		get("/api/employees/emp-mid/balance?as_of="+p.Date, &balance)
+		if len(balance.Policies) != 1 {
+			t.Fatalf("%s: expected one policy, got %+v", p.Date, balance.Policies)
+		}
+		b := balance.Policies[0]
+		if roundFloat(decimal.NewFromFloat(b.Available), 4) != p.Available || roundFloat(decimal.NewFromFloat(b.AccruedToDate), 4) != p.Accrued ||
+			roundFloat(decimal.NewFromFloat(b.Consumed), 4) != p.Consumed || roundFloat(decimal.NewFromFloat(b.Pending), 4) != p.Pending {
+			t.Errorf("%s: balance available %v accrued %v consumed %v pending %v; history %v %v %v %v",
+				p.Date, b.Available, b.AccruedToDate, b.Consumed, b.Pending, p.Available, p.Accrued, p.Consumed, p.Pending)
+		}
+	}
+	if first := history.Points[0]; first.Accrued >= 1 {
+		t.Errorf("Apr 30: expected accruals prorated from April 15, got %v", first.Accrued)
+	}
+}
@@ -0,0 +1,189 @@
+/*
+balance_projection.go - Forward projection ("when will I have N days?")
+
+PURPOSE:
+  Employees planning a long trip want the earliest day their balance will
+  reach an amount. The projection endpoint returns the first day on which
+  the available balance, summed over the employee's policies for the
+  resource type, reaches it.
+
+KEY CONCEPTS:
+  What is projected (generic.ProjectionEngine.Forecast):
+    Each day's balance is that of its period, like the balance endpoint:
+    pending and future-dated requests count. Periods not reached yet
+    start from the carryover their predecessor's reconciliation would
+    post, so carryover caps and expiry apply, and a policy never holds
+    more than its max_balance.
+
+  Non-deterministic accruals:
+    Grant-based policies and schedules that cannot predict future
+    accruals (hours worked) accrue nothing in the projection unless
+    ?expected_rate= gives an amount per month to assume, credited on the
+    first of each month.
+
+  Not reached:
+    reached is false and best/best_date give the highest balance in the
+    range, so the UI can say how close it gets.
+
+ENDPOINTS:
+  GET /api/employees/{id}/balance/projection?amount=&resource_type=pto
+      &from=&until=&expected_rate=
+
+SEE ALSO:
+  - generic/projection.go: Forecast
+  - handlers.go: GetBalance
+  - reconciliation.go: proposePeriod (the same carryover at period end)
+*/
+package api
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/shopspring/decimal"
+	"github.com/warp/resource-engine/generic"
+	"github.com/warp/resource-engine/timeoff"
+)
+
+// Projection range: until defaults to two years after from, and may be at
+// most five.
+const (
+	defaultProjectionYears = 2
+	maxProjectionYears     = 5
+)
+
+// GetBalanceProjection returns the first day the employee's available
+// balance reaches amount.
+// GET /api/employees/{id}/balance/projection?amount=10
+func (h *Handler) GetBalanceProjection(w http.ResponseWriter, r *http.Request) {
+	entityID := generic.EntityID(chi.URLParam(r, "id"))
+	q := r.URL.Query()
+	resourceType := q.Get("resource_type")
+	if resourceType == "" {
+		resourceType = string(timeoff.ResourcePTO) // Default
+	}
+
+	amount, err := decimal.NewFromString(q.Get("amount"))
+	if err != nil || !amount.IsPositive() {
+		writeError(w, http.StatusBadRequest, "amount must be a positive number", nil)
+		return
+	}
+	var rate *decimal.Decimal
+	if s := q.Get("expected_rate"); s != "" {
+		d, err := decimal.NewFromString(s)
+		if err != nil || d.IsNegative() {
+			writeError(w, http.StatusBadRequest, "expected_rate must be a number of at least 0 (per month)", nil)
+			return
+		}
+		rate = &d
+	}
+
+	from := generic.Today()
+	if s := q.Get("from"); s != "" {
+		t, err := time.Parse("2006-01-02", s)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, "Invalid from format (use YYYY-MM-DD)", err)
+			return
+		}
+		from = generic.TimePoint{Time: t}
+	}
+	until := from.AddYears(defaultProjectionYears)
+	if s := q.Get("until"); s != "" {
+		t, err := time.Parse("2006-01-02", s)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, "Invalid until format (use YYYY-MM-DD)", err)
+			return
+		}
+		until = generic.TimePoint{Time: t}
+	}
+	if until.Before(from) {
+		writeError(w, http.StatusBadRequest, "from must not be after until", nil)
+		return
+	}
+	if until.After(from.AddYears(maxProjectionYears)) {
+		writeError(w, http.StatusBadRequest, "until may be at most 5 years after from", nil)
+		return
+	}
+
+	ctx := r.Context()
+	cache, err := h.cache(ctx)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "Failed to load policies", err)
+		return
+	}
+	all, err := h.assignments(cache).GetByEntity(ctx, entityID)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "Failed to get assignments", err)
+		return
+	}
+
+	accruals := cache.accruals
+	unit := generic.UnitDays
+	var policies []generic.ForecastPolicy
+	for _, a := range all {
+		if a.Policy.ResourceType.ResourceID() != resourceType {
+			continue
+		}
+		if a.EffectiveTo != nil && a.EffectiveTo.Before(from) {
+			continue
+		}
+		p := generic.ForecastPolicy{Assignment: a, Accruals: accruals[a.PolicyID]}
+		if rate != nil {
+			p.ExpectedRate = &generic.Amount{Value: *rate, Unit: a.Policy.Unit}
+		}
+		policies = append(policies, p)
+		unit = a.Policy.Unit
+	}
+
+	engine := &generic.ProjectionEngine{Ledger: generic.NewLedger(h.Store)}
+	result, err := engine.Forecast(ctx, generic.ForecastInput{
+		EntityID: entityID,
+		Policies: policies,
+		Target:   generic.Amount{Value: amount, Unit: unit},
+		From:     from,
+		Until:    until,
+	})
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "Failed to project balance", err)
+		return
+	}
+
+	resp := BalanceProjectionDTO{
+		EntityID:     string(entityID),
+		ResourceType: resourceType,
+		Amount:       roundFloat(amount, 4),
+		From:         from.Time.Format("2006-01-02"),
+		Until:        until.Time.Format("2006-01-02"),
+		Reached:      result.Reached,
+		Unlimited:    result.Unlimited,
+		Available:    roundFloat(result.Available.Value, 4),
+		Best:         roundFloat(result.Best.Value, 4),
+		Policies:     []PolicyProjectionDTO{},
+	}
+	if rate != nil {
+		r := roundFloat(*rate, 4)
+		resp.ExpectedRate = &r
+	}
+	if result.Reached {
+		resp.Date = result.Date.Time.Format("2006-01-02")
+	}
+	if !result.BestDate.IsZero() {
+		resp.BestDate = result.BestDate.Time.Format("2006-01-02")
+	}
+	// Points are in the order of policies
+	for i, pt := range result.Points {
+		resp.Policies = append(resp.Policies, PolicyProjectionDTO{
+			PolicyID:    string(pt.PolicyID),
+			PolicyName:  policies[i].Assignment.Policy.Name,
+			Available:   roundFloat(pt.Available.Value, 4),
+			CarriedIn:   roundFloat(pt.CarriedIn.Value, 4),
+			Expired:     roundFloat(pt.Expired.Value, 4),
+			Expected:    roundFloat(pt.Expected.Value, 4),
+			Capped:      pt.Capped,
+			AssumedRate: policies[i].AssumesRate(),
+		})
+	}
+
+	writeJSON(w, http.StatusOK, resp)
+}
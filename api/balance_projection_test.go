@@ -0,0 +1,106 @@
+/*
+balance_projection_test.go - Unit tests for balance projections
+
+Tests for:
+- Forward projection (GetBalanceProjection: carryover, expiry, expected rate)
+*/
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/warp/resource-engine/generic"
+	"github.com/warp/resource-engine/store/sqlite"
+	"github.com/warp/resource-engine/timeoff"
+)
+
+func TestBalanceProjection_FirstDayAmountIsAvailable(t *testing.T) {
+	// GIVEN: 12 days a year (carryover capped at 5, the rest expires) with
+	//        3 days taken and 2 pending in 2025, and a grant-based policy
+	//        with 1 day granted
+	// WHEN: Projections are asked from Mar 15, 2025
+	// THEN: 7 days are available at once, 8 only from Jan 1, 2026 (12 plus
+	//       5 carried, 4 expired), 20 never; the grant-based policy
+	//       reaches 5 only with an expected rate of 2 a month
+
+	handler := setupTestHandler(t)
+	ctx := context.Background()
+
+	grants := `{"id": "pto-grants", "name": "Granted PTO", "resource_type": "pto", "unit": "days",
+		"period_type": "calendar_year", "consumption_mode": "consume_up_to_accrued"}`
+	for _, pj := range []string{timeoff.StandardPTOJSON("pto-test", "Test PTO", 12, 5), grants} {
+		if err := handler.createPolicyFromJSON(ctx, pj); err != nil {
+			t.Fatalf("Failed to create policy: %v", err)
+		}
+	}
+	since := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	for _, a := range []struct{ emp, policy string }{{"emp-trip", "pto-test"}, {"emp-hourly", "pto-grants"}} {
+		if err := handler.Store.SaveEmployee(ctx, sqlite.Employee{ID: a.emp, Name: a.emp, HireDate: since}); err != nil {
+			t.Fatalf("Failed to create employee: %v", err)
+		}
+		if err := handler.Store.SaveAssignment(ctx, sqlite.AssignmentRecord{
+			ID: "assign-" + a.emp, EntityID: a.emp, PolicyID: a.policy,
+			EffectiveFrom: since, ConsumptionPriority: 1,
+		}); err != nil {
+			t.Fatalf("Failed to save assignment: %v", err)
+		}
+	}
+	tx := func(id, emp, policy string, at time.Time, delta float64, txType generic.TransactionType) generic.Transaction {
+		return generic.Transaction{
+			ID: generic.TransactionID(id), EntityID: generic.EntityID(emp), PolicyID: generic.PolicyID(policy),
+			ResourceType: timeoff.ResourcePTO, EffectiveAt: generic.TimePoint{Time: at},
+			Delta: generic.NewAmount(delta, generic.UnitDays), Type: txType, IdempotencyKey: id,
+		}
+	}
+	if err := handler.Store.AppendBatch(ctx, []generic.Transaction{
+		tx("tx-taken", "emp-trip", "pto-test", time.Date(2025, 4, 1, 0, 0, 0, 0, time.UTC), -3, generic.TxConsumption),
+		tx("tx-pending", "emp-trip", "pto-test", time.Date(2025, 6, 2, 0, 0, 0, 0, time.UTC), -2, generic.TxPending),
+		tx("tx-grant", "emp-hourly", "pto-grants", time.Date(2025, 3, 1, 0, 0, 0, 0, time.UTC), 1, generic.TxGrant),
+	}); err != nil {
+		t.Fatalf("Failed to append transactions: %v", err)
+	}
+
+	router := NewRouter(handler)
+	project := func(emp, query string) (BalanceProjectionDTO, int) {
+		t.Helper()
+		req := httptest.NewRequest(http.MethodGet, "/api/employees/"+emp+"/balance/projection?from=2025-03-15&"+query, nil)
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, req)
+		var p BalanceProjectionDTO
+		json.Unmarshal(rec.Body.Bytes(), &p)
+		return p, rec.Code
+	}
+
+	if p, _ := project("emp-trip", "amount=7"); !p.Reached || p.Date != "2025-03-15" || p.Available != 7 {
+		t.Errorf("7 days: expected available on from (12 - 3 taken - 2 pending), got %+v", p)
+	}
+	p, _ := project("emp-trip", "amount=8")
+	if !p.Reached || p.Date != "2026-01-01" || p.Available != 17 || len(p.Policies) != 1 {
+		t.Fatalf("8 days: expected Jan 1, 2026 with 17, got %+v", p)
+	}
+	if pp := p.Policies[0]; pp.CarriedIn != 5 || pp.Expired != 4 || pp.AssumedRate {
+		t.Errorf("8 days: expected 5 carried and 4 expired, got %+v", pp)
+	}
+	if p, _ := project("emp-trip", "amount=20&until=2026-12-31"); p.Reached || p.Date != "" || p.Best != 17 || p.BestDate != "2026-01-01" {
+		t.Errorf("20 days: expected not reached, best 17 on Jan 1, 2026, got %+v", p)
+	}
+
+	if p, _ := project("emp-hourly", "amount=5&until=2025-12-31"); p.Reached || p.Best != 1 {
+		t.Errorf("Grant-based without a rate: expected only the 1 granted, got %+v", p)
+	}
+	p, _ = project("emp-hourly", "amount=5&expected_rate=2")
+	if !p.Reached || p.Date != "2025-05-01" || len(p.Policies) != 1 || p.Policies[0].Expected != 4 || !p.Policies[0].AssumedRate {
+		t.Errorf("Grant-based at 2 a month: expected May 1 (1 + 2 + 2), got %+v", p)
+	}
+
+	for _, query := range []string{"", "amount=-1", "amount=5&expected_rate=x", "amount=5&until=2035-01-01"} {
+		if _, code := project("emp-trip", query); code != http.StatusBadRequest {
+			t.Errorf("%q: %d, want 400", query, code)
+		}
+	}
+}
@@ -36,13 +36,13 @@ func TestCalculateBalance_NewEmployee_MidDecemberHire(t *testing.T) {
 	}
 
 	// Calculate with hire date prorating
-	balance := calculateBalanceWithHireDate(
+	balance := generic.ComputeBalance(
 		nil, // no transactions
 		period,
 		generic.UnitDays,
 		accrual,
-		periodEnd, // asOf
 		hireDate,  // hire date
+		periodEnd, // asOf
 	)
 
 	// Dec 15 hire misses Dec 1 accrual, so 0 days accrued
@@ -67,13 +67,13 @@ func TestCalculateBalance_NewEmployee_EarlyDecemberHire(t *testing.T) {
 		Frequency:  generic.FreqMonthly,
 	}
 
-	balance := calculateBalanceWithHireDate(
+	balance := generic.ComputeBalance(
 		nil,
 		period,
 		generic.UnitDays,
 		accrual,
-		periodEnd,
 		hireDate,
+		periodEnd,
 	)
 
 	accruedDays, _ := balance.AccruedToDate.Value.Float64()
@@ -97,13 +97,13 @@ func TestCalculateBalance_NewEmployee_JulyHire(t *testing.T) {
 		Frequency:  generic.FreqMonthly,
 	}
 
-	balance := calculateBalanceWithHireDate(
+	balance := generic.ComputeBalance(
 		nil,
 		period,
 		generic.UnitDays,
 		accrual,
-		periodEnd,
 		hireDate,
+		periodEnd,
 	)
 
 	accruedDays, _ := balance.AccruedToDate.Value.Float64()
@@ -127,13 +127,13 @@ func TestCalculateBalance_ExistingEmployee_FullYear(t *testing.T) {
 		Frequency:  generic.FreqMonthly,
 	}
 
-	balance := calculateBalanceWithHireDate(
+	balance := generic.ComputeBalance(
 		nil,
 		period,
 		generic.UnitDays,
 		accrual,
-		periodEnd,
 		hireDate,
+		periodEnd,
 	)
 
 	accruedDays, _ := balance.AccruedToDate.Value.Float64()
@@ -165,13 +165,13 @@ func TestCalculateBalance_WithConsumption(t *testing.T) {
 		},
 	}
 
-	balance := calculateBalanceWithHireDate(
+	balance := generic.ComputeBalance(
 		txs,
 		period,
 		generic.UnitDays,
 		accrual,
-		periodEnd,
 		hireDate,
+		periodEnd,
 	)
 
 	// CurrentAccrued = AccruedToDate - TotalConsumed + Adjustments = 24 - 10 + 0 = 14
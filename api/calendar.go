@@ -0,0 +1,304 @@
+/*
+calendar.go - iCalendar subscription feeds for time off
+
+PURPOSE:
+  Serves RFC 5545 feeds that calendar apps subscribe to. Each feed is
+  reached through an unguessable token, because calendar clients cannot
+  send credentials - the URL itself is the secret.
+
+SCOPES:
+  self:    The owner's own days off (with reasons)
+  team:    Everyone sharing the owner's team (no reasons)
+  reports: The owner's direct reports, by manager_id (no reasons)
+
+  Membership is resolved on every fetch, so people joining or leaving a
+  team show up in existing subscriptions automatically.
+
+FEED CONTENT:
+  Days from FeedLookbackDays ago to FeedLookaheadDays ahead, read via
+  TimeOffLedger.GetDaysOff and merged into spans by timeoff.MergeDaysOff.
+  Canceled days (reversals) drop out on the next fetch.
+
+TENANTS:
+  Tokens are unique across tenants. The feed URL carries no tenant; the
+  token's tenant is used for the fetch, whatever X-Tenant-ID says.
+
+ENDPOINTS:
+  GET    /api/employees/{id}/calendar-feeds           List feeds
+  POST   /api/employees/{id}/calendar-feeds           Create feed {"scope":"team"}
+  DELETE /api/employees/{id}/calendar-feeds/{token}   Revoke feed
+  GET    /api/calendar/{token}.ics                    The feed itself
+
+  Feeds are managed by their owner and admins only (ownerOnly): the
+  tokens are credentials, so a manager must not create, read or revoke
+  their reports'.
+
+SEE ALSO:
+  - timeoff/ical.go: Span merging and ICS rendering
+  - timeoff/ledger.go: GetDaysOff
+*/
+package api
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/warp/resource-engine/generic"
+	"github.com/warp/resource-engine/store/sqlite"
+	"github.com/warp/resource-engine/timeoff"
+)
+
+// Feed window relative to today.
+const (
+	FeedLookbackDays  = 180
+	FeedLookaheadDays = 365
+)
+
+// Calendar feed scopes.
+const (
+	FeedScopeSelf    = "self"
+	FeedScopeTeam    = "team"
+	FeedScopeReports = "reports"
+)
+
+// =============================================================================
+// FEED MANAGEMENT
+// =============================================================================
+
+// ListCalendarFeeds returns the calendar feeds an employee has created.
+// GET /api/employees/{id}/calendar-feeds
+func (h *Handler) ListCalendarFeeds(w http.ResponseWriter, r *http.Request) {
+	ownerID := chi.URLParam(r, "id")
+
+	feeds, err := h.Store.GetCalendarFeedsByOwner(r.Context(), ownerID)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "Failed to get calendar feeds", err)
+		return
+	}
+
+	dtos := make([]CalendarFeedDTO, len(feeds))
+	for i, f := range feeds {
+		dtos[i] = toCalendarFeedDTO(f)
+	}
+	writeJSON(w, http.StatusOK, dtos)
+}
+
+// CreateCalendarFeed creates a new token-protected calendar feed.
+// POST /api/employees/{id}/calendar-feeds
+func (h *Handler) CreateCalendarFeed(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	ownerID := chi.URLParam(r, "id")
+
+	var req CreateCalendarFeedRequest
+	if r.ContentLength != 0 {
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			writeError(w, http.StatusBadRequest, "Invalid request body", err)
+			return
+		}
+	}
+	if req.Scope == "" {
+		req.Scope = FeedScopeSelf
+	}
+	if req.Scope != FeedScopeSelf && req.Scope != FeedScopeTeam && req.Scope != FeedScopeReports {
+		writeError(w, http.StatusBadRequest, "Invalid scope (use self, team or reports)", nil)
+		return
+	}
+
+	owner, err := h.Store.GetEmployee(ctx, ownerID)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "Failed to get employee", err)
+		return
+	}
+	if owner == nil {
+		writeError(w, http.StatusNotFound, "Employee not found", nil)
+		return
+	}
+	if req.Scope == FeedScopeTeam && owner.Team == "" {
+		writeError(w, http.StatusBadRequest, "Employee has no team", nil)
+		return
+	}
+
+	token, err := newFeedToken()
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "Failed to generate token", err)
+		return
+	}
+
+	feed := sqlite.CalendarFeed{
+		Token:     token,
+		OwnerID:   ownerID,
+		Scope:     req.Scope,
+		CreatedAt: time.Now().UTC(),
+	}
+	if err := h.Store.SaveCalendarFeed(ctx, feed); err != nil {
+		writeError(w, http.StatusInternalServerError, "Failed to save calendar feed", err)
+		return
+	}
+
+	writeJSON(w, http.StatusCreated, toCalendarFeedDTO(feed))
+}
+
+// RevokeCalendarFeed revokes a feed token. The URL stops working immediately.
+// DELETE /api/employees/{id}/calendar-feeds/{token}
+func (h *Handler) RevokeCalendarFeed(w http.ResponseWriter, r *http.Request) {
+	ownerID := chi.URLParam(r, "id")
+	token := chi.URLParam(r, "token")
+
+	revoked, err := h.Store.RevokeCalendarFeed(r.Context(), ownerID, token)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "Failed to revoke calendar feed", err)
+		return
+	}
+	if !revoked {
+		writeError(w, http.StatusNotFound, "Calendar feed not found", nil)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// newFeedToken returns 256 bits of randomness, URL-safe.
+func newFeedToken() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+func toCalendarFeedDTO(f sqlite.CalendarFeed) CalendarFeedDTO {
+	dto := CalendarFeedDTO{
+		Token:     f.Token,
+		Scope:     f.Scope,
+		URL:       fmt.Sprintf("/api/calendar/%s.ics", f.Token),
+		CreatedAt: f.CreatedAt.Format(time.RFC3339),
+	}
+	if f.RevokedAt != nil {
+		dto.RevokedAt = strPtr(f.RevokedAt.Format(time.RFC3339))
+	}
+	return dto
+}
+
+// =============================================================================
+// FEED DELIVERY
+// =============================================================================
+
+// GetCalendarFeed serves the iCalendar document for a feed token.
+// GET /api/calendar/{token}.ics
+func (h *Handler) GetCalendarFeed(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	token := strings.TrimSuffix(chi.URLParam(r, "token"), ".ics")
+
+	feed, err := h.Store.GetCalendarFeed(ctx, token)
+	if err != nil {
+		http.Error(w, "failed to load feed", http.StatusInternalServerError)
+		return
+	}
+	if feed == nil {
+		http.NotFound(w, r)
+		return
+	}
+
+	// Calendar clients send no tenant header; the token decides
+	ctx = generic.WithTenant(ctx, feed.TenantID)
+
+	owner, err := h.Store.GetEmployee(ctx, feed.OwnerID)
+	if err != nil {
+		http.Error(w, "failed to load feed", http.StatusInternalServerError)
+		return
+	}
+	if owner == nil {
+		http.NotFound(w, r)
+		return
+	}
+
+	now := time.Now().UTC()
+	cal, err := h.buildCalendar(ctx, feed, owner, now)
+	if err != nil {
+		http.Error(w, "failed to build feed", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/calendar; charset=utf-8")
+	w.Header().Set("Cache-Control", "private, max-age=300")
+	w.WriteHeader(http.StatusOK)
+	cal.WriteICS(w, now)
+}
+
+// buildCalendar collects the days off for everyone covered by the feed.
+func (h *Handler) buildCalendar(ctx context.Context, feed *sqlite.CalendarFeed, owner *sqlite.Employee, now time.Time) (timeoff.Calendar, error) {
+	members, err := h.feedMembers(ctx, feed, owner)
+	if err != nil {
+		return timeoff.Calendar{}, err
+	}
+
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+	from := today.AddDate(0, 0, -FeedLookbackDays)
+	to := today.AddDate(0, 0, FeedLookaheadDays)
+
+	ledger := timeoff.NewTimeOffLedger(h.Store)
+	cal := timeoff.Calendar{Name: feedName(feed, owner)}
+
+	for _, m := range members {
+		days, err := ledger.GetDaysOff(ctx, generic.EntityID(m.ID), from, to)
+		if err != nil {
+			return timeoff.Calendar{}, err
+		}
+
+		for _, span := range timeoff.MergeDaysOff(days) {
+			label := m.Name
+			description := ""
+			if feed.Scope == FeedScopeSelf {
+				label = "Time off"
+				description = strings.Join(span.Reasons, "; ")
+			}
+			cal.Events = append(cal.Events, span.Event(generic.EntityID(m.ID), label, description))
+		}
+	}
+	return cal, nil
+}
+
+// feedMembers resolves who a feed covers at fetch time.
+func (h *Handler) feedMembers(ctx context.Context, feed *sqlite.CalendarFeed, owner *sqlite.Employee) ([]sqlite.Employee, error) {
+	if feed.Scope == FeedScopeSelf {
+		return []sqlite.Employee{*owner}, nil
+	}
+
+	employees, err := h.Store.ListEmployees(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var members []sqlite.Employee
+	for _, e := range employees {
+		switch feed.Scope {
+		case FeedScopeTeam:
+			if owner.Team != "" && e.Team == owner.Team {
+				members = append(members, e)
+			}
+		case FeedScopeReports:
+			if e.ManagerID == owner.ID && e.ID != owner.ID {
+				members = append(members, e)
+			}
+		}
+	}
+	return members, nil
+}
+
+func feedName(feed *sqlite.CalendarFeed, owner *sqlite.Employee) string {
+	switch feed.Scope {
+	case FeedScopeTeam:
+		return fmt.Sprintf("%s team - Time Off", owner.Team)
+	case FeedScopeReports:
+		return fmt.Sprintf("%s's reports - Time Off", owner.Name)
+	default:
+		return fmt.Sprintf("%s - Time Off", owner.Name)
+	}
+}
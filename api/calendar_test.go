@@ -0,0 +1,190 @@
+/*
+calendar_test.go - Unit tests for calendar feeds
+
+Tests for:
+- Calendar feeds (CreateCalendarFeed, GetCalendarFeed)
+- Feed management rights (ownerOnly)
+*/
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/warp/resource-engine/generic"
+	"github.com/warp/resource-engine/store/sqlite"
+	"github.com/warp/resource-engine/timeoff"
+)
+
+func TestCalendarFeed_TeamFeedMergesDaysAndHonorsRevocation(t *testing.T) {
+	// GIVEN: Two teammates; Alice has 3 consecutive approved days, Bob 1 pending day
+	// WHEN: Alice creates a team feed and it is fetched
+	// THEN: Alice's days are one event, Bob's day is tentative; cancelling
+	//       Alice's middle day splits her event; a revoked token returns 404
+
+	handler := setupTestHandler(t)
+	ctx := context.Background()
+	router := NewRouter(handler)
+
+	hire := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	for _, e := range []sqlite.Employee{
+		{ID: "alice", Name: "Alice", Team: "eng", HireDate: hire},
+		{ID: "bob", Name: "Bob", Team: "eng", HireDate: hire},
+	} {
+		if err := handler.Store.SaveEmployee(ctx, e); err != nil {
+			t.Fatalf("Failed to save employee: %v", err)
+		}
+	}
+
+	now := time.Now().UTC()
+	start := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC).AddDate(0, 0, 14)
+	dayOff := func(entity string, d time.Time, id string, txType generic.TransactionType) generic.Transaction {
+		return generic.Transaction{
+			ID: generic.TransactionID(id), EntityID: generic.EntityID(entity), PolicyID: "pto",
+			ResourceType: timeoff.ResourcePTO, EffectiveAt: generic.TimePoint{Time: d},
+			Delta: generic.NewAmount(-1, generic.UnitDays), Type: txType,
+			ReferenceID: "req-" + entity, IdempotencyKey: id,
+		}
+	}
+	if err := handler.Store.AppendBatch(ctx, []generic.Transaction{
+		dayOff("alice", start, "a-0", generic.TxConsumption),
+		dayOff("alice", start.AddDate(0, 0, 1), "a-1", generic.TxConsumption),
+		dayOff("alice", start.AddDate(0, 0, 2), "a-2", generic.TxConsumption),
+		dayOff("bob", start, "b-0", generic.TxPending),
+	}); err != nil {
+		t.Fatalf("Failed to append days off: %v", err)
+	}
+
+	req := httptest.NewRequest(http.MethodPost, "/api/employees/alice/calendar-feeds", strings.NewReader(`{"scope":"team"}`))
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("Expected 201, got %d: %s", rec.Code, rec.Body.String())
+	}
+	var feed CalendarFeedDTO
+	if err := json.Unmarshal(rec.Body.Bytes(), &feed); err != nil {
+		t.Fatalf("Failed to decode feed: %v", err)
+	}
+	if len(feed.Token) < 40 {
+		t.Errorf("Token should be long and random, got %q", feed.Token)
+	}
+
+	fetch := func() *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, feed.URL, nil))
+		return rec
+	}
+
+	rec = fetch()
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Expected 200, got %d", rec.Code)
+	}
+	ics := rec.Body.String()
+	if n := strings.Count(ics, "BEGIN:VEVENT"); n != 2 {
+		t.Fatalf("Expected 2 events, got %d:\n%s", n, ics)
+	}
+	if !strings.Contains(ics, "DTEND;VALUE=DATE:"+start.AddDate(0, 0, 3).Format("20060102")) {
+		t.Errorf("Alice's 3 days should be a single event ending after day 3:\n%s", ics)
+	}
+	if !strings.Contains(ics, "SUMMARY:Bob: PTO (pending)") || !strings.Contains(ics, "STATUS:TENTATIVE") {
+		t.Errorf("Bob's pending day should be tentative:\n%s", ics)
+	}
+
+	// Cancel Alice's middle day
+	if err := handler.Store.Append(ctx, generic.Transaction{
+		ID: "rev-a-1", EntityID: "alice", PolicyID: "pto", ResourceType: timeoff.ResourcePTO,
+		EffectiveAt: generic.TimePoint{Time: start.AddDate(0, 0, 1)}, Delta: generic.NewAmount(1, generic.UnitDays),
+		Type: generic.TxReversal, ReferenceID: "a-1", IdempotencyKey: "rev-a-1",
+	}); err != nil {
+		t.Fatalf("Failed to append reversal: %v", err)
+	}
+	if n := strings.Count(fetch().Body.String(), "BEGIN:VEVENT"); n != 3 {
+		t.Errorf("Expected cancellation to split Alice's event (3 events), got %d", n)
+	}
+
+	req = httptest.NewRequest(http.MethodDelete, "/api/employees/alice/calendar-feeds/"+feed.Token, nil)
+	rec = httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+	if rec.Code != http.StatusNoContent {
+		t.Fatalf("Expected 204 on revoke, got %d", rec.Code)
+	}
+	if rec := fetch(); rec.Code != http.StatusNotFound {
+		t.Errorf("Expected 404 for revoked feed, got %d", rec.Code)
+	}
+}
+
+func TestCalendarFeed_OnlyOwnerAndAdminManageFeeds(t *testing.T) {
+	// GIVEN: Alice (reporting to manager bob) with a feed, and tokens for
+	//        alice, bob and an admin
+	// WHEN: Each of them creates, lists and revokes alice's feeds
+	// THEN: Bob gets 403 for all three and alice's feed still works;
+	//       alice and the admin may do all three
+
+	handler := setupTestHandler(t)
+	tokens := NewStaticTokens()
+	tokens.Add("admin-token", Principal{Actor: generic.Actor{ID: "ops", Type: generic.ActorAdmin}, AllTenants: true})
+	tokens.Add("bob-token", Principal{Actor: generic.Actor{ID: "bob", Type: generic.ActorManager}})
+	tokens.Add("alice-token", Principal{Actor: generic.Actor{ID: "alice", Type: generic.ActorEmployee}})
+	handler.Auth = tokens
+	router := NewRouter(handler)
+	ctx := context.Background()
+
+	hire := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	for id, manager := range map[string]string{"alice": "bob", "bob": ""} {
+		if err := handler.Store.SaveEmployee(ctx, sqlite.Employee{ID: id, Name: id, ManagerID: manager, HireDate: hire}); err != nil {
+			t.Fatalf("Failed to save employee: %v", err)
+		}
+	}
+
+	do := func(method, path, token string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, nil)
+		req.Header.Set("Authorization", "Bearer "+token)
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, req)
+		return rec
+	}
+	create := func(token string) (CalendarFeedDTO, int) {
+		rec := do(http.MethodPost, "/api/employees/alice/calendar-feeds", token)
+		var feed CalendarFeedDTO
+		json.Unmarshal(rec.Body.Bytes(), &feed)
+		return feed, rec.Code
+	}
+
+	feed, code := create("alice-token")
+	if code != http.StatusCreated {
+		t.Fatalf("Expected alice to create her feed, got %d", code)
+	}
+	if _, code := create("bob-token"); code != http.StatusForbidden {
+		t.Errorf("bob creates alice's feed: expected 403, got %d", code)
+	}
+	if rec := do(http.MethodGet, "/api/employees/alice/calendar-feeds", "bob-token"); rec.Code != http.StatusForbidden {
+		t.Errorf("bob lists alice's feeds: expected 403, got %d: %s", rec.Code, rec.Body.String())
+	}
+	if rec := do(http.MethodDelete, "/api/employees/alice/calendar-feeds/"+feed.Token, "bob-token"); rec.Code != http.StatusForbidden {
+		t.Errorf("bob revokes alice's feed: expected 403, got %d", rec.Code)
+	}
+	if rec := do(http.MethodGet, "/api/calendar/"+feed.Token+".ics", ""); rec.Code != http.StatusOK {
+		t.Errorf("Expected alice's feed to still work, got %d", rec.Code)
+	}
+
+	for _, token := range []string{"alice-token", "admin-token"} {
+		feed, code := create(token)
+		if code != http.StatusCreated {
+			t.Fatalf("%s creates alice's feed: expected 201, got %d", token, code)
+		}
+		var feeds []CalendarFeedDTO
+		rec := do(http.MethodGet, "/api/employees/alice/calendar-feeds", token)
+		json.Unmarshal(rec.Body.Bytes(), &feeds)
+		if rec.Code != http.StatusOK || len(feeds) == 0 {
+			t.Errorf("%s lists alice's feeds: got %d with %d feeds", token, rec.Code, len(feeds))
+		}
+		if rec := do(http.MethodDelete, "/api/employees/alice/calendar-feeds/"+feed.Token, token); rec.Code != http.StatusNoContent {
+			t.Errorf("%s revokes alice's feed: expected 204, got %d", token, rec.Code)
+		}
+	}
+}
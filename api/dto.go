@@ -18,7 +18,8 @@ TYPES:
     EmployeeDTO, CreateEmployeeRequest
 
   Balance:
-    BalanceSummaryDTO, PolicyBalanceDTO, BalanceDisplayDTO
+    BalanceSummaryDTO, PolicyBalanceDTO, BalanceDisplayDTO,
+    BalanceHistoryDTO, BalancePointDTO
 
   Request:
     SubmitRequestDTO, RequestDTO
@@ -32,6 +33,29 @@ TYPES:
   Scenarios:
     ScenarioDTO, LoadScenarioRequest
 
+  Termination:
+    TerminateEmployeeRequest, TerminationStatementDTO, PolicySettlementDTO
+
+  Liability:
+    PayRateDTO, CreatePayRateRequest, LiabilityReportDTO
+
+  Calendar:
+    CalendarFeedDTO, CreateCalendarFeedRequest
+
+  Holidays:
+    HolidayImportResultDTO, HolidayCalendarDTO, EmployeeHolidayCalendarDTO
+
+  Tenants:
+    TenantDTO, CreateTenantRequest
+
+  Webhooks:
+    WebhookEndpointDTO, CreateWebhookEndpointRequest, WebhookDeliveryDTO,
+    OutboxEventDTO, ReplayWebhookRequest
+
+  Reconciliation:
+    ReconciliationBatchDTO, StartReconciliationBatchRequest,
+    ReconciliationItemDTO, ReconciliationPreviewDTO, RollbackResultDTO
+
 VALIDATION:
   Validation is done in handlers, not in DTOs. DTOs are pure data carriers.
   Future: Add struct tags for validation library.
@@ -43,6 +67,7 @@ SEE ALSO:
 package api
 
 import (
+	"encoding/json"
 	"time"
 
 	"github.com/warp/resource-engine/factory"
@@ -58,6 +83,8 @@ type EmployeeDTO struct {
 	ID        string `json:"id"`
 	Name      string `json:"name"`
 	Email     string `json:"email"`
+	Team      string `json:"team,omitempty"`
+	ManagerID string `json:"manager_id,omitempty"`
 	HireDate  string `json:"hire_date"`
 	CreatedAt string `json:"created_at,omitempty"`
 }
@@ -67,7 +94,9 @@ type CreateEmployeeRequest struct {
 	ID       string `json:"id"`
 	Name     string `json:"name"`
 	Email    string `json:"email"`
-	HireDate string `json:"hire_date"`
+	Team      string `json:"team,omitempty"`
+	ManagerID string `json:"manager_id,omitempty"`
+	HireDate  string `json:"hire_date"`
 }
 
 // PolicyDTO represents a policy in API responses.
@@ -117,6 +146,7 @@ type BalanceDTO struct {
 	TotalPending   float64              `json:"total_pending"`
 	Policies       []PolicyBalanceDTO   `json:"policies"`
 	AsOf           string               `json:"as_of"`
+	AsKnownAt      string               `json:"as_known_at,omitempty"` // RFC3339; bookings recorded later are left out
 }
 
 // PolicyBalanceDTO represents balance for a single policy.
@@ -133,21 +163,149 @@ type PolicyBalanceDTO struct {
 	RequiresApproval bool    `json:"requires_approval"`
 }
 
+// BalanceHistoryDTO is an employee's balance at regular points in a range.
+type BalanceHistoryDTO struct {
+	EntityID     string            `json:"entity_id"`
+	ResourceType string            `json:"resource_type"`
+	From         string            `json:"from"`
+	To           string            `json:"to"`
+	Interval     string            `json:"interval"` // day, week or month
+	AsKnownAt    string            `json:"as_known_at,omitempty"`
+	Points       []BalancePointDTO `json:"points"`
+}
+
+// BalancePointDTO is the balance at the end of one day, in total and per
+// policy.
+type BalancePointDTO struct {
+	Date      string                  `json:"date"`
+	Available float64                 `json:"available"`
+	Accrued   float64                 `json:"accrued"`
+	Consumed  float64                 `json:"consumed"`
+	Pending   float64                 `json:"pending"`
+	Policies  []PolicyBalancePointDTO `json:"policies"`
+}
+
+// PolicyBalancePointDTO is one policy's share of a BalancePointDTO.
+type PolicyBalancePointDTO struct {
+	PolicyID  string  `json:"policy_id"`
+	Available float64 `json:"available"`
+	Accrued   float64 `json:"accrued"`
+	Consumed  float64 `json:"consumed"`
+	Pending   float64 `json:"pending"`
+}
+
+// BalanceExplanationDTO itemizes how an employee's balance was computed.
+type BalanceExplanationDTO struct {
+	EntityID       string                        `json:"entity_id"`
+	ResourceType   string                        `json:"resource_type"`
+	TotalAvailable float64                       `json:"total_available"`
+	AsOf           string                        `json:"as_of"`
+	AsKnownAt      string                        `json:"as_known_at,omitempty"`
+	Policies       []PolicyBalanceExplanationDTO `json:"policies"`
+}
+
+// PolicyBalanceExplanationDTO is one policy's derivation. The figures are
+// those of PolicyBalanceDTO; TotalEntitlement here is the schedule alone,
+// before adjustments.
+type PolicyBalanceExplanationDTO struct {
+	PolicyID        string `json:"policy_id"`
+	PolicyName      string `json:"policy_name"`
+	PeriodStart     string `json:"period_start"`
+	PeriodEnd       string `json:"period_end"`
+	Unit            string `json:"unit"`
+	ConsumptionMode string `json:"consumption_mode"`
+	Rule            string `json:"rule"` // availability formula for the mode
+
+	Available        float64 `json:"available"`
+	AccruedToDate    float64 `json:"accrued_to_date"`
+	TotalEntitlement float64 `json:"total_entitlement"`
+	Consumed         float64 `json:"consumed"`
+	Pending          float64 `json:"pending"`
+	Adjustments      float64 `json:"adjustments"`
+
+	Granted      float64 `json:"granted"`       // Sum of grant transactions
+	AccruedBasis string  `json:"accrued_basis"` // "schedule" or "grants": the larger is accrued to date
+
+	Accruals     []ExplainedAccrualDTO     `json:"accruals"`
+	Transactions []ExplainedTransactionDTO `json:"transactions"`
+	Constraints  []ConstraintCheckDTO      `json:"constraints"`
+}
+
+// ExplainedAccrualDTO is one scheduled accrual event.
+type ExplainedAccrualDTO struct {
+	Date   string  `json:"date"`
+	Amount float64 `json:"amount"`
+	Reason string  `json:"reason"`
+	ToDate bool    `json:"to_date"` // Counted in accrued_to_date (on or before as_of)
+}
+
+// ExplainedTransactionDTO is one transaction and the component it feeds.
+type ExplainedTransactionDTO struct {
+	ID          string  `json:"id"`
+	Type        string  `json:"type"`
+	EffectiveAt string  `json:"effective_at"`
+	Delta       float64 `json:"delta"`
+	Component   string  `json:"component"` // accrued, consumed, pending or adjustments
+	Effect      float64 `json:"effect"`    // Contribution to the component
+	ReferenceID string  `json:"reference_id,omitempty"`
+	Reason      string  `json:"reason,omitempty"`
+}
+
+// ConstraintCheckDTO is one policy constraint evaluated against the balance.
+type ConstraintCheckDTO struct {
+	Name   string   `json:"name"`
+	Limit  *float64 `json:"limit,omitempty"`
+	Value  float64  `json:"value"`
+	Passed bool     `json:"passed"`
+	Detail string   `json:"detail"`
+}
+
+// BalanceProjectionDTO is the first day a balance reaches an amount.
+type BalanceProjectionDTO struct {
+	EntityID     string                `json:"entity_id"`
+	ResourceType string                `json:"resource_type"`
+	Amount       float64               `json:"amount"`
+	From         string                `json:"from"`
+	Until        string                `json:"until"`
+	ExpectedRate *float64              `json:"expected_rate,omitempty"` // Per month, for non-deterministic accruals
+	Reached      bool                  `json:"reached"`
+	Unlimited    bool                  `json:"unlimited,omitempty"`
+	Date         string                `json:"date,omitempty"` // First day available reaches amount
+	Available    float64               `json:"available"`      // On date
+	Best         float64               `json:"best"`           // Highest available in the range
+	BestDate     string                `json:"best_date,omitempty"`
+	Policies     []PolicyProjectionDTO `json:"policies"` // On date, or best_date if not reached
+}
+
+// PolicyProjectionDTO is one policy's share of a BalanceProjectionDTO.
+type PolicyProjectionDTO struct {
+	PolicyID    string  `json:"policy_id"`
+	PolicyName  string  `json:"policy_name"`
+	Available   float64 `json:"available"`
+	CarriedIn   float64 `json:"carried_in"` // Projected carryover into the period
+	Expired     float64 `json:"expired"`    // Projected expiry at the end of the period before
+	Expected    float64 `json:"expected"`   // Assumed at expected_rate so far in the period
+	Capped      bool    `json:"capped"`     // Held at max_balance
+	AssumedRate bool    `json:"assumed_rate"`
+}
+
 // TransactionDTO represents a ledger transaction.
 type TransactionDTO struct {
-	ID           string  `json:"id"`
-	EntityID     string  `json:"entity_id"`
-	PolicyID     string  `json:"policy_id"`
-	ResourceType string  `json:"resource_type"`
-	EffectiveAt  string  `json:"effective_at"`
-	Delta        float64 `json:"delta"`
-	Unit         string  `json:"unit"`
-	Type         string  `json:"type"`
-	ReferenceID  string  `json:"reference_id,omitempty"`
-	Reason       string  `json:"reason,omitempty"`
-	CreatedAt    string  `json:"created_at,omitempty"`
-	Balance      float64 `json:"balance,omitempty"`      // Balance at this transaction date
-	BalanceAfter float64 `json:"balance_after,omitempty"` // Balance after this transaction (for policy changes)
+	ID            string  `json:"id"`
+	EntityID      string  `json:"entity_id"`
+	PolicyID      string  `json:"policy_id"`
+	ResourceType  string  `json:"resource_type"`
+	EffectiveAt   string  `json:"effective_at"`
+	Delta         float64 `json:"delta"`
+	Unit          string  `json:"unit"`
+	Type          string  `json:"type"`
+	ReferenceID   string  `json:"reference_id,omitempty"`
+	Reason        string  `json:"reason,omitempty"`
+	CreatedBy     string  `json:"created_by,omitempty"`      // Actor who wrote it (when authenticated)
+	CreatedByType string  `json:"created_by_type,omitempty"` // employee, manager, admin, system
+	CreatedAt     string  `json:"created_at,omitempty"`
+	Balance       float64 `json:"balance,omitempty"`       // Balance at this transaction date
+	BalanceAfter  float64 `json:"balance_after,omitempty"` // Balance after this transaction (for policy changes)
 }
 
 // TimeOffRequestDTO represents a time-off request.
@@ -180,6 +338,7 @@ type RolloverRequestDTO struct {
 	EntityID   *string `json:"entity_id,omitempty"`   // nil = all entities
 	PolicyID   *string `json:"policy_id,omitempty"`   // nil = all policies
 	PeriodEnd  string  `json:"period_end"`            // ISO date
+	DryRun     bool    `json:"dry_run,omitempty"`     // Preview only, write nothing
 }
 
 // RolloverResultDTO is the result of a rollover operation.
@@ -189,14 +348,16 @@ type RolloverResultDTO struct {
 	CarriedOver float64 `json:"carried_over"`
 	Expired     float64 `json:"expired"`
 	Transactions []TransactionDTO `json:"transactions"`
+	Error       string  `json:"error,omitempty"` // Set when posting failed; nothing was posted
 }
 
 // AdjustmentRequestDTO is the request to make a manual adjustment.
 type AdjustmentRequestDTO struct {
-	EntityID string  `json:"entity_id"`
-	PolicyID string  `json:"policy_id"`
-	Delta    float64 `json:"delta"`
-	Reason   string  `json:"reason"`
+	EntityID    string  `json:"entity_id"`
+	PolicyID    string  `json:"policy_id"`
+	Delta       float64 `json:"delta"`
+	Reason      string  `json:"reason"`
+	EffectiveAt string  `json:"effective_at,omitempty"` // YYYY-MM-DD; default today
 }
 
 // ScenarioDTO represents a demo scenario.
@@ -221,17 +382,28 @@ type ErrorResponse struct {
 func toTransactionDTO(tx generic.Transaction) TransactionDTO {
 	delta, _ := tx.Delta.Value.Float64()
 	return TransactionDTO{
-		ID:           string(tx.ID),
-		EntityID:     string(tx.EntityID),
-		PolicyID:     string(tx.PolicyID),
-		ResourceType: tx.ResourceType.ResourceID(),
-		EffectiveAt:  tx.EffectiveAt.Time.Format(time.RFC3339),
-		Delta:        delta,
-		Unit:         string(tx.Delta.Unit),
-		Type:         string(tx.Type),
-		ReferenceID:  tx.ReferenceID,
-		Reason:       tx.Reason,
+		ID:            string(tx.ID),
+		EntityID:      string(tx.EntityID),
+		PolicyID:      string(tx.PolicyID),
+		ResourceType:  tx.ResourceType.ResourceID(),
+		EffectiveAt:   tx.EffectiveAt.Time.Format(time.RFC3339),
+		Delta:         delta,
+		Unit:          string(tx.Delta.Unit),
+		Type:          string(tx.Type),
+		ReferenceID:   tx.ReferenceID,
+		Reason:        tx.Reason,
+		CreatedBy:     tx.CreatedBy,
+		CreatedByType: tx.CreatedByType,
+		CreatedAt:     formatCreatedAt(tx.CreatedAt),
+	}
+}
+
+// formatCreatedAt is empty for transactions not read back from a store.
+func formatCreatedAt(at generic.TimePoint) string {
+	if at.Time.IsZero() {
+		return ""
 	}
+	return at.Time.Format(time.RFC3339)
 }
 
 func toTransactionDTOs(txs []generic.Transaction) []TransactionDTO {
@@ -241,3 +413,382 @@ func toTransactionDTOs(txs []generic.Transaction) []TransactionDTO {
 	}
 	return dtos
 }
+
+// =============================================================================
+// TERMINATION TYPES
+// =============================================================================
+
+// TerminateEmployeeRequest is the request to terminate an employee.
+type TerminateEmployeeRequest struct {
+	TerminationDate string `json:"termination_date"` // ISO date, last day of employment
+	Payout          *bool  `json:"payout,omitempty"` // nil = true; false = forfeit remaining balance
+	Reason          string `json:"reason,omitempty"`
+}
+
+// TerminationStatementDTO is the final statement returned for payroll.
+type TerminationStatementDTO struct {
+	EntityID         string                `json:"entity_id"`
+	EmployeeName     string                `json:"employee_name"`
+	HireDate         string                `json:"hire_date"`
+	TerminationDate  string                `json:"termination_date"`
+	Reason           string                `json:"reason,omitempty"`
+	Payout           bool                  `json:"payout"`
+	EndedAssignments int                   `json:"ended_assignments"`
+	CancelledDays    int                   `json:"cancelled_days"`
+	Policies         []PolicySettlementDTO `json:"policies"`
+	Transactions     []TransactionDTO      `json:"transactions"`
+}
+
+// PolicySettlementDTO is the settlement of a single policy.
+// Amount is positive for payout/forfeit and negative for clawback.
+type PolicySettlementDTO struct {
+	PolicyID         string  `json:"policy_id"`
+	PolicyName       string  `json:"policy_name"`
+	ResourceType     string  `json:"resource_type"`
+	Unit             string  `json:"unit"`
+	ConsumptionMode  string  `json:"consumption_mode"`
+	TotalEntitlement float64 `json:"total_entitlement"`
+	AccruedToDate    float64 `json:"accrued_to_date"`
+	Consumed         float64 `json:"consumed"`
+	Pending          float64 `json:"pending"`
+	Adjustments      float64 `json:"adjustments"`
+	FinalBalance     float64 `json:"final_balance"`
+	Action           string  `json:"action"` // none, payout, forfeit, clawback
+	Amount           float64 `json:"amount"`
+	TransactionID    string  `json:"transaction_id,omitempty"`
+}
+
+// =============================================================================
+// PAY RATE & LIABILITY TYPES
+// =============================================================================
+
+// PayRateDTO represents an effective-dated pay rate.
+type PayRateDTO struct {
+	ID            string  `json:"id"`
+	EntityID      string  `json:"entity_id"`
+	Rate          string  `json:"rate"` // Decimal string, money per unit
+	Currency      string  `json:"currency"`
+	Unit          string  `json:"unit"` // days or hours
+	HoursPerDay   float64 `json:"hours_per_day"`
+	EffectiveFrom string  `json:"effective_from"`
+}
+
+// CreatePayRateRequest is the request to add a pay rate for an employee.
+type CreatePayRateRequest struct {
+	Rate          string  `json:"rate"`                    // e.g. "425.50"
+	Currency      string  `json:"currency"`                // ISO 4217
+	Unit          string  `json:"unit,omitempty"`          // days (default) or hours
+	HoursPerDay   float64 `json:"hours_per_day,omitempty"` // default 8
+	EffectiveFrom string  `json:"effective_from"`          // ISO date
+}
+
+// LiabilityReportDTO is the accrued leave liability as of a date.
+// Money is never summed across currencies.
+type LiabilityReportDTO struct {
+	AsOf         string              `json:"as_of"`
+	AsKnownAt    string              `json:"as_known_at,omitempty"`
+	Lines        []LiabilityLineDTO  `json:"lines"`
+	ByPolicy     []LiabilityGroupDTO `json:"by_policy"`
+	ByTeam       []LiabilityGroupDTO `json:"by_team"`
+	ByCurrency   []LiabilityGroupDTO `json:"by_currency"`
+	MissingRates []string            `json:"missing_rates"` // Employees with balance but no rate
+}
+
+// LiabilityLineDTO is one employee's outstanding balance in one policy.
+type LiabilityLineDTO struct {
+	EntityID     string  `json:"entity_id"`
+	EmployeeName string  `json:"employee_name"`
+	Team         string  `json:"team"`
+	PolicyID     string  `json:"policy_id"`
+	PolicyName   string  `json:"policy_name"`
+	ResourceType string  `json:"resource_type"`
+	Unit         string  `json:"unit"`
+	Outstanding  float64 `json:"outstanding"`
+	Rate         string  `json:"rate,omitempty"`
+	RateUnit     string  `json:"rate_unit,omitempty"`
+	Currency     string  `json:"currency,omitempty"`
+	Value        float64 `json:"value"`
+	Receivable   float64 `json:"receivable"` // Owed back on an overdrawn balance
+}
+
+// LiabilityGroupDTO is a liability subtotal for one group in one currency.
+type LiabilityGroupDTO struct {
+	Key        string  `json:"key"`
+	Currency   string  `json:"currency"`
+	Value      float64 `json:"value"`
+	Receivable float64 `json:"receivable"`
+	Lines      int     `json:"lines"`
+}
+
+// =============================================================================
+// CALENDAR FEED TYPES
+// =============================================================================
+
+// CreateCalendarFeedRequest is the request to create an iCalendar feed.
+type CreateCalendarFeedRequest struct {
+	Scope string `json:"scope"` // self (default), team, reports
+}
+
+// CalendarFeedDTO represents a calendar feed subscription.
+// The URL is the only credential needed to read the feed.
+type CalendarFeedDTO struct {
+	Token     string  `json:"token"`
+	Scope     string  `json:"scope"`
+	URL       string  `json:"url"`
+	CreatedAt string  `json:"created_at"`
+	RevokedAt *string `json:"revoked_at,omitempty"`
+}
+
+// =============================================================================
+// HOLIDAY IMPORT TYPES
+// =============================================================================
+
+// HolidayImportResultDTO is the outcome of a holiday file import.
+type HolidayImportResultDTO struct {
+	CompanyID   string                  `json:"company_id"`
+	CalendarID  string                  `json:"calendar_id,omitempty"`
+	Format      string                  `json:"format"`
+	DryRun      bool                    `json:"dry_run"`
+	ReplaceYear int                     `json:"replace_year,omitempty"`
+	Parsed      int                     `json:"parsed"`
+	Created     int                     `json:"created"`
+	Duplicates  int                     `json:"duplicates"`
+	OutOfYear   int                     `json:"out_of_year"`
+	Removed     int                     `json:"removed"`
+	Holidays    []HolidayImportItemDTO  `json:"holidays"`
+	Issues      []HolidayImportIssueDTO `json:"issues"`
+}
+
+// HolidayImportItemDTO is one parsed holiday and what happened to it.
+type HolidayImportItemDTO struct {
+	ID        string `json:"id"`
+	Date      string `json:"date"`
+	Name      string `json:"name"`
+	Recurring bool   `json:"recurring"`
+	Rule      string `json:"rule,omitempty"`
+	Status    string `json:"status"` // created, duplicate, out_of_year
+}
+
+// HolidayImportIssueDTO is a row that could not be parsed.
+type HolidayImportIssueDTO struct {
+	Line    int    `json:"line"`
+	Message string `json:"message"`
+}
+
+// =============================================================================
+// HOLIDAY CALENDAR TYPES
+// =============================================================================
+
+// HolidayCalendarDTO represents a named holiday calendar.
+type HolidayCalendarDTO struct {
+	ID        string `json:"id"`
+	CompanyID string `json:"company_id"`
+	Name      string `json:"name"`
+}
+
+// CreateHolidayCalendarRequest is the request to create a holiday calendar.
+type CreateHolidayCalendarRequest struct {
+	ID        string `json:"id"` // e.g. "us", "uk", "fr-paris"
+	CompanyID string `json:"company_id"`
+	Name      string `json:"name"`
+}
+
+// EmployeeHolidayCalendarDTO is one entry of an employee's calendar history.
+type EmployeeHolidayCalendarDTO struct {
+	CalendarID    string `json:"calendar_id"`
+	EffectiveFrom string `json:"effective_from"`
+}
+
+// AssignHolidayCalendarRequest assigns a calendar to an employee.
+type AssignHolidayCalendarRequest struct {
+	CalendarID    string `json:"calendar_id"`
+	EffectiveFrom string `json:"effective_from"` // YYYY-MM-DD; defaults to today
+}
+
+// EmployeeHolidayCalendarsDTO is an employee's calendar history.
+type EmployeeHolidayCalendarsDTO struct {
+	EntityID string                       `json:"entity_id"`
+	Current  string                       `json:"current,omitempty"` // Calendar in effect today
+	History  []EmployeeHolidayCalendarDTO `json:"history"`
+}
+
+// EmployeeHolidayDTO is a holiday as it applies to one employee.
+type EmployeeHolidayDTO struct {
+	Date       string `json:"date"`
+	Name       string `json:"name"`
+	CalendarID string `json:"calendar_id,omitempty"`
+	Recurring  bool   `json:"recurring"`
+}
+
+// =============================================================================
+// TENANT TYPES
+// =============================================================================
+
+// TenantDTO represents a tenant (a company hosted on the server).
+type TenantDTO struct {
+	ID        string `json:"id"`
+	Name      string `json:"name"`
+	CreatedAt string `json:"created_at"`
+}
+
+// CreateTenantRequest is the request to create a tenant.
+type CreateTenantRequest struct {
+	ID   string `json:"id"` // e.g. "acme"; sent back as X-Tenant-ID
+	Name string `json:"name"`
+}
+
+// =============================================================================
+// WEBHOOK TYPES
+// =============================================================================
+
+// WebhookEndpointDTO represents a registered webhook endpoint.
+type WebhookEndpointDTO struct {
+	ID          string   `json:"id"`
+	URL         string   `json:"url"`
+	EventTypes  []string `json:"event_types"` // Empty = all
+	Description string   `json:"description,omitempty"`
+	Active      bool     `json:"active"`
+	Secret      string   `json:"secret,omitempty"` // Only returned on creation
+	CreatedAt   string   `json:"created_at"`
+}
+
+// CreateWebhookEndpointRequest registers a webhook endpoint.
+type CreateWebhookEndpointRequest struct {
+	URL         string   `json:"url"`
+	EventTypes  []string `json:"event_types,omitempty"` // e.g. ["request.approved"]; empty = all
+	Description string   `json:"description,omitempty"`
+}
+
+// WebhookDeliveryDTO represents one event sent to one endpoint.
+type WebhookDeliveryDTO struct {
+	ID             string  `json:"id"`
+	EndpointID     string  `json:"endpoint_id"`
+	EventID        string  `json:"event_id"`
+	EventType      string  `json:"event_type"`
+	Status         string  `json:"status"` // pending, delivered, dead
+	Attempts       int     `json:"attempts"`
+	NextAttemptAt  *string `json:"next_attempt_at,omitempty"`
+	LastStatusCode int     `json:"last_status_code,omitempty"`
+	LastError      string  `json:"last_error,omitempty"`
+	DeliveredAt    *string `json:"delivered_at,omitempty"`
+	CreatedAt      string  `json:"created_at"`
+}
+
+// OutboxEventDTO represents a recorded ledger event.
+type OutboxEventDTO struct {
+	ID           string          `json:"id"`
+	Type         string          `json:"type"`
+	CreatedAt    string          `json:"created_at"`
+	DispatchedAt *string         `json:"dispatched_at,omitempty"`
+	Payload      json.RawMessage `json:"payload"`
+}
+
+// ReplayWebhookRequest replays deliveries to an endpoint.
+type ReplayWebhookRequest struct {
+	Since string `json:"since,omitempty"` // RFC3339; empty = requeue dead deliveries
+}
+
+// =============================================================================
+// RECONCILIATION BATCH TYPES
+// =============================================================================
+
+// ReconciliationBatchDTO summarizes a batch reconciliation run.
+type ReconciliationBatchDTO struct {
+	ID          string  `json:"id"`
+	Trigger     string  `json:"trigger"` // scheduler or api
+	Status      string  `json:"status"`  // running, completed, cancelled, rolled_back
+	AsOf        string  `json:"as_of"`   // Periods ending before this day
+	Workers     int     `json:"workers"`
+	Total       int     `json:"total"`     // Entities with work
+	Processed   int     `json:"processed"` // Entities done
+	Reconciled  int     `json:"reconciled"`
+	Failed      int     `json:"failed"`
+	CarriedOver float64 `json:"carried_over"`
+	Expired     float64 `json:"expired"`
+	Checkpoint  string  `json:"checkpoint,omitempty"`
+	Error       string  `json:"error,omitempty"`
+	CreatedAt   string  `json:"created_at"`
+	UpdatedAt   string  `json:"updated_at"`
+	CompletedAt *string `json:"completed_at,omitempty"`
+}
+
+// StartReconciliationBatchRequest starts a batch reconciliation run.
+type StartReconciliationBatchRequest struct {
+	Workers int    `json:"workers,omitempty"` // Default 8, at most 64
+	AsOf    string `json:"as_of,omitempty"`   // YYYY-MM-DD; default today
+}
+
+// ReconciliationItemDTO is the reconciliation of one entity, policy and
+// period (an item of a batch).
+type ReconciliationItemDTO struct {
+	ID          string  `json:"id"`
+	BatchID     string  `json:"batch_id,omitempty"`
+	PolicyID    string  `json:"policy_id"`
+	EntityID    string  `json:"entity_id"`
+	PeriodStart string  `json:"period_start"`
+	PeriodEnd   string  `json:"period_end"`
+	Status      string  `json:"status"` // running, completed, failed, rolled_back
+	CarriedOver float64 `json:"carried_over"`
+	Expired     float64 `json:"expired"`
+	Error       string  `json:"error,omitempty"`
+	CompletedAt string  `json:"completed_at,omitempty"`
+
+	RolledBackAt string `json:"rolled_back_at,omitempty"`
+
+	// Corrections after backdated transactions
+	Corrections   int    `json:"corrections,omitempty"`
+	CorrectedAt   string `json:"corrected_at,omitempty"`
+	CorrectedBy   string `json:"corrected_by,omitempty"`   // Backdated transaction
+	CorrectedFrom string `json:"corrected_from,omitempty"` // Item of the earlier period that cascaded here
+}
+
+// RollbackResultDTO is the outcome of rolling back a batch.
+type RollbackResultDTO struct {
+	Batch      ReconciliationBatchDTO  `json:"batch"`
+	RolledBack []ReconciliationItemDTO `json:"rolled_back"`
+	Errors     []RollbackErrorDTO      `json:"errors"` // Items left as they were
+}
+
+// RollbackErrorDTO is an item a batch rollback could not roll back.
+type RollbackErrorDTO struct {
+	ItemID string `json:"item_id"`
+	Error  string `json:"error"`
+}
+
+// ReconciliationPreviewDTO is what reconciliation would post, computed
+// without writing anything (a dry run).
+type ReconciliationPreviewDTO struct {
+	AsOf      string                          `json:"as_of,omitempty"`      // Scheduler preview: periods ending before this day
+	PeriodEnd string                          `json:"period_end,omitempty"` // Rollover preview: the period end requested
+	Lines     []ReconciliationPreviewLineDTO  `json:"lines"`
+	Totals    []ReconciliationPreviewTotalDTO `json:"totals"` // One per unit
+}
+
+// ReconciliationPreviewLineDTO is the proposed reconciliation of one
+// entity, policy and period.
+type ReconciliationPreviewLineDTO struct {
+	EntityID     string           `json:"entity_id"`
+	PolicyID     string           `json:"policy_id"`
+	Unit         string           `json:"unit"`
+	PeriodStart  string           `json:"period_start"`
+	PeriodEnd    string           `json:"period_end"`
+	Balance      float64          `json:"balance"`                 // Accrued and unused at period end
+	CarryoverCap *float64         `json:"carryover_cap,omitempty"` // Policy's max carryover
+	MaxBalance   *float64         `json:"max_balance,omitempty"`   // Policy's balance cap
+	CarriedOver  float64          `json:"carried_over"`
+	Expired      float64          `json:"expired"` // Includes what a cap removes
+	Prorated     float64          `json:"prorated"`
+	Transactions []TransactionDTO `json:"transactions"`
+	Error        string           `json:"error,omitempty"`
+}
+
+// ReconciliationPreviewTotalDTO sums the preview lines of one unit.
+type ReconciliationPreviewTotalDTO struct {
+	Unit        string  `json:"unit"`
+	Entities    int     `json:"entities"`
+	Lines       int     `json:"lines"`
+	Failed      int     `json:"failed"`
+	CarriedOver float64 `json:"carried_over"`
+	Expired     float64 `json:"expired"`
+	Prorated    float64 `json:"prorated"`
+}
@@ -0,0 +1,295 @@
+/*
+events.go - Live event stream (Server-Sent Events)
+
+PURPOSE:
+  Pushes request and ledger changes to the web dashboard as they happen,
+  so balances and the approval queue update without polling or reloads.
+
+DESIGN:
+  - EventBus: in-process publish/subscribe, one per Handler (Handler.Events)
+  - Handlers publish after their writes commit; the
+    ReconciliationScheduler publishes through its Handler
+  - Subscribers only receive their own tenant's events
+  - Best effort: a subscriber that falls more than subscriberBuffer events
+    behind is disconnected, and clients reload state when they reconnect.
+    Reliable delivery to other systems is the outbox's job (webhooks.go).
+  - Per process: with several replicas, each streams the changes made
+    through it
+
+EVENTS:
+  request.submitted         Time off requested (data: request_id, status)
+  request.approved          Pending request approved
+  request.rejected          Pending request rejected
+  transaction.appended      Ledger rows appended, one event per entity
+                            (data: transaction_ids, resource_types)
+  reconciliation.completed  Period-end rollover for one entity and policy
+
+STREAM:
+  GET /api/events                     Everything the caller may see
+  GET /api/events?entity_id=alice     One employee
+  GET /api/events?manager_id=bob      Bob's direct reports
+
+  : connected
+
+  id: 42
+  event: request.approved
+  data: {"id":42,"type":"request.approved","tenant_id":"","entity_id":"alice",...}
+
+  An idle stream sends a ": ping" comment every 15s so proxies keep it open.
+
+ACCESS:
+  Employees only stream their own events (the default without filters),
+  managers their own or their reports' (default: manager_id=self), admins
+  anything. Browsers' EventSource cannot send an Authorization header, so
+  with authentication enabled clients need a fetch-based EventSource.
+
+SEE ALSO:
+  - webhook_dispatcher.go: durable delivery of ledger events
+  - web/src/api/client.ts: subscribeEvents
+*/
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/warp/resource-engine/generic"
+)
+
+// sseHeartbeat is how often an idle stream sends a keep-alive comment.
+var sseHeartbeat = 15 * time.Second
+
+// subscriberBuffer is how many events a subscriber may fall behind before
+// it is disconnected.
+const subscriberBuffer = 64
+
+// LiveEvent is one change pushed to stream subscribers.
+type LiveEvent struct {
+	ID         uint64            `json:"id"`
+	Type       generic.EventType `json:"type"`
+	TenantID   generic.TenantID  `json:"tenant_id"`
+	EntityID   string            `json:"entity_id,omitempty"`
+	OccurredAt time.Time         `json:"occurred_at"`
+	Data       map[string]any    `json:"data,omitempty"`
+}
+
+// EventBus fans live events out to in-process subscribers. Publishing to
+// a nil *EventBus does nothing.
+type EventBus struct {
+	mu     sync.Mutex
+	nextID uint64
+	subs   map[*Subscription]struct{}
+}
+
+// Subscription receives one tenant's events on Events until Close. If the
+// subscriber falls behind, Events is closed.
+type Subscription struct {
+	Events <-chan LiveEvent
+
+	bus    *EventBus
+	tenant generic.TenantID
+	events chan LiveEvent
+}
+
+// NewEventBus creates an event bus with no subscribers.
+func NewEventBus() *EventBus {
+	return &EventBus{subs: make(map[*Subscription]struct{})}
+}
+
+// Publish stamps the event with an ID, the context's tenant and the
+// current time, and hands it to that tenant's subscribers without
+// blocking.
+func (b *EventBus) Publish(ctx context.Context, e LiveEvent) {
+	if b == nil {
+		return
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.nextID++
+	e.ID = b.nextID
+	e.TenantID = generic.TenantFrom(ctx)
+	e.OccurredAt = time.Now().UTC()
+
+	for s := range b.subs {
+		if s.tenant != e.TenantID {
+			continue
+		}
+		select {
+		case s.events <- e:
+		default:
+			// Too slow: disconnect rather than block the writer
+			delete(b.subs, s)
+			close(s.events)
+		}
+	}
+}
+
+// Subscribe starts receiving the context's tenant's events.
+func (b *EventBus) Subscribe(ctx context.Context) *Subscription {
+	s := &Subscription{
+		bus:    b,
+		tenant: generic.TenantFrom(ctx),
+		events: make(chan LiveEvent, subscriberBuffer),
+	}
+	s.Events = s.events
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.subs[s] = struct{}{}
+	return s
+}
+
+// Close stops the subscription. Safe to call more than once.
+func (s *Subscription) Close() {
+	s.bus.mu.Lock()
+	defer s.bus.mu.Unlock()
+
+	if _, ok := s.bus.subs[s]; ok {
+		delete(s.bus.subs, s)
+		close(s.events)
+	}
+}
+
+// appendWithEvent appends a batch together with its outbox event, then
+// tells live subscribers whose ledgers changed.
+func (h *Handler) appendWithEvent(ctx context.Context, txs []generic.Transaction, eventType generic.EventType, data map[string]any) error {
+	if err := h.Store.AppendBatchWithEvent(ctx, txs, eventType, data); err != nil {
+		return err
+	}
+	h.publishAppended(ctx, txs)
+	return nil
+}
+
+// publishAppended publishes one transaction.appended event per entity.
+func (h *Handler) publishAppended(ctx context.Context, txs []generic.Transaction) {
+	var entities []generic.EntityID
+	ids := make(map[generic.EntityID][]string)
+	types := make(map[generic.EntityID][]string)
+	seenType := make(map[string]bool)
+	for _, tx := range txs {
+		if _, ok := ids[tx.EntityID]; !ok {
+			entities = append(entities, tx.EntityID)
+		}
+		ids[tx.EntityID] = append(ids[tx.EntityID], string(tx.ID))
+		rt := tx.ResourceType.ResourceID()
+		if key := string(tx.EntityID) + "/" + rt; !seenType[key] {
+			seenType[key] = true
+			types[tx.EntityID] = append(types[tx.EntityID], rt)
+		}
+	}
+
+	for _, entity := range entities {
+		h.Events.Publish(ctx, LiveEvent{
+			Type:     generic.EventTransactionAppended,
+			EntityID: string(entity),
+			Data:     map[string]any{"transaction_ids": ids[entity], "resource_types": types[entity]},
+		})
+	}
+}
+
+// StreamEvents streams live events as Server-Sent Events.
+// GET /api/events?entity_id=alice or ?manager_id=bob
+func (h *Handler) StreamEvents(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	if h.Events == nil {
+		writeError(w, http.StatusServiceUnavailable, "Live events are not enabled", nil)
+		return
+	}
+
+	entityID := r.URL.Query().Get("entity_id")
+	managerID := r.URL.Query().Get("manager_id")
+	if p := principalFrom(ctx); p != nil && !p.privileged() {
+		if entityID == "" && managerID == "" {
+			if p.Type == generic.ActorManager {
+				managerID = p.ID
+			} else {
+				entityID = p.ID
+			}
+		}
+		if managerID != "" && (p.Type != generic.ActorManager || managerID != p.ID) {
+			writeError(w, http.StatusForbidden, "Managers can only stream their own reports", nil)
+			return
+		}
+		if entityID != "" {
+			if ok, err := h.canActFor(ctx, entityID); err != nil {
+				writeError(w, http.StatusInternalServerError, "Failed to authorize request", err)
+				return
+			} else if !ok {
+				writeError(w, http.StatusForbidden, "Not allowed to stream this employee's events", nil)
+				return
+			}
+		}
+	}
+
+	// The server's write timeout would otherwise end the stream; not every
+	// ResponseWriter supports deadlines, which is fine
+	rc := http.NewResponseController(w)
+	rc.SetWriteDeadline(time.Time{})
+
+	sub := h.Events.Subscribe(ctx)
+	defer sub.Close()
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+	fmt.Fprint(w, ": connected\n\n")
+	if err := rc.Flush(); err != nil {
+		return
+	}
+
+	heartbeat := time.NewTicker(sseHeartbeat)
+	defer heartbeat.Stop()
+	reports := make(map[string]bool) // entity -> reports to managerID
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-heartbeat.C:
+			fmt.Fprint(w, ": ping\n\n")
+		case e, ok := <-sub.Events:
+			if !ok {
+				return // Fell behind; the client reconnects and reloads
+			}
+			if !h.streamWants(ctx, e, entityID, managerID, reports) {
+				continue
+			}
+			body, err := json.Marshal(e)
+			if err != nil {
+				continue
+			}
+			fmt.Fprintf(w, "id: %d\nevent: %s\ndata: %s\n\n", e.ID, e.Type, body)
+		}
+		if err := rc.Flush(); err != nil {
+			return
+		}
+	}
+}
+
+// streamWants applies a stream's filters to an event. Manager lookups are
+// cached per stream in reports.
+func (h *Handler) streamWants(ctx context.Context, e LiveEvent, entityID, managerID string, reports map[string]bool) bool {
+	if entityID != "" && e.EntityID != entityID {
+		return false
+	}
+	if managerID == "" {
+		return true
+	}
+
+	isReport, seen := reports[e.EntityID]
+	if !seen {
+		emp, err := h.Store.GetEmployee(ctx, e.EntityID)
+		if err != nil {
+			return false
+		}
+		isReport = emp != nil && emp.ManagerID == managerID
+		reports[e.EntityID] = isReport
+	}
+	return isReport
+}
@@ -0,0 +1,219 @@
+/*
+events_test.go - Unit tests for live events
+
+Tests for:
+- Live events (EventBus, StreamEvents)
+*/
+package api
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/warp/resource-engine/generic"
+	"github.com/warp/resource-engine/store/sqlite"
+	"github.com/warp/resource-engine/timeoff"
+)
+
+// streamEvents opens an SSE stream on a test server and returns its events.
+func streamEvents(t *testing.T, url, token string) <-chan LiveEvent {
+	t.Helper()
+	req, _ := http.NewRequest(http.MethodGet, url, nil)
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("Failed to open stream: %v", err)
+	}
+	t.Cleanup(func() { resp.Body.Close() })
+	if resp.StatusCode != http.StatusOK || resp.Header.Get("Content-Type") != "text/event-stream" {
+		t.Fatalf("Expected an event stream, got %d %s", resp.StatusCode, resp.Header.Get("Content-Type"))
+	}
+
+	reader := bufio.NewReader(resp.Body)
+	if line, _ := reader.ReadString('\n'); line != ": connected\n" {
+		t.Fatalf("Expected connected comment, got %q", line)
+	}
+
+	events := make(chan LiveEvent, 16)
+	go func() {
+		defer close(events)
+		for {
+			line, err := reader.ReadString('\n')
+			if err != nil {
+				return
+			}
+			if data, ok := strings.CutPrefix(line, "data: "); ok {
+				var e LiveEvent
+				if json.Unmarshal([]byte(data), &e) == nil {
+					events <- e
+				}
+			}
+		}
+	}()
+	return events
+}
+
+func TestEvents_StreamFiltersByEntityAndManager(t *testing.T) {
+	// GIVEN: alice reports to bob, carol to dave; streams for bob's reports
+	//        and for carol
+	// WHEN: Both submit time off and alice's period is rolled over
+	// THEN: Each stream gets only its employees' submitted, appended and
+	//       reconciliation events, in order
+
+	handler := setupTestHandler(t)
+	handler.Events = NewEventBus()
+	ctx := context.Background()
+
+	if err := handler.createPolicyFromJSON(ctx, timeoff.StandardPTOJSON("pto-test", "Test PTO", 24, 5)); err != nil {
+		t.Fatalf("Failed to create policy: %v", err)
+	}
+	hireDate := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	for id, manager := range map[string]string{"alice": "bob", "carol": "dave"} {
+		if err := handler.Store.SaveEmployee(ctx, sqlite.Employee{ID: id, Name: id, ManagerID: manager, HireDate: hireDate}); err != nil {
+			t.Fatalf("Failed to create employee: %v", err)
+		}
+		if err := handler.Store.SaveAssignment(ctx, sqlite.AssignmentRecord{
+			ID: "assign-" + id, EntityID: id, PolicyID: "pto-test",
+			EffectiveFrom: hireDate, ConsumptionPriority: 1,
+		}); err != nil {
+			t.Fatalf("Failed to save assignment: %v", err)
+		}
+	}
+
+	server := httptest.NewServer(NewRouter(handler))
+	t.Cleanup(server.Close) // Registered first, so streams close before it
+	bobStream := streamEvents(t, server.URL+"/api/events?manager_id=bob", "")
+	carolStream := streamEvents(t, server.URL+"/api/events?entity_id=carol", "")
+
+	post := func(path, body string) {
+		t.Helper()
+		resp, err := http.Post(server.URL+path, "application/json", strings.NewReader(body))
+		if err != nil {
+			t.Fatalf("POST %s: %v", path, err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode >= 300 {
+			t.Fatalf("POST %s: got %d", path, resp.StatusCode)
+		}
+	}
+	next := func(stream <-chan LiveEvent, who string) LiveEvent {
+		t.Helper()
+		select {
+		case e, ok := <-stream:
+			if !ok {
+				t.Fatalf("%s: stream closed", who)
+			}
+			return e
+		case <-time.After(5 * time.Second):
+			t.Fatalf("%s: no event within 5s", who)
+		}
+		return LiveEvent{}
+	}
+	expectEvent := func(stream <-chan LiveEvent, who string, eventType generic.EventType, entity string) LiveEvent {
+		t.Helper()
+		e := next(stream, who)
+		if e.Type != eventType || e.EntityID != entity {
+			t.Errorf("%s: expected %s for %s, got %s for %s", who, eventType, entity, e.Type, e.EntityID)
+		}
+		return e
+	}
+
+	post("/api/employees/alice/requests", `{"resource_type":"pto","days":["2025-06-02"]}`)
+	post("/api/employees/carol/requests", `{"resource_type":"pto","days":["2025-06-03"]}`)
+	post("/api/employees/alice/requests", `{"resource_type":"pto","days":["2025-06-04"]}`)
+
+	appended := expectEvent(bobStream, "bob", generic.EventTransactionAppended, "alice")
+	if ids, _ := appended.Data["transaction_ids"].([]any); len(ids) != 1 {
+		t.Errorf("Expected one transaction ID, got %v", appended.Data)
+	}
+	submitted := expectEvent(bobStream, "bob", generic.EventRequestSubmitted, "alice")
+	if submitted.Data["status"] != "approved" || submitted.Data["request_id"] == "" {
+		t.Errorf("Expected request details, got %v", submitted.Data)
+	}
+	// carol's submission is filtered out of bob's stream
+	expectEvent(bobStream, "bob", generic.EventTransactionAppended, "alice")
+	expectEvent(bobStream, "bob", generic.EventRequestSubmitted, "alice")
+
+	expectEvent(carolStream, "carol", generic.EventTransactionAppended, "carol")
+	expectEvent(carolStream, "carol", generic.EventRequestSubmitted, "carol")
+
+	post("/api/admin/rollover", `{"entity_id":"alice","period_end":"2025-12-31"}`)
+	for {
+		e := next(bobStream, "bob")
+		if e.Type == generic.EventTransactionAppended {
+			continue
+		}
+		if e.Type != generic.EventReconciliationCompleted || e.EntityID != "alice" || e.Data["policy_id"] != "pto-test" {
+			t.Errorf("Expected reconciliation.completed for alice, got %+v", e)
+		}
+		break
+	}
+}
+
+func TestEvents_BusIsolatesTenantsAndDropsSlowSubscribers(t *testing.T) {
+	// GIVEN: Subscribers in two tenants, and employee tokens
+	// WHEN: Events are published faster than a subscriber reads
+	// THEN: Tenants never see each other's events, a lagging subscriber
+	//       is disconnected instead of blocking writers, and employees
+	//       cannot stream other people's events
+
+	bus := NewEventBus()
+	acme := generic.WithTenant(context.Background(), "acme")
+	defaultSub := bus.Subscribe(context.Background())
+	acmeSub := bus.Subscribe(acme)
+	defer acmeSub.Close()
+
+	bus.Publish(acme, LiveEvent{Type: generic.EventRequestSubmitted, EntityID: "alice"})
+	if e := <-acmeSub.Events; e.TenantID != "acme" || e.ID != 1 {
+		t.Errorf("Expected acme event 1, got %+v", e)
+	}
+	select {
+	case e := <-defaultSub.Events:
+		t.Errorf("Default tenant received acme event %+v", e)
+	default:
+	}
+
+	for i := 0; i <= subscriberBuffer; i++ {
+		bus.Publish(context.Background(), LiveEvent{Type: generic.EventTransactionAppended})
+	}
+	received := 0
+	for range defaultSub.Events {
+		received++
+	}
+	if received != subscriberBuffer {
+		t.Errorf("Expected %d buffered events before disconnect, got %d", subscriberBuffer, received)
+	}
+	defaultSub.Close() // Already dropped; must not panic
+
+	var nilBus *EventBus
+	nilBus.Publish(context.Background(), LiveEvent{}) // No-op
+
+	handler := setupTestHandler(t)
+	handler.Events = bus
+	tokens := NewStaticTokens()
+	tokens.Add("alice-token", Principal{Actor: generic.Actor{ID: "alice", Type: generic.ActorEmployee}})
+	tokens.Add("bob-token", Principal{Actor: generic.Actor{ID: "bob", Type: generic.ActorManager}})
+	handler.Auth = tokens
+	router := NewRouter(handler)
+	for _, tc := range []struct{ path, token string }{
+		{"/api/events?entity_id=carol", "alice-token"},
+		{"/api/events?manager_id=bob", "alice-token"},
+		{"/api/events?manager_id=dave", "bob-token"},
+	} {
+		req := httptest.NewRequest(http.MethodGet, tc.path, nil)
+		req.Header.Set("Authorization", "Bearer "+tc.token)
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, req)
+		if rec.Code != http.StatusForbidden {
+			t.Errorf("%s as %s: expected 403, got %d", tc.path, tc.token, rec.Code)
+		}
+	}
+}
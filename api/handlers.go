@@ -10,29 +10,47 @@ ENDPOINTS:
     GET    /api/employees              List all employees
     POST   /api/employees              Create employee
     GET    /api/employees/{id}         Get employee details
-    GET    /api/employees/{id}/balance Get balance summary
+    GET    /api/employees/{id}/balance Get balance summary (?as_of=, ?as_known_at=)
+    GET    /api/employees/{id}/balance/history Balance series (balance_history.go)
+    GET    /api/employees/{id}/balance/explain Itemized derivation (balance_explain.go)
+    GET    /api/employees/{id}/balance/projection First day N is available (balance_projection.go)
+    POST   /api/employees/{id}/terminate End assignments, settle balances
 
   Requests:
     POST   /api/employees/{id}/requests Submit time-off/resource request
-    GET    /api/employees/{id}/transactions Transaction history
+    GET    /api/employees/{id}/transactions Transaction history (?as_known_at=)
 
   Policies:
     GET    /api/policies               List all policies
     POST   /api/policies               Create policy from JSON
 
+  Holidays:
+    GET    /api/holidays               List holidays (?calendar_id=)
+    POST   /api/holidays/import        Bulk import .ics or CSV (dry_run, replace_year)
+    GET    /api/holiday-calendars      Named calendars (see holiday_calendars.go)
+
   Admin:
-    POST   /api/admin/rollover         Trigger year-end rollover
-    POST   /api/admin/adjustment       Manual balance adjustment
+    POST   /api/admin/rollover         Trigger year-end rollover (dry_run previews)
+    POST   /api/admin/adjustment       Manual balance adjustment (backdated: rereconcile.go)
 
   Scenarios:
     GET    /api/scenarios              List demo scenarios
     POST   /api/scenarios/load         Load a demo scenario
 
+  Tenants:
+    GET    /api/tenants                List tenants (see tenants.go)
+    POST   /api/tenants                Create tenant
+
 ARCHITECTURE:
   Handler struct holds all dependencies:
   - Store: Database access
   - PolicyFactory: JSON to Policy conversion
-  - Cached policies/accruals for performance
+  - Cached policies/accruals for performance, one cache per tenant
+
+TENANCY:
+  Every request context carries a tenant (ResolveTenant middleware) and
+  the store scopes all queries by it. Handlers pass r.Context() through
+  and use h.cache(ctx), never a shared map.
 
 REQUEST FLOW:
   1. Parse HTTP request
@@ -41,6 +59,14 @@ REQUEST FLOW:
   4. Serialize response
   5. Handle errors
 
+KNOWLEDGE TIME:
+  Balance, history, transaction and liability reads take ?as_known_at=
+  (a day, meaning its end in UTC, or an RFC3339 time) and then ignore
+  transactions recorded after it, even backdated ones effective earlier:
+  the ledger as the system knew it then. as_of picks the day a balance is
+  computed for; as_known_at picks which bookings were known. Accrual
+  rules and assignments are read as they are now.
+
 ERROR HANDLING:
   Errors are returned as JSON with appropriate HTTP status:
   - 400: Validation errors, invalid input
@@ -49,8 +75,10 @@ ERROR HANDLING:
   - 500: Internal errors
 
 SECURITY NOTE:
-  Currently NO authentication or authorization. All endpoints are public.
-  See DEVOPS_SECURITY.md for production requirements.
+  Authentication and role checks run before handlers (auth.go, server.go).
+  Handlers add the checks that need data: cancelling checks the
+  transaction's employee, approvals check the manager, and employee and
+  pending-request lists are filtered to what the caller may see.
 
 SEE ALSO:
   - dto.go: Request/response data structures
@@ -60,13 +88,18 @@ SEE ALSO:
 package api
 
 import (
+	"bytes"
 	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
+	"io"
+	"log"
 	"net/http"
 	"sort"
+	"strconv"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/go-chi/chi/v5"
@@ -82,50 +115,141 @@ import (
 
 // Handler holds all dependencies for HTTP handlers.
 type Handler struct {
-	Store         *sqlite.Store
+	Store         Store
 	PolicyFactory *factory.PolicyFactory
+
+	// Auth authenticates API requests; nil disables authentication
+	Auth Authenticator
+
+	// Events publishes live updates to /api/events; nil disables them
+	Events *EventBus
+
+	// Reconciliation batches running in this process, by ID
+	batches   map[string]context.CancelFunc
+	batchesMu sync.Mutex
+
+	// Serialize corrections of reconciled periods per tenant, entity and
+	// policy (rereconcile.go); rereconcileMu guards the map
+	rereconcileLocks map[[3]string]*sync.Mutex
+	rereconcileMu    sync.Mutex
 	
-	// Cached policies and accruals for quick lookups
+	// Cached policies and accruals for quick lookups, per tenant
+	tenants   map[generic.TenantID]*tenantCache
+	tenantsMu sync.Mutex
+}
+
+// tenantCache holds one tenant's parsed policies and demo scenario.
+type tenantCache struct {
 	policies map[generic.PolicyID]*generic.Policy
 	accruals map[generic.PolicyID]generic.AccrualSchedule
-	
+
 	// Track currently loaded scenario
-	currentScenario string
+	scenario string
+}
+
+func newTenantCache() *tenantCache {
+	return &tenantCache{
+		policies: make(map[generic.PolicyID]*generic.Policy),
+		accruals: make(map[generic.PolicyID]generic.AccrualSchedule),
+	}
 }
 
 // NewHandler creates a new handler with the given store.
-func NewHandler(store *sqlite.Store) *Handler {
+func NewHandler(store Store) *Handler {
 	return &Handler{
 		Store:         store,
 		PolicyFactory: factory.NewPolicyFactory(),
-		policies:      make(map[generic.PolicyID]*generic.Policy),
-		accruals:      make(map[generic.PolicyID]generic.AccrualSchedule),
+		Events:        NewEventBus(),
+		tenants:       make(map[generic.TenantID]*tenantCache),
 	}
 }
 
-// LoadPolicies loads all policies from the database into cache.
+// LoadPolicies loads all policies of the context's tenant from the
+// database into cache. Other tenants are loaded on first use.
 func (h *Handler) LoadPolicies(ctx context.Context) error {
-	records, err := h.Store.ListPolicies(ctx)
+	cache, err := h.loadTenantCache(ctx)
 	if err != nil {
 		return err
 	}
 
+	h.tenantsMu.Lock()
+	defer h.tenantsMu.Unlock()
+	if h.tenants == nil {
+		h.tenants = make(map[generic.TenantID]*tenantCache)
+	}
+	h.tenants[generic.TenantFrom(ctx)] = cache
+	return nil
+}
+
+// cache returns the cache of the context's tenant, loading its policies
+// the first time the tenant is seen. A failed load is not cached, so the
+// next request retries it.
+func (h *Handler) cache(ctx context.Context) (*tenantCache, error) {
+	h.tenantsMu.Lock()
+	defer h.tenantsMu.Unlock()
+
+	tenant := generic.TenantFrom(ctx)
+	if cache, ok := h.tenants[tenant]; ok {
+		return cache, nil
+	}
+	cache, err := h.loadTenantCache(ctx)
+	if err != nil {
+		log.Printf("[Cache] Failed to load policies of tenant %q: %v", tenant, err)
+		return nil, fmt.Errorf("failed to load policies: %w", err)
+	}
+	if h.tenants == nil {
+		h.tenants = make(map[generic.TenantID]*tenantCache)
+	}
+	h.tenants[tenant] = cache
+	return cache, nil
+}
+
+// assignments returns the store's assignments as a generic.AssignmentStore,
+// resolving policies from the given tenant cache. Assignments whose policy
+// is not loaded are skipped.
+func (h *Handler) assignments(cache *tenantCache) generic.AssignmentStore {
+	return h.Store.Assignments(func(ctx context.Context, id generic.PolicyID) (*generic.Policy, bool) {
+		policy, ok := cache.policies[id]
+		return policy, ok
+	})
+}
+
+// resetCache clears the context's tenant cache (after Store.Reset).
+func (h *Handler) resetCache(ctx context.Context) {
+	h.tenantsMu.Lock()
+	defer h.tenantsMu.Unlock()
+
+	if h.tenants == nil {
+		h.tenants = make(map[generic.TenantID]*tenantCache)
+	}
+	h.tenants[generic.TenantFrom(ctx)] = newTenantCache()
+}
+
+func (h *Handler) loadTenantCache(ctx context.Context) (*tenantCache, error) {
+	records, err := h.Store.ListPolicies(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	cache := newTenantCache()
 	for _, r := range records {
 		policy, accrual, err := h.PolicyFactory.ParsePolicy(r.ConfigJSON)
 		if err != nil {
 			continue // Skip invalid policies
 		}
-		h.policies[policy.ID] = policy
-		h.accruals[policy.ID] = accrual
+		cache.policies[policy.ID] = policy
+		cache.accruals[policy.ID] = accrual
 	}
-	return nil
+	return cache, nil
 }
 
 // =============================================================================
 // EMPLOYEE HANDLERS
 // =============================================================================
 
-// ListEmployees returns all employees.
+// ListEmployees returns all employees the caller may see: everyone for
+// admins, themselves and their reports for managers, themselves for
+// employees.
 func (h *Handler) ListEmployees(w http.ResponseWriter, r *http.Request) {
 	employees, err := h.Store.ListEmployees(r.Context())
 	if err != nil {
@@ -133,15 +257,22 @@ func (h *Handler) ListEmployees(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	dtos := make([]EmployeeDTO, len(employees))
-	for i, e := range employees {
-		dtos[i] = EmployeeDTO{
+	p := principalFrom(r.Context())
+	dtos := make([]EmployeeDTO, 0, len(employees))
+	for _, e := range employees {
+		if p != nil && !p.privileged() && e.ID != p.ID &&
+			!(p.Type == generic.ActorManager && e.ManagerID == p.ID) {
+			continue
+		}
+		dtos = append(dtos, EmployeeDTO{
 			ID:        e.ID,
 			Name:      e.Name,
 			Email:     e.Email,
+			Team:      e.Team,
+			ManagerID: e.ManagerID,
 			HireDate:  e.HireDate.Format("2006-01-02"),
 			CreatedAt: e.CreatedAt.Format(time.RFC3339),
-		}
+		})
 	}
 
 	writeJSON(w, http.StatusOK, dtos)
@@ -165,6 +296,8 @@ func (h *Handler) GetEmployee(w http.ResponseWriter, r *http.Request) {
 		ID:        emp.ID,
 		Name:      emp.Name,
 		Email:     emp.Email,
+		Team:      emp.Team,
+		ManagerID: emp.ManagerID,
 		HireDate:  emp.HireDate.Format("2006-01-02"),
 		CreatedAt: emp.CreatedAt.Format(time.RFC3339),
 	})
@@ -185,10 +318,12 @@ func (h *Handler) CreateEmployee(w http.ResponseWriter, r *http.Request) {
 	}
 
 	emp := sqlite.Employee{
-		ID:       req.ID,
-		Name:     req.Name,
-		Email:    req.Email,
-		HireDate: hireDate,
+		ID:        req.ID,
+		Name:      req.Name,
+		Email:     req.Email,
+		Team:      req.Team,
+		ManagerID: req.ManagerID,
+		HireDate:  hireDate,
 	}
 
 	if err := h.Store.SaveEmployee(r.Context(), emp); err != nil {
@@ -197,10 +332,12 @@ func (h *Handler) CreateEmployee(w http.ResponseWriter, r *http.Request) {
 	}
 
 	writeJSON(w, http.StatusCreated, EmployeeDTO{
-		ID:       emp.ID,
-		Name:     emp.Name,
-		Email:    emp.Email,
-		HireDate: emp.HireDate.Format("2006-01-02"),
+		ID:        emp.ID,
+		Name:      emp.Name,
+		Email:     emp.Email,
+		Team:      emp.Team,
+		ManagerID: emp.ManagerID,
+		HireDate:  emp.HireDate.Format("2006-01-02"),
 	})
 }
 
@@ -208,7 +345,13 @@ func (h *Handler) CreateEmployee(w http.ResponseWriter, r *http.Request) {
 // BALANCE HANDLERS
 // =============================================================================
 
-// GetBalance returns aggregate balance for an employee.
+// GetBalance returns aggregate balance for an employee, as of today or
+// the as_of day (past or future), counting transactions known at
+// as_known_at. Without as_of it is the balance to book against: every
+// transaction in the current period counts, so days booked later in it
+// reduce what is available. With as_of it is the balance on that day, by
+// the rule of the history endpoint (asOfBalanceRange).
+// GET /api/employees/{id}/balance?resource_type=pto&as_of=YYYY-MM-DD&as_known_at=
 func (h *Handler) GetBalance(w http.ResponseWriter, r *http.Request) {
 	entityID := generic.EntityID(chi.URLParam(r, "id"))
 	resourceType := r.URL.Query().Get("resource_type")
@@ -218,6 +361,20 @@ func (h *Handler) GetBalance(w http.ResponseWriter, r *http.Request) {
 
 	ctx := r.Context()
 	asOf := generic.Today()
+	asOfOnly := false
+	if s := r.URL.Query().Get("as_of"); s != "" {
+		t, err := time.Parse("2006-01-02", s)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, "Invalid as_of format (use YYYY-MM-DD)", err)
+			return
+		}
+		asOf = generic.TimePoint{Time: t}
+		asOfOnly = true
+	}
+	knownAt, ok := asKnownAt(w, r)
+	if !ok {
+		return
+	}
 
 	// Get assignments for this employee
 	assignments, err := h.Store.GetAssignmentsByEntity(ctx, string(entityID))
@@ -231,24 +388,34 @@ func (h *Handler) GetBalance(w http.ResponseWriter, r *http.Request) {
 	totalAvailable := 0.0
 	totalPending := 0.0
 
-	ledger := generic.NewLedger(h.Store)
+	ledger := generic.NewLedger(h.Store).AsKnownAt(knownAt)
+	cache, err := h.cache(ctx)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "Failed to load policies", err)
+		return
+	}
 
 	for _, a := range assignments {
-		policy, ok := h.policies[generic.PolicyID(a.PolicyID)]
+		policy, ok := cache.policies[generic.PolicyID(a.PolicyID)]
 		if !ok || policy.ResourceType.ResourceID() != resourceType {
 			continue
 		}
 
-		accrual := h.accruals[policy.ID]
+		if asOfOnly && !assignmentActive(a, asOf) {
+			continue
+		}
+
+		accrual := cache.accruals[policy.ID]
 		period := policy.PeriodConfig.PeriodFor(asOf)
+		to, accrualStart := asOfBalanceRange(period, a, asOf, asOfOnly)
 
 		// Get balance for this policy
-		txs, err := ledger.TransactionsInRange(ctx, entityID, policy.ID, period.Start, period.End)
+		txs, err := ledger.TransactionsInRange(ctx, entityID, policy.ID, period.Start, to)
 		if err != nil {
 			continue
 		}
 
-		balance := calculateBalance(txs, period, policy.Unit, accrual, asOf)
+		balance := generic.ComputeBalance(txs, period, policy.Unit, accrual, accrualStart, asOf)
 		available, _ := balance.AvailableWithMode(policy.ConsumptionMode).Value.Float64()
 		accrued, _ := balance.AccruedToDate.Value.Float64()
 		entitlement, _ := balance.TotalEntitlement.Value.Float64()
@@ -283,94 +450,55 @@ func (h *Handler) GetBalance(w http.ResponseWriter, r *http.Request) {
 		TotalPending:   totalPending,
 		Policies:       policyBalances,
 		AsOf:           asOf.Time.Format("2006-01-02"),
+		AsKnownAt:      formatKnownAt(knownAt),
 	})
 }
 
-func calculateBalance(txs []generic.Transaction, period generic.Period, unit generic.Unit, accrual generic.AccrualSchedule, asOf generic.TimePoint) generic.Balance {
-	return calculateBalanceWithHireDate(txs, period, unit, accrual, asOf, period.Start)
+// asOfBalanceRange returns the last day of an assignment's period whose
+// transactions count toward its balance on asOf, and the accrual start.
+// With asOfOnly, transactions after asOf are left out, as in the history
+// endpoint and the liability report, so a past day never changes when
+// later days are booked. Accruals start at the assignment start, so
+// mid-period hires are prorated.
+func asOfBalanceRange(period generic.Period, a sqlite.AssignmentRecord, asOf generic.TimePoint, asOfOnly bool) (to, accrualStart generic.TimePoint) {
+	to = period.End
+	if asOfOnly && asOf.Before(to) {
+		to = asOf
+	}
+	return to, generic.TimePoint{Time: a.EffectiveFrom}
 }
 
-// calculateBalanceWithHireDate calculates balance with prorating from hire date.
-// For mid-period hires, accruals should start from hireDate, not period.Start.
-func calculateBalanceWithHireDate(txs []generic.Transaction, period generic.Period, unit generic.Unit, accrual generic.AccrualSchedule, asOf generic.TimePoint, hireDate generic.TimePoint) generic.Balance {
-	var (
-		actualAccruals = generic.NewAmount(0, unit)
-		consumed       = generic.NewAmount(0, unit)
-		pending        = generic.NewAmount(0, unit)
-		adjustments    = generic.NewAmount(0, unit)
-	)
-
-	for _, tx := range txs {
-		switch tx.Type {
-		case generic.TxGrant:
-			// Grants add to balance (bonus days, carryover balance, hours-worked accruals)
-			actualAccruals = actualAccruals.Add(tx.Delta)
-		case generic.TxConsumption:
-			consumed = consumed.Add(tx.Delta.Neg())
-		case generic.TxPending:
-			pending = pending.Add(tx.Delta.Neg())
-		case generic.TxReconciliation, generic.TxAdjustment:
-			adjustments = adjustments.Add(tx.Delta)
-		case generic.TxReversal:
-			consumed = consumed.Sub(tx.Delta)
-		}
-	}
-
-	accruedToDate := actualAccruals
-	totalEntitlement := actualAccruals
-
-	// Determine accrual start: later of period start or hire date
-	accrualStart := period.Start
-	if hireDate.After(period.Start) {
-		accrualStart = hireDate
-	}
-
-	if accrual != nil {
-		// Accruals from hire date (or period start) to asOf
-		accruedEvents := accrual.GenerateAccruals(accrualStart, asOf)
-		accruedTotal := generic.NewAmount(0, unit)
-		for _, e := range accruedEvents {
-			accruedTotal = accruedTotal.Add(e.Amount)
-		}
-		if accruedTotal.GreaterThan(actualAccruals) {
-			accruedToDate = accruedTotal
-		}
-
-		// Total entitlement from hire date to period end (prorated)
-		allEvents := accrual.GenerateAccruals(accrualStart, period.End)
-		entitlementTotal := generic.NewAmount(0, unit)
-		for _, e := range allEvents {
-			entitlementTotal = entitlementTotal.Add(e.Amount)
-		}
-		totalEntitlement = entitlementTotal
-	}
-
-	return generic.Balance{
-		Period:           period,
-		AccruedToDate:    accruedToDate,
-		TotalEntitlement: totalEntitlement,
-		TotalConsumed:    consumed,
-		Pending:          pending,
-		Adjustments:      adjustments,
+// assignmentActive reports whether an assignment is in effect on day, as
+// generic.PolicyAssignment.IsActive.
+func assignmentActive(a sqlite.AssignmentRecord, day generic.TimePoint) bool {
+	if day.Time.Before(a.EffectiveFrom) {
+		return false
 	}
+	return a.EffectiveTo == nil || !day.Time.After(*a.EffectiveTo)
 }
 
-// GetTransactions returns transaction history for an employee.
+// GetTransactions returns transaction history for an employee, as known
+// at as_known_at if given.
 func (h *Handler) GetTransactions(w http.ResponseWriter, r *http.Request) {
 	entityID := chi.URLParam(r, "id")
 	policyID := r.URL.Query().Get("policy_id")
+	knownAt, ok := asKnownAt(w, r)
+	if !ok {
+		return
+	}
 
 	ctx := r.Context()
+	ledger := generic.NewLedger(h.Store).AsKnownAt(knownAt)
 	var txs []generic.Transaction
 	var err error
 
 	if policyID != "" {
-		txs, err = h.Store.Load(ctx, generic.EntityID(entityID), generic.PolicyID(policyID))
+		txs, err = ledger.Transactions(ctx, generic.EntityID(entityID), generic.PolicyID(policyID))
 	} else {
 		// Get all transactions for employee (across all policies)
 		assignments, _ := h.Store.GetAssignmentsByEntity(ctx, entityID)
 		for _, a := range assignments {
-			policyTxs, _ := h.Store.Load(ctx, generic.EntityID(entityID), generic.PolicyID(a.PolicyID))
+			policyTxs, _ := ledger.Transactions(ctx, generic.EntityID(entityID), generic.PolicyID(a.PolicyID))
 			txs = append(txs, policyTxs...)
 		}
 	}
@@ -380,8 +508,14 @@ func (h *Handler) GetTransactions(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	cache, err := h.cache(ctx)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "Failed to load policies", err)
+		return
+	}
+
 	// Calculate balance at each transaction date
-	dtos := h.toTransactionDTOsWithBalance(ctx, txs)
+	dtos := toTransactionDTOsWithBalance(cache, txs)
 	writeJSON(w, http.StatusOK, dtos)
 }
 
@@ -412,8 +546,10 @@ func (h *Handler) SubmitRequest(w http.ResponseWriter, r *http.Request) {
 			writeError(w, http.StatusBadRequest, fmt.Sprintf("Invalid date: %s", d), err)
 			return
 		}
+		// Weekends and the employee's holidays (from the calendar in
+		// effect on that day) are not deducted
 		tp := generic.TimePoint{Time: t}
-		if tp.IsWorkday() {
+		if tp.IsWorkdayFor(r.Context(), h.Store, entityID) {
 			days = append(days, tp)
 		}
 	}
@@ -445,22 +581,27 @@ func (h *Handler) SubmitRequest(w http.ResponseWriter, r *http.Request) {
 	var allocations []AllocationDTO
 	remaining := requestAmount
 	requiresApproval := false
+	cache, err := h.cache(ctx)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "Failed to load policies", err)
+		return
+	}
 
 	for _, a := range assignments {
 		if remaining.IsZero() {
 			break
 		}
 
-		policy, ok := h.policies[generic.PolicyID(a.PolicyID)]
+		policy, ok := cache.policies[generic.PolicyID(a.PolicyID)]
 		if !ok || policy.ResourceType.ResourceID() != resourceType {
 			continue
 		}
 
-		accrual := h.accruals[policy.ID]
+		accrual := cache.accruals[policy.ID]
 		period := policy.PeriodConfig.PeriodFor(asOf)
 
 		txs, _ := ledger.TransactionsInRange(ctx, entityID, policy.ID, period.Start, period.End)
-		balance := calculateBalance(txs, period, policy.Unit, accrual, asOf)
+		balance := generic.ComputeBalance(txs, period, policy.Unit, accrual, period.Start, asOf)
 		available := balance.AvailableWithMode(policy.ConsumptionMode)
 
 		if available.IsZero() || available.IsNegative() {
@@ -493,7 +634,9 @@ func (h *Handler) SubmitRequest(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Create transactions
-	requestID := fmt.Sprintf("req-%d", time.Now().UnixNano())
+	// Retries with the same Idempotency-Key reuse the ID, so their
+	// transactions' idempotency keys collide instead of double-booking
+	requestID := idempotentID(ctx, "req-", fmt.Sprintf("req-%d", time.Now().UnixNano()))
 	var txs []generic.Transaction
 
 	dayIndex := 0 // Track day index across all policies
@@ -523,7 +666,13 @@ func (h *Handler) SubmitRequest(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 
-	if err := h.Store.AppendBatch(ctx, txs); err != nil {
+	status := "approved"
+	if requiresApproval {
+		status = "pending"
+	}
+
+	event := map[string]any{"request_id": requestID, "status": status}
+	if err := h.appendWithEvent(ctx, txs, generic.EventRequestSubmitted, event); err != nil {
 		if errors.Is(err, generic.ErrDuplicateDayConsumption) {
 			writeError(w, http.StatusConflict, "One or more selected dates already have time off scheduled", err)
 			return
@@ -531,11 +680,7 @@ func (h *Handler) SubmitRequest(w http.ResponseWriter, r *http.Request) {
 		writeError(w, http.StatusInternalServerError, "Failed to create request", err)
 		return
 	}
-
-	status := "approved"
-	if requiresApproval {
-		status = "pending"
-	}
+	h.Events.Publish(ctx, LiveEvent{Type: generic.EventRequestSubmitted, EntityID: string(entityID), Data: event})
 
 	writeJSON(w, http.StatusCreated, TimeOffResponseDTO{
 		RequestID:        requestID,
@@ -566,6 +711,13 @@ func (h *Handler) CancelTransaction(w http.ResponseWriter, r *http.Request) {
 		writeError(w, http.StatusNotFound, "Transaction not found", nil)
 		return
 	}
+	if ok, err := h.canActFor(ctx, string(tx.EntityID)); err != nil {
+		writeError(w, http.StatusInternalServerError, "Failed to authorize request", err)
+		return
+	} else if !ok {
+		writeError(w, http.StatusForbidden, "Not allowed to act for this employee", nil)
+		return
+	}
 
 	// Only allow cancelling consumption or pending transactions
 	if tx.Type != generic.TxConsumption && tx.Type != generic.TxPending {
@@ -598,10 +750,12 @@ func (h *Handler) CancelTransaction(w http.ResponseWriter, r *http.Request) {
 		IdempotencyKey: fmt.Sprintf("reversal-%s", txID),
 	}
 
-	if err := h.Store.AppendBatch(ctx, []generic.Transaction{reversalTx}); err != nil {
+	event := map[string]any{"transaction_id": txID}
+	if err := h.appendWithEvent(ctx, []generic.Transaction{reversalTx}, generic.EventDayCancelled, event); err != nil {
 		writeError(w, http.StatusInternalServerError, "Failed to cancel transaction", err)
 		return
 	}
+	h.reconcileBackdated(ctx, []generic.Transaction{reversalTx})
 
 	writeJSON(w, http.StatusOK, map[string]any{
 		"status":        "cancelled",
@@ -673,8 +827,13 @@ func (h *Handler) CreatePolicy(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Update cache
-	h.policies[policy.ID] = policy
-	h.accruals[policy.ID] = accrual
+	cache, err := h.cache(r.Context())
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "Failed to load policies", err)
+		return
+	}
+	cache.policies[policy.ID] = policy
+	cache.accruals[policy.ID] = accrual
 
 	writeJSON(w, http.StatusCreated, PolicyDTO{
 		ID:           record.ID,
@@ -726,6 +885,11 @@ func (h *Handler) GetAssignments(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	cache, err := h.cache(r.Context())
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "Failed to load policies", err)
+		return
+	}
 	dtos := make([]AssignmentDTO, len(assignments))
 	for i, a := range assignments {
 		dto := AssignmentDTO{
@@ -739,7 +903,7 @@ func (h *Handler) GetAssignments(w http.ResponseWriter, r *http.Request) {
 			s := a.EffectiveTo.Format("2006-01-02")
 			dto.EffectiveTo = &s
 		}
-		if policy, ok := h.policies[generic.PolicyID(a.PolicyID)]; ok {
+		if policy, ok := cache.policies[generic.PolicyID(a.PolicyID)]; ok {
 			dto.PolicyName = policy.Name
 		}
 		dtos[i] = dto
@@ -814,7 +978,9 @@ func (h *Handler) CreateAssignment(w http.ResponseWriter, r *http.Request) {
 // ADMIN HANDLERS
 // =============================================================================
 
-// TriggerRollover processes period-end rollover.
+// TriggerRollover processes period-end rollover. A rollover that fails to
+// post is returned with its error and publishes no event; one already
+// posted (by the scheduler) counts as done.
 func (h *Handler) TriggerRollover(w http.ResponseWriter, r *http.Request) {
 	var req RolloverRequestDTO
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
@@ -827,12 +993,17 @@ func (h *Handler) TriggerRollover(w http.ResponseWriter, r *http.Request) {
 		writeError(w, http.StatusBadRequest, "Invalid period_end date", err)
 		return
 	}
+	if req.DryRun && !validPreviewFormat(w, r) {
+		return
+	}
 
 	ctx := r.Context()
 	engine := &generic.ReconciliationEngine{}
 	ledger := generic.NewLedger(h.Store)
 
 	var results []RolloverResultDTO
+	var preview []ReconciliationPreviewLineDTO
+	failed := 0
 
 	// Get all assignments to process
 	var assignments []sqlite.AssignmentRecord
@@ -847,17 +1018,22 @@ func (h *Handler) TriggerRollover(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 
+	cache, err := h.cache(ctx)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "Failed to load policies", err)
+		return
+	}
 	for _, a := range assignments {
 		if req.PolicyID != nil && a.PolicyID != *req.PolicyID {
 			continue
 		}
 
-		policy, ok := h.policies[generic.PolicyID(a.PolicyID)]
+		policy, ok := cache.policies[generic.PolicyID(a.PolicyID)]
 		if !ok {
 			continue
 		}
 
-		accrual := h.accruals[policy.ID]
+		accrual := cache.accruals[policy.ID]
 		entityID := generic.EntityID(a.EntityID)
 
 		// Get the ending period
@@ -866,7 +1042,7 @@ func (h *Handler) TriggerRollover(w http.ResponseWriter, r *http.Request) {
 
 		// Get current balance
 		txs, _ := ledger.TransactionsInRange(ctx, entityID, policy.ID, endingPeriod.Start, endingPeriod.End)
-		balance := calculateBalance(txs, endingPeriod, policy.Unit, accrual, endPoint)
+		balance := generic.ComputeBalance(txs, endingPeriod, policy.Unit, accrual, endingPeriod.Start, endPoint)
 		balance.EntityID = entityID
 		balance.PolicyID = policy.ID
 
@@ -885,18 +1061,47 @@ func (h *Handler) TriggerRollover(w http.ResponseWriter, r *http.Request) {
 			continue
 		}
 
-		// Add idempotency keys and append transactions
-		for i := range output.Transactions {
-			output.Transactions[i].IdempotencyKey = fmt.Sprintf("rollover-%s-%s-%s-%d",
-				a.EntityID, a.PolicyID, req.PeriodEnd, i)
+		// Add IDs and idempotency keys and append transactions
+		generation, err := h.rolloverGeneration(ctx, a.EntityID, a.PolicyID, req.PeriodEnd)
+		if err != nil {
+			continue
+		}
+		keyRollover(output.Transactions, a.EntityID, a.PolicyID, req.PeriodEnd, generation)
+
+		if req.DryRun {
+			preview = append(preview, previewLine(a.EntityID, policy, endingPeriod, balance, output, nil))
+			continue
 		}
 
+		// A duplicate key means the scheduler already posted the period
 		if len(output.Transactions) > 0 {
-			h.Store.AppendBatch(ctx, output.Transactions)
+			event := map[string]any{"period_end": req.PeriodEnd}
+			err := h.appendWithEvent(ctx, output.Transactions, generic.EventRolloverPosted, event)
+			if errors.Is(err, generic.ErrDuplicateIdempotencyKey) {
+				log.Printf("[Rollover] %s/%s %s: rollover already posted", a.EntityID, a.PolicyID, req.PeriodEnd)
+			} else if err != nil {
+				log.Printf("[Rollover] %s/%s %s: %v", a.EntityID, a.PolicyID, req.PeriodEnd, err)
+				failed++
+				results = append(results, RolloverResultDTO{
+					EntityID:     a.EntityID,
+					PolicyID:     a.PolicyID,
+					Transactions: []TransactionDTO{},
+					Error:        err.Error(),
+				})
+				continue
+			}
 		}
 
 		carriedOver, _ := output.Summary.CarriedOver.Value.Float64()
 		expired, _ := output.Summary.Expired.Value.Float64()
+		h.Events.Publish(ctx, LiveEvent{
+			Type:     generic.EventReconciliationCompleted,
+			EntityID: a.EntityID,
+			Data: map[string]any{
+				"policy_id": a.PolicyID, "period_end": req.PeriodEnd,
+				"carried_over": carriedOver, "expired": expired,
+			},
+		})
 
 		results = append(results, RolloverResultDTO{
 			EntityID:     a.EntityID,
@@ -907,10 +1112,43 @@ func (h *Handler) TriggerRollover(w http.ResponseWriter, r *http.Request) {
 		})
 	}
 
+	if req.DryRun {
+		report := newReconciliationPreview(preview)
+		report.PeriodEnd = req.PeriodEnd
+		writeReconciliationPreview(w, r, report)
+		return
+	}
+	if failed > 0 {
+		log.Printf("[Rollover] %s: %d of %d rollovers failed", req.PeriodEnd, failed, len(results))
+	}
 	writeJSON(w, http.StatusOK, results)
 }
 
-// CreateAdjustment creates a manual adjustment.
+// keyRollover gives a period's rollover transactions IDs and idempotency
+// keys derived from the entity, policy and period end (YYYY-MM-DD), so the
+// manual rollover and the scheduler never post the same period twice.
+// generation is the number of times the period was rolled back
+// (rolloverGeneration), so a rolled back period can be rolled over again.
+func keyRollover(txs []generic.Transaction, entityID, policyID, periodEnd string, generation int) {
+	for i := range txs {
+		key := rolloverKey(entityID, policyID, periodEnd, generation, i)
+		txs[i].ID = generic.TransactionID(key)
+		txs[i].IdempotencyKey = key
+	}
+}
+
+// rolloverKey is the key of a period's i-th rollover transaction. The
+// first generation keeps the format from before rollbacks.
+func rolloverKey(entityID, policyID, periodEnd string, generation, i int) string {
+	if generation == 0 {
+		return fmt.Sprintf("rollover-%s-%s-%s-%d", entityID, policyID, periodEnd, i)
+	}
+	return fmt.Sprintf("rollover-%s-%s-%s-g%d-%d", entityID, policyID, periodEnd, generation, i)
+}
+
+// CreateAdjustment creates a manual adjustment, effective today or on
+// effective_at. One dated in a reconciled period corrects that period's
+// rollover (rereconcile.go).
 func (h *Handler) CreateAdjustment(w http.ResponseWriter, r *http.Request) {
 	var req AdjustmentRequestDTO
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
@@ -918,28 +1156,44 @@ func (h *Handler) CreateAdjustment(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	policy, ok := h.policies[generic.PolicyID(req.PolicyID)]
+	cache, err := h.cache(r.Context())
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "Failed to load policies", err)
+		return
+	}
+	policy, ok := cache.policies[generic.PolicyID(req.PolicyID)]
 	if !ok {
 		writeError(w, http.StatusBadRequest, "Policy not found", nil)
 		return
 	}
 
+	effectiveAt := generic.Today()
+	if req.EffectiveAt != "" {
+		t, err := time.Parse("2006-01-02", req.EffectiveAt)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, "Invalid effective_at format (use YYYY-MM-DD)", err)
+			return
+		}
+		effectiveAt = generic.TimePoint{Time: t}
+	}
+
 	tx := generic.Transaction{
 		ID:             generic.TransactionID(fmt.Sprintf("adj-%d", time.Now().UnixNano())),
 		EntityID:       generic.EntityID(req.EntityID),
 		PolicyID:       generic.PolicyID(req.PolicyID),
 		ResourceType:   policy.ResourceType,
-		EffectiveAt:    generic.Today(),
+		EffectiveAt:    effectiveAt,
 		Delta:          generic.NewAmount(req.Delta, policy.Unit),
 		Type:           generic.TxAdjustment,
 		Reason:         req.Reason,
 		IdempotencyKey: fmt.Sprintf("adj-%s-%s-%d", req.EntityID, req.PolicyID, time.Now().UnixNano()),
 	}
 
-	if err := h.Store.Append(r.Context(), tx); err != nil {
+	if err := h.appendWithEvent(r.Context(), []generic.Transaction{tx}, generic.EventAdjustmentPosted, nil); err != nil {
 		writeError(w, http.StatusInternalServerError, "Failed to create adjustment", err)
 		return
 	}
+	h.reconcileBackdated(r.Context(), []generic.Transaction{tx})
 
 	writeJSON(w, http.StatusCreated, toTransactionDTO(tx))
 }
@@ -952,9 +1206,7 @@ func (h *Handler) ResetDatabase(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Clear caches
-	h.policies = make(map[generic.PolicyID]*generic.Policy)
-	h.accruals = make(map[generic.PolicyID]generic.AccrualSchedule)
-	h.currentScenario = ""
+	h.resetCache(r.Context())
 
 	writeJSON(w, http.StatusOK, map[string]string{"status": "ok"})
 }
@@ -970,7 +1222,7 @@ func writeJSON(w http.ResponseWriter, status int, data any) {
 }
 
 // toTransactionDTOsWithBalance calculates balance at each transaction date
-func (h *Handler) toTransactionDTOsWithBalance(ctx context.Context, txs []generic.Transaction) []TransactionDTO {
+func toTransactionDTOsWithBalance(cache *tenantCache, txs []generic.Transaction) []TransactionDTO {
 	if len(txs) == 0 {
 		return []TransactionDTO{}
 	}
@@ -991,7 +1243,7 @@ func (h *Handler) toTransactionDTOsWithBalance(ctx context.Context, txs []generi
 		})
 
 		// Get policy and accrual
-		policy, ok := h.policies[policyID]
+		policy, ok := cache.policies[policyID]
 		if !ok {
 			// If policy not found, just convert without balance
 			for _, tx := range policyTransactions {
@@ -1001,7 +1253,7 @@ func (h *Handler) toTransactionDTOsWithBalance(ctx context.Context, txs []generi
 			continue
 		}
 
-		accrual := h.accruals[policyID]
+		accrual := cache.accruals[policyID]
 
 		// Process transactions chronologically and calculate balance at each point
 		for i, tx := range policyTransactions {
@@ -1013,7 +1265,7 @@ func (h *Handler) toTransactionDTOsWithBalance(ctx context.Context, txs []generi
 			period := policy.PeriodConfig.PeriodFor(txDate)
 
 			// Calculate balance using existing logic
-			balance := calculateBalance(txsUpToNow, period, policy.Unit, accrual, txDate)
+			balance := generic.ComputeBalance(txsUpToNow, period, policy.Unit, accrual, period.Start, txDate)
 
 			// Get available balance based on consumption mode
 			availableAmount := balance.AvailableWithMode(policy.ConsumptionMode)
@@ -1076,54 +1328,108 @@ func strPtr(s string) *string {
 	return &s
 }
 
+// asKnownAt parses ?as_known_at: a day, meaning the end of it in UTC, or
+// an RFC3339 time. nil means now. On an invalid value it writes the 400
+// and returns false.
+func asKnownAt(w http.ResponseWriter, r *http.Request) (*time.Time, bool) {
+	s := r.URL.Query().Get("as_known_at")
+	if s == "" {
+		return nil, true
+	}
+	if day, err := time.Parse("2006-01-02", s); err == nil {
+		end := day.Add(24*time.Hour - time.Second)
+		return &end, true
+	}
+	t, err := time.Parse(time.RFC3339, s)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "Invalid as_known_at format (use YYYY-MM-DD or RFC3339)", err)
+		return nil, false
+	}
+	t = t.UTC()
+	return &t, true
+}
+
+// formatKnownAt echoes as_known_at in responses; empty when not given.
+func formatKnownAt(knownAt *time.Time) string {
+	if knownAt == nil {
+		return ""
+	}
+	return knownAt.Format(time.RFC3339)
+}
+
 // =============================================================================
 // HOLIDAY ENDPOINTS
 // =============================================================================
 
-// ListHolidays returns all holidays.
-// GET /api/holidays
+// ListHolidays returns all holidays, optionally only those of one calendar.
+// With ?year=, recurring and rule-based holidays are expanded to their
+// (observed) dates in that year.
+// GET /api/holidays?company_id=&calendar_id=&year=
 func (h *Handler) ListHolidays(w http.ResponseWriter, r *http.Request) {
 	ctx := r.Context()
 	companyID := r.URL.Query().Get("company_id")
+	calendarID, filterCalendar := r.URL.Query()["calendar_id"]
 
 	holidays, err := h.Store.GetAllHolidays(ctx, companyID)
 	if err != nil {
 		writeError(w, http.StatusInternalServerError, "Failed to get holidays", err)
 		return
 	}
+	if v := r.URL.Query().Get("year"); v != "" {
+		year, err := strconv.Atoi(v)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, "Invalid year", err)
+			return
+		}
+		holidays = generic.ExpandHolidays(holidays, year)
+	}
 
 	type HolidayDTO struct {
-		ID        string `json:"id"`
-		CompanyID string `json:"company_id"`
-		Date      string `json:"date"`
-		Name      string `json:"name"`
-		Recurring bool   `json:"recurring"`
+		ID         string `json:"id"`
+		CompanyID  string `json:"company_id"`
+		CalendarID string `json:"calendar_id"`
+		Date       string `json:"date"`
+		Name       string `json:"name"`
+		Recurring  bool   `json:"recurring"`
+		Rule       string `json:"rule,omitempty"`
 	}
 
 	dtos := make([]HolidayDTO, 0, len(holidays))
 	for _, hol := range holidays {
-		dtos = append(dtos, HolidayDTO{
-			ID:        hol.ID,
-			CompanyID: hol.CompanyID,
-			Date:      hol.Date.Time.Format("2006-01-02"),
-			Name:      hol.Name,
-			Recurring: hol.Recurring,
-		})
+		if filterCalendar && hol.CalendarID != calendarID[0] {
+			continue
+		}
+		dto := HolidayDTO{
+			ID:         hol.ID,
+			CompanyID:  hol.CompanyID,
+			CalendarID: hol.CalendarID,
+			Date:       hol.Date.Time.Format("2006-01-02"),
+			Name:       hol.Name,
+			Recurring:  hol.Recurring,
+		}
+		if hol.Rule != nil {
+			dto.Rule = hol.Rule.String()
+		}
+		dtos = append(dtos, dto)
 	}
 
 	writeJSON(w, http.StatusOK, map[string]any{"holidays": dtos})
 }
 
-// CreateHoliday creates a new holiday.
+// CreateHoliday creates a new holiday. A rule ("4th thu nov",
+// "easter-2", "7-4 observed") makes it recurring with a computed date;
+// date is then optional.
 // POST /api/holidays
 func (h *Handler) CreateHoliday(w http.ResponseWriter, r *http.Request) {
 	ctx := r.Context()
 
 	var req struct {
-		CompanyID string `json:"company_id"`
-		Date      string `json:"date"`
-		Name      string `json:"name"`
-		Recurring bool   `json:"recurring"`
+		CompanyID  string `json:"company_id"`
+		CalendarID string `json:"calendar_id"` // Empty = company-wide
+		Date       string `json:"date"`
+		Name       string `json:"name"`
+		Recurring  bool   `json:"recurring"`
+		Rule       string `json:"rule"`
 	}
 
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
@@ -1131,23 +1437,47 @@ func (h *Handler) CreateHoliday(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if req.Date == "" || req.Name == "" {
-		writeError(w, http.StatusBadRequest, "Date and name are required", nil)
+	if (req.Date == "" && req.Rule == "") || req.Name == "" {
+		writeError(w, http.StatusBadRequest, "Date (or rule) and name are required", nil)
 		return
 	}
 
-	date, err := time.Parse("2006-01-02", req.Date)
+	var rule *generic.HolidayRule
+	if req.Rule != "" {
+		parsed, err := generic.ParseHolidayRule(req.Rule)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, err.Error(), err)
+			return
+		}
+		rule = &parsed
+	}
+
+	var date time.Time
+	if req.Date != "" {
+		var err error
+		date, err = time.Parse("2006-01-02", req.Date)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, "Invalid date format (use YYYY-MM-DD)", err)
+			return
+		}
+	} else {
+		date, _ = rule.DateIn(time.Now().Year())
+	}
+
+	companyID, err := h.holidayCalendarCompany(ctx, req.CompanyID, req.CalendarID)
 	if err != nil {
-		writeError(w, http.StatusBadRequest, "Invalid date format (use YYYY-MM-DD)", err)
+		writeError(w, http.StatusBadRequest, err.Error(), nil)
 		return
 	}
 
 	holiday := generic.Holiday{
-		ID:        fmt.Sprintf("holiday-%d", time.Now().UnixNano()),
-		CompanyID: req.CompanyID,
-		Date:      generic.TimePoint{Time: date, Granularity: generic.GranularityDay},
-		Name:      req.Name,
-		Recurring: req.Recurring,
+		ID:         fmt.Sprintf("holiday-%d", time.Now().UnixNano()),
+		CompanyID:  companyID,
+		CalendarID: req.CalendarID,
+		Date:       generic.TimePoint{Time: date, Granularity: generic.GranularityDay},
+		Name:       req.Name,
+		Recurring:  req.Recurring || rule != nil,
+		Rule:       rule,
 	}
 
 	if err := h.Store.SaveHoliday(ctx, holiday); err != nil {
@@ -1175,7 +1505,8 @@ func (h *Handler) DeleteHoliday(w http.ResponseWriter, r *http.Request) {
 	writeJSON(w, http.StatusOK, map[string]any{"status": "deleted"})
 }
 
-// AddDefaultHolidays adds common US holidays.
+// AddDefaultHolidays adds the US federal holidays (rule-based, observed on
+// the nearest weekday) plus New Year's Eve.
 // POST /api/holidays/defaults
 func (h *Handler) AddDefaultHolidays(w http.ResponseWriter, r *http.Request) {
 	ctx := r.Context()
@@ -1185,26 +1516,18 @@ func (h *Handler) AddDefaultHolidays(w http.ResponseWriter, r *http.Request) {
 	}
 	json.NewDecoder(r.Body).Decode(&req)
 
-	// Common US holidays (recurring)
-	defaults := []struct {
-		month int
-		day   int
-		name  string
-	}{
-		{1, 1, "New Year's Day"},
-		{7, 4, "Independence Day"},
-		{12, 25, "Christmas Day"},
-		{12, 31, "New Year's Eve"},
-	}
-
 	year := time.Now().Year()
-	for _, d := range defaults {
-		holiday := generic.Holiday{
-			ID:        fmt.Sprintf("holiday-%s-%02d%02d", req.CompanyID, d.month, d.day),
-			CompanyID: req.CompanyID,
-			Date:      generic.NewTimePoint(year, time.Month(d.month), d.day),
-			Name:      d.name,
-			Recurring: true,
+	defaults := append(generic.USFederalHolidays(req.CompanyID), generic.Holiday{
+		CompanyID: req.CompanyID,
+		Date:      generic.NewTimePoint(year, time.December, 31),
+		Name:      "New Year's Eve",
+		Recurring: true,
+	})
+
+	for _, holiday := range defaults {
+		holiday.ID = fmt.Sprintf("holiday-%s-%s", req.CompanyID, holiday.Date.Time.Format("0102"))
+		if holiday.Rule != nil {
+			holiday.ID = fmt.Sprintf("holiday-%s-%s", req.CompanyID, strings.ReplaceAll(holiday.Rule.String(), " ", "-"))
 		}
 		h.Store.SaveHoliday(ctx, holiday)
 	}
@@ -1215,6 +1538,168 @@ func (h *Handler) AddDefaultHolidays(w http.ResponseWriter, r *http.Request) {
 	})
 }
 
+// MaxHolidayImportBytes caps the size of an uploaded holiday file.
+const MaxHolidayImportBytes = 2 << 20
+
+// ImportHolidays bulk-loads holidays from an iCalendar or CSV file.
+// POST /api/holidays/import?company_id=&calendar_id=&format=ics|csv&dry_run=true&replace_year=2026
+//
+// The request body is the raw file. Format comes from ?format, then the
+// Content-Type (text/calendar, text/csv), then the content itself. With
+// dry_run the import is rolled back and the response previews it.
+func (h *Handler) ImportHolidays(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	q := r.URL.Query()
+	calendarID := q.Get("calendar_id")
+	companyID, err := h.holidayCalendarCompany(ctx, q.Get("company_id"), calendarID)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error(), nil)
+		return
+	}
+
+	dryRun := false
+	if v := q.Get("dry_run"); v != "" {
+		b, err := strconv.ParseBool(v)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, "Invalid dry_run (use true or false)", err)
+			return
+		}
+		dryRun = b
+	}
+
+	replaceYear := 0
+	if v := q.Get("replace_year"); v != "" {
+		y, err := strconv.Atoi(v)
+		if err != nil || y < 1900 || y > 9999 {
+			writeError(w, http.StatusBadRequest, "Invalid replace_year", err)
+			return
+		}
+		replaceYear = y
+	}
+
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, MaxHolidayImportBytes))
+	if err != nil {
+		writeError(w, http.StatusRequestEntityTooLarge, "Holiday file too large", err)
+		return
+	}
+
+	format := holidayImportFormat(q.Get("format"), r.Header.Get("Content-Type"), body)
+	var (
+		holidays []generic.Holiday
+		issues   []generic.HolidayParseIssue
+	)
+	switch format {
+	case "ics":
+		holidays, issues, err = generic.ParseHolidaysICS(bytes.NewReader(body), companyID)
+	case "csv":
+		holidays, issues, err = generic.ParseHolidaysCSV(bytes.NewReader(body), companyID)
+	default:
+		writeError(w, http.StatusBadRequest, "Unknown format (use ics or csv)", nil)
+		return
+	}
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "Failed to parse holiday file", err)
+		return
+	}
+	if len(holidays) == 0 {
+		// Guards replace_year against wiping a year with an empty file
+		writeError(w, http.StatusBadRequest, "No holidays found in file", nil)
+		return
+	}
+
+	result, err := h.Store.ImportHolidays(ctx, companyID, holidays, sqlite.HolidayImportOptions{
+		CalendarID:  calendarID,
+		ReplaceYear: replaceYear,
+		DryRun:      dryRun,
+	})
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "Failed to import holidays", err)
+		return
+	}
+
+	dto := HolidayImportResultDTO{
+		CompanyID:   companyID,
+		CalendarID:  calendarID,
+		Format:      format,
+		DryRun:      dryRun,
+		ReplaceYear: replaceYear,
+		Parsed:      len(holidays),
+		Created:     result.Created,
+		Duplicates:  result.Duplicates,
+		OutOfYear:   result.OutOfYear,
+		Removed:     result.Removed,
+		Holidays:    make([]HolidayImportItemDTO, 0, len(result.Items)),
+		Issues:      make([]HolidayImportIssueDTO, 0, len(issues)),
+	}
+	for _, item := range result.Items {
+		itemDTO := HolidayImportItemDTO{
+			ID:        item.Holiday.ID,
+			Date:      item.Holiday.Date.Time.Format("2006-01-02"),
+			Name:      item.Holiday.Name,
+			Recurring: item.Holiday.Recurring,
+			Status:    item.Status,
+		}
+		if item.Holiday.Rule != nil {
+			itemDTO.Rule = item.Holiday.Rule.String()
+		}
+		dto.Holidays = append(dto.Holidays, itemDTO)
+	}
+	for _, issue := range issues {
+		dto.Issues = append(dto.Issues, HolidayImportIssueDTO{Line: issue.Line, Message: issue.Message})
+	}
+
+	status := http.StatusCreated
+	if dryRun {
+		status = http.StatusOK
+	}
+	writeJSON(w, status, dto)
+}
+
+// holidayCalendarCompany validates a holiday's calendar and returns the
+// company it belongs to. Without a calendar, companyID is used as given.
+func (h *Handler) holidayCalendarCompany(ctx context.Context, companyID, calendarID string) (string, error) {
+	if calendarID == "" {
+		return companyID, nil
+	}
+	calendar, err := h.Store.GetHolidayCalendar(ctx, calendarID)
+	if err != nil {
+		return "", err
+	}
+	if calendar == nil {
+		return "", fmt.Errorf("Unknown holiday calendar: %s", calendarID)
+	}
+	if companyID != "" && companyID != calendar.CompanyID {
+		return "", fmt.Errorf("Holiday calendar %s belongs to another company", calendarID)
+	}
+	return calendar.CompanyID, nil
+}
+
+// holidayImportFormat picks "ics" or "csv" from the explicit format, the
+// content type, or the file contents, in that order.
+func holidayImportFormat(format, contentType string, body []byte) string {
+	switch strings.ToLower(format) {
+	case "ics", "ical", "icalendar":
+		return "ics"
+	case "csv":
+		return "csv"
+	case "":
+	default:
+		return ""
+	}
+
+	switch {
+	case strings.HasPrefix(contentType, "text/calendar"):
+		return "ics"
+	case strings.HasPrefix(contentType, "text/csv"):
+		return "csv"
+	}
+
+	if bytes.HasPrefix(bytes.TrimSpace(bytes.TrimPrefix(body, []byte("\xef\xbb\xbf"))), []byte("BEGIN:VCALENDAR")) {
+		return "ics"
+	}
+	return "csv"
+}
+
 // =============================================================================
 // APPROVAL WORKFLOW ENDPOINTS
 // =============================================================================
@@ -1245,6 +1730,11 @@ func (h *Handler) ListPendingRequests(w http.ResponseWriter, r *http.Request) {
 
 	dtos := make([]RequestDTO, 0, len(requests))
 	for _, req := range requests {
+		// Managers only see their reports' requests
+		if ok, err := h.canApprove(ctx, req.EntityID); err != nil || !ok {
+			continue
+		}
+
 		emp, _ := h.Store.GetEmployee(ctx, req.EntityID)
 		empName := req.EntityID
 		if emp != nil {
@@ -1281,6 +1771,8 @@ func (h *Handler) ApproveRequest(w http.ResponseWriter, r *http.Request) {
 	if req.ApproverID == "" {
 		req.ApproverID = "admin"
 	}
+	// An authenticated approver is always the caller
+	req.ApproverID = actorID(ctx, req.ApproverID)
 
 	// Get the request
 	request, err := h.Store.GetRequest(ctx, id)
@@ -1292,6 +1784,13 @@ func (h *Handler) ApproveRequest(w http.ResponseWriter, r *http.Request) {
 		writeError(w, http.StatusNotFound, "Request not found", nil)
 		return
 	}
+	if ok, err := h.canApprove(ctx, request.EntityID); err != nil {
+		writeError(w, http.StatusInternalServerError, "Failed to authorize request", err)
+		return
+	} else if !ok {
+		writeError(w, http.StatusForbidden, "Only the employee's manager can decide this request", nil)
+		return
+	}
 	if request.Status != "pending" {
 		writeError(w, http.StatusConflict, "Request is not pending", nil)
 		return
@@ -1343,11 +1842,11 @@ func (h *Handler) ApproveRequest(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 
-	if len(batchTxs) > 0 {
-		if err := h.Store.AppendBatch(ctx, batchTxs); err != nil {
-			writeError(w, http.StatusInternalServerError, "Failed to process approval", err)
-			return
-		}
+	// Recorded even without pending transactions, so the event always fires
+	event := map[string]any{"request_id": id, "approved_by": req.ApproverID}
+	if err := h.appendWithEvent(ctx, batchTxs, generic.EventRequestApproved, event); err != nil {
+		writeError(w, http.StatusInternalServerError, "Failed to process approval", err)
+		return
 	}
 
 	// Save updated request
@@ -1355,6 +1854,7 @@ func (h *Handler) ApproveRequest(w http.ResponseWriter, r *http.Request) {
 		writeError(w, http.StatusInternalServerError, "Failed to update request", err)
 		return
 	}
+	h.Events.Publish(ctx, LiveEvent{Type: generic.EventRequestApproved, EntityID: request.EntityID, Data: event})
 
 	writeJSON(w, http.StatusOK, map[string]any{
 		"status":      "approved",
@@ -1377,6 +1877,7 @@ func (h *Handler) RejectRequest(w http.ResponseWriter, r *http.Request) {
 	if req.RejecterID == "" {
 		req.RejecterID = "admin"
 	}
+	req.RejecterID = actorID(ctx, req.RejecterID)
 
 	// Get the request
 	request, err := h.Store.GetRequest(ctx, id)
@@ -1388,6 +1889,13 @@ func (h *Handler) RejectRequest(w http.ResponseWriter, r *http.Request) {
 		writeError(w, http.StatusNotFound, "Request not found", nil)
 		return
 	}
+	if ok, err := h.canApprove(ctx, request.EntityID); err != nil {
+		writeError(w, http.StatusInternalServerError, "Failed to authorize request", err)
+		return
+	} else if !ok {
+		writeError(w, http.StatusForbidden, "Only the employee's manager can decide this request", nil)
+		return
+	}
 	if request.Status != "pending" {
 		writeError(w, http.StatusConflict, "Request is not pending", nil)
 		return
@@ -1421,11 +1929,10 @@ func (h *Handler) RejectRequest(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 
-	if len(batchTxs) > 0 {
-		if err := h.Store.AppendBatch(ctx, batchTxs); err != nil {
-			writeError(w, http.StatusInternalServerError, "Failed to process rejection", err)
-			return
-		}
+	event := map[string]any{"request_id": id, "rejected_by": req.RejecterID, "reason": req.Reason}
+	if err := h.appendWithEvent(ctx, batchTxs, generic.EventRequestRejected, event); err != nil {
+		writeError(w, http.StatusInternalServerError, "Failed to process rejection", err)
+		return
 	}
 
 	// Save updated request
@@ -1433,6 +1940,7 @@ func (h *Handler) RejectRequest(w http.ResponseWriter, r *http.Request) {
 		writeError(w, http.StatusInternalServerError, "Failed to update request", err)
 		return
 	}
+	h.Events.Publish(ctx, LiveEvent{Type: generic.EventRequestRejected, EntityID: request.EntityID, Data: event})
 
 	writeJSON(w, http.StatusOK, map[string]any{
 		"status":      "rejected",
@@ -1440,54 +1948,3 @@ func (h *Handler) RejectRequest(w http.ResponseWriter, r *http.Request) {
 		"reason":      req.Reason,
 	})
 }
-
-// =============================================================================
-// RECONCILIATION ENDPOINTS
-// =============================================================================
-
-// ListReconciliationRuns returns reconciliation run history.
-// GET /api/reconciliation/runs
-func (h *Handler) ListReconciliationRuns(w http.ResponseWriter, r *http.Request) {
-	ctx := r.Context()
-	status := r.URL.Query().Get("status")
-
-	runs, err := h.Store.GetReconciliationRuns(ctx, status)
-	if err != nil {
-		writeError(w, http.StatusInternalServerError, "Failed to get reconciliation runs", err)
-		return
-	}
-
-	type RunDTO struct {
-		ID          string  `json:"id"`
-		PolicyID    string  `json:"policy_id"`
-		EntityID    string  `json:"entity_id"`
-		PeriodStart string  `json:"period_start"`
-		PeriodEnd   string  `json:"period_end"`
-		Status      string  `json:"status"`
-		CarriedOver float64 `json:"carried_over"`
-		Expired     float64 `json:"expired"`
-		Error       string  `json:"error,omitempty"`
-		CompletedAt string  `json:"completed_at,omitempty"`
-	}
-
-	dtos := make([]RunDTO, 0, len(runs))
-	for _, run := range runs {
-		dto := RunDTO{
-			ID:          run.ID,
-			PolicyID:    run.PolicyID,
-			EntityID:    run.EntityID,
-			PeriodStart: run.PeriodStart.Format("2006-01-02"),
-			PeriodEnd:   run.PeriodEnd.Format("2006-01-02"),
-			Status:      run.Status,
-			CarriedOver: run.CarriedOver,
-			Expired:     run.Expired,
-			Error:       run.Error,
-		}
-		if run.CompletedAt != nil {
-			dto.CompletedAt = run.CompletedAt.Format(time.RFC3339)
-		}
-		dtos = append(dtos, dto)
-	}
-
-	writeJSON(w, http.StatusOK, map[string]any{"runs": dtos})
-}
@@ -4,12 +4,21 @@ handlers_test.go - Unit tests for API handlers
 Tests for:
 - Transaction cancellation (CancelTransaction)
 - Balance updates after cancellation
+- Holidays (CreateHoliday, recurring and rule-based, AddDefaultHolidays)
+- Holiday import (ImportHolidays)
+- Requests and reconciliation runs in the store
+- Rollover failures (TriggerRollover reports failed posts)
+- Knowledge time (as_known_at on balance, history and transactions)
 */
 package api
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
 	"testing"
 	"time"
 
@@ -436,6 +445,118 @@ func TestHoliday_Delete(t *testing.T) {
 	}
 }
 
+func TestImportHolidays_DryRunDedupAndReplaceYear(t *testing.T) {
+	// GIVEN: A company with one holiday already stored
+	// WHEN: Importing a CSV as a dry run, then for real, then replacing the year
+	// THEN: Dry run changes nothing, duplicates are skipped, and replace
+	//       removes entries missing from the new file
+
+	handler := setupTestHandler(t)
+	router := NewRouter(handler)
+	ctx := context.Background()
+
+	if err := handler.Store.SaveHoliday(ctx, generic.Holiday{
+		ID: "existing", CompanyID: "acme", Date: generic.NewTimePoint(2026, time.January, 1), Name: "New Year's Day",
+	}); err != nil {
+		t.Fatalf("Failed to save holiday: %v", err)
+	}
+
+	importCSV := func(query, body string) (int, HolidayImportResultDTO) {
+		req := httptest.NewRequest(http.MethodPost, "/api/holidays/import?company_id=acme&"+query, strings.NewReader(body))
+		req.Header.Set("Content-Type", "text/csv")
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, req)
+		var result HolidayImportResultDTO
+		json.Unmarshal(rec.Body.Bytes(), &result)
+		return rec.Code, result
+	}
+	count := func() int {
+		holidays, err := handler.Store.GetAllHolidays(ctx, "acme")
+		if err != nil {
+			t.Fatalf("Failed to list holidays: %v", err)
+		}
+		return len(holidays)
+	}
+
+	file := "date,name\n2026-01-01,New Year's Day\n2026-05-01,Labour Day\n2026-05-01,Labour Day\n"
+
+	code, result := importCSV("dry_run=true", file)
+	if code != http.StatusOK {
+		t.Fatalf("Expected 200 for dry run, got %d", code)
+	}
+	if result.Created != 1 || result.Duplicates != 2 {
+		t.Errorf("Expected 1 created and 2 duplicates, got %+v", result)
+	}
+	if n := count(); n != 1 {
+		t.Fatalf("Dry run should not write, found %d holidays", n)
+	}
+
+	code, result = importCSV("", file)
+	if code != http.StatusCreated || result.Created != 1 {
+		t.Fatalf("Expected 201 with 1 created, got %d %+v", code, result)
+	}
+	if n := count(); n != 2 {
+		t.Fatalf("Expected 2 holidays after import, got %d", n)
+	}
+
+	code, result = importCSV("replace_year=2026", "date,name\n2026-12-25,Christmas Day\n2027-01-01,New Year's Day\n")
+	if code != http.StatusCreated {
+		t.Fatalf("Expected 201, got %d", code)
+	}
+	if result.Removed != 2 || result.Created != 1 || result.OutOfYear != 1 {
+		t.Errorf("Expected 2 removed, 1 created, 1 out of year, got %+v", result)
+	}
+	if n := count(); n != 1 {
+		t.Errorf("Expected only the replacement holiday, got %d", n)
+	}
+}
+
+func TestHoliday_RuleBasedAndObserved(t *testing.T) {
+	// GIVEN: The default US holidays and a Good Friday rule
+	// WHEN: Querying holidays for specific years
+	// THEN: Rules expand per year and weekend holidays move to weekdays
+
+	handler := setupTestHandler(t)
+	router := NewRouter(handler)
+
+	for _, req := range []*http.Request{
+		httptest.NewRequest(http.MethodPost, "/api/holidays/defaults", strings.NewReader(`{"company_id":"acme"}`)),
+		httptest.NewRequest(http.MethodPost, "/api/holidays", strings.NewReader(`{"company_id":"acme","name":"Good Friday","rule":"easter-2"}`)),
+	} {
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, req)
+		if rec.Code != http.StatusCreated {
+			t.Fatalf("Expected 201, got %d: %s", rec.Code, rec.Body.String())
+		}
+	}
+
+	tests := []struct {
+		date    generic.TimePoint
+		holiday bool
+	}{
+		{generic.NewTimePoint(2025, time.November, 27), true}, // Thanksgiving
+		{generic.NewTimePoint(2026, time.November, 26), true},
+		{generic.NewTimePoint(2026, time.November, 27), false},
+		{generic.NewTimePoint(2026, time.July, 3), true},   // Observed Independence Day
+		{generic.NewTimePoint(2025, time.April, 18), true}, // Good Friday
+		{generic.NewTimePoint(2026, time.April, 3), true},
+	}
+	for _, tt := range tests {
+		if got := handler.Store.IsHoliday("acme", tt.date); got != tt.holiday {
+			t.Errorf("IsHoliday(%s) = %v, want %v", tt.date, got, tt.holiday)
+		}
+	}
+
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/holidays?company_id=acme&year=2026", nil))
+	if !strings.Contains(rec.Body.String(), `"date":"2026-07-03","name":"Independence Day (observed)"`) {
+		t.Errorf("Expected observed Independence Day in 2026 listing: %s", rec.Body.String())
+	}
+	if !strings.Contains(rec.Body.String(), `"rule":"easter-2"`) {
+		t.Errorf("Expected Good Friday rule in listing: %s", rec.Body.String())
+	}
+}
+
 // =============================================================================
 // REQUEST APPROVAL TESTS
 // =============================================================================
@@ -680,3 +801,185 @@ func TestReconciliationRun_IsComplete(t *testing.T) {
 		t.Error("Different employee should not be complete")
 	}
 }
+
+// failingAppendStore fails ledger appends for one entity.
+type failingAppendStore struct {
+	Store
+	fail generic.EntityID
+}
+
+func (s *failingAppendStore) AppendBatchWithEvent(ctx context.Context, txs []generic.Transaction, eventType generic.EventType, data map[string]any) error {
+	if len(txs) > 0 && txs[0].EntityID == s.fail {
+		return fmt.Errorf("database is locked")
+	}
+	return s.Store.AppendBatchWithEvent(ctx, txs, eventType, data)
+}
+
+func TestTriggerRollover_ReportsFailedPostsAndSkipsTheirEvents(t *testing.T) {
+	// GIVEN: Two employees with PTO since 2025, and a ledger that rejects
+	//        emp-fail's writes
+	// WHEN: 2025 is rolled over for everyone, then again for emp-ok
+	// THEN: emp-fail's result carries the error and no
+	//       reconciliation.completed is published for it; the repeat finds
+	//       emp-ok's rollover already posted and posts nothing more
+
+	handler := setupTestHandler(t)
+	handler.Events = NewEventBus()
+	ctx := context.Background()
+
+	if err := handler.createPolicyFromJSON(ctx, timeoff.StandardPTOJSON("pto-test", "Test PTO", 12, 5)); err != nil {
+		t.Fatalf("Failed to create policy: %v", err)
+	}
+	hireDate := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	for _, id := range []string{"emp-ok", "emp-fail"} {
+		if err := handler.Store.SaveEmployee(ctx, sqlite.Employee{ID: id, Name: id, HireDate: hireDate}); err != nil {
+			t.Fatalf("Failed to create employee: %v", err)
+		}
+		if err := handler.Store.SaveAssignment(ctx, sqlite.AssignmentRecord{
+			ID: "assign-" + id, EntityID: id, PolicyID: "pto-test",
+			EffectiveFrom: hireDate, ConsumptionPriority: 1,
+		}); err != nil {
+			t.Fatalf("Failed to save assignment: %v", err)
+		}
+	}
+	handler.Store = &failingAppendStore{Store: handler.Store, fail: "emp-fail"}
+	router := NewRouter(handler)
+	rollover := func(body string) []RolloverResultDTO {
+		t.Helper()
+		req := httptest.NewRequest(http.MethodPost, "/api/admin/rollover", strings.NewReader(body))
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, req)
+		if rec.Code != http.StatusOK {
+			t.Fatalf("Expected 200, got %d: %s", rec.Code, rec.Body.String())
+		}
+		var results []RolloverResultDTO
+		if err := json.Unmarshal(rec.Body.Bytes(), &results); err != nil {
+			t.Fatalf("Failed to decode results: %v", err)
+		}
+		return results
+	}
+
+	sub := handler.Events.Subscribe(ctx)
+	defer sub.Close()
+	results := rollover(`{"period_end":"2025-12-31"}`)
+	if len(results) != 2 {
+		t.Fatalf("Expected 2 results, got %+v", results)
+	}
+	for _, r := range results {
+		if failed := r.EntityID == "emp-fail"; failed != (r.Error != "") {
+			t.Errorf("%s: unexpected error %q", r.EntityID, r.Error)
+		}
+	}
+
+	// Published before the response, so already buffered
+	completed := map[string]int{}
+	for len(sub.Events) > 0 {
+		if e := <-sub.Events; e.Type == generic.EventReconciliationCompleted {
+			completed[e.EntityID]++
+		}
+	}
+	if completed["emp-ok"] != 1 || completed["emp-fail"] != 0 {
+		t.Errorf("Expected reconciliation.completed for emp-ok only, got %v", completed)
+	}
+
+	before, _ := handler.Store.GetAllTransactions(ctx, 1000)
+	again := rollover(`{"entity_id":"emp-ok","period_end":"2025-12-31"}`)
+	if len(again) != 1 || again[0].Error != "" {
+		t.Errorf("Expected the repeat to find the rollover posted, got %+v", again)
+	}
+	if after, _ := handler.Store.GetAllTransactions(ctx, 1000); len(after) != len(before) {
+		t.Errorf("Repeat posted %d more transactions", len(after)-len(before))
+	}
+}
+
+// =============================================================================
+// KNOWLEDGE TIME TESTS
+// =============================================================================
+
+func TestBalanceAsKnownAt_LeavesOutLaterBookings(t *testing.T) {
+	// GIVEN: A day taken on Jan 15, then (a second later) a +3 adjustment
+	//        backdated to Feb 1
+	// WHEN: The balance, history and transactions are read as known when
+	//       the day was booked, and as known now
+	// THEN: Only the current reads include the adjustment; created_at is
+	//       returned and a bad as_known_at is a 400
+
+	handler := setupTestHandler(t)
+	ctx := context.Background()
+
+	if err := handler.createPolicyFromJSON(ctx, timeoff.StandardPTOJSON("pto-test", "Test PTO", 12, 5)); err != nil {
+		t.Fatalf("Failed to create policy: %v", err)
+	}
+	year := time.Now().UTC().Year()
+	since := time.Date(year, 1, 1, 0, 0, 0, 0, time.UTC)
+	if err := handler.Store.SaveEmployee(ctx, sqlite.Employee{ID: "emp-known", Name: "Known User", HireDate: since}); err != nil {
+		t.Fatalf("Failed to create employee: %v", err)
+	}
+	if err := handler.Store.SaveAssignment(ctx, sqlite.AssignmentRecord{
+		ID: "assign-known", EntityID: "emp-known", PolicyID: "pto-test",
+		EffectiveFrom: since, ConsumptionPriority: 1,
+	}); err != nil {
+		t.Fatalf("Failed to save assignment: %v", err)
+	}
+	tx := func(id string, at time.Time, delta float64, txType generic.TransactionType) generic.Transaction {
+		return generic.Transaction{
+			ID: generic.TransactionID(id), EntityID: "emp-known", PolicyID: "pto-test",
+			ResourceType: timeoff.ResourcePTO, EffectiveAt: generic.TimePoint{Time: at},
+			Delta: generic.NewAmount(delta, generic.UnitDays), Type: txType, IdempotencyKey: id,
+		}
+	}
+	if err := handler.Store.Append(ctx, tx("tx-day", time.Date(year, 1, 15, 0, 0, 0, 0, time.UTC), -1, generic.TxConsumption)); err != nil {
+		t.Fatalf("Failed to append day: %v", err)
+	}
+	// created_at has second precision
+	time.Sleep(time.Until(time.Now().Truncate(time.Second).Add(time.Second)))
+	if err := handler.Store.Append(ctx, tx("tx-backdated", time.Date(year, 2, 1, 0, 0, 0, 0, time.UTC), 3, generic.TxAdjustment)); err != nil {
+		t.Fatalf("Failed to append adjustment: %v", err)
+	}
+
+	router := NewRouter(handler)
+	get := func(path string, v any) int {
+		t.Helper()
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, req)
+		json.Unmarshal(rec.Body.Bytes(), v)
+		return rec.Code
+	}
+
+	var txs []TransactionDTO
+	get("/api/employees/emp-known/transactions?policy_id=pto-test", &txs)
+	if len(txs) != 2 || txs[0].ID != "tx-day" || txs[0].CreatedAt == "" {
+		t.Fatalf("Expected both transactions with created_at, got %+v", txs)
+	}
+	knownAt := txs[0].CreatedAt
+
+	get("/api/employees/emp-known/transactions?policy_id=pto-test&as_known_at="+knownAt, &txs)
+	if len(txs) != 1 || txs[0].ID != "tx-day" {
+		t.Errorf("As known at %s: expected only tx-day, got %+v", knownAt, txs)
+	}
+
+	var now, then BalanceDTO
+	get("/api/employees/emp-known/balance", &now)
+	get("/api/employees/emp-known/balance?as_known_at="+knownAt, &then)
+	if len(now.Policies) != 1 || len(then.Policies) != 1 {
+		t.Fatalf("Expected one policy balance, got %+v and %+v", now, then)
+	}
+	if diff := now.TotalAvailable - then.TotalAvailable; diff != 3 {
+		t.Errorf("Expected the backdated +3 only in the current balance, got %.2f vs %.2f", now.TotalAvailable, then.TotalAvailable)
+	}
+	if then.AsKnownAt != knownAt || now.AsKnownAt != "" {
+		t.Errorf("Expected as_known_at echoed only when given, got %q and %q", then.AsKnownAt, now.AsKnownAt)
+	}
+
+	var history BalanceHistoryDTO
+	path := fmt.Sprintf("/api/employees/emp-known/balance/history?from=%d-02-01&to=%d-02-01&as_known_at=%s", year, year, knownAt)
+	get(path, &history)
+	if len(history.Points) != 1 || history.Points[0].Available != 11 || history.AsKnownAt != knownAt {
+		t.Errorf("Expected 11 available on Feb 1 as then known (12 less the day, no adjustment), got %+v", history)
+	}
+
+	if code := get("/api/employees/emp-known/balance?as_known_at=yesterday", &then); code != http.StatusBadRequest {
+		t.Errorf("Invalid as_known_at: %d, want 400", code)
+	}
+}
@@ -0,0 +1,242 @@
+/*
+holiday_calendars.go - Named holiday calendars assigned to employees
+
+PURPOSE:
+  Offices observe different holidays. A holiday calendar ("us", "uk",
+  "fr-paris") groups the holidays of one location, and each employee is
+  assigned a calendar with an effective date so relocations work:
+
+    alice: us from 2024-01-01, uk from 2025-07-01
+      -> 2025-07-04 is a workday for Alice (she is in the UK by then)
+      -> 2025-12-26 (Boxing Day) is a holiday for Alice
+
+WHICH HOLIDAYS APPLY:
+  On a given date, an employee gets:
+  - Global holidays (no company, no calendar)
+  - Company-wide holidays of their calendar's company (no calendar)
+  - Holidays of the calendar assigned to them on that date
+  Employees without a calendar only get global holidays.
+
+WHERE IT IS USED:
+  - SubmitRequest skips the employee's holidays, so they are not deducted
+  - GET /api/employees/{id}/holidays lists the employee's holidays
+  - POST /api/holidays and /api/holidays/import accept calendar_id
+
+ENDPOINTS:
+  GET  /api/holiday-calendars                    List calendars
+  POST /api/holiday-calendars                    Create calendar {"id":"uk","name":"United Kingdom"}
+  GET  /api/employees/{id}/holiday-calendars     Calendar history
+  POST /api/employees/{id}/holiday-calendars     Assign {"calendar_id":"uk","effective_from":"2025-07-01"}
+  GET  /api/employees/{id}/holidays?year=        Holidays resolved for the employee
+
+SEE ALSO:
+  - generic/time.go: EntityHolidayCalendar, IsWorkdayFor
+  - store/sqlite/sqlite.go: IsEntityHoliday, GetEntityHolidays
+*/
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"regexp"
+	"strconv"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/warp/resource-engine/generic"
+	"github.com/warp/resource-engine/store/sqlite"
+)
+
+// holidayCalendarIDPattern keeps calendar IDs usable in URLs and filters.
+var holidayCalendarIDPattern = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9_.-]*$`)
+
+// =============================================================================
+// CALENDARS
+// =============================================================================
+
+// ListHolidayCalendars returns all holiday calendars.
+// GET /api/holiday-calendars
+func (h *Handler) ListHolidayCalendars(w http.ResponseWriter, r *http.Request) {
+	calendars, err := h.Store.ListHolidayCalendars(r.Context())
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "Failed to get holiday calendars", err)
+		return
+	}
+
+	dtos := make([]HolidayCalendarDTO, len(calendars))
+	for i, c := range calendars {
+		dtos[i] = toHolidayCalendarDTO(c)
+	}
+	writeJSON(w, http.StatusOK, dtos)
+}
+
+// CreateHolidayCalendar creates (or renames) a holiday calendar.
+// POST /api/holiday-calendars
+func (h *Handler) CreateHolidayCalendar(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	var req CreateHolidayCalendarRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "Invalid request body", err)
+		return
+	}
+	if !holidayCalendarIDPattern.MatchString(req.ID) {
+		writeError(w, http.StatusBadRequest, "Invalid id (letters, digits, '-', '_' and '.')", nil)
+		return
+	}
+	if req.Name == "" {
+		req.Name = req.ID
+	}
+
+	existing, err := h.Store.GetHolidayCalendar(ctx, req.ID)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "Failed to get holiday calendar", err)
+		return
+	}
+	if existing != nil && existing.CompanyID != req.CompanyID {
+		writeError(w, http.StatusConflict, "Holiday calendar belongs to another company", nil)
+		return
+	}
+
+	calendar := sqlite.HolidayCalendarRecord{ID: req.ID, CompanyID: req.CompanyID, Name: req.Name}
+	if err := h.Store.SaveHolidayCalendar(ctx, calendar); err != nil {
+		writeError(w, http.StatusInternalServerError, "Failed to save holiday calendar", err)
+		return
+	}
+
+	status := http.StatusCreated
+	if existing != nil {
+		status = http.StatusOK
+	}
+	writeJSON(w, status, toHolidayCalendarDTO(calendar))
+}
+
+func toHolidayCalendarDTO(c sqlite.HolidayCalendarRecord) HolidayCalendarDTO {
+	return HolidayCalendarDTO{ID: c.ID, CompanyID: c.CompanyID, Name: c.Name}
+}
+
+// =============================================================================
+// EMPLOYEE ASSIGNMENTS
+// =============================================================================
+
+// GetEmployeeHolidayCalendars returns an employee's calendar history.
+// GET /api/employees/{id}/holiday-calendars
+func (h *Handler) GetEmployeeHolidayCalendars(w http.ResponseWriter, r *http.Request) {
+	entityID := chi.URLParam(r, "id")
+
+	history, err := h.Store.GetEmployeeHolidayCalendars(r.Context(), entityID)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "Failed to get holiday calendars", err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, toEmployeeHolidayCalendarsDTO(entityID, history, time.Now().UTC()))
+}
+
+// AssignHolidayCalendar assigns a holiday calendar to an employee from a date.
+// POST /api/employees/{id}/holiday-calendars
+func (h *Handler) AssignHolidayCalendar(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	entityID := chi.URLParam(r, "id")
+
+	var req AssignHolidayCalendarRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "Invalid request body", err)
+		return
+	}
+
+	effectiveFrom := generic.Today().Time
+	if req.EffectiveFrom != "" {
+		t, err := time.Parse("2006-01-02", req.EffectiveFrom)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, "Invalid effective_from format (use YYYY-MM-DD)", err)
+			return
+		}
+		effectiveFrom = t
+	}
+
+	emp, err := h.Store.GetEmployee(ctx, entityID)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "Failed to get employee", err)
+		return
+	}
+	if emp == nil {
+		writeError(w, http.StatusNotFound, "Employee not found", nil)
+		return
+	}
+
+	calendar, err := h.Store.GetHolidayCalendar(ctx, req.CalendarID)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "Failed to get holiday calendar", err)
+		return
+	}
+	if calendar == nil {
+		writeError(w, http.StatusBadRequest, fmt.Sprintf("Unknown holiday calendar: %s", req.CalendarID), nil)
+		return
+	}
+
+	assignment := sqlite.EmployeeHolidayCalendar{
+		ID:            fmt.Sprintf("holcal-%s-%s", entityID, effectiveFrom.Format("2006-01-02")),
+		EntityID:      entityID,
+		CalendarID:    calendar.ID,
+		EffectiveFrom: effectiveFrom,
+	}
+	if err := h.Store.AssignHolidayCalendar(ctx, assignment); err != nil {
+		writeError(w, http.StatusInternalServerError, "Failed to assign holiday calendar", err)
+		return
+	}
+
+	history, err := h.Store.GetEmployeeHolidayCalendars(ctx, entityID)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "Failed to get holiday calendars", err)
+		return
+	}
+	writeJSON(w, http.StatusCreated, toEmployeeHolidayCalendarsDTO(entityID, history, time.Now().UTC()))
+}
+
+func toEmployeeHolidayCalendarsDTO(entityID string, history []sqlite.EmployeeHolidayCalendar, now time.Time) EmployeeHolidayCalendarsDTO {
+	dto := EmployeeHolidayCalendarsDTO{
+		EntityID: entityID,
+		History:  make([]EmployeeHolidayCalendarDTO, len(history)),
+	}
+	for i, a := range history {
+		dto.History[i] = EmployeeHolidayCalendarDTO{
+			CalendarID:    a.CalendarID,
+			EffectiveFrom: a.EffectiveFrom.Format("2006-01-02"),
+		}
+		if !a.EffectiveFrom.After(now) {
+			dto.Current = a.CalendarID
+		}
+	}
+	return dto
+}
+
+// GetEmployeeHolidays returns the holidays that apply to an employee in a
+// year, each taken from the calendar assigned on its date.
+// GET /api/employees/{id}/holidays?year=2025
+func (h *Handler) GetEmployeeHolidays(w http.ResponseWriter, r *http.Request) {
+	entityID := generic.EntityID(chi.URLParam(r, "id"))
+
+	year := time.Now().Year()
+	if v := r.URL.Query().Get("year"); v != "" {
+		y, err := strconv.Atoi(v)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, "Invalid year", err)
+			return
+		}
+		year = y
+	}
+
+	holidays := h.Store.GetEntityHolidays(r.Context(), entityID, year)
+	dtos := make([]EmployeeHolidayDTO, len(holidays))
+	for i, hol := range holidays {
+		dtos[i] = EmployeeHolidayDTO{
+			Date:       hol.Date.Time.Format("2006-01-02"),
+			Name:       hol.Name,
+			CalendarID: hol.CalendarID,
+			Recurring:  hol.Recurring,
+		}
+	}
+	writeJSON(w, http.StatusOK, map[string]any{"holidays": dtos})
+}
@@ -0,0 +1,111 @@
+/*
+holiday_calendars_test.go - Unit tests for holiday calendars
+
+Tests for:
+- Holiday calendars per employee (AssignHolidayCalendar, SubmitRequest)
+*/
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/warp/resource-engine/store/sqlite"
+	"github.com/warp/resource-engine/timeoff"
+)
+
+func TestHolidayCalendars_RelocationResolvesCalendarPerDate(t *testing.T) {
+	// GIVEN: US and UK calendars, a company-wide holiday, and an employee
+	//        on the US calendar who relocates to the UK on July 1
+	// WHEN: Listing her 2025 holidays and requesting Dec 24 + Dec 26 off
+	// THEN: She gets the company-wide holiday and Boxing Day but not
+	//       July 4, and only Dec 24 is deducted
+
+	handler := setupTestHandler(t)
+	router := NewRouter(handler)
+	ctx := context.Background()
+
+	policyJSON := timeoff.StandardPTOJSON("pto-test", "Test PTO", 24, 5)
+	if err := handler.createPolicyFromJSON(ctx, policyJSON); err != nil {
+		t.Fatalf("Failed to create policy: %v", err)
+	}
+	hireDate := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	if err := handler.Store.SaveEmployee(ctx, sqlite.Employee{ID: "alice", Name: "Alice", HireDate: hireDate}); err != nil {
+		t.Fatalf("Failed to create employee: %v", err)
+	}
+	if err := handler.Store.SaveAssignment(ctx, sqlite.AssignmentRecord{
+		ID: "assign-alice", EntityID: "alice", PolicyID: "pto-test",
+		EffectiveFrom: hireDate, ConsumptionPriority: 1,
+	}); err != nil {
+		t.Fatalf("Failed to save assignment: %v", err)
+	}
+
+	post := func(path, body string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, path, strings.NewReader(body)))
+		return rec
+	}
+
+	for _, body := range []string{
+		`{"id":"us","company_id":"acme","name":"United States"}`,
+		`{"id":"uk","company_id":"acme","name":"United Kingdom"}`,
+	} {
+		if rec := post("/api/holiday-calendars", body); rec.Code != http.StatusCreated {
+			t.Fatalf("Expected 201 creating calendar, got %d: %s", rec.Code, rec.Body.String())
+		}
+	}
+	for _, body := range []string{
+		`{"calendar_id":"us","date":"2025-07-04","name":"Independence Day"}`,
+		`{"calendar_id":"uk","date":"2025-12-26","name":"Boxing Day"}`,
+		`{"company_id":"acme","date":"2025-03-03","name":"Founders Day"}`,
+	} {
+		if rec := post("/api/holidays", body); rec.Code != http.StatusCreated {
+			t.Fatalf("Expected 201 creating holiday, got %d: %s", rec.Code, rec.Body.String())
+		}
+	}
+	if rec := post("/api/holidays", `{"calendar_id":"nope","date":"2025-01-02","name":"X"}`); rec.Code != http.StatusBadRequest {
+		t.Errorf("Expected 400 for unknown calendar, got %d", rec.Code)
+	}
+
+	for _, body := range []string{
+		`{"calendar_id":"us","effective_from":"2025-01-01"}`,
+		`{"calendar_id":"uk","effective_from":"2025-07-01"}`,
+	} {
+		if rec := post("/api/employees/alice/holiday-calendars", body); rec.Code != http.StatusCreated {
+			t.Fatalf("Expected 201 assigning calendar, got %d: %s", rec.Code, rec.Body.String())
+		}
+	}
+
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/employees/alice/holidays?year=2025", nil))
+	var resp struct {
+		Holidays []EmployeeHolidayDTO `json:"holidays"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("Failed to decode holidays: %v", err)
+	}
+	var names []string
+	for _, hol := range resp.Holidays {
+		names = append(names, hol.Name)
+	}
+	if got := strings.Join(names, ","); got != "Founders Day,Boxing Day" {
+		t.Errorf("Expected Founders Day,Boxing Day, got %s", got)
+	}
+
+	rec = post("/api/employees/alice/requests", `{"resource_type":"pto","days":["2025-12-24","2025-12-26"]}`)
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("Expected 201, got %d: %s", rec.Code, rec.Body.String())
+	}
+	var submitted TimeOffResponseDTO
+	if err := json.Unmarshal(rec.Body.Bytes(), &submitted); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if submitted.TotalDays != 1 {
+		t.Errorf("Expected Boxing Day to be skipped (1 day), got %.1f", submitted.TotalDays)
+	}
+}
@@ -0,0 +1,216 @@
+/*
+idempotency.go - Idempotency-Key header for mutating requests
+
+PURPOSE:
+  A client that times out cannot tell whether its request was applied.
+  Sending the same Idempotency-Key header on the retry makes it safe: the
+  first response is stored and replayed, so a retried submission never
+  books the same time off twice.
+
+BEHAVIOR (POST, PUT, PATCH, DELETE with the header):
+  First use                 Run the handler, store status and body
+  Same key, same request    Replay the stored response
+                            (Idempotent-Replayed: true)
+  Same key, other request   409 Conflict (different method, path or body)
+  Same key, still running   409 Conflict with Retry-After
+  5xx response              Not stored; the key can be retried
+  Body over the limit       413 (maxIdempotentBodyBytes, the largest
+                            body any route accepts)
+  Without the header, requests run as before.
+
+SCOPE:
+  Keys belong to a tenant and a caller (the authenticated principal), so
+  two clients cannot replay each other's responses. Keys expire after
+  idempotencyTTL.
+
+LEDGER:
+  SubmitRequest also derives its request and transaction IDs from the key
+  (idempotentID), so even a retry that misses the stored response collides
+  on Transaction.IdempotencyKey instead of double-booking.
+
+SEE ALSO:
+  - store/sqlite/sqlite.go: idempotency_keys table
+  - server.go: middleware order
+*/
+package api
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/warp/resource-engine/store/sqlite"
+)
+
+// IdempotencyHeader is the request header carrying the client's key.
+const IdempotencyHeader = "Idempotency-Key"
+
+// idempotencyTTL is how long a key and its response are kept.
+const idempotencyTTL = 24 * time.Hour
+
+// maxIdempotencyKeyLength bounds the header value.
+const maxIdempotencyKeyLength = 255
+
+// maxIdempotentBodyBytes bounds the body buffered for fingerprinting. It
+// is the largest body any route accepts (a holiday file); smaller route
+// limits still apply when the handler reads the buffered body.
+const maxIdempotentBodyBytes = MaxHolidayImportBytes
+
+type idempotencyKey struct{}
+
+// Idempotency stores and replays responses of mutating requests that
+// carry an Idempotency-Key header.
+func (h *Handler) Idempotency(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		key := r.Header.Get(IdempotencyHeader)
+		if key == "" || !isMutating(r.Method) {
+			next.ServeHTTP(w, r)
+			return
+		}
+		if len(key) > maxIdempotencyKeyLength {
+			writeError(w, http.StatusBadRequest, "Idempotency-Key is too long", nil)
+			return
+		}
+
+		body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxIdempotentBodyBytes))
+		if err != nil {
+			var tooLarge *http.MaxBytesError
+			if errors.As(err, &tooLarge) {
+				writeError(w, http.StatusRequestEntityTooLarge, "Request body too large", err)
+				return
+			}
+			writeError(w, http.StatusBadRequest, "Failed to read request body", err)
+			return
+		}
+		r.Body = io.NopCloser(bytes.NewReader(body))
+
+		ctx := r.Context()
+		rec := sqlite.IdempotencyRecord{
+			Actor:       idempotencyActor(ctx),
+			Key:         key,
+			Method:      r.Method,
+			Path:        r.URL.RequestURI(),
+			RequestHash: requestFingerprint(r.Method, r.URL.RequestURI(), body),
+		}
+
+		existing, err := h.Store.ReserveIdempotencyKey(ctx, rec, idempotencyTTL)
+		if err != nil {
+			writeError(w, http.StatusInternalServerError, "Failed to check Idempotency-Key", err)
+			return
+		}
+		if existing != nil {
+			replayIdempotent(w, rec, existing)
+			return
+		}
+
+		recorder := &responseRecorder{ResponseWriter: w, status: http.StatusOK}
+		defer func() {
+			// Record the outcome even if the client has gone away
+			saveCtx := context.WithoutCancel(ctx)
+
+			// A panic or server error leaves the key free for a retry
+			if p := recover(); p != nil {
+				h.Store.ReleaseIdempotencyKey(saveCtx, rec.Actor, rec.Key)
+				panic(p)
+			}
+			if recorder.status >= 500 {
+				h.Store.ReleaseIdempotencyKey(saveCtx, rec.Actor, rec.Key)
+				return
+			}
+			rec.StatusCode = recorder.status
+			rec.ContentType = recorder.Header().Get("Content-Type")
+			rec.Body = recorder.body.Bytes()
+			if err := h.Store.CompleteIdempotencyKey(saveCtx, rec); err != nil {
+				log.Printf("Failed to store response for Idempotency-Key %q: %v", rec.Key, err)
+			}
+		}()
+
+		next.ServeHTTP(recorder, r.WithContext(context.WithValue(ctx, idempotencyKey{}, key)))
+	})
+}
+
+func replayIdempotent(w http.ResponseWriter, rec sqlite.IdempotencyRecord, existing *sqlite.IdempotencyRecord) {
+	if existing.RequestHash != rec.RequestHash {
+		writeError(w, http.StatusConflict, "Idempotency-Key was already used for a different request", nil)
+		return
+	}
+	if existing.CompletedAt == nil {
+		w.Header().Set("Retry-After", "1")
+		writeError(w, http.StatusConflict, "A request with this Idempotency-Key is still in progress", nil)
+		return
+	}
+
+	if existing.ContentType != "" {
+		w.Header().Set("Content-Type", existing.ContentType)
+	}
+	w.Header().Set("Idempotent-Replayed", "true")
+	w.WriteHeader(existing.StatusCode)
+	w.Write(existing.Body)
+}
+
+// idempotentID returns an ID derived from the request's Idempotency-Key
+// (and caller), or fallback when the request has none. The same retried
+// request always gets the same ID.
+func idempotentID(ctx context.Context, prefix, fallback string) string {
+	key, _ := ctx.Value(idempotencyKey{}).(string)
+	if key == "" {
+		return fallback
+	}
+	sum := sha256.Sum256([]byte(idempotencyActor(ctx) + "\x00" + key))
+	return prefix + hex.EncodeToString(sum[:10])
+}
+
+// idempotencyActor scopes keys to the authenticated caller.
+func idempotencyActor(ctx context.Context) string {
+	if p := principalFrom(ctx); p != nil {
+		return p.Type + ":" + p.ID
+	}
+	return ""
+}
+
+func requestFingerprint(method, uri string, body []byte) string {
+	h := sha256.New()
+	h.Write([]byte(method + " " + uri + "\n"))
+	h.Write(body)
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+func isMutating(method string) bool {
+	switch method {
+	case http.MethodPost, http.MethodPut, http.MethodPatch, http.MethodDelete:
+		return true
+	}
+	return false
+}
+
+// responseRecorder passes a response through while keeping a copy.
+type responseRecorder struct {
+	http.ResponseWriter
+	status      int
+	body        bytes.Buffer
+	wroteHeader bool
+}
+
+func (r *responseRecorder) WriteHeader(status int) {
+	if !r.wroteHeader {
+		r.status = status
+		r.wroteHeader = true
+	}
+	r.ResponseWriter.WriteHeader(status)
+}
+
+func (r *responseRecorder) Write(b []byte) (int, error) {
+	r.wroteHeader = true
+	r.body.Write(b)
+	return r.ResponseWriter.Write(b)
+}
+
+func (r *responseRecorder) Unwrap() http.ResponseWriter {
+	return r.ResponseWriter
+}
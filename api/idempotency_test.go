@@ -0,0 +1,157 @@
+/*
+idempotency_test.go - Unit tests for Idempotency-Key replay
+
+Tests for:
+- Idempotency-Key replay (Idempotency, idempotentID)
+*/
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/warp/resource-engine/generic"
+	"github.com/warp/resource-engine/store/sqlite"
+	"github.com/warp/resource-engine/timeoff"
+)
+
+func TestIdempotency_ReplaysResponsesAndRejectsReusedKeys(t *testing.T) {
+	// GIVEN: An employee with PTO
+	// WHEN: A submission is retried with the same Idempotency-Key, the key
+	//       is reused for another payload, a cancellation is retried, and
+	//       an oversized body is sent
+	// THEN: Retries replay the first response without new transactions,
+	//       a reused key is a conflict, an oversized body is a 413, and
+	//       keys are scoped per caller
+
+	handler := setupTestHandler(t)
+	router := NewRouter(handler)
+	ctx := context.Background()
+
+	if err := handler.createPolicyFromJSON(ctx, timeoff.StandardPTOJSON("pto-test", "Test PTO", 24, 5)); err != nil {
+		t.Fatalf("Failed to create policy: %v", err)
+	}
+	hireDate := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	if err := handler.Store.SaveEmployee(ctx, sqlite.Employee{ID: "emp-idem", Name: "Retry User", HireDate: hireDate}); err != nil {
+		t.Fatalf("Failed to create employee: %v", err)
+	}
+	if err := handler.Store.SaveAssignment(ctx, sqlite.AssignmentRecord{
+		ID: "assign-idem", EntityID: "emp-idem", PolicyID: "pto-test",
+		EffectiveFrom: hireDate, ConsumptionPriority: 1,
+	}); err != nil {
+		t.Fatalf("Failed to save assignment: %v", err)
+	}
+
+	do := func(method, path, key, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		if key != "" {
+			req.Header.Set(IdempotencyHeader, key)
+		}
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, req)
+		return rec
+	}
+	countTxs := func() int {
+		txs, err := handler.Store.GetAllTransactions(ctx, 100)
+		if err != nil {
+			t.Fatalf("Failed to load transactions: %v", err)
+		}
+		return len(txs)
+	}
+
+	submit := `{"resource_type":"pto","days":["2025-06-02","2025-06-03"]}`
+	first := do(http.MethodPost, "/api/employees/emp-idem/requests", "retry-1", submit)
+	if first.Code != http.StatusCreated {
+		t.Fatalf("Expected 201, got %d: %s", first.Code, first.Body.String())
+	}
+	if countTxs() != 2 {
+		t.Fatalf("Expected 2 transactions, got %d", countTxs())
+	}
+
+	retry := do(http.MethodPost, "/api/employees/emp-idem/requests", "retry-1", submit)
+	if retry.Code != http.StatusCreated || retry.Body.String() != first.Body.String() {
+		t.Errorf("Expected replayed 201 %s, got %d %s", first.Body.String(), retry.Code, retry.Body.String())
+	}
+	if retry.Header().Get("Idempotent-Replayed") != "true" {
+		t.Error("Expected Idempotent-Replayed header on replay")
+	}
+	if retry.Header().Get("Content-Type") != "application/json" {
+		t.Errorf("Expected replayed content type, got %q", retry.Header().Get("Content-Type"))
+	}
+	if countTxs() != 2 {
+		t.Errorf("Retry must not append transactions, got %d", countTxs())
+	}
+
+	other := do(http.MethodPost, "/api/employees/emp-idem/requests", "retry-1", `{"resource_type":"pto","days":["2025-07-01"]}`)
+	if other.Code != http.StatusConflict {
+		t.Errorf("Expected 409 for a reused key, got %d: %s", other.Code, other.Body.String())
+	}
+
+	// A lost response: the stored outcome is gone, but the derived request
+	// ID makes the ledger reject the duplicate
+	if err := handler.Store.ReleaseIdempotencyKey(ctx, "", "retry-1"); err != nil {
+		t.Fatalf("Failed to release key: %v", err)
+	}
+	if rec := do(http.MethodPost, "/api/employees/emp-idem/requests", "retry-1", submit); rec.Code != http.StatusConflict {
+		t.Errorf("Expected 409 from the ledger, got %d: %s", rec.Code, rec.Body.String())
+	}
+	if countTxs() != 2 {
+		t.Errorf("Expected still 2 transactions, got %d", countTxs())
+	}
+
+	// DELETE is covered too, including replay of the first outcome
+	var submitted TimeOffResponseDTO
+	json.Unmarshal(first.Body.Bytes(), &submitted)
+	txID := submitted.RequestID + "-0-0"
+	cancel := do(http.MethodDelete, "/api/transactions/"+txID, "cancel-1", "")
+	if cancel.Code != http.StatusOK {
+		t.Fatalf("Expected 200 on cancel, got %d: %s", cancel.Code, cancel.Body.String())
+	}
+	if rec := do(http.MethodDelete, "/api/transactions/"+txID, "cancel-1", ""); rec.Code != http.StatusOK || rec.Body.String() != cancel.Body.String() {
+		t.Errorf("Expected replayed cancel, got %d: %s", rec.Code, rec.Body.String())
+	}
+	if rec := do(http.MethodDelete, "/api/transactions/"+txID, "", ""); rec.Code != http.StatusConflict {
+		t.Errorf("Expected a plain retry to hit the already-cancelled check, got %d", rec.Code)
+	}
+
+	// A request still in progress
+	if _, err := handler.Store.ReserveIdempotencyKey(ctx, sqlite.IdempotencyRecord{
+		Key: "busy", Method: http.MethodPost, Path: "/api/admin/adjustments",
+		RequestHash: requestFingerprint(http.MethodPost, "/api/admin/adjustments", []byte(`{}`)),
+	}, time.Hour); err != nil {
+		t.Fatalf("Failed to reserve key: %v", err)
+	}
+	if rec := do(http.MethodPost, "/api/admin/adjustments", "busy", `{}`); rec.Code != http.StatusConflict || rec.Header().Get("Retry-After") == "" {
+		t.Errorf("Expected 409 with Retry-After while in progress, got %d", rec.Code)
+	}
+
+	// The buffered body is bounded
+	huge := `{"resource_type":"pto","reason":"` + strings.Repeat("x", maxIdempotentBodyBytes) + `"}`
+	if rec := do(http.MethodPost, "/api/employees/emp-idem/requests", "huge-1", huge); rec.Code != http.StatusRequestEntityTooLarge {
+		t.Errorf("Expected 413 for an oversized body, got %d", rec.Code)
+	}
+
+	// Keys belong to the caller
+	tokens := NewStaticTokens()
+	tokens.Add("admin-a", Principal{Actor: generic.Actor{ID: "a", Type: generic.ActorAdmin}, AllTenants: true})
+	tokens.Add("admin-b", Principal{Actor: generic.Actor{ID: "b", Type: generic.ActorAdmin}, AllTenants: true})
+	handler.Auth = tokens
+	holiday := func(token, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/api/holidays", strings.NewReader(body))
+		req.Header.Set("Authorization", "Bearer "+token)
+		req.Header.Set(IdempotencyHeader, "holiday-1")
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, req)
+		return rec
+	}
+	a := holiday("admin-a", `{"date":"2025-12-24","name":"Christmas Eve"}`)
+	b := holiday("admin-b", `{"date":"2025-12-31","name":"New Year's Eve"}`)
+	if a.Code != http.StatusCreated || b.Code != http.StatusCreated || a.Body.String() == b.Body.String() {
+		t.Errorf("Expected independent keys per caller, got %d %s / %d %s", a.Code, a.Body.String(), b.Code, b.Body.String())
+	}
+}
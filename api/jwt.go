@@ -0,0 +1,240 @@
+/*
+jwt.go - JWT bearer tokens verified with a local key
+
+PURPOSE:
+  Lets an identity provider (or a script holding the key) issue
+  short-lived tokens without the server keeping a token list. Tokens are
+  verified locally; there is no key discovery or network call.
+
+ALGORITHMS:
+  HS256   Shared secret          (NewHMACVerifier)
+  RS256   RSA public key (PEM)   (NewPublicKeyVerifier)
+  ES256   P-256 public key (PEM) (NewPublicKeyVerifier)
+  The algorithm must match the key: a verifier holding a public key never
+  accepts HS256 tokens (so the public key cannot be used as an HMAC
+  secret), and "none" is never accepted.
+
+CLAIMS:
+  sub      Actor ID (employee ID for employees and managers)   required
+  role     employee, manager, admin or system                   required
+  exp      Expiry (Unix seconds)                                required
+  nbf      Not before (Unix seconds)                            optional
+  tenant   Tenant the token is bound to ("*" = all tenants)     optional
+  iss/aud  Checked when the verifier sets Issuer/Audience
+
+  Example payload:
+  {"sub":"alice","role":"employee","tenant":"acme","exp":1767225600}
+
+SEE ALSO:
+  - auth.go: Authenticator, roles and tenant binding
+*/
+package api
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/hmac"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"math/big"
+	"strings"
+	"time"
+)
+
+// JWTVerifier authenticates JWT bearer tokens signed with a local key.
+type JWTVerifier struct {
+	Issuer   string        // Required "iss" claim, if set
+	Audience string        // Required "aud" entry, if set
+	Leeway   time.Duration // Clock skew allowed on exp and nbf
+
+	secret    []byte
+	publicKey crypto.PublicKey
+	now       func() time.Time
+}
+
+// NewHMACVerifier returns a verifier for HS256 tokens.
+func NewHMACVerifier(secret []byte) *JWTVerifier {
+	return &JWTVerifier{secret: secret, now: time.Now}
+}
+
+// NewPublicKeyVerifier returns a verifier for RS256 or ES256 tokens,
+// from a PEM public key or certificate.
+func NewPublicKeyVerifier(pemBytes []byte) (*JWTVerifier, error) {
+	block, _ := pem.Decode(pemBytes)
+	if block == nil {
+		return nil, errors.New("no PEM block found")
+	}
+
+	var key crypto.PublicKey
+	switch block.Type {
+	case "CERTIFICATE":
+		cert, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			return nil, err
+		}
+		key = cert.PublicKey
+	default:
+		parsed, err := x509.ParsePKIXPublicKey(block.Bytes)
+		if err != nil {
+			return nil, err
+		}
+		key = parsed
+	}
+
+	switch k := key.(type) {
+	case *rsa.PublicKey:
+	case *ecdsa.PublicKey:
+		if k.Curve != elliptic.P256() {
+			return nil, errors.New("ECDSA key must use P-256 (ES256)")
+		}
+	default:
+		return nil, fmt.Errorf("unsupported public key type %T", key)
+	}
+	return &JWTVerifier{publicKey: key, now: time.Now}, nil
+}
+
+type jwtHeader struct {
+	Alg string `json:"alg"`
+}
+
+type jwtClaims struct {
+	Subject   string      `json:"sub"`
+	Role      string      `json:"role"`
+	Tenant    *string     `json:"tenant"`
+	Issuer    string      `json:"iss"`
+	Audience  jwtAudience `json:"aud"`
+	ExpiresAt *int64      `json:"exp"`
+	NotBefore *int64      `json:"nbf"`
+}
+
+// jwtAudience accepts "aud" as a string or an array of strings.
+type jwtAudience []string
+
+func (a *jwtAudience) UnmarshalJSON(data []byte) error {
+	var single string
+	if err := json.Unmarshal(data, &single); err == nil {
+		*a = jwtAudience{single}
+		return nil
+	}
+	var many []string
+	if err := json.Unmarshal(data, &many); err != nil {
+		return err
+	}
+	*a = many
+	return nil
+}
+
+// Authenticate implements Authenticator.
+func (v *JWTVerifier) Authenticate(token string) (*Principal, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return nil, ErrInvalidCredentials
+	}
+
+	var header jwtHeader
+	if err := decodeJWTPart(parts[0], &header); err != nil {
+		return nil, fmt.Errorf("%w: bad header", ErrInvalidCredentials)
+	}
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return nil, fmt.Errorf("%w: bad signature encoding", ErrInvalidCredentials)
+	}
+	if err := v.verifySignature(header.Alg, parts[0]+"."+parts[1], signature); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidCredentials, err)
+	}
+
+	var claims jwtClaims
+	if err := decodeJWTPart(parts[1], &claims); err != nil {
+		return nil, fmt.Errorf("%w: bad claims", ErrInvalidCredentials)
+	}
+	if err := v.checkClaims(claims); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidCredentials, err)
+	}
+
+	tenant := ""
+	if claims.Tenant != nil {
+		tenant = *claims.Tenant
+	}
+	p, err := newPrincipal(claims.Subject, claims.Role, tenant, claims.Tenant != nil)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidCredentials, err)
+	}
+	return &p, nil
+}
+
+func (v *JWTVerifier) verifySignature(alg, signed string, signature []byte) error {
+	digest := sha256.Sum256([]byte(signed))
+
+	switch key := v.publicKey.(type) {
+	case nil:
+		if alg != "HS256" {
+			return fmt.Errorf("unexpected alg %q", alg)
+		}
+		mac := hmac.New(sha256.New, v.secret)
+		mac.Write([]byte(signed))
+		if !hmac.Equal(signature, mac.Sum(nil)) {
+			return errors.New("signature mismatch")
+		}
+	case *rsa.PublicKey:
+		if alg != "RS256" {
+			return fmt.Errorf("unexpected alg %q", alg)
+		}
+		if err := rsa.VerifyPKCS1v15(key, crypto.SHA256, digest[:], signature); err != nil {
+			return errors.New("signature mismatch")
+		}
+	case *ecdsa.PublicKey:
+		// JWS encodes ES256 signatures as r||s, 32 bytes each
+		if alg != "ES256" || len(signature) != 64 {
+			return fmt.Errorf("unexpected alg %q", alg)
+		}
+		r := new(big.Int).SetBytes(signature[:32])
+		s := new(big.Int).SetBytes(signature[32:])
+		if !ecdsa.Verify(key, digest[:], r, s) {
+			return errors.New("signature mismatch")
+		}
+	}
+	return nil
+}
+
+func (v *JWTVerifier) checkClaims(c jwtClaims) error {
+	now := v.now()
+	if c.ExpiresAt == nil {
+		return errors.New("missing exp")
+	}
+	if now.After(time.Unix(*c.ExpiresAt, 0).Add(v.Leeway)) {
+		return errors.New("token expired")
+	}
+	if c.NotBefore != nil && now.Add(v.Leeway).Before(time.Unix(*c.NotBefore, 0)) {
+		return errors.New("token not yet valid")
+	}
+	if v.Issuer != "" && c.Issuer != v.Issuer {
+		return errors.New("wrong issuer")
+	}
+	if v.Audience != "" {
+		found := false
+		for _, aud := range c.Audience {
+			if aud == v.Audience {
+				found = true
+			}
+		}
+		if !found {
+			return errors.New("wrong audience")
+		}
+	}
+	return nil
+}
+
+func decodeJWTPart(part string, v any) error {
+	data, err := base64.RawURLEncoding.DecodeString(part)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, v)
+}
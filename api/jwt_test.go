@@ -0,0 +1,148 @@
+/*
+jwt_test.go - Unit tests for JWT verification
+
+Tests for:
+- JWT verification (HMAC, RSA, ECDSA) and tenant binding
+*/
+package api
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/warp/resource-engine/generic"
+	"github.com/warp/resource-engine/store/sqlite"
+)
+
+// signTestJWT builds a JWT signed with an HMAC secret, RSA or ECDSA key.
+func signTestJWT(t *testing.T, alg string, key any, claims map[string]any) string {
+	t.Helper()
+	header, _ := json.Marshal(map[string]string{"alg": alg, "typ": "JWT"})
+	payload, _ := json.Marshal(claims)
+	signed := base64.RawURLEncoding.EncodeToString(header) + "." + base64.RawURLEncoding.EncodeToString(payload)
+	digest := sha256.Sum256([]byte(signed))
+
+	var sig []byte
+	switch k := key.(type) {
+	case []byte:
+		mac := hmac.New(sha256.New, k)
+		mac.Write([]byte(signed))
+		sig = mac.Sum(nil)
+	case *rsa.PrivateKey:
+		var err error
+		if sig, err = rsa.SignPKCS1v15(rand.Reader, k, crypto.SHA256, digest[:]); err != nil {
+			t.Fatalf("Failed to sign: %v", err)
+		}
+	case *ecdsa.PrivateKey:
+		r, s, err := ecdsa.Sign(rand.Reader, k, digest[:])
+		if err != nil {
+			t.Fatalf("Failed to sign: %v", err)
+		}
+		sig = append(r.FillBytes(make([]byte, 32)), s.FillBytes(make([]byte, 32))...)
+	case nil:
+	}
+	return signed + "." + base64.RawURLEncoding.EncodeToString(sig)
+}
+
+func TestAuth_JWTVerificationAndTenantBinding(t *testing.T) {
+	// GIVEN: An HS256 verifier, tenants acme and globex, and RSA/ECDSA
+	//        public-key verifiers
+	// WHEN: Presenting valid, expired, tampered and mis-signed tokens
+	// THEN: Only valid tokens authenticate, and a token bound to acme
+	//       resolves acme and cannot use globex
+
+	secret := []byte("0123456789abcdef0123456789abcdef")
+	handler := setupTestHandler(t)
+	handler.Auth = Authenticators{NewHMACVerifier(secret)}
+	router := NewRouter(handler)
+
+	exp := time.Now().Add(time.Hour).Unix()
+	adminToken := signTestJWT(t, "HS256", secret, map[string]any{"sub": "ops", "role": "admin", "exp": exp})
+	aliceToken := signTestJWT(t, "HS256", secret, map[string]any{"sub": "alice", "role": "employee", "tenant": "acme", "exp": exp})
+
+	do := func(method, path, token, tenant, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		req.Header.Set("Authorization", "Bearer "+token)
+		if tenant != "" {
+			req.Header.Set(TenantHeader, tenant)
+		}
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, req)
+		return rec
+	}
+
+	for _, id := range []string{"acme", "globex"} {
+		if rec := do(http.MethodPost, "/api/tenants", adminToken, "", `{"id":"`+id+`"}`); rec.Code != http.StatusCreated {
+			t.Fatalf("Expected 201 creating tenant, got %d: %s", rec.Code, rec.Body.String())
+		}
+	}
+	acme := generic.WithTenant(context.Background(), "acme")
+	if err := handler.Store.SaveEmployee(acme, sqlite.Employee{ID: "alice", Name: "Alice", HireDate: time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)}); err != nil {
+		t.Fatalf("Failed to create employee: %v", err)
+	}
+
+	// No header: the token's tenant
+	if rec := do(http.MethodGet, "/api/employees/alice", aliceToken, "", ""); rec.Code != http.StatusOK {
+		t.Errorf("Expected alice's token to resolve acme, got %d: %s", rec.Code, rec.Body.String())
+	}
+	if rec := do(http.MethodGet, "/api/employees", aliceToken, "globex", ""); rec.Code != http.StatusForbidden {
+		t.Errorf("Expected 403 using an acme token for globex, got %d", rec.Code)
+	}
+
+	rejected := map[string]string{
+		"expired":     signTestJWT(t, "HS256", secret, map[string]any{"sub": "ops", "role": "admin", "exp": time.Now().Add(-time.Hour).Unix()}),
+		"no exp":      signTestJWT(t, "HS256", secret, map[string]any{"sub": "ops", "role": "admin"}),
+		"wrong key":   signTestJWT(t, "HS256", []byte("another-secret-another-secret-xx"), map[string]any{"sub": "ops", "role": "admin", "exp": exp}),
+		"alg none":    signTestJWT(t, "none", nil, map[string]any{"sub": "ops", "role": "admin", "exp": exp}),
+		"bad role":    signTestJWT(t, "HS256", secret, map[string]any{"sub": "ops", "role": "root", "exp": exp}),
+		"all tenants": signTestJWT(t, "HS256", secret, map[string]any{"sub": "alice", "role": "employee", "tenant": "*", "exp": exp}),
+		"tampered":    aliceToken[:strings.LastIndex(aliceToken, ".")] + "x" + aliceToken[strings.LastIndex(aliceToken, "."):],
+	}
+	for name, token := range rejected {
+		if rec := do(http.MethodGet, "/api/policies", token, "", ""); rec.Code != http.StatusUnauthorized {
+			t.Errorf("%s: expected 401, got %d", name, rec.Code)
+		}
+	}
+
+	// Public-key verifiers: RS256 and ES256, never HS256 with the PEM
+	rsaKey, _ := rsa.GenerateKey(rand.Reader, 2048)
+	ecKey, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	for alg, key := range map[string]any{"RS256": rsaKey, "ES256": ecKey} {
+		var public any = &rsaKey.PublicKey
+		if alg == "ES256" {
+			public = &ecKey.PublicKey
+		}
+		der, _ := x509.MarshalPKIXPublicKey(public)
+		pemBytes := pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der})
+		verifier, err := NewPublicKeyVerifier(pemBytes)
+		if err != nil {
+			t.Fatalf("%s: failed to load key: %v", alg, err)
+		}
+
+		p, err := verifier.Authenticate(signTestJWT(t, alg, key, map[string]any{"sub": "bob", "role": "manager", "exp": exp}))
+		if err != nil {
+			t.Fatalf("%s: expected valid token, got %v", alg, err)
+		}
+		if p.ID != "bob" || p.Type != generic.ActorManager || p.AllTenants || p.Tenant != generic.DefaultTenant {
+			t.Errorf("%s: unexpected principal %+v", alg, p)
+		}
+		if _, err := verifier.Authenticate(signTestJWT(t, "HS256", pemBytes, map[string]any{"sub": "ops", "role": "admin", "exp": exp})); err == nil {
+			t.Errorf("%s: accepted an HS256 token signed with the public key", alg)
+		}
+	}
+}
@@ -0,0 +1,445 @@
+/*
+liability.go - Pay rates and accrued leave liability reporting
+
+PURPOSE:
+  Finance needs to know what unused leave is worth. This file stores
+  effective-dated pay rates per employee and values every outstanding
+  balance at the rate in effect on the report date.
+
+KEY CONCEPTS:
+  Outstanding balance:
+    What the employee has EARNED and not used as of the report date:
+    AccruedToDate - Consumed + Adjustments (Balance.CurrentAccrued).
+    Pending requests and future bookings are not liabilities yet.
+
+  Reproducibility:
+    Balances come from ResourceBalanceCalculator in AsOfOnly mode, so only
+    transactions effective on or before as_of count. Assignments and pay
+    rates are effective-dated too, and pay rates are append-only: adding
+    one for an existing effective_from is a 409, so a correction takes
+    effect from a later date and past reports keep their values. A later
+    backdated ledger entry still changes a past report; as_known_at
+    leaves out entries recorded after it, reproducing the report as it
+    was run then.
+
+  Currencies:
+    Money is grouped by currency and never summed across currencies.
+    Employees without a rate still appear in the lines (value 0) and are
+    listed in missing_rates so the gap is visible.
+
+  Overdrawn balances:
+    A negative outstanding balance (ConsumeAhead beyond what was earned)
+    is money the employee owes back. Its line has value 0 and the amount
+    in receivable instead; group subtotals keep value and receivable
+    apart, so an overdraft never reduces the liability.
+
+  CSV:
+    format=csv writes one row per line. Adding group_by=policy, team or
+    currency writes that grouping's subtotals instead, one row per
+    (key, currency). JSON always carries all three groupings.
+
+ENDPOINTS:
+  GET  /api/employees/{id}/pay-rates    Pay rate history
+  POST /api/employees/{id}/pay-rates    Add a pay rate
+  GET  /api/reports/liability?as_of=2025-03-31&as_known_at=2025-04-05&format=csv&group_by=team
+
+SEE ALSO:
+  - generic/assignment.go: ResourceBalanceCalculator
+  - store/sqlite/sqlite.go: PayRate
+*/
+package api
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/shopspring/decimal"
+	"github.com/warp/resource-engine/generic"
+	"github.com/warp/resource-engine/store/record"
+	"github.com/warp/resource-engine/store/sqlite"
+)
+
+// =============================================================================
+// PAY RATE ENDPOINTS
+// =============================================================================
+
+// ListPayRates returns an employee's pay rate history.
+// GET /api/employees/{id}/pay-rates
+func (h *Handler) ListPayRates(w http.ResponseWriter, r *http.Request) {
+	entityID := chi.URLParam(r, "id")
+
+	rates, err := h.Store.GetPayRates(r.Context(), entityID)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "Failed to get pay rates", err)
+		return
+	}
+
+	dtos := make([]PayRateDTO, len(rates))
+	for i, rate := range rates {
+		dtos[i] = toPayRateDTO(rate)
+	}
+	writeJSON(w, http.StatusOK, dtos)
+}
+
+// CreatePayRate adds an effective-dated pay rate for an employee.
+// POST /api/employees/{id}/pay-rates
+func (h *Handler) CreatePayRate(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	entityID := chi.URLParam(r, "id")
+
+	var req CreatePayRateRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "Invalid request body", err)
+		return
+	}
+
+	rate, err := decimal.NewFromString(req.Rate)
+	if err != nil || rate.IsNegative() {
+		writeError(w, http.StatusBadRequest, "Invalid rate (use a non-negative decimal string)", err)
+		return
+	}
+	if len(req.Currency) != 3 {
+		writeError(w, http.StatusBadRequest, "Invalid currency (use ISO 4217 code)", nil)
+		return
+	}
+	effectiveFrom, err := time.Parse("2006-01-02", req.EffectiveFrom)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "Invalid effective_from format (use YYYY-MM-DD)", err)
+		return
+	}
+
+	unit := generic.Unit(req.Unit)
+	if unit == "" {
+		unit = generic.UnitDays
+	}
+	if unit != generic.UnitDays && unit != generic.UnitHours {
+		writeError(w, http.StatusBadRequest, "Invalid unit (use days or hours)", nil)
+		return
+	}
+
+	emp, err := h.Store.GetEmployee(ctx, entityID)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "Failed to get employee", err)
+		return
+	}
+	if emp == nil {
+		writeError(w, http.StatusNotFound, "Employee not found", nil)
+		return
+	}
+
+	payRate := sqlite.PayRate{
+		ID:            fmt.Sprintf("rate-%s-%s", entityID, req.EffectiveFrom),
+		EntityID:      entityID,
+		Rate:          rate,
+		Currency:      req.Currency,
+		Unit:          unit,
+		HoursPerDay:   req.HoursPerDay,
+		EffectiveFrom: effectiveFrom,
+	}
+	if err := h.Store.SavePayRate(ctx, payRate); err != nil {
+		if errors.Is(err, record.ErrPayRateExists) {
+			writeError(w, http.StatusConflict, "A pay rate already starts on this date; add a correction with a later effective_from", err)
+			return
+		}
+		writeError(w, http.StatusInternalServerError, "Failed to save pay rate", err)
+		return
+	}
+	if payRate.HoursPerDay == 0 {
+		payRate.HoursPerDay = 8
+	}
+
+	writeJSON(w, http.StatusCreated, toPayRateDTO(payRate))
+}
+
+func toPayRateDTO(r sqlite.PayRate) PayRateDTO {
+	return PayRateDTO{
+		ID:            r.ID,
+		EntityID:      r.EntityID,
+		Rate:          r.Rate.String(),
+		Currency:      r.Currency,
+		Unit:          string(r.Unit),
+		HoursPerDay:   r.HoursPerDay,
+		EffectiveFrom: r.EffectiveFrom.Format("2006-01-02"),
+	}
+}
+
+// =============================================================================
+// LIABILITY REPORT
+// =============================================================================
+
+// GetLiabilityReport values outstanding balances at each employee's pay rate.
+// GET /api/reports/liability?as_of=YYYY-MM-DD&as_known_at=&format=json|csv&group_by=policy|team|currency
+func (h *Handler) GetLiabilityReport(w http.ResponseWriter, r *http.Request) {
+	asOf := generic.Today()
+	if s := r.URL.Query().Get("as_of"); s != "" {
+		t, err := time.Parse("2006-01-02", s)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, "Invalid as_of format (use YYYY-MM-DD)", err)
+			return
+		}
+		asOf = generic.TimePoint{Time: t}
+	}
+	knownAt, ok := asKnownAt(w, r)
+	if !ok {
+		return
+	}
+	groupBy := r.URL.Query().Get("group_by")
+	switch groupBy {
+	case "", "policy", "team", "currency":
+	default:
+		writeError(w, http.StatusBadRequest, "Invalid group_by (use policy, team or currency)", nil)
+		return
+	}
+
+	report, err := h.buildLiabilityReport(r.Context(), asOf, knownAt)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "Failed to build liability report", err)
+		return
+	}
+
+	switch r.URL.Query().Get("format") {
+	case "", "json":
+		writeJSON(w, http.StatusOK, report)
+	case "csv":
+		writeLiabilityCSV(w, report, groupBy)
+	default:
+		writeError(w, http.StatusBadRequest, "Invalid format (use json or csv)", nil)
+	}
+}
+
+func (h *Handler) buildLiabilityReport(ctx context.Context, asOf generic.TimePoint, knownAt *time.Time) (*LiabilityReportDTO, error) {
+	employees, err := h.Store.ListEmployees(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	cache, err := h.cache(ctx)
+	if err != nil {
+		return nil, err
+	}
+	assignments := h.assignments(cache)
+	calculator := &generic.ResourceBalanceCalculator{
+		Ledger:          generic.NewLedger(h.Store).AsKnownAt(knownAt),
+		AssignmentStore: assignments,
+		Accruals:        cache.accruals,
+		AsOfOnly:        true,
+	}
+
+	report := &LiabilityReportDTO{
+		AsOf:         asOf.Time.Format("2006-01-02"),
+		AsKnownAt:    formatKnownAt(knownAt),
+		Lines:        []LiabilityLineDTO{},
+		MissingRates: []string{},
+	}
+	byPolicy := newLiabilityGroups()
+	byTeam := newLiabilityGroups()
+	byCurrency := newLiabilityGroups()
+
+	for _, emp := range employees {
+		active, err := assignments.GetActive(ctx, generic.EntityID(emp.ID), asOf)
+		if err != nil {
+			return nil, err
+		}
+
+		// One calculation per resource type, in a stable order
+		resourceTypes := make(map[string]generic.ResourceType)
+		for _, a := range active {
+			resourceTypes[a.Policy.ResourceType.ResourceID()] = a.Policy.ResourceType
+		}
+		rtIDs := make([]string, 0, len(resourceTypes))
+		for id := range resourceTypes {
+			rtIDs = append(rtIDs, id)
+		}
+		sort.Strings(rtIDs)
+
+		rate, err := h.Store.GetPayRateAt(ctx, emp.ID, asOf.Time)
+		if err != nil {
+			return nil, err
+		}
+		missingRate := false
+
+		for _, rtID := range rtIDs {
+			rb, err := calculator.Calculate(ctx, generic.EntityID(emp.ID), resourceTypes[rtID], asOf)
+			if err != nil {
+				return nil, err
+			}
+
+			for _, pb := range rb.PolicyBalances {
+				policy := pb.Assignment.Policy
+				// Only time-based, capped balances are a monetary liability
+				if policy.IsUnlimited || (policy.Unit != generic.UnitDays && policy.Unit != generic.UnitHours) {
+					continue
+				}
+				outstanding := pb.Balance.CurrentAccrued()
+				if outstanding.IsZero() {
+					continue
+				}
+
+				line := LiabilityLineDTO{
+					EntityID:     emp.ID,
+					EmployeeName: emp.Name,
+					Team:         emp.Team,
+					PolicyID:     string(policy.ID),
+					PolicyName:   policy.Name,
+					ResourceType: rtID,
+					Unit:         string(policy.Unit),
+					Outstanding:  roundFloat(outstanding.Value, 4),
+				}
+
+				if rate == nil {
+					missingRate = true
+					report.Lines = append(report.Lines, line)
+					continue
+				}
+
+				value := liabilityValue(outstanding, rate)
+				line.Rate = rate.Rate.String()
+				line.RateUnit = string(rate.Unit)
+				line.Currency = rate.Currency
+				if value.IsNegative() {
+					line.Receivable = roundFloat(value.Neg(), 2)
+				} else {
+					line.Value = roundFloat(value, 2)
+				}
+				report.Lines = append(report.Lines, line)
+
+				byPolicy.add(string(policy.ID), rate.Currency, value)
+				byTeam.add(emp.Team, rate.Currency, value)
+				byCurrency.add(rate.Currency, rate.Currency, value)
+			}
+		}
+
+		if missingRate {
+			report.MissingRates = append(report.MissingRates, emp.ID)
+		}
+	}
+
+	report.ByPolicy = byPolicy.list()
+	report.ByTeam = byTeam.list()
+	report.ByCurrency = byCurrency.list()
+	return report, nil
+}
+
+// liabilityValue converts an outstanding balance to money, bridging days and
+// hours with the rate's HoursPerDay.
+func liabilityValue(outstanding generic.Amount, rate *sqlite.PayRate) decimal.Decimal {
+	qty := outstanding.Value
+	hoursPerDay := decimal.NewFromFloat(rate.HoursPerDay)
+	if hoursPerDay.IsZero() {
+		hoursPerDay = decimal.NewFromInt(8)
+	}
+
+	switch {
+	case outstanding.Unit == generic.UnitDays && rate.Unit == generic.UnitHours:
+		qty = qty.Mul(hoursPerDay)
+	case outstanding.Unit == generic.UnitHours && rate.Unit == generic.UnitDays:
+		qty = qty.Div(hoursPerDay)
+	}
+	return qty.Mul(rate.Rate)
+}
+
+func roundFloat(d decimal.Decimal, places int32) float64 {
+	f, _ := d.Round(places).Float64()
+	return f
+}
+
+// liabilityGroups accumulates subtotals keyed by (group, currency).
+// Negative values go to the receivable total, not the liability.
+type liabilityGroups struct {
+	values      map[[2]string]decimal.Decimal
+	receivables map[[2]string]decimal.Decimal
+	lines       map[[2]string]int
+}
+
+func newLiabilityGroups() *liabilityGroups {
+	return &liabilityGroups{
+		values:      make(map[[2]string]decimal.Decimal),
+		receivables: make(map[[2]string]decimal.Decimal),
+		lines:       make(map[[2]string]int),
+	}
+}
+
+func (g *liabilityGroups) add(key, currency string, value decimal.Decimal) {
+	k := [2]string{key, currency}
+	if value.IsNegative() {
+		g.receivables[k] = g.receivables[k].Sub(value)
+	} else {
+		g.values[k] = g.values[k].Add(value)
+	}
+	g.lines[k]++
+}
+
+func (g *liabilityGroups) list() []LiabilityGroupDTO {
+	groups := make([]LiabilityGroupDTO, 0, len(g.lines))
+	for k, n := range g.lines {
+		groups = append(groups, LiabilityGroupDTO{
+			Key:        k[0],
+			Currency:   k[1],
+			Value:      roundFloat(g.values[k], 2),
+			Receivable: roundFloat(g.receivables[k], 2),
+			Lines:      n,
+		})
+	}
+	sort.Slice(groups, func(i, j int) bool {
+		if groups[i].Key != groups[j].Key {
+			return groups[i].Key < groups[j].Key
+		}
+		return groups[i].Currency < groups[j].Currency
+	})
+	return groups
+}
+
+// writeLiabilityCSV writes the report's lines, or with groupBy set, the
+// subtotals of that grouping.
+func writeLiabilityCSV(w http.ResponseWriter, report *LiabilityReportDTO, groupBy string) {
+	filename := "liability-" + report.AsOf
+	if groupBy != "" {
+		filename += "-by-" + groupBy
+	}
+	w.Header().Set("Content-Type", "text/csv")
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%s.csv", filename))
+	w.WriteHeader(http.StatusOK)
+
+	cw := csv.NewWriter(w)
+	defer cw.Flush()
+
+	if groupBy != "" {
+		groups := map[string][]LiabilityGroupDTO{
+			"policy":   report.ByPolicy,
+			"team":     report.ByTeam,
+			"currency": report.ByCurrency,
+		}[groupBy]
+		cw.Write([]string{"as_of", groupBy, "currency", "lines", "value", "receivable"})
+		for _, g := range groups {
+			cw.Write([]string{
+				report.AsOf, g.Key, g.Currency, strconv.Itoa(g.Lines),
+				strconv.FormatFloat(g.Value, 'f', 2, 64),
+				strconv.FormatFloat(g.Receivable, 'f', 2, 64),
+			})
+		}
+		return
+	}
+
+	cw.Write([]string{
+		"as_of", "entity_id", "employee_name", "team", "policy_id", "policy_name",
+		"resource_type", "unit", "outstanding", "rate", "rate_unit", "currency", "value", "receivable",
+	})
+	for _, l := range report.Lines {
+		cw.Write([]string{
+			report.AsOf, l.EntityID, l.EmployeeName, l.Team, l.PolicyID, l.PolicyName,
+			l.ResourceType, l.Unit,
+			strconv.FormatFloat(l.Outstanding, 'f', -1, 64),
+			l.Rate, l.RateUnit, l.Currency,
+			strconv.FormatFloat(l.Value, 'f', 2, 64),
+			strconv.FormatFloat(l.Receivable, 'f', 2, 64),
+		})
+	}
+}
@@ -0,0 +1,284 @@
+/*
+liability_test.go - Unit tests for pay rates and the liability report
+
+Tests for:
+- Liability report (GetLiabilityReport, CSV groupings, receivables)
+- Pay rates (CreatePayRate)
+*/
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/warp/resource-engine/generic"
+	"github.com/warp/resource-engine/store/sqlite"
+	"github.com/warp/resource-engine/timeoff"
+)
+
+func TestLiabilityReport_ValuesOutstandingBalanceAsOf(t *testing.T) {
+	// GIVEN: An employee with 1 day taken in February and 1 in April,
+	//        a 400 USD/day rate from hire and a 500 USD/day rate from June
+	// WHEN: Requesting the liability report as of March 31
+	// THEN: Only the February day and the 400 rate count, and the CSV
+	//       export carries the same line
+
+	handler := setupTestHandler(t)
+	ctx := context.Background()
+
+	policyJSON := timeoff.StandardPTOJSON("pto-test", "Test PTO", 24, 5)
+	if err := handler.createPolicyFromJSON(ctx, policyJSON); err != nil {
+		t.Fatalf("Failed to create policy: %v", err)
+	}
+	hireDate := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	if err := handler.Store.SaveEmployee(ctx, sqlite.Employee{ID: "emp-fin", Name: "Finance User", Team: "eng", HireDate: hireDate}); err != nil {
+		t.Fatalf("Failed to create employee: %v", err)
+	}
+	if err := handler.Store.SaveAssignment(ctx, sqlite.AssignmentRecord{
+		ID: "assign-fin", EntityID: "emp-fin", PolicyID: "pto-test",
+		EffectiveFrom: hireDate, ConsumptionPriority: 1,
+	}); err != nil {
+		t.Fatalf("Failed to save assignment: %v", err)
+	}
+
+	for _, d := range []time.Time{
+		time.Date(2025, 2, 10, 0, 0, 0, 0, time.UTC),
+		time.Date(2025, 4, 10, 0, 0, 0, 0, time.UTC),
+	} {
+		id := "tx-" + d.Format("0102")
+		if err := handler.Store.Append(ctx, generic.Transaction{
+			ID: generic.TransactionID(id), EntityID: "emp-fin", PolicyID: "pto-test",
+			ResourceType: timeoff.ResourcePTO, EffectiveAt: generic.TimePoint{Time: d},
+			Delta: generic.NewAmount(-1, generic.UnitDays), Type: generic.TxConsumption,
+			IdempotencyKey: id,
+		}); err != nil {
+			t.Fatalf("Failed to append consumption: %v", err)
+		}
+	}
+
+	router := NewRouter(handler)
+	for _, body := range []string{
+		`{"rate":"400","currency":"USD","effective_from":"2025-01-01"}`,
+		`{"rate":"500","currency":"USD","effective_from":"2025-06-01"}`,
+	} {
+		req := httptest.NewRequest(http.MethodPost, "/api/employees/emp-fin/pay-rates", strings.NewReader(body))
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, req)
+		if rec.Code != http.StatusCreated {
+			t.Fatalf("Expected 201 creating pay rate, got %d: %s", rec.Code, rec.Body.String())
+		}
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/api/reports/liability?as_of=2025-03-31", nil)
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Expected 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+
+	var report LiabilityReportDTO
+	if err := json.Unmarshal(rec.Body.Bytes(), &report); err != nil {
+		t.Fatalf("Failed to decode report: %v", err)
+	}
+	if len(report.Lines) != 1 {
+		t.Fatalf("Expected 1 line, got %d", len(report.Lines))
+	}
+
+	// Expected outstanding: accrued through March 31 minus the February day
+	cache := testCache(t, handler, context.Background())
+	policy := cache.policies["pto-test"]
+	asOf := generic.TimePoint{Time: time.Date(2025, 3, 31, 0, 0, 0, 0, time.UTC)}
+	period := policy.PeriodConfig.PeriodFor(asOf)
+	expected := generic.ComputeBalance(nil, period, policy.Unit, cache.accruals[policy.ID], generic.TimePoint{Time: hireDate}, asOf)
+	accrued, _ := expected.AccruedToDate.Value.Float64()
+
+	line := report.Lines[0]
+	if line.Outstanding != accrued-1 {
+		t.Errorf("Expected outstanding %.2f, got %.2f", accrued-1, line.Outstanding)
+	}
+	if line.Rate != "400" || line.Currency != "USD" {
+		t.Errorf("Expected 400 USD rate, got %s %s", line.Rate, line.Currency)
+	}
+	if line.Value != (accrued-1)*400 {
+		t.Errorf("Expected value %.2f, got %.2f", (accrued-1)*400, line.Value)
+	}
+	if len(report.ByTeam) != 1 || report.ByTeam[0].Key != "eng" || report.ByTeam[0].Value != line.Value {
+		t.Errorf("Expected single eng team group, got %+v", report.ByTeam)
+	}
+	if len(report.MissingRates) != 0 {
+		t.Errorf("Expected no missing rates, got %v", report.MissingRates)
+	}
+
+	req = httptest.NewRequest(http.MethodGet, "/api/reports/liability?as_of=2025-03-31&format=csv", nil)
+	rec = httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+	rows := strings.Split(strings.TrimSpace(rec.Body.String()), "\n")
+	if len(rows) != 2 {
+		t.Fatalf("Expected header + 1 CSV row, got %d", len(rows))
+	}
+	if !strings.HasPrefix(rows[1], "2025-03-31,emp-fin,Finance User,eng,pto-test,") {
+		t.Errorf("Unexpected CSV row: %s", rows[1])
+	}
+}
+
+func TestLiabilityReport_PastReportUnchangedByLaterRates(t *testing.T) {
+	// GIVEN: An employee with a 400 USD/day rate from hire and a liability
+	//        report as of March 31
+	// WHEN: A 500 rate is added from June, and the January rate is posted
+	//       again at 900
+	// THEN: The June rate is accepted, the January one is rejected with 409,
+	//       and the March report is the same as before
+
+	handler := setupTestHandler(t)
+	ctx := context.Background()
+
+	if err := handler.createPolicyFromJSON(ctx, timeoff.StandardPTOJSON("pto-test", "Test PTO", 24, 5)); err != nil {
+		t.Fatalf("Failed to create policy: %v", err)
+	}
+	hireDate := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	if err := handler.Store.SaveEmployee(ctx, sqlite.Employee{ID: "emp-fin", Name: "Finance User", Team: "eng", HireDate: hireDate}); err != nil {
+		t.Fatalf("Failed to create employee: %v", err)
+	}
+	if err := handler.Store.SaveAssignment(ctx, sqlite.AssignmentRecord{
+		ID: "assign-fin", EntityID: "emp-fin", PolicyID: "pto-test",
+		EffectiveFrom: hireDate, ConsumptionPriority: 1,
+	}); err != nil {
+		t.Fatalf("Failed to save assignment: %v", err)
+	}
+
+	router := NewRouter(handler)
+	addRate := func(body string) int {
+		req := httptest.NewRequest(http.MethodPost, "/api/employees/emp-fin/pay-rates", strings.NewReader(body))
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, req)
+		return rec.Code
+	}
+	report := func() string {
+		req := httptest.NewRequest(http.MethodGet, "/api/reports/liability?as_of=2025-03-31", nil)
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, req)
+		if rec.Code != http.StatusOK {
+			t.Fatalf("Expected 200, got %d: %s", rec.Code, rec.Body.String())
+		}
+		return rec.Body.String()
+	}
+
+	if code := addRate(`{"rate":"400","currency":"USD","effective_from":"2025-01-01"}`); code != http.StatusCreated {
+		t.Fatalf("Expected 201 creating pay rate, got %d", code)
+	}
+	before := report()
+
+	if code := addRate(`{"rate":"500","currency":"USD","effective_from":"2025-06-01"}`); code != http.StatusCreated {
+		t.Errorf("Expected 201 for a later rate, got %d", code)
+	}
+	if code := addRate(`{"rate":"900","currency":"USD","effective_from":"2025-01-01"}`); code != http.StatusConflict {
+		t.Errorf("Expected 409 overwriting a rate, got %d", code)
+	}
+
+	if after := report(); after != before {
+		t.Errorf("Past report changed:\nbefore: %s\nafter:  %s", before, after)
+	}
+	rates, err := handler.Store.GetPayRates(ctx, "emp-fin")
+	if err != nil {
+		t.Fatalf("Failed to get pay rates: %v", err)
+	}
+	if len(rates) != 2 || rates[0].Rate.String() != "400" {
+		t.Errorf("Expected the 400 and 500 rates, got %+v", rates)
+	}
+}
+
+func TestLiabilityReport_OverdrawnBalanceIsReceivableAndCSVGroups(t *testing.T) {
+	// GIVEN: Two eng employees at 400 USD/day, one with days left and one
+	//        who has taken 20 days more than earned
+	// WHEN: Requesting the liability report as of March 31, as JSON and as
+	//       CSV grouped by team
+	// THEN: The overdrawn line has value 0 and its amount as receivable,
+	//       the team subtotal is the other line's value alone, and the CSV
+	//       has one team row with the same value and receivable
+
+	handler := setupTestHandler(t)
+	ctx := context.Background()
+
+	if err := handler.createPolicyFromJSON(ctx, timeoff.StandardPTOJSON("pto-test", "Test PTO", 24, 5)); err != nil {
+		t.Fatalf("Failed to create policy: %v", err)
+	}
+	hireDate := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	router := NewRouter(handler)
+	for _, id := range []string{"emp-ok", "emp-over"} {
+		if err := handler.Store.SaveEmployee(ctx, sqlite.Employee{ID: id, Name: id, Team: "eng", HireDate: hireDate}); err != nil {
+			t.Fatalf("Failed to create employee: %v", err)
+		}
+		if err := handler.Store.SaveAssignment(ctx, sqlite.AssignmentRecord{
+			ID: "assign-" + id, EntityID: id, PolicyID: "pto-test",
+			EffectiveFrom: hireDate, ConsumptionPriority: 1,
+		}); err != nil {
+			t.Fatalf("Failed to save assignment: %v", err)
+		}
+		req := httptest.NewRequest(http.MethodPost, "/api/employees/"+id+"/pay-rates",
+			strings.NewReader(`{"rate":"400","currency":"USD","effective_from":"2025-01-01"}`))
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, req)
+		if rec.Code != http.StatusCreated {
+			t.Fatalf("Expected 201 creating pay rate, got %d: %s", rec.Code, rec.Body.String())
+		}
+	}
+	if err := handler.Store.Append(ctx, generic.Transaction{
+		ID: "tx-over", EntityID: "emp-over", PolicyID: "pto-test",
+		ResourceType: timeoff.ResourcePTO, EffectiveAt: generic.TimePoint{Time: time.Date(2025, 2, 10, 0, 0, 0, 0, time.UTC)},
+		Delta: generic.NewAmount(-20, generic.UnitDays), Type: generic.TxConsumption,
+		IdempotencyKey: "tx-over",
+	}); err != nil {
+		t.Fatalf("Failed to append consumption: %v", err)
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/api/reports/liability?as_of=2025-03-31", nil)
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Expected 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+	var report LiabilityReportDTO
+	if err := json.Unmarshal(rec.Body.Bytes(), &report); err != nil {
+		t.Fatalf("Failed to decode report: %v", err)
+	}
+	lines := make(map[string]LiabilityLineDTO)
+	for _, l := range report.Lines {
+		lines[l.EntityID] = l
+	}
+	ok, over := lines["emp-ok"], lines["emp-over"]
+	if ok.Value <= 0 || ok.Receivable != 0 {
+		t.Errorf("emp-ok: expected a value and no receivable, got %+v", ok)
+	}
+	if over.Outstanding >= 0 || over.Value != 0 || over.Receivable != -over.Outstanding*400 {
+		t.Errorf("emp-over: expected value 0 and receivable %.2f, got %+v", -over.Outstanding*400, over)
+	}
+	if len(report.ByTeam) != 1 || report.ByTeam[0].Value != ok.Value ||
+		report.ByTeam[0].Receivable != over.Receivable || report.ByTeam[0].Lines != 2 {
+		t.Errorf("Expected eng subtotal %.2f with receivable %.2f, got %+v", ok.Value, over.Receivable, report.ByTeam)
+	}
+
+	req = httptest.NewRequest(http.MethodGet, "/api/reports/liability?as_of=2025-03-31&format=csv&group_by=team", nil)
+	rec = httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Expected 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+	rows := strings.Split(strings.TrimSpace(rec.Body.String()), "\n")
+	want := fmt.Sprintf("2025-03-31,eng,USD,2,%.2f,%.2f", ok.Value, over.Receivable)
+	if len(rows) != 2 || rows[0] != "as_of,team,currency,lines,value,receivable" || rows[1] != want {
+		t.Errorf("Expected team CSV row %q, got %q", want, rows)
+	}
+
+	req = httptest.NewRequest(http.MethodGet, "/api/reports/liability?format=csv&group_by=employee", nil)
+	rec = httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("Expected 400 for an unknown group_by, got %d", rec.Code)
+	}
+}
@@ -0,0 +1,289 @@
+/*
+preview.go - Reconciliation dry runs
+
+PURPOSE:
+  Shows what reconciliation would post before it posts anything: the
+  carryover, expiry and cap transactions ReconciliationEngine.Process
+  proposes for every entity and policy, with its ReconciliationSummary
+  and totals per unit. Nothing is written, no run is recorded and no
+  event is published.
+
+MODES:
+  Rollover preview:
+    POST /api/admin/rollover with "dry_run": true. Same selection as the
+    rollover itself (entity_id, policy_id, period_end), one line per
+    assignment.
+
+  Scheduler preview:
+    GET /api/reconciliation/preview?as_of=YYYY-MM-DD. Plans exactly as a
+    scheduler batch would (planBatch, entityWork): every period ending
+    before as_of without a completed run, one line per period. When an
+    assignment has several such periods, each later period is computed
+    with the earlier proposals applied, as the batch would post them.
+
+FORMATS:
+  JSON by default; ?format=csv returns one row per line, with the
+  proposed transactions in one column.
+
+SEE ALSO:
+  - reconciliation.go: proposePeriod, planBatch, the batches themselves
+  - handlers.go: TriggerRollover
+  - generic/policy.go: ReconciliationEngine
+*/
+package api
+
+import (
+	"context"
+	"encoding/csv"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/shopspring/decimal"
+	"github.com/warp/resource-engine/generic"
+	"github.com/warp/resource-engine/store/sqlite"
+)
+
+// =============================================================================
+// PREVIEW ENDPOINT
+// =============================================================================
+
+// PreviewReconciliation returns what a scheduler batch would post as of
+// a day, without posting it.
+// GET /api/reconciliation/preview?as_of=YYYY-MM-DD&format=json|csv
+func (h *Handler) PreviewReconciliation(w http.ResponseWriter, r *http.Request) {
+	asOf := generic.Today()
+	if s := r.URL.Query().Get("as_of"); s != "" {
+		t, err := time.Parse("2006-01-02", s)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, "Invalid as_of format (use YYYY-MM-DD)", err)
+			return
+		}
+		asOf = generic.TimePoint{Time: t}
+	}
+	if !validPreviewFormat(w, r) {
+		return
+	}
+
+	preview, err := h.previewReconciliation(r.Context(), asOf)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "Failed to preview reconciliation", err)
+		return
+	}
+	writeReconciliationPreview(w, r, preview)
+}
+
+// previewReconciliation proposes every period a scheduler batch as of
+// asOf would reconcile. An assignment's later periods are skipped after
+// a failed one, as in reconcileEntity.
+func (h *Handler) previewReconciliation(ctx context.Context, asOf generic.TimePoint) (*ReconciliationPreviewDTO, error) {
+	policies := newBatchPolicies(h)
+	b := sqlite.ReconciliationBatch{AsOf: asOf.Time}
+	entities, err := h.planBatch(ctx, b, policies)
+	if err != nil {
+		return nil, err
+	}
+
+	var lines []ReconciliationPreviewLineDTO
+	for _, entityID := range entities {
+		items, err := h.entityWork(ctx, entityID, asOf, policies)
+		if err != nil {
+			return nil, err
+		}
+
+		// Proposals not in the ledger yet, per assignment
+		pending := make(map[string][]generic.Transaction)
+		stalled := make(map[string]bool)
+		for _, item := range items {
+			if stalled[item.assign.ID] {
+				continue
+			}
+			periodEnd := item.period.End.Time.Format("2006-01-02")
+			balance, output, err := h.proposePeriod(ctx, entityID, item.assign, item.policy, item.period, pending[item.assign.ID])
+			var generation int
+			if err == nil {
+				generation, err = h.rolloverGeneration(ctx, entityID, item.assign.PolicyID, periodEnd)
+			}
+			if err != nil {
+				stalled[item.assign.ID] = true
+				lines = append(lines, previewLine(entityID, item.policy, item.period, generic.Balance{}, nil, err))
+				continue
+			}
+			keyRollover(output.Transactions, entityID, item.assign.PolicyID, periodEnd, generation)
+			pending[item.assign.ID] = append(pending[item.assign.ID], output.Transactions...)
+			lines = append(lines, previewLine(entityID, item.policy, item.period, balance, output, nil))
+		}
+	}
+
+	preview := newReconciliationPreview(lines)
+	preview.AsOf = asOf.Time.Format("2006-01-02")
+	return preview, nil
+}
+
+// =============================================================================
+// PREVIEW LINES AND TOTALS
+// =============================================================================
+
+// previewLine describes one proposed reconciliation, or the error that
+// prevented it.
+func previewLine(
+	entityID string,
+	policy *generic.Policy,
+	period generic.Period,
+	balance generic.Balance,
+	output *generic.ReconciliationOutput,
+	err error,
+) ReconciliationPreviewLineDTO {
+	line := ReconciliationPreviewLineDTO{
+		EntityID:     entityID,
+		PolicyID:     string(policy.ID),
+		Unit:         string(policy.Unit),
+		PeriodStart:  period.Start.Time.Format("2006-01-02"),
+		PeriodEnd:    period.End.Time.Format("2006-01-02"),
+		CarryoverCap: carryoverCap(policy),
+		Transactions: []TransactionDTO{},
+	}
+	if policy.Constraints.MaxBalance != nil {
+		max := roundFloat(policy.Constraints.MaxBalance.Value, 4)
+		line.MaxBalance = &max
+	}
+	if err != nil {
+		line.Error = err.Error()
+		return line
+	}
+
+	line.Balance = roundFloat(balance.CurrentAccrued().Value, 4)
+	line.CarriedOver = roundFloat(output.Summary.CarriedOver.Value, 4)
+	line.Expired = roundFloat(output.Summary.Expired.Value, 4)
+	line.Prorated = roundFloat(output.Summary.Prorated.Value, 4)
+	line.Transactions = toTransactionDTOs(output.Transactions)
+	return line
+}
+
+// carryoverCap returns the smallest max carryover of the policy's period
+// end carryover actions, or nil if carryover is unlimited.
+func carryoverCap(policy *generic.Policy) *float64 {
+	var smallest *generic.Amount
+	for _, rule := range policy.ReconciliationRules {
+		if rule.Trigger.Type != generic.TriggerPeriodEnd {
+			continue
+		}
+		for _, action := range rule.Actions {
+			max := action.Config.MaxCarryover
+			if action.Type != generic.ActionCarryover || max == nil {
+				continue
+			}
+			if smallest == nil || max.Value.LessThan(smallest.Value) {
+				smallest = max
+			}
+		}
+	}
+	if smallest == nil {
+		return nil
+	}
+	f := roundFloat(smallest.Value, 4)
+	return &f
+}
+
+// newReconciliationPreview wraps lines with their totals per unit. Days
+// and hours are never summed together.
+func newReconciliationPreview(lines []ReconciliationPreviewLineDTO) *ReconciliationPreviewDTO {
+	if lines == nil {
+		lines = []ReconciliationPreviewLineDTO{}
+	}
+
+	byUnit := make(map[string]*ReconciliationPreviewTotalDTO)
+	entities := make(map[string]map[string]bool)
+	for _, l := range lines {
+		t, ok := byUnit[l.Unit]
+		if !ok {
+			t = &ReconciliationPreviewTotalDTO{Unit: l.Unit}
+			byUnit[l.Unit] = t
+			entities[l.Unit] = make(map[string]bool)
+		}
+		t.Lines++
+		if l.Error != "" {
+			t.Failed++
+		}
+		t.CarriedOver += l.CarriedOver
+		t.Expired += l.Expired
+		t.Prorated += l.Prorated
+		entities[l.Unit][l.EntityID] = true
+	}
+
+	totals := make([]ReconciliationPreviewTotalDTO, 0, len(byUnit))
+	for unit, t := range byUnit {
+		t.Entities = len(entities[unit])
+		t.CarriedOver = roundFloat(decimal.NewFromFloat(t.CarriedOver), 4)
+		t.Expired = roundFloat(decimal.NewFromFloat(t.Expired), 4)
+		t.Prorated = roundFloat(decimal.NewFromFloat(t.Prorated), 4)
+		totals = append(totals, *t)
+	}
+	sort.Slice(totals, func(i, j int) bool { return totals[i].Unit < totals[j].Unit })
+
+	return &ReconciliationPreviewDTO{Lines: lines, Totals: totals}
+}
+
+// =============================================================================
+// OUTPUT
+// =============================================================================
+
+// validPreviewFormat checks ?format before any work is done, writing the
+// error if it is not json or csv.
+func validPreviewFormat(w http.ResponseWriter, r *http.Request) bool {
+	switch r.URL.Query().Get("format") {
+	case "", "json", "csv":
+		return true
+	default:
+		writeError(w, http.StatusBadRequest, "Invalid format (use json or csv)", nil)
+		return false
+	}
+}
+
+func writeReconciliationPreview(w http.ResponseWriter, r *http.Request, preview *ReconciliationPreviewDTO) {
+	if r.URL.Query().Get("format") != "csv" {
+		writeJSON(w, http.StatusOK, preview)
+		return
+	}
+
+	date := preview.AsOf
+	if date == "" {
+		date = preview.PeriodEnd
+	}
+	w.Header().Set("Content-Type", "text/csv")
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=reconciliation-preview-%s.csv", date))
+	w.WriteHeader(http.StatusOK)
+
+	cw := csv.NewWriter(w)
+	cw.Write([]string{
+		"entity_id", "policy_id", "unit", "period_start", "period_end", "balance",
+		"carryover_cap", "max_balance", "carried_over", "expired", "prorated",
+		"transactions", "error",
+	})
+	for _, l := range preview.Lines {
+		txs := make([]string, len(l.Transactions))
+		for i, tx := range l.Transactions {
+			txs[i] = fmt.Sprintf("%s %s %s", tx.EffectiveAt[:10], strconv.FormatFloat(tx.Delta, 'f', -1, 64), tx.Reason)
+		}
+		cw.Write([]string{
+			l.EntityID, l.PolicyID, l.Unit, l.PeriodStart, l.PeriodEnd,
+			strconv.FormatFloat(l.Balance, 'f', -1, 64),
+			optionalFloat(l.CarryoverCap), optionalFloat(l.MaxBalance),
+			strconv.FormatFloat(l.CarriedOver, 'f', -1, 64),
+			strconv.FormatFloat(l.Expired, 'f', -1, 64),
+			strconv.FormatFloat(l.Prorated, 'f', -1, 64),
+			strings.Join(txs, "; "), l.Error,
+		})
+	}
+	cw.Flush()
+}
+
+func optionalFloat(f *float64) string {
+	if f == nil {
+		return ""
+	}
+	return strconv.FormatFloat(*f, 'f', -1, 64)
+}
@@ -0,0 +1,118 @@
+/*
+preview_test.go - Unit tests for reconciliation dry runs
+
+Tests for:
+- Reconciliation dry runs (PreviewReconciliation, TriggerRollover dry_run)
+*/
+package api
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/warp/resource-engine/store/sqlite"
+	"github.com/warp/resource-engine/timeoff"
+)
+
+func TestReconciliationPreview_DryRunsMatchWhatIsPosted(t *testing.T) {
+	// GIVEN: PTO of 12 days a year (carryover up to 5) assigned since 2023
+	// WHEN: The scheduler's reconciliation as of Feb 1, 2026 and the 2023
+	//       rollover are previewed, as JSON and CSV, and then the
+	//       scheduler runs
+	// THEN: The previews chain 2023 -> 2025 like the scheduler, write
+	//       nothing, and propose exactly what the scheduler then posts
+
+	handler := setupTestHandler(t)
+	ctx := context.Background()
+
+	if err := handler.createPolicyFromJSON(ctx, timeoff.StandardPTOJSON("pto-test", "Test PTO", 12, 5)); err != nil {
+		t.Fatalf("Failed to create policy: %v", err)
+	}
+	since := time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC)
+	if err := handler.Store.SaveEmployee(ctx, sqlite.Employee{ID: "emp-back", Name: "Back User", HireDate: since}); err != nil {
+		t.Fatalf("Failed to create employee: %v", err)
+	}
+	if err := handler.Store.SaveAssignment(ctx, sqlite.AssignmentRecord{
+		ID: "assign-back", EntityID: "emp-back", PolicyID: "pto-test",
+		EffectiveFrom: since, ConsumptionPriority: 1,
+	}); err != nil {
+		t.Fatalf("Failed to save assignment: %v", err)
+	}
+
+	router := NewRouter(handler)
+	call := func(method, path, body string) *httptest.ResponseRecorder {
+		t.Helper()
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, req)
+		if rec.Code != http.StatusOK {
+			t.Fatalf("%s %s: %d %s", method, path, rec.Code, rec.Body.String())
+		}
+		return rec
+	}
+
+	var preview ReconciliationPreviewDTO
+	json.Unmarshal(call(http.MethodGet, "/api/reconciliation/preview?as_of=2026-02-01", "").Body.Bytes(), &preview)
+	if len(preview.Lines) != 3 {
+		t.Fatalf("Expected 3 preview lines (2023-2025), got %+v", preview.Lines)
+	}
+	want := []struct{ balance, carried, expired float64 }{{12, 5, 7}, {17, 5, 12}, {17, 5, 12}}
+	for i, l := range preview.Lines {
+		if l.Balance != want[i].balance || l.CarriedOver != want[i].carried || l.Expired != want[i].expired {
+			t.Errorf("%s: balance %.0f, carried %.0f, expired %.0f; want %v",
+				l.PeriodEnd, l.Balance, l.CarriedOver, l.Expired, want[i])
+		}
+		if l.CarryoverCap == nil || *l.CarryoverCap != 5 || len(l.Transactions) != 2 {
+			t.Errorf("%s: cap %v, %d transactions; want cap 5 and 2 transactions", l.PeriodEnd, l.CarryoverCap, len(l.Transactions))
+		}
+	}
+	if len(preview.Totals) != 1 || preview.Totals[0].CarriedOver != 15 || preview.Totals[0].Expired != 31 || preview.Totals[0].Entities != 1 {
+		t.Errorf("Expected days totals of 1 entity, 15 carried, 31 expired; got %+v", preview.Totals)
+	}
+
+	csvRec := call(http.MethodGet, "/api/reconciliation/preview?as_of=2026-02-01&format=csv", "")
+	rows, err := csv.NewReader(csvRec.Body).ReadAll()
+	if err != nil || len(rows) != 4 || rows[1][0] != "emp-back" || rows[1][4] != "2023-12-31" {
+		t.Errorf("Expected a header and 3 CSV rows starting with emp-back's 2023, got %v (%v)", rows, err)
+	}
+
+	var rollover ReconciliationPreviewDTO
+	body := `{"entity_id":"emp-back","period_end":"2023-12-31","dry_run":true}`
+	json.Unmarshal(call(http.MethodPost, "/api/admin/rollover", body).Body.Bytes(), &rollover)
+	if len(rollover.Lines) != 1 || rollover.Lines[0].CarriedOver != 5 || rollover.Lines[0].Expired != 7 {
+		t.Errorf("Expected the 2023 rollover preview to carry 5 and expire 7, got %+v", rollover.Lines)
+	}
+
+	txs, _ := handler.Store.GetAllTransactions(ctx, 100)
+	runs, _ := handler.Store.GetReconciliationRuns(ctx, "")
+	if len(txs) != 0 || len(runs) != 0 {
+		t.Fatalf("Dry runs wrote %d transactions and %d runs", len(txs), len(runs))
+	}
+
+	scheduler := NewReconciliationScheduler(handler.Store, handler)
+	scheduler.Now = func() time.Time { return time.Date(2026, 2, 1, 9, 0, 0, 0, time.UTC) }
+	scheduler.RunNow()
+
+	posted := map[string]bool{}
+	txs, _ = handler.Store.GetAllTransactions(ctx, 100)
+	for _, tx := range txs {
+		posted[fmt.Sprintf("%s %.0f", tx.ID, tx.Delta.Value.InexactFloat64())] = true
+	}
+	for _, l := range preview.Lines {
+		for _, tx := range l.Transactions {
+			if !posted[fmt.Sprintf("%s %.0f", tx.ID, tx.Delta)] {
+				t.Errorf("Previewed %s (%.0f) was not posted", tx.ID, tx.Delta)
+			}
+		}
+	}
+	if len(txs) != 6 {
+		t.Errorf("Expected the 6 previewed transactions to be posted, got %d", len(txs))
+	}
+}
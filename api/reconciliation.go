@@ -0,0 +1,789 @@
+/*
+reconciliation.go - Batch reconciliation runs
+
+PURPOSE:
+  Reconciles (rolls over) every entity with ended, unreconciled periods
+  as one batch: a pool of workers takes entities in ID order, progress
+  and totals are saved on the batch as entities finish, and a batch can
+  be cancelled and later resumed from its checkpoint. The scheduler runs
+  one batch per tenant per check; admins start, follow, cancel and
+  resume batches through the API.
+
+BATCH LIFECYCLE:
+  running   -> completed    Every planned entity processed
+  running   -> cancelled    Cancel endpoint, or the scheduler lost its lease
+  cancelled -> running      Resume: plans the entities after Checkpoint
+  completed -> rolled_back  Rollback of every completed item (rollback.go)
+
+  Planning lists the entities that have at least one period ending
+  before AsOf without a completed run (entityWork); Total is that count.
+
+CHECKPOINT:
+  Workers finish entities out of order. Checkpoint only advances over a
+  contiguous run of finished entities, so every entity up to it is done.
+  Resuming redoes at most the entities that were in flight: completed
+  periods are skipped and rollover transactions have deterministic
+  idempotency keys (keyRollover), so nothing is posted twice.
+
+FAILURES:
+  A failed period is saved as a failed item (a reconciliation_runs row
+  with the error) and counted in Failed. The assignment's later periods
+  wait, since they would start from a wrong balance; the next batch
+  plans the entity again.
+
+ENDPOINTS:
+  GET  /api/reconciliation/runs                  Batches, newest first
+  POST /api/reconciliation/runs                  Start a batch
+  GET  /api/reconciliation/runs/{id}             One batch
+  GET  /api/reconciliation/runs/{id}/items       Its items (?status=)
+  POST /api/reconciliation/runs/{id}/cancel      Stop after in-flight entities
+  POST /api/reconciliation/runs/{id}/resume      Continue after Checkpoint
+  POST /api/reconciliation/runs/{id}/rollback    Reverse its items (rollback.go)
+  GET  /api/reconciliation/items                 Every item (?status=)
+  POST /api/reconciliation/items/{id}/rollback   Reverse one item (rollback.go)
+  GET  /api/reconciliation/preview               Dry run (preview.go)
+
+  Completed items are corrected in place after backdated transactions
+  (rereconcile.go).
+
+SEE ALSO:
+  - scheduler.go: Runs a batch per tenant on the leader replica
+  - handlers.go: TriggerRollover (one period end, synchronous)
+  - generic/policy.go: ReconciliationEngine
+*/
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/warp/resource-engine/generic"
+	"github.com/warp/resource-engine/store/record"
+	"github.com/warp/resource-engine/store/sqlite"
+)
+
+// Batch worker pool size.
+const (
+	DefaultBatchWorkers = 8
+	maxBatchWorkers     = 64
+)
+
+// =============================================================================
+// PLANNING
+// =============================================================================
+
+// reconcileItem is one period of one assignment to reconcile.
+type reconcileItem struct {
+	assign sqlite.AssignmentRecord
+	policy *generic.Policy
+	period generic.Period
+}
+
+// batchPolicies parses each policy once per batch rather than once per
+// entity. Unknown and unparsable policies are remembered as nil.
+type batchPolicies struct {
+	h      *Handler
+	mu     sync.Mutex
+	parsed map[string]*generic.Policy
+}
+
+func newBatchPolicies(h *Handler) *batchPolicies {
+	return &batchPolicies{h: h, parsed: make(map[string]*generic.Policy)}
+}
+
+func (bp *batchPolicies) get(ctx context.Context, id string) (*generic.Policy, error) {
+	bp.mu.Lock()
+	defer bp.mu.Unlock()
+
+	if policy, ok := bp.parsed[id]; ok {
+		return policy, nil
+	}
+	policyRecord, err := bp.h.Store.GetPolicy(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	var policy *generic.Policy
+	if policyRecord != nil {
+		if parsed, _, err := bp.h.PolicyFactory.ParsePolicy(policyRecord.ConfigJSON); err == nil {
+			policy = parsed
+		}
+	}
+	bp.parsed[id] = policy
+	return policy, nil
+}
+
+// entityWork returns the entity's periods that ended before asOf and have
+// no completed run, oldest first within each assignment.
+func (h *Handler) entityWork(ctx context.Context, entityID string, asOf generic.TimePoint, policies *batchPolicies) ([]reconcileItem, error) {
+	assignments, err := h.Store.GetAssignmentsByEntity(ctx, entityID)
+	if err != nil {
+		return nil, err
+	}
+
+	var items []reconcileItem
+	for _, assign := range assignments {
+		policy, err := policies.get(ctx, assign.PolicyID)
+		if err != nil {
+			return nil, err
+		}
+		if policy == nil {
+			continue
+		}
+		for _, period := range pendingPeriods(policy.PeriodConfig, assign, asOf) {
+			done, err := h.Store.IsReconciliationComplete(ctx, entityID, assign.PolicyID, period.End.Time)
+			if err != nil {
+				return nil, err
+			}
+			if !done {
+				items = append(items, reconcileItem{assign: assign, policy: policy, period: period})
+			}
+		}
+	}
+	return items, nil
+}
+
+// planBatch returns, in ID order, the entities after the batch's
+// checkpoint that have work as of its AsOf.
+func (h *Handler) planBatch(ctx context.Context, b sqlite.ReconciliationBatch, policies *batchPolicies) ([]string, error) {
+	employees, err := h.Store.ListEmployees(ctx)
+	if err != nil {
+		return nil, err
+	}
+	sort.Slice(employees, func(i, j int) bool { return employees[i].ID < employees[j].ID })
+
+	asOf := generic.TimePoint{Time: b.AsOf}
+	var entities []string
+	for _, emp := range employees {
+		if b.Checkpoint != "" && emp.ID <= b.Checkpoint {
+			continue
+		}
+		items, err := h.entityWork(ctx, emp.ID, asOf, policies)
+		if err != nil {
+			return nil, fmt.Errorf("planning %s: %w", emp.ID, err)
+		}
+		if len(items) > 0 {
+			entities = append(entities, emp.ID)
+		}
+	}
+	return entities, nil
+}
+
+// pendingPeriods returns the assignment's periods that ended before today,
+// oldest first: from the period containing EffectiveFrom up to the last
+// one that starts before EffectiveTo.
+func pendingPeriods(pc generic.PeriodConfig, assign sqlite.AssignmentRecord, today generic.TimePoint) []generic.Period {
+	var periods []generic.Period
+	period := pc.PeriodFor(generic.TimePoint{Time: assign.EffectiveFrom})
+	for period.End.Before(today) {
+		if assign.EffectiveTo != nil && period.Start.After(generic.TimePoint{Time: *assign.EffectiveTo}) {
+			break
+		}
+		periods = append(periods, period)
+
+		next := pc.PeriodFor(period.End.AddDays(1))
+		if !next.End.After(period.End) {
+			break // Period config that does not advance
+		}
+		period = next
+	}
+	return periods
+}
+
+// newBatch returns a running batch for the context's tenant (not saved).
+func newBatch(trigger string, asOf generic.TimePoint, workers int) sqlite.ReconciliationBatch {
+	if workers <= 0 {
+		workers = DefaultBatchWorkers
+	}
+	if workers > maxBatchWorkers {
+		workers = maxBatchWorkers
+	}
+	now := time.Now().UTC()
+	return sqlite.ReconciliationBatch{
+		ID:        fmt.Sprintf("batch-%d", now.UnixNano()),
+		Trigger:   trigger,
+		Status:    sqlite.BatchRunning,
+		AsOf:      asOf.Time,
+		Workers:   workers,
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+}
+
+// =============================================================================
+// EXECUTION
+// =============================================================================
+
+// entityResult is what reconciling one entity added to its batch.
+type entityResult struct {
+	index       int
+	reconciled  int
+	failed      int
+	carriedOver float64
+	expired     float64
+	err         error
+}
+
+// executeBatch reconciles the planned entities with the batch's worker
+// pool and returns the batch as last saved. It stops handing out entities
+// when the batch is cancelled (through the API, on any replica) or when
+// heartbeat, if set, returns false; in-flight entities still finish.
+func (h *Handler) executeBatch(ctx context.Context, b sqlite.ReconciliationBatch, entities []string, policies *batchPolicies, heartbeat func() bool) sqlite.ReconciliationBatch {
+	runCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	h.trackBatch(b.ID, cancel)
+	defer h.untrackBatch(b.ID)
+
+	asOf := generic.TimePoint{Time: b.AsOf}
+	jobs := make(chan int)
+	results := make(chan entityResult)
+
+	// Workers finish their entity even once the batch is cancelled, so
+	// they use ctx rather than runCtx; entities handed out after the
+	// cancellation are dropped
+	var wg sync.WaitGroup
+	for w := 0; w < b.Workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				if runCtx.Err() != nil {
+					continue
+				}
+				res := h.reconcileEntity(ctx, b.ID, entities[i], asOf, policies)
+				res.index = i
+				results <- res
+			}
+		}()
+	}
+	go func() {
+		defer close(jobs)
+		for i := range entities {
+			select {
+			case jobs <- i:
+			case <-runCtx.Done():
+				return
+			}
+		}
+	}()
+	go func() {
+		wg.Wait()
+		close(results)
+	}()
+
+	done := make([]bool, len(entities))
+	next := 0
+	for res := range results {
+		done[res.index] = true
+		for next < len(entities) && done[next] {
+			b.Checkpoint = entities[next]
+			next++
+		}
+		b.Processed++
+		b.Reconciled += res.reconciled
+		b.Failed += res.failed
+		b.CarriedOver += res.carriedOver
+		b.Expired += res.expired
+		if res.err != nil {
+			b.Error = fmt.Sprintf("%s: %v", entities[res.index], res.err)
+		}
+
+		if latest, err := h.Store.GetReconciliationBatch(ctx, b.ID); err == nil && latest != nil && latest.Status == sqlite.BatchCancelled {
+			cancel()
+		}
+		if heartbeat != nil && !heartbeat() {
+			cancel()
+		}
+		if runCtx.Err() != nil {
+			b.Status = sqlite.BatchCancelled
+		}
+		b.UpdatedAt = time.Now().UTC()
+		if err := h.Store.SaveReconciliationBatch(ctx, b); err != nil {
+			log.Printf("[Reconciliation] Error saving batch %s: %v", b.ID, err)
+		}
+	}
+
+	if next < len(entities) {
+		b.Status = sqlite.BatchCancelled
+	} else {
+		completedAt := time.Now().UTC()
+		b.Status = sqlite.BatchCompleted
+		b.CompletedAt = &completedAt
+	}
+	b.UpdatedAt = time.Now().UTC()
+	if err := h.Store.SaveReconciliationBatch(ctx, b); err != nil {
+		log.Printf("[Reconciliation] Error saving batch %s: %v", b.ID, err)
+	}
+	log.Printf("[Reconciliation] Batch %s %s: %d/%d entities, %d periods reconciled, %d failed",
+		b.ID, b.Status, b.Processed, b.Total, b.Reconciled, b.Failed)
+	return b
+}
+
+// planAndExecute plans a saved batch and runs it, for batches started or
+// resumed through the API. A planning failure cancels the batch, so it
+// can be resumed.
+func (h *Handler) planAndExecute(ctx context.Context, b sqlite.ReconciliationBatch) {
+	policies := newBatchPolicies(h)
+	entities, err := h.planBatch(ctx, b, policies)
+	if err != nil {
+		b.Status = sqlite.BatchCancelled
+		b.Error = err.Error()
+		b.UpdatedAt = time.Now().UTC()
+		if err := h.Store.SaveReconciliationBatch(ctx, b); err != nil {
+			log.Printf("[Reconciliation] Error saving batch %s: %v", b.ID, err)
+		}
+		return
+	}
+
+	if b.Checkpoint == "" {
+		b.Total = len(entities)
+	} else if b.Processed = b.Total - len(entities); b.Processed < 0 {
+		// Entities that gained work since the batch was planned
+		b.Total, b.Processed = len(entities), 0
+	}
+	h.executeBatch(ctx, b, entities, policies, nil)
+}
+
+// reconcileEntity reconciles an entity's pending periods, oldest first.
+func (h *Handler) reconcileEntity(ctx context.Context, batchID, entityID string, asOf generic.TimePoint, policies *batchPolicies) entityResult {
+	items, err := h.entityWork(ctx, entityID, asOf, policies)
+	if err != nil {
+		return entityResult{failed: 1, err: err}
+	}
+
+	var res entityResult
+	stalled := make(map[string]bool) // Assignments with a failed period
+	for _, item := range items {
+		if stalled[item.assign.ID] {
+			continue
+		}
+		run, err := h.reconcilePeriod(ctx, batchID, entityID, item.assign, item.policy, item.period)
+		if err != nil {
+			log.Printf("[Reconciliation] Error processing reconciliation for %s/%s (%s): %v",
+				entityID, item.assign.PolicyID, item.period, err)
+			res.failed++
+			res.err = err
+			stalled[item.assign.ID] = true
+			continue
+		}
+		res.reconciled++
+		res.carriedOver += run.CarriedOver
+		res.expired += run.Expired
+	}
+	return res
+}
+
+// proposePeriod computes the period's end balance and what the
+// reconciliation engine would post for it, without writing anything.
+// Proposed transactions of earlier periods that are not in the ledger
+// yet (a dry run catching up several periods) are passed as pending.
+func (h *Handler) proposePeriod(
+	ctx context.Context,
+	entityID string,
+	assign sqlite.AssignmentRecord,
+	policy *generic.Policy,
+	period generic.Period,
+	pending []generic.Transaction,
+) (generic.Balance, *generic.ReconciliationOutput, error) {
+	loaded, err := h.Store.LoadRange(ctx, generic.EntityID(entityID), generic.PolicyID(assign.PolicyID), period.Start, period.End)
+	if err != nil {
+		return generic.Balance{}, nil, err
+	}
+
+	// The period's own expiry, cap and corrections are its output, not
+	// its balance (they exist when it is proposed again: rereconcile.go)
+	periodEnd := period.End.Time.Format("2006-01-02")
+	var txs []generic.Transaction
+	for _, tx := range loaded {
+		if !isPeriodRollover(tx, entityID, assign.PolicyID, periodEnd) {
+			txs = append(txs, tx)
+		}
+	}
+	for _, tx := range pending {
+		if !tx.EffectiveAt.Before(period.Start) && !tx.EffectiveAt.After(period.End) {
+			txs = append(txs, tx)
+		}
+	}
+
+	// Get accrual schedule if available
+	cache, err := h.cache(ctx)
+	if err != nil {
+		return generic.Balance{}, nil, err
+	}
+	var accruals generic.AccrualSchedule
+	if sched, ok := cache.accruals[generic.PolicyID(assign.PolicyID)]; ok {
+		accruals = sched
+	}
+
+	// Calculate balance from transactions
+	balance := generic.ComputeBalance(txs, period, policy.Unit, accruals, period.Start, period.End)
+
+	engine := &generic.ReconciliationEngine{}
+	nextPeriod := policy.PeriodConfig.PeriodFor(period.End.AddDays(1))
+	output, err := engine.Process(generic.ReconciliationInput{
+		EntityID:       generic.EntityID(entityID),
+		PolicyID:       generic.PolicyID(assign.PolicyID),
+		Policy:         *policy,
+		CurrentBalance: balance,
+		EndingPeriod:   period,
+		NextPeriod:     nextPeriod,
+	})
+	return balance, output, err
+}
+
+// reconcilePeriod rolls one period of an assignment over and records it as
+// a reconciliation run (an item of batchID).
+func (h *Handler) reconcilePeriod(
+	ctx context.Context,
+	batchID string,
+	entityID string,
+	assign sqlite.AssignmentRecord,
+	policy *generic.Policy,
+	period generic.Period,
+) (sqlite.ReconciliationRun, error) {
+	// A period keeps its run (and ID) when it runs again: after a failure,
+	// on resume or after a rollback. Workers run in parallel, so a new ID
+	// must not depend on the clock alone.
+	runID := record.NewEventID("run")
+	existing, err := h.Store.GetReconciliationRunForPeriod(ctx, entityID, assign.PolicyID, period.Start.Time, period.End.Time)
+	if err != nil {
+		return sqlite.ReconciliationRun{}, fmt.Errorf("failed to load run record: %w", err)
+	}
+	if existing != nil {
+		runID = existing.ID
+	}
+	startTime := time.Now()
+
+	// Create run record (pending)
+	run := sqlite.ReconciliationRun{
+		ID:          runID,
+		BatchID:     batchID,
+		PolicyID:    assign.PolicyID,
+		EntityID:    entityID,
+		PeriodStart: period.Start.Time,
+		PeriodEnd:   period.End.Time,
+		Status:      "running",
+		StartedAt:   &startTime,
+		CreatedAt:   startTime,
+	}
+
+	if err := h.Store.SaveReconciliationRun(ctx, run); err != nil {
+		return run, fmt.Errorf("failed to save run record: %w", err)
+	}
+
+	_, output, err := h.proposePeriod(ctx, entityID, assign, policy, period, nil)
+	if err != nil {
+		run.Status = "failed"
+		run.Error = err.Error()
+		h.Store.SaveReconciliationRun(ctx, run)
+		return run, err
+	}
+
+	// Same keys as TriggerRollover, so a period is never rolled over twice
+	periodEnd := period.End.Time.Format("2006-01-02")
+	generation, err := h.rolloverGeneration(ctx, entityID, assign.PolicyID, periodEnd)
+	if err != nil {
+		run.Status = "failed"
+		run.Error = err.Error()
+		h.Store.SaveReconciliationRun(ctx, run)
+		return run, err
+	}
+	keyRollover(output.Transactions, entityID, assign.PolicyID, periodEnd, generation)
+
+	// Append reconciliation transactions; a duplicate key means the
+	// rollover was already posted manually
+	if len(output.Transactions) > 0 {
+		event := map[string]any{"period_end": periodEnd, "run_id": runID}
+		err := h.appendWithEvent(ctx, output.Transactions, generic.EventRolloverPosted, event)
+		if errors.Is(err, generic.ErrDuplicateIdempotencyKey) {
+			log.Printf("[Reconciliation] %s/%s %s: rollover already posted", entityID, assign.PolicyID, periodEnd)
+		} else if err != nil {
+			run.Status = "failed"
+			run.Error = err.Error()
+			h.Store.SaveReconciliationRun(ctx, run)
+			return run, err
+		}
+	}
+
+	// Update run record (completed)
+	completedTime := time.Now()
+	run.Status = "completed"
+	carriedOver, _ := output.Summary.CarriedOver.Value.Float64()
+	expired, _ := output.Summary.Expired.Value.Float64()
+	run.CarriedOver = carriedOver
+	run.Expired = expired
+	run.CompletedAt = &completedTime
+
+	if err := h.Store.SaveReconciliationRun(ctx, run); err != nil {
+		return run, fmt.Errorf("failed to update run record: %w", err)
+	}
+
+	log.Printf("[Reconciliation] Processed %s/%s: carried=%.2f, expired=%.2f",
+		entityID, assign.PolicyID, carriedOver, expired)
+
+	h.Events.Publish(ctx, LiveEvent{
+		Type:     generic.EventReconciliationCompleted,
+		EntityID: entityID,
+		Data: map[string]any{
+			"run_id": runID, "batch_id": batchID, "policy_id": assign.PolicyID,
+			"period_end":   periodEnd,
+			"carried_over": carriedOver, "expired": expired,
+		},
+	})
+
+	return run, nil
+}
+
+// trackBatch registers a batch running in this process, so the cancel
+// endpoint can stop it without waiting for the next entity.
+func (h *Handler) trackBatch(id string, cancel context.CancelFunc) {
+	h.batchesMu.Lock()
+	defer h.batchesMu.Unlock()
+	if h.batches == nil {
+		h.batches = make(map[string]context.CancelFunc)
+	}
+	h.batches[id] = cancel
+}
+
+func (h *Handler) untrackBatch(id string) {
+	h.batchesMu.Lock()
+	defer h.batchesMu.Unlock()
+	delete(h.batches, id)
+}
+
+// batchCancel returns the cancel function of a batch running in this
+// process, or nil.
+func (h *Handler) batchCancel(id string) context.CancelFunc {
+	h.batchesMu.Lock()
+	defer h.batchesMu.Unlock()
+	return h.batches[id]
+}
+
+// =============================================================================
+// RECONCILIATION ENDPOINTS
+// =============================================================================
+
+// ListReconciliationBatches returns batch runs with their summaries.
+// GET /api/reconciliation/runs
+func (h *Handler) ListReconciliationBatches(w http.ResponseWriter, r *http.Request) {
+	batches, err := h.Store.ListReconciliationBatches(r.Context())
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "Failed to get reconciliation runs", err)
+		return
+	}
+
+	dtos := make([]ReconciliationBatchDTO, 0, len(batches))
+	for _, b := range batches {
+		dtos = append(dtos, toReconciliationBatchDTO(b))
+	}
+	writeJSON(w, http.StatusOK, map[string]any{"runs": dtos})
+}
+
+// StartReconciliationBatch starts a batch run in the background and
+// returns it; poll GET /api/reconciliation/runs/{id} for progress.
+// POST /api/reconciliation/runs
+func (h *Handler) StartReconciliationBatch(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	var req StartReconciliationBatchRequest
+	if r.ContentLength > 0 {
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			writeError(w, http.StatusBadRequest, "Invalid request body", err)
+			return
+		}
+	}
+	if req.Workers < 0 || req.Workers > maxBatchWorkers {
+		writeError(w, http.StatusBadRequest, fmt.Sprintf("workers must be between 1 and %d", maxBatchWorkers), nil)
+		return
+	}
+	asOf := generic.Today()
+	if req.AsOf != "" {
+		t, err := time.Parse("2006-01-02", req.AsOf)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, "Invalid as_of date", err)
+			return
+		}
+		asOf = generic.TimePoint{Time: t}
+	}
+
+	b := newBatch("api", asOf, req.Workers)
+	if err := h.Store.SaveReconciliationBatch(ctx, b); err != nil {
+		writeError(w, http.StatusInternalServerError, "Failed to save reconciliation run", err)
+		return
+	}
+	go h.planAndExecute(context.WithoutCancel(ctx), b)
+
+	writeJSON(w, http.StatusAccepted, toReconciliationBatchDTO(b))
+}
+
+// GetReconciliationBatch returns one batch run.
+// GET /api/reconciliation/runs/{id}
+func (h *Handler) GetReconciliationBatch(w http.ResponseWriter, r *http.Request) {
+	b, ok := h.findBatch(w, r)
+	if !ok {
+		return
+	}
+	writeJSON(w, http.StatusOK, toReconciliationBatchDTO(*b))
+}
+
+// ListReconciliationBatchItems returns a batch's items, newest first.
+// GET /api/reconciliation/runs/{id}/items?status=failed
+func (h *Handler) ListReconciliationBatchItems(w http.ResponseWriter, r *http.Request) {
+	b, ok := h.findBatch(w, r)
+	if !ok {
+		return
+	}
+	runs, err := h.Store.GetReconciliationRunsByBatch(r.Context(), b.ID)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "Failed to get reconciliation items", err)
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]any{"items": toReconciliationItemDTOs(runs, r.URL.Query().Get("status"))})
+}
+
+// CancelReconciliationBatch stops a running batch after its in-flight
+// entities. The batch can be resumed.
+// POST /api/reconciliation/runs/{id}/cancel
+func (h *Handler) CancelReconciliationBatch(w http.ResponseWriter, r *http.Request) {
+	b, ok := h.findBatch(w, r)
+	if !ok {
+		return
+	}
+	if b.Status != sqlite.BatchRunning {
+		writeError(w, http.StatusConflict, "Reconciliation run is not running", nil)
+		return
+	}
+
+	// Runners on other replicas see the status before their next entity
+	b.Status = sqlite.BatchCancelled
+	b.UpdatedAt = time.Now().UTC()
+	if err := h.Store.SaveReconciliationBatch(r.Context(), *b); err != nil {
+		writeError(w, http.StatusInternalServerError, "Failed to cancel reconciliation run", err)
+		return
+	}
+	if cancel := h.batchCancel(b.ID); cancel != nil {
+		cancel()
+	}
+	writeJSON(w, http.StatusOK, toReconciliationBatchDTO(*b))
+}
+
+// ResumeReconciliationBatch continues a cancelled batch after its
+// checkpoint, in the background.
+// POST /api/reconciliation/runs/{id}/resume
+func (h *Handler) ResumeReconciliationBatch(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	b, ok := h.findBatch(w, r)
+	if !ok {
+		return
+	}
+	if b.Status != sqlite.BatchCancelled || h.batchCancel(b.ID) != nil {
+		writeError(w, http.StatusConflict, "Only a cancelled reconciliation run can be resumed", nil)
+		return
+	}
+
+	b.Status = sqlite.BatchRunning
+	b.Error = ""
+	b.UpdatedAt = time.Now().UTC()
+	if err := h.Store.SaveReconciliationBatch(ctx, *b); err != nil {
+		writeError(w, http.StatusInternalServerError, "Failed to resume reconciliation run", err)
+		return
+	}
+	go h.planAndExecute(context.WithoutCancel(ctx), *b)
+
+	writeJSON(w, http.StatusAccepted, toReconciliationBatchDTO(*b))
+}
+
+// ListReconciliationRuns returns every reconciliation item (one entity,
+// policy and period), whichever batch ran it.
+// GET /api/reconciliation/items?status=completed
+func (h *Handler) ListReconciliationRuns(w http.ResponseWriter, r *http.Request) {
+	runs, err := h.Store.GetReconciliationRuns(r.Context(), r.URL.Query().Get("status"))
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "Failed to get reconciliation runs", err)
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]any{"items": toReconciliationItemDTOs(runs, "")})
+}
+
+// findBatch loads the batch named in the URL, writing 404 if it does not
+// exist.
+func (h *Handler) findBatch(w http.ResponseWriter, r *http.Request) (*sqlite.ReconciliationBatch, bool) {
+	b, err := h.Store.GetReconciliationBatch(r.Context(), chi.URLParam(r, "id"))
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "Failed to get reconciliation run", err)
+		return nil, false
+	}
+	if b == nil {
+		writeError(w, http.StatusNotFound, "Reconciliation run not found", nil)
+		return nil, false
+	}
+	return b, true
+}
+
+func toReconciliationBatchDTO(b sqlite.ReconciliationBatch) ReconciliationBatchDTO {
+	dto := ReconciliationBatchDTO{
+		ID:          b.ID,
+		Trigger:     b.Trigger,
+		Status:      b.Status,
+		AsOf:        b.AsOf.Format("2006-01-02"),
+		Workers:     b.Workers,
+		Total:       b.Total,
+		Processed:   b.Processed,
+		Reconciled:  b.Reconciled,
+		Failed:      b.Failed,
+		CarriedOver: b.CarriedOver,
+		Expired:     b.Expired,
+		Checkpoint:  b.Checkpoint,
+		Error:       b.Error,
+		CreatedAt:   b.CreatedAt.Format(time.RFC3339),
+		UpdatedAt:   b.UpdatedAt.Format(time.RFC3339),
+	}
+	if b.CompletedAt != nil {
+		s := b.CompletedAt.Format(time.RFC3339)
+		dto.CompletedAt = &s
+	}
+	return dto
+}
+
+// toReconciliationItemDTOs converts runs, keeping those with status if set.
+func toReconciliationItemDTOs(runs []sqlite.ReconciliationRun, status string) []ReconciliationItemDTO {
+	dtos := make([]ReconciliationItemDTO, 0, len(runs))
+	for _, run := range runs {
+		if status != "" && run.Status != status {
+			continue
+		}
+		dto := ReconciliationItemDTO{
+			ID:          run.ID,
+			BatchID:     run.BatchID,
+			PolicyID:    run.PolicyID,
+			EntityID:    run.EntityID,
+			PeriodStart: run.PeriodStart.Format("2006-01-02"),
+			PeriodEnd:   run.PeriodEnd.Format("2006-01-02"),
+			Status:      run.Status,
+			CarriedOver: run.CarriedOver,
+			Expired:     run.Expired,
+			Error:       run.Error,
+		}
+		if run.CompletedAt != nil {
+			dto.CompletedAt = run.CompletedAt.Format(time.RFC3339)
+		}
+		if run.RolledBackAt != nil {
+			dto.RolledBackAt = run.RolledBackAt.Format(time.RFC3339)
+		}
+		dto.Corrections = run.Corrections
+		dto.CorrectedBy = run.CorrectedBy
+		dto.CorrectedFrom = run.CorrectedFrom
+		if run.CorrectedAt != nil {
+			dto.CorrectedAt = run.CorrectedAt.Format(time.RFC3339)
+		}
+		dtos = append(dtos, dto)
+	}
+	return dtos
+}
@@ -0,0 +1,209 @@
+/*
+reconciliation_test.go - Unit tests for batch reconciliation
+
+Tests for:
+- Batch reconciliation runs (worker pool, per-item errors, cancel, resume, retry)
+*/
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/warp/resource-engine/generic"
+	"github.com/warp/resource-engine/store/sqlite"
+	"github.com/warp/resource-engine/timeoff"
+)
+
+// gatedStore holds one entity's reconciliation until released and fails
+// another's, so a test can cancel a batch mid-entity.
+type gatedStore struct {
+	Store
+	hold    generic.EntityID
+	entered chan struct{}
+	release chan struct{}
+	fail    generic.EntityID
+}
+
+func (s *gatedStore) LoadRange(ctx context.Context, entityID generic.EntityID, policyID generic.PolicyID, from, to generic.TimePoint) ([]generic.Transaction, error) {
+	switch entityID {
+	case s.fail:
+		return nil, fmt.Errorf("ledger unavailable")
+	case s.hold:
+		s.entered <- struct{}{}
+		<-s.release
+		s.hold = "" // Only the first period waits
+	}
+	return s.Store.LoadRange(ctx, entityID, policyID, from, to)
+}
+
+func TestReconciliationBatch_CancelResumeAndSummaries(t *testing.T) {
+	// GIVEN: Six employees with PTO (12 days, carryover up to 5) since 2024,
+	//        emp-02's ledger held up and emp-04's failing
+	// WHEN: A single-worker batch is started as of Feb 1, 2026, cancelled
+	//       while emp-02 is in flight, then resumed
+	// THEN: The cancelled batch checkpoints at emp-02, the resumed one
+	//       finishes the rest, and the summary totals every entity: 10
+	//       periods reconciled, 1 failed (listed with its error), 50 days
+	//       carried and 95 expired
+	base, err := sqlite.New(":memory:")
+	if err != nil {
+		t.Fatalf("Failed to create store: %v", err)
+	}
+	defer base.Close()
+	store := &gatedStore{Store: base, hold: "emp-02", entered: make(chan struct{}), release: make(chan struct{}), fail: "emp-04"}
+	handler := NewHandler(store)
+	ctx := context.Background()
+
+	if err := handler.createPolicyFromJSON(ctx, timeoff.StandardPTOJSON("pto-test", "Test PTO", 12, 5)); err != nil {
+		t.Fatalf("Failed to create policy: %v", err)
+	}
+	since := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	for i := 1; i <= 6; i++ {
+		id := fmt.Sprintf("emp-%02d", i)
+		if err := base.SaveEmployee(ctx, sqlite.Employee{ID: id, Name: id, HireDate: since}); err != nil {
+			t.Fatalf("Failed to create employee: %v", err)
+		}
+		if err := base.SaveAssignment(ctx, sqlite.AssignmentRecord{
+			ID: "assign-" + id, EntityID: id, PolicyID: "pto-test", EffectiveFrom: since, ConsumptionPriority: 1,
+		}); err != nil {
+			t.Fatalf("Failed to save assignment: %v", err)
+		}
+	}
+
+	router := NewRouter(handler)
+	call := func(method, path, body string) ReconciliationBatchDTO {
+		t.Helper()
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, req)
+		if rec.Code != http.StatusOK && rec.Code != http.StatusAccepted {
+			t.Fatalf("%s %s: %d %s", method, path, rec.Code, rec.Body.String())
+		}
+		var dto ReconciliationBatchDTO
+		json.Unmarshal(rec.Body.Bytes(), &dto)
+		return dto
+	}
+	settled := func(id string) ReconciliationBatchDTO {
+		t.Helper()
+		deadline := time.Now().Add(5 * time.Second)
+		for handler.batchCancel(id) != nil || call(http.MethodGet, "/api/reconciliation/runs/"+id, "").Status == sqlite.BatchRunning {
+			if time.Now().After(deadline) {
+				t.Fatalf("Batch %s did not settle", id)
+			}
+			time.Sleep(5 * time.Millisecond)
+		}
+		return call(http.MethodGet, "/api/reconciliation/runs/"+id, "")
+	}
+
+	started := call(http.MethodPost, "/api/reconciliation/runs", `{"workers":1,"as_of":"2026-02-01"}`)
+	<-store.entered
+	call(http.MethodPost, "/api/reconciliation/runs/"+started.ID+"/cancel", "")
+	close(store.release)
+
+	cancelled := settled(started.ID)
+	if cancelled.Status != sqlite.BatchCancelled || cancelled.Checkpoint != "emp-02" || cancelled.Processed != 2 || cancelled.Total != 6 {
+		t.Fatalf("After cancel: %s at %q, %d/%d processed; want cancelled at emp-02, 2/6",
+			cancelled.Status, cancelled.Checkpoint, cancelled.Processed, cancelled.Total)
+	}
+
+	call(http.MethodPost, "/api/reconciliation/runs/"+started.ID+"/resume", "")
+	done := settled(started.ID)
+	if done.Status != sqlite.BatchCompleted || done.Processed != 6 || done.Total != 6 {
+		t.Errorf("After resume: %s, %d/%d processed; want completed, 6/6", done.Status, done.Processed, done.Total)
+	}
+	if done.Reconciled != 10 || done.Failed != 1 || done.CarriedOver != 50 || done.Expired != 95 {
+		t.Errorf("Summary: %d reconciled, %d failed, %.0f carried, %.0f expired; want 10, 1, 50, 95",
+			done.Reconciled, done.Failed, done.CarriedOver, done.Expired)
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/api/reconciliation/runs/"+started.ID+"/items?status=failed", nil)
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+	var failed struct {
+		Items []ReconciliationItemDTO `json:"items"`
+	}
+	json.Unmarshal(rec.Body.Bytes(), &failed)
+	if len(failed.Items) != 1 || failed.Items[0].EntityID != "emp-04" || failed.Items[0].Error == "" {
+		t.Errorf("Expected emp-04's failed item with its error, got %+v", failed.Items)
+	}
+
+	// Every period was rolled over exactly once
+	txs, _ := base.GetAllTransactions(ctx, 1000)
+	if len(txs) != 20 {
+		t.Errorf("Expected 20 rollover transactions (5 entities x 2 years x 2), got %d", len(txs))
+	}
+}
+
+func TestReconciliationRun_RetryKeepsRunID(t *testing.T) {
+	// GIVEN: A period whose reconciliation failed (ledger unavailable)
+	// WHEN: It is retried once the ledger is back
+	// THEN: The retry returns, publishes and posts under the failed run's
+	//       ID, and that item can be rolled back by it
+
+	base, err := sqlite.New(":memory:")
+	if err != nil {
+		t.Fatalf("Failed to create store: %v", err)
+	}
+	defer base.Close()
+	store := &gatedStore{Store: base, fail: "emp-retry"}
+	handler := NewHandler(store)
+	ctx := generic.WithActor(context.Background(), generic.SystemActor)
+
+	if err := handler.createPolicyFromJSON(ctx, timeoff.StandardPTOJSON("pto-test", "Test PTO", 12, 5)); err != nil {
+		t.Fatalf("Failed to create policy: %v", err)
+	}
+	since := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	if err := base.SaveEmployee(ctx, sqlite.Employee{ID: "emp-retry", Name: "Retry User", HireDate: since}); err != nil {
+		t.Fatalf("Failed to create employee: %v", err)
+	}
+	assign := sqlite.AssignmentRecord{
+		ID: "assign-retry", EntityID: "emp-retry", PolicyID: "pto-test", EffectiveFrom: since, ConsumptionPriority: 1,
+	}
+	if err := base.SaveAssignment(ctx, assign); err != nil {
+		t.Fatalf("Failed to save assignment: %v", err)
+	}
+	policy := testCache(t, handler, ctx).policies["pto-test"]
+	year := policy.PeriodConfig.PeriodFor(generic.TimePoint{Time: since})
+
+	failed, err := handler.reconcilePeriod(ctx, "", "emp-retry", assign, policy, year)
+	if err == nil || failed.Status != "failed" {
+		t.Fatalf("Expected the first attempt to fail, got %+v (%v)", failed, err)
+	}
+
+	store.fail = ""
+	sub := handler.Events.Subscribe(ctx)
+	defer sub.Close()
+	run, err := handler.reconcilePeriod(ctx, "", "emp-retry", assign, policy, year)
+	if err != nil || run.ID != failed.ID {
+		t.Fatalf("Retry: run %q (%v), want the failed run's ID %q", run.ID, err, failed.ID)
+	}
+
+	// Published before reconcilePeriod returned, so already buffered
+	var published any
+	for len(sub.Events) > 0 {
+		if e := <-sub.Events; e.Type == generic.EventReconciliationCompleted {
+			published = e.Data["run_id"]
+		}
+	}
+	if published != failed.ID {
+		t.Errorf("reconciliation.completed run_id %v, want %q", published, failed.ID)
+	}
+	events, _ := base.ListOutboxEvents(ctx, 10)
+	if len(events) == 0 || events[0].Type != generic.EventRolloverPosted || !strings.Contains(events[0].Payload, `"run_id":"`+failed.ID+`"`) {
+		t.Errorf("Expected rollover.posted with run_id %q, got %+v", failed.ID, events)
+	}
+
+	req := httptest.NewRequest(http.MethodPost, "/api/reconciliation/items/"+failed.ID+"/rollback", nil)
+	rec := httptest.NewRecorder()
+	NewRouter(handler).ServeHTTP(rec, req)
+	if rec.Code != http.StatusOK {
+		t.Errorf("Rolling back by the published run_id: %d %s, want 200", rec.Code, rec.Body.String())
+	}
+}
@@ -0,0 +1,339 @@
+/*
+rereconcile.go - Re-reconciliation after backdated transactions
+
+PURPOSE:
+  An adjustment or a cancellation can be dated in a period that was
+  already reconciled. Its carryover (and expiry) then no longer match the
+  period's balance, and the period is never planned again because its
+  run is complete. After such a write, the affected period is recomputed
+  and the difference is posted as correction transactions; when that
+  changes the carryover, the next reconciled period is recomputed too,
+  and so on down the chain.
+
+CORRECTIONS:
+  The period is proposed again (proposePeriod, which leaves out the
+  period's own rollover and correction transactions) and compared, per
+  day, with what its rollover and earlier corrections posted. Each
+  difference is one TxReconciliation with ReferenceID set to the
+  backdated transaction. Nothing already posted is reversed or edited.
+
+  Keys are "rereconcile-<entity>-<policy>-<period end>-g<generation>-
+  <correction>-<i>", so a rollback of the period reverses its
+  corrections with its rollover (rollback.go).
+
+THE CHAIN:
+  The period's reconciliation run is updated in place (there is one per
+  period): new totals, Corrections incremented, CorrectedBy the backdated
+  transaction and CorrectedFrom the run of the earlier period whose
+  correction cascaded into it. The chain stops at the first period whose
+  proposals are unchanged or that is not reconciled yet (its own
+  reconciliation will start from the corrected carryover).
+
+  Corrections of one entity's periods of one policy are serialized, per
+  tenant; only that entity and policy's runs are loaded for them.
+
+SEE ALSO:
+  - handlers.go: CreateAdjustment (effective_at), CancelTransaction
+  - reconciliation.go: proposePeriod, reconcilePeriod
+  - rollback.go: Rollover generations and reversals
+*/
+package api
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/shopspring/decimal"
+	"github.com/warp/resource-engine/generic"
+	"github.com/warp/resource-engine/store/sqlite"
+)
+
+// correctionKey is the key of the i-th transaction of a period's n-th
+// correction within a rollover generation.
+func correctionKey(entityID, policyID, periodEnd string, generation, n, i int) string {
+	return fmt.Sprintf("rereconcile-%s-%s-%s-g%d-%d-%d", entityID, policyID, periodEnd, generation, n, i)
+}
+
+// isPeriodRollover reports whether tx was posted by the reconciliation of
+// the period ending periodEnd: its rollover, a correction, or a reversal
+// of either. IDs may contain '-', so the key alone is ambiguous (entity
+// "emp" and policy "1-pto" vs "emp-1" and "pto"); the entity and policy
+// come from the transaction.
+func isPeriodRollover(tx generic.Transaction, entityID, policyID, periodEnd string) bool {
+	if string(tx.EntityID) != entityID || string(tx.PolicyID) != policyID {
+		return false
+	}
+	id := strings.TrimPrefix(string(tx.ID), rollbackKey(""))
+	suffix := fmt.Sprintf("-%s-%s-%s-", entityID, policyID, periodEnd)
+	return strings.HasPrefix(id, "rollover"+suffix) || strings.HasPrefix(id, "rereconcile"+suffix)
+}
+
+// =============================================================================
+// DETECTION
+// =============================================================================
+
+// reconcileBackdated corrects the reconciled periods the transactions are
+// dated in, and the chain after them. Failures are logged: the
+// transactions are already posted.
+func (h *Handler) reconcileBackdated(ctx context.Context, txs []generic.Transaction) {
+	// The transactions of each entity and policy
+	byAssignment := make(map[[2]string][]generic.Transaction)
+	for _, tx := range txs {
+		k := [2]string{string(tx.EntityID), string(tx.PolicyID)}
+		byAssignment[k] = append(byAssignment[k], tx)
+	}
+	keys := make([][2]string, 0, len(byAssignment))
+	for k := range byAssignment {
+		keys = append(keys, k)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if keys[i][0] != keys[j][0] {
+			return keys[i][0] < keys[j][0]
+		}
+		return keys[i][1] < keys[j][1]
+	})
+
+	for _, k := range keys {
+		if err := h.reconcileBackdatedFor(ctx, k[0], k[1], byAssignment[k]); err != nil {
+			log.Printf("[Reconciliation] Error re-reconciling %s/%s: %v", k[0], k[1], err)
+		}
+	}
+}
+
+// reconcileBackdatedFor corrects the earliest reconciled period of the
+// entity and policy that one of its transactions is dated in, and the
+// chain after it.
+func (h *Handler) reconcileBackdatedFor(ctx context.Context, entityID, policyID string, txs []generic.Transaction) error {
+	mu := h.rereconcileLock(ctx, entityID, policyID)
+	mu.Lock()
+	defer mu.Unlock()
+
+	runs, err := h.Store.GetReconciliationRunsFor(ctx, entityID, policyID)
+	if err != nil {
+		return err
+	}
+	var completed []sqlite.ReconciliationRun
+	for _, run := range runs {
+		if run.Status == "completed" {
+			completed = append(completed, run)
+		}
+	}
+
+	var target *sqlite.ReconciliationRun
+	var cause generic.Transaction
+	for _, tx := range txs {
+		for i, run := range completed {
+			if tx.EffectiveAt.Time.Before(run.PeriodStart) || tx.EffectiveAt.Time.After(run.PeriodEnd) {
+				continue
+			}
+			if target == nil || run.PeriodEnd.Before(target.PeriodEnd) {
+				target, cause = &completed[i], tx
+			}
+		}
+	}
+	if target == nil {
+		return nil
+	}
+	if _, err := h.rereconcileChain(ctx, *target, cause, completed); err != nil {
+		return fmt.Errorf("after %s: %w", cause.ID, err)
+	}
+	return nil
+}
+
+// rereconcileLock returns the mutex that serializes corrections of an
+// entity's periods of a policy in the context's tenant.
+func (h *Handler) rereconcileLock(ctx context.Context, entityID, policyID string) *sync.Mutex {
+	h.rereconcileMu.Lock()
+	defer h.rereconcileMu.Unlock()
+	if h.rereconcileLocks == nil {
+		h.rereconcileLocks = make(map[[3]string]*sync.Mutex)
+	}
+	k := [3]string{string(generic.TenantFrom(ctx)), entityID, policyID}
+	mu, ok := h.rereconcileLocks[k]
+	if !ok {
+		mu = &sync.Mutex{}
+		h.rereconcileLocks[k] = mu
+	}
+	return mu
+}
+
+// rereconcileChain corrects run's period and the reconciled periods after
+// it for as long as corrections change something. It returns the runs it
+// corrected, oldest first.
+func (h *Handler) rereconcileChain(
+	ctx context.Context,
+	run sqlite.ReconciliationRun,
+	cause generic.Transaction,
+	completed []sqlite.ReconciliationRun,
+) ([]sqlite.ReconciliationRun, error) {
+	policies := newBatchPolicies(h)
+	policy, err := policies.get(ctx, run.PolicyID)
+	if err != nil {
+		return nil, err
+	}
+	if policy == nil {
+		return nil, fmt.Errorf("policy %s not found", run.PolicyID)
+	}
+	assign, err := h.assignmentFor(ctx, run.EntityID, run.PolicyID, run.PeriodEnd)
+	if err != nil {
+		return nil, err
+	}
+
+	var corrected []sqlite.ReconciliationRun
+	from := ""
+	for {
+		period := policy.PeriodConfig.PeriodFor(generic.TimePoint{Time: run.PeriodEnd})
+		updated, changed, err := h.rereconcilePeriod(ctx, run, assign, policy, period, cause, from)
+		if err != nil || !changed {
+			return corrected, err
+		}
+		corrected = append(corrected, updated)
+
+		// The next period starts from the corrected carryover
+		next := policy.PeriodConfig.PeriodFor(period.End.AddDays(1))
+		found := false
+		for _, other := range completed {
+			if other.EntityID == run.EntityID && other.PolicyID == run.PolicyID && other.PeriodEnd.Equal(next.End.Time) {
+				run, found = other, true
+				break
+			}
+		}
+		if !found {
+			return corrected, nil
+		}
+		from = updated.ID
+	}
+}
+
+// assignmentFor returns the entity's latest assignment of the policy that
+// started on or before the day.
+func (h *Handler) assignmentFor(ctx context.Context, entityID, policyID string, day time.Time) (sqlite.AssignmentRecord, error) {
+	assignments, err := h.Store.GetAssignmentsByEntity(ctx, entityID)
+	if err != nil {
+		return sqlite.AssignmentRecord{}, err
+	}
+	var found *sqlite.AssignmentRecord
+	for i, a := range assignments {
+		if a.PolicyID != policyID || a.EffectiveFrom.After(day) {
+			continue
+		}
+		if found == nil || a.EffectiveFrom.After(found.EffectiveFrom) {
+			found = &assignments[i]
+		}
+	}
+	if found == nil {
+		return sqlite.AssignmentRecord{}, fmt.Errorf("no assignment of %s to %s", policyID, entityID)
+	}
+	return *found, nil
+}
+
+// =============================================================================
+// CORRECTION
+// =============================================================================
+
+// rereconcilePeriod posts the difference between what the period's
+// reconciliation proposes now and what it has posted, and updates its run.
+// changed is false when there was nothing to post.
+func (h *Handler) rereconcilePeriod(
+	ctx context.Context,
+	run sqlite.ReconciliationRun,
+	assign sqlite.AssignmentRecord,
+	policy *generic.Policy,
+	period generic.Period,
+	cause generic.Transaction,
+	correctedFrom string,
+) (sqlite.ReconciliationRun, bool, error) {
+	_, output, err := h.proposePeriod(ctx, run.EntityID, assign, policy, period, nil)
+	if err != nil {
+		return run, false, err
+	}
+
+	// What the rollover and earlier corrections posted, per day; the
+	// carryover is in the next period
+	periodEnd := period.End.Time.Format("2006-01-02")
+	next := policy.PeriodConfig.PeriodFor(period.End.AddDays(1))
+	txs, err := h.Store.LoadRange(ctx, generic.EntityID(run.EntityID), generic.PolicyID(run.PolicyID), period.Start, next.End)
+	if err != nil {
+		return run, false, err
+	}
+	diff := make(map[string]decimal.Decimal)
+	for _, tx := range output.Transactions {
+		day := tx.EffectiveAt.Time.Format("2006-01-02")
+		diff[day] = diff[day].Add(tx.Delta.Value)
+	}
+	for _, tx := range txs {
+		if isPeriodRollover(tx, run.EntityID, run.PolicyID, periodEnd) {
+			day := tx.EffectiveAt.Time.Format("2006-01-02")
+			diff[day] = diff[day].Sub(tx.Delta.Value)
+		}
+	}
+	days := make([]string, 0, len(diff))
+	for day, delta := range diff {
+		if !delta.IsZero() {
+			days = append(days, day)
+		}
+	}
+	if len(days) == 0 {
+		return run, false, nil
+	}
+	sort.Strings(days)
+
+	generation, err := h.rolloverGeneration(ctx, run.EntityID, run.PolicyID, periodEnd)
+	if err != nil {
+		return run, false, err
+	}
+	n := run.Corrections + 1
+	for {
+		// A rollback resets Corrections but not a generation without
+		// rollover transactions
+		tx, err := h.Store.GetTransaction(ctx, correctionKey(run.EntityID, run.PolicyID, periodEnd, generation, n, 0))
+		if err != nil {
+			return run, false, err
+		}
+		if tx == nil {
+			break
+		}
+		n++
+	}
+	corrections := make([]generic.Transaction, len(days))
+	for i, day := range days {
+		at, _ := time.Parse("2006-01-02", day)
+		key := correctionKey(run.EntityID, run.PolicyID, periodEnd, generation, n, i)
+		corrections[i] = generic.Transaction{
+			ID:             generic.TransactionID(key),
+			EntityID:       generic.EntityID(run.EntityID),
+			PolicyID:       generic.PolicyID(run.PolicyID),
+			ResourceType:   policy.ResourceType,
+			EffectiveAt:    generic.TimePoint{Time: at},
+			Delta:          generic.Amount{Value: diff[day], Unit: policy.Unit},
+			Type:           generic.TxReconciliation,
+			ReferenceID:    string(cause.ID),
+			Reason:         fmt.Sprintf("re-reconciliation of period ending %s after %s", periodEnd, cause.ID),
+			IdempotencyKey: key,
+		}
+	}
+	event := map[string]any{"run_id": run.ID, "period_end": periodEnd, "caused_by": string(cause.ID)}
+	if err := h.appendWithEvent(ctx, corrections, generic.EventRolloverCorrected, event); err != nil {
+		return run, false, err
+	}
+
+	now := time.Now().UTC()
+	run.CarriedOver, _ = output.Summary.CarriedOver.Value.Float64()
+	run.Expired, _ = output.Summary.Expired.Value.Float64()
+	run.Corrections = n
+	run.CorrectedAt = &now
+	run.CorrectedBy = string(cause.ID)
+	run.CorrectedFrom = correctedFrom
+	if err := h.Store.SaveReconciliationRun(ctx, run); err != nil {
+		return run, true, fmt.Errorf("failed to update run record: %w", err)
+	}
+
+	log.Printf("[Reconciliation] Corrected %s/%s %s after %s: carried=%.2f, expired=%.2f",
+		run.EntityID, run.PolicyID, periodEnd, cause.ID, run.CarriedOver, run.Expired)
+	return run, true, nil
+}
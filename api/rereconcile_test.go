@@ -0,0 +1,239 @@
+/*
+rereconcile_test.go - Unit tests for re-reconciliation
+
+Tests for:
+- Re-reconciliation after backdated writes (reconcileBackdated, chain)
+- Rollover key matching (isPeriodRollover)
+*/
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/shopspring/decimal"
+	"github.com/warp/resource-engine/generic"
+	"github.com/warp/resource-engine/store/sqlite"
+	"github.com/warp/resource-engine/timeoff"
+)
+
+func TestIsPeriodRollover_HyphenatedIDsAreNotConfused(t *testing.T) {
+	// GIVEN: Rollover keys of entity "emp-1" with policy "pto", which read
+	//        the same as entity "emp" with policy "1-pto"
+	// WHEN: Checking them against each pair
+	// THEN: Only the transaction's own entity and policy match
+
+	rollover := generic.Transaction{
+		ID:       generic.TransactionID(rolloverKey("emp-1", "pto", "2025-12-31", 0, 0)),
+		EntityID: "emp-1", PolicyID: "pto",
+	}
+	correction := generic.Transaction{
+		ID:       generic.TransactionID(correctionKey("emp-1", "pto", "2025-12-31", 1, 0, 0)),
+		EntityID: "emp-1", PolicyID: "pto",
+	}
+	reversal := generic.Transaction{
+		ID:       generic.TransactionID(rollbackKey(string(rollover.ID))),
+		EntityID: "emp-1", PolicyID: "pto",
+	}
+	for _, tx := range []generic.Transaction{rollover, correction, reversal} {
+		if !isPeriodRollover(tx, "emp-1", "pto", "2025-12-31") {
+			t.Errorf("%s: expected a rollover of emp-1/pto", tx.ID)
+		}
+		if isPeriodRollover(tx, "emp", "1-pto", "2025-12-31") {
+			t.Errorf("%s: matched emp/1-pto", tx.ID)
+		}
+		if isPeriodRollover(tx, "emp-1", "pto", "2024-12-31") {
+			t.Errorf("%s: matched another period", tx.ID)
+		}
+	}
+}
+
+func TestRereconciliation_BackdatedAdjustmentCorrectsChain(t *testing.T) {
+	// GIVEN: PTO of 12 days a year (carryover cap 5) since 2024, with 2024
+	//        and 2025 reconciled: 5 carried and 7, then 12, expired
+	// WHEN: A -10 adjustment is backdated to June 2024, then 2025 is
+	//       rolled back
+	// THEN: 2024 is corrected to carry 2 and expire nothing, 2025 (now
+	//       starting from 2) to expire 9, the items record the chain and
+	//       the corrections reference the adjustment; the rollback
+	//       reverses 2025's corrections with its rollover
+
+	handler := setupTestHandler(t)
+	ctx := context.Background()
+
+	if err := handler.createPolicyFromJSON(ctx, timeoff.StandardPTOJSON("pto-test", "Test PTO", 12, 5)); err != nil {
+		t.Fatalf("Failed to create policy: %v", err)
+	}
+	since := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	if err := handler.Store.SaveEmployee(ctx, sqlite.Employee{ID: "emp-fix", Name: "Fix User", HireDate: since}); err != nil {
+		t.Fatalf("Failed to create employee: %v", err)
+	}
+	if err := handler.Store.SaveAssignment(ctx, sqlite.AssignmentRecord{
+		ID: "assign-fix", EntityID: "emp-fix", PolicyID: "pto-test",
+		EffectiveFrom: since, ConsumptionPriority: 1,
+	}); err != nil {
+		t.Fatalf("Failed to save assignment: %v", err)
+	}
+
+	scheduler := NewReconciliationScheduler(handler.Store, handler)
+	scheduler.Now = func() time.Time { return time.Date(2026, 2, 1, 9, 0, 0, 0, time.UTC) }
+	scheduler.RunNow()
+
+	router := NewRouter(handler)
+	body := `{"entity_id":"emp-fix","policy_id":"pto-test","delta":-10,"reason":"payroll fix","effective_at":"2024-06-01"}`
+	req := httptest.NewRequest(http.MethodPost, "/api/admin/adjustments", strings.NewReader(body))
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+	var adj TransactionDTO
+	json.Unmarshal(rec.Body.Bytes(), &adj)
+	if rec.Code != http.StatusCreated || !strings.HasPrefix(adj.EffectiveAt, "2024-06-01") {
+		t.Fatalf("Backdated adjustment: %d %s", rec.Code, rec.Body.String())
+	}
+
+	items := make(map[int]sqlite.ReconciliationRun)
+	completed, _ := handler.Store.GetReconciliationRuns(ctx, "completed")
+	for _, r := range completed {
+		items[r.PeriodEnd.Year()] = r
+	}
+	if len(items) != 2 {
+		t.Fatalf("Expected 2024 and 2025 to stay completed, got %+v", completed)
+	}
+	first, second := items[2024], items[2025]
+	if first.CarriedOver != 2 || first.Expired != 0 || first.Corrections != 1 || first.CorrectedBy != adj.ID || first.CorrectedFrom != "" {
+		t.Errorf("2024: carried %.0f expired %.0f corrections %d by %q from %q; want 2, 0, 1 by %s",
+			first.CarriedOver, first.Expired, first.Corrections, first.CorrectedBy, first.CorrectedFrom, adj.ID)
+	}
+	if second.CarriedOver != 5 || second.Expired != 9 || second.Corrections != 1 || second.CorrectedBy != adj.ID || second.CorrectedFrom != first.ID {
+		t.Errorf("2025: carried %.0f expired %.0f corrections %d by %q from %q; want 5, 9, 1 by %s from %s",
+			second.CarriedOver, second.Expired, second.Corrections, second.CorrectedBy, second.CorrectedFrom, adj.ID, first.ID)
+	}
+
+	// Per day, rollover plus corrections now match a clean reconciliation
+	want := map[string]int64{"2024-12-31": 0, "2025-01-01": 2, "2025-12-31": -9, "2026-01-01": 5}
+	txs, _ := handler.Store.GetAllTransactions(ctx, 100)
+	got := make(map[string]decimal.Decimal)
+	corrections := 0
+	for _, tx := range txs {
+		if tx.Type != generic.TxReconciliation {
+			continue
+		}
+		day := tx.EffectiveAt.Time.Format("2006-01-02")
+		got[day] = got[day].Add(tx.Delta.Value)
+		if strings.HasPrefix(string(tx.ID), "rereconcile-") {
+			corrections++
+			if tx.ReferenceID != adj.ID {
+				t.Errorf("%s: reference %q, want the adjustment %s", tx.ID, tx.ReferenceID, adj.ID)
+			}
+		}
+	}
+	for day, net := range want {
+		if !got[day].Equal(decimal.NewFromInt(net)) {
+			t.Errorf("%s: net reconciliation %s, want %d", day, got[day], net)
+		}
+	}
+	if corrections != 3 {
+		t.Errorf("Expected 3 corrections (2024 expiry and carryover, 2025 expiry), got %d", corrections)
+	}
+
+	rec = httptest.NewRecorder()
+	router.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/api/reconciliation/items/"+second.ID+"/rollback", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Rolling back 2025: %d %s", rec.Code, rec.Body.String())
+	}
+	txs, _ = handler.Store.GetAllTransactions(ctx, 100)
+	net := decimal.Zero
+	for _, tx := range txs {
+		if isPeriodRollover(tx, "emp-fix", "pto-test", "2025-12-31") {
+			net = net.Add(tx.Delta.Value)
+		}
+	}
+	if !net.IsZero() {
+		t.Errorf("Expected 2025's rollover and corrections reversed, net %s", net)
+	}
+}
+
+// allRunsStore counts loads of every reconciliation run of the tenant.
+type allRunsStore struct {
+	Store
+	loads int
+}
+
+func (s *allRunsStore) GetReconciliationRuns(ctx context.Context, status string) ([]sqlite.ReconciliationRun, error) {
+	s.loads++
+	return s.Store.GetReconciliationRuns(ctx, status)
+}
+
+func TestRereconciliation_OnlyTheEntityAndPolicyAreLoadedAndLocked(t *testing.T) {
+	// GIVEN: Two employees with 2024 and 2025 reconciled, and a correction
+	//        of the first one's periods in progress
+	// WHEN: An adjustment of the second one is backdated to June 2024
+	// THEN: It does not wait for the first one's correction, corrects the
+	//       second one's 2024 period, and never loads the tenant's runs
+
+	handler := setupTestHandler(t)
+	ctx := context.Background()
+
+	if err := handler.createPolicyFromJSON(ctx, timeoff.StandardPTOJSON("pto-test", "Test PTO", 12, 5)); err != nil {
+		t.Fatalf("Failed to create policy: %v", err)
+	}
+	since := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	for _, id := range []string{"emp-busy", "emp-fix"} {
+		if err := handler.Store.SaveEmployee(ctx, sqlite.Employee{ID: id, Name: id, HireDate: since}); err != nil {
+			t.Fatalf("Failed to create employee: %v", err)
+		}
+		if err := handler.Store.SaveAssignment(ctx, sqlite.AssignmentRecord{
+			ID: "assign-" + id, EntityID: id, PolicyID: "pto-test",
+			EffectiveFrom: since, ConsumptionPriority: 1,
+		}); err != nil {
+			t.Fatalf("Failed to save assignment: %v", err)
+		}
+	}
+	scheduler := NewReconciliationScheduler(handler.Store, handler)
+	scheduler.Now = func() time.Time { return time.Date(2026, 2, 1, 9, 0, 0, 0, time.UTC) }
+	scheduler.RunNow()
+
+	store := &allRunsStore{Store: handler.Store}
+	handler.Store = store
+	busy := handler.rereconcileLock(ctx, "emp-busy", "pto-test")
+	busy.Lock()
+	defer busy.Unlock()
+
+	router := NewRouter(handler)
+	done := make(chan *httptest.ResponseRecorder)
+	go func() {
+		body := `{"entity_id":"emp-fix","policy_id":"pto-test","delta":-10,"reason":"payroll fix","effective_at":"2024-06-01"}`
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/api/admin/adjustments", strings.NewReader(body)))
+		done <- rec
+	}()
+	select {
+	case rec := <-done:
+		if rec.Code != http.StatusCreated {
+			t.Fatalf("Backdated adjustment: %d %s", rec.Code, rec.Body.String())
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("The adjustment waited for another employee's correction")
+	}
+
+	runs, err := store.GetReconciliationRunsFor(ctx, "emp-fix", "pto-test")
+	if err != nil {
+		t.Fatalf("Failed to get runs: %v", err)
+	}
+	corrected := 0
+	for _, r := range runs {
+		if r.PeriodEnd.Year() == 2024 {
+			corrected = r.Corrections
+		}
+	}
+	if corrected != 1 {
+		t.Errorf("Expected emp-fix's 2024 period corrected once, got %d (%+v)", corrected, runs)
+	}
+	if store.loads != 0 {
+		t.Errorf("Expected no loads of every run, got %d", store.loads)
+	}
+}
@@ -0,0 +1,257 @@
+/*
+rollback.go - Rolling back reconciliation runs
+
+PURPOSE:
+  Undoes a rollover that ran with the wrong policy config, without
+  hand-crafted adjustments. Rolling back a reconciliation run (an item)
+  posts one reversing transaction per reconciliation transaction it
+  created and marks the run rolled_back; rolling back a batch does that
+  for each of its completed items. Nothing is deleted: the ledger keeps
+  the rollover and its reversal.
+
+REVERSALS:
+  A reversal is a TxReconciliation with the negated delta, on the same
+  day as the transaction it reverses (ReferenceID). The run's corrections
+  after backdated transactions (rereconcile.go) are reversed with it. Its ID and
+  idempotency key are "rollback-" + that transaction's ID, so a rollback
+  retried or run twice posts nothing more.
+
+RE-RUNS:
+  A rolled back run no longer counts as complete, so the period is
+  planned again by the next batch or scheduler check, or can be rolled
+  over by hand. Rollover keys carry the period's generation (how many
+  times it was rolled back), so the re-run posts new transactions
+  instead of colliding with the reversed ones.
+
+ORDER:
+  Each period starts from the carryover of the one before, so only an
+  assignment's latest completed period can be rolled back. A batch rolls
+  back its items newest period first.
+
+ENDPOINTS:
+  POST /api/reconciliation/items/{id}/rollback  One run
+  POST /api/reconciliation/runs/{id}/rollback   Every completed item of a batch
+
+SEE ALSO:
+  - reconciliation.go: Batches and reconcilePeriod
+  - handlers.go: keyRollover, CancelTransaction (reversal of one day)
+*/
+package api
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/warp/resource-engine/generic"
+	"github.com/warp/resource-engine/store/sqlite"
+)
+
+// Rollback errors the endpoints report as 409 Conflict.
+var (
+	errRunNotCompleted = errors.New("only a completed reconciliation run can be rolled back")
+	errLaterRunExists  = errors.New("a later period of this assignment is reconciled; roll that back first")
+)
+
+// =============================================================================
+// GENERATIONS
+// =============================================================================
+
+// rolloverGeneration returns the generation of a period's rollover keys:
+// the number of earlier rollovers of the period that were rolled back.
+func (h *Handler) rolloverGeneration(ctx context.Context, entityID, policyID, periodEnd string) (int, error) {
+	for generation := 0; ; generation++ {
+		key := rolloverKey(entityID, policyID, periodEnd, generation, 0)
+		tx, err := h.Store.GetTransaction(ctx, key)
+		if err != nil || tx == nil {
+			return generation, err
+		}
+		reversal, err := h.Store.GetTransaction(ctx, rollbackKey(key))
+		if err != nil || reversal == nil {
+			return generation, err
+		}
+	}
+}
+
+// rollbackKey is the ID and idempotency key of a transaction's reversal.
+func rollbackKey(txID string) string {
+	return "rollback-" + txID
+}
+
+// =============================================================================
+// ROLLBACK
+// =============================================================================
+
+// rollbackRun reverses the rollover transactions of a completed run and
+// marks it rolled back.
+func (h *Handler) rollbackRun(ctx context.Context, run sqlite.ReconciliationRun) (sqlite.ReconciliationRun, error) {
+	if run.Status != "completed" {
+		return run, errRunNotCompleted
+	}
+	others, err := h.Store.GetReconciliationRunsFor(ctx, run.EntityID, run.PolicyID)
+	if err != nil {
+		return run, err
+	}
+	for _, other := range others {
+		if other.Status == "completed" && other.PeriodEnd.After(run.PeriodEnd) {
+			return run, errLaterRunExists
+		}
+	}
+
+	// The run's transactions are the current generation of its keys
+	periodEnd := run.PeriodEnd.Format("2006-01-02")
+	generation, err := h.rolloverGeneration(ctx, run.EntityID, run.PolicyID, periodEnd)
+	if err != nil {
+		return run, err
+	}
+	var posted []*generic.Transaction
+	for i := 0; ; i++ {
+		tx, err := h.Store.GetTransaction(ctx, rolloverKey(run.EntityID, run.PolicyID, periodEnd, generation, i))
+		if err != nil {
+			return run, err
+		}
+		if tx == nil {
+			break
+		}
+		posted = append(posted, tx)
+	}
+	// Corrections after backdated transactions (rereconcile.go)
+	for n := 1; n <= run.Corrections; n++ {
+		for i := 0; ; i++ {
+			tx, err := h.Store.GetTransaction(ctx, correctionKey(run.EntityID, run.PolicyID, periodEnd, generation, n, i))
+			if err != nil {
+				return run, err
+			}
+			if tx == nil {
+				break
+			}
+			posted = append(posted, tx)
+		}
+	}
+
+	var reversals []generic.Transaction
+	for _, tx := range posted {
+		if tx.Type != generic.TxReconciliation {
+			continue
+		}
+		// Corrections of a generation with no rollover transactions can
+		// outlive an earlier rollback
+		if reversed, err := h.Store.GetTransaction(ctx, rollbackKey(string(tx.ID))); err != nil {
+			return run, err
+		} else if reversed != nil {
+			continue
+		}
+		key := rollbackKey(string(tx.ID))
+		reversals = append(reversals, generic.Transaction{
+			ID:             generic.TransactionID(key),
+			EntityID:       tx.EntityID,
+			PolicyID:       tx.PolicyID,
+			ResourceType:   tx.ResourceType,
+			EffectiveAt:    tx.EffectiveAt,
+			Delta:          tx.Delta.Neg(),
+			Type:           generic.TxReconciliation,
+			ReferenceID:    string(tx.ID),
+			Reason:         fmt.Sprintf("rollback of reconciliation run %s", run.ID),
+			IdempotencyKey: key,
+		})
+	}
+
+	// A duplicate key means a concurrent rollback already posted them
+	if len(reversals) > 0 {
+		event := map[string]any{"run_id": run.ID, "period_end": periodEnd}
+		err := h.appendWithEvent(ctx, reversals, generic.EventRolloverRolledBack, event)
+		if err != nil && !errors.Is(err, generic.ErrDuplicateIdempotencyKey) {
+			return run, err
+		}
+	}
+
+	now := time.Now().UTC()
+	run.Status = "rolled_back"
+	run.RolledBackAt = &now
+	if err := h.Store.SaveReconciliationRun(ctx, run); err != nil {
+		return run, fmt.Errorf("failed to update run record: %w", err)
+	}
+	return run, nil
+}
+
+// =============================================================================
+// ROLLBACK ENDPOINTS
+// =============================================================================
+
+// RollbackReconciliationRun rolls back one reconciliation run.
+// POST /api/reconciliation/items/{id}/rollback
+func (h *Handler) RollbackReconciliationRun(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	run, err := h.Store.GetReconciliationRun(ctx, chi.URLParam(r, "id"))
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "Failed to get reconciliation item", err)
+		return
+	}
+	if run == nil {
+		writeError(w, http.StatusNotFound, "Reconciliation item not found", nil)
+		return
+	}
+
+	rolledBack, err := h.rollbackRun(ctx, *run)
+	if errors.Is(err, errRunNotCompleted) || errors.Is(err, errLaterRunExists) {
+		writeError(w, http.StatusConflict, err.Error(), nil)
+		return
+	}
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "Failed to roll back reconciliation item", err)
+		return
+	}
+	writeJSON(w, http.StatusOK, toReconciliationItemDTOs([]sqlite.ReconciliationRun{rolledBack}, "")[0])
+}
+
+// RollbackReconciliationBatch rolls back every completed item of a batch
+// that is not running, newest period first. Items that cannot be rolled
+// back are listed with their error; the batch is marked rolled_back only
+// if none failed.
+// POST /api/reconciliation/runs/{id}/rollback
+func (h *Handler) RollbackReconciliationBatch(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	b, ok := h.findBatch(w, r)
+	if !ok {
+		return
+	}
+	if b.Status == sqlite.BatchRunning || h.batchCancel(b.ID) != nil {
+		writeError(w, http.StatusConflict, "Cancel the reconciliation run before rolling it back", nil)
+		return
+	}
+
+	runs, err := h.Store.GetReconciliationRunsByBatch(ctx, b.ID)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "Failed to get reconciliation items", err)
+		return
+	}
+	sort.SliceStable(runs, func(i, j int) bool { return runs[i].PeriodEnd.After(runs[j].PeriodEnd) })
+
+	result := RollbackResultDTO{RolledBack: []ReconciliationItemDTO{}, Errors: []RollbackErrorDTO{}}
+	for _, run := range runs {
+		if run.Status != "completed" {
+			continue
+		}
+		rolledBack, err := h.rollbackRun(ctx, run)
+		if err != nil {
+			result.Errors = append(result.Errors, RollbackErrorDTO{ItemID: run.ID, Error: err.Error()})
+			continue
+		}
+		result.RolledBack = append(result.RolledBack, toReconciliationItemDTOs([]sqlite.ReconciliationRun{rolledBack}, "")...)
+	}
+
+	if len(result.Errors) == 0 {
+		b.Status = sqlite.BatchRolledBack
+		b.UpdatedAt = time.Now().UTC()
+		if err := h.Store.SaveReconciliationBatch(ctx, *b); err != nil {
+			writeError(w, http.StatusInternalServerError, "Failed to update reconciliation run", err)
+			return
+		}
+	}
+	result.Batch = toReconciliationBatchDTO(*b)
+	writeJSON(w, http.StatusOK, result)
+}
@@ -0,0 +1,124 @@
+/*
+rollback_test.go - Unit tests for reconciliation rollback
+
+Tests for:
+- Reconciliation rollback (rollbackRun, rollover key generations, re-run)
+*/
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/shopspring/decimal"
+	"github.com/warp/resource-engine/generic"
+	"github.com/warp/resource-engine/store/sqlite"
+	"github.com/warp/resource-engine/timeoff"
+)
+
+func TestReconciliationRollback_ReversesAndAllowsCleanReRun(t *testing.T) {
+	// GIVEN: PTO of 12 days a year assigned since 2023, reconciled up to
+	//        2025 by the scheduler with the wrong carryover cap (10)
+	// WHEN: The oldest item is rolled back, then the whole batch, then the
+	//       cap is fixed (5) and the scheduler runs again
+	// THEN: The oldest item is refused while later periods stand, the
+	//       batch rollback reverses all six transactions and marks its
+	//       items rolled back, and the re-run posts under new keys, leaving
+	//       only the corrected carryover of 5 days into 2026
+
+	handler := setupTestHandler(t)
+	ctx := context.Background()
+
+	if err := handler.createPolicyFromJSON(ctx, timeoff.StandardPTOJSON("pto-test", "Test PTO", 12, 10)); err != nil {
+		t.Fatalf("Failed to create policy: %v", err)
+	}
+	since := time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC)
+	if err := handler.Store.SaveEmployee(ctx, sqlite.Employee{ID: "emp-back", Name: "Back User", HireDate: since}); err != nil {
+		t.Fatalf("Failed to create employee: %v", err)
+	}
+	if err := handler.Store.SaveAssignment(ctx, sqlite.AssignmentRecord{
+		ID: "assign-back", EntityID: "emp-back", PolicyID: "pto-test",
+		EffectiveFrom: since, ConsumptionPriority: 1,
+	}); err != nil {
+		t.Fatalf("Failed to save assignment: %v", err)
+	}
+
+	scheduler := NewReconciliationScheduler(handler.Store, handler)
+	scheduler.Now = func() time.Time { return time.Date(2026, 2, 1, 9, 0, 0, 0, time.UTC) }
+	scheduler.RunNow()
+
+	batches, _ := handler.Store.ListReconciliationBatches(ctx)
+	if len(batches) != 1 {
+		t.Fatalf("Expected one scheduler batch, got %d", len(batches))
+	}
+	runs, _ := handler.Store.GetReconciliationRunsByBatch(ctx, batches[0].ID)
+	var oldest sqlite.ReconciliationRun
+	for _, r := range runs {
+		if r.PeriodEnd.Year() == 2023 {
+			oldest = r
+		}
+	}
+
+	router := NewRouter(handler)
+	post := func(path string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, path, nil)
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, req)
+		return rec
+	}
+
+	if rec := post("/api/reconciliation/items/" + oldest.ID + "/rollback"); rec.Code != http.StatusConflict {
+		t.Errorf("Rolling back 2023 before 2024: %d, want 409", rec.Code)
+	}
+
+	rec := post("/api/reconciliation/runs/" + batches[0].ID + "/rollback")
+	var result RollbackResultDTO
+	json.Unmarshal(rec.Body.Bytes(), &result)
+	if rec.Code != http.StatusOK || len(result.RolledBack) != 3 || len(result.Errors) != 0 || result.Batch.Status != sqlite.BatchRolledBack {
+		t.Fatalf("Batch rollback: %d %s", rec.Code, rec.Body.String())
+	}
+	for _, item := range result.RolledBack {
+		if item.Status != "rolled_back" || item.RolledBackAt == "" {
+			t.Errorf("Item %s: %s at %q, want rolled_back with a time", item.PeriodEnd, item.Status, item.RolledBackAt)
+		}
+	}
+
+	txs, _ := handler.Store.GetAllTransactions(ctx, 100)
+	net := decimal.Zero
+	for _, tx := range txs {
+		if tx.Type != generic.TxReconciliation {
+			t.Errorf("%s: type %s, want reconciliation", tx.ID, tx.Type)
+		}
+		net = net.Add(tx.Delta.Value)
+	}
+	if len(txs) != 12 || !net.IsZero() {
+		t.Errorf("Expected 6 rollovers and 6 reversals netting to 0, got %d netting to %s", len(txs), net)
+	}
+	if rec := post("/api/reconciliation/items/" + oldest.ID + "/rollback"); rec.Code != http.StatusConflict {
+		t.Errorf("Rolling back a rolled back item: %d, want 409", rec.Code)
+	}
+
+	if err := handler.createPolicyFromJSON(ctx, timeoff.StandardPTOJSON("pto-test", "Test PTO", 12, 5)); err != nil {
+		t.Fatalf("Failed to update policy: %v", err)
+	}
+	scheduler.RunNow()
+
+	completed, _ := handler.Store.GetReconciliationRuns(ctx, "completed")
+	if len(completed) != 3 {
+		t.Fatalf("Expected the 3 periods to be reconciled again, got %d", len(completed))
+	}
+	txs, _ = handler.Store.GetAllTransactions(ctx, 100)
+	into2026 := decimal.Zero
+	for _, tx := range txs {
+		if tx.EffectiveAt.Time.Equal(time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)) {
+			into2026 = into2026.Add(tx.Delta.Value)
+		}
+	}
+	if len(txs) != 18 || !into2026.Equal(decimal.NewFromInt(5)) {
+		t.Errorf("Expected 18 transactions and a net carryover of 5 into 2026, got %d and %s", len(txs), into2026)
+	}
+}
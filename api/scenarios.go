@@ -107,14 +107,20 @@ func (h *Handler) ListScenarios(w http.ResponseWriter, r *http.Request) {
 
 // GetCurrentScenario returns the currently loaded scenario, if any.
 func (h *Handler) GetCurrentScenario(w http.ResponseWriter, r *http.Request) {
-	if h.currentScenario == "" {
+	cache, err := h.cache(r.Context())
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "Failed to load policies", err)
+		return
+	}
+	current := cache.scenario
+	if current == "" {
 		writeJSON(w, http.StatusOK, nil)
 		return
 	}
 
 	// Find the scenario details
 	for _, s := range scenarios {
-		if s.ID == h.currentScenario {
+		if s.ID == current {
 			writeJSON(w, http.StatusOK, s)
 			return
 		}
@@ -122,8 +128,8 @@ func (h *Handler) GetCurrentScenario(w http.ResponseWriter, r *http.Request) {
 
 	// Scenario ID exists but not in list (shouldn't happen)
 	writeJSON(w, http.StatusOK, ScenarioDTO{
-		ID:          h.currentScenario,
-		Name:        h.currentScenario,
+		ID:          current,
+		Name:        current,
 		Description: "Currently loaded scenario",
 	})
 }
@@ -145,9 +151,7 @@ func (h *Handler) LoadScenario(w http.ResponseWriter, r *http.Request) {
 		writeError(w, http.StatusInternalServerError, "Failed to reset database", err)
 		return
 	}
-	h.policies = make(map[generic.PolicyID]*generic.Policy)
-	h.accruals = make(map[generic.PolicyID]generic.AccrualSchedule)
-	h.currentScenario = "" // Clear current scenario on reset
+	h.resetCache(ctx) // Also clears the current scenario
 
 	var err error
 	switch req.ScenarioID {
@@ -174,7 +178,12 @@ func (h *Handler) LoadScenario(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Track the loaded scenario
-	h.currentScenario = req.ScenarioID
+	cache, err := h.cache(ctx)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "Failed to load policies", err)
+		return
+	}
+	cache.scenario = req.ScenarioID
 
 	writeJSON(w, http.StatusOK, map[string]string{"status": "loaded", "scenario": req.ScenarioID})
 }
@@ -217,11 +226,15 @@ func (h *Handler) loadNewEmployeeScenario(ctx context.Context) error {
 	}
 
 	// Get policy and accrual schedule for rollover calculation
-	policy, ok := h.policies[generic.PolicyID("pto-standard")]
+	cache, err := h.cache(ctx)
+	if err != nil {
+		return err
+	}
+	policy, ok := cache.policies[generic.PolicyID("pto-standard")]
 	if !ok {
 		return fmt.Errorf("policy not found")
 	}
-	accrual := h.accruals[policy.ID]
+	accrual := cache.accruals[policy.ID]
 
 	// Process year-end rollover for 2025
 	// Accruals are computed on-demand from AccrualSchedule, not stored as transactions
@@ -235,7 +248,7 @@ func (h *Handler) loadNewEmployeeScenario(ctx context.Context) error {
 	// Balance calculation uses AccrualSchedule.GenerateAccruals() for computed accruals
 	// IMPORTANT: Use hire date for prorating - new employee only accrues from their start date
 	hireDateTP := generic.TimePoint{Time: hireDate}
-	balance2025 := calculateBalanceWithHireDate(nil, period2025, policy.Unit, accrual, generic.TimePoint{Time: yearEnd2025}, hireDateTP)
+	balance2025 := generic.ComputeBalance(nil, period2025, policy.Unit, accrual, hireDateTP, generic.TimePoint{Time: yearEnd2025})
 	balance2025.EntityID = generic.EntityID("emp-001")
 	balance2025.PolicyID = policy.ID
 
@@ -334,11 +347,15 @@ func (h *Handler) loadYearEndRolloverScenario(ctx context.Context) error {
 	}
 
 	// Get policy and accrual schedule
-	policy, ok := h.policies[generic.PolicyID("pto-rollover")]
+	cache, err := h.cache(ctx)
+	if err != nil {
+		return err
+	}
+	policy, ok := cache.policies[generic.PolicyID("pto-rollover")]
 	if !ok {
 		return fmt.Errorf("policy not found after creation")
 	}
-	accrual := h.accruals[policy.ID]
+	accrual := cache.accruals[policy.ID]
 
 	// Last year: Only consumption transactions (accruals are computed on-demand)
 	lastYear := time.Now().Year() - 1
@@ -382,7 +399,7 @@ func (h *Handler) loadYearEndRolloverScenario(ctx context.Context) error {
 	}
 
 	// Balance calculation uses AccrualSchedule for accruals + stored transactions for consumption
-	balance := calculateBalance(lastYearTxs, lastYearPeriod, policy.Unit, accrual, lastYearEnd)
+	balance := generic.ComputeBalance(lastYearTxs, lastYearPeriod, policy.Unit, accrual, lastYearPeriod.Start, lastYearEnd)
 	balance.EntityID = generic.EntityID("emp-003")
 	balance.PolicyID = policy.ID
 
@@ -833,11 +850,15 @@ func (h *Handler) loadMidYearPolicyChangeScenario(ctx context.Context) error {
 	}
 
 	// Get initial policy and accrual schedule
-	policy1, ok := h.policies[generic.PolicyID("pto-initial")]
+	cache, err := h.cache(ctx)
+	if err != nil {
+		return err
+	}
+	policy1, ok := cache.policies[generic.PolicyID("pto-initial")]
 	if !ok {
 		return fmt.Errorf("initial policy not found")
 	}
-	accrual1 := h.accruals[policy1.ID]
+	accrual1 := cache.accruals[policy1.ID]
 
 	scenarioPrefix := "policy-change-scenario"
 
@@ -880,7 +901,7 @@ func (h *Handler) loadMidYearPolicyChangeScenario(ctx context.Context) error {
 	if err != nil {
 		return err
 	}
-	juneBalance := calculateBalance(juneTxs, junePeriod, policy1.Unit, accrual1, juneEnd)
+	juneBalance := generic.ComputeBalance(juneTxs, junePeriod, policy1.Unit, accrual1, junePeriod.Start, juneEnd)
 	juneBalance.EntityID = generic.EntityID("emp-policy-change")
 	juneBalance.PolicyID = policy1.ID
 
@@ -1012,7 +1033,11 @@ func (h *Handler) createPolicyFromJSON(ctx context.Context, jsonStr string) erro
 		return err
 	}
 
-	h.policies[policy.ID] = policy
-	h.accruals[policy.ID] = accrual
+	cache, err := h.cache(ctx)
+	if err != nil {
+		return err
+	}
+	cache.policies[policy.ID] = policy
+	cache.accruals[policy.ID] = accrual
 	return nil
 }
@@ -32,12 +32,21 @@ func setupTestHandler(t *testing.T) *Handler {
 	handler := &Handler{
 		Store:         store,
 		PolicyFactory: factory.NewPolicyFactory(),
-		policies:      make(map[generic.PolicyID]*generic.Policy),
-		accruals:      make(map[generic.PolicyID]generic.AccrualSchedule),
+		tenants:       make(map[generic.TenantID]*tenantCache),
 	}
 	return handler
 }
 
+// testCache returns the handler's policy cache for ctx's tenant.
+func testCache(t *testing.T, handler *Handler, ctx context.Context) *tenantCache {
+	t.Helper()
+	cache, err := handler.cache(ctx)
+	if err != nil {
+		t.Fatalf("Failed to load policies: %v", err)
+	}
+	return cache
+}
+
 func TestScenario_NewEmployee(t *testing.T) {
 	// GIVEN: New employee scenario
 	// WHEN: Loading the scenario
@@ -64,7 +73,7 @@ func TestScenario_NewEmployee(t *testing.T) {
 	}
 
 	// Verify policy exists
-	policy, ok := handler.policies[generic.PolicyID("pto-standard")]
+	policy, ok := testCache(t, handler, ctx).policies[generic.PolicyID("pto-standard")]
 	if !ok {
 		t.Fatal("Policy 'pto-standard' not found")
 	}
@@ -126,7 +135,7 @@ func TestScenario_MultiPolicy(t *testing.T) {
 	// Verify multiple policies exist
 	expectedPolicies := []string{"pto-standard", "pto-bonus", "pto-carryover", "sick-standard"}
 	for _, policyID := range expectedPolicies {
-		if _, ok := handler.policies[generic.PolicyID(policyID)]; !ok {
+		if _, ok := testCache(t, handler, ctx).policies[generic.PolicyID(policyID)]; !ok {
 			t.Errorf("Expected policy '%s' not found", policyID)
 		}
 	}
@@ -190,7 +199,7 @@ func TestScenario_YearEndRollover(t *testing.T) {
 	}
 
 	// Verify policy exists
-	policy, ok := handler.policies[generic.PolicyID("pto-rollover")]
+	policy, ok := testCache(t, handler, ctx).policies[generic.PolicyID("pto-rollover")]
 	if !ok {
 		t.Fatal("Policy 'pto-rollover' not found")
 	}
@@ -241,7 +250,7 @@ func TestScenario_HourlyWorker(t *testing.T) {
 	}
 
 	// Verify policy exists with consume-up-to-accrued mode
-	policy, ok := handler.policies[generic.PolicyID("pto-hourly")]
+	policy, ok := testCache(t, handler, ctx).policies[generic.PolicyID("pto-hourly")]
 	if !ok {
 		t.Fatal("Policy 'pto-hourly' not found")
 	}
@@ -288,7 +297,7 @@ func TestScenario_RewardsBenefits(t *testing.T) {
 		"volunteer-time",
 	}
 	for _, policyID := range expectedPolicies {
-		if _, ok := handler.policies[generic.PolicyID(policyID)]; !ok {
+		if _, ok := testCache(t, handler, ctx).policies[generic.PolicyID(policyID)]; !ok {
 			t.Errorf("Expected policy '%s' not found", policyID)
 		}
 	}
@@ -309,7 +318,7 @@ func TestScenario_RewardsBenefits(t *testing.T) {
 	yearEnd := generic.TimePoint{Time: time.Date(year, time.December, 31, 23, 59, 59, 0, time.UTC)}
 
 	// Check wellness points transactions
-	wellnessPolicy := handler.policies[generic.PolicyID("wellness-program")]
+	wellnessPolicy := testCache(t, handler, ctx).policies[generic.PolicyID("wellness-program")]
 	if wellnessPolicy != nil {
 		txs, err := ledger.TransactionsInRange(ctx, generic.EntityID("emp-alex"), wellnessPolicy.ID, yearStart, yearEnd)
 		if err != nil {
@@ -320,7 +329,7 @@ func TestScenario_RewardsBenefits(t *testing.T) {
 	}
 
 	// Check learning credits transactions
-	learningPolicy := handler.policies[generic.PolicyID("learning-budget")]
+	learningPolicy := testCache(t, handler, ctx).policies[generic.PolicyID("learning-budget")]
 	if learningPolicy != nil {
 		txs, err := ledger.TransactionsInRange(ctx, generic.EntityID("emp-alex"), learningPolicy.ID, yearStart, yearEnd)
 		if err != nil {
@@ -354,10 +363,10 @@ func TestScenario_PolicyChange(t *testing.T) {
 	}
 
 	// Verify both policies exist
-	if _, ok := handler.policies[generic.PolicyID("pto-initial")]; !ok {
+	if _, ok := testCache(t, handler, ctx).policies[generic.PolicyID("pto-initial")]; !ok {
 		t.Error("Expected 'pto-initial' policy")
 	}
-	if _, ok := handler.policies[generic.PolicyID("pto-upgraded")]; !ok {
+	if _, ok := testCache(t, handler, ctx).policies[generic.PolicyID("pto-upgraded")]; !ok {
 		t.Error("Expected 'pto-upgraded' policy")
 	}
 
@@ -7,13 +7,34 @@ PURPOSE:
 
 DESIGN:
   - Runs a background goroutine with configurable check interval
-  - Detects assignments where current date is past the period end
-  - Skips assignments that have already been reconciled
+  - Only the leader runs reconciliation: replicas sharing a store compete
+    for one lease (SchedulerLease), renewed before each tenant and while
+    a batch runs as a heartbeat, and released on Stop. A replica that
+    loses the lease cancels its batch; rollover transactions have
+    deterministic idempotency keys (keyRollover), so a period the new
+    leader redoes is not posted twice
+  - Each check runs once per tenant (default tenant first), with the
+    tenant in the context so every read and write stays in that tenant
+  - Runs as generic.SystemActor, so its transactions are recorded as
+    created by the system
+  - Each tenant's check is one reconciliation batch (reconciliation.go),
+    run by a pool of Workers and recorded only if some entity has work
+  - Catches up: walks every period from each assignment's EffectiveFrom
+    that has ended, oldest first, so a carryover posted for one period
+    is in the balance of the next (see pendingPeriods)
+  - Skips periods that already have a completed reconciliation run
+  - Stops an assignment's catch-up at the first failed period; later
+    periods are retried on the next check
   - Records reconciliation runs for audit and UI display
+  - Publishes reconciliation.completed to Handler.Events (events.go)
 
 CONFIGURATION:
   - CheckInterval: How often to check (default: 1 hour)
   - Enabled: Whether scheduler is active (default: true)
+  - Holder: This replica's lease holder ID (default: host, PID and time)
+  - LeaseTTL: How long the lease outlives a heartbeat (default: 2 x
+    CheckInterval, so the leader keeps it between checks)
+  - Workers: Entities reconciled at once (default: DefaultBatchWorkers)
 
 USAGE:
   scheduler := NewReconciliationScheduler(store, handler)
@@ -22,6 +43,7 @@ USAGE:
   scheduler.Stop()
 
 SEE ALSO:
+  - reconciliation.go: Batches, per-period reconciliation, endpoints
   - handlers.go: TriggerRollover endpoint (manual reconciliation)
   - generic/policy.go: ReconciliationEngine
 */
@@ -31,19 +53,26 @@ import (
 	"context"
 	"fmt"
 	"log"
+	"os"
 	"sync"
 	"time"
 
 	"github.com/warp/resource-engine/generic"
-	"github.com/warp/resource-engine/store/sqlite"
 )
 
+// SchedulerLease is the lease a scheduler must hold to reconcile.
+const SchedulerLease = "reconciliation-scheduler"
+
 // ReconciliationScheduler handles automated year-end reconciliation.
 type ReconciliationScheduler struct {
-	Store         *sqlite.Store
+	Store         Store
 	Handler       *Handler
 	CheckInterval time.Duration
 	Enabled       bool
+	Now           func() time.Time // Clock; time.Now unless set in tests
+	Holder        string           // Lease holder ID, unique per replica
+	LeaseTTL      time.Duration    // 0 means 2 x CheckInterval
+	Workers       int              // Batch worker pool size
 
 	ticker *time.Ticker
 	stop   chan bool
@@ -52,12 +81,15 @@ type ReconciliationScheduler struct {
 }
 
 // NewReconciliationScheduler creates a new scheduler.
-func NewReconciliationScheduler(store *sqlite.Store, handler *Handler) *ReconciliationScheduler {
+func NewReconciliationScheduler(store Store, handler *Handler) *ReconciliationScheduler {
 	return &ReconciliationScheduler{
 		Store:         store,
 		Handler:       handler,
 		CheckInterval: 1 * time.Hour,
 		Enabled:       true,
+		Now:           time.Now,
+		Holder:        defaultHolder(),
+		Workers:       DefaultBatchWorkers,
 		stop:          make(chan bool),
 	}
 }
@@ -80,7 +112,8 @@ func (rs *ReconciliationScheduler) Start() {
 	log.Printf("[Scheduler] Started with check interval: %v", rs.CheckInterval)
 }
 
-// Stop stops the scheduler.
+// Stop stops the scheduler and releases its lease, so another replica
+// can take over without waiting for it to expire.
 func (rs *ReconciliationScheduler) Stop() {
 	rs.mu.Lock()
 	defer rs.mu.Unlock()
@@ -91,6 +124,9 @@ func (rs *ReconciliationScheduler) Stop() {
 		rs.wg.Wait()
 		log.Println("[Scheduler] Stopped")
 	}
+	if err := rs.Store.ReleaseLease(context.Background(), SchedulerLease, rs.Holder); err != nil {
+		log.Printf("[Scheduler] Error releasing lease: %v", err)
+	}
 }
 
 func (rs *ReconciliationScheduler) run() {
@@ -109,168 +145,87 @@ func (rs *ReconciliationScheduler) run() {
 	}
 }
 
+// checkAndProcess runs one check per tenant, each with its own context,
+// so a tenant's run only sees that tenant's employees and policies.
+// It renews the lease before each tenant and stops once it is lost.
 func (rs *ReconciliationScheduler) checkAndProcess() {
-	ctx := context.Background()
-	now := time.Now()
-
-	log.Printf("[Scheduler] Checking for reconciliations at %v", now)
+	ctx := generic.WithActor(context.Background(), generic.SystemActor)
 
-	// Get all active assignments
-	employees, err := rs.Store.ListEmployees(ctx)
-	if err != nil {
-		log.Printf("[Scheduler] Error listing employees: %v", err)
-		return
-	}
-
-	processedCount := 0
-	skippedCount := 0
-
-	for _, emp := range employees {
-		assignments, err := rs.Store.GetAssignmentsByEntity(ctx, emp.ID)
-		if err != nil {
-			log.Printf("[Scheduler] Error getting assignments for %s: %v", emp.ID, err)
-			continue
-		}
-
-		for _, assign := range assignments {
-			// Get the policy to determine period
-			policyRecord, err := rs.Store.GetPolicy(ctx, assign.PolicyID)
-			if err != nil || policyRecord == nil {
-				continue
-			}
-
-			// Parse the policy config
-			policy, _, err := rs.Handler.PolicyFactory.ParsePolicy(policyRecord.ConfigJSON)
-			if err != nil {
-				continue
-			}
-
-			// Calculate the period for this policy
-			period := policy.PeriodConfig.PeriodFor(generic.Today())
-
-			// Check if we're past the period end
-			if !now.After(period.End.Time) {
-				// Current period not ended yet
-				continue
-			}
-
-			// Check if reconciliation already done for this period
-			alreadyDone, err := rs.Store.IsReconciliationComplete(ctx, emp.ID, assign.PolicyID, period.End.Time)
-			if err != nil {
-				log.Printf("[Scheduler] Error checking reconciliation status: %v", err)
-				continue
-			}
-			if alreadyDone {
-				skippedCount++
-				continue
-			}
-
-			// Process reconciliation
-			err = rs.processReconciliation(ctx, emp.ID, assign, policy, period)
-			if err != nil {
-				log.Printf("[Scheduler] Error processing reconciliation for %s/%s: %v", emp.ID, assign.PolicyID, err)
-			} else {
-				processedCount++
-			}
+	for _, tenantCtx := range tenantContexts(ctx, rs.Store, "[Scheduler]") {
+		if !rs.holdLease(ctx) {
+			return
 		}
-	}
-
-	if processedCount > 0 || skippedCount > 0 {
-		log.Printf("[Scheduler] Completed: %d processed, %d skipped (already done)", processedCount, skippedCount)
+		rs.checkTenant(tenantCtx)
 	}
 }
 
-func (rs *ReconciliationScheduler) processReconciliation(
-	ctx context.Context,
-	entityID string,
-	assign sqlite.AssignmentRecord,
-	policy *generic.Policy,
-	period generic.Period,
-) error {
-	runID := fmt.Sprintf("run-%d", time.Now().UnixNano())
-	startTime := time.Now()
-
-	// Create run record (pending)
-	run := sqlite.ReconciliationRun{
-		ID:          runID,
-		PolicyID:    assign.PolicyID,
-		EntityID:    entityID,
-		PeriodStart: period.Start.Time,
-		PeriodEnd:   period.End.Time,
-		Status:      "running",
-		StartedAt:   &startTime,
-		CreatedAt:   startTime,
-	}
-
-	if err := rs.Store.SaveReconciliationRun(ctx, run); err != nil {
-		return fmt.Errorf("failed to save run record: %w", err)
-	}
-
-	// Calculate current balance
-	txs, err := rs.Store.LoadRange(ctx, generic.EntityID(entityID), generic.PolicyID(assign.PolicyID), period.Start, period.End)
+// holdLease takes or renews the scheduler lease and reports whether this
+// replica is the leader.
+func (rs *ReconciliationScheduler) holdLease(ctx context.Context) bool {
+	held, err := rs.Store.AcquireLease(ctx, SchedulerLease, rs.Holder, rs.leaseTTL())
 	if err != nil {
-		run.Status = "failed"
-		run.Error = err.Error()
-		rs.Store.SaveReconciliationRun(ctx, run)
-		return err
+		log.Printf("[Scheduler] Error acquiring lease: %v", err)
+		return false
+	}
+	if !held {
+		log.Printf("[Scheduler] Not the leader (%s), skipping check", rs.Holder)
 	}
+	return held
+}
 
-	// Get accrual schedule if available
-	var accruals generic.AccrualSchedule
-	if sched, ok := rs.Handler.accruals[generic.PolicyID(assign.PolicyID)]; ok {
-		accruals = sched
+func (rs *ReconciliationScheduler) leaseTTL() time.Duration {
+	if rs.LeaseTTL == 0 {
+		return 2 * rs.CheckInterval
 	}
+	return rs.LeaseTTL
+}
 
-	// Calculate balance from transactions
-	balance := calculateBalanceForScheduler(txs, period, policy.Unit, accruals, period.End)
+// defaultHolder identifies this replica: host, process and start time.
+func defaultHolder() string {
+	host, _ := os.Hostname()
+	return fmt.Sprintf("%s-%d-%d", host, os.Getpid(), time.Now().UnixNano())
+}
 
-	// Process reconciliation
-	engine := &generic.ReconciliationEngine{}
-	nextPeriod := policy.PeriodConfig.PeriodFor(period.End.AddDays(1))
+// checkTenant plans the tenant's reconciliation and, if any entity has
+// ended periods to reconcile, runs it as a batch. The lease is renewed
+// as the batch progresses, so a long batch keeps the leadership; if it
+// is lost, the batch is cancelled and the new leader plans the rest.
+func (rs *ReconciliationScheduler) checkTenant(ctx context.Context) {
+	now := rs.Now()
+	asOf := generic.NewTimePoint(now.Year(), now.Month(), now.Day())
+	tenant := generic.TenantFrom(ctx)
 
-	output, err := engine.Process(generic.ReconciliationInput{
-		EntityID:       generic.EntityID(entityID),
-		PolicyID:       generic.PolicyID(assign.PolicyID),
-		Policy:         *policy,
-		CurrentBalance: balance,
-		EndingPeriod:   period,
-		NextPeriod:     nextPeriod,
-	})
+	log.Printf("[Scheduler] Checking for reconciliations at %v (tenant %q)", now, tenant)
 
+	b := newBatch("scheduler", asOf, rs.Workers)
+	policies := newBatchPolicies(rs.Handler)
+	entities, err := rs.Handler.planBatch(ctx, b, policies)
 	if err != nil {
-		run.Status = "failed"
-		run.Error = err.Error()
-		rs.Store.SaveReconciliationRun(ctx, run)
-		return err
+		log.Printf("[Scheduler] Error planning reconciliation: %v", err)
+		return
 	}
-
-	// Append reconciliation transactions
-	if len(output.Transactions) > 0 {
-		if err := rs.Store.AppendBatch(ctx, output.Transactions); err != nil {
-			run.Status = "failed"
-			run.Error = err.Error()
-			rs.Store.SaveReconciliationRun(ctx, run)
-			return err
-		}
+	if len(entities) == 0 {
+		return
 	}
 
-	// Update run record (completed)
-	completedTime := time.Now()
-	run.Status = "completed"
-	carriedOver, _ := output.Summary.CarriedOver.Value.Float64()
-	expired, _ := output.Summary.Expired.Value.Float64()
-	run.CarriedOver = carriedOver
-	run.Expired = expired
-	run.CompletedAt = &completedTime
-
-	if err := rs.Store.SaveReconciliationRun(ctx, run); err != nil {
-		return fmt.Errorf("failed to update run record: %w", err)
+	b.Total = len(entities)
+	if err := rs.Store.SaveReconciliationBatch(ctx, b); err != nil {
+		log.Printf("[Scheduler] Error saving reconciliation batch: %v", err)
+		return
 	}
 
-	log.Printf("[Scheduler] Processed %s/%s: carried=%.2f, expired=%.2f",
-		entityID, assign.PolicyID, carriedOver, expired)
+	renewed := time.Now()
+	heartbeat := func() bool {
+		if time.Since(renewed) < rs.leaseTTL()/4 {
+			return true
+		}
+		renewed = time.Now()
+		return rs.holdLease(ctx)
+	}
+	b = rs.Handler.executeBatch(ctx, b, entities, policies, heartbeat)
 
-	return nil
+	log.Printf("[Scheduler] Completed tenant %q: batch %s %s, %d periods reconciled, %d failed",
+		tenant, b.ID, b.Status, b.Reconciled, b.Failed)
 }
 
 // RunNow triggers an immediate check (for testing/admin).
@@ -282,62 +237,3 @@ func (rs *ReconciliationScheduler) RunNow() {
 func (rs *ReconciliationScheduler) GetNextRunTime() time.Time {
 	return time.Now().Add(rs.CheckInterval)
 }
-
-// calculateBalanceForScheduler calculates balance for reconciliation.
-// This mirrors the calculateBalance function in handlers.go.
-func calculateBalanceForScheduler(txs []generic.Transaction, period generic.Period, unit generic.Unit, accrual generic.AccrualSchedule, asOf generic.TimePoint) generic.Balance {
-	var actualAccruals, consumed, pending, adjustments generic.Amount
-	actualAccruals = generic.NewAmount(0, unit)
-	consumed = generic.NewAmount(0, unit)
-	pending = generic.NewAmount(0, unit)
-	adjustments = generic.NewAmount(0, unit)
-
-	for _, tx := range txs {
-		switch tx.Type {
-		case generic.TxGrant:
-			actualAccruals = actualAccruals.Add(tx.Delta)
-		case generic.TxConsumption:
-			consumed = consumed.Add(tx.Delta.Neg())
-		case generic.TxPending:
-			pending = pending.Add(tx.Delta.Neg())
-		case generic.TxReconciliation, generic.TxAdjustment:
-			adjustments = adjustments.Add(tx.Delta)
-		case generic.TxReversal:
-			consumed = consumed.Sub(tx.Delta)
-		}
-	}
-
-	// Calculate scheduled accruals if deterministic
-	var scheduledAccruals generic.Amount
-	scheduledAccruals = generic.NewAmount(0, unit)
-	if accrual != nil && accrual.IsDeterministic() {
-		events := accrual.GenerateAccruals(period.Start, asOf)
-		for _, e := range events {
-			scheduledAccruals = scheduledAccruals.Add(e.Amount)
-		}
-	}
-
-	// Use maximum of actual and scheduled
-	accruedToDate := actualAccruals
-	if scheduledAccruals.GreaterThan(actualAccruals) {
-		accruedToDate = scheduledAccruals
-	}
-
-	// Full entitlement for deterministic
-	totalEntitlement := accruedToDate
-	if accrual != nil && accrual.IsDeterministic() {
-		events := accrual.GenerateAccruals(period.Start, period.End)
-		totalEntitlement = generic.NewAmount(0, unit)
-		for _, e := range events {
-			totalEntitlement = totalEntitlement.Add(e.Amount)
-		}
-	}
-
-	return generic.Balance{
-		AccruedToDate:    accruedToDate,
-		TotalEntitlement: totalEntitlement,
-		TotalConsumed:    consumed,
-		Pending:          pending,
-		Adjustments:      adjustments,
-	}
-}
@@ -0,0 +1,188 @@
+/*
+scheduler_test.go - Unit tests for the reconciliation scheduler
+
+Tests for:
+- Scheduler catch-up and leader lease (ReconciliationScheduler)
+*/
+package api
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/warp/resource-engine/generic"
+	"github.com/warp/resource-engine/store/kv"
+	"github.com/warp/resource-engine/store/sqlite"
+	"github.com/warp/resource-engine/timeoff"
+)
+
+func TestScheduler_CatchesUpEndedPeriodsInOrder(t *testing.T) {
+	// GIVEN: PTO of 12 days a year (carryover up to 5) assigned since 2023,
+	//        and a scheduler that first runs on February 1, 2026
+	// WHEN: The scheduler checks, then checks again
+	// THEN: 2023, 2024 and 2025 are reconciled oldest first, each year
+	//       starting from the carryover of the one before, and the second
+	//       check finds nothing left to do
+
+	handler := setupTestHandler(t)
+	ctx := context.Background()
+
+	if err := handler.createPolicyFromJSON(ctx, timeoff.StandardPTOJSON("pto-test", "Test PTO", 12, 5)); err != nil {
+		t.Fatalf("Failed to create policy: %v", err)
+	}
+	since := time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC)
+	if err := handler.Store.SaveEmployee(ctx, sqlite.Employee{ID: "emp-back", Name: "Back User", HireDate: since}); err != nil {
+		t.Fatalf("Failed to create employee: %v", err)
+	}
+	if err := handler.Store.SaveAssignment(ctx, sqlite.AssignmentRecord{
+		ID: "assign-back", EntityID: "emp-back", PolicyID: "pto-test",
+		EffectiveFrom: since, ConsumptionPriority: 1,
+	}); err != nil {
+		t.Fatalf("Failed to save assignment: %v", err)
+	}
+
+	scheduler := NewReconciliationScheduler(handler.Store, handler)
+	scheduler.Now = func() time.Time { return time.Date(2026, 2, 1, 9, 0, 0, 0, time.UTC) }
+	scheduler.RunNow()
+
+	runs, err := handler.Store.GetReconciliationRuns(ctx, "completed")
+	if err != nil {
+		t.Fatalf("Failed to get runs: %v", err)
+	}
+	if len(runs) != 3 {
+		t.Fatalf("Expected 3 completed runs, got %d", len(runs))
+	}
+	byYear := map[int]sqlite.ReconciliationRun{}
+	for _, r := range runs {
+		byYear[r.PeriodEnd.Year()] = r
+	}
+	for _, year := range []int{2023, 2024, 2025} {
+		if _, ok := byYear[year]; !ok {
+			t.Errorf("No run for %d", year)
+		}
+	}
+	if r := byYear[2023]; r.CarriedOver != 5 || r.Expired != 7 {
+		t.Errorf("2023: carried %.2f, expired %.2f; want 5 and 7", r.CarriedOver, r.Expired)
+	}
+	// 12 accrued + 5 carried in from 2023: 2023 was reconciled first
+	if r := byYear[2024]; r.CarriedOver != 5 || r.Expired != 12 {
+		t.Errorf("2024: carried %.2f, expired %.2f; want 5 and 12", r.CarriedOver, r.Expired)
+	}
+
+	scheduler.RunNow()
+	runs, err = handler.Store.GetReconciliationRuns(ctx, "")
+	if err != nil {
+		t.Fatalf("Failed to get runs: %v", err)
+	}
+	if len(runs) != 3 {
+		t.Errorf("Expected no new runs on the second check, got %d runs", len(runs))
+	}
+}
+
+func TestScheduler_OnlyTheLeaseHolderReconciles(t *testing.T) {
+	backends := map[string]func(t *testing.T) Store{
+		"sqlite": func(t *testing.T) Store {
+			s, err := sqlite.New(":memory:")
+			if err != nil {
+				t.Fatalf("Failed to create store: %v", err)
+			}
+			t.Cleanup(func() { s.Close() })
+			return s
+		},
+		"kv": func(t *testing.T) Store {
+			s, err := kv.New(":memory:")
+			if err != nil {
+				t.Fatalf("Failed to create store: %v", err)
+			}
+			t.Cleanup(func() { s.Close() })
+			return s
+		},
+	}
+	for name, open := range backends {
+		t.Run(name, func(t *testing.T) {
+			// GIVEN: Two replicas' schedulers sharing one store, and PTO
+			//        assigned since 2025 (one ended period on Feb 1, 2026)
+			// WHEN: Replica A checks, then B, then A stops and B checks again
+			// THEN: Only the lease holder reconciles, B takes over once A
+			//       releases the lease, and redoing a posted period is harmless
+			store := open(t)
+			handler := NewHandler(store)
+			ctx := context.Background()
+
+			if err := handler.createPolicyFromJSON(ctx, timeoff.StandardPTOJSON("pto-test", "Test PTO", 12, 5)); err != nil {
+				t.Fatalf("Failed to create policy: %v", err)
+			}
+			since := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+			assign := func(id string) sqlite.AssignmentRecord {
+				if err := store.SaveEmployee(ctx, sqlite.Employee{ID: id, Name: id, HireDate: since}); err != nil {
+					t.Fatalf("Failed to create employee: %v", err)
+				}
+				a := sqlite.AssignmentRecord{ID: "assign-" + id, EntityID: id, PolicyID: "pto-test", EffectiveFrom: since, ConsumptionPriority: 1}
+				if err := store.SaveAssignment(ctx, a); err != nil {
+					t.Fatalf("Failed to save assignment: %v", err)
+				}
+				return a
+			}
+			assign("emp-a")
+
+			replica := func(holder string) *ReconciliationScheduler {
+				rs := NewReconciliationScheduler(store, handler)
+				rs.Holder = holder
+				rs.Now = func() time.Time { return time.Date(2026, 2, 1, 9, 0, 0, 0, time.UTC) }
+				return rs
+			}
+			a, b := replica("replica-a"), replica("replica-b")
+			completed := func() int {
+				runs, err := store.GetReconciliationRuns(ctx, "completed")
+				if err != nil {
+					t.Fatalf("Failed to get runs: %v", err)
+				}
+				return len(runs)
+			}
+			holder := func() string {
+				lease, err := store.GetLease(ctx, SchedulerLease)
+				if err != nil {
+					t.Fatalf("Failed to get lease: %v", err)
+				}
+				if lease == nil {
+					return ""
+				}
+				return lease.Holder
+			}
+
+			a.RunNow()
+			if completed() != 1 || holder() != "replica-a" {
+				t.Fatalf("After A: %d runs, lease held by %q; want 1 and replica-a", completed(), holder())
+			}
+
+			late := assign("emp-b")
+			b.RunNow()
+			if completed() != 1 || holder() != "replica-a" {
+				t.Errorf("B reconciled without the lease: %d runs, lease held by %q", completed(), holder())
+			}
+
+			a.Stop()
+			if holder() != "" {
+				t.Errorf("Lease still held by %q after A stopped", holder())
+			}
+			b.RunNow()
+			if completed() != 2 || holder() != "replica-b" {
+				t.Errorf("After takeover: %d runs, lease held by %q; want 2 and replica-b", completed(), holder())
+			}
+
+			// A leader that lost its lease after posting, before recording
+			// the run: the new leader redoes the period without a second post
+			before, _ := store.GetAllTransactions(ctx, 1000)
+			policy := testCache(t, handler, ctx).policies["pto-test"]
+			year := policy.PeriodConfig.PeriodFor(generic.TimePoint{Time: since})
+			if _, err := handler.reconcilePeriod(generic.WithActor(ctx, generic.SystemActor), "", "emp-b", late, policy, year); err != nil {
+				t.Fatalf("Redoing the period failed: %v", err)
+			}
+			after, _ := store.GetAllTransactions(ctx, 1000)
+			if len(after) != len(before) {
+				t.Errorf("Redoing the period posted %d more transactions", len(after)-len(before))
+			}
+		})
+	}
+}
@@ -17,13 +17,24 @@ MIDDLEWARE STACK:
   2. Recoverer:  Panic recovery (500 instead of crash)
   3. RequestID:  Unique ID per request for tracing
   4. CORS:       Cross-origin requests for frontend
+  5. Auth:       Bearer token -> principal and actor (/api only, see auth.go)
+  6. Tenant:     X-Tenant-ID -> request context (/api only, see tenants.go)
+  7. Idempotency: Idempotency-Key replay for writes (/api only, see
+                 idempotency.go)
+  Routes add role checks: allow(roles...), actingFor and ownerOnly (see
+  auth.go).
 
 ROUTE GROUPS:
+  /api/tenants          Tenant management
   /api/employees/*      Employee management
   /api/policies/*       Policy management
   /api/scenarios/*      Demo scenarios
-  /api/admin/*          Admin operations
-  /api/reset            Database reset (dev only)
+  /api/admin/*          Admin operations (incl. webhooks)
+  /api/reports/*        Finance reports (liability)
+  /api/reconciliation/* Batch rollover runs and their items (admin)
+  /api/events           Live updates (Server-Sent Events)
+  /api/calendar/*       iCalendar feeds (feed token, no bearer token)
+  /api/scenarios/reset  Database reset (admin, dev only)
   /*                    Static files (frontend)
 
 STATIC FILE SERVING:
@@ -31,8 +42,8 @@ STATIC FILE SERVING:
   Falls back to index.html for client-side routing.
 
 SECURITY NOTE:
-  No authentication middleware currently. All endpoints are public.
-  See DEVOPS_SECURITY.md for production requirements.
+  Authentication is off unless Handler.Auth is set (cmd/server -auth-tokens
+  or -jwt-* flags). With it off, all endpoints are public.
 
 SEE ALSO:
   - handlers.go: Handler implementations
@@ -48,6 +59,7 @@ import (
 	"github.com/go-chi/chi/v5"
 	"github.com/go-chi/chi/v5/middleware"
 	"github.com/go-chi/cors"
+	"github.com/warp/resource-engine/generic"
 )
 
 // NewRouter creates a new router with all routes configured.
@@ -61,23 +73,65 @@ func NewRouter(h *Handler) *chi.Mux {
 	r.Use(cors.Handler(cors.Options{
 		AllowedOrigins:   []string{"http://localhost:5173", "http://localhost:8080"},
 		AllowedMethods:   []string{"GET", "POST", "PUT", "DELETE", "OPTIONS"},
-		AllowedHeaders:   []string{"Accept", "Authorization", "Content-Type"},
+		AllowedHeaders:   []string{"Accept", "Authorization", "Content-Type", TenantHeader, IdempotencyHeader},
+		ExposedHeaders:   []string{"Idempotent-Replayed"},
 		AllowCredentials: true,
 	}))
 
 	// API routes
 	r.Route("/api", func(r chi.Router) {
+		// Every API request is authenticated (except calendar feeds),
+		// then scoped to one tenant
+		r.Use(h.Authenticate)
+		r.Use(h.ResolveTenant)
+		r.Use(h.Idempotency)
+
+		admin := h.allow(generic.ActorAdmin, generic.ActorSystem)
+		approver := h.allow(generic.ActorManager, generic.ActorAdmin, generic.ActorSystem)
+
+		// Tenant routes
+		r.Route("/tenants", func(r chi.Router) {
+			r.Use(admin, h.allowAllTenants)
+			r.Get("/", h.ListTenants)
+			r.Post("/", h.CreateTenant)
+		})
+
 		// Employee routes
 		r.Route("/employees", func(r chi.Router) {
 			r.Get("/", h.ListEmployees)
-			r.Post("/", h.CreateEmployee)
-			r.Get("/{id}", h.GetEmployee)
-			r.Get("/{id}/balance", h.GetBalance)
-			r.Get("/{id}/transactions", h.GetTransactions)
-			r.Get("/{id}/assignments", h.GetAssignments)
-			r.Post("/{id}/requests", h.SubmitRequest)
+			r.With(admin).Post("/", h.CreateEmployee)
+			r.With(admin).Post("/{id}/terminate", h.TerminateEmployee)
+			r.With(admin).Post("/{id}/pay-rates", h.CreatePayRate)
+			r.With(admin).Post("/{id}/holiday-calendars", h.AssignHolidayCalendar)
+
+			// The employee themselves, their manager, or admin
+			r.Group(func(r chi.Router) {
+				r.Use(h.actingFor)
+				r.Get("/{id}", h.GetEmployee)
+				r.Get("/{id}/balance", h.GetBalance)
+				r.Get("/{id}/balance/history", h.GetBalanceHistory)
+				r.Get("/{id}/balance/explain", h.GetBalanceExplanation)
+				r.Get("/{id}/balance/projection", h.GetBalanceProjection)
+				r.Get("/{id}/transactions", h.GetTransactions)
+				r.Get("/{id}/assignments", h.GetAssignments)
+				r.Post("/{id}/requests", h.SubmitRequest)
+				r.Get("/{id}/pay-rates", h.ListPayRates)
+				r.Get("/{id}/holiday-calendars", h.GetEmployeeHolidayCalendars)
+				r.Get("/{id}/holidays", h.GetEmployeeHolidays)
+			})
+
+			// The employee themselves or admin: feed URLs are credentials
+			r.Group(func(r chi.Router) {
+				r.Use(h.ownerOnly)
+				r.Get("/{id}/calendar-feeds", h.ListCalendarFeeds)
+				r.Post("/{id}/calendar-feeds", h.CreateCalendarFeed)
+				r.Delete("/{id}/calendar-feeds/{token}", h.RevokeCalendarFeed)
+			})
 		})
 
+		// Live updates (Server-Sent Events, see events.go)
+		r.Get("/events", h.StreamEvents)
+
 		// Transaction routes
 		r.Route("/transactions", func(r chi.Router) {
 			r.Delete("/{id}", h.CancelTransaction)
@@ -86,27 +140,45 @@ func NewRouter(h *Handler) *chi.Mux {
 		// Policy routes
 		r.Route("/policies", func(r chi.Router) {
 			r.Get("/", h.ListPolicies)
-			r.Post("/", h.CreatePolicy)
+			r.With(admin).Post("/", h.CreatePolicy)
 			r.Get("/{id}", h.GetPolicy)
 		})
 
 		// Admin routes
 		r.Route("/admin", func(r chi.Router) {
+			r.Use(admin)
 			r.Post("/assignments", h.CreateAssignment)
 			r.Post("/rollover", h.TriggerRollover)
 			r.Post("/adjustments", h.CreateAdjustment)
+
+			// Webhooks (see webhooks.go)
+			r.Get("/webhooks", h.ListWebhookEndpoints)
+			r.Post("/webhooks", h.CreateWebhookEndpoint)
+			r.Get("/webhooks/events", h.ListOutboxEvents)
+			r.Delete("/webhooks/{id}", h.DisableWebhookEndpoint)
+			r.Get("/webhooks/{id}/deliveries", h.ListWebhookDeliveries)
+			r.Post("/webhooks/{id}/replay", h.ReplayWebhookEndpoint)
+			r.Post("/webhooks/deliveries/{id}/replay", h.ReplayWebhookDelivery)
 		})
 
 		// Holiday routes
 		r.Route("/holidays", func(r chi.Router) {
 			r.Get("/", h.ListHolidays)
-			r.Post("/", h.CreateHoliday)
-			r.Post("/defaults", h.AddDefaultHolidays)
-			r.Delete("/{id}", h.DeleteHoliday)
+			r.With(admin).Post("/", h.CreateHoliday)
+			r.With(admin).Post("/defaults", h.AddDefaultHolidays)
+			r.With(admin).Post("/import", h.ImportHolidays)
+			r.With(admin).Delete("/{id}", h.DeleteHoliday)
+		})
+
+		// Holiday calendar routes (one per office/location)
+		r.Route("/holiday-calendars", func(r chi.Router) {
+			r.Get("/", h.ListHolidayCalendars)
+			r.With(admin).Post("/", h.CreateHolidayCalendar)
 		})
 
 		// Request approval routes
 		r.Route("/requests", func(r chi.Router) {
+			r.Use(approver)
 			r.Get("/pending", h.ListPendingRequests)
 			r.Post("/{id}/approve", h.ApproveRequest)
 			r.Post("/{id}/reject", h.RejectRequest)
@@ -114,19 +186,38 @@ func NewRouter(h *Handler) *chi.Mux {
 
 		// Reconciliation routes
 		r.Route("/reconciliation", func(r chi.Router) {
-			r.Get("/runs", h.ListReconciliationRuns)
+			r.Use(admin)
+			r.Get("/runs", h.ListReconciliationBatches)
+			r.Post("/runs", h.StartReconciliationBatch)
+			r.Get("/runs/{id}", h.GetReconciliationBatch)
+			r.Get("/runs/{id}/items", h.ListReconciliationBatchItems)
+			r.Post("/runs/{id}/cancel", h.CancelReconciliationBatch)
+			r.Post("/runs/{id}/resume", h.ResumeReconciliationBatch)
+			r.Post("/runs/{id}/rollback", h.RollbackReconciliationBatch)
+			r.Get("/items", h.ListReconciliationRuns)
+			r.Post("/items/{id}/rollback", h.RollbackReconciliationRun)
+			r.Get("/preview", h.PreviewReconciliation)
 			r.Post("/process", h.TriggerRollover) // Existing endpoint
 		})
 
+		// Report routes
+		r.Route("/reports", func(r chi.Router) {
+			r.Use(admin)
+			r.Get("/liability", h.GetLiabilityReport)
+		})
+
 		// Scenario routes
 		r.Route("/scenarios", func(r chi.Router) {
 			r.Get("/", h.ListScenarios)
 			r.Get("/current", h.GetCurrentScenario)
-			r.Post("/load", h.LoadScenario)
-			r.Post("/reset", h.ResetDatabase)
+			r.With(admin).Post("/load", h.LoadScenario)
+			r.With(admin).Post("/reset", h.ResetDatabase)
 		})
 	})
 
+	// Calendar feeds (token in URL is the credential, no bearer token)
+	r.With(h.ResolveTenant).Get("/api/calendar/{token}", h.GetCalendarFeed)
+
 	// Serve static files (React app)
 	// First try ./web/dist (development), then fall back to message
 	staticDir := "./web/dist"
@@ -0,0 +1,131 @@
+/*
+store.go - Storage the API depends on
+
+PURPOSE:
+  Handlers, the reconciliation scheduler and the webhook dispatcher use
+  the backend through this interface rather than a concrete store, so the
+  server can run on SQLite or on the embedded key-value store (-store flag
+  in cmd/server).
+
+IMPLEMENTATIONS:
+  *sqlite.Store   store/sqlite (default; needs CGO)
+  *kv.Store       store/kv (pure Go, single file)
+
+  Both share their record types (store/record), which package sqlite
+  re-exports as aliases.
+
+SEE ALSO:
+  - generic/store.go: Ledger interfaces
+  - cmd/server/main.go: Backend selection
+*/
+package api
+
+import (
+	"context"
+	"time"
+
+	"github.com/warp/resource-engine/generic"
+	"github.com/warp/resource-engine/store/kv"
+	"github.com/warp/resource-engine/store/sqlite"
+)
+
+// Backends the API runs on.
+var (
+	_ Store = (*sqlite.Store)(nil)
+	_ Store = (*kv.Store)(nil)
+)
+
+// Store is everything the API persists. Every method is scoped to the
+// tenant of its context (generic.TenantFrom).
+type Store interface {
+	generic.EntityStore
+	generic.TxStore
+	generic.KnownAtStore
+	generic.HolidayCalendar
+	generic.EntityHolidayCalendar
+
+	// Ledger
+	AppendBatchWithEvent(ctx context.Context, txs []generic.Transaction, eventType generic.EventType, data map[string]any) error
+	GetAllTransactions(ctx context.Context, limit int) ([]generic.Transaction, error)
+	GetTransaction(ctx context.Context, id string) (*generic.Transaction, error)
+	IsTransactionReversed(ctx context.Context, txID string) (bool, error)
+	Reset(ctx context.Context) error
+
+	// Tenants
+	SaveTenant(ctx context.Context, t sqlite.Tenant) error
+	GetTenant(ctx context.Context, id generic.TenantID) (*sqlite.Tenant, error)
+	ListTenants(ctx context.Context) ([]sqlite.Tenant, error)
+
+	// Policies, employees and assignments
+	SavePolicy(ctx context.Context, policy sqlite.PolicyRecord) error
+	GetPolicy(ctx context.Context, id string) (*sqlite.PolicyRecord, error)
+	ListPolicies(ctx context.Context) ([]sqlite.PolicyRecord, error)
+	SaveEmployee(ctx context.Context, emp sqlite.Employee) error
+	GetEmployee(ctx context.Context, id string) (*sqlite.Employee, error)
+	ListEmployees(ctx context.Context) ([]sqlite.Employee, error)
+	SaveAssignment(ctx context.Context, a sqlite.AssignmentRecord) error
+	GetAssignmentsByEntity(ctx context.Context, entityID string) ([]sqlite.AssignmentRecord, error)
+
+	// The same assignments and snapshots for the generic services
+	Assignments(policies sqlite.PolicyResolver) generic.AssignmentStore
+	Snapshots() generic.SnapshotStore
+
+	// Pay rates and calendar feeds
+	SavePayRate(ctx context.Context, r sqlite.PayRate) error
+	GetPayRates(ctx context.Context, entityID string) ([]sqlite.PayRate, error)
+	GetPayRateAt(ctx context.Context, entityID string, at time.Time) (*sqlite.PayRate, error)
+	SaveCalendarFeed(ctx context.Context, f sqlite.CalendarFeed) error
+	GetCalendarFeed(ctx context.Context, token string) (*sqlite.CalendarFeed, error)
+	GetCalendarFeedsByOwner(ctx context.Context, ownerID string) ([]sqlite.CalendarFeed, error)
+	RevokeCalendarFeed(ctx context.Context, ownerID, token string) (bool, error)
+
+	// Holidays
+	SaveHoliday(ctx context.Context, h generic.Holiday) error
+	DeleteHoliday(ctx context.Context, id string) error
+	GetAllHolidays(ctx context.Context, companyID string) ([]generic.Holiday, error)
+	HolidayCalendarFor(tenant generic.TenantID) generic.HolidayCalendar
+	ImportHolidays(ctx context.Context, companyID string, holidays []generic.Holiday, opts sqlite.HolidayImportOptions) (*sqlite.HolidayImportResult, error)
+	SaveHolidayCalendar(ctx context.Context, c sqlite.HolidayCalendarRecord) error
+	GetHolidayCalendar(ctx context.Context, id string) (*sqlite.HolidayCalendarRecord, error)
+	ListHolidayCalendars(ctx context.Context) ([]sqlite.HolidayCalendarRecord, error)
+	AssignHolidayCalendar(ctx context.Context, a sqlite.EmployeeHolidayCalendar) error
+	GetEmployeeHolidayCalendars(ctx context.Context, entityID string) ([]sqlite.EmployeeHolidayCalendar, error)
+
+	// Requests, reconciliation runs (items) and batches
+	SaveRequest(ctx context.Context, r sqlite.Request) error
+	GetRequest(ctx context.Context, id string) (*sqlite.Request, error)
+	GetPendingRequests(ctx context.Context) ([]sqlite.Request, error)
+	SaveReconciliationRun(ctx context.Context, r sqlite.ReconciliationRun) error
+	GetReconciliationRuns(ctx context.Context, status string) ([]sqlite.ReconciliationRun, error)
+	IsReconciliationComplete(ctx context.Context, entityID, policyID string, periodEnd time.Time) (bool, error)
+	GetReconciliationRun(ctx context.Context, id string) (*sqlite.ReconciliationRun, error)
+	GetReconciliationRunForPeriod(ctx context.Context, entityID, policyID string, periodStart, periodEnd time.Time) (*sqlite.ReconciliationRun, error)
+	GetReconciliationRunsFor(ctx context.Context, entityID, policyID string) ([]sqlite.ReconciliationRun, error)
+	GetReconciliationRunsByBatch(ctx context.Context, batchID string) ([]sqlite.ReconciliationRun, error)
+	SaveReconciliationBatch(ctx context.Context, b sqlite.ReconciliationBatch) error
+	GetReconciliationBatch(ctx context.Context, id string) (*sqlite.ReconciliationBatch, error)
+	ListReconciliationBatches(ctx context.Context) ([]sqlite.ReconciliationBatch, error)
+
+	// Outbox and webhooks
+	ListOutboxEvents(ctx context.Context, limit int) ([]sqlite.OutboxEvent, error)
+	DispatchOutbox(ctx context.Context, limit int) (int, error)
+	SaveWebhookEndpoint(ctx context.Context, e sqlite.WebhookEndpoint) error
+	GetWebhookEndpoint(ctx context.Context, id string) (*sqlite.WebhookEndpoint, error)
+	ListWebhookEndpoints(ctx context.Context) ([]sqlite.WebhookEndpoint, error)
+	DueWebhookDeliveries(ctx context.Context, now time.Time, limit int) ([]sqlite.WebhookDelivery, error)
+	ListWebhookDeliveries(ctx context.Context, endpointID, status string, limit int) ([]sqlite.WebhookDelivery, error)
+	GetWebhookDelivery(ctx context.Context, id string) (*sqlite.WebhookDelivery, error)
+	SaveWebhookDelivery(ctx context.Context, d sqlite.WebhookDelivery) error
+	ReplayWebhookDeliveries(ctx context.Context, endpointID string, since *time.Time) (int, error)
+	RequeueWebhookDelivery(ctx context.Context, id string) (bool, error)
+
+	// Idempotency-Key responses
+	ReserveIdempotencyKey(ctx context.Context, rec sqlite.IdempotencyRecord, ttl time.Duration) (*sqlite.IdempotencyRecord, error)
+	CompleteIdempotencyKey(ctx context.Context, rec sqlite.IdempotencyRecord) error
+	ReleaseIdempotencyKey(ctx context.Context, actor, key string) error
+
+	// Leases (global; the scheduler's leader lease)
+	AcquireLease(ctx context.Context, name, holder string, ttl time.Duration) (bool, error)
+	ReleaseLease(ctx context.Context, name, holder string) error
+	GetLease(ctx context.Context, name string) (*sqlite.Lease, error)
+}
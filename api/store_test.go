@@ -0,0 +1,113 @@
+/*
+store_test.go - Unit tests for the store backends behind the API
+
+Tests for:
+- Key-value backend (kv.Store behind the API, reopen after a torn write)
+*/
+package api
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+
+	"github.com/warp/resource-engine/store/kv"
+	"github.com/warp/resource-engine/store/sqlite"
+)
+
+func TestKVStore_MatchesSQLiteAndSurvivesReopen(t *testing.T) {
+	// GIVEN: The same scenario loaded on SQLite and on the key-value store
+	scenarios := map[string]func(h *Handler, ctx context.Context) error{
+		"multi-policy":      (*Handler).loadMultiPolicyScenario,
+		"year-end-rollover": (*Handler).loadYearEndRolloverScenario,
+		"policy-change":     (*Handler).loadMidYearPolicyChangeScenario,
+		"rewards-benefits":  (*Handler).loadRewardsBenefitsScenario,
+	}
+	ctx := context.Background()
+
+	balances := func(t *testing.T, handler *Handler) map[string]string {
+		router := NewRouter(handler)
+		employees, err := handler.Store.ListEmployees(ctx)
+		if err != nil || len(employees) == 0 {
+			t.Fatalf("Expected employees, got %d (%v)", len(employees), err)
+		}
+		result := make(map[string]string)
+		for _, emp := range employees {
+			rec := httptest.NewRecorder()
+			router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/employees/"+emp.ID+"/balance", nil))
+			if rec.Code != http.StatusOK {
+				t.Fatalf("Expected 200 for %s balance, got %d: %s", emp.ID, rec.Code, rec.Body.String())
+			}
+			result[emp.ID] = rec.Body.String()
+		}
+		return result
+	}
+
+	for name, load := range scenarios {
+		t.Run(name, func(t *testing.T) {
+			reference := setupTestHandler(t)
+			if err := load(reference, ctx); err != nil {
+				t.Fatalf("Failed to load scenario on SQLite: %v", err)
+			}
+
+			path := t.TempDir() + "/warp.kv"
+			store, err := kv.New(path)
+			if err != nil {
+				t.Fatalf("Failed to open kv store: %v", err)
+			}
+			handler := NewHandler(store)
+			if err := load(handler, ctx); err != nil {
+				t.Fatalf("Failed to load scenario on kv: %v", err)
+			}
+
+			// WHEN: Both serve balances
+			// THEN: The responses are identical
+			want := balances(t, reference)
+			got := balances(t, handler)
+			for id, body := range want {
+				if got[id] != body {
+					t.Errorf("Balance of %s differs:\nsqlite: %s\nkv:     %s", id, body, got[id])
+				}
+			}
+			txs, _ := store.GetAllTransactions(ctx, 1000)
+			if len(txs) == 0 {
+				t.Fatal("Expected the scenario to post transactions")
+			}
+			store.Close()
+
+			// WHEN: The log is reopened after a write torn mid-record
+			f, err := os.OpenFile(path, os.O_APPEND|os.O_WRONLY, 0)
+			if err != nil {
+				t.Fatalf("Failed to open log: %v", err)
+			}
+			f.Write([]byte{0x40, 0, 0, 0, 0xde, 0xad})
+			f.Close()
+
+			store, err = kv.New(path)
+			if err != nil {
+				t.Fatalf("Failed to reopen kv store: %v", err)
+			}
+			defer store.Close()
+			reopened := NewHandler(store)
+			if err := reopened.LoadPolicies(ctx); err != nil {
+				t.Fatalf("Failed to load policies: %v", err)
+			}
+
+			// THEN: Every committed write is still there and the torn one is dropped
+			again, _ := store.GetAllTransactions(ctx, 1000)
+			if len(again) != len(txs) {
+				t.Errorf("Expected %d transactions after reopen, got %d", len(txs), len(again))
+			}
+			for id, body := range balances(t, reopened) {
+				if body != want[id] {
+					t.Errorf("Balance of %s changed after reopen: %s", id, body)
+				}
+			}
+			if err := store.SaveEmployee(ctx, sqlite.Employee{ID: "emp-new", Name: "New"}); err != nil {
+				t.Errorf("Expected writes after recovery to succeed: %v", err)
+			}
+		})
+	}
+}
@@ -0,0 +1,159 @@
+/*
+tenants.go - Tenant resolution and management
+
+PURPOSE:
+  Several companies (tenants) share one server. Every request is scoped to
+  one tenant before any handler runs: ResolveTenant reads the X-Tenant-ID
+  header and puts the tenant in the request context, and the store filters
+  every query by it (see generic/tenant.go). Handlers never pick a tenant
+  themselves, so a request cannot read or write another tenant's data.
+
+RESOLUTION:
+  X-Tenant-ID: acme    -> tenant "acme" (404 if it does not exist)
+  (no header)          -> default tenant "", or the tenant the caller's
+                          token is bound to (see auth.go)
+  X-Tenant-ID: globex  -> 403 if the caller's token is bound to acme
+  /api/calendar/{tok}  -> the feed token's tenant (calendar clients send
+                          no headers; see calendar.go)
+
+PER-TENANT STATE:
+  - Policy cache: Handler.cache(ctx), loaded on a tenant's first request
+  - Demo scenarios and /api/reset only touch the request's tenant
+  - Background jobs (reconciliation scheduler, webhook dispatcher) run
+    once per tenant (tenantContexts)
+
+ENDPOINTS:
+  GET  /api/tenants      List tenants
+  POST /api/tenants      Create tenant {"id":"acme","name":"Acme Corp"}
+
+SEE ALSO:
+  - generic/tenant.go: WithTenant, TenantFrom
+  - store/sqlite/sqlite.go: tenant_id on every table
+  - scheduler.go: per-tenant runs
+*/
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"net/http"
+	"regexp"
+	"time"
+
+	"github.com/warp/resource-engine/generic"
+	"github.com/warp/resource-engine/store/sqlite"
+)
+
+// TenantHeader names the tenant of an API request.
+const TenantHeader = "X-Tenant-ID"
+
+// tenantIDPattern keeps tenant IDs usable in headers and logs.
+var tenantIDPattern = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9_.-]*$`)
+
+// ResolveTenant is middleware that scopes the request context to the
+// tenant named by the X-Tenant-ID header, or the default tenant if none.
+// Unknown tenants are rejected so nothing is ever written to a tenant
+// that was not created.
+// Callers whose token is bound to a tenant get that tenant when they send
+// no header, and 403 when they name another.
+func (h *Handler) ResolveTenant(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		tenant := generic.TenantID(r.Header.Get(TenantHeader))
+		if p := principalFrom(r.Context()); p != nil && !p.AllTenants {
+			if r.Header.Get(TenantHeader) == "" {
+				tenant = p.Tenant
+			} else if tenant != p.Tenant {
+				writeError(w, http.StatusForbidden, "Token is not valid for this tenant", nil)
+				return
+			}
+		}
+		if tenant != generic.DefaultTenant {
+			record, err := h.Store.GetTenant(r.Context(), tenant)
+			if err != nil {
+				writeError(w, http.StatusInternalServerError, "Failed to resolve tenant", err)
+				return
+			}
+			if record == nil {
+				writeError(w, http.StatusNotFound, "Unknown tenant", nil)
+				return
+			}
+		}
+		next.ServeHTTP(w, r.WithContext(generic.WithTenant(r.Context(), tenant)))
+	})
+}
+
+// ListTenants returns all tenants (not including the default tenant).
+// GET /api/tenants
+func (h *Handler) ListTenants(w http.ResponseWriter, r *http.Request) {
+	tenants, err := h.Store.ListTenants(r.Context())
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "Failed to list tenants", err)
+		return
+	}
+
+	dtos := make([]TenantDTO, len(tenants))
+	for i, t := range tenants {
+		dtos[i] = toTenantDTO(t)
+	}
+	writeJSON(w, http.StatusOK, dtos)
+}
+
+// CreateTenant creates (or renames) a tenant.
+// POST /api/tenants
+func (h *Handler) CreateTenant(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	var req CreateTenantRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "Invalid request body", err)
+		return
+	}
+	if !tenantIDPattern.MatchString(req.ID) {
+		writeError(w, http.StatusBadRequest, "Invalid id (letters, digits, '-', '_' and '.')", nil)
+		return
+	}
+	if req.Name == "" {
+		req.Name = req.ID
+	}
+
+	existing, err := h.Store.GetTenant(ctx, generic.TenantID(req.ID))
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "Failed to get tenant", err)
+		return
+	}
+
+	tenant := sqlite.Tenant{ID: generic.TenantID(req.ID), Name: req.Name, CreatedAt: time.Now().UTC()}
+	if existing != nil {
+		tenant.CreatedAt = existing.CreatedAt
+	}
+	if err := h.Store.SaveTenant(ctx, tenant); err != nil {
+		writeError(w, http.StatusInternalServerError, "Failed to save tenant", err)
+		return
+	}
+
+	status := http.StatusCreated
+	if existing != nil {
+		status = http.StatusOK
+	}
+	writeJSON(w, status, toTenantDTO(tenant))
+}
+
+// tenantContexts returns one context per tenant, the default tenant
+// first, for background jobs that run once per tenant. A failure to list
+// tenants is logged and leaves just the default tenant.
+func tenantContexts(ctx context.Context, store Store, logPrefix string) []context.Context {
+	contexts := []context.Context{generic.WithTenant(ctx, generic.DefaultTenant)}
+	records, err := store.ListTenants(ctx)
+	if err != nil {
+		log.Printf("%s Error listing tenants: %v", logPrefix, err)
+	}
+	for _, t := range records {
+		contexts = append(contexts, generic.WithTenant(ctx, t.ID))
+	}
+	return contexts
+}
+
+func toTenantDTO(t sqlite.Tenant) TenantDTO {
+	return TenantDTO{ID: string(t.ID), Name: t.Name, CreatedAt: t.CreatedAt.Format(time.RFC3339)}
+}
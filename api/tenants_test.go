@@ -0,0 +1,345 @@
+/*
+tenants_test.go - Unit tests for tenants
+
+Tests for:
+- Tenant isolation (store queries, ResolveTenant, legacy migration)
+*/
+package api
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/warp/resource-engine/generic"
+	"github.com/warp/resource-engine/store/sqlite"
+	"github.com/warp/resource-engine/timeoff"
+)
+
+func TestTenant_StoreQueriesNeverCrossTenants(t *testing.T) {
+	// GIVEN: Two tenants using the same employee, policy, transaction and
+	//        holiday IDs, and the same idempotency key
+	// WHEN: Reading through each tenant's context
+	// THEN: Each sees only its own rows, and resetting one leaves the other
+
+	store, err := sqlite.New(":memory:")
+	if err != nil {
+		t.Fatalf("Failed to create store: %v", err)
+	}
+	defer store.Close()
+
+	acme := generic.WithTenant(context.Background(), "acme")
+	globex := generic.WithTenant(context.Background(), "globex")
+	hireDate := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	day := generic.TimePoint{Time: time.Date(2025, 3, 10, 0, 0, 0, 0, time.UTC)}
+
+	for _, tc := range []struct {
+		ctx  context.Context
+		name string
+	}{{acme, "Alice (Acme)"}, {globex, "Alice (Globex)"}} {
+		if err := store.SaveEmployee(tc.ctx, sqlite.Employee{ID: "alice", Name: tc.name, HireDate: hireDate}); err != nil {
+			t.Fatalf("Failed to save employee: %v", err)
+		}
+		if err := store.SavePolicy(tc.ctx, sqlite.PolicyRecord{ID: "pto", Name: tc.name, ResourceType: "pto", ConfigJSON: "{}"}); err != nil {
+			t.Fatalf("Failed to save policy: %v", err)
+		}
+		// Same ID, idempotency key and day: only unique within a tenant
+		if err := store.Append(tc.ctx, generic.Transaction{
+			ID: "tx-1", EntityID: "alice", PolicyID: "pto", ResourceType: timeoff.ResourcePTO,
+			EffectiveAt: day, Delta: generic.NewAmount(-1, generic.UnitDays),
+			Type: generic.TxConsumption, Reason: tc.name, IdempotencyKey: "key-1",
+		}); err != nil {
+			t.Fatalf("Failed to append transaction for %s: %v", tc.name, err)
+		}
+	}
+	if err := store.SaveHoliday(acme, generic.Holiday{
+		ID: "h-1", Date: generic.TimePoint{Time: time.Date(2025, 7, 4, 0, 0, 0, 0, time.UTC)}, Name: "Acme Day",
+	}); err != nil {
+		t.Fatalf("Failed to save holiday: %v", err)
+	}
+
+	emp, err := store.GetEmployee(globex, "alice")
+	if err != nil || emp == nil || emp.Name != "Alice (Globex)" {
+		t.Fatalf("Expected Globex's own Alice, got %+v (err %v)", emp, err)
+	}
+	if policies, _ := store.ListPolicies(globex); len(policies) != 1 || policies[0].Name != "Alice (Globex)" {
+		t.Errorf("Expected only Globex's policy, got %+v", policies)
+	}
+	if tx, _ := store.GetTransaction(globex, "tx-1"); tx == nil || tx.Reason != "Alice (Globex)" {
+		t.Errorf("Expected Globex's own tx-1, got %+v", tx)
+	}
+	if txs, _ := store.LoadByEntity(globex, "alice", day, day); len(txs) != 1 {
+		t.Errorf("Expected 1 transaction for Globex's Alice, got %d", len(txs))
+	}
+
+	// Duplicates are still rejected within a tenant
+	err = store.Append(acme, generic.Transaction{
+		ID: "tx-2", EntityID: "bob", PolicyID: "pto", ResourceType: timeoff.ResourcePTO,
+		EffectiveAt: day, Delta: generic.NewAmount(-1, generic.UnitDays),
+		Type: generic.TxConsumption, IdempotencyKey: "key-1",
+	})
+	if err != generic.ErrDuplicateIdempotencyKey {
+		t.Errorf("Expected duplicate idempotency key within a tenant, got %v", err)
+	}
+
+	// Holidays: only Acme has one
+	july4 := generic.TimePoint{Time: time.Date(2025, 7, 4, 0, 0, 0, 0, time.UTC)}
+	if !store.HolidayCalendarFor("acme").IsHoliday("", july4) {
+		t.Error("Expected Acme's holiday in Acme")
+	}
+	if store.HolidayCalendarFor("globex").IsHoliday("", july4) || store.IsHoliday("", july4) {
+		t.Error("Acme's holiday leaked into Globex or the default tenant")
+	}
+	if holidays, _ := store.GetAllHolidays(globex, ""); len(holidays) != 0 {
+		t.Errorf("Expected no holidays for Globex, got %d", len(holidays))
+	}
+
+	// Reset is per tenant
+	if err := store.Reset(acme); err != nil {
+		t.Fatalf("Failed to reset: %v", err)
+	}
+	if emp, _ := store.GetEmployee(acme, "alice"); emp != nil {
+		t.Error("Expected Acme's Alice to be gone after reset")
+	}
+	if emp, _ := store.GetEmployee(globex, "alice"); emp == nil {
+		t.Error("Resetting Acme deleted Globex's Alice")
+	}
+	if exists, _ := store.Exists(globex, "key-1"); !exists {
+		t.Error("Resetting Acme deleted Globex's transaction")
+	}
+}
+
+func TestTenant_APIResolvesTenantPerRequest(t *testing.T) {
+	// GIVEN: Tenants acme and globex, each with a policy "pto" of a
+	//        different size, and an Acme employee with a calendar feed
+	// WHEN: Calling the API with different X-Tenant-ID headers
+	// THEN: Each request sees only its tenant's employees and policies,
+	//       unknown tenants are rejected, and the feed token finds Acme
+
+	handler := setupTestHandler(t)
+	router := NewRouter(handler)
+
+	do := func(method, path, tenant, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		if tenant != "" {
+			req.Header.Set(TenantHeader, tenant)
+		}
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, req)
+		return rec
+	}
+
+	for _, id := range []string{"acme", "globex"} {
+		if rec := do(http.MethodPost, "/api/tenants", "", `{"id":"`+id+`"}`); rec.Code != http.StatusCreated {
+			t.Fatalf("Expected 201 creating tenant, got %d: %s", rec.Code, rec.Body.String())
+		}
+	}
+	if rec := do(http.MethodGet, "/api/employees", "initech", ""); rec.Code != http.StatusNotFound {
+		t.Errorf("Expected 404 for unknown tenant, got %d", rec.Code)
+	}
+
+	// Same policy ID, different accrual per tenant (cached per tenant)
+	for tenant, days := range map[string]float64{"acme": 24, "globex": 12} {
+		config := timeoff.StandardPTOJSON("pto", "PTO", days, 5)
+		if rec := do(http.MethodPost, "/api/policies", tenant, `{"config":`+config+`}`); rec.Code != http.StatusCreated {
+			t.Fatalf("Expected 201 creating policy, got %d: %s", rec.Code, rec.Body.String())
+		}
+	}
+	acme := generic.WithTenant(context.Background(), "acme")
+	globex := generic.WithTenant(context.Background(), "globex")
+	acmePolicy := testCache(t, handler, acme).policies["pto"]
+	globexPolicy := testCache(t, handler, globex).policies["pto"]
+	if acmePolicy == nil || globexPolicy == nil || acmePolicy == globexPolicy {
+		t.Fatal("Expected a separate cached policy per tenant")
+	}
+	if len(testCache(t, handler, context.Background()).policies) != 0 {
+		t.Error("Tenant policies leaked into the default tenant's cache")
+	}
+
+	if rec := do(http.MethodPost, "/api/employees", "acme", `{"id":"alice","name":"Alice","hire_date":"2025-01-01"}`); rec.Code != http.StatusCreated {
+		t.Fatalf("Expected 201 creating employee, got %d: %s", rec.Code, rec.Body.String())
+	}
+	var employees []EmployeeDTO
+	json.Unmarshal(do(http.MethodGet, "/api/employees", "globex", "").Body.Bytes(), &employees)
+	if len(employees) != 0 {
+		t.Errorf("Globex should see no employees, got %d", len(employees))
+	}
+	if rec := do(http.MethodGet, "/api/employees/alice", "globex", ""); rec.Code != http.StatusNotFound {
+		t.Errorf("Expected 404 for Acme's employee under Globex, got %d", rec.Code)
+	}
+	if rec := do(http.MethodGet, "/api/employees/alice", "acme", ""); rec.Code != http.StatusOK {
+		t.Errorf("Expected 200 for Acme's employee under Acme, got %d", rec.Code)
+	}
+	if rec := do(http.MethodPost, "/api/employees/alice/calendar-feeds", "globex", `{"scope":"self"}`); rec.Code != http.StatusNotFound {
+		t.Errorf("Expected 404 creating a feed for Acme's employee under Globex, got %d", rec.Code)
+	}
+
+	// The feed URL has no tenant header; the token resolves Acme
+	rec := do(http.MethodPost, "/api/employees/alice/calendar-feeds", "acme", `{"scope":"self"}`)
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("Expected 201 creating feed, got %d: %s", rec.Code, rec.Body.String())
+	}
+	var feed CalendarFeedDTO
+	json.Unmarshal(rec.Body.Bytes(), &feed)
+	if rec := do(http.MethodGet, feed.URL, "", ""); rec.Code != http.StatusOK {
+		t.Errorf("Expected 200 fetching Acme's feed without a header, got %d", rec.Code)
+	}
+	if rec := do(http.MethodGet, "/api/employees/alice/calendar-feeds", "globex", ""); strings.Contains(rec.Body.String(), feed.Token) {
+		t.Error("Globex can list Acme's feed token")
+	}
+
+	// Reset only clears the calling tenant
+	if rec := do(http.MethodPost, "/api/scenarios/reset", "globex", ""); rec.Code != http.StatusOK {
+		t.Fatalf("Expected 200 on reset, got %d", rec.Code)
+	}
+	if rec := do(http.MethodGet, "/api/employees/alice", "acme", ""); rec.Code != http.StatusOK {
+		t.Errorf("Resetting Globex deleted Acme's employee (got %d)", rec.Code)
+	}
+	if testCache(t, handler, acme).policies["pto"] == nil {
+		t.Error("Resetting Globex cleared Acme's policy cache")
+	}
+}
+
+// failingPoliciesStore fails ListPolicies while down is set.
+type failingPoliciesStore struct {
+	Store
+	down bool
+}
+
+func (s *failingPoliciesStore) ListPolicies(ctx context.Context) ([]sqlite.PolicyRecord, error) {
+	if s.down {
+		return nil, fmt.Errorf("database is locked")
+	}
+	return s.Store.ListPolicies(ctx)
+}
+
+func TestTenant_FailedPolicyLoadIsAnErrorAndRetried(t *testing.T) {
+	// GIVEN: An employee with PTO and a handler whose policy cache is
+	//        not loaded yet
+	// WHEN: The balance is requested while policies cannot be listed,
+	//       then again once they can
+	// THEN: The first request is a 500 instead of an empty balance, and
+	//       the second loads the policies
+
+	handler := setupTestHandler(t)
+	ctx := context.Background()
+	if err := handler.createPolicyFromJSON(ctx, timeoff.StandardPTOJSON("pto-test", "Test PTO", 24, 5)); err != nil {
+		t.Fatalf("Failed to create policy: %v", err)
+	}
+	hireDate := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	if err := handler.Store.SaveEmployee(ctx, sqlite.Employee{ID: "emp-cache", Name: "Cache User", HireDate: hireDate}); err != nil {
+		t.Fatalf("Failed to create employee: %v", err)
+	}
+	if err := handler.Store.SaveAssignment(ctx, sqlite.AssignmentRecord{
+		ID: "assign-cache", EntityID: "emp-cache", PolicyID: "pto-test",
+		EffectiveFrom: hireDate, ConsumptionPriority: 1,
+	}); err != nil {
+		t.Fatalf("Failed to save assignment: %v", err)
+	}
+
+	store := &failingPoliciesStore{Store: handler.Store, down: true}
+	handler.Store = store
+	handler.tenants = make(map[generic.TenantID]*tenantCache)
+	router := NewRouter(handler)
+	balance := func() *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/api/employees/emp-cache/balance?as_of=2025-06-01", nil)
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, req)
+		return rec
+	}
+
+	if rec := balance(); rec.Code != http.StatusInternalServerError {
+		t.Errorf("Expected 500 while policies cannot be loaded, got %d: %s", rec.Code, rec.Body.String())
+	}
+
+	store.down = false
+	rec := balance()
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Expected 200 once policies load, got %d: %s", rec.Code, rec.Body.String())
+	}
+	var resp BalanceDTO
+	if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("Failed to decode balance: %v", err)
+	}
+	if len(resp.Policies) != 1 {
+		t.Errorf("Expected the PTO policy in the balance, got %+v", resp.Policies)
+	}
+}
+
+func TestTenant_LegacyDatabaseMovesToDefaultTenant(t *testing.T) {
+	// GIVEN: A database created before multi-tenancy (no tenant_id, an
+	//        employee without team, an idempotency key and a holiday)
+	// WHEN: Opening it with the current store
+	// THEN: The rows are in the default tenant and keys are now per tenant
+
+	path := t.TempDir() + "/legacy.db"
+	db, err := sql.Open("sqlite3", path)
+	if err != nil {
+		t.Fatalf("Failed to open legacy database: %v", err)
+	}
+	if _, err := db.Exec(`
+		CREATE TABLE employees (id TEXT PRIMARY KEY, name TEXT NOT NULL, email TEXT,
+			hire_date TEXT NOT NULL, created_at TEXT NOT NULL);
+		CREATE TABLE transactions (id TEXT PRIMARY KEY, entity_id TEXT NOT NULL, policy_id TEXT NOT NULL,
+			resource_type TEXT NOT NULL, effective_at TEXT NOT NULL, delta_value TEXT NOT NULL,
+			delta_unit TEXT NOT NULL, tx_type TEXT NOT NULL, reference_id TEXT, reason TEXT,
+			idempotency_key TEXT UNIQUE, metadata_json TEXT, created_at TEXT NOT NULL);
+		CREATE INDEX idx_transactions_entity_policy ON transactions(entity_id, policy_id);
+		CREATE TABLE holidays (id TEXT PRIMARY KEY, company_id TEXT NOT NULL DEFAULT '',
+			date TEXT NOT NULL, name TEXT NOT NULL, recurring BOOLEAN DEFAULT FALSE, created_at TEXT NOT NULL);
+		CREATE UNIQUE INDEX idx_holidays_unique ON holidays(company_id, date, name);
+		INSERT INTO employees VALUES ('alice', 'Alice', '', '2025-01-01T00:00:00Z', '2025-01-01T00:00:00Z');
+		INSERT INTO transactions VALUES ('tx-1', 'alice', 'pto', 'pto', '2025-03-10T00:00:00Z', '-1', 'days',
+			'consumption', '', '', 'key-1', '', '2025-03-01T00:00:00Z');
+		INSERT INTO holidays VALUES ('h-1', '', '2025-12-25', 'Christmas', TRUE, '2025-01-01T00:00:00Z');
+	`); err != nil {
+		t.Fatalf("Failed to create legacy schema: %v", err)
+	}
+	db.Close()
+
+	store, err := sqlite.New(path)
+	if err != nil {
+		t.Fatalf("Failed to migrate legacy database: %v", err)
+	}
+	defer store.Close()
+
+	ctx := context.Background()
+	if emp, _ := store.GetEmployee(ctx, "alice"); emp == nil || emp.Team != "" {
+		t.Errorf("Expected Alice in the default tenant, got %+v", emp)
+	}
+	if exists, _ := store.Exists(ctx, "key-1"); !exists {
+		t.Error("Expected the legacy transaction in the default tenant")
+	}
+	if !store.IsHoliday("", generic.NewTimePoint(2026, 12, 25)) {
+		t.Error("Expected the legacy recurring holiday in the default tenant")
+	}
+
+	acme := generic.WithTenant(ctx, "acme")
+	if emp, _ := store.GetEmployee(acme, "alice"); emp != nil {
+		t.Error("Legacy rows must not be visible to other tenants")
+	}
+	if err := store.Append(acme, generic.Transaction{
+		ID: "tx-1", EntityID: "alice", PolicyID: "pto", ResourceType: timeoff.ResourcePTO,
+		EffectiveAt: generic.TimePoint{Time: time.Date(2025, 3, 10, 0, 0, 0, 0, time.UTC)}, Delta: generic.NewAmount(-1, generic.UnitDays),
+		Type: generic.TxConsumption, IdempotencyKey: "key-1",
+	}); err != nil {
+		t.Errorf("Expected legacy keys to be per tenant after migration, got %v", err)
+	}
+
+	// Reopening an already migrated database is a no-op
+	store.Close()
+	store, err = sqlite.New(path)
+	if err != nil {
+		t.Fatalf("Failed to reopen migrated database: %v", err)
+	}
+	defer store.Close()
+	if exists, _ := store.Exists(acme, "key-1"); !exists {
+		t.Error("Expected Acme's transaction after reopening")
+	}
+}
@@ -0,0 +1,369 @@
+/*
+termination.go - Employee termination and final settlement
+
+PURPOSE:
+  Ends an employee's policy assignments at a termination date and settles
+  every policy: remaining balance is paid out (or forfeited), and balance
+  used beyond what was earned is clawed back. Returns a final statement
+  that payroll can consume.
+
+FLOW:
+  1. Load employee and assignments still active on the termination date
+  2. Reverse time off booked after the termination date, and every
+     pending request (it will never be decided)
+  3. For each policy, compute the balance as of the termination date
+     (prorated from hire date, without the rejected pending days) and
+     settle it via generic.TerminationEngine
+  4. Append reversals + settlement transactions atomically
+  5. Mark the pending requests rejected
+  6. Set EffectiveTo on every open assignment
+
+ENDPOINT:
+  POST /api/employees/{id}/terminate
+  {
+    "termination_date": "2025-03-15",
+    "payout": true,            // default true; false = forfeit
+    "reason": "resignation"
+  }
+
+IDEMPOTENCY:
+  Settlement transactions use deterministic idempotency keys per
+  entity/policy/date. Terminating an already-terminated employee
+  returns 409, also with another date: a settlement for another date,
+  or assignments that all ended on one day, would otherwise be settled
+  a second time. Moving a termination date means reversing the first
+  settlement. A retry after a failure past step 4 (assignments still
+  open) finds the settlement posted, skips the append and finishes
+  steps 5 and 6; its statement leaves the settlement itself out of the
+  balance.
+
+SEE ALSO:
+  - generic/termination.go: Settlement rules
+  - generic/balance.go: ComputeBalance
+*/
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/warp/resource-engine/generic"
+	"github.com/warp/resource-engine/store/sqlite"
+)
+
+// farPast and farFuture bound open-ended ledger queries.
+var (
+	farPast   = generic.NewTimePoint(1, time.January, 1)
+	farFuture = generic.NewTimePoint(9999, time.December, 31)
+)
+
+// TerminateEmployee ends all active assignments and settles final balances.
+// POST /api/employees/{id}/terminate
+func (h *Handler) TerminateEmployee(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	entityID := chi.URLParam(r, "id")
+
+	var req TerminateEmployeeRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "Invalid request body", err)
+		return
+	}
+
+	termTime, err := time.Parse("2006-01-02", req.TerminationDate)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "Invalid termination_date format (use YYYY-MM-DD)", err)
+		return
+	}
+	termDate := generic.TimePoint{Time: termTime}
+
+	payout := true
+	if req.Payout != nil {
+		payout = *req.Payout
+	}
+
+	emp, err := h.Store.GetEmployee(ctx, entityID)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "Failed to get employee", err)
+		return
+	}
+	if emp == nil {
+		writeError(w, http.StatusNotFound, "Employee not found", nil)
+		return
+	}
+	if termTime.Before(emp.HireDate) {
+		writeError(w, http.StatusBadRequest, "Termination date is before hire date", nil)
+		return
+	}
+
+	assignments, err := h.Store.GetAssignmentsByEntity(ctx, entityID)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "Failed to get assignments", err)
+		return
+	}
+
+	// A termination on another date was settled already
+	prior, err := h.priorTermination(ctx, generic.EntityID(entityID), req.TerminationDate)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "Failed to check earlier settlements", err)
+		return
+	}
+	if prior != "" {
+		writeError(w, http.StatusConflict, "Employee was already terminated on "+prior+"; reverse that settlement first", nil)
+		return
+	}
+
+	// Only assignments still open after the termination date need ending.
+	// Assignments that all ended on one day were ended by a termination
+	// (with nothing to settle): ending them earlier would settle again.
+	var open []sqlite.AssignmentRecord
+	ends := make(map[string]bool) // "" = open-ended
+	for _, a := range assignments {
+		if a.EffectiveTo == nil {
+			ends[""] = true
+		} else {
+			ends[a.EffectiveTo.Format("2006-01-02")] = true
+		}
+		if a.EffectiveTo == nil || a.EffectiveTo.After(termTime) {
+			open = append(open, a)
+		}
+	}
+	if len(open) == 0 || (len(ends) == 1 && !ends[""]) {
+		writeError(w, http.StatusConflict, "Employee has no active assignments (already terminated?)", nil)
+		return
+	}
+
+	// Reverse time off booked after the last day of employment, and
+	// pending days before it
+	reversals, err := h.reverseForTermination(ctx, generic.EntityID(entityID), termDate, req.Reason)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "Failed to cancel future time off", err)
+		return
+	}
+	reversed := make(map[string]bool)
+	for _, tx := range reversals {
+		reversed[tx.ReferenceID] = true
+	}
+
+	engine := &generic.TerminationEngine{}
+	ledger := generic.NewLedger(h.Store)
+	hireDate := generic.TimePoint{Time: emp.HireDate}
+
+	var (
+		settlementTxs []generic.Transaction
+		statements    []PolicySettlementDTO
+	)
+
+	cache, err := h.cache(ctx)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "Failed to load policies", err)
+		return
+	}
+	for _, a := range open {
+		policy, ok := cache.policies[generic.PolicyID(a.PolicyID)]
+		if !ok {
+			continue
+		}
+		// Assignments that had not started yet have nothing to settle
+		if a.EffectiveFrom.After(termTime) {
+			continue
+		}
+
+		period := policy.PeriodConfig.PeriodFor(termDate)
+		loaded, err := ledger.TransactionsInRange(ctx, generic.EntityID(entityID), policy.ID, period.Start, termDate)
+		if err != nil {
+			writeError(w, http.StatusInternalServerError, "Failed to load transactions", err)
+			return
+		}
+		// Pending days being rejected do not count, nor does this
+		// settlement when a retry finds it posted
+		settled := fmt.Sprintf("terminate-%s-%s-%s-", entityID, policy.ID, req.TerminationDate)
+		var txs []generic.Transaction
+		for _, tx := range loaded {
+			if !reversed[string(tx.ID)] && !strings.HasPrefix(string(tx.ID), settled) {
+				txs = append(txs, tx)
+			}
+		}
+
+		balance := generic.ComputeBalance(txs, period, policy.Unit, cache.accruals[policy.ID], hireDate, termDate)
+		balance.EntityID = generic.EntityID(entityID)
+		balance.PolicyID = policy.ID
+
+		output, err := engine.Settle(generic.TerminationInput{
+			EntityID:        generic.EntityID(entityID),
+			Policy:          *policy,
+			Balance:         balance,
+			TerminationDate: termDate,
+			Payout:          payout,
+		})
+		if err != nil {
+			writeError(w, http.StatusInternalServerError, "Failed to settle policy", err)
+			return
+		}
+
+		for i := range output.Transactions {
+			key := fmt.Sprintf("terminate-%s-%s-%s-%d", entityID, policy.ID, req.TerminationDate, i)
+			output.Transactions[i].ID = generic.TransactionID(key)
+			output.Transactions[i].IdempotencyKey = key
+		}
+		settlementTxs = append(settlementTxs, output.Transactions...)
+
+		statements = append(statements, toPolicySettlementDTO(policy, balance, output.Settlement, output.Transactions))
+	}
+
+	batch := append(reversals, settlementTxs...)
+	if len(batch) > 0 {
+		event := map[string]any{"termination_date": req.TerminationDate}
+		err := h.appendWithEvent(ctx, batch, generic.EventEmployeeTerminated, event)
+		if errors.Is(err, generic.ErrDuplicateIdempotencyKey) {
+			// Posted by an attempt that failed afterwards: finish it
+			log.Printf("[Termination] %s %s: settlement already posted", entityID, req.TerminationDate)
+		} else if err != nil {
+			writeError(w, http.StatusInternalServerError, "Failed to record settlement", err)
+			return
+		}
+	}
+
+	// Pending requests will never be decided
+	pending, err := h.Store.GetPendingRequests(ctx)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "Failed to get pending requests", err)
+		return
+	}
+	now := time.Now()
+	for _, p := range pending {
+		if p.EntityID != entityID {
+			continue
+		}
+		p.Status = "rejected"
+		p.RejectionReason = "Employee terminated: " + req.Reason
+		p.UpdatedAt = now
+		if err := h.Store.SaveRequest(ctx, p); err != nil {
+			writeError(w, http.StatusInternalServerError, "Failed to reject pending request", err)
+			return
+		}
+	}
+
+	// End assignments only once the ledger reflects the settlement
+	for _, a := range open {
+		end := termTime
+		a.EffectiveTo = &end
+		if err := h.Store.SaveAssignment(ctx, a); err != nil {
+			writeError(w, http.StatusInternalServerError, "Failed to end assignment", err)
+			return
+		}
+	}
+
+	writeJSON(w, http.StatusOK, TerminationStatementDTO{
+		EntityID:         entityID,
+		EmployeeName:     emp.Name,
+		HireDate:         emp.HireDate.Format("2006-01-02"),
+		TerminationDate:  req.TerminationDate,
+		Reason:           req.Reason,
+		Payout:           payout,
+		EndedAssignments: len(open),
+		CancelledDays:    len(reversals),
+		Policies:         statements,
+		Transactions:     toTransactionDTOs(batch),
+	})
+}
+
+// priorTermination returns the date of a termination settlement of the
+// entity for another date than terminationDate, or "" if there is none.
+func (h *Handler) priorTermination(ctx context.Context, entityID generic.EntityID, terminationDate string) (string, error) {
+	txs, err := h.Store.LoadByEntity(ctx, entityID, farPast, farFuture)
+	if err != nil {
+		return "", err
+	}
+	prefix := fmt.Sprintf("terminate-%s-", entityID)
+	for _, tx := range txs {
+		if tx.EntityID != entityID || !strings.HasPrefix(string(tx.ID), prefix) {
+			continue
+		}
+		if date := tx.EffectiveAt.Time.Format("2006-01-02"); date != terminationDate {
+			return date, nil
+		}
+	}
+	return "", nil
+}
+
+// reverseForTermination builds reversals for consumption/pending
+// transactions dated after the termination date, and pending ones on or
+// before it, that have not already been reversed.
+func (h *Handler) reverseForTermination(ctx context.Context, entityID generic.EntityID, termDate generic.TimePoint, reason string) ([]generic.Transaction, error) {
+	txs, err := h.Store.LoadByEntity(ctx, entityID, farPast, farFuture)
+	if err != nil {
+		return nil, err
+	}
+
+	var reversals []generic.Transaction
+	for _, tx := range txs {
+		after := tx.EffectiveAt.After(termDate)
+		if tx.Type != generic.TxPending && !(after && tx.Type == generic.TxConsumption) {
+			continue
+		}
+		reversed, err := h.Store.IsTransactionReversed(ctx, string(tx.ID))
+		if err != nil {
+			return nil, err
+		}
+		if reversed {
+			continue
+		}
+		reversals = append(reversals, generic.Transaction{
+			ID:             generic.TransactionID(fmt.Sprintf("reversal-%s", tx.ID)),
+			EntityID:       tx.EntityID,
+			PolicyID:       tx.PolicyID,
+			ResourceType:   tx.ResourceType,
+			EffectiveAt:    tx.EffectiveAt,
+			Delta:          tx.Delta.Neg(),
+			Type:           generic.TxReversal,
+			ReferenceID:    string(tx.ID),
+			Reason:         "Cancelled on termination: " + reason,
+			IdempotencyKey: fmt.Sprintf("reversal-%s", tx.ID),
+		})
+	}
+	return reversals, nil
+}
+
+func toPolicySettlementDTO(policy *generic.Policy, balance generic.Balance, s generic.TerminationSettlement, txs []generic.Transaction) PolicySettlementDTO {
+	f := func(a generic.Amount) float64 {
+		v, _ := a.Value.Float64()
+		return v
+	}
+
+	var amount float64
+	switch s.Action {
+	case generic.SettlementPayout:
+		amount = f(s.PaidOut)
+	case generic.SettlementForfeit:
+		amount = f(s.Forfeited)
+	case generic.SettlementClawback:
+		amount = -f(s.ClawedBack)
+	}
+
+	dto := PolicySettlementDTO{
+		PolicyID:         string(policy.ID),
+		PolicyName:       policy.Name,
+		ResourceType:     policy.ResourceType.ResourceID(),
+		Unit:             string(policy.Unit),
+		ConsumptionMode:  string(policy.ConsumptionMode),
+		TotalEntitlement: f(balance.TotalEntitlement),
+		AccruedToDate:    f(balance.AccruedToDate),
+		Consumed:         f(balance.TotalConsumed),
+		Pending:          f(balance.Pending),
+		Adjustments:      f(balance.Adjustments),
+		FinalBalance:     f(s.FinalBalance),
+		Action:           string(s.Action),
+		Amount:           amount,
+	}
+	if len(txs) > 0 {
+		dto.TransactionID = string(txs[0].ID)
+	}
+	return dto
+}
@@ -0,0 +1,287 @@
+/*
+termination_test.go - Unit tests for employee termination
+
+Tests for:
+- Employee termination (TerminateEmployee, retry, pending requests)
+*/
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/warp/resource-engine/generic"
+	"github.com/warp/resource-engine/store/sqlite"
+	"github.com/warp/resource-engine/timeoff"
+)
+
+func TestTerminateEmployee_SettlesAndEndsAssignments(t *testing.T) {
+	// GIVEN: An employee with PTO, 1 day taken before and 1 day booked after termination
+	// WHEN: Terminating on March 31 with payout
+	// THEN: Future day is reversed, remaining earned balance is paid out,
+	//       the assignment is ended, and a second termination is rejected
+
+	handler := setupTestHandler(t)
+	ctx := context.Background()
+
+	policyJSON := timeoff.StandardPTOJSON("pto-test", "Test PTO", 24, 5)
+	if err := handler.createPolicyFromJSON(ctx, policyJSON); err != nil {
+		t.Fatalf("Failed to create policy: %v", err)
+	}
+	hireDate := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	if err := handler.Store.SaveEmployee(ctx, sqlite.Employee{ID: "emp-term", Name: "Leaving User", HireDate: hireDate}); err != nil {
+		t.Fatalf("Failed to create employee: %v", err)
+	}
+	if err := handler.Store.SaveAssignment(ctx, sqlite.AssignmentRecord{
+		ID: "assign-term", EntityID: "emp-term", PolicyID: "pto-test",
+		EffectiveFrom: hireDate, ConsumptionPriority: 1,
+	}); err != nil {
+		t.Fatalf("Failed to save assignment: %v", err)
+	}
+
+	dayOff := func(id string, d time.Time) generic.Transaction {
+		return generic.Transaction{
+			ID: generic.TransactionID(id), EntityID: "emp-term", PolicyID: "pto-test",
+			ResourceType: timeoff.ResourcePTO, EffectiveAt: generic.TimePoint{Time: d},
+			Delta: generic.NewAmount(-1, generic.UnitDays), Type: generic.TxConsumption,
+			IdempotencyKey: id,
+		}
+	}
+	if err := handler.Store.AppendBatch(ctx, []generic.Transaction{
+		dayOff("tx-feb", time.Date(2025, 2, 10, 0, 0, 0, 0, time.UTC)),
+		dayOff("tx-aug", time.Date(2025, 8, 4, 0, 0, 0, 0, time.UTC)),
+	}); err != nil {
+		t.Fatalf("Failed to append consumption: %v", err)
+	}
+
+	router := NewRouter(handler)
+	terminate := func() *httptest.ResponseRecorder {
+		body := strings.NewReader(`{"termination_date":"2025-03-31","reason":"resignation"}`)
+		req := httptest.NewRequest(http.MethodPost, "/api/employees/emp-term/terminate", body)
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, req)
+		return rec
+	}
+
+	rec := terminate()
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Expected 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+
+	var stmt TerminationStatementDTO
+	if err := json.Unmarshal(rec.Body.Bytes(), &stmt); err != nil {
+		t.Fatalf("Failed to decode statement: %v", err)
+	}
+	if stmt.CancelledDays != 1 {
+		t.Errorf("Expected 1 cancelled day, got %d", stmt.CancelledDays)
+	}
+	if len(stmt.Policies) != 1 {
+		t.Fatalf("Expected 1 policy settlement, got %d", len(stmt.Policies))
+	}
+	ps := stmt.Policies[0]
+	if ps.Action != string(generic.SettlementPayout) {
+		t.Errorf("Expected payout, got %s", ps.Action)
+	}
+	if ps.FinalBalance != ps.AccruedToDate-1 {
+		t.Errorf("Expected final balance %.2f (accrued - 1), got %.2f", ps.AccruedToDate-1, ps.FinalBalance)
+	}
+
+	reversed, err := handler.Store.IsTransactionReversed(ctx, "tx-aug")
+	if err != nil {
+		t.Fatalf("Failed to check reversal: %v", err)
+	}
+	if !reversed {
+		t.Error("Day booked after termination should be reversed")
+	}
+
+	assignments, err := handler.Store.GetAssignmentsByEntity(ctx, "emp-term")
+	if err != nil {
+		t.Fatalf("Failed to get assignments: %v", err)
+	}
+	if len(assignments) != 1 || assignments[0].EffectiveTo == nil ||
+		!assignments[0].EffectiveTo.Equal(time.Date(2025, 3, 31, 0, 0, 0, 0, time.UTC)) {
+		t.Errorf("Assignment should end on termination date, got %+v", assignments)
+	}
+
+	if rec := terminate(); rec.Code != http.StatusConflict {
+		t.Errorf("Expected 409 on repeat termination, got %d", rec.Code)
+	}
+}
+
+func TestTerminateEmployee_EarlierDateAfterTerminationIsRejected(t *testing.T) {
+	// GIVEN: An employee with PTO terminated on March 31 with payout
+	// WHEN: Terminating again on February 28
+	// THEN: 409, nothing more is posted and the assignment still ends on
+	//       March 31
+
+	handler := setupTestHandler(t)
+	ctx := context.Background()
+
+	if err := handler.createPolicyFromJSON(ctx, timeoff.StandardPTOJSON("pto-test", "Test PTO", 24, 5)); err != nil {
+		t.Fatalf("Failed to create policy: %v", err)
+	}
+	hireDate := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	if err := handler.Store.SaveEmployee(ctx, sqlite.Employee{ID: "emp-term", Name: "Leaving User", HireDate: hireDate}); err != nil {
+		t.Fatalf("Failed to create employee: %v", err)
+	}
+	if err := handler.Store.SaveAssignment(ctx, sqlite.AssignmentRecord{
+		ID: "assign-term", EntityID: "emp-term", PolicyID: "pto-test",
+		EffectiveFrom: hireDate, ConsumptionPriority: 1,
+	}); err != nil {
+		t.Fatalf("Failed to save assignment: %v", err)
+	}
+
+	router := NewRouter(handler)
+	terminate := func(date string) *httptest.ResponseRecorder {
+		body := strings.NewReader(`{"termination_date":"` + date + `","reason":"resignation"}`)
+		req := httptest.NewRequest(http.MethodPost, "/api/employees/emp-term/terminate", body)
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, req)
+		return rec
+	}
+
+	if rec := terminate("2025-03-31"); rec.Code != http.StatusOK {
+		t.Fatalf("Expected 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+	before, _ := handler.Store.GetAllTransactions(ctx, 100)
+	if len(before) == 0 {
+		t.Fatal("Expected a payout to be posted")
+	}
+
+	if rec := terminate("2025-02-28"); rec.Code != http.StatusConflict {
+		t.Errorf("Expected 409 terminating again earlier, got %d: %s", rec.Code, rec.Body.String())
+	}
+	if after, _ := handler.Store.GetAllTransactions(ctx, 100); len(after) != len(before) {
+		t.Errorf("Expected no new transactions, got %d more", len(after)-len(before))
+	}
+	assignments, err := handler.Store.GetAssignmentsByEntity(ctx, "emp-term")
+	if err != nil {
+		t.Fatalf("Failed to get assignments: %v", err)
+	}
+	if len(assignments) != 1 || assignments[0].EffectiveTo == nil ||
+		!assignments[0].EffectiveTo.Equal(time.Date(2025, 3, 31, 0, 0, 0, 0, time.UTC)) {
+		t.Errorf("Assignment should still end on March 31, got %+v", assignments)
+	}
+}
+
+// flakyAssignmentStore fails SaveAssignment the first time, after a
+// termination's settlement has been posted.
+type flakyAssignmentStore struct {
+	Store
+	failed bool
+}
+
+func (s *flakyAssignmentStore) SaveAssignment(ctx context.Context, a sqlite.AssignmentRecord) error {
+	if !s.failed {
+		s.failed = true
+		return fmt.Errorf("database is locked")
+	}
+	return s.Store.SaveAssignment(ctx, a)
+}
+
+func TestTerminateEmployee_RetryFinishesAndRejectsPending(t *testing.T) {
+	// GIVEN: An employee with a pending day before the termination date,
+	//        and a store whose first assignment write fails
+	// WHEN: Terminating, then retrying
+	// THEN: The first attempt fails after posting the settlement, the retry
+	//       ends the assignment with the same statement, the pending day is
+	//       reversed and its request rejected, and the payout is not
+	//       reduced by it
+
+	handler := setupTestHandler(t)
+	ctx := context.Background()
+
+	if err := handler.createPolicyFromJSON(ctx, timeoff.StandardPTOJSON("pto-test", "Test PTO", 24, 5)); err != nil {
+		t.Fatalf("Failed to create policy: %v", err)
+	}
+	hireDate := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	if err := handler.Store.SaveEmployee(ctx, sqlite.Employee{ID: "emp-term", Name: "Leaving User", HireDate: hireDate}); err != nil {
+		t.Fatalf("Failed to create employee: %v", err)
+	}
+	if err := handler.Store.SaveAssignment(ctx, sqlite.AssignmentRecord{
+		ID: "assign-term", EntityID: "emp-term", PolicyID: "pto-test",
+		EffectiveFrom: hireDate, ConsumptionPriority: 1,
+	}); err != nil {
+		t.Fatalf("Failed to save assignment: %v", err)
+	}
+
+	now := time.Now()
+	pendingDay := time.Date(2025, 3, 20, 0, 0, 0, 0, time.UTC)
+	if err := handler.Store.SaveRequest(ctx, sqlite.Request{
+		ID: "req-pending", EntityID: "emp-term", ResourceType: "pto", Amount: 1, Unit: "days",
+		EffectiveAt: pendingDay, Status: "pending", RequiresApproval: true, CreatedAt: now, UpdatedAt: now,
+	}); err != nil {
+		t.Fatalf("Failed to save request: %v", err)
+	}
+	if err := handler.Store.AppendBatch(ctx, []generic.Transaction{{
+		ID: "tx-pending", EntityID: "emp-term", PolicyID: "pto-test",
+		ResourceType: timeoff.ResourcePTO, EffectiveAt: generic.TimePoint{Time: pendingDay},
+		Delta: generic.NewAmount(-1, generic.UnitDays), Type: generic.TxPending,
+		ReferenceID: "req-pending", IdempotencyKey: "tx-pending",
+	}}); err != nil {
+		t.Fatalf("Failed to append pending: %v", err)
+	}
+
+	handler.Store = &flakyAssignmentStore{Store: handler.Store}
+	router := NewRouter(handler)
+	terminate := func() *httptest.ResponseRecorder {
+		body := strings.NewReader(`{"termination_date":"2025-03-31","reason":"resignation"}`)
+		req := httptest.NewRequest(http.MethodPost, "/api/employees/emp-term/terminate", body)
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, req)
+		return rec
+	}
+
+	if rec := terminate(); rec.Code != http.StatusInternalServerError {
+		t.Fatalf("Expected 500 from the failing assignment write, got %d: %s", rec.Code, rec.Body.String())
+	}
+	rec := terminate()
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Expected retry to finish with 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+
+	var stmt TerminationStatementDTO
+	if err := json.Unmarshal(rec.Body.Bytes(), &stmt); err != nil {
+		t.Fatalf("Failed to decode statement: %v", err)
+	}
+	if len(stmt.Policies) != 1 {
+		t.Fatalf("Expected 1 policy settlement, got %d", len(stmt.Policies))
+	}
+	ps := stmt.Policies[0]
+	if ps.FinalBalance != ps.AccruedToDate || ps.FinalBalance <= 0 {
+		t.Errorf("Expected final balance %.2f (all accrued, pending day not deducted), got %.2f", ps.AccruedToDate, ps.FinalBalance)
+	}
+
+	reversed, err := handler.Store.IsTransactionReversed(ctx, "tx-pending")
+	if err != nil {
+		t.Fatalf("Failed to check reversal: %v", err)
+	}
+	if !reversed {
+		t.Error("Pending day before termination should be reversed")
+	}
+	request, err := handler.Store.GetRequest(ctx, "req-pending")
+	if err != nil || request == nil {
+		t.Fatalf("Failed to get request: %v", err)
+	}
+	if request.Status != "rejected" {
+		t.Errorf("Expected pending request rejected, got %s", request.Status)
+	}
+
+	assignments, err := handler.Store.GetAssignmentsByEntity(ctx, "emp-term")
+	if err != nil {
+		t.Fatalf("Failed to get assignments: %v", err)
+	}
+	if len(assignments) != 1 || assignments[0].EffectiveTo == nil {
+		t.Errorf("Assignment should be ended by the retry, got %+v", assignments)
+	}
+
+	if rec := terminate(); rec.Code != http.StatusConflict {
+		t.Errorf("Expected 409 once terminated, got %d", rec.Code)
+	}
+}
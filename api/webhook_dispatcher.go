@@ -0,0 +1,255 @@
+/*
+webhook_dispatcher.go - Delivers outbox events to webhook endpoints
+
+PURPOSE:
+  Ledger appends record an outbox event in the same SQL transaction (see
+  store/sqlite/sqlite.go). The dispatcher turns those events into signed
+  HTTP POSTs to every registered endpoint that subscribes to the event
+  type, retrying failures with exponential backoff until the delivery is
+  marked dead (the dead-letter state; admins can replay it).
+
+DESIGN:
+  - Runs a background goroutine, like the reconciliation scheduler
+  - Each run, per tenant:
+    1. DispatchOutbox: undispatched events -> one pending delivery per
+       matching active endpoint
+    2. DueWebhookDeliveries: POST each due delivery and record the outcome
+  - Delivery is at-least-once: receivers deduplicate on X-Webhook-Delivery
+    (or the event's "id" in the body)
+
+RETRIES:
+  2xx                         -> delivered
+  other status, network error -> retry after BaseBackoff * 2^(attempts-1),
+                                 capped at MaxBackoff
+  MaxAttempts reached         -> dead
+  endpoint disabled           -> dead
+
+REQUEST:
+  POST <endpoint url>
+  Content-Type:        application/json
+  X-Webhook-Event:     request.approved
+  X-Webhook-Delivery:  dlv-...
+  X-Webhook-Signature: t=1735689600,v1=<hex HMAC-SHA256(secret, "t.body")>
+  Body: {"id":"evt-...","type":"request.approved","tenant_id":"acme",
+         "occurred_at":"...","actor":{...},"data":{...},"transactions":[...]}
+
+  Receivers verify the signature with the endpoint's secret and reject
+  old timestamps to prevent replays.
+
+SEE ALSO:
+  - webhooks.go: admin endpoints (register, deliveries, replay)
+  - generic/event.go: event types
+*/
+package api
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/warp/resource-engine/generic"
+	"github.com/warp/resource-engine/store/sqlite"
+)
+
+// WebhookDispatcher delivers outbox events to webhook endpoints.
+type WebhookDispatcher struct {
+	Store        Store
+	Client       *http.Client
+	PollInterval time.Duration // How often to look for events (default: 5s)
+	MaxAttempts  int           // Attempts before a delivery is dead (default: 8)
+	BaseBackoff  time.Duration // Delay after the first failure (default: 30s)
+	MaxBackoff   time.Duration // Longest delay between attempts (default: 1h)
+	BatchSize    int           // Events and deliveries per tenant per run (default: 100)
+
+	now    func() time.Time
+	ticker *time.Ticker
+	stop   chan bool
+	wg     sync.WaitGroup
+	mu     sync.Mutex
+}
+
+// NewWebhookDispatcher creates a dispatcher with default settings.
+func NewWebhookDispatcher(store Store) *WebhookDispatcher {
+	return &WebhookDispatcher{
+		Store:        store,
+		Client:       &http.Client{Timeout: 10 * time.Second},
+		PollInterval: 5 * time.Second,
+		MaxAttempts:  8,
+		BaseBackoff:  30 * time.Second,
+		MaxBackoff:   time.Hour,
+		BatchSize:    100,
+		now:          time.Now,
+		stop:         make(chan bool),
+	}
+}
+
+// Start begins delivering in the background.
+func (d *WebhookDispatcher) Start() {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	d.ticker = time.NewTicker(d.PollInterval)
+	d.wg.Add(1)
+
+	go func() {
+		defer d.wg.Done()
+		for {
+			select {
+			case <-d.ticker.C:
+				d.RunOnce(context.Background())
+			case <-d.stop:
+				return
+			}
+		}
+	}()
+
+	log.Printf("[Webhooks] Started with poll interval: %v", d.PollInterval)
+}
+
+// Stop stops the dispatcher, waiting for an in-flight run to finish.
+func (d *WebhookDispatcher) Stop() {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if d.ticker != nil {
+		d.ticker.Stop()
+		close(d.stop)
+		d.wg.Wait()
+		log.Println("[Webhooks] Stopped")
+	}
+}
+
+// RunOnce dispatches new events and attempts due deliveries for every
+// tenant. Returns the number of deliveries attempted.
+func (d *WebhookDispatcher) RunOnce(ctx context.Context) int {
+	ctx = generic.WithActor(ctx, generic.SystemActor)
+
+	attempted := 0
+	for _, tenantCtx := range tenantContexts(ctx, d.Store, "[Webhooks]") {
+		attempted += d.runTenant(tenantCtx)
+	}
+	return attempted
+}
+
+func (d *WebhookDispatcher) runTenant(ctx context.Context) int {
+	if _, err := d.Store.DispatchOutbox(ctx, d.BatchSize); err != nil {
+		log.Printf("[Webhooks] Error dispatching outbox (tenant %q): %v", generic.TenantFrom(ctx), err)
+		return 0
+	}
+
+	deliveries, err := d.Store.DueWebhookDeliveries(ctx, d.now(), d.BatchSize)
+	if err != nil {
+		log.Printf("[Webhooks] Error loading deliveries (tenant %q): %v", generic.TenantFrom(ctx), err)
+		return 0
+	}
+
+	endpoints := make(map[string]*sqlite.WebhookEndpoint)
+	for i := range deliveries {
+		delivery := &deliveries[i]
+		endpoint, ok := endpoints[delivery.EndpointID]
+		if !ok {
+			endpoint, err = d.Store.GetWebhookEndpoint(ctx, delivery.EndpointID)
+			if err != nil {
+				log.Printf("[Webhooks] Error loading endpoint %s: %v", delivery.EndpointID, err)
+				continue
+			}
+			endpoints[delivery.EndpointID] = endpoint
+		}
+
+		d.attempt(ctx, endpoint, delivery)
+		if err := d.Store.SaveWebhookDelivery(ctx, *delivery); err != nil {
+			log.Printf("[Webhooks] Error saving delivery %s: %v", delivery.ID, err)
+		}
+	}
+	return len(deliveries)
+}
+
+// attempt sends one delivery and records the outcome on it.
+func (d *WebhookDispatcher) attempt(ctx context.Context, endpoint *sqlite.WebhookEndpoint, delivery *sqlite.WebhookDelivery) {
+	if endpoint == nil || !endpoint.Active {
+		delivery.Status = sqlite.DeliveryDead
+		delivery.NextAttemptAt = nil
+		delivery.LastError = "endpoint disabled"
+		return
+	}
+
+	now := d.now()
+	delivery.Attempts++
+	status, err := d.post(ctx, endpoint, delivery, now)
+	delivery.LastStatusCode = status
+
+	switch {
+	case err == nil && status >= 200 && status < 300:
+		delivery.Status = sqlite.DeliveryDelivered
+		delivery.DeliveredAt = &now
+		delivery.NextAttemptAt = nil
+		delivery.LastError = ""
+		return
+	case err != nil:
+		delivery.LastError = err.Error()
+	default:
+		delivery.LastError = fmt.Sprintf("endpoint returned %d", status)
+	}
+
+	if delivery.Attempts >= d.MaxAttempts {
+		delivery.Status = sqlite.DeliveryDead
+		delivery.NextAttemptAt = nil
+		log.Printf("[Webhooks] Delivery %s to %s is dead after %d attempts: %s",
+			delivery.ID, endpoint.URL, delivery.Attempts, delivery.LastError)
+		return
+	}
+	next := now.Add(d.backoff(delivery.Attempts))
+	delivery.NextAttemptAt = &next
+}
+
+func (d *WebhookDispatcher) post(ctx context.Context, endpoint *sqlite.WebhookEndpoint, delivery *sqlite.WebhookDelivery, now time.Time) (int, error) {
+	body := []byte(delivery.Payload)
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint.URL, bytes.NewReader(body))
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "warp-webhooks/1")
+	req.Header.Set("X-Webhook-Event", string(delivery.EventType))
+	req.Header.Set("X-Webhook-Delivery", delivery.ID)
+	req.Header.Set("X-Webhook-Signature", SignWebhook(endpoint.Secret, now.Unix(), body))
+
+	resp, err := d.Client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+	return resp.StatusCode, nil
+}
+
+// backoff returns the delay after the given number of failed attempts.
+func (d *WebhookDispatcher) backoff(attempts int) time.Duration {
+	delay := d.BaseBackoff
+	for i := 1; i < attempts && delay < d.MaxBackoff; i++ {
+		delay *= 2
+	}
+	if delay > d.MaxBackoff {
+		delay = d.MaxBackoff
+	}
+	return delay
+}
+
+// SignWebhook returns the X-Webhook-Signature value for a body:
+// "t=<unix seconds>,v1=<hex HMAC-SHA256 of "<t>.<body>">".
+func SignWebhook(secret string, timestamp int64, body []byte) string {
+	t := strconv.FormatInt(timestamp, 10)
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(t + "."))
+	mac.Write(body)
+	return "t=" + t + ",v1=" + hex.EncodeToString(mac.Sum(nil))
+}
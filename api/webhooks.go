@@ -0,0 +1,304 @@
+/*
+webhooks.go - Webhook endpoint administration
+
+PURPOSE:
+  Lets admins register the URLs that receive ledger events (payroll,
+  chat notifications), choose which event types each receives, inspect
+  deliveries and replay them. Delivery itself is done by the
+  WebhookDispatcher (webhook_dispatcher.go).
+
+EVENT TYPES (generic/event.go):
+  request.submitted, request.approved, request.rejected, day.cancelled,
+  rollover.posted, adjustment.posted, employee.terminated,
+  transactions.posted (any other ledger append, e.g. demo scenarios)
+
+ENDPOINTS (admin only):
+  GET    /api/admin/webhooks                        List endpoints
+  POST   /api/admin/webhooks                        Register endpoint; the
+                                                    response carries the
+                                                    signing secret, once
+  DELETE /api/admin/webhooks/{id}                   Disable endpoint
+  GET    /api/admin/webhooks/{id}/deliveries        Deliveries (?status=)
+  POST   /api/admin/webhooks/{id}/replay            Requeue dead deliveries,
+                                                    or {"since": RFC3339}
+                                                    to (re)send every event
+                                                    from then on
+  POST   /api/admin/webhooks/deliveries/{id}/replay Requeue one delivery
+  GET    /api/admin/webhooks/events                 Recent outbox events
+
+SEE ALSO:
+  - webhook_dispatcher.go: delivery, retries, signatures
+  - store/sqlite/sqlite.go: outbox_events, webhook_* tables
+*/
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/warp/resource-engine/generic"
+	"github.com/warp/resource-engine/store/sqlite"
+)
+
+// ListWebhookEndpoints returns all webhook endpoints (without secrets).
+// GET /api/admin/webhooks
+func (h *Handler) ListWebhookEndpoints(w http.ResponseWriter, r *http.Request) {
+	endpoints, err := h.Store.ListWebhookEndpoints(r.Context())
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "Failed to list webhook endpoints", err)
+		return
+	}
+
+	dtos := make([]WebhookEndpointDTO, len(endpoints))
+	for i, e := range endpoints {
+		dtos[i] = toWebhookEndpointDTO(e)
+	}
+	writeJSON(w, http.StatusOK, dtos)
+}
+
+// CreateWebhookEndpoint registers an endpoint and returns its signing
+// secret. The secret is not shown again.
+// POST /api/admin/webhooks
+func (h *Handler) CreateWebhookEndpoint(w http.ResponseWriter, r *http.Request) {
+	var req CreateWebhookEndpointRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "Invalid request body", err)
+		return
+	}
+
+	u, err := url.Parse(req.URL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		writeError(w, http.StatusBadRequest, "url must be an absolute http(s) URL", err)
+		return
+	}
+
+	var eventTypes []generic.EventType
+	for _, t := range req.EventTypes {
+		if !isEventType(generic.EventType(t)) {
+			writeError(w, http.StatusBadRequest, fmt.Sprintf("Unknown event type %q", t), nil)
+			return
+		}
+		eventTypes = append(eventTypes, generic.EventType(t))
+	}
+
+	secret, err := newFeedToken()
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "Failed to generate secret", err)
+		return
+	}
+
+	endpoint := sqlite.WebhookEndpoint{
+		ID:          fmt.Sprintf("wh-%d", time.Now().UnixNano()),
+		URL:         req.URL,
+		Secret:      secret,
+		EventTypes:  eventTypes,
+		Description: req.Description,
+		Active:      true,
+		CreatedAt:   time.Now(),
+	}
+	if err := h.Store.SaveWebhookEndpoint(r.Context(), endpoint); err != nil {
+		writeError(w, http.StatusInternalServerError, "Failed to save webhook endpoint", err)
+		return
+	}
+
+	dto := toWebhookEndpointDTO(endpoint)
+	dto.Secret = endpoint.Secret
+	writeJSON(w, http.StatusCreated, dto)
+}
+
+// DisableWebhookEndpoint stops deliveries to an endpoint. Its pending
+// deliveries become dead; history is kept.
+// DELETE /api/admin/webhooks/{id}
+func (h *Handler) DisableWebhookEndpoint(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	endpoint, err := h.Store.GetWebhookEndpoint(ctx, chi.URLParam(r, "id"))
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "Failed to get webhook endpoint", err)
+		return
+	}
+	if endpoint == nil {
+		writeError(w, http.StatusNotFound, "Webhook endpoint not found", nil)
+		return
+	}
+
+	endpoint.Active = false
+	if err := h.Store.SaveWebhookEndpoint(ctx, *endpoint); err != nil {
+		writeError(w, http.StatusInternalServerError, "Failed to disable webhook endpoint", err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// ListWebhookDeliveries returns an endpoint's deliveries, newest first.
+// GET /api/admin/webhooks/{id}/deliveries?status=dead&limit=100
+func (h *Handler) ListWebhookDeliveries(w http.ResponseWriter, r *http.Request) {
+	status := r.URL.Query().Get("status")
+	if status != "" && status != sqlite.DeliveryPending && status != sqlite.DeliveryDelivered && status != sqlite.DeliveryDead {
+		writeError(w, http.StatusBadRequest, "status must be pending, delivered or dead", nil)
+		return
+	}
+
+	deliveries, err := h.Store.ListWebhookDeliveries(r.Context(), chi.URLParam(r, "id"), status, queryLimit(r, 100))
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "Failed to list deliveries", err)
+		return
+	}
+
+	dtos := make([]WebhookDeliveryDTO, len(deliveries))
+	for i, d := range deliveries {
+		dtos[i] = toWebhookDeliveryDTO(d)
+	}
+	writeJSON(w, http.StatusOK, dtos)
+}
+
+// ReplayWebhookEndpoint requeues an endpoint's dead deliveries, or with
+// "since" every event it accepts from that time on.
+// POST /api/admin/webhooks/{id}/replay
+func (h *Handler) ReplayWebhookEndpoint(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	var req ReplayWebhookRequest
+	if r.ContentLength != 0 {
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			writeError(w, http.StatusBadRequest, "Invalid request body", err)
+			return
+		}
+	}
+	var since *time.Time
+	if req.Since != "" {
+		t, err := time.Parse(time.RFC3339, req.Since)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, "Invalid since (RFC3339)", err)
+			return
+		}
+		since = &t
+	}
+
+	endpoint, err := h.Store.GetWebhookEndpoint(ctx, chi.URLParam(r, "id"))
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "Failed to get webhook endpoint", err)
+		return
+	}
+	if endpoint == nil {
+		writeError(w, http.StatusNotFound, "Webhook endpoint not found", nil)
+		return
+	}
+
+	queued, err := h.Store.ReplayWebhookDeliveries(ctx, endpoint.ID, since)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "Failed to replay deliveries", err)
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]any{"queued": queued})
+}
+
+// ReplayWebhookDelivery requeues one delivery, whatever its status.
+// POST /api/admin/webhooks/deliveries/{id}/replay
+func (h *Handler) ReplayWebhookDelivery(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	id := chi.URLParam(r, "id")
+
+	found, err := h.Store.RequeueWebhookDelivery(ctx, id)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "Failed to replay delivery", err)
+		return
+	}
+	if !found {
+		writeError(w, http.StatusNotFound, "Delivery not found", nil)
+		return
+	}
+
+	delivery, err := h.Store.GetWebhookDelivery(ctx, id)
+	if err != nil || delivery == nil {
+		writeError(w, http.StatusInternalServerError, "Failed to get delivery", err)
+		return
+	}
+	writeJSON(w, http.StatusOK, toWebhookDeliveryDTO(*delivery))
+}
+
+// ListOutboxEvents returns recent ledger events, newest first.
+// GET /api/admin/webhooks/events?limit=100
+func (h *Handler) ListOutboxEvents(w http.ResponseWriter, r *http.Request) {
+	events, err := h.Store.ListOutboxEvents(r.Context(), queryLimit(r, 100))
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "Failed to list events", err)
+		return
+	}
+
+	dtos := make([]OutboxEventDTO, len(events))
+	for i, e := range events {
+		dtos[i] = OutboxEventDTO{
+			ID:        e.ID,
+			Type:      string(e.Type),
+			CreatedAt: e.CreatedAt.Format(time.RFC3339),
+			Payload:   json.RawMessage(e.Payload),
+		}
+		if e.DispatchedAt != nil {
+			dtos[i].DispatchedAt = strPtr(e.DispatchedAt.Format(time.RFC3339))
+		}
+	}
+	writeJSON(w, http.StatusOK, dtos)
+}
+
+func isEventType(t generic.EventType) bool {
+	for _, known := range generic.EventTypes {
+		if t == known {
+			return true
+		}
+	}
+	return false
+}
+
+// queryLimit parses ?limit=, between 1 and 1000.
+func queryLimit(r *http.Request, fallback int) int {
+	limit, err := strconv.Atoi(r.URL.Query().Get("limit"))
+	if err != nil || limit < 1 {
+		return fallback
+	}
+	if limit > 1000 {
+		return 1000
+	}
+	return limit
+}
+
+func toWebhookEndpointDTO(e sqlite.WebhookEndpoint) WebhookEndpointDTO {
+	types := make([]string, len(e.EventTypes))
+	for i, t := range e.EventTypes {
+		types[i] = string(t)
+	}
+	return WebhookEndpointDTO{
+		ID:          e.ID,
+		URL:         e.URL,
+		EventTypes:  types,
+		Description: e.Description,
+		Active:      e.Active,
+		CreatedAt:   e.CreatedAt.Format(time.RFC3339),
+	}
+}
+
+func toWebhookDeliveryDTO(d sqlite.WebhookDelivery) WebhookDeliveryDTO {
+	dto := WebhookDeliveryDTO{
+		ID:             d.ID,
+		EndpointID:     d.EndpointID,
+		EventID:        d.EventID,
+		EventType:      string(d.EventType),
+		Status:         d.Status,
+		Attempts:       d.Attempts,
+		LastStatusCode: d.LastStatusCode,
+		LastError:      d.LastError,
+		CreatedAt:      d.CreatedAt.Format(time.RFC3339),
+	}
+	if d.NextAttemptAt != nil {
+		dto.NextAttemptAt = strPtr(d.NextAttemptAt.Format(time.RFC3339))
+	}
+	if d.DeliveredAt != nil {
+		dto.DeliveredAt = strPtr(d.DeliveredAt.Format(time.RFC3339))
+	}
+	return dto
+}
@@ -0,0 +1,328 @@
+/*
+webhooks_test.go - Unit tests for the outbox and webhooks
+
+Tests for:
+- Outbox and webhooks (AppendBatchWithEvent, WebhookDispatcher, replay)
+*/
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/warp/resource-engine/generic"
+	"github.com/warp/resource-engine/store/sqlite"
+	"github.com/warp/resource-engine/timeoff"
+)
+
+func TestWebhooks_OutboxDeliversSignedEventsWithRetries(t *testing.T) {
+	// GIVEN: An endpoint subscribed to day.cancelled, and a failing
+	//        endpoint subscribed to every event
+	// WHEN: A booked day is cancelled and the dispatcher runs
+	// THEN: Each append records exactly one event with its transactions,
+	//       the receiver can verify the signature, the failing endpoint
+	//       backs off until its deliveries are dead, and replay requeues them
+
+	handler := setupTestHandler(t)
+	router := NewRouter(handler)
+	ctx := context.Background()
+
+	if err := handler.createPolicyFromJSON(ctx, timeoff.StandardPTOJSON("pto-test", "Test PTO", 24, 5)); err != nil {
+		t.Fatalf("Failed to create policy: %v", err)
+	}
+	hireDate := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	if err := handler.Store.SaveEmployee(ctx, sqlite.Employee{ID: "emp-hook", Name: "Hook User", HireDate: hireDate}); err != nil {
+		t.Fatalf("Failed to create employee: %v", err)
+	}
+	if err := handler.Store.SaveAssignment(ctx, sqlite.AssignmentRecord{
+		ID: "assign-hook", EntityID: "emp-hook", PolicyID: "pto-test",
+		EffectiveFrom: hireDate, ConsumptionPriority: 1,
+	}); err != nil {
+		t.Fatalf("Failed to save assignment: %v", err)
+	}
+
+	type received struct {
+		event, signature string
+		body             []byte
+	}
+	var mu sync.Mutex
+	var good []received
+	failing := true
+	goodServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		mu.Lock()
+		good = append(good, received{r.Header.Get("X-Webhook-Event"), r.Header.Get("X-Webhook-Signature"), body})
+		mu.Unlock()
+	}))
+	defer goodServer.Close()
+	badServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		if failing {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+	}))
+	defer badServer.Close()
+
+	do := func(method, path, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, req)
+		return rec
+	}
+	register := func(body string) WebhookEndpointDTO {
+		t.Helper()
+		rec := do(http.MethodPost, "/api/admin/webhooks", body)
+		if rec.Code != http.StatusCreated {
+			t.Fatalf("Expected 201, got %d: %s", rec.Code, rec.Body.String())
+		}
+		var dto WebhookEndpointDTO
+		if err := json.Unmarshal(rec.Body.Bytes(), &dto); err != nil {
+			t.Fatalf("Failed to decode endpoint: %v", err)
+		}
+		if dto.Secret == "" {
+			t.Fatal("Expected the signing secret on creation")
+		}
+		return dto
+	}
+
+	if rec := do(http.MethodPost, "/api/admin/webhooks", `{"url":"ftp://example.com"}`); rec.Code != http.StatusBadRequest {
+		t.Errorf("Expected 400 for a non-http URL, got %d", rec.Code)
+	}
+	if rec := do(http.MethodPost, "/api/admin/webhooks", `{"url":"https://example.com","event_types":["nope"]}`); rec.Code != http.StatusBadRequest {
+		t.Errorf("Expected 400 for an unknown event type, got %d", rec.Code)
+	}
+	goodEndpoint := register(`{"url":"` + goodServer.URL + `","event_types":["day.cancelled"]}`)
+	badEndpoint := register(`{"url":"` + badServer.URL + `"}`)
+
+	// One plain append and one cancellation: two events
+	if err := handler.Store.AppendBatch(ctx, []generic.Transaction{{
+		ID: "tx-hook", EntityID: "emp-hook", PolicyID: "pto-test",
+		ResourceType: timeoff.ResourcePTO, EffectiveAt: generic.TimePoint{Time: time.Date(2025, 6, 2, 0, 0, 0, 0, time.UTC)},
+		Delta: generic.NewAmount(-1, generic.UnitDays), Type: generic.TxConsumption, IdempotencyKey: "tx-hook",
+	}}); err != nil {
+		t.Fatalf("Failed to append consumption: %v", err)
+	}
+	if rec := do(http.MethodDelete, "/api/transactions/tx-hook", ""); rec.Code != http.StatusOK {
+		t.Fatalf("Expected 200 on cancel, got %d: %s", rec.Code, rec.Body.String())
+	}
+
+	// A rejected append records no event
+	if err := handler.Store.AppendBatchWithEvent(ctx, []generic.Transaction{{
+		ID: "tx-hook-2", EntityID: "emp-hook", PolicyID: "pto-test",
+		ResourceType: timeoff.ResourcePTO, EffectiveAt: generic.TimePoint{Time: time.Date(2025, 6, 3, 0, 0, 0, 0, time.UTC)},
+		Delta: generic.NewAmount(-1, generic.UnitDays), Type: generic.TxConsumption, IdempotencyKey: "tx-hook",
+	}}, generic.EventAdjustmentPosted, nil); err == nil {
+		t.Fatal("Expected duplicate idempotency key to be rejected")
+	}
+
+	rec := do(http.MethodGet, "/api/admin/webhooks/events", "")
+	var events []OutboxEventDTO
+	if err := json.Unmarshal(rec.Body.Bytes(), &events); err != nil {
+		t.Fatalf("Failed to decode events: %v", err)
+	}
+	if len(events) != 2 {
+		t.Fatalf("Expected 2 events, got %d: %s", len(events), rec.Body.String())
+	}
+	var cancelled struct {
+		Type         string         `json:"type"`
+		Data         map[string]any `json:"data"`
+		Transactions []struct {
+			ID   string `json:"id"`
+			Type string `json:"type"`
+		} `json:"transactions"`
+	}
+	if err := json.Unmarshal(events[0].Payload, &cancelled); err != nil {
+		t.Fatalf("Failed to decode payload: %v", err)
+	}
+	if cancelled.Type != string(generic.EventDayCancelled) || cancelled.Data["transaction_id"] != "tx-hook" {
+		t.Errorf("Expected day.cancelled for tx-hook, got %s %v", cancelled.Type, cancelled.Data)
+	}
+	if len(cancelled.Transactions) != 1 || cancelled.Transactions[0].ID != "reversal-tx-hook" {
+		t.Errorf("Expected the reversal in the event, got %+v", cancelled.Transactions)
+	}
+
+	dispatcher := NewWebhookDispatcher(handler.Store)
+	dispatcher.MaxAttempts = 3
+	clock := time.Now()
+	dispatcher.now = func() time.Time { return clock }
+
+	if n := dispatcher.RunOnce(ctx); n != 3 {
+		t.Fatalf("Expected 3 attempts (1 good, 2 failing), got %d", n)
+	}
+	if len(good) != 1 || good[0].event != string(generic.EventDayCancelled) {
+		t.Fatalf("Expected one day.cancelled delivery, got %+v", good)
+	}
+	var ts int64
+	fmt.Sscanf(good[0].signature, "t=%d,", &ts)
+	if want := SignWebhook(goodEndpoint.Secret, ts, good[0].body); good[0].signature != want {
+		t.Errorf("Signature %q does not verify (want %q)", good[0].signature, want)
+	}
+
+	// Backoff: 30s, then 60s; the third failure is dead
+	if n := dispatcher.RunOnce(ctx); n != 0 {
+		t.Errorf("Expected no attempts before backoff, got %d", n)
+	}
+	clock = clock.Add(30 * time.Second)
+	if n := dispatcher.RunOnce(ctx); n != 2 {
+		t.Errorf("Expected 2 retries after 30s, got %d", n)
+	}
+	clock = clock.Add(30 * time.Second)
+	if n := dispatcher.RunOnce(ctx); n != 0 {
+		t.Errorf("Expected second backoff to be 60s, got %d attempts", n)
+	}
+	clock = clock.Add(30 * time.Second)
+	if n := dispatcher.RunOnce(ctx); n != 2 {
+		t.Errorf("Expected 2 final attempts, got %d", n)
+	}
+
+	rec = do(http.MethodGet, "/api/admin/webhooks/"+badEndpoint.ID+"/deliveries?status=dead", "")
+	var dead []WebhookDeliveryDTO
+	if err := json.Unmarshal(rec.Body.Bytes(), &dead); err != nil {
+		t.Fatalf("Failed to decode deliveries: %v", err)
+	}
+	if len(dead) != 2 {
+		t.Fatalf("Expected 2 dead deliveries, got %d: %s", len(dead), rec.Body.String())
+	}
+	if dead[0].Attempts != 3 || dead[0].LastStatusCode != http.StatusServiceUnavailable {
+		t.Errorf("Expected 3 attempts ending in 503, got %+v", dead[0])
+	}
+
+	// Replay: the endpoint's dead deliveries, then one delivery by ID
+	mu.Lock()
+	failing = false
+	mu.Unlock()
+	rec = do(http.MethodPost, "/api/admin/webhooks/"+badEndpoint.ID+"/replay", "")
+	if rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), `"queued":2`) {
+		t.Fatalf("Expected 2 queued, got %d: %s", rec.Code, rec.Body.String())
+	}
+	clock = time.Now()
+	if n := dispatcher.RunOnce(ctx); n != 2 {
+		t.Errorf("Expected 2 replayed attempts, got %d", n)
+	}
+	delivered, _ := handler.Store.ListWebhookDeliveries(ctx, badEndpoint.ID, sqlite.DeliveryDelivered, 10)
+	if len(delivered) != 2 {
+		t.Errorf("Expected 2 delivered after replay, got %d", len(delivered))
+	}
+
+	rec = do(http.MethodPost, "/api/admin/webhooks/deliveries/"+dead[0].ID+"/replay", "")
+	if rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), `"status":"pending"`) {
+		t.Errorf("Expected delivery requeued, got %d: %s", rec.Code, rec.Body.String())
+	}
+	if rec := do(http.MethodPost, "/api/admin/webhooks/deliveries/dlv-missing/replay", ""); rec.Code != http.StatusNotFound {
+		t.Errorf("Expected 404 for unknown delivery, got %d", rec.Code)
+	}
+
+	// A disabled endpoint gets nothing more
+	if rec := do(http.MethodDelete, "/api/admin/webhooks/"+badEndpoint.ID, ""); rec.Code != http.StatusNoContent {
+		t.Errorf("Expected 204 on disable, got %d", rec.Code)
+	}
+	if n := dispatcher.RunOnce(ctx); n != 1 {
+		t.Errorf("Expected the requeued delivery to be settled, got %d", n)
+	}
+	if d, _ := handler.Store.GetWebhookDelivery(ctx, dead[0].ID); d == nil || d.Status != sqlite.DeliveryDead {
+		t.Errorf("Expected delivery to a disabled endpoint to be dead, got %+v", d)
+	}
+}
+
+func TestWebhooks_TypedEventsAndReplaySince(t *testing.T) {
+	// GIVEN: A submitted request, a pending request, and an endpoint
+	//        registered after both
+	// WHEN: The pending request is approved, then the endpoint replays
+	//       since the start
+	// THEN: Approval records request.approved (even without new transactions
+	//       of its own), and replay backfills events from before registration
+
+	handler := setupTestHandler(t)
+	router := NewRouter(handler)
+	ctx := context.Background()
+
+	if err := handler.createPolicyFromJSON(ctx, timeoff.StandardPTOJSON("pto-test", "Test PTO", 24, 5)); err != nil {
+		t.Fatalf("Failed to create policy: %v", err)
+	}
+	hireDate := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	if err := handler.Store.SaveEmployee(ctx, sqlite.Employee{ID: "emp-typed", Name: "Typed User", HireDate: hireDate}); err != nil {
+		t.Fatalf("Failed to create employee: %v", err)
+	}
+	if err := handler.Store.SaveAssignment(ctx, sqlite.AssignmentRecord{
+		ID: "assign-typed", EntityID: "emp-typed", PolicyID: "pto-test",
+		EffectiveFrom: hireDate, ConsumptionPriority: 1,
+	}); err != nil {
+		t.Fatalf("Failed to save assignment: %v", err)
+	}
+
+	do := func(method, path, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, req)
+		return rec
+	}
+
+	rec := do(http.MethodPost, "/api/employees/emp-typed/requests", `{"resource_type":"pto","days":["2025-06-02"]}`)
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("Expected 201, got %d: %s", rec.Code, rec.Body.String())
+	}
+	now := time.Now()
+	if err := handler.Store.SaveRequest(ctx, sqlite.Request{
+		ID: "req-typed", EntityID: "emp-typed", ResourceType: "pto", Amount: 1, Unit: "days",
+		EffectiveAt: time.Date(2025, 7, 1, 0, 0, 0, 0, time.UTC),
+		Status:      "pending", RequiresApproval: true, CreatedAt: now, UpdatedAt: now,
+	}); err != nil {
+		t.Fatalf("Failed to save request: %v", err)
+	}
+	if rec := do(http.MethodPost, "/api/requests/req-typed/approve", `{"approver_id":"mgr"}`); rec.Code != http.StatusOK {
+		t.Fatalf("Expected 200 on approve, got %d: %s", rec.Code, rec.Body.String())
+	}
+
+	events, err := handler.Store.ListOutboxEvents(ctx, 10)
+	if err != nil {
+		t.Fatalf("Failed to list events: %v", err)
+	}
+	var types []string
+	for _, e := range events {
+		types = append(types, string(e.Type))
+	}
+	if strings.Join(types, ",") != "request.approved,request.submitted" {
+		t.Fatalf("Expected approved then submitted (newest first), got %v", types)
+	}
+
+	var bodies []string
+	var mu sync.Mutex
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		mu.Lock()
+		bodies = append(bodies, string(body))
+		mu.Unlock()
+	}))
+	defer server.Close()
+
+	// Events before registration are dispatched without deliveries
+	dispatcher := NewWebhookDispatcher(handler.Store)
+	dispatcher.RunOnce(ctx)
+
+	rec = do(http.MethodPost, "/api/admin/webhooks", `{"url":"`+server.URL+`","event_types":["request.approved"]}`)
+	var endpoint WebhookEndpointDTO
+	json.Unmarshal(rec.Body.Bytes(), &endpoint)
+	if n := dispatcher.RunOnce(ctx); n != 0 {
+		t.Fatalf("Expected no deliveries for old events, got %d", n)
+	}
+
+	rec = do(http.MethodPost, "/api/admin/webhooks/"+endpoint.ID+"/replay", `{"since":"2000-01-01T00:00:00Z"}`)
+	if !strings.Contains(rec.Body.String(), `"queued":1`) {
+		t.Fatalf("Expected 1 approved event queued, got %d: %s", rec.Code, rec.Body.String())
+	}
+	if n := dispatcher.RunOnce(ctx); n != 1 {
+		t.Fatalf("Expected 1 delivery, got %d", n)
+	}
+	if len(bodies) != 1 || !strings.Contains(bodies[0], `"approved_by":"mgr"`) {
+		t.Errorf("Expected the approval event to be delivered, got %v", bodies)
+	}
+}
@@ -7,22 +7,41 @@ PURPOSE:
 
 STARTUP SEQUENCE:
   1. Parse command-line flags
-  2. Initialize SQLite store
-  3. Create API handler with dependencies
-  4. Configure HTTP router
-  5. Start server with graceful shutdown
+  2. Open the store (SQLite or embedded key-value, -store)
+  3. Create API handler with dependencies (and authenticators)
+  4. Start webhook dispatcher (delivers outbox events)
+  5. Start reconciliation scheduler (-scheduler; only the replica holding
+     the leader lease reconciles, see api/scheduler.go)
+  6. Configure HTTP router
+  7. Start server with graceful shutdown
 
 COMMAND-LINE FLAGS:
   -port    HTTP server port (default: 8080)
-  -db      SQLite database path (default: timeoff.db)
+  -store   Storage backend: "sqlite" (default) or "kv"
+           kv is the pure-Go embedded store (store/kv) and needs no
+           CGO; its -db file is a log, not an SQLite database
+  -db      Database path (default: timeoff.db)
            Use ":memory:" for in-memory database
+  -scheduler  Run period-end reconciliation in the background (default:
+              true). Replicas sharing a database elect one leader.
+  -auth-tokens      Static API token file ("token role subject [tenant]"
+                    per line, see api/auth.go)
+  -jwt-secret-file  File holding the HS256 secret for JWT bearer tokens
+  -jwt-public-key   PEM public key or certificate for RS256/ES256 JWTs
+  -jwt-issuer       Required JWT "iss" claim (optional)
+  -jwt-audience     Required JWT "aud" claim (optional)
+
+  Without -auth-tokens or a JWT key, authentication is disabled and every
+  API request is allowed. Never run a shared server that way.
 
 GRACEFUL SHUTDOWN:
   On SIGINT/SIGTERM:
   1. Stop accepting new connections
   2. Wait for active requests to complete (30s timeout)
-  3. Close database connection
-  4. Exit
+  3. Stop webhook dispatcher (undelivered events stay in the outbox)
+  4. Stop reconciliation scheduler (releases its leader lease)
+  5. Close database connection
+  6. Exit
 
 EXAMPLES:
   # Run with file database
@@ -31,9 +50,15 @@ EXAMPLES:
   # Run with in-memory database
   ./server -db=":memory:"
 
+  # Run on the embedded key-value store
+  ./server -store=kv -db="./data/warp.kv"
+
   # Run on different port
   ./server -port=3000
 
+  # Require bearer tokens (static tokens or HS256 JWTs)
+  ./server -auth-tokens=./tokens.txt -jwt-secret-file=./jwt.key
+
 ENVIRONMENT:
   No environment variables currently. All config via flags.
   Future: DATABASE_URL, PORT, LOG_LEVEL
@@ -42,10 +67,12 @@ SEE ALSO:
   - api/server.go: Router configuration
   - api/handlers.go: HTTP handlers
   - store/sqlite/sqlite.go: Database implementation
+  - store/kv/store.go: Embedded key-value implementation
 */
 package main
 
 import (
+	"bytes"
 	"context"
 	"flag"
 	"fmt"
@@ -57,17 +84,25 @@ import (
 	"time"
 
 	"github.com/warp/resource-engine/api"
+	"github.com/warp/resource-engine/store/kv"
 	"github.com/warp/resource-engine/store/sqlite"
 )
 
 func main() {
 	// Flags
 	port := flag.Int("port", 8080, "HTTP server port")
-	dbPath := flag.String("db", "timeoff.db", "SQLite database path")
+	backend := flag.String("store", "sqlite", "Storage backend (sqlite or kv)")
+	dbPath := flag.String("db", "timeoff.db", "Database path")
+	runScheduler := flag.Bool("scheduler", true, "Run period-end reconciliation")
+	tokenFile := flag.String("auth-tokens", "", "Static API token file")
+	jwtSecretFile := flag.String("jwt-secret-file", "", "HS256 JWT secret file")
+	jwtPublicKey := flag.String("jwt-public-key", "", "RS256/ES256 JWT public key (PEM)")
+	jwtIssuer := flag.String("jwt-issuer", "", "Required JWT issuer")
+	jwtAudience := flag.String("jwt-audience", "", "Required JWT audience")
 	flag.Parse()
 
 	// Initialize store
-	store, err := sqlite.New(*dbPath)
+	store, err := openStore(*backend, *dbPath)
 	if err != nil {
 		log.Fatalf("Failed to initialize database: %v", err)
 	}
@@ -75,12 +110,32 @@ func main() {
 
 	// Initialize handler
 	handler := api.NewHandler(store)
+
+	// Configure authentication
+	auth, err := loadAuthenticators(*tokenFile, *jwtSecretFile, *jwtPublicKey, *jwtIssuer, *jwtAudience)
+	if err != nil {
+		log.Fatalf("Failed to configure authentication: %v", err)
+	}
+	if len(auth) == 0 {
+		log.Printf("Warning: authentication disabled, all API endpoints are public")
+	} else {
+		handler.Auth = auth
+	}
 	
 	// Load existing policies into cache
 	if err := handler.LoadPolicies(context.Background()); err != nil {
 		log.Printf("Warning: Failed to load policies: %v", err)
 	}
 
+	// Deliver ledger events to registered webhooks
+	dispatcher := api.NewWebhookDispatcher(store)
+	dispatcher.Start()
+
+	// Reconcile ended periods (the leader replica only)
+	scheduler := api.NewReconciliationScheduler(store, handler)
+	scheduler.Enabled = *runScheduler
+	scheduler.Start()
+
 	// Create router
 	router := api.NewRouter(handler)
 
@@ -115,6 +170,76 @@ func main() {
 	if err := server.Shutdown(ctx); err != nil {
 		log.Fatalf("Server forced to shutdown: %v", err)
 	}
+	dispatcher.Stop()
+	scheduler.Stop()
 
 	log.Println("Server stopped")
 }
+
+// closableStore is a backend the server owns and closes on exit.
+type closableStore interface {
+	api.Store
+	Close() error
+}
+
+// openStore opens the backend named by the -store flag.
+func openStore(backend, path string) (closableStore, error) {
+	switch backend {
+	case "sqlite":
+		return sqlite.New(path)
+	case "kv":
+		return kv.New(path)
+	default:
+		return nil, fmt.Errorf("unknown store %q (want sqlite or kv)", backend)
+	}
+}
+
+// loadAuthenticators builds the authenticators named by the auth flags.
+func loadAuthenticators(tokenFile, secretFile, publicKeyFile, issuer, audience string) (api.Authenticators, error) {
+	var auth api.Authenticators
+
+	if tokenFile != "" {
+		f, err := os.Open(tokenFile)
+		if err != nil {
+			return nil, err
+		}
+		defer f.Close()
+		tokens, err := api.LoadStaticTokens(f)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", tokenFile, err)
+		}
+		auth = append(auth, tokens)
+	}
+
+	var verifiers []*api.JWTVerifier
+	if secretFile != "" {
+		secret, err := os.ReadFile(secretFile)
+		if err != nil {
+			return nil, err
+		}
+		secret = bytes.TrimSpace(secret)
+		if len(secret) < 32 {
+			return nil, fmt.Errorf("%s: JWT secret must be at least 32 bytes", secretFile)
+		}
+		verifiers = append(verifiers, api.NewHMACVerifier(secret))
+	}
+	if publicKeyFile != "" {
+		pemBytes, err := os.ReadFile(publicKeyFile)
+		if err != nil {
+			return nil, err
+		}
+		verifier, err := api.NewPublicKeyVerifier(pemBytes)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", publicKeyFile, err)
+		}
+		verifiers = append(verifiers, verifier)
+	}
+	for _, v := range verifiers {
+		v.Issuer = issuer
+		v.Audience = audience
+		v.Leeway = time.Minute
+		auth = append(auth, v)
+	}
+
+	return auth, nil
+}
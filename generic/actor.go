@@ -0,0 +1,71 @@
+/*
+actor.go - The acting user via context
+
+PURPOSE:
+  Every write is made by someone: an employee submitting a request, a
+  manager approving it, an admin adjusting a balance, or the system
+  running reconciliation. The actor travels with the context, like the
+  tenant, and the store stamps it on every transaction it appends
+  (Transaction.CreatedBy / CreatedByType) unless the transaction already
+  names one.
+
+ACTOR TYPES:
+  employee   Acts for themselves
+  manager    Acts for themselves and their direct reports
+  admin      Acts for anyone in the tenant
+  system     Background jobs and service accounts
+
+WHERE THE ACTOR COMES FROM:
+  - HTTP requests:  api.Handler.Authenticate (bearer token)
+  - Scheduler runs: SystemActor
+  - Tests/scripts:  WithActor(ctx, Actor{...})
+
+SEE ALSO:
+  - tenant.go: the same pattern for tenants
+  - api/auth.go: authentication and authorization
+*/
+package generic
+
+import "context"
+
+// Actor types, stored in Transaction.CreatedByType.
+const (
+	ActorEmployee = "employee"
+	ActorManager  = "manager"
+	ActorAdmin    = "admin"
+	ActorSystem   = "system"
+)
+
+// Actor identifies who performs an operation.
+type Actor struct {
+	ID   string // Employee ID, or a service name for system actors
+	Type string // ActorEmployee, ActorManager, ActorAdmin or ActorSystem
+}
+
+// SystemActor is the actor of background jobs (reconciliation scheduler).
+var SystemActor = Actor{ID: "scheduler", Type: ActorSystem}
+
+type actorKey struct{}
+
+// WithActor returns a context carrying an actor.
+func WithActor(ctx context.Context, actor Actor) context.Context {
+	return context.WithValue(ctx, actorKey{}, actor)
+}
+
+// ActorFrom returns the actor of a context, if any.
+func ActorFrom(ctx context.Context) (Actor, bool) {
+	actor, ok := ctx.Value(actorKey{}).(Actor)
+	return actor, ok
+}
+
+// Stamp fills a transaction's audit fields from the context's actor,
+// leaving fields that are already set alone.
+func (tx *Transaction) Stamp(ctx context.Context) {
+	if tx.CreatedBy != "" {
+		return
+	}
+	if actor, ok := ActorFrom(ctx); ok {
+		tx.CreatedBy = actor.ID
+		tx.CreatedByType = actor.Type
+	}
+}
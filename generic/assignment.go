@@ -155,8 +155,19 @@ type PolicyBalance struct {
 // =============================================================================
 
 type ResourceBalanceCalculator struct {
+	// Ledger supplies the transactions. A DefaultLedger made with
+	// AsKnownAt computes the balance as it was known at that moment.
 	Ledger          Ledger
 	AssignmentStore AssignmentStore
+
+	// Accruals provides computed accrual schedules per policy. Optional:
+	// without it only TxGrant transactions count as accrued.
+	Accruals map[PolicyID]AccrualSchedule
+
+	// AsOfOnly ignores transactions effective after 'at'. Reports use this
+	// so a past date always reproduces the same numbers; request validation
+	// leaves it off so future bookings reduce what is available.
+	AsOfOnly bool
 }
 
 // Calculate computes the aggregate balance for a resource type
@@ -189,14 +200,22 @@ func (rbc *ResourceBalanceCalculator) Calculate(
 		period := assignment.Policy.PeriodConfig.PeriodFor(at)
 		
 		// Get transactions for this policy
+		to := period.End
+		if rbc.AsOfOnly && at.Before(to) {
+			to = at
+		}
 		txs, err := rbc.Ledger.TransactionsInRange(
-			ctx, entityID, assignment.PolicyID, period.Start, period.End,
+			ctx, entityID, assignment.PolicyID, period.Start, to,
 		)
 		if err != nil {
 			return nil, err
 		}
 
-		balance := rbc.calculatePolicyBalance(txs, period, assignment.Policy.Unit)
+		// Accrual starts at the assignment start, so mid-period hires are prorated
+		balance := ComputeBalance(txs, period, assignment.Policy.Unit,
+			rbc.Accruals[assignment.PolicyID], assignment.EffectiveFrom, at)
+		balance.EntityID = entityID
+		balance.PolicyID = assignment.PolicyID
 		
 		policyBalances = append(policyBalances, PolicyBalance{
 			Assignment: assignment,
@@ -213,49 +232,21 @@ func (rbc *ResourceBalanceCalculator) Calculate(
 		return policyBalances[i].Priority < policyBalances[j].Priority
 	})
 
+	var period Period
+	if len(policyBalances) > 0 {
+		period = policyBalances[0].Balance.Period // Use first policy's period
+	}
+
 	return &ResourceBalance{
 		EntityID:       entityID,
 		ResourceType:   resourceType,
-		Period:         policyBalances[0].Balance.Period, // Use first policy's period
+		Period:         period,
 		TotalAvailable: totalAvailable,
 		TotalPending:   totalPending,
 		PolicyBalances: policyBalances,
 	}, nil
 }
 
-func (rbc *ResourceBalanceCalculator) calculatePolicyBalance(txs []Transaction, period Period, unit Unit) Balance {
-	var (
-		accruals    = NewAmount(0, unit)
-		consumed    = NewAmount(0, unit)
-		pending     = NewAmount(0, unit)
-		adjustments = NewAmount(0, unit)
-	)
-
-	for _, tx := range txs {
-		switch tx.Type {
-		case TxGrant:
-			accruals = accruals.Add(tx.Delta)
-		case TxConsumption:
-			consumed = consumed.Add(tx.Delta.Neg())
-		case TxPending:
-			pending = pending.Add(tx.Delta.Neg())
-		case TxReconciliation, TxAdjustment:
-			adjustments = adjustments.Add(tx.Delta)
-		case TxReversal:
-			consumed = consumed.Sub(tx.Delta)
-		}
-	}
-
-	return Balance{
-		Period:           period,
-		AccruedToDate:    accruals,
-		TotalEntitlement: accruals,
-		TotalConsumed:    consumed,
-		Pending:          pending,
-		Adjustments:      adjustments,
-	}
-}
-
 // =============================================================================
 // CONSUMPTION DISTRIBUTOR - Splits consumption across policies
 // =============================================================================
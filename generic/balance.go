@@ -38,6 +38,11 @@ VALIDATION:
   2. Available() >= amount (unless AllowNegative)
   3. Returns ValidationError with details if invalid
 
+EXPLANATION:
+  ExplainBalance returns the same figures with their derivation: each
+  scheduled accrual, each transaction and the component it feeds, the
+  rule for the consumption mode, and the policy's constraints.
+
 SEE ALSO:
   - projection.go: Validates future requests against projected balance
   - assignment.go: Aggregates balance across multiple policies
@@ -163,7 +168,30 @@ func (bc *BalanceCalculator) CalculateBalance(
 		return Balance{}, err
 	}
 
-	// 2. Sum transactions by type
+	balance := ComputeBalance(txs, period, unit, accruals, period.Start, asOf)
+	balance.EntityID = entityID
+	balance.PolicyID = policyID
+	return balance, nil
+}
+
+// ComputeBalance sums transactions into a Balance for the period. It is the
+// one place balance arithmetic lives: the calculators above and in
+// assignment.go and snapshot.go, and the API handlers, all go through it.
+//
+// With an accrual schedule, accruals are generated from accrualStart (the
+// later of it and period.Start, so mid-period hires are prorated):
+//   - AccruedToDate: max of granted transactions and scheduled up to asOf
+//   - TotalEntitlement: scheduled up to period.End
+//
+// Without one, both are the granted transactions.
+func ComputeBalance(
+	txs []Transaction,
+	period Period,
+	unit Unit,
+	accruals AccrualSchedule, // nil for non-deterministic
+	accrualStart TimePoint,
+	asOf TimePoint,
+) Balance {
 	var (
 		actualAccruals = NewAmount(0, unit)
 		consumed       = NewAmount(0, unit)
@@ -188,15 +216,17 @@ func (bc *BalanceCalculator) CalculateBalance(
 		}
 	}
 
-	// 3. Calculate accrued-to-date and total entitlement
 	accruedToDate := actualAccruals
 	totalEntitlement := actualAccruals
 
 	if accruals != nil {
+		if accrualStart.Before(period.Start) {
+			accrualStart = period.Start
+		}
+
 		// Accrued to date: only accruals up to 'asOf'
-		accruedEvents := accruals.GenerateAccruals(period.Start, asOf)
 		accruedTotal := NewAmount(0, unit)
-		for _, e := range accruedEvents {
+		for _, e := range accruals.GenerateAccruals(accrualStart, asOf) {
 			accruedTotal = accruedTotal.Add(e.Amount)
 		}
 		// Use max of actual transactions and projected (in case accruals recorded early)
@@ -204,25 +234,190 @@ func (bc *BalanceCalculator) CalculateBalance(
 			accruedToDate = accruedTotal
 		}
 
-		// Total entitlement: all accruals for the full period
-		allEvents := accruals.GenerateAccruals(period.Start, period.End)
+		// Total entitlement: all accruals for the rest of the period
 		entitlementTotal := NewAmount(0, unit)
-		for _, e := range allEvents {
+		for _, e := range accruals.GenerateAccruals(accrualStart, period.End) {
 			entitlementTotal = entitlementTotal.Add(e.Amount)
 		}
 		totalEntitlement = entitlementTotal
 	}
 
 	return Balance{
-		EntityID:         entityID,
-		PolicyID:         policyID,
 		Period:           period,
 		AccruedToDate:    accruedToDate,
 		TotalEntitlement: totalEntitlement,
 		TotalConsumed:    consumed,
 		Pending:          pending,
 		Adjustments:      adjustments,
-	}, nil
+	}
+}
+
+// =============================================================================
+// BALANCE EXPLANATION - "Why is my balance X?"
+// =============================================================================
+
+// Balance components a transaction or accrual feeds, as named in
+// BalanceExplanation.
+const (
+	ComponentAccrued     = "accrued"
+	ComponentEntitlement = "entitlement"
+	ComponentConsumed    = "consumed"
+	ComponentPending     = "pending"
+	ComponentAdjustments = "adjustments"
+)
+
+// BalanceExplanation itemizes how ComputeBalance and AvailableWithMode
+// arrived at a balance. Balance and Available are the figures themselves;
+// the rest is the derivation.
+type BalanceExplanation struct {
+	Balance   Balance
+	Mode      ConsumptionMode
+	Available Amount
+
+	// Scheduled accruals in the period; ToDate marks those up to asOf
+	Accruals []ExplainedAccrual
+
+	// Granted transactions, and whether AccruedToDate is the schedule
+	// (the larger of the two) rather than the grants
+	Granted             Amount
+	AccruedFromSchedule bool
+
+	Transactions []ExplainedTransaction
+
+	// Rule is the availability formula for Mode
+	Rule        string
+	Constraints []ConstraintCheck
+}
+
+// ExplainedAccrual is a scheduled accrual event.
+type ExplainedAccrual struct {
+	AccrualEvent
+	ToDate bool
+}
+
+// ExplainedTransaction is a transaction and the component it feeds. Effect
+// is its signed contribution to that component (consumption of 2 days is
+// +2 consumed).
+type ExplainedTransaction struct {
+	Transaction
+	Component string
+	Effect    Amount
+}
+
+// ConstraintCheck is one policy constraint evaluated against the balance.
+type ConstraintCheck struct {
+	Name   string // allow_negative, min_balance, max_balance, max_request_size
+	Limit  *Amount
+	Value  Amount
+	Passed bool
+	Detail string
+}
+
+// ExplainBalance computes the balance exactly as ComputeBalance does and
+// itemizes it: the accrual events, each transaction's component, the
+// consumption-mode rule and the policy's constraints.
+func ExplainBalance(
+	txs []Transaction,
+	period Period,
+	unit Unit,
+	accruals AccrualSchedule,
+	accrualStart TimePoint,
+	asOf TimePoint,
+	mode ConsumptionMode,
+	constraints Constraints,
+) BalanceExplanation {
+	balance := ComputeBalance(txs, period, unit, accruals, accrualStart, asOf)
+	ex := BalanceExplanation{
+		Balance:   balance,
+		Mode:      mode,
+		Available: balance.AvailableWithMode(mode),
+		Granted:   NewAmount(0, unit),
+	}
+
+	for _, tx := range txs {
+		item := ExplainedTransaction{Transaction: tx}
+		switch tx.Type {
+		case TxGrant:
+			item.Component, item.Effect = ComponentAccrued, tx.Delta
+			ex.Granted = ex.Granted.Add(tx.Delta)
+		case TxConsumption:
+			item.Component, item.Effect = ComponentConsumed, tx.Delta.Neg()
+		case TxPending:
+			item.Component, item.Effect = ComponentPending, tx.Delta.Neg()
+		case TxReconciliation, TxAdjustment:
+			item.Component, item.Effect = ComponentAdjustments, tx.Delta
+		case TxReversal:
+			item.Component, item.Effect = ComponentConsumed, tx.Delta.Neg()
+		default:
+			continue // not part of the balance
+		}
+		ex.Transactions = append(ex.Transactions, item)
+	}
+
+	if accruals != nil {
+		if accrualStart.Before(period.Start) {
+			accrualStart = period.Start
+		}
+		// The same two calls as ComputeBalance; an event is to date if the
+		// first one generated it
+		key := func(e AccrualEvent) string {
+			return e.At.String() + "|" + e.Amount.Value.String() + "|" + e.Reason
+		}
+		toDate := make(map[string]bool)
+		for _, e := range accruals.GenerateAccruals(accrualStart, asOf) {
+			toDate[key(e)] = true
+		}
+		for _, e := range accruals.GenerateAccruals(accrualStart, period.End) {
+			ex.Accruals = append(ex.Accruals, ExplainedAccrual{AccrualEvent: e, ToDate: toDate[key(e)]})
+		}
+		ex.AccruedFromSchedule = balance.AccruedToDate.GreaterThan(ex.Granted)
+	}
+
+	switch mode {
+	case ConsumeUpToAccrued:
+		ex.Rule = "available = accrued_to_date - consumed + adjustments - pending"
+	default:
+		ex.Rule = "available = total_entitlement - consumed + adjustments - pending"
+	}
+
+	negative := ConstraintCheck{
+		Name:   "allow_negative",
+		Value:  ex.Available,
+		Passed: constraints.AllowNegative || !ex.Available.IsNegative(),
+		Detail: "available must not be negative",
+	}
+	if constraints.AllowNegative {
+		negative.Detail = "negative balance allowed"
+	}
+	ex.Constraints = append(ex.Constraints, negative)
+	if constraints.MinBalance != nil {
+		ex.Constraints = append(ex.Constraints, ConstraintCheck{
+			Name:   "min_balance",
+			Limit:  constraints.MinBalance,
+			Value:  ex.Available,
+			Passed: !ex.Available.LessThan(*constraints.MinBalance),
+			Detail: "available must not fall below the minimum",
+		})
+	}
+	if constraints.MaxBalance != nil {
+		ex.Constraints = append(ex.Constraints, ConstraintCheck{
+			Name:   "max_balance",
+			Limit:  constraints.MaxBalance,
+			Value:  balance.Current(),
+			Passed: !balance.Current().GreaterThan(*constraints.MaxBalance),
+			Detail: "balance (entitlement - consumed + adjustments) must not exceed the cap",
+		})
+	}
+	if constraints.MaxRequestSize != nil {
+		ex.Constraints = append(ex.Constraints, ConstraintCheck{
+			Name:   "max_request_size",
+			Limit:  constraints.MaxRequestSize,
+			Value:  ex.Available,
+			Passed: true,
+			Detail: "checked per request: a single request may not exceed the limit",
+		})
+	}
+	return ex
 }
 
 // =============================================================================
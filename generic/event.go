@@ -0,0 +1,57 @@
+/*
+event.go - Ledger event types
+
+PURPOSE:
+  Downstream systems (payroll, chat notifications) react to ledger
+  changes. Every append to the ledger records one event in the store's
+  outbox, in the same database transaction as the ledger rows, so an
+  event exists if and only if its transactions were committed. The
+  webhook dispatcher (api/webhook_dispatcher.go) delivers them.
+
+EVENT TYPES:
+  Writers that know what happened name the event; plain appends record
+  EventTransactionsPosted. The live stream (api/events.go) also carries
+  transaction.appended and reconciliation.completed, which are not
+  recorded in the outbox.
+
+SEE ALSO:
+  - store/sqlite/sqlite.go: outbox_events, AppendBatchWithEvent
+  - api/webhooks.go: webhook endpoints and deliveries
+*/
+package generic
+
+// EventType names a ledger event, e.g. "request.approved".
+type EventType string
+
+const (
+	EventTransactionsPosted EventType = "transactions.posted"  // Any other ledger append
+	EventRequestSubmitted   EventType = "request.submitted"    // Time off requested (pending or auto-approved)
+	EventRequestApproved    EventType = "request.approved"     // Pending request approved
+	EventRequestRejected    EventType = "request.rejected"     // Pending request rejected
+	EventDayCancelled       EventType = "day.cancelled"        // One booked day reversed
+	EventRolloverPosted     EventType = "rollover.posted"      // Period-end reconciliation posted
+	EventAdjustmentPosted   EventType = "adjustment.posted"    // Manual balance adjustment
+	EventEmployeeTerminated EventType = "employee.terminated"  // Final settlement posted
+	EventRolloverRolledBack EventType = "rollover.rolled_back" // Reconciliation run reversed
+	EventRolloverCorrected  EventType = "rollover.corrected"   // Reconciled period recomputed after a backdated write
+)
+
+// Live stream only (api/events.go); not recorded in the outbox.
+const (
+	EventTransactionAppended     EventType = "transaction.appended"     // Ledger rows appended for one entity
+	EventReconciliationCompleted EventType = "reconciliation.completed" // Rollover done for one entity and policy
+)
+
+// EventTypes lists every outbox event type, for validating webhook filters.
+var EventTypes = []EventType{
+	EventTransactionsPosted,
+	EventRequestSubmitted,
+	EventRequestApproved,
+	EventRequestRejected,
+	EventDayCancelled,
+	EventRolloverPosted,
+	EventAdjustmentPosted,
+	EventEmployeeTerminated,
+	EventRolloverRolledBack,
+	EventRolloverCorrected,
+}
@@ -0,0 +1,420 @@
+/*
+holiday_import.go - Parse holiday calendars from iCalendar and CSV files
+
+PURPOSE:
+  Offices publish their official holiday calendars as .ics files (Google,
+  Outlook, government sites) or spreadsheets. These parsers turn both into
+  Holiday values ready for storage. They do no I/O beyond reading the input
+  and never touch the store; deduplication happens at import time.
+
+ICALENDAR:
+  Each VEVENT becomes one holiday per day it covers:
+  - DTSTART;VALUE=DATE:20251225              -> Dec 25
+  - DTSTART/DTEND spanning several days      -> one holiday per day
+  - RRULE:FREQ=YEARLY (no BY* parts)         -> Recurring = true
+  - RRULE:FREQ=YEARLY;BYMONTH=11;BYDAY=4TH   -> Rule "4th thu nov"
+  - Any other RRULE                          -> only DTSTART, with a warning
+  - STATUS:CANCELLED                         -> skipped
+  Folded lines and TEXT escapes are handled. Date-times are reduced to
+  their calendar date.
+
+CSV:
+  Header row required. Columns are matched by name, case-insensitive:
+    date (required)       YYYY-MM-DD
+    name (required)
+    recurring (optional)  true/false/yes/no/1/0
+    rule (optional)       e.g. "4th thu nov", "easter-2" (see holiday_rules.go)
+
+  With a rule, date may be left empty; it defaults to the rule's date in
+  the current year.
+
+  Example:
+    date,name,recurring,rule
+    2025-01-01,New Year's Day,true,1-1 observed
+    2025-04-18,Good Friday,false,
+    ,Thanksgiving,,4th thu nov
+
+ERRORS:
+  Malformed rows do not abort the parse. They are returned as
+  HolidayParseIssue values with line numbers so a dry-run can show them.
+  Only an unreadable input or a missing CSV header returns an error.
+
+SEE ALSO:
+  - time.go: Holiday, HolidayCalendar
+  - holiday_rules.go: HolidayRule
+  - store/sqlite/sqlite.go: ImportHolidays
+*/
+package generic
+
+import (
+	"bufio"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// HolidayParseIssue describes a row that could not be imported as-is.
+type HolidayParseIssue struct {
+	Line    int
+	Message string
+}
+
+func (i HolidayParseIssue) String() string {
+	return fmt.Sprintf("line %d: %s", i.Line, i.Message)
+}
+
+// =============================================================================
+// ICALENDAR
+// =============================================================================
+
+// ParseHolidaysICS parses VEVENTs from an iCalendar file.
+func ParseHolidaysICS(r io.Reader, companyID string) ([]Holiday, []HolidayParseIssue, error) {
+	lines, err := unfoldICSLines(r)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var (
+		holidays []Holiday
+		issues   []HolidayParseIssue
+		event    map[string]icsProp
+		startAt  int
+	)
+
+	for _, l := range lines {
+		switch {
+		case l.text == "BEGIN:VEVENT":
+			event = make(map[string]icsProp)
+			startAt = l.num
+		case l.text == "END:VEVENT":
+			if event == nil {
+				continue
+			}
+			hs, eventIssues := icsEventToHolidays(event, companyID, startAt)
+			holidays = append(holidays, hs...)
+			issues = append(issues, eventIssues...)
+			event = nil
+		case event != nil:
+			name, prop := parseICSProp(l.text)
+			prop.line = l.num
+			if _, seen := event[name]; !seen {
+				event[name] = prop
+			}
+		}
+	}
+
+	return holidays, issues, nil
+}
+
+type icsLine struct {
+	num  int // Line number of the first physical line
+	text string
+}
+
+type icsProp struct {
+	params map[string]string
+	value  string
+	line   int
+}
+
+// unfoldICSLines joins continuation lines (RFC 5545 3.1).
+func unfoldICSLines(r io.Reader) ([]icsLine, error) {
+	var lines []icsLine
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	num := 0
+	for scanner.Scan() {
+		num++
+		text := strings.TrimRight(scanner.Text(), "\r")
+		if (strings.HasPrefix(text, " ") || strings.HasPrefix(text, "\t")) && len(lines) > 0 {
+			lines[len(lines)-1].text += text[1:]
+			continue
+		}
+		if text != "" {
+			lines = append(lines, icsLine{num: num, text: text})
+		}
+	}
+	return lines, scanner.Err()
+}
+
+// parseICSProp splits "NAME;PARAM=X:value" into name, params and value.
+func parseICSProp(text string) (string, icsProp) {
+	prop := icsProp{params: make(map[string]string)}
+	colon := strings.Index(text, ":")
+	if colon < 0 {
+		return strings.ToUpper(text), prop
+	}
+	head, value := text[:colon], text[colon+1:]
+	prop.value = value
+
+	parts := strings.Split(head, ";")
+	for _, p := range parts[1:] {
+		if eq := strings.Index(p, "="); eq > 0 {
+			prop.params[strings.ToUpper(p[:eq])] = strings.Trim(p[eq+1:], `"`)
+		}
+	}
+	return strings.ToUpper(parts[0]), prop
+}
+
+func icsEventToHolidays(event map[string]icsProp, companyID string, line int) ([]Holiday, []HolidayParseIssue) {
+	if status, ok := event["STATUS"]; ok && strings.EqualFold(status.value, "CANCELLED") {
+		return nil, nil
+	}
+
+	name := unescapeICSText(event["SUMMARY"].value)
+	if name == "" {
+		return nil, []HolidayParseIssue{{Line: line, Message: "event has no SUMMARY"}}
+	}
+
+	startProp, ok := event["DTSTART"]
+	if !ok {
+		return nil, []HolidayParseIssue{{Line: line, Message: fmt.Sprintf("%q has no DTSTART", name)}}
+	}
+	start, err := parseICSDate(startProp.value)
+	if err != nil {
+		return nil, []HolidayParseIssue{{Line: startProp.line, Message: fmt.Sprintf("%q: %v", name, err)}}
+	}
+
+	// DTEND is exclusive for all-day events; default is a single day
+	end := start.AddDate(0, 0, 1)
+	if endProp, ok := event["DTEND"]; ok {
+		if e, err := parseICSDate(endProp.value); err == nil && e.After(start) {
+			end = e
+			// Timed events ending later the same day still cover that day
+			if startProp.params["VALUE"] != "DATE" && len(endProp.value) > 8 {
+				end = e.AddDate(0, 0, 1)
+			}
+		}
+	}
+
+	var issues []HolidayParseIssue
+	recurring := false
+	var rule *HolidayRule
+	if rrule, ok := event["RRULE"]; ok {
+		switch {
+		case isSimpleYearlyRule(rrule.value):
+			recurring = true
+		case weekdayRuleFromRRULE(rrule.value) != nil:
+			rule = weekdayRuleFromRRULE(rrule.value)
+			recurring = true
+		default:
+			issues = append(issues, HolidayParseIssue{
+				Line:    rrule.line,
+				Message: fmt.Sprintf("%q: RRULE %q is not a fixed yearly date; only %s imported", name, rrule.value, start.Format("2006-01-02")),
+			})
+		}
+	}
+
+	var holidays []Holiday
+	for d := start; d.Before(end); d = d.AddDate(0, 0, 1) {
+		holidays = append(holidays, Holiday{
+			CompanyID: companyID,
+			Date:      TimePoint{Time: d, Granularity: GranularityDay},
+			Name:      name,
+			Recurring: recurring,
+			Rule:      rule,
+		})
+	}
+	return holidays, issues
+}
+
+// parseICSDate accepts DATE (20251225) and DATE-TIME (20251225T000000[Z]).
+func parseICSDate(value string) (time.Time, error) {
+	if len(value) < 8 {
+		return time.Time{}, fmt.Errorf("invalid date %q", value)
+	}
+	t, err := time.Parse("20060102", value[:8])
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid date %q", value)
+	}
+	return t, nil
+}
+
+// isSimpleYearlyRule reports whether an RRULE repeats on the same month/day
+// every year (FREQ=YEARLY with at most INTERVAL=1, COUNT or UNTIL).
+func isSimpleYearlyRule(rule string) bool {
+	yearly := false
+	for _, part := range strings.Split(strings.ToUpper(rule), ";") {
+		kv := strings.SplitN(part, "=", 2)
+		if len(kv) != 2 {
+			return false
+		}
+		switch kv[0] {
+		case "FREQ":
+			yearly = kv[1] == "YEARLY"
+		case "INTERVAL":
+			if kv[1] != "1" {
+				return false
+			}
+		case "COUNT", "UNTIL", "WKST":
+		default:
+			return false
+		}
+	}
+	return yearly
+}
+
+// weekdayRuleFromRRULE converts "FREQ=YEARLY;BYMONTH=11;BYDAY=4TH" (or
+// BYDAY=-1MO for the last Monday) into a HolidayRule. Returns nil for
+// anything else.
+func weekdayRuleFromRRULE(rrule string) *HolidayRule {
+	var (
+		yearly bool
+		month  int
+		byDay  string
+	)
+	for _, part := range strings.Split(strings.ToUpper(rrule), ";") {
+		kv := strings.SplitN(part, "=", 2)
+		if len(kv) != 2 {
+			return nil
+		}
+		switch kv[0] {
+		case "FREQ":
+			yearly = kv[1] == "YEARLY"
+		case "BYMONTH":
+			month, _ = strconv.Atoi(kv[1])
+		case "BYDAY":
+			byDay = kv[1]
+		case "INTERVAL":
+			if kv[1] != "1" {
+				return nil
+			}
+		case "COUNT", "UNTIL", "WKST":
+		default:
+			return nil
+		}
+	}
+	if !yearly || month < 1 || month > 12 || len(byDay) < 3 {
+		return nil
+	}
+
+	n, err := strconv.Atoi(byDay[:len(byDay)-2])
+	if err != nil || n == 0 || n < -1 || n > 5 {
+		return nil
+	}
+	weekdays := map[string]time.Weekday{
+		"SU": time.Sunday, "MO": time.Monday, "TU": time.Tuesday, "WE": time.Wednesday,
+		"TH": time.Thursday, "FR": time.Friday, "SA": time.Saturday,
+	}
+	wd, ok := weekdays[byDay[len(byDay)-2:]]
+	if !ok {
+		return nil
+	}
+	return &HolidayRule{Kind: RuleNthWeekday, Month: time.Month(month), Weekday: wd, N: n}
+}
+
+func unescapeICSText(s string) string {
+	return strings.NewReplacer(`\n`, " ", `\N`, " ", `\,`, ",", `\;`, ";", `\\`, `\`).Replace(strings.TrimSpace(s))
+}
+
+// =============================================================================
+// CSV
+// =============================================================================
+
+// ErrMissingCSVHeader is returned when the CSV has no date/name header.
+var ErrMissingCSVHeader = errors.New("CSV header must include date and name columns")
+
+// ParseHolidaysCSV parses a CSV file with date, name and optional recurring columns.
+func ParseHolidaysCSV(r io.Reader, companyID string) ([]Holiday, []HolidayParseIssue, error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+
+	header, err := reader.Read()
+	if err == io.EOF {
+		return nil, nil, ErrMissingCSVHeader
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+
+	cols := map[string]int{"date": -1, "name": -1, "recurring": -1, "rule": -1}
+	for i, h := range header {
+		key := strings.ToLower(strings.TrimSpace(strings.TrimPrefix(h, "\ufeff")))
+		if _, ok := cols[key]; ok {
+			cols[key] = i
+		}
+	}
+	if cols["date"] < 0 || cols["name"] < 0 {
+		return nil, nil, ErrMissingCSVHeader
+	}
+
+	var (
+		holidays []Holiday
+		issues   []HolidayParseIssue
+	)
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		line, _ := reader.FieldPos(0)
+		if err != nil {
+			issues = append(issues, HolidayParseIssue{Line: line, Message: err.Error()})
+			continue
+		}
+
+		field := func(col string) string {
+			i := cols[col]
+			if i < 0 || i >= len(record) {
+				return ""
+			}
+			return strings.TrimSpace(record[i])
+		}
+
+		dateStr, name, ruleStr := field("date"), field("name"), field("rule")
+		if dateStr == "" && name == "" {
+			continue // Blank line
+		}
+		if name == "" {
+			issues = append(issues, HolidayParseIssue{Line: line, Message: "missing name"})
+			continue
+		}
+
+		var rule *HolidayRule
+		if ruleStr != "" {
+			r, err := ParseHolidayRule(ruleStr)
+			if err != nil {
+				issues = append(issues, HolidayParseIssue{Line: line, Message: fmt.Sprintf("%q: %v", name, err)})
+				continue
+			}
+			rule = &r
+		}
+
+		var date time.Time
+		if dateStr == "" && rule != nil {
+			date, _ = rule.DateIn(Today().Year())
+		} else {
+			d, err := time.Parse("2006-01-02", dateStr)
+			if err != nil {
+				issues = append(issues, HolidayParseIssue{Line: line, Message: fmt.Sprintf("%q: invalid date %q (use YYYY-MM-DD)", name, dateStr)})
+				continue
+			}
+			date = d
+		}
+
+		recurring := false
+		switch strings.ToLower(field("recurring")) {
+		case "", "false", "no", "0", "n":
+		case "true", "yes", "1", "y":
+			recurring = true
+		default:
+			issues = append(issues, HolidayParseIssue{Line: line, Message: fmt.Sprintf("%q: invalid recurring value %q", name, field("recurring"))})
+			continue
+		}
+
+		holidays = append(holidays, Holiday{
+			CompanyID: companyID,
+			Date:      TimePoint{Time: date, Granularity: GranularityDay},
+			Name:      name,
+			Recurring: recurring || rule != nil,
+			Rule:      rule,
+		})
+	}
+
+	return holidays, issues, nil
+}
@@ -0,0 +1,113 @@
+package generic_test
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/warp/resource-engine/generic"
+)
+
+func TestParseHolidaysICS_AllDayMultiDayAndRecurring(t *testing.T) {
+	// GIVEN: A yearly fixed-date event, a two-day event, a "4th Thursday"
+	//        rule, a monthly rule, a cancelled event, and a folded,
+	//        escaped summary
+	// WHEN: Parsing
+	// THEN: One holiday per day; fixed yearly is recurring; BYDAY becomes
+	//       a weekday rule; the monthly rule warns
+
+	ics := strings.Join([]string{
+		"BEGIN:VCALENDAR",
+		"VERSION:2.0",
+		"BEGIN:VEVENT",
+		"DTSTART;VALUE=DATE:20250101",
+		"DTEND;VALUE=DATE:20250102",
+		"RRULE:FREQ=YEARLY",
+		"SUMMARY:New Year's Day",
+		"END:VEVENT",
+		"BEGIN:VEVENT",
+		"DTSTART;VALUE=DATE:20251225",
+		"DTEND;VALUE=DATE:20251227",
+		"SUMMARY:Christmas\\, Boxing",
+		"  Day",
+		"END:VEVENT",
+		"BEGIN:VEVENT",
+		"DTSTART;VALUE=DATE:20251127",
+		"RRULE:FREQ=YEARLY;BYMONTH=11;BYDAY=4TH",
+		"SUMMARY:Thanksgiving",
+		"END:VEVENT",
+		"BEGIN:VEVENT",
+		"DTSTART;VALUE=DATE:20250115",
+		"RRULE:FREQ=MONTHLY",
+		"SUMMARY:Payday",
+		"END:VEVENT",
+		"BEGIN:VEVENT",
+		"DTSTART;VALUE=DATE:20250704",
+		"STATUS:CANCELLED",
+		"SUMMARY:Office closed",
+		"END:VEVENT",
+		"END:VCALENDAR",
+	}, "\r\n")
+
+	holidays, issues, err := generic.ParseHolidaysICS(strings.NewReader(ics), "acme")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(holidays) != 5 {
+		t.Fatalf("Expected 5 holidays, got %d: %+v", len(holidays), holidays)
+	}
+
+	if !holidays[0].Recurring || holidays[0].Name != "New Year's Day" {
+		t.Errorf("Expected recurring New Year's Day, got %+v", holidays[0])
+	}
+	if holidays[1].Name != "Christmas, Boxing Day" {
+		t.Errorf("Expected unfolded, unescaped summary, got %q", holidays[1].Name)
+	}
+	if !holidays[2].Date.Time.Equal(time.Date(2025, 12, 26, 0, 0, 0, 0, time.UTC)) {
+		t.Errorf("Expected second day Dec 26 (DTEND exclusive), got %v", holidays[2].Date.Time)
+	}
+	if holidays[3].Rule == nil || holidays[3].Rule.String() != "4th thu nov" {
+		t.Errorf("Expected Thanksgiving rule 4th thu nov, got %+v", holidays[3].Rule)
+	}
+	if holidays[4].Recurring {
+		t.Error("Monthly rule should not be imported as recurring")
+	}
+	if len(issues) != 1 || !strings.Contains(issues[0].Message, "Payday") {
+		t.Errorf("Expected one warning for Payday, got %v", issues)
+	}
+	for _, h := range holidays {
+		if h.CompanyID != "acme" {
+			t.Errorf("Expected company acme, got %q", h.CompanyID)
+		}
+	}
+}
+
+func TestParseHolidaysCSV_ReportsBadRowsWithLineNumbers(t *testing.T) {
+	csv := "Name,Date,Recurring\n" +
+		"New Year's Day,2025-01-01,yes\n" +
+		"Bad Date,01/04/2025,\n" +
+		"\n" +
+		"Good Friday,2025-04-18,\n"
+
+	holidays, issues, err := generic.ParseHolidaysCSV(strings.NewReader(csv), "acme")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(holidays) != 2 {
+		t.Fatalf("Expected 2 holidays, got %d", len(holidays))
+	}
+	if !holidays[0].Recurring || holidays[1].Recurring {
+		t.Errorf("Recurring flags not parsed: %+v", holidays)
+	}
+	if len(issues) != 1 || issues[0].Line != 3 {
+		t.Errorf("Expected one issue on line 3, got %v", issues)
+	}
+}
+
+func TestParseHolidaysCSV_RequiresHeader(t *testing.T) {
+	_, _, err := generic.ParseHolidaysCSV(strings.NewReader("2025-01-01,New Year's Day\n"), "acme")
+	if !errors.Is(err, generic.ErrMissingCSVHeader) {
+		t.Errorf("Expected ErrMissingCSVHeader, got %v", err)
+	}
+}
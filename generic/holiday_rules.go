@@ -0,0 +1,296 @@
+/*
+holiday_rules.go - Rule-based recurring holidays
+
+PURPOSE:
+  Most public holidays are not a fixed month and day. Thanksgiving is the
+  fourth Thursday of November, Memorial Day the last Monday of May, Good
+  Friday two days before Easter. And when a fixed-date holiday falls on a
+  weekend, offices usually observe it on the nearest weekday. A HolidayRule
+  describes one such holiday; ExpandHolidays turns rules into dates for a
+  given year.
+
+RULE SYNTAX (stored as text, case-insensitive):
+  12-25                 Fixed month-day
+  4th thu nov           Nth weekday of month (1st..5th)
+  last mon may          Last weekday of month
+  easter                Easter Sunday (Gregorian)
+  easter-2, easter+1    Days relative to Easter
+  ... observed          Saturday -> Friday, Sunday -> Monday
+
+  Examples:
+    "7-4 observed"      Independence Day (Fri Jul 3 2026)
+    "1st mon sep"       Labor Day
+    "easter+1"          Easter Monday
+
+OBSERVANCE ACROSS YEARS:
+  "1-1 observed" in 2022 falls on Saturday and is observed on Friday
+  Dec 31 2021. ExpandHolidays evaluates rules for the neighbouring years
+  too, so that day shows up in 2021's holidays, not 2022's.
+
+EXPANSION:
+  ExpandHolidays handles all three kinds of Holiday:
+  - Rule set:       date computed from the rule
+  - Recurring only: same month/day every year (no observance)
+  - Neither:        included only in its own year
+
+SEE ALSO:
+  - time.go: Holiday, HolidayCalendar, DefaultHolidayCalendar
+  - store/sqlite/sqlite.go: holidays.rule column
+*/
+package generic
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// HolidayRuleKind identifies how a rule computes its date.
+type HolidayRuleKind string
+
+const (
+	RuleFixed      HolidayRuleKind = "fixed"       // Month and day
+	RuleNthWeekday HolidayRuleKind = "nth_weekday" // Nth (or last) weekday of a month
+	RuleEaster     HolidayRuleKind = "easter"      // Offset from Easter Sunday
+)
+
+// HolidayRule computes a holiday's date in any year.
+type HolidayRule struct {
+	Kind     HolidayRuleKind
+	Month    time.Month   // RuleFixed, RuleNthWeekday
+	Day      int          // RuleFixed
+	Weekday  time.Weekday // RuleNthWeekday
+	N        int          // RuleNthWeekday: 1-5, or -1 for last
+	Offset   int          // RuleEaster: days from Easter Sunday
+	Observed bool         // Shift Saturday to Friday, Sunday to Monday
+}
+
+// DateIn returns the rule's actual date in a year, before observance.
+// ok is false when the date does not exist that year (e.g. 5th Monday,
+// Feb 29).
+func (r HolidayRule) DateIn(year int) (date time.Time, ok bool) {
+	switch r.Kind {
+	case RuleFixed:
+		date = time.Date(year, r.Month, r.Day, 0, 0, 0, 0, time.UTC)
+		return date, date.Month() == r.Month
+	case RuleNthWeekday:
+		if r.N == -1 {
+			last := time.Date(year, r.Month+1, 0, 0, 0, 0, 0, time.UTC)
+			back := (int(last.Weekday()) - int(r.Weekday) + 7) % 7
+			return last.AddDate(0, 0, -back), true
+		}
+		first := time.Date(year, r.Month, 1, 0, 0, 0, 0, time.UTC)
+		ahead := (int(r.Weekday) - int(first.Weekday()) + 7) % 7
+		date = first.AddDate(0, 0, ahead+7*(r.N-1))
+		return date, date.Month() == r.Month
+	case RuleEaster:
+		return Easter(year).AddDate(0, 0, r.Offset), true
+	}
+	return time.Time{}, false
+}
+
+// ObservedIn returns the day the holiday is taken off in a year, after
+// weekend observance.
+func (r HolidayRule) ObservedIn(year int) (time.Time, bool) {
+	date, ok := r.DateIn(year)
+	if !ok || !r.Observed {
+		return date, ok
+	}
+	switch date.Weekday() {
+	case time.Saturday:
+		return date.AddDate(0, 0, -1), true
+	case time.Sunday:
+		return date.AddDate(0, 0, 1), true
+	}
+	return date, true
+}
+
+// Easter returns Easter Sunday in the Gregorian calendar
+// (anonymous Gregorian algorithm).
+func Easter(year int) time.Time {
+	a := year % 19
+	b, c := year/100, year%100
+	d, e := b/4, b%4
+	f := (b + 8) / 25
+	g := (b - f + 1) / 3
+	h := (19*a + b - d - g + 15) % 30
+	i, k := c/4, c%4
+	l := (32 + 2*e + 2*i - h - k) % 7
+	m := (a + 11*h + 22*l) / 451
+	month := (h + l - 7*m + 114) / 31
+	day := (h+l-7*m+114)%31 + 1
+	return time.Date(year, time.Month(month), day, 0, 0, 0, 0, time.UTC)
+}
+
+// =============================================================================
+// PARSING
+// =============================================================================
+
+var (
+	ruleOrdinals = map[string]int{"1st": 1, "2nd": 2, "3rd": 3, "4th": 4, "5th": 5, "last": -1}
+	ruleWeekdays = map[string]time.Weekday{
+		"sun": time.Sunday, "mon": time.Monday, "tue": time.Tuesday, "wed": time.Wednesday,
+		"thu": time.Thursday, "fri": time.Friday, "sat": time.Saturday,
+	}
+	ruleMonths = map[string]time.Month{
+		"jan": time.January, "feb": time.February, "mar": time.March, "apr": time.April,
+		"may": time.May, "jun": time.June, "jul": time.July, "aug": time.August,
+		"sep": time.September, "oct": time.October, "nov": time.November, "dec": time.December,
+	}
+)
+
+// ParseHolidayRule parses the text form of a rule (see RULE SYNTAX).
+func ParseHolidayRule(s string) (HolidayRule, error) {
+	fields := strings.Fields(strings.ToLower(s))
+	var r HolidayRule
+	if n := len(fields); n > 0 && fields[n-1] == "observed" {
+		r.Observed = true
+		fields = fields[:n-1]
+	}
+
+	switch {
+	case len(fields) == 1 && strings.HasPrefix(fields[0], "easter"):
+		r.Kind = RuleEaster
+		if offset := strings.TrimPrefix(fields[0], "easter"); offset != "" {
+			n, err := strconv.Atoi(offset)
+			if err != nil || (offset[0] != '+' && offset[0] != '-') {
+				return HolidayRule{}, fmt.Errorf("invalid Easter offset in rule %q", s)
+			}
+			r.Offset = n
+		}
+
+	case len(fields) == 1:
+		parts := strings.Split(fields[0], "-")
+		if len(parts) != 2 {
+			return HolidayRule{}, fmt.Errorf("invalid holiday rule %q", s)
+		}
+		month, err1 := strconv.Atoi(parts[0])
+		day, err2 := strconv.Atoi(parts[1])
+		if err1 != nil || err2 != nil || month < 1 || month > 12 || day < 1 || day > 31 {
+			return HolidayRule{}, fmt.Errorf("invalid month-day in rule %q", s)
+		}
+		r.Kind, r.Month, r.Day = RuleFixed, time.Month(month), day
+
+	case len(fields) == 3:
+		n, ok1 := ruleOrdinals[fields[0]]
+		wd, ok2 := ruleWeekdays[fields[1][:min(3, len(fields[1]))]]
+		month, ok3 := ruleMonths[fields[2][:min(3, len(fields[2]))]]
+		if !ok1 || !ok2 || !ok3 {
+			return HolidayRule{}, fmt.Errorf("invalid weekday rule %q (e.g. \"4th thu nov\", \"last mon may\")", s)
+		}
+		r.Kind, r.N, r.Weekday, r.Month = RuleNthWeekday, n, wd, month
+
+	default:
+		return HolidayRule{}, fmt.Errorf("invalid holiday rule %q", s)
+	}
+	return r, nil
+}
+
+// String returns the rule's text form; ParseHolidayRule(r.String()) == r.
+func (r HolidayRule) String() string {
+	var s string
+	switch r.Kind {
+	case RuleFixed:
+		s = fmt.Sprintf("%d-%d", r.Month, r.Day)
+	case RuleNthWeekday:
+		ordinal := "last"
+		for k, v := range ruleOrdinals {
+			if v == r.N {
+				ordinal = k
+			}
+		}
+		s = fmt.Sprintf("%s %s %s", ordinal,
+			strings.ToLower(r.Weekday.String()[:3]), strings.ToLower(r.Month.String()[:3]))
+	case RuleEaster:
+		s = "easter"
+		if r.Offset != 0 {
+			s += fmt.Sprintf("%+d", r.Offset)
+		}
+	}
+	if r.Observed {
+		s += " observed"
+	}
+	return s
+}
+
+// =============================================================================
+// EXPANSION
+// =============================================================================
+
+// ExpandHolidays returns the holidays that fall in a year, with Date set to
+// the day actually taken off. Holidays moved by observance get
+// " (observed)" appended to their name. Result is sorted by date.
+func ExpandHolidays(holidays []Holiday, year int) []Holiday {
+	var result []Holiday
+	for _, h := range holidays {
+		switch {
+		case h.Rule != nil:
+			// Observance can move a date into the neighbouring year
+			for y := year - 1; y <= year+1; y++ {
+				observed, ok := h.Rule.ObservedIn(y)
+				if !ok || observed.Year() != year {
+					continue
+				}
+				e := h
+				e.Date = TimePoint{Time: observed, Granularity: GranularityDay}
+				if actual, _ := h.Rule.DateIn(y); !actual.Equal(observed) {
+					e.Name += " (observed)"
+				}
+				result = append(result, e)
+			}
+		case h.Recurring:
+			t := h.Date.Time
+			date := time.Date(year, t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+			if date.Month() != t.Month() {
+				continue // Feb 29 in a non-leap year
+			}
+			e := h
+			e.Date = TimePoint{Time: date, Granularity: GranularityDay}
+			result = append(result, e)
+		case h.Date.Time.Year() == year:
+			result = append(result, h)
+		}
+	}
+	sort.SliceStable(result, func(i, j int) bool {
+		return result[i].Date.Time.Before(result[j].Date.Time)
+	})
+	return result
+}
+
+// USFederalHolidays returns the US federal holidays as rules, observed on
+// the nearest weekday.
+func USFederalHolidays(companyID string) []Holiday {
+	rules := []struct{ name, rule string }{
+		{"New Year's Day", "1-1 observed"},
+		{"Martin Luther King Jr. Day", "3rd mon jan"},
+		{"Presidents' Day", "3rd mon feb"},
+		{"Memorial Day", "last mon may"},
+		{"Juneteenth", "6-19 observed"},
+		{"Independence Day", "7-4 observed"},
+		{"Labor Day", "1st mon sep"},
+		{"Columbus Day", "2nd mon oct"},
+		{"Veterans Day", "11-11 observed"},
+		{"Thanksgiving Day", "4th thu nov"},
+		{"Christmas Day", "12-25 observed"},
+	}
+
+	year := Today().Year()
+	holidays := make([]Holiday, 0, len(rules))
+	for _, r := range rules {
+		rule, err := ParseHolidayRule(r.rule)
+		if err != nil {
+			panic(err) // The list above is static
+		}
+		date, _ := rule.DateIn(year)
+		holidays = append(holidays, Holiday{
+			CompanyID: companyID,
+			Date:      TimePoint{Time: date, Granularity: GranularityDay},
+			Name:      r.name,
+			Recurring: true,
+			Rule:      &rule,
+		})
+	}
+	return holidays
+}
@@ -0,0 +1,95 @@
+package generic_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/warp/resource-engine/generic"
+)
+
+func date(year int, month time.Month, day int) time.Time {
+	return time.Date(year, month, day, 0, 0, 0, 0, time.UTC)
+}
+
+func TestHolidayRule_DatesInYear(t *testing.T) {
+	tests := []struct {
+		rule string
+		year int
+		want time.Time
+	}{
+		{"4th thu nov", 2025, date(2025, time.November, 27)}, // Thanksgiving
+		{"last mon may", 2025, date(2025, time.May, 26)},     // Memorial Day
+		{"1st mon sep", 2026, date(2026, time.September, 7)}, // Labor Day
+		{"easter", 2024, date(2024, time.March, 31)},
+		{"easter", 2025, date(2025, time.April, 20)},
+		{"easter-2", 2025, date(2025, time.April, 18)}, // Good Friday
+		{"easter+1", 2026, date(2026, time.April, 6)},  // Easter Monday
+		{"12-25", 2025, date(2025, time.December, 25)},
+		{"7-4 observed", 2026, date(2026, time.July, 3)},        // Saturday -> Friday
+		{"12-26 observed", 2027, date(2027, time.December, 27)}, // Sunday -> Monday
+	}
+
+	for _, tt := range tests {
+		rule, err := generic.ParseHolidayRule(tt.rule)
+		if err != nil {
+			t.Fatalf("%s: %v", tt.rule, err)
+		}
+		got, ok := rule.ObservedIn(tt.year)
+		if !ok || !got.Equal(tt.want) {
+			t.Errorf("%s in %d: expected %s, got %s", tt.rule, tt.year, tt.want.Format("2006-01-02"), got.Format("2006-01-02"))
+		}
+		if again, _ := generic.ParseHolidayRule(rule.String()); again != rule {
+			t.Errorf("%s: String() %q does not round-trip", tt.rule, rule.String())
+		}
+	}
+}
+
+func TestHolidayRule_RejectsInvalidRules(t *testing.T) {
+	for _, s := range []string{"", "13-01", "6th mon may", "4th xyz nov", "easter2", "thanksgiving"} {
+		if _, err := generic.ParseHolidayRule(s); err == nil {
+			t.Errorf("Expected error for %q", s)
+		}
+	}
+}
+
+func TestExpandHolidays_ObservanceCrossesYearBoundary(t *testing.T) {
+	// GIVEN: New Year's Day observed; Jan 1 2022 is a Saturday
+	// WHEN: Expanding 2021 and 2022
+	// THEN: Dec 31 2021 is in 2021 (as observed), 2022 has no New Year's Day
+
+	rule, _ := generic.ParseHolidayRule("1-1 observed")
+	holidays := []generic.Holiday{{Name: "New Year's Day", Recurring: true, Rule: &rule}}
+
+	in2021 := generic.ExpandHolidays(holidays, 2021)
+	if len(in2021) != 2 {
+		t.Fatalf("Expected Jan 1 and Dec 31 in 2021, got %d", len(in2021))
+	}
+	if !in2021[1].Date.Time.Equal(date(2021, time.December, 31)) || in2021[1].Name != "New Year's Day (observed)" {
+		t.Errorf("Expected observed New Year's Day on Dec 31 2021, got %s %s", in2021[1].Date, in2021[1].Name)
+	}
+	if in2022 := generic.ExpandHolidays(holidays, 2022); len(in2022) != 0 {
+		t.Errorf("Expected no New Year's Day in 2022, got %v", in2022)
+	}
+}
+
+func TestDefaultHolidayCalendar_UsesRules(t *testing.T) {
+	cal := &generic.DefaultHolidayCalendar{Holidays: generic.USFederalHolidays("")}
+
+	if !cal.IsHoliday("acme", generic.NewTimePoint(2026, time.July, 3)) {
+		t.Error("Expected Independence Day observed on Friday Jul 3 2026")
+	}
+	if cal.IsHoliday("acme", generic.NewTimePoint(2026, time.July, 4)) {
+		t.Error("Saturday Jul 4 2026 should not be the observed holiday")
+	}
+	if !cal.IsHoliday("acme", generic.NewTimePoint(2025, time.November, 27)) {
+		t.Error("Expected Thanksgiving on Nov 27 2025")
+	}
+	if got := len(cal.GetHolidays("acme", 2025)); got != 11 {
+		t.Errorf("Expected 11 federal holidays in 2025, got %d", got)
+	}
+
+	var disabled generic.DefaultHolidayCalendar
+	if disabled.IsHoliday("acme", generic.NewTimePoint(2025, time.December, 25)) {
+		t.Error("Zero-value calendar should have no holidays")
+	}
+}
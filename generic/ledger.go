@@ -34,13 +34,21 @@ EXAMPLE FLOW:
   PTO ledger: [+20, -3, +3] = 20 days
   Sick ledger: [-3] = -3 days (or from sick balance)
 
+KNOWLEDGE TIME:
+  A DefaultLedger made with AsKnownAt reads only transactions created at
+  or before that moment: "what did the ledger say on March 31?", before
+  any later backdated adjustment. Writes are unaffected.
+
 SEE ALSO:
   - store.go: Low-level persistence interface
   - timeoff/ledger.go: Domain-specific wrapper with day-uniqueness
 */
 package generic
 
-import "context"
+import (
+	"context"
+	"time"
+)
 
 // =============================================================================
 // LEDGER - Append-only transaction log
@@ -82,12 +90,21 @@ type Ledger interface {
 
 type DefaultLedger struct {
 	Store Store
+
+	// KnownAt, if set, hides transactions created after it from reads.
+	KnownAt *time.Time
 }
 
 func NewLedger(store Store) *DefaultLedger {
 	return &DefaultLedger{Store: store}
 }
 
+// AsKnownAt returns a copy of the ledger that reads it as it was at
+// knownAt. A nil knownAt reads everything.
+func (l *DefaultLedger) AsKnownAt(knownAt *time.Time) *DefaultLedger {
+	return &DefaultLedger{Store: l.Store, KnownAt: knownAt}
+}
+
 func (l *DefaultLedger) Append(ctx context.Context, tx Transaction) error {
 	if tx.IdempotencyKey != "" {
 		exists, err := l.Store.Exists(ctx, tx.IdempotencyKey)
@@ -118,15 +135,29 @@ func (l *DefaultLedger) AppendBatch(ctx context.Context, txs []Transaction) erro
 }
 
 func (l *DefaultLedger) Transactions(ctx context.Context, entityID EntityID, policyID PolicyID) ([]Transaction, error) {
-	return l.Store.Load(ctx, entityID, policyID)
+	txs, err := l.Store.Load(ctx, entityID, policyID)
+	if err != nil || l.KnownAt == nil {
+		return txs, err
+	}
+	return KnownBy(txs, *l.KnownAt), nil
 }
 
 func (l *DefaultLedger) TransactionsInRange(ctx context.Context, entityID EntityID, policyID PolicyID, from, to TimePoint) ([]Transaction, error) {
-	return l.Store.LoadRange(ctx, entityID, policyID, from, to)
+	if l.KnownAt == nil {
+		return l.Store.LoadRange(ctx, entityID, policyID, from, to)
+	}
+	if ks, ok := l.Store.(KnownAtStore); ok {
+		return ks.LoadRangeKnownAt(ctx, entityID, policyID, from, to, *l.KnownAt)
+	}
+	txs, err := l.Store.LoadRange(ctx, entityID, policyID, from, to)
+	if err != nil {
+		return nil, err
+	}
+	return KnownBy(txs, *l.KnownAt), nil
 }
 
 func (l *DefaultLedger) BalanceAt(ctx context.Context, entityID EntityID, policyID PolicyID, at TimePoint, unit Unit) (Amount, error) {
-	txs, err := l.Store.Load(ctx, entityID, policyID)
+	txs, err := l.Transactions(ctx, entityID, policyID)
 	if err != nil {
 		return Amount{}, err
 	}
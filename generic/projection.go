@@ -34,6 +34,14 @@ PROJECTION vs REAL-TIME:
   The projection engine answers "COULD this request be valid?"
   It doesn't actually create transactions - that's RequestService's job.
 
+FORWARD PROJECTION:
+  Forecast answers "when will I have N days?": the first day the
+  available balance, summed over an entity's policies, reaches a target.
+  Later periods start from the carryover the ReconciliationEngine would
+  post (caps and expiry included). Accruals that cannot be predicted
+  (grant-based or non-deterministic schedules) are assumed at an
+  expected monthly rate.
+
 EXAMPLE:
   engine := &ProjectionEngine{Ledger: ledger}
   result, _ := engine.Project(ctx, ProjectionInput{
@@ -57,7 +65,10 @@ SEE ALSO:
 */
 package generic
 
-import "context"
+import (
+	"context"
+	"sort"
+)
 
 // =============================================================================
 // PROJECTION ENGINE - Validates consumption against period balance
@@ -257,3 +268,252 @@ func (pe *ProjectionEngine) QuickValidate(
 	}
 	return result.IsValid, nil
 }
+
+// =============================================================================
+// FORWARD PROJECTION - When will the balance reach a target?
+// =============================================================================
+
+// ForecastPolicy is one assignment's inputs to a forward projection.
+type ForecastPolicy struct {
+	Assignment PolicyAssignment
+	Accruals   AccrualSchedule // nil for grant-based policies
+
+	// ExpectedRate is the accrual assumed per month when Accruals cannot
+	// predict it (nil, or not deterministic). nil assumes none.
+	ExpectedRate *Amount
+}
+
+// AssumesRate reports whether the policy's accruals are assumed at
+// ExpectedRate.
+func (p ForecastPolicy) AssumesRate() bool {
+	return p.ExpectedRate != nil && (p.Accruals == nil || !p.Accruals.IsDeterministic())
+}
+
+// ForecastInput asks for the first day in [From, Until] on which the
+// available balance summed over Policies reaches Target.
+type ForecastInput struct {
+	EntityID EntityID
+	Policies []ForecastPolicy
+	Target   Amount
+	From     TimePoint
+	Until    TimePoint
+}
+
+// ForecastResult is the answer to a ForecastInput. When the target is not
+// reached, Best is the highest sum in the range and BestDate its first day.
+type ForecastResult struct {
+	Reached   bool
+	Unlimited bool // a policy is unlimited: reached on From
+	Date      TimePoint
+	Available Amount
+	Best      Amount
+	BestDate  TimePoint
+
+	// Per policy, on Date (or BestDate)
+	Points []ForecastPoint
+}
+
+// ForecastPoint is one policy's projected balance on a day.
+type ForecastPoint struct {
+	PolicyID  PolicyID
+	Available Amount
+	CarriedIn Amount // projected carryover into the day's period
+	Expired   Amount // projected expiry at the end of the period before
+	Expected  Amount // accruals assumed at ExpectedRate so far in the period
+	Capped    bool   // Available limited to the policy's MaxBalance
+}
+
+// Forecast projects the balance forward and returns the first day it
+// reaches the target. Only days on which a balance can change are
+// evaluated: From, accrual days, period starts and the first of each
+// month when a rate is assumed.
+//
+// On each day, a policy's balance is that of its period (ComputeBalance
+// over every transaction in the period, so pending and future-dated
+// requests count). Periods after From's are not reconciled yet: each one
+// starts from the ReconciliationEngine's carryover of the projected
+// period before it, so carryover caps and expiry apply. A policy with a
+// MaxBalance cannot hold more than it.
+func (pe *ProjectionEngine) Forecast(ctx context.Context, input ForecastInput) (*ForecastResult, error) {
+	zero := NewAmount(0, input.Target.Unit)
+	result := &ForecastResult{Available: zero, Best: zero}
+
+	days := map[string]TimePoint{dayKey(input.From): input.From}
+	var forecasts []*policyForecast
+	for _, p := range input.Policies {
+		if p.Assignment.Policy.IsUnlimited {
+			result.Reached, result.Unlimited = true, true
+			result.Date, result.Available = input.From, input.Target
+			return result, nil
+		}
+		f := &policyForecast{ForecastPolicy: p, entityID: input.EntityID, from: input.From, periods: make(map[string]*forecastPeriod)}
+		for _, day := range f.changeDays(input.From, input.Until) {
+			days[dayKey(day)] = day
+		}
+		forecasts = append(forecasts, f)
+	}
+
+	keys := make([]string, 0, len(days))
+	for k := range days {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	first := true
+	for _, k := range keys {
+		day := days[k]
+		total := zero
+		points := make([]ForecastPoint, 0, len(forecasts))
+		for _, f := range forecasts {
+			point, err := f.at(ctx, pe.Ledger, day)
+			if err != nil {
+				return nil, err
+			}
+			total = total.Add(point.Available)
+			points = append(points, point)
+		}
+		if first || total.GreaterThan(result.Best) {
+			result.Best, result.BestDate, result.Points = total, day, points
+			first = false
+		}
+		if !total.LessThan(input.Target) {
+			result.Reached, result.Date, result.Available = true, day, total
+			return result, nil
+		}
+	}
+	return result, nil
+}
+
+func dayKey(tp TimePoint) string { return tp.Time.Format("2006-01-02") }
+
+// policyForecast projects one policy, period by period.
+type policyForecast struct {
+	ForecastPolicy
+	entityID EntityID
+	from     TimePoint
+	periods  map[string]*forecastPeriod // by period start
+}
+
+// forecastPeriod is a period's transactions, with its projected carryover
+// and the assumed accruals.
+type forecastPeriod struct {
+	period    Period
+	txs       []Transaction
+	expected  []Transaction
+	carriedIn Amount
+	expired   Amount
+}
+
+// changeDays returns the days in (from, until] on which the policy's
+// balance can change.
+func (f *policyForecast) changeDays(from, until TimePoint) []TimePoint {
+	var days []TimePoint
+	add := func(day TimePoint) {
+		if day.After(from) && !day.After(until) {
+			days = append(days, day)
+		}
+	}
+	add(f.Assignment.EffectiveFrom)
+	if f.Accruals != nil {
+		for _, e := range f.Accruals.GenerateAccruals(from, until) {
+			add(e.At)
+		}
+	}
+	config := f.Assignment.Policy.PeriodConfig
+	for p := config.PeriodFor(from); !p.End.After(until); {
+		p = config.PeriodFor(p.End.AddDays(1))
+		add(p.Start)
+	}
+	if f.AssumesRate() {
+		for m := StartOfMonth(from.Year(), from.Month()).AddMonths(1); !m.After(until); m = m.AddMonths(1) {
+			add(m)
+		}
+	}
+	return days
+}
+
+// at returns the policy's projected balance on day.
+func (f *policyForecast) at(ctx context.Context, ledger Ledger, day TimePoint) (ForecastPoint, error) {
+	policy := f.Assignment.Policy
+	point := ForecastPoint{PolicyID: policy.ID, Available: NewAmount(0, policy.Unit)}
+	point.CarriedIn, point.Expired, point.Expected = point.Available, point.Available, point.Available
+	if !f.Assignment.IsActive(day) {
+		return point, nil
+	}
+
+	fp, err := f.periodFor(ctx, ledger, policy.PeriodConfig.PeriodFor(day))
+	if err != nil {
+		return point, err
+	}
+	txs := append([]Transaction{}, fp.txs...)
+	for _, tx := range fp.expected {
+		if !tx.EffectiveAt.After(day) {
+			txs = append(txs, tx)
+			point.Expected = point.Expected.Add(tx.Delta)
+		}
+	}
+	balance := ComputeBalance(txs, fp.period, policy.Unit, f.Accruals, f.Assignment.EffectiveFrom, day)
+	point.Available = balance.AvailableWithMode(policy.ConsumptionMode)
+	if max := policy.Constraints.MaxBalance; max != nil && point.Available.GreaterThan(*max) {
+		point.Available, point.Capped = *max, true
+	}
+	point.CarriedIn, point.Expired = fp.carriedIn, fp.expired
+	return point, nil
+}
+
+// periodFor returns the period's transactions: the ledger's, plus, after
+// From's period, the carryover projected from the period before.
+func (f *policyForecast) periodFor(ctx context.Context, ledger Ledger, period Period) (*forecastPeriod, error) {
+	if fp, ok := f.periods[dayKey(period.Start)]; ok {
+		return fp, nil
+	}
+	policy := f.Assignment.Policy
+	txs, err := ledger.TransactionsInRange(ctx, f.entityID, policy.ID, period.Start, period.End)
+	if err != nil {
+		return nil, err
+	}
+	fp := &forecastPeriod{period: period, txs: txs, carriedIn: NewAmount(0, policy.Unit), expired: NewAmount(0, policy.Unit)}
+
+	if f.AssumesRate() {
+		for m := StartOfMonth(period.Start.Year(), period.Start.Month()); !m.After(period.End); m = m.AddMonths(1) {
+			if m.Before(period.Start) || !m.After(f.from) {
+				continue
+			}
+			// An adjustment, so it counts in either consumption mode
+			fp.expected = append(fp.expected, Transaction{
+				EntityID: f.entityID, PolicyID: policy.ID, ResourceType: policy.ResourceType,
+				EffectiveAt: m, Delta: *f.ExpectedRate, Type: TxAdjustment, Reason: "expected accrual",
+			})
+		}
+	}
+
+	config := policy.PeriodConfig
+	if period.Start.After(f.from) {
+		prev, err := f.periodFor(ctx, ledger, config.PeriodFor(period.Start.AddDays(-1)))
+		if err != nil {
+			return nil, err
+		}
+		ending := append(append([]Transaction{}, prev.txs...), prev.expected...)
+		balance := ComputeBalance(ending, prev.period, policy.Unit, f.Accruals, f.Assignment.EffectiveFrom, prev.period.End)
+		output, err := (&ReconciliationEngine{}).Process(ReconciliationInput{
+			EntityID:       f.entityID,
+			PolicyID:       policy.ID,
+			Policy:         policy,
+			CurrentBalance: balance,
+			EndingPeriod:   prev.period,
+			NextPeriod:     period,
+		})
+		if err != nil {
+			return nil, err
+		}
+		for _, tx := range output.Transactions {
+			if !tx.EffectiveAt.Before(period.Start) {
+				fp.txs = append(fp.txs, tx)
+			}
+		}
+		fp.carriedIn, fp.expired = output.Summary.CarriedOver, output.Summary.Expired
+	}
+
+	f.periods[dayKey(period.Start)] = fp
+	return fp, nil
+}
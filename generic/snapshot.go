@@ -92,7 +92,9 @@ func (pm *PeriodManager) ClosePeriod(ctx context.Context, input ClosePeriodInput
 		return nil, err
 	}
 
-	balance := pm.calculateBalance(txs, input.Period, input.Accruals, input.Policy.Unit)
+	balance := ComputeBalance(txs, input.Period, input.Policy.Unit, input.Accruals, input.Period.Start, input.Period.End)
+	balance.EntityID = input.EntityID
+	balance.PolicyID = input.PolicyID
 
 	// 2. Take snapshot
 	snapshot := Snapshot{
@@ -217,51 +219,6 @@ func (pm *PeriodManager) ChangePolicy(ctx context.Context, input ChangePolicyInp
 	return closeOutput, nil
 }
 
-func (pm *PeriodManager) calculateBalance(txs []Transaction, period Period, accruals AccrualSchedule, unit Unit) Balance {
-	var (
-		actualAccruals = NewAmount(0, unit)
-		consumed       = NewAmount(0, unit)
-		pending        = NewAmount(0, unit)
-		adjustments    = NewAmount(0, unit)
-	)
-
-	for _, tx := range txs {
-		switch tx.Type {
-		case TxGrant:
-			actualAccruals = actualAccruals.Add(tx.Delta)
-		case TxConsumption:
-			consumed = consumed.Add(tx.Delta.Neg())
-		case TxPending:
-			pending = pending.Add(tx.Delta.Neg())
-		case TxReconciliation, TxAdjustment:
-			adjustments = adjustments.Add(tx.Delta)
-		case TxReversal:
-			consumed = consumed.Sub(tx.Delta)
-		}
-	}
-
-	totalAccruals := actualAccruals
-	if accruals != nil {
-		projectedEvents := accruals.GenerateAccruals(period.Start, period.End)
-		projectedTotal := NewAmount(0, unit)
-		for _, e := range projectedEvents {
-			projectedTotal = projectedTotal.Add(e.Amount)
-		}
-		totalAccruals = projectedTotal
-	}
-
-	return Balance{
-		EntityID:         "", // Will be set by caller
-		PolicyID:         "", // Will be set by caller
-		Period:           period,
-		AccruedToDate:    totalAccruals,
-		TotalEntitlement: totalAccruals,
-		TotalConsumed:    consumed,
-		Pending:          pending,
-		Adjustments:      adjustments,
-	}
-}
-
 func generateSnapshotID(entityID EntityID, policyID PolicyID, period Period) string {
 	return string(entityID) + "-" + string(policyID) + "-" + period.End.String()
 }
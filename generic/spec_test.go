@@ -927,6 +927,62 @@ func TestSpec_Pending_DeductsFromAvailable(t *testing.T) {
 	}
 }
 
+func TestSpec_Forecast_AccruesUpToMaxBalance(t *testing.T) {
+	// SPEC: A forward projection finds the first day a target is available
+	//
+	// GIVEN: 12 days/year accrued monthly, ConsumeUpToAccrued, MaxBalance 5
+	// WHEN: Forecasting from Jan 15 for 3 days, then for 6
+	// THEN: 3 days on Mar 1; 6 never, the balance stops at the cap of 5
+	//
+	// PURPOSE: Employees planning ahead see when they can book, and that a
+	// capped policy will not get them there.
+
+	ctx := context.Background()
+	engine := &generic.ProjectionEngine{Ledger: newLedger()}
+
+	max := d(5)
+	policy := generic.ForecastPolicy{
+		Assignment: generic.PolicyAssignment{
+			EntityID: "emp-1",
+			PolicyID: "test-policy",
+			Policy: generic.Policy{
+				ID:              "test-policy",
+				Unit:            generic.UnitDays,
+				PeriodConfig:    generic.PeriodConfig{Type: generic.PeriodCalendarYear},
+				ConsumptionMode: generic.ConsumeUpToAccrued,
+				Constraints:     generic.Constraints{MaxBalance: &max},
+			},
+			EffectiveFrom: generic.NewTimePoint(2025, time.January, 1),
+		},
+		Accruals: &TestYearlyAccrual{AnnualDays: 12},
+	}
+	forecast := func(target float64) *generic.ForecastResult {
+		result, err := engine.Forecast(ctx, generic.ForecastInput{
+			EntityID: "emp-1",
+			Policies: []generic.ForecastPolicy{policy},
+			Target:   d(target),
+			From:     generic.NewTimePoint(2025, time.January, 15),
+			Until:    generic.NewTimePoint(2025, time.December, 31),
+		})
+		if err != nil {
+			t.Fatalf("forecast failed: %v", err)
+		}
+		return result
+	}
+
+	if result := forecast(3); !result.Reached || !result.Date.Equal(generic.NewTimePoint(2025, time.March, 1)) {
+		t.Errorf("SPEC VIOLATION: 3 days should be available on Mar 1, got %+v", result)
+	}
+
+	result := forecast(6)
+	if result.Reached {
+		t.Errorf("SPEC VIOLATION: 6 days exceed the cap of 5, got reached on %v", result.Date)
+	}
+	if !result.Best.Value.Equal(d(5).Value) || !result.BestDate.Equal(generic.NewTimePoint(2025, time.May, 1)) {
+		t.Errorf("Best should be 5 (the cap) from May 1, got %v on %v", result.Best.Value, result.BestDate)
+	}
+}
+
 // =============================================================================
 // SPEC 10: AUDIT TRAIL
 // =============================================================================
@@ -9,6 +9,7 @@ PURPOSE:
 KEY INTERFACES:
   Store:           Core transaction persistence (append, load, exists)
   TxStore:         Transactional operations (atomic multi-table writes)
+  KnownAtStore:    Ledger as it was known at a past moment (bitemporal)
   AssignmentStore: Policy-to-entity mapping
   SnapshotStore:   Balance snapshots for performance optimization
 
@@ -28,9 +29,18 @@ ATOMIC BATCHES:
   5-day PTO request (5 transactions), either all 5 are written or
   none are. This prevents partial state.
 
+TWO TIMES:
+  EffectiveAt is when a transaction counts; CreatedAt is when the store
+  learned of it (stamped on append). A backdated adjustment has an early
+  EffectiveAt and a late CreatedAt. Filtering on CreatedAt <= knownAt
+  reconstructs what the system believed at knownAt.
+
 IMPLEMENTATIONS:
   - store/sqlite/sqlite.go: Production SQLite/PostgreSQL
-  - generic/store/memory.go: In-memory for testing
+  - store/kv/store.go: Embedded key-value store (no CGO)
+  - generic/store/memory.go: In-memory (tests, embedding)
+
+  Each runs the conformance suite in generic/store/storetest.
 
 EXAMPLE:
   store := sqlite.New("./data.db")
@@ -45,7 +55,10 @@ SEE ALSO:
 */
 package generic
 
-import "context"
+import (
+	"context"
+	"time"
+)
 
 // =============================================================================
 // STORE - Interface for transaction persistence (append-only)
@@ -90,6 +103,29 @@ type EntityStore interface {
 	GetConsumedDays(ctx context.Context, entityID EntityID, resourceType ResourceType, from, to TimePoint) ([]TimePoint, error)
 }
 
+// KnownAtStore extends Store with knowledge-time queries.
+// Used to reconstruct a balance as the system knew it on a past date.
+type KnownAtStore interface {
+	Store
+
+	// LoadRangeKnownAt returns transactions in [from, to] created at or
+	// before knownAt, ordered by EffectiveAt.
+	LoadRangeKnownAt(ctx context.Context, entityID EntityID, policyID PolicyID, from, to TimePoint, knownAt time.Time) ([]Transaction, error)
+}
+
+// KnownBy returns the transactions created at or before knownAt.
+// CreatedAt is kept at second precision, so knownAt is too.
+func KnownBy(txs []Transaction, knownAt time.Time) []Transaction {
+	knownAt = knownAt.Truncate(time.Second)
+	var known []Transaction
+	for _, tx := range txs {
+		if !tx.CreatedAt.Time.After(knownAt) {
+			known = append(known, tx)
+		}
+	}
+	return known
+}
+
 // =============================================================================
 // TRANSACTIONAL STORE - For atomic operations across multiple writes
 // =============================================================================
@@ -1,68 +1,203 @@
-// Package store provides Store implementations.
+/*
+Package store provides an in-memory implementation of the storage interfaces.
+
+PURPOSE:
+  Runs the whole engine without a database: in unit tests, in services
+  that embed the engine, and in development. Nothing is persisted; the
+  store lives as long as the process.
+
+INTERFACES IMPLEMENTED:
+  generic.Store:           Transaction persistence
+  generic.EntityStore:     Entity-wide queries (day uniqueness)
+  generic.TxStore:         Atomic multi-append (WithTx)
+  generic.KnownAtStore:    Ranges as known at a past moment (CreatedAt)
+  generic.AssignmentStore: Policy-to-entity mappings (Assignments)
+  generic.SnapshotStore:   Balance snapshots (Snapshots)
+  generic.HolidayCalendar: Company holidays
+  Plus requests (SaveRequest, GetRequest, GetPendingRequests).
+
+  AssignmentStore and SnapshotStore both have a Save method, so Memory
+  returns them as views (memory_records.go), as store/sqlite does.
+
+INDEXES:
+  Transactions are kept sorted by EffectiveAt twice: per entity+policy
+  (Load, LoadRange) and per entity (LoadByEntity, GetConsumedDays), so
+  range queries are two binary searches and a copy. A map from
+  (entity, resource type, UTC day) to transaction ID enforces one
+  consumption or pending transaction per day, as the SQL unique index
+  does in store/sqlite, and answers IsDayConsumed directly.
+
+TENANCY:
+  Every key starts with the tenant of the context (generic.TenantFrom),
+  so tenants never see each other's data. The generic.HolidayCalendar
+  methods take no context and read the default tenant; HolidayCalendarFor
+  binds them to another tenant.
+
+CONCURRENCY:
+  One sync.RWMutex guards everything. WithTx holds the write lock while
+  fn runs, so fn must use the Store it is given, not the Memory.
+
+SEE ALSO:
+  - generic/store.go: Interface definitions
+  - generic/store/storetest: Conformance suite (memory_test.go runs it)
+  - store/sqlite/sqlite.go, store/kv/store.go: Persistent backends
+*/
 package store
 
 import (
 	"context"
 	"sort"
 	"sync"
+	"time"
 
 	"github.com/warp/resource-engine/generic"
 )
 
+// Compile-time checks for the generic interfaces Memory provides.
+var (
+	_ generic.EntityStore     = (*Memory)(nil)
+	_ generic.TxStore         = (*Memory)(nil)
+	_ generic.KnownAtStore    = (*Memory)(nil)
+	_ generic.HolidayCalendar = (*Memory)(nil)
+)
+
 // =============================================================================
 // MEMORY STORE - In-memory implementation (for testing/dev)
 // =============================================================================
 
 type Memory struct {
 	mu           sync.RWMutex
-	transactions map[key][]generic.Transaction
-	idempotency  map[string]bool
+	transactions map[key][]generic.Transaction       // By entity+policy, sorted by EffectiveAt
+	byEntity     map[entityKey][]generic.Transaction // By entity, sorted by EffectiveAt
+	days         map[dayKey]generic.TransactionID    // Consumption/pending per day
+	idempotency  map[tenantKey]bool
+
+	// Records (memory_records.go)
+	assignments map[entityKey][]generic.PolicyAssignment
+	snapshots   map[key][]generic.Snapshot
+	holidays    map[generic.TenantID]map[string]generic.Holiday
+	requests    map[tenantKey]generic.Request
 }
 
 type key struct {
+	Tenant   generic.TenantID
 	EntityID generic.EntityID
 	PolicyID generic.PolicyID
 }
 
+type entityKey struct {
+	Tenant   generic.TenantID
+	EntityID generic.EntityID
+}
+
+type dayKey struct {
+	Tenant     generic.TenantID
+	EntityID   generic.EntityID
+	ResourceID string
+	Day        string // UTC date, 2006-01-02
+}
+
+type tenantKey struct {
+	Tenant generic.TenantID
+	Key    string
+}
+
 func NewMemory() *Memory {
 	return &Memory{
 		transactions: make(map[key][]generic.Transaction),
-		idempotency:  make(map[string]bool),
+		byEntity:     make(map[entityKey][]generic.Transaction),
+		days:         make(map[dayKey]generic.TransactionID),
+		idempotency:  make(map[tenantKey]bool),
+		assignments:  make(map[entityKey][]generic.PolicyAssignment),
+		snapshots:    make(map[key][]generic.Snapshot),
+		holidays:     make(map[generic.TenantID]map[string]generic.Holiday),
+		requests:     make(map[tenantKey]generic.Request),
+	}
+}
+
+func txKey(ctx context.Context, entityID generic.EntityID, policyID generic.PolicyID) key {
+	return key{Tenant: generic.TenantFrom(ctx), EntityID: entityID, PolicyID: policyID}
+}
+
+func entityKeyOf(ctx context.Context, entityID generic.EntityID) entityKey {
+	return entityKey{Tenant: generic.TenantFrom(ctx), EntityID: entityID}
+}
+
+func dayKeyOf(ctx context.Context, entityID generic.EntityID, resourceType generic.ResourceType, t time.Time) dayKey {
+	return dayKey{
+		Tenant:     generic.TenantFrom(ctx),
+		EntityID:   entityID,
+		ResourceID: resourceType.ResourceID(),
+		Day:        t.UTC().Format("2006-01-02"),
 	}
 }
 
+// occupiesDay reports whether a transaction takes its day off.
+func occupiesDay(tx generic.Transaction) bool {
+	return (tx.Type == generic.TxConsumption || tx.Type == generic.TxPending) && tx.ResourceType != nil
+}
+
 // Append adds a single transaction. Append-only.
-func (m *Memory) Append(_ context.Context, tx generic.Transaction) error {
+func (m *Memory) Append(ctx context.Context, tx generic.Transaction) error {
 	m.mu.Lock()
 	defer m.mu.Unlock()
-	return m.appendLocked(tx)
+	return m.appendBatchLocked(ctx, []generic.Transaction{tx})
 }
 
 // AppendBatch adds multiple transactions atomically.
-func (m *Memory) AppendBatch(_ context.Context, txs []generic.Transaction) error {
+func (m *Memory) AppendBatch(ctx context.Context, txs []generic.Transaction) error {
 	m.mu.Lock()
 	defer m.mu.Unlock()
+	return m.appendBatchLocked(ctx, txs)
+}
 
-	// Check all idempotency keys first (atomic check)
+// appendBatchLocked checks every idempotency key and day first, including
+// duplicates within the batch, so a failing batch writes nothing.
+func (m *Memory) appendBatchLocked(ctx context.Context, txs []generic.Transaction) error {
+	tenant := generic.TenantFrom(ctx)
+	keys := make(map[string]bool)
+	days := make(map[dayKey]bool)
 	for _, tx := range txs {
-		if tx.IdempotencyKey != "" && m.idempotency[tx.IdempotencyKey] {
-			return generic.ErrDuplicateIdempotencyKey
+		if tx.IdempotencyKey != "" {
+			if m.idempotency[tenantKey{tenant, tx.IdempotencyKey}] || keys[tx.IdempotencyKey] {
+				return generic.ErrDuplicateIdempotencyKey
+			}
+			keys[tx.IdempotencyKey] = true
+		}
+		if occupiesDay(tx) {
+			dk := dayKeyOf(ctx, tx.EntityID, tx.ResourceType, tx.EffectiveAt.Time)
+			if _, taken := m.days[dk]; taken || days[dk] {
+				return generic.ErrDuplicateDayConsumption
+			}
+			days[dk] = true
 		}
 	}
 
-	// Append all (atomic write)
 	for _, tx := range txs {
-		if err := m.appendLocked(tx); err != nil {
-			return err
-		}
+		m.appendLocked(ctx, tx)
 	}
 	return nil
 }
 
-func (m *Memory) appendLocked(tx generic.Transaction) error {
-	k := key{EntityID: tx.EntityID, PolicyID: tx.PolicyID}
-	txs := m.transactions[k]
+// appendLocked writes a checked transaction to every index, stamping
+// CreatedAt at second precision as the other stores do.
+func (m *Memory) appendLocked(ctx context.Context, tx generic.Transaction) {
+	tx.CreatedAt = generic.TimePoint{Time: time.Now().UTC().Truncate(time.Second)}
+	k := txKey(ctx, tx.EntityID, tx.PolicyID)
+	m.transactions[k] = insertSorted(m.transactions[k], tx)
+	ek := entityKeyOf(ctx, tx.EntityID)
+	m.byEntity[ek] = insertSorted(m.byEntity[ek], tx)
 
+	if tx.IdempotencyKey != "" {
+		m.idempotency[tenantKey{k.Tenant, tx.IdempotencyKey}] = true
+	}
+	if occupiesDay(tx) {
+		m.days[dayKeyOf(ctx, tx.EntityID, tx.ResourceType, tx.EffectiveAt.Time)] = tx.ID
+	}
+}
+
+// insertSorted inserts tx after every transaction not later than it.
+func insertSorted(txs []generic.Transaction, tx generic.Transaction) []generic.Transaction {
 	// Binary search for insertion point: O(log n) instead of O(n log n)
 	i := sort.Search(len(txs), func(i int) bool {
 		return txs[i].EffectiveAt.After(tx.EffectiveAt)
@@ -72,73 +207,121 @@ func (m *Memory) appendLocked(tx generic.Transaction) error {
 	txs = append(txs, generic.Transaction{})
 	copy(txs[i+1:], txs[i:])
 	txs[i] = tx
-	m.transactions[k] = txs
+	return txs
+}
 
-	if tx.IdempotencyKey != "" {
-		m.idempotency[tx.IdempotencyKey] = true
+// inRange returns a copy of the sorted transactions in [from, to].
+func inRange(txs []generic.Transaction, from, to generic.TimePoint) []generic.Transaction {
+	lo := sort.Search(len(txs), func(i int) bool { return from.BeforeOrEqual(txs[i].EffectiveAt) })
+	hi := sort.Search(len(txs), func(i int) bool { return txs[i].EffectiveAt.After(to) })
+	if lo >= hi {
+		return nil
 	}
-	return nil
+	return append([]generic.Transaction(nil), txs[lo:hi]...)
 }
 
-func (m *Memory) Load(_ context.Context, entityID generic.EntityID, policyID generic.PolicyID) ([]generic.Transaction, error) {
+func (m *Memory) Load(ctx context.Context, entityID generic.EntityID, policyID generic.PolicyID) ([]generic.Transaction, error) {
 	m.mu.RLock()
 	defer m.mu.RUnlock()
+	return m.loadLocked(ctx, entityID, policyID), nil
+}
 
-	k := key{EntityID: entityID, PolicyID: policyID}
-	result := make([]generic.Transaction, len(m.transactions[k]))
-	copy(result, m.transactions[k])
-	return result, nil
+func (m *Memory) loadLocked(ctx context.Context, entityID generic.EntityID, policyID generic.PolicyID) []generic.Transaction {
+	txs := m.transactions[txKey(ctx, entityID, policyID)]
+	result := make([]generic.Transaction, len(txs))
+	copy(result, txs)
+	return result
 }
 
-func (m *Memory) LoadRange(_ context.Context, entityID generic.EntityID, policyID generic.PolicyID, from, to generic.TimePoint) ([]generic.Transaction, error) {
+func (m *Memory) LoadRange(ctx context.Context, entityID generic.EntityID, policyID generic.PolicyID, from, to generic.TimePoint) ([]generic.Transaction, error) {
 	m.mu.RLock()
 	defer m.mu.RUnlock()
+	return inRange(m.transactions[txKey(ctx, entityID, policyID)], from, to), nil
+}
 
-	k := key{EntityID: entityID, PolicyID: policyID}
-	var result []generic.Transaction
-	for _, tx := range m.transactions[k] {
-		if from.BeforeOrEqual(tx.EffectiveAt) && tx.EffectiveAt.BeforeOrEqual(to) {
-			result = append(result, tx)
-		}
-	}
-	return result, nil
+func (m *Memory) LoadRangeKnownAt(ctx context.Context, entityID generic.EntityID, policyID generic.PolicyID, from, to generic.TimePoint, knownAt time.Time) ([]generic.Transaction, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return generic.KnownBy(inRange(m.transactions[txKey(ctx, entityID, policyID)], from, to), knownAt), nil
 }
 
-func (m *Memory) Exists(_ context.Context, idempotencyKey string) (bool, error) {
+func (m *Memory) Exists(ctx context.Context, idempotencyKey string) (bool, error) {
 	m.mu.RLock()
 	defer m.mu.RUnlock()
-	return m.idempotency[idempotencyKey], nil
+	return m.idempotency[tenantKey{generic.TenantFrom(ctx), idempotencyKey}], nil
 }
 
 // =============================================================================
-// TRANSACTIONAL MEMORY STORE
+// ENTITY-WIDE QUERIES (generic.EntityStore interface)
 // =============================================================================
 
-// TxMemory wraps Memory with transaction support.
-type TxMemory struct {
-	*Memory
+// LoadByEntity returns all transactions for an entity across ALL policies.
+func (m *Memory) LoadByEntity(ctx context.Context, entityID generic.EntityID, from, to generic.TimePoint) ([]generic.Transaction, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return inRange(m.byEntity[entityKeyOf(ctx, entityID)], from, to), nil
 }
 
-func NewTxMemory() *TxMemory {
-	return &TxMemory{Memory: NewMemory()}
+// IsDayConsumed checks if a specific day already has a consumption or
+// pending transaction. Returns the existing transaction ID if found.
+func (m *Memory) IsDayConsumed(ctx context.Context, entityID generic.EntityID, resourceType generic.ResourceType, day generic.TimePoint) (bool, generic.TransactionID, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.isDayConsumedLocked(ctx, entityID, resourceType, day)
 }
 
+func (m *Memory) isDayConsumedLocked(ctx context.Context, entityID generic.EntityID, resourceType generic.ResourceType, day generic.TimePoint) (bool, generic.TransactionID, error) {
+	id, ok := m.days[dayKeyOf(ctx, entityID, resourceType, day.Time)]
+	return ok, id, nil
+}
+
+// GetConsumedDays returns all days (UTC dates) that have consumption or
+// pending transactions.
+func (m *Memory) GetConsumedDays(ctx context.Context, entityID generic.EntityID, resourceType generic.ResourceType, from, to generic.TimePoint) ([]generic.TimePoint, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.consumedDaysLocked(ctx, entityID, resourceType, from, to), nil
+}
+
+func (m *Memory) consumedDaysLocked(ctx context.Context, entityID generic.EntityID, resourceType generic.ResourceType, from, to generic.TimePoint) []generic.TimePoint {
+	seen := make(map[string]bool)
+	var days []generic.TimePoint
+	for _, tx := range inRange(m.byEntity[entityKeyOf(ctx, entityID)], from, to) {
+		if !occupiesDay(tx) || tx.ResourceType.ResourceID() != resourceType.ResourceID() {
+			continue
+		}
+		day := tx.EffectiveAt.Time.UTC().Format("2006-01-02")
+		if seen[day] {
+			continue
+		}
+		seen[day] = true
+		t, _ := time.Parse("2006-01-02", day)
+		days = append(days, generic.TimePoint{Time: t})
+	}
+	sort.Slice(days, func(i, j int) bool { return days[i].Time.Before(days[j].Time) })
+	return days
+}
+
+// =============================================================================
+// TRANSACTIONAL STORE (generic.TxStore interface)
+// =============================================================================
+
 // WithTx executes fn within a transaction.
 // For memory store, this is simulated with a snapshot + rollback on error.
-func (tm *TxMemory) WithTx(ctx context.Context, fn func(generic.Store) error) error {
-	tm.mu.Lock()
-	defer tm.mu.Unlock()
+func (m *Memory) WithTx(ctx context.Context, fn func(generic.Store) error) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
 
 	// Snapshot current state
-	snapshot := tm.snapshot()
+	snapshot := m.snapshot()
 
 	// Create a transactional view
-	txStore := &txMemoryView{parent: tm}
+	txStore := &txMemoryView{parent: m}
 
 	// Execute function
 	if err := fn(txStore); err != nil {
 		// Rollback
-		tm.restore(snapshot)
+		m.restore(snapshot)
 		return err
 	}
 
@@ -146,61 +329,95 @@ func (tm *TxMemory) WithTx(ctx context.Context, fn func(generic.Store) error) er
 	return nil
 }
 
-func (tm *TxMemory) snapshot() memorySnapshot {
-	txsCopy := make(map[key][]generic.Transaction)
-	for k, v := range tm.transactions {
-		txsCopy[k] = append([]generic.Transaction{}, v...)
+// TxMemory is the memory store under the name it had before Memory
+// implemented WithTx itself.
+type TxMemory struct {
+	*Memory
+}
+
+func NewTxMemory() *TxMemory {
+	return &TxMemory{Memory: NewMemory()}
+}
+
+func (m *Memory) snapshot() memorySnapshot {
+	s := memorySnapshot{
+		transactions: make(map[key][]generic.Transaction, len(m.transactions)),
+		byEntity:     make(map[entityKey][]generic.Transaction, len(m.byEntity)),
+		days:         make(map[dayKey]generic.TransactionID, len(m.days)),
+		idempotency:  make(map[tenantKey]bool, len(m.idempotency)),
+	}
+	for k, v := range m.transactions {
+		s.transactions[k] = append([]generic.Transaction{}, v...)
 	}
-	idempCopy := make(map[string]bool)
-	for k, v := range tm.idempotency {
-		idempCopy[k] = v
+	for k, v := range m.byEntity {
+		s.byEntity[k] = append([]generic.Transaction{}, v...)
 	}
-	return memorySnapshot{transactions: txsCopy, idempotency: idempCopy}
+	for k, v := range m.days {
+		s.days[k] = v
+	}
+	for k, v := range m.idempotency {
+		s.idempotency[k] = v
+	}
+	return s
 }
 
-func (tm *TxMemory) restore(s memorySnapshot) {
-	tm.transactions = s.transactions
-	tm.idempotency = s.idempotency
+func (m *Memory) restore(s memorySnapshot) {
+	m.transactions = s.transactions
+	m.byEntity = s.byEntity
+	m.days = s.days
+	m.idempotency = s.idempotency
 }
 
 type memorySnapshot struct {
 	transactions map[key][]generic.Transaction
-	idempotency  map[string]bool
+	byEntity     map[entityKey][]generic.Transaction
+	days         map[dayKey]generic.TransactionID
+	idempotency  map[tenantKey]bool
 }
 
+// txMemoryView is the store WithTx hands to fn. The parent's write lock is
+// already held, so it calls the *Locked methods directly.
 type txMemoryView struct {
-	parent *TxMemory
+	parent *Memory
 }
 
+var (
+	_ generic.EntityStore  = (*txMemoryView)(nil)
+	_ generic.KnownAtStore = (*txMemoryView)(nil)
+)
+
 func (tv *txMemoryView) Append(ctx context.Context, tx generic.Transaction) error {
-	return tv.parent.appendLocked(tx)
+	return tv.parent.appendBatchLocked(ctx, []generic.Transaction{tx})
 }
 
 func (tv *txMemoryView) AppendBatch(ctx context.Context, txs []generic.Transaction) error {
-	for _, tx := range txs {
-		if err := tv.parent.appendLocked(tx); err != nil {
-			return err
-		}
-	}
-	return nil
+	return tv.parent.appendBatchLocked(ctx, txs)
 }
 
 func (tv *txMemoryView) Load(ctx context.Context, entityID generic.EntityID, policyID generic.PolicyID) ([]generic.Transaction, error) {
-	k := key{EntityID: entityID, PolicyID: policyID}
-	return tv.parent.transactions[k], nil
+	return tv.parent.loadLocked(ctx, entityID, policyID), nil
 }
 
 func (tv *txMemoryView) LoadRange(ctx context.Context, entityID generic.EntityID, policyID generic.PolicyID, from, to generic.TimePoint) ([]generic.Transaction, error) {
-	k := key{EntityID: entityID, PolicyID: policyID}
-	var result []generic.Transaction
-	for _, tx := range tv.parent.transactions[k] {
-		if from.BeforeOrEqual(tx.EffectiveAt) && tx.EffectiveAt.BeforeOrEqual(to) {
-			result = append(result, tx)
-		}
-	}
-	return result, nil
+	return inRange(tv.parent.transactions[txKey(ctx, entityID, policyID)], from, to), nil
+}
+
+func (tv *txMemoryView) LoadRangeKnownAt(ctx context.Context, entityID generic.EntityID, policyID generic.PolicyID, from, to generic.TimePoint, knownAt time.Time) ([]generic.Transaction, error) {
+	return generic.KnownBy(inRange(tv.parent.transactions[txKey(ctx, entityID, policyID)], from, to), knownAt), nil
+}
+
+func (tv *txMemoryView) Exists(ctx context.Context, idempotencyKey string) (bool, error) {
+	return tv.parent.idempotency[tenantKey{generic.TenantFrom(ctx), idempotencyKey}], nil
+}
+
+func (tv *txMemoryView) LoadByEntity(ctx context.Context, entityID generic.EntityID, from, to generic.TimePoint) ([]generic.Transaction, error) {
+	return inRange(tv.parent.byEntity[entityKeyOf(ctx, entityID)], from, to), nil
+}
+
+func (tv *txMemoryView) IsDayConsumed(ctx context.Context, entityID generic.EntityID, resourceType generic.ResourceType, day generic.TimePoint) (bool, generic.TransactionID, error) {
+	return tv.parent.isDayConsumedLocked(ctx, entityID, resourceType, day)
 }
 
-func (tv *txMemoryView) Exists(_ context.Context, idempotencyKey string) (bool, error) {
-	return tv.parent.idempotency[idempotencyKey], nil
+func (tv *txMemoryView) GetConsumedDays(ctx context.Context, entityID generic.EntityID, resourceType generic.ResourceType, from, to generic.TimePoint) ([]generic.TimePoint, error) {
+	return tv.parent.consumedDaysLocked(ctx, entityID, resourceType, from, to), nil
 }
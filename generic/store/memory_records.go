@@ -0,0 +1,256 @@
+package store
+
+import (
+	"context"
+	"sort"
+
+	"github.com/warp/resource-engine/generic"
+)
+
+// =============================================================================
+// ASSIGNMENT STORE (generic.AssignmentStore interface)
+// =============================================================================
+
+// Assignments returns the store's assignments as a generic.AssignmentStore.
+// Assignments are kept whole, policy included, so no policy lookup is needed.
+func (m *Memory) Assignments() generic.AssignmentStore {
+	return memoryAssignments{m}
+}
+
+type memoryAssignments struct {
+	m *Memory
+}
+
+// Save adds an assignment, or replaces the entity's assignment with the
+// same ID.
+func (ma memoryAssignments) Save(ctx context.Context, a generic.PolicyAssignment) error {
+	ma.m.mu.Lock()
+	defer ma.m.mu.Unlock()
+
+	k := entityKeyOf(ctx, a.EntityID)
+	assignments := ma.m.assignments[k]
+	replaced := false
+	for i := range assignments {
+		if assignments[i].ID == a.ID {
+			assignments[i] = a
+			replaced = true
+		}
+	}
+	if !replaced {
+		assignments = append(assignments, a)
+	}
+	// Consumption order, as store/sqlite returns them
+	sort.SliceStable(assignments, func(i, j int) bool {
+		return assignments[i].ConsumptionPriority < assignments[j].ConsumptionPriority
+	})
+	ma.m.assignments[k] = assignments
+	return nil
+}
+
+func (ma memoryAssignments) GetByEntity(ctx context.Context, entityID generic.EntityID) ([]generic.PolicyAssignment, error) {
+	ma.m.mu.RLock()
+	defer ma.m.mu.RUnlock()
+	return append([]generic.PolicyAssignment(nil), ma.m.assignments[entityKeyOf(ctx, entityID)]...), nil
+}
+
+func (ma memoryAssignments) GetByEntityAndResource(ctx context.Context, entityID generic.EntityID, resourceType generic.ResourceType) ([]generic.PolicyAssignment, error) {
+	all, _ := ma.GetByEntity(ctx, entityID)
+
+	var filtered []generic.PolicyAssignment
+	for _, a := range all {
+		if a.Policy.ResourceType != nil && a.Policy.ResourceType.ResourceID() == resourceType.ResourceID() {
+			filtered = append(filtered, a)
+		}
+	}
+	return filtered, nil
+}
+
+func (ma memoryAssignments) GetActive(ctx context.Context, entityID generic.EntityID, at generic.TimePoint) ([]generic.PolicyAssignment, error) {
+	all, _ := ma.GetByEntity(ctx, entityID)
+
+	var active []generic.PolicyAssignment
+	for _, a := range all {
+		if a.IsActive(at) {
+			active = append(active, a)
+		}
+	}
+	return active, nil
+}
+
+// =============================================================================
+// SNAPSHOT STORE (generic.SnapshotStore interface)
+// =============================================================================
+
+// Snapshots returns the store's snapshots as a generic.SnapshotStore.
+func (m *Memory) Snapshots() generic.SnapshotStore {
+	return memorySnapshots{m}
+}
+
+type memorySnapshots struct {
+	m *Memory
+}
+
+// Save adds a snapshot, or replaces the one for the same period.
+// Snapshots are kept sorted by period end.
+func (ms memorySnapshots) Save(ctx context.Context, snap generic.Snapshot) error {
+	ms.m.mu.Lock()
+	defer ms.m.mu.Unlock()
+
+	k := txKey(ctx, snap.EntityID, snap.PolicyID)
+	snaps := ms.m.snapshots[k]
+	for i := range snaps {
+		if samePeriod(snaps[i].Period, snap.Period) {
+			snaps[i] = snap
+			return nil
+		}
+	}
+	i := sort.Search(len(snaps), func(i int) bool {
+		return snaps[i].Period.End.Time.After(snap.Period.End.Time)
+	})
+	snaps = append(snaps, generic.Snapshot{})
+	copy(snaps[i+1:], snaps[i:])
+	snaps[i] = snap
+	ms.m.snapshots[k] = snaps
+	return nil
+}
+
+func (ms memorySnapshots) Get(ctx context.Context, entityID generic.EntityID, policyID generic.PolicyID, period generic.Period) (*generic.Snapshot, error) {
+	ms.m.mu.RLock()
+	defer ms.m.mu.RUnlock()
+
+	for _, snap := range ms.m.snapshots[txKey(ctx, entityID, policyID)] {
+		if samePeriod(snap.Period, period) {
+			return &snap, nil
+		}
+	}
+	return nil, nil
+}
+
+// GetLatest returns the snapshot with the latest period end, or nil.
+func (ms memorySnapshots) GetLatest(ctx context.Context, entityID generic.EntityID, policyID generic.PolicyID) (*generic.Snapshot, error) {
+	ms.m.mu.RLock()
+	defer ms.m.mu.RUnlock()
+
+	snaps := ms.m.snapshots[txKey(ctx, entityID, policyID)]
+	if len(snaps) == 0 {
+		return nil, nil
+	}
+	snap := snaps[len(snaps)-1]
+	return &snap, nil
+}
+
+func samePeriod(a, b generic.Period) bool {
+	return a.Start.Time.Equal(b.Start.Time) && a.End.Time.Equal(b.End.Time)
+}
+
+// =============================================================================
+// HOLIDAY CALENDAR (generic.HolidayCalendar interface)
+// =============================================================================
+
+// SaveHoliday adds a holiday, or replaces the one with the same ID.
+func (m *Memory) SaveHoliday(ctx context.Context, h generic.Holiday) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	tenant := generic.TenantFrom(ctx)
+	if m.holidays[tenant] == nil {
+		m.holidays[tenant] = make(map[string]generic.Holiday)
+	}
+	m.holidays[tenant][h.ID] = h
+	return nil
+}
+
+// DeleteHoliday deletes a holiday by ID.
+func (m *Memory) DeleteHoliday(ctx context.Context, id string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	delete(m.holidays[generic.TenantFrom(ctx)], id)
+	return nil
+}
+
+// GetHolidays returns all holidays for a company in a given year, with
+// recurring and rule-based holidays moved to their date in that year.
+// Includes both company-specific and global holidays, but not those of
+// named calendars. generic.HolidayCalendar takes no context, so this
+// reads the default tenant; see HolidayCalendarFor.
+func (m *Memory) GetHolidays(companyID string, year int) []generic.Holiday {
+	return m.calendar(generic.DefaultTenant).GetHolidays(companyID, year)
+}
+
+// IsHoliday checks if a date is a holiday for the given company, in the
+// default tenant.
+func (m *Memory) IsHoliday(companyID string, date generic.TimePoint) bool {
+	return m.calendar(generic.DefaultTenant).IsHoliday(companyID, date)
+}
+
+// HolidayCalendarFor returns the generic.HolidayCalendar of a tenant.
+// It sees the tenant's holidays as they are when it is called.
+func (m *Memory) HolidayCalendarFor(tenant generic.TenantID) generic.HolidayCalendar {
+	return m.calendar(tenant)
+}
+
+// calendar copies a tenant's holidays, by date, into the calendar that
+// applies the company and named-calendar rules.
+func (m *Memory) calendar(tenant generic.TenantID) *generic.DefaultHolidayCalendar {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	holidays := make([]generic.Holiday, 0, len(m.holidays[tenant]))
+	for _, h := range m.holidays[tenant] {
+		holidays = append(holidays, h)
+	}
+	sort.Slice(holidays, func(i, j int) bool {
+		if !holidays[i].Date.Time.Equal(holidays[j].Date.Time) {
+			return holidays[i].Date.Time.Before(holidays[j].Date.Time)
+		}
+		return holidays[i].ID < holidays[j].ID
+	})
+	return &generic.DefaultHolidayCalendar{Holidays: holidays}
+}
+
+// =============================================================================
+// REQUESTS
+// =============================================================================
+
+// SaveRequest adds a request, or replaces the one with the same ID.
+func (m *Memory) SaveRequest(ctx context.Context, r generic.Request) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.requests[tenantKey{generic.TenantFrom(ctx), string(r.ID)}] = r
+	return nil
+}
+
+// GetRequest retrieves a request by ID, or nil.
+func (m *Memory) GetRequest(ctx context.Context, id generic.RequestID) (*generic.Request, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	r, ok := m.requests[tenantKey{generic.TenantFrom(ctx), string(id)}]
+	if !ok {
+		return nil, nil
+	}
+	return &r, nil
+}
+
+// GetPendingRequests returns the tenant's pending requests, oldest first.
+func (m *Memory) GetPendingRequests(ctx context.Context) ([]generic.Request, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	tenant := generic.TenantFrom(ctx)
+	var pending []generic.Request
+	for k, r := range m.requests {
+		if k.Tenant == tenant && r.Status == generic.RequestPending {
+			pending = append(pending, r)
+		}
+	}
+	sort.Slice(pending, func(i, j int) bool {
+		if !pending[i].CreatedAt.Equal(pending[j].CreatedAt) {
+			return pending[i].CreatedAt.Before(pending[j].CreatedAt)
+		}
+		return pending[i].ID < pending[j].ID
+	})
+	return pending, nil
+}
@@ -0,0 +1,134 @@
+package store_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/warp/resource-engine/generic"
+	"github.com/warp/resource-engine/generic/store"
+	"github.com/warp/resource-engine/generic/store/storetest"
+	"github.com/warp/resource-engine/timeoff"
+)
+
+func TestMemory_Conformance(t *testing.T) {
+	storetest.Run(t, func(t *testing.T) generic.Store { return store.NewMemory() })
+}
+
+func TestTxMemory_Conformance(t *testing.T) {
+	storetest.Run(t, func(t *testing.T) generic.Store { return store.NewTxMemory() })
+}
+
+func TestMemory_RunsTheEngine(t *testing.T) {
+	// GIVEN: A memory store with standard (priority 1, approval above 1 day)
+	//        and carryover (priority 2) PTO assignments, and a holiday
+	// WHEN: The generic services and the time-off ledger run on it
+	// THEN: Requests are split by priority, a second day off on the same
+	//       day is rejected across policies, the year-end snapshot reads
+	//       back, and holidays stay in their tenant
+	m := store.NewMemory()
+	ctx := context.Background()
+
+	days := func(n float64) generic.Amount { return generic.NewAmount(n, generic.UnitDays) }
+	yearly := generic.PeriodConfig{Type: generic.PeriodCalendarYear}
+	standard := generic.Policy{ID: "standard", ResourceType: timeoff.ResourcePTO, Unit: generic.UnitDays, PeriodConfig: yearly}
+	carryover := generic.Policy{ID: "carryover", ResourceType: timeoff.ResourcePTO, Unit: generic.UnitDays, PeriodConfig: yearly}
+	jan1 := generic.NewTimePoint(2025, time.January, 1)
+	mar10 := generic.NewTimePoint(2025, time.March, 10)
+
+	autoApprove := days(1)
+	assignments := m.Assignments()
+	for _, a := range []generic.PolicyAssignment{
+		{ID: "a-carryover", PolicyID: "carryover", Policy: carryover, ConsumptionPriority: 2},
+		{ID: "a-standard", PolicyID: "standard", Policy: standard, ConsumptionPriority: 1,
+			ApprovalConfig: generic.ApprovalConfig{RequiresApproval: true, AutoApproveUpTo: &autoApprove}},
+	} {
+		a.EntityID = "emp-1"
+		a.EffectiveFrom = jan1
+		if err := assignments.Save(ctx, a); err != nil {
+			t.Fatalf("Save assignment: %v", err)
+		}
+	}
+
+	ledger := timeoff.NewTimeOffLedger(m)
+	for _, g := range []struct {
+		policy generic.PolicyID
+		amount float64
+	}{{"standard", 10}, {"carryover", 3}} {
+		err := ledger.Append(ctx, generic.Transaction{
+			ID: generic.TransactionID("grant-" + g.policy), EntityID: "emp-1", PolicyID: g.policy,
+			ResourceType: timeoff.ResourcePTO, EffectiveAt: jan1, Delta: days(g.amount), Type: generic.TxGrant,
+			IdempotencyKey: "grant-" + string(g.policy),
+		})
+		if err != nil {
+			t.Fatalf("Grant %s: %v", g.policy, err)
+		}
+	}
+
+	calculator := &generic.ResourceBalanceCalculator{Ledger: ledger, AssignmentStore: assignments}
+	service := &generic.RequestService{
+		Ledger:          ledger,
+		AssignmentStore: assignments,
+		BalanceCalc:     calculator,
+		Distributor:     &generic.ConsumptionDistributor{},
+	}
+	req, err := service.CreateRequest(ctx, "emp-1", timeoff.ResourcePTO, mar10, days(3), "trip")
+	if err != nil {
+		t.Fatalf("CreateRequest: %v", err)
+	}
+	if req.Status != generic.RequestPending || len(req.Distribution.Allocations) != 1 || req.Distribution.Allocations[0].PolicyID != "standard" {
+		t.Errorf("Expected 3 pending days from standard, got %s %+v", req.Status, req.Distribution.Allocations)
+	}
+	if err := m.SaveRequest(ctx, *req); err != nil {
+		t.Fatalf("SaveRequest: %v", err)
+	}
+	if pending, _ := m.GetPendingRequests(ctx); len(pending) != 1 || pending[0].ID != req.ID {
+		t.Errorf("Expected the request to be pending, got %+v", pending)
+	}
+
+	rb, err := calculator.Calculate(ctx, "emp-1", timeoff.ResourcePTO, mar10)
+	if err != nil {
+		t.Fatalf("Calculate: %v", err)
+	}
+	split := service.Distributor.Distribute(rb, days(9), false)
+	if len(split.Allocations) != 2 || !split.Allocations[0].Amount.Value.Equal(days(7).Value) || !split.Allocations[1].Amount.Value.Equal(days(2).Value) {
+		t.Errorf("Expected 9 days to split 7 standard + 2 carryover, got %+v", split.Allocations)
+	}
+
+	err = ledger.Append(ctx, generic.Transaction{
+		ID: "same-day", EntityID: "emp-1", PolicyID: "carryover", ResourceType: timeoff.ResourcePTO,
+		EffectiveAt: mar10, Delta: days(-1), Type: generic.TxConsumption, IdempotencyKey: "same-day",
+	})
+	var dup *timeoff.DuplicateDayError
+	if !errors.As(err, &dup) {
+		t.Errorf("Expected DuplicateDayError for a second day off on March 10, got %v", err)
+	}
+
+	manager := &generic.PeriodManager{Ledger: ledger, SnapshotStore: m.Snapshots(), Reconciler: &generic.ReconciliationEngine{}}
+	year := yearly.PeriodFor(jan1)
+	if _, err := manager.ClosePeriod(ctx, generic.ClosePeriodInput{
+		EntityID: "emp-1", PolicyID: "standard", Policy: standard, Period: year, Reason: generic.SnapshotPeriodEnd,
+	}); err != nil {
+		t.Fatalf("ClosePeriod: %v", err)
+	}
+	latest, err := m.Snapshots().GetLatest(ctx, "emp-1", "standard")
+	if err != nil || latest == nil {
+		t.Fatalf("Expected the year-end snapshot, got %v (%v)", latest, err)
+	}
+	if !latest.Balance.Pending.Value.Equal(days(3).Value) {
+		t.Errorf("Snapshot pending = %v, want 3", latest.Balance.Pending.Value)
+	}
+
+	christmas := generic.Holiday{ID: "xmas", Date: generic.NewTimePoint(2024, time.December, 25), Name: "Christmas", Recurring: true}
+	if err := m.SaveHoliday(generic.WithTenant(ctx, "acme"), christmas); err != nil {
+		t.Fatalf("SaveHoliday: %v", err)
+	}
+	xmas2025 := generic.NewTimePoint(2025, time.December, 25)
+	if !m.HolidayCalendarFor("acme").IsHoliday("", xmas2025) {
+		t.Error("Expected the recurring holiday in acme's 2025 calendar")
+	}
+	if m.IsHoliday("", xmas2025) {
+		t.Error("Expected acme's holiday to stay out of the default tenant")
+	}
+}
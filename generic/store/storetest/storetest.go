@@ -0,0 +1,735 @@
+/*
+Package storetest is a conformance suite for generic.Store implementations.
+
+PURPOSE:
+  Every backend (generic/store, store/sqlite, store/kv, ...) must honor
+  the same contract, because the ledger and the time-off rules rely on it
+  rather than on any one backend. Run checks a backend against that
+  contract; a new backend proves itself by passing it.
+
+USAGE:
+  func TestConformance(t *testing.T) {
+      storetest.Run(t, func(t *testing.T) generic.Store {
+          s, err := mybackend.New(":memory:")
+          if err != nil {
+              t.Fatal(err)
+          }
+          t.Cleanup(func() { s.Close() })
+          return s
+      })
+  }
+
+CONTRACT CHECKED:
+  Store (always):
+    - Every field round-trips (times at second precision, UTC)
+    - Load/LoadRange are per entity+policy, ordered by EffectiveAt;
+      LoadRange bounds are inclusive (checked on whole days only: the
+      memory store compares TimePoints at their day granularity)
+    - A reused idempotency key fails with ErrDuplicateIdempotencyKey and
+      writes nothing; an empty key is never a duplicate
+    - AppendBatch is all-or-nothing, including for keys repeated within
+      the batch; an empty batch is a no-op
+    - Parallel appends all land; of parallel appends racing for one key,
+      exactly one wins
+
+  EntityStore (when implemented):
+    - LoadByEntity spans policies, ordered by EffectiveAt
+    - One consumption or pending transaction per entity, resource type and
+      UTC day (ErrDuplicateDayConsumption), across policies and within a
+      batch; other transaction types and other resource types don't count
+    - IsDayConsumed and GetConsumedDays agree with the above
+
+  TxStore (when implemented):
+    - WithTx commits when fn returns nil and rolls back everything,
+      idempotency keys included, when it returns an error
+    - Reads inside fn see fn's own appends
+
+  KnownAtStore (when implemented):
+    - Appends are stamped with CreatedAt (UTC, second precision)
+    - LoadRangeKnownAt keeps LoadRange's bounds and drops transactions
+      created after knownAt, inclusive at CreatedAt itself
+
+SEE ALSO:
+  - generic/store.go: Interface definitions
+  - generic/store/memory_test.go, store/sqlite/sqlite_test.go,
+    store/kv/store_test.go: Backends running the suite
+*/
+package storetest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/warp/resource-engine/generic"
+)
+
+// Factory returns a new, empty store. It is called once per check.
+type Factory func(t *testing.T) generic.Store
+
+// Run runs the conformance suite against the stores newStore returns.
+// EntityStore, TxStore and KnownAtStore checks are skipped for stores
+// that don't implement those interfaces.
+func Run(t *testing.T, newStore Factory) {
+	t.Run("Store", func(t *testing.T) {
+		for _, c := range storeChecks {
+			t.Run(c.name, func(t *testing.T) { c.fn(t, newStore(t)) })
+		}
+	})
+
+	t.Run("EntityStore", func(t *testing.T) {
+		for _, c := range entityChecks {
+			t.Run(c.name, func(t *testing.T) {
+				s, ok := newStore(t).(generic.EntityStore)
+				if !ok {
+					t.Skip("store does not implement generic.EntityStore")
+				}
+				c.fn(t, s)
+			})
+		}
+	})
+
+	t.Run("TxStore", func(t *testing.T) {
+		for _, c := range txChecks {
+			t.Run(c.name, func(t *testing.T) {
+				s, ok := newStore(t).(generic.TxStore)
+				if !ok {
+					t.Skip("store does not implement generic.TxStore")
+				}
+				c.fn(t, s)
+			})
+		}
+	})
+
+	t.Run("KnownAtStore", func(t *testing.T) {
+		for _, c := range knownAtChecks {
+			t.Run(c.name, func(t *testing.T) {
+				s, ok := newStore(t).(generic.KnownAtStore)
+				if !ok {
+					t.Skip("store does not implement generic.KnownAtStore")
+				}
+				c.fn(t, s)
+			})
+		}
+	})
+}
+
+var storeChecks = []struct {
+	name string
+	fn   func(t *testing.T, s generic.Store)
+}{
+	{"RoundTrip", testRoundTrip},
+	{"LoadOrdersByEffectiveAt", testLoadOrder},
+	{"LoadIsolatesEntityAndPolicy", testLoadIsolation},
+	{"LoadRangeIsInclusive", testLoadRange},
+	{"DuplicateIdempotencyKey", testDuplicateKey},
+	{"EmptyIdempotencyKey", testEmptyKey},
+	{"AppendBatchIsAtomic", testBatchAtomic},
+	{"AppendBatchEmpty", testBatchEmpty},
+	{"ParallelAppends", testParallelAppends},
+	{"ParallelDuplicateKey", testParallelDuplicateKey},
+}
+
+var entityChecks = []struct {
+	name string
+	fn   func(t *testing.T, s generic.EntityStore)
+}{
+	{"LoadByEntitySpansPolicies", testLoadByEntity},
+	{"OneDayOffPerDay", testDayUniqueness},
+	{"DayUniquenessInBatch", testDayUniquenessInBatch},
+	{"IsDayConsumed", testIsDayConsumed},
+	{"GetConsumedDays", testGetConsumedDays},
+}
+
+var txChecks = []struct {
+	name string
+	fn   func(t *testing.T, s generic.TxStore)
+}{
+	{"Commit", testTxCommit},
+	{"Rollback", testTxRollback},
+	{"ReadsOwnWrites", testTxReadsOwnWrites},
+}
+
+var knownAtChecks = []struct {
+	name string
+	fn   func(t *testing.T, s generic.KnownAtStore)
+}{
+	{"StampsCreatedAt", testStampsCreatedAt},
+	{"LoadRangeKnownAt", testLoadRangeKnownAt},
+}
+
+// =============================================================================
+// FIXTURES
+// =============================================================================
+
+var (
+	ctx = context.Background()
+
+	pto  = generic.NewStringResource("storetest-pto")
+	sick = generic.NewStringResource("storetest-sick")
+)
+
+// at returns 9:00 UTC on the given day of March 2025, plus any hours.
+func at(day int, hours ...int) generic.TimePoint {
+	h := 9
+	for _, extra := range hours {
+		h += extra
+	}
+	return generic.TimePoint{Time: time.Date(2025, time.March, day, h, 0, 0, 0, time.UTC)}
+}
+
+// newTx returns a PTO transaction keyed by its ID.
+func newTx(id string, entity generic.EntityID, policy generic.PolicyID, when generic.TimePoint, typ generic.TransactionType, delta float64) generic.Transaction {
+	return generic.Transaction{
+		ID:             generic.TransactionID(id),
+		EntityID:       entity,
+		PolicyID:       policy,
+		ResourceType:   pto,
+		EffectiveAt:    when,
+		Delta:          generic.NewAmount(delta, generic.UnitDays),
+		Type:           typ,
+		IdempotencyKey: "key-" + id,
+	}
+}
+
+func mustAppend(t *testing.T, s generic.Store, txs ...generic.Transaction) {
+	t.Helper()
+	for _, tx := range txs {
+		if err := s.Append(ctx, tx); err != nil {
+			t.Fatalf("Append(%s) failed: %v", tx.ID, err)
+		}
+	}
+}
+
+func mustLoad(t *testing.T, s generic.Store, entity generic.EntityID, policy generic.PolicyID) []generic.Transaction {
+	t.Helper()
+	txs, err := s.Load(ctx, entity, policy)
+	if err != nil {
+		t.Fatalf("Load(%s, %s) failed: %v", entity, policy, err)
+	}
+	return txs
+}
+
+// ids lists transaction IDs, for comparing order.
+func ids(txs []generic.Transaction) string {
+	s := ""
+	for i, tx := range txs {
+		if i > 0 {
+			s += ","
+		}
+		s += string(tx.ID)
+	}
+	return s
+}
+
+// =============================================================================
+// STORE
+// =============================================================================
+
+func testRoundTrip(t *testing.T, s generic.Store) {
+	want := generic.Transaction{
+		ID:             "tx-1",
+		EntityID:       "emp-1",
+		PolicyID:       "pol-1",
+		ResourceType:   pto,
+		EffectiveAt:    at(10),
+		Delta:          generic.NewAmount(-1.5, generic.UnitDays),
+		Type:           generic.TxConsumption,
+		ReferenceID:    "req-1",
+		Reason:         "vacation",
+		IdempotencyKey: "key-1",
+		Metadata:       map[string]string{"source": "storetest"},
+		CreatedBy:      "mgr-1",
+		CreatedByType:  "manager",
+	}
+	mustAppend(t, s, want)
+
+	txs := mustLoad(t, s, "emp-1", "pol-1")
+	if len(txs) != 1 {
+		t.Fatalf("Expected 1 transaction, got %d", len(txs))
+	}
+	got := txs[0]
+	if got.ID != want.ID || got.EntityID != want.EntityID || got.PolicyID != want.PolicyID || got.Type != want.Type {
+		t.Errorf("Identity changed: got %s/%s/%s/%s", got.ID, got.EntityID, got.PolicyID, got.Type)
+	}
+	if got.ResourceType == nil || got.ResourceType.ResourceID() != pto.ResourceID() {
+		t.Errorf("Expected resource type %s, got %v", pto.ResourceID(), got.ResourceType)
+	}
+	if !got.EffectiveAt.Time.Equal(want.EffectiveAt.Time) {
+		t.Errorf("Expected EffectiveAt %v, got %v", want.EffectiveAt.Time, got.EffectiveAt.Time)
+	}
+	if !got.Delta.Value.Equal(want.Delta.Value) || got.Delta.Unit != want.Delta.Unit {
+		t.Errorf("Expected delta %s %s, got %s %s", want.Delta.Value, want.Delta.Unit, got.Delta.Value, got.Delta.Unit)
+	}
+	if got.ReferenceID != want.ReferenceID || got.Reason != want.Reason || got.IdempotencyKey != want.IdempotencyKey {
+		t.Errorf("Expected reference/reason/key %q/%q/%q, got %q/%q/%q",
+			want.ReferenceID, want.Reason, want.IdempotencyKey, got.ReferenceID, got.Reason, got.IdempotencyKey)
+	}
+	if got.Metadata["source"] != "storetest" || len(got.Metadata) != 1 {
+		t.Errorf("Expected metadata to round-trip, got %v", got.Metadata)
+	}
+	if got.CreatedBy != want.CreatedBy || got.CreatedByType != want.CreatedByType {
+		t.Errorf("Expected creator %s (%s), got %s (%s)", want.CreatedBy, want.CreatedByType, got.CreatedBy, got.CreatedByType)
+	}
+
+	exists, err := s.Exists(ctx, "key-1")
+	if err != nil || !exists {
+		t.Errorf("Expected Exists(key-1) = true, got %v (%v)", exists, err)
+	}
+	exists, err = s.Exists(ctx, "key-unknown")
+	if err != nil || exists {
+		t.Errorf("Expected Exists(key-unknown) = false, got %v (%v)", exists, err)
+	}
+}
+
+func testLoadOrder(t *testing.T, s generic.Store) {
+	// Appended out of order, one of them in a batch
+	mustAppend(t, s, newTx("c", "emp-1", "pol-1", at(20), generic.TxGrant, 1))
+	mustAppend(t, s, newTx("a", "emp-1", "pol-1", at(1), generic.TxGrant, 1))
+	if err := s.AppendBatch(ctx, []generic.Transaction{
+		newTx("d", "emp-1", "pol-1", at(25), generic.TxGrant, 1),
+		newTx("b", "emp-1", "pol-1", at(5), generic.TxGrant, 1),
+	}); err != nil {
+		t.Fatalf("AppendBatch failed: %v", err)
+	}
+
+	if got := ids(mustLoad(t, s, "emp-1", "pol-1")); got != "a,b,c,d" {
+		t.Errorf("Expected Load ordered by EffectiveAt (a,b,c,d), got %s", got)
+	}
+	txs, err := s.LoadRange(ctx, "emp-1", "pol-1", at(1), at(31))
+	if err != nil {
+		t.Fatalf("LoadRange failed: %v", err)
+	}
+	if got := ids(txs); got != "a,b,c,d" {
+		t.Errorf("Expected LoadRange ordered by EffectiveAt (a,b,c,d), got %s", got)
+	}
+}
+
+func testLoadIsolation(t *testing.T, s generic.Store) {
+	mustAppend(t, s,
+		newTx("mine", "emp-1", "pol-1", at(1), generic.TxGrant, 1),
+		newTx("other-policy", "emp-1", "pol-2", at(1), generic.TxGrant, 1),
+		newTx("other-entity", "emp-2", "pol-1", at(1), generic.TxGrant, 1),
+		// IDs that are prefixes of each other must not match either
+		newTx("prefix-entity", "emp-10", "pol-1", at(1), generic.TxGrant, 1),
+		newTx("prefix-policy", "emp-1", "pol-10", at(1), generic.TxGrant, 1),
+	)
+
+	if got := ids(mustLoad(t, s, "emp-1", "pol-1")); got != "mine" {
+		t.Errorf("Expected only emp-1/pol-1's transaction, got %s", got)
+	}
+	if got := mustLoad(t, s, "emp-3", "pol-1"); len(got) != 0 {
+		t.Errorf("Expected nothing for an unknown entity, got %s", ids(got))
+	}
+}
+
+func testLoadRange(t *testing.T, s generic.Store) {
+	mustAppend(t, s,
+		newTx("before", "emp-1", "pol-1", at(9), generic.TxGrant, 1),
+		newTx("from", "emp-1", "pol-1", at(10), generic.TxGrant, 1),
+		newTx("inside", "emp-1", "pol-1", at(15), generic.TxGrant, 1),
+		newTx("to", "emp-1", "pol-1", at(20), generic.TxGrant, 1),
+		newTx("after", "emp-1", "pol-1", at(21), generic.TxGrant, 1),
+	)
+
+	txs, err := s.LoadRange(ctx, "emp-1", "pol-1", at(10), at(20))
+	if err != nil {
+		t.Fatalf("LoadRange failed: %v", err)
+	}
+	if got := ids(txs); got != "from,inside,to" {
+		t.Errorf("Expected [from, to] inclusive (from,inside,to), got %s", got)
+	}
+}
+
+func testDuplicateKey(t *testing.T, s generic.Store) {
+	first := newTx("first", "emp-1", "pol-1", at(1), generic.TxGrant, 1)
+	mustAppend(t, s, first)
+
+	second := newTx("second", "emp-1", "pol-1", at(2), generic.TxGrant, 5)
+	second.IdempotencyKey = first.IdempotencyKey
+	if err := s.Append(ctx, second); !errors.Is(err, generic.ErrDuplicateIdempotencyKey) {
+		t.Errorf("Expected ErrDuplicateIdempotencyKey, got %v", err)
+	}
+	if got := ids(mustLoad(t, s, "emp-1", "pol-1")); got != "first" {
+		t.Errorf("Expected the rejected append to write nothing, got %s", got)
+	}
+}
+
+func testEmptyKey(t *testing.T, s generic.Store) {
+	a := newTx("a", "emp-1", "pol-1", at(1), generic.TxGrant, 1)
+	b := newTx("b", "emp-1", "pol-1", at(2), generic.TxGrant, 1)
+	a.IdempotencyKey, b.IdempotencyKey = "", ""
+	mustAppend(t, s, a, b)
+
+	if got := ids(mustLoad(t, s, "emp-1", "pol-1")); got != "a,b" {
+		t.Errorf("Expected transactions without keys to never collide, got %s", got)
+	}
+	if exists, _ := s.Exists(ctx, ""); exists {
+		t.Error("Expected the empty key to never exist")
+	}
+}
+
+func testBatchAtomic(t *testing.T, s generic.Store) {
+	mustAppend(t, s, newTx("existing", "emp-1", "pol-1", at(1), generic.TxGrant, 1))
+
+	// Conflicts with a stored key
+	clash := newTx("clash", "emp-1", "pol-1", at(3), generic.TxGrant, 1)
+	clash.IdempotencyKey = "key-existing"
+	err := s.AppendBatch(ctx, []generic.Transaction{
+		newTx("ok-1", "emp-1", "pol-1", at(2), generic.TxGrant, 1),
+		clash,
+	})
+	if !errors.Is(err, generic.ErrDuplicateIdempotencyKey) {
+		t.Errorf("Expected ErrDuplicateIdempotencyKey for a stored key, got %v", err)
+	}
+
+	// Repeats a key within the batch
+	twin := newTx("twin", "emp-1", "pol-1", at(5), generic.TxGrant, 1)
+	twin.IdempotencyKey = "key-ok-2"
+	err = s.AppendBatch(ctx, []generic.Transaction{
+		newTx("ok-2", "emp-1", "pol-1", at(4), generic.TxGrant, 1),
+		twin,
+	})
+	if !errors.Is(err, generic.ErrDuplicateIdempotencyKey) {
+		t.Errorf("Expected ErrDuplicateIdempotencyKey for a key repeated in the batch, got %v", err)
+	}
+
+	if got := ids(mustLoad(t, s, "emp-1", "pol-1")); got != "existing" {
+		t.Errorf("Expected failed batches to write nothing, got %s", got)
+	}
+	for _, key := range []string{"key-ok-1", "key-ok-2"} {
+		if exists, _ := s.Exists(ctx, key); exists {
+			t.Errorf("Expected %s from a failed batch not to exist", key)
+		}
+	}
+}
+
+func testBatchEmpty(t *testing.T, s generic.Store) {
+	if err := s.AppendBatch(ctx, nil); err != nil {
+		t.Errorf("Expected an empty batch to succeed, got %v", err)
+	}
+	if got := mustLoad(t, s, "emp-1", "pol-1"); len(got) != 0 {
+		t.Errorf("Expected an empty batch to write nothing, got %s", ids(got))
+	}
+}
+
+func testParallelAppends(t *testing.T, s generic.Store) {
+	const writers, perWriter = 8, 10
+
+	var wg sync.WaitGroup
+	errs := make(chan error, writers*perWriter)
+	for w := 0; w < writers; w++ {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+			for i := 0; i < perWriter; i++ {
+				id := fmt.Sprintf("w%d-%d", w, i)
+				if i%2 == 0 {
+					errs <- s.Append(ctx, newTx(id, "emp-1", "pol-1", at(1+i), generic.TxGrant, 1))
+				} else {
+					errs <- s.AppendBatch(ctx, []generic.Transaction{newTx(id, "emp-1", "pol-1", at(1+i), generic.TxGrant, 1)})
+				}
+			}
+		}(w)
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		if err != nil {
+			t.Errorf("Parallel append failed: %v", err)
+		}
+	}
+
+	txs := mustLoad(t, s, "emp-1", "pol-1")
+	if len(txs) != writers*perWriter {
+		t.Errorf("Expected %d transactions, got %d", writers*perWriter, len(txs))
+	}
+	for i := 1; i < len(txs); i++ {
+		if txs[i].EffectiveAt.Time.Before(txs[i-1].EffectiveAt.Time) {
+			t.Fatalf("Expected parallel appends to stay ordered by EffectiveAt, got %s", ids(txs))
+		}
+	}
+}
+
+func testParallelDuplicateKey(t *testing.T, s generic.Store) {
+	const racers = 8
+
+	var wg sync.WaitGroup
+	errs := make(chan error, racers)
+	for r := 0; r < racers; r++ {
+		wg.Add(1)
+		go func(r int) {
+			defer wg.Done()
+			tx := newTx(fmt.Sprintf("racer-%d", r), "emp-1", "pol-1", at(1), generic.TxGrant, 1)
+			tx.IdempotencyKey = "contended"
+			errs <- s.Append(ctx, tx)
+		}(r)
+	}
+	wg.Wait()
+	close(errs)
+
+	won := 0
+	for err := range errs {
+		switch {
+		case err == nil:
+			won++
+		case !errors.Is(err, generic.ErrDuplicateIdempotencyKey):
+			t.Errorf("Expected losers to get ErrDuplicateIdempotencyKey, got %v", err)
+		}
+	}
+	if won != 1 {
+		t.Errorf("Expected exactly one append to win the key, got %d", won)
+	}
+	if got := mustLoad(t, s, "emp-1", "pol-1"); len(got) != 1 {
+		t.Errorf("Expected 1 stored transaction, got %d", len(got))
+	}
+}
+
+// =============================================================================
+// ENTITY STORE
+// =============================================================================
+
+func testLoadByEntity(t *testing.T, s generic.EntityStore) {
+	mustAppend(t, s,
+		newTx("pto-late", "emp-1", "pto", at(20), generic.TxGrant, 1),
+		newTx("sick-early", "emp-1", "sick", at(2), generic.TxGrant, 1),
+		newTx("pto-mid", "emp-1", "pto", at(10), generic.TxGrant, 1),
+		newTx("outside", "emp-1", "pto", at(30), generic.TxGrant, 1),
+		newTx("other", "emp-2", "pto", at(10), generic.TxGrant, 1),
+	)
+
+	txs, err := s.LoadByEntity(ctx, "emp-1", at(1), at(20))
+	if err != nil {
+		t.Fatalf("LoadByEntity failed: %v", err)
+	}
+	if got := ids(txs); got != "sick-early,pto-mid,pto-late" {
+		t.Errorf("Expected emp-1's transactions across policies in [from, to], by EffectiveAt, got %s", got)
+	}
+}
+
+func testDayUniqueness(t *testing.T, s generic.EntityStore) {
+	mustAppend(t, s, newTx("monday", "emp-1", "pto", at(10), generic.TxConsumption, -1))
+
+	// Same day, resource type and entity: rejected whatever the policy,
+	// time of day or consumption type
+	for _, tx := range []generic.Transaction{
+		newTx("same-policy", "emp-1", "pto", at(10), generic.TxConsumption, -1),
+		newTx("other-policy", "emp-1", "pto-bonus", at(10, 5), generic.TxConsumption, -1),
+		newTx("pending", "emp-1", "pto-bonus", at(10), generic.TxPending, -1),
+	} {
+		if err := s.Append(ctx, tx); !errors.Is(err, generic.ErrDuplicateDayConsumption) {
+			t.Errorf("Expected ErrDuplicateDayConsumption for %s, got %v", tx.ID, err)
+		}
+	}
+
+	// Not the same resource, entity, day or kind of transaction: allowed
+	sickDay := newTx("sick", "emp-1", "sick", at(10), generic.TxConsumption, -1)
+	sickDay.ResourceType = sick
+	mustAppend(t, s,
+		sickDay,
+		newTx("colleague", "emp-2", "pto", at(10), generic.TxConsumption, -1),
+		newTx("tuesday", "emp-1", "pto", at(11), generic.TxConsumption, -1),
+		newTx("grant", "emp-1", "pto", at(10), generic.TxGrant, 1),
+		newTx("adjustment", "emp-1", "pto", at(10), generic.TxAdjustment, -1),
+	)
+
+	if got := ids(mustLoad(t, s, "emp-1", "pto-bonus")); got != "" {
+		t.Errorf("Expected rejected appends to write nothing, got %s", got)
+	}
+}
+
+func testDayUniquenessInBatch(t *testing.T, s generic.EntityStore) {
+	err := s.AppendBatch(ctx, []generic.Transaction{
+		newTx("first", "emp-1", "pto", at(10), generic.TxConsumption, -1),
+		newTx("second", "emp-1", "pto-bonus", at(10, 2), generic.TxConsumption, -1),
+	})
+	if !errors.Is(err, generic.ErrDuplicateDayConsumption) {
+		t.Errorf("Expected ErrDuplicateDayConsumption for two days off on one day, got %v", err)
+	}
+	if consumed, _, _ := s.IsDayConsumed(ctx, "emp-1", pto, at(10)); consumed {
+		t.Error("Expected the failed batch to write nothing")
+	}
+}
+
+func testIsDayConsumed(t *testing.T, s generic.EntityStore) {
+	mustAppend(t, s,
+		newTx("taken", "emp-1", "pto", at(10), generic.TxConsumption, -1),
+		newTx("held", "emp-1", "pto", at(12), generic.TxPending, -1),
+		newTx("granted", "emp-1", "pto", at(14), generic.TxGrant, 1),
+	)
+
+	cases := []struct {
+		day    generic.TimePoint
+		wantID generic.TransactionID
+	}{
+		{at(10), "taken"},
+		{at(10, -9), "taken"}, // Midnight: any time on the day
+		{at(12), "held"},
+		{at(14), ""},
+		{at(11), ""},
+	}
+	for _, c := range cases {
+		consumed, id, err := s.IsDayConsumed(ctx, "emp-1", pto, c.day)
+		if err != nil {
+			t.Fatalf("IsDayConsumed failed: %v", err)
+		}
+		if consumed != (c.wantID != "") || id != c.wantID {
+			t.Errorf("IsDayConsumed(%s) = %v, %q; expected %q", c.day.Time.Format(time.RFC3339), consumed, id, c.wantID)
+		}
+	}
+	if consumed, _, _ := s.IsDayConsumed(ctx, "emp-1", sick, at(10)); consumed {
+		t.Error("Expected another resource type's day to be free")
+	}
+}
+
+func testGetConsumedDays(t *testing.T, s generic.EntityStore) {
+	mustAppend(t, s,
+		newTx("d12", "emp-1", "pto-bonus", at(12), generic.TxPending, -1),
+		newTx("d10", "emp-1", "pto", at(10), generic.TxConsumption, -1),
+		newTx("d20", "emp-1", "pto", at(20), generic.TxConsumption, -1),
+		newTx("grant", "emp-1", "pto", at(11), generic.TxGrant, 1),
+		newTx("d25", "emp-1", "pto", at(25), generic.TxConsumption, -1),
+	)
+
+	days, err := s.GetConsumedDays(ctx, "emp-1", pto, at(1), at(20))
+	if err != nil {
+		t.Fatalf("GetConsumedDays failed: %v", err)
+	}
+	var got []string
+	for _, d := range days {
+		got = append(got, d.Time.UTC().Format("2006-01-02"))
+	}
+	if want := "[2025-03-10 2025-03-12 2025-03-20]"; fmt.Sprint(got) != want {
+		t.Errorf("Expected consumed days %s, got %v", want, got)
+	}
+}
+
+// =============================================================================
+// TX STORE
+// =============================================================================
+
+func testTxCommit(t *testing.T, s generic.TxStore) {
+	err := s.WithTx(ctx, func(tx generic.Store) error {
+		if err := tx.Append(ctx, newTx("a", "emp-1", "pol-1", at(1), generic.TxGrant, 1)); err != nil {
+			return err
+		}
+		return tx.AppendBatch(ctx, []generic.Transaction{newTx("b", "emp-1", "pol-1", at(2), generic.TxGrant, 1)})
+	})
+	if err != nil {
+		t.Fatalf("WithTx failed: %v", err)
+	}
+	if got := ids(mustLoad(t, s, "emp-1", "pol-1")); got != "a,b" {
+		t.Errorf("Expected committed appends, got %s", got)
+	}
+}
+
+func testTxRollback(t *testing.T, s generic.TxStore) {
+	mustAppend(t, s, newTx("before", "emp-1", "pol-1", at(1), generic.TxGrant, 1))
+
+	failure := errors.New("storetest: abort")
+	err := s.WithTx(ctx, func(tx generic.Store) error {
+		if err := tx.Append(ctx, newTx("rolled-back", "emp-1", "pol-1", at(2), generic.TxGrant, 1)); err != nil {
+			return err
+		}
+		return failure
+	})
+	if !errors.Is(err, failure) {
+		t.Errorf("Expected WithTx to return fn's error, got %v", err)
+	}
+
+	if got := ids(mustLoad(t, s, "emp-1", "pol-1")); got != "before" {
+		t.Errorf("Expected the rolled-back append to be gone, got %s", got)
+	}
+	if exists, _ := s.Exists(ctx, "key-rolled-back"); exists {
+		t.Error("Expected the rolled-back idempotency key to be free")
+	}
+	mustAppend(t, s, newTx("rolled-back", "emp-1", "pol-1", at(2), generic.TxGrant, 1))
+}
+
+func testTxReadsOwnWrites(t *testing.T, s generic.TxStore) {
+	mustAppend(t, s, newTx("before", "emp-1", "pol-1", at(1), generic.TxGrant, 1))
+
+	err := s.WithTx(ctx, func(tx generic.Store) error {
+		if err := tx.Append(ctx, newTx("inside", "emp-1", "pol-1", at(2), generic.TxGrant, 1)); err != nil {
+			return err
+		}
+
+		txs, err := tx.Load(ctx, "emp-1", "pol-1")
+		if err != nil {
+			return err
+		}
+		if got := ids(txs); got != "before,inside" {
+			t.Errorf("Expected Load inside the transaction to see its append, got %s", got)
+		}
+		txs, err = tx.LoadRange(ctx, "emp-1", "pol-1", at(2), at(2))
+		if err != nil {
+			return err
+		}
+		if got := ids(txs); got != "inside" {
+			t.Errorf("Expected LoadRange inside the transaction to see its append, got %s", got)
+		}
+		if exists, err := tx.Exists(ctx, "key-inside"); err != nil || !exists {
+			t.Errorf("Expected Exists inside the transaction to see its key, got %v (%v)", exists, err)
+		}
+		if err := tx.Append(ctx, newTx("again", "emp-1", "pol-1", at(3), generic.TxGrant, 1)); err != nil {
+			return err
+		}
+		dup := newTx("dup", "emp-1", "pol-1", at(4), generic.TxGrant, 1)
+		dup.IdempotencyKey = "key-inside"
+		if err := tx.Append(ctx, dup); !errors.Is(err, generic.ErrDuplicateIdempotencyKey) {
+			t.Errorf("Expected ErrDuplicateIdempotencyKey inside the transaction, got %v", err)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("WithTx failed: %v", err)
+	}
+}
+
+// =============================================================================
+// KNOWN AT STORE
+// =============================================================================
+
+func testStampsCreatedAt(t *testing.T, s generic.KnownAtStore) {
+	before := time.Now().UTC().Truncate(time.Second)
+	mustAppend(t, s, newTx("a", "emp-1", "pol-1", at(1), generic.TxGrant, 1))
+	after := time.Now().UTC()
+
+	got := mustLoad(t, s, "emp-1", "pol-1")[0].CreatedAt.Time
+	if got.Before(before) || got.After(after) {
+		t.Errorf("Expected CreatedAt between %v and %v, got %v", before, after, got)
+	}
+	if got.Location() != time.UTC || !got.Equal(got.Truncate(time.Second)) {
+		t.Errorf("Expected CreatedAt in UTC at second precision, got %v", got)
+	}
+}
+
+// CreatedAt is stamped by the store, so knownAt is taken relative to it
+// rather than by waiting for the clock.
+func testLoadRangeKnownAt(t *testing.T, s generic.KnownAtStore) {
+	mustAppend(t, s,
+		newTx("before", "emp-1", "pol-1", at(9), generic.TxGrant, 1),
+		newTx("inside", "emp-1", "pol-1", at(15), generic.TxGrant, 1),
+		newTx("after", "emp-1", "pol-1", at(21), generic.TxGrant, 1),
+	)
+	createdAt := mustLoad(t, s, "emp-1", "pol-1")[0].CreatedAt.Time
+
+	txs, err := s.LoadRangeKnownAt(ctx, "emp-1", "pol-1", at(10), at(20), createdAt)
+	if err != nil {
+		t.Fatalf("LoadRangeKnownAt failed: %v", err)
+	}
+	if got := ids(txs); got != "inside" {
+		t.Errorf("Expected the range as of CreatedAt (inside), got %s", got)
+	}
+
+	txs, err = s.LoadRangeKnownAt(ctx, "emp-1", "pol-1", at(10), at(20), createdAt.Add(-time.Second))
+	if err != nil {
+		t.Fatalf("LoadRangeKnownAt failed: %v", err)
+	}
+	if len(txs) != 0 {
+		t.Errorf("Expected nothing known before the appends, got %s", ids(txs))
+	}
+}
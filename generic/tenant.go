@@ -0,0 +1,46 @@
+/*
+tenant.go - Tenant scoping via context
+
+PURPOSE:
+  One server can host several companies (tenants). Every stored record
+  belongs to exactly one tenant, and every read and write is scoped to the
+  tenant carried in the context. The tenant travels with the context so
+  that domain code (ledger, calendars, policies) stays tenant-agnostic:
+  whoever builds the context decides the tenant, the store enforces it.
+
+DEFAULT TENANT:
+  A context without a tenant belongs to DefaultTenant (""). Single-tenant
+  deployments never set one, and data created before multi-tenancy lives
+  there.
+
+WHERE THE TENANT COMES FROM:
+  - HTTP requests:  api.Handler.ResolveTenant (X-Tenant-ID header)
+  - Scheduler runs: one context per tenant
+  - Tests/scripts:  WithTenant(ctx, "acme")
+
+SEE ALSO:
+  - store/sqlite/sqlite.go: tenant_id on every table
+  - api/tenants.go: tenant resolution and management endpoints
+*/
+package generic
+
+import "context"
+
+// TenantID identifies a tenant (one company hosted on the server).
+type TenantID string
+
+// DefaultTenant is the tenant of contexts that carry none.
+const DefaultTenant TenantID = ""
+
+type tenantKey struct{}
+
+// WithTenant returns a context scoped to a tenant.
+func WithTenant(ctx context.Context, tenant TenantID) context.Context {
+	return context.WithValue(ctx, tenantKey{}, tenant)
+}
+
+// TenantFrom returns the tenant of a context, or DefaultTenant.
+func TenantFrom(ctx context.Context) TenantID {
+	tenant, _ := ctx.Value(tenantKey{}).(TenantID)
+	return tenant
+}
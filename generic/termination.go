@@ -0,0 +1,143 @@
+/*
+termination.go - Final settlement when an entity leaves
+
+PURPOSE:
+  When an employee is terminated, every policy they hold must be closed out
+  with a final balance. Whatever is left is either paid out or forfeited,
+  and whatever was used beyond what was earned is clawed back.
+
+KEY INSIGHT:
+  Settlement is always computed on what was EARNED, not on the full period
+  entitlement. A ConsumeAhead employee who took 15 days in February and
+  leaves in March has only earned ~5 days, so 10 days are clawed back.
+  This is the risk described under ConsumeAhead in policy.go.
+
+  Final balance = AccruedToDate - Consumed + Adjustments - Pending
+
+OUTCOMES:
+  Final > 0, payout:  TxReconciliation -final ("final payout")
+  Final > 0, forfeit: TxReconciliation -final ("forfeited")
+  Final < 0:          TxReconciliation +|final| ("clawback")
+  Final = 0:          No transaction
+
+  Every outcome zeroes the policy balance at the termination date, so the
+  ledger stays append-only and balanced.
+
+EXAMPLE:
+  engine := &TerminationEngine{}
+  output, _ := engine.Settle(TerminationInput{
+      EntityID:        "emp-123",
+      Policy:          policy,
+      Balance:         balanceAsOfTermination,
+      TerminationDate: march15,
+      Payout:          true,
+  })
+  fmt.Println(output.Settlement.PaidOut)
+
+SEE ALSO:
+  - policy.go: ReconciliationEngine (same shape, period-end instead of exit)
+  - balance.go: Balance.CurrentAccrued()
+*/
+package generic
+
+// =============================================================================
+// TERMINATION ENGINE
+// =============================================================================
+
+// SettlementAction describes what happened to a policy balance at termination.
+type SettlementAction string
+
+const (
+	SettlementNone     SettlementAction = "none"     // Nothing left, nothing owed
+	SettlementPayout   SettlementAction = "payout"   // Remaining balance paid out
+	SettlementForfeit  SettlementAction = "forfeit"  // Remaining balance lost
+	SettlementClawback SettlementAction = "clawback" // Overdrawn balance recovered
+)
+
+// TerminationInput contains everything needed to settle one policy.
+type TerminationInput struct {
+	EntityID        EntityID
+	Policy          Policy
+	Balance         Balance // Computed as of TerminationDate
+	TerminationDate TimePoint
+	Payout          bool // false = forfeit positive balances
+}
+
+// TerminationSettlement is the per-policy final statement.
+type TerminationSettlement struct {
+	PolicyID     PolicyID
+	Action       SettlementAction
+	FinalBalance Amount
+	PaidOut      Amount
+	Forfeited    Amount
+	ClawedBack   Amount
+}
+
+type TerminationOutput struct {
+	Transactions []Transaction
+	Settlement   TerminationSettlement
+}
+
+type TerminationEngine struct{}
+
+// Settle computes the final balance for a policy and the transaction that
+// closes it out.
+func (te *TerminationEngine) Settle(input TerminationInput) (*TerminationOutput, error) {
+	unit := input.Policy.Unit
+	if unit == "" {
+		unit = input.Balance.AccruedToDate.Unit
+	}
+	zero := NewAmount(0, unit)
+
+	settlement := TerminationSettlement{
+		PolicyID:     input.Policy.ID,
+		Action:       SettlementNone,
+		FinalBalance: zero,
+		PaidOut:      zero,
+		Forfeited:    zero,
+		ClawedBack:   zero,
+	}
+
+	// Unlimited policies have no balance to settle
+	if input.Policy.IsUnlimited {
+		return &TerminationOutput{Settlement: settlement}, nil
+	}
+
+	// Prorated: only what was earned up to the termination date counts
+	final := input.Balance.AvailableWithMode(ConsumeUpToAccrued)
+	settlement.FinalBalance = final
+
+	if final.IsZero() {
+		return &TerminationOutput{Settlement: settlement}, nil
+	}
+
+	tx := Transaction{
+		EntityID:     input.EntityID,
+		PolicyID:     input.Policy.ID,
+		ResourceType: input.Policy.ResourceType,
+		EffectiveAt:  input.TerminationDate,
+		Delta:        final.Neg(),
+		Type:         TxReconciliation,
+	}
+
+	switch {
+	case final.IsNegative():
+		settlement.Action = SettlementClawback
+		settlement.ClawedBack = final.Neg()
+		tx.Reason = "clawback of unearned balance on termination"
+	case input.Payout:
+		settlement.Action = SettlementPayout
+		settlement.PaidOut = final
+		tx.Reason = "final payout on termination"
+	default:
+		settlement.Action = SettlementForfeit
+		settlement.Forfeited = final
+		tx.Reason = "balance forfeited on termination"
+	}
+	tx.Metadata = map[string]string{"settlement": string(settlement.Action)}
+
+	return &TerminationOutput{
+		Transactions: []Transaction{tx},
+		Settlement:   settlement,
+	}, nil
+}
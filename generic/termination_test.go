@@ -0,0 +1,141 @@
+package generic_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/warp/resource-engine/generic"
+)
+
+func terminationPolicy() generic.Policy {
+	return generic.Policy{
+		ID:              "pto",
+		ResourceType:    testResourceType,
+		Unit:            generic.UnitDays,
+		ConsumptionMode: generic.ConsumeAhead,
+	}
+}
+
+func TestTermination_PayoutRemainingBalance(t *testing.T) {
+	// GIVEN: 10 days earned, 4 used
+	// WHEN: Terminated with payout
+	// THEN: 6 days paid out via a single -6 reconciliation transaction
+
+	engine := &generic.TerminationEngine{}
+	output, err := engine.Settle(generic.TerminationInput{
+		EntityID:        "emp-1",
+		Policy:          terminationPolicy(),
+		Balance:         balance(10, 4),
+		TerminationDate: generic.NewTimePoint(2025, time.June, 30),
+		Payout:          true,
+	})
+	if err != nil {
+		t.Fatalf("Settle failed: %v", err)
+	}
+
+	if output.Settlement.Action != generic.SettlementPayout {
+		t.Errorf("Expected payout, got %s", output.Settlement.Action)
+	}
+	if !approxEqual(output.Settlement.PaidOut, days(6)) {
+		t.Errorf("Expected 6 days paid out, got %v", output.Settlement.PaidOut)
+	}
+	if len(output.Transactions) != 1 {
+		t.Fatalf("Expected 1 transaction, got %d", len(output.Transactions))
+	}
+	tx := output.Transactions[0]
+	if tx.Type != generic.TxReconciliation {
+		t.Errorf("Expected TxReconciliation, got %s", tx.Type)
+	}
+	if !approxEqual(tx.Delta, days(-6)) {
+		t.Errorf("Expected delta -6, got %v", tx.Delta)
+	}
+}
+
+func TestTermination_ForfeitWhenNoPayout(t *testing.T) {
+	engine := &generic.TerminationEngine{}
+	output, err := engine.Settle(generic.TerminationInput{
+		EntityID:        "emp-1",
+		Policy:          terminationPolicy(),
+		Balance:         balance(10, 4),
+		TerminationDate: generic.NewTimePoint(2025, time.June, 30),
+		Payout:          false,
+	})
+	if err != nil {
+		t.Fatalf("Settle failed: %v", err)
+	}
+
+	if output.Settlement.Action != generic.SettlementForfeit {
+		t.Errorf("Expected forfeit, got %s", output.Settlement.Action)
+	}
+	if !approxEqual(output.Settlement.Forfeited, days(6)) {
+		t.Errorf("Expected 6 days forfeited, got %v", output.Settlement.Forfeited)
+	}
+	if !output.Settlement.PaidOut.IsZero() {
+		t.Errorf("Expected nothing paid out, got %v", output.Settlement.PaidOut)
+	}
+}
+
+func TestTermination_ClawbackConsumeAheadOveruse(t *testing.T) {
+	// GIVEN: ConsumeAhead policy, 24 day entitlement, only 5 earned, 15 used
+	// WHEN: Terminated
+	// THEN: 10 unearned days clawed back (+10), regardless of payout flag
+
+	b := balance(5, 15)
+	b.TotalEntitlement = days(24)
+
+	engine := &generic.TerminationEngine{}
+	output, err := engine.Settle(generic.TerminationInput{
+		EntityID:        "emp-1",
+		Policy:          terminationPolicy(),
+		Balance:         b,
+		TerminationDate: generic.NewTimePoint(2025, time.March, 15),
+		Payout:          true,
+	})
+	if err != nil {
+		t.Fatalf("Settle failed: %v", err)
+	}
+
+	if output.Settlement.Action != generic.SettlementClawback {
+		t.Errorf("Expected clawback, got %s", output.Settlement.Action)
+	}
+	if !approxEqual(output.Settlement.ClawedBack, days(10)) {
+		t.Errorf("Expected 10 days clawed back, got %v", output.Settlement.ClawedBack)
+	}
+	if len(output.Transactions) != 1 || !approxEqual(output.Transactions[0].Delta, days(10)) {
+		t.Errorf("Expected single +10 transaction, got %+v", output.Transactions)
+	}
+}
+
+func TestTermination_ZeroAndUnlimitedProduceNoTransactions(t *testing.T) {
+	engine := &generic.TerminationEngine{}
+
+	output, err := engine.Settle(generic.TerminationInput{
+		EntityID:        "emp-1",
+		Policy:          terminationPolicy(),
+		Balance:         balance(8, 8),
+		TerminationDate: generic.NewTimePoint(2025, time.June, 30),
+		Payout:          true,
+	})
+	if err != nil {
+		t.Fatalf("Settle failed: %v", err)
+	}
+	if output.Settlement.Action != generic.SettlementNone || len(output.Transactions) != 0 {
+		t.Errorf("Zero balance: expected no action, got %s with %d txs", output.Settlement.Action, len(output.Transactions))
+	}
+
+	unlimited := terminationPolicy()
+	unlimited.IsUnlimited = true
+	output, err = engine.Settle(generic.TerminationInput{
+		EntityID:        "emp-1",
+		Policy:          unlimited,
+		Balance:         balance(0, 12),
+		TerminationDate: generic.NewTimePoint(2025, time.June, 30),
+		Payout:          true,
+	})
+	if err != nil {
+		t.Fatalf("Settle failed: %v", err)
+	}
+	if output.Settlement.Action != generic.SettlementNone || len(output.Transactions) != 0 {
+		t.Errorf("Unlimited: expected no action, got %s with %d txs", output.Settlement.Action, len(output.Transactions))
+	}
+}
@@ -1,6 +1,7 @@
 package generic
 
 import (
+	"context"
 	"time"
 )
 
@@ -84,11 +85,13 @@ func (tp TimePoint) String() string {
 
 // Holiday represents a company holiday that should not count against time-off.
 type Holiday struct {
-	ID        string
-	CompanyID string    // Empty string = global/default holidays
-	Date      TimePoint // The holiday date
-	Name      string    // e.g., "Christmas Day", "Independence Day"
-	Recurring bool      // true = same month/day every year
+	ID         string
+	CompanyID  string       // Empty string = global/default holidays
+	CalendarID string       // Named calendar (e.g. "uk"); empty = every calendar
+	Date       TimePoint    // The holiday date
+	Name       string       // e.g., "Christmas Day", "Independence Day"
+	Recurring  bool         // true = same month/day every year
+	Rule       *HolidayRule // Computed date each year (e.g. "4th thu nov"); implies Recurring
 }
 
 // HolidayCalendar provides holiday lookup functionality.
@@ -102,11 +105,32 @@ type HolidayCalendar interface {
 	GetHolidays(companyID string, year int) []Holiday
 }
 
-// DefaultHolidayCalendar is a no-op calendar for when holidays are disabled.
-type DefaultHolidayCalendar struct{}
+// DefaultHolidayCalendar is an in-memory calendar over a fixed list of
+// holidays, expanded per year by ExpandHolidays. The zero value has no
+// holidays, for when holidays are disabled.
+type DefaultHolidayCalendar struct {
+	Holidays []Holiday
+}
+
+func (d *DefaultHolidayCalendar) IsHoliday(companyID string, date TimePoint) bool {
+	day := date.Time.Format("2006-01-02")
+	for _, h := range d.GetHolidays(companyID, date.Year()) {
+		if h.Date.Time.Format("2006-01-02") == day {
+			return true
+		}
+	}
+	return false
+}
 
-func (d *DefaultHolidayCalendar) IsHoliday(companyID string, date TimePoint) bool { return false }
-func (d *DefaultHolidayCalendar) GetHolidays(companyID string, year int) []Holiday { return nil }
+func (d *DefaultHolidayCalendar) GetHolidays(companyID string, year int) []Holiday {
+	var matching []Holiday
+	for _, h := range d.Holidays {
+		if (h.CompanyID == "" || h.CompanyID == companyID) && h.CalendarID == "" {
+			matching = append(matching, h)
+		}
+	}
+	return ExpandHolidays(matching, year)
+}
 
 // IsWorkdayWithHolidays checks if a date is a working day, considering holidays.
 func (tp TimePoint) IsWorkdayWithHolidays(calendar HolidayCalendar, companyID string) bool {
@@ -119,6 +143,33 @@ func (tp TimePoint) IsWorkdayWithHolidays(calendar HolidayCalendar, companyID st
 	return true
 }
 
+// EntityHolidayCalendar resolves holidays for a specific entity.
+// Entities are assigned to named calendars (e.g. "us", "uk", "fr-paris")
+// with effective dates, so the calendar is picked per date: an employee
+// relocating mid-year gets the old office's holidays before the move and
+// the new office's after. Entities belong to a tenant, so lookups take
+// the context carrying it (see WithTenant).
+type EntityHolidayCalendar interface {
+	// IsEntityHoliday checks if a date is a holiday for the entity,
+	// using the calendar assigned to it on that date.
+	IsEntityHoliday(ctx context.Context, entityID EntityID, date TimePoint) bool
+
+	// GetEntityHolidays returns the entity's holidays in a given year,
+	// each taken from the calendar in effect on its date.
+	GetEntityHolidays(ctx context.Context, entityID EntityID, year int) []Holiday
+}
+
+// IsWorkdayFor checks if a date is a working day for an entity.
+func (tp TimePoint) IsWorkdayFor(ctx context.Context, calendar EntityHolidayCalendar, entityID EntityID) bool {
+	if tp.IsWeekend() {
+		return false
+	}
+	if calendar != nil && calendar.IsEntityHoliday(ctx, entityID, tp) {
+		return false
+	}
+	return true
+}
+
 // =============================================================================
 // TIME UTILITIES
 // =============================================================================
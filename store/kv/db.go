@@ -0,0 +1,598 @@
+/*
+db.go - Embedded key-value engine
+
+PURPOSE:
+  The ordered, durable key-value map store.go keeps its records in.
+
+LOG FORMAT:
+  "WARPKV1\n", then one record per committed Update:
+    [payload length u32][CRC-32C of payload u32][payload]
+  A payload is a sequence of operations:
+    [op u8][key length uvarint][key][value length uvarint][value]  (put)
+    [op u8][key length uvarint][key]                               (delete)
+  Record header integers are little-endian.
+
+LOCKING:
+  Open takes an exclusive flock on the log and fails if another process
+  holds it; closing the file releases it. Compaction locks the new log
+  before renaming it into place, so the lock is never dropped.
+
+RECOVERY:
+  Open replays the log into the index. Replay stops at the first record
+  that is short or fails its checksum (a write torn by a crash) and
+  truncates the file there, so only fully written updates survive.
+
+SEE ALSO:
+  - store/kv/store.go: Storage interfaces on top of this engine
+*/
+package kv
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"math/rand/v2"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+)
+
+// =============================================================================
+// EMBEDDED KEY-VALUE ENGINE
+// =============================================================================
+
+// logMagic starts every log file, so a wrong -db path fails loudly instead
+// of being read as an empty database.
+const logMagic = "WARPKV1\n"
+
+// Compaction rewrites the log once it is both larger than compactMinBytes
+// and more than compactRatio times the size of the live data.
+const (
+	compactMinBytes = 4 << 20
+	compactRatio    = 2
+)
+
+// Log record operations.
+const (
+	opPut    byte = 1
+	opDelete byte = 2
+)
+
+var (
+	// ErrClosed is returned by View and Update after Close.
+	ErrClosed = errors.New("kv: database is closed")
+
+	castagnoli = crc32.MakeTable(crc32.Castagnoli)
+)
+
+// DB is an ordered key-value store kept in memory and made durable by an
+// append-only log. Each Update is one log record, written and fsynced
+// before the update becomes visible, so a crash loses at most the update
+// being written; a torn record at the end of the log is dropped on Open.
+//
+// Updates are serialized; Views run concurrently with each other. Only one
+// process may open a log file at a time; Open fails while another holds
+// its lock.
+type DB struct {
+	mu     sync.RWMutex
+	path   string // "" for a database that is never written to disk
+	file   *os.File
+	index  *skiplist
+	size   int64 // Bytes in the log file
+	closed bool
+}
+
+// Open opens (or creates) the database logged at path. The path ":memory:"
+// opens a database that lives only in memory.
+func Open(path string) (*DB, error) {
+	db := &DB{index: newSkiplist()}
+	if path == ":memory:" {
+		return db, nil
+	}
+	db.path = path
+
+	file, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0o644)
+	if err != nil {
+		return nil, fmt.Errorf("kv: failed to open %s: %w", path, err)
+	}
+	db.file = file
+	if err := lockFile(file); err != nil {
+		file.Close()
+		return nil, fmt.Errorf("kv: failed to lock %s: %w", path, err)
+	}
+
+	if err := db.replay(); err != nil {
+		file.Close()
+		return nil, err
+	}
+	if db.needsCompaction() {
+		if err := db.compact(); err != nil {
+			db.file.Close()
+			return nil, err
+		}
+	}
+	return db, nil
+}
+
+// Close closes the log file, releasing its lock. Pending Views and Updates
+// finish first.
+func (db *DB) Close() error {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	if db.closed {
+		return nil
+	}
+	db.closed = true
+	if db.file == nil {
+		return nil
+	}
+	return db.file.Close()
+}
+
+// View runs fn with a read-only transaction.
+func (db *DB) View(fn func(tx *Tx) error) error {
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+
+	if db.closed {
+		return ErrClosed
+	}
+	return fn(&Tx{db: db})
+}
+
+// Update runs fn with a read-write transaction. If fn returns nil, its
+// writes are logged and applied atomically; otherwise they are discarded.
+func (db *DB) Update(fn func(tx *Tx) error) error {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	if db.closed {
+		return ErrClosed
+	}
+	tx := &Tx{db: db, writes: make(map[string]*[]byte)}
+	if err := fn(tx); err != nil {
+		return err
+	}
+	return db.commit(tx)
+}
+
+// commit logs a transaction's writes, then applies them to the index.
+func (db *DB) commit(tx *Tx) error {
+	if len(tx.writes) == 0 {
+		return nil
+	}
+	keys := tx.pendingKeys("", "")
+
+	if db.file != nil {
+		var payload []byte
+		for _, k := range keys {
+			payload = appendOp(payload, k, tx.writes[k])
+		}
+		if err := db.appendRecord(payload); err != nil {
+			return err
+		}
+	}
+
+	for _, k := range keys {
+		if v := tx.writes[k]; v != nil {
+			db.index.put(k, *v)
+		} else {
+			db.index.delete(k)
+		}
+	}
+
+	if db.needsCompaction() {
+		// The update is already durable; a failed compaction leaves the old
+		// log in place and is retried after the next update
+		db.compact()
+	}
+	return nil
+}
+
+// appendRecord writes one checksummed record and fsyncs it. On failure the
+// log is truncated back, so a partial record never precedes later ones.
+func (db *DB) appendRecord(payload []byte) error {
+	record := make([]byte, 8, 8+len(payload))
+	binary.LittleEndian.PutUint32(record[0:4], uint32(len(payload)))
+	binary.LittleEndian.PutUint32(record[4:8], crc32.Checksum(payload, castagnoli))
+	record = append(record, payload...)
+
+	if _, err := db.file.WriteAt(record, db.size); err != nil {
+		db.file.Truncate(db.size)
+		return fmt.Errorf("kv: failed to write log: %w", err)
+	}
+	if err := db.file.Sync(); err != nil {
+		db.file.Truncate(db.size)
+		return fmt.Errorf("kv: failed to sync log: %w", err)
+	}
+	db.size += int64(len(record))
+	return nil
+}
+
+func appendOp(buf []byte, key string, value *[]byte) []byte {
+	if value == nil {
+		buf = append(buf, opDelete)
+		buf = binary.AppendUvarint(buf, uint64(len(key)))
+		return append(buf, key...)
+	}
+	buf = append(buf, opPut)
+	buf = binary.AppendUvarint(buf, uint64(len(key)))
+	buf = append(buf, key...)
+	buf = binary.AppendUvarint(buf, uint64(len(*value)))
+	return append(buf, *value...)
+}
+
+// replay loads the log into the index. Reading stops at the first
+// incomplete or corrupt record, which is cut off: only the last record
+// can be torn, since every record is fsynced before the next is written.
+func (db *DB) replay() error {
+	info, err := db.file.Stat()
+	if err != nil {
+		return err
+	}
+	if info.Size() == 0 {
+		if _, err := db.file.WriteAt([]byte(logMagic), 0); err != nil {
+			return fmt.Errorf("kv: failed to initialize log: %w", err)
+		}
+		db.size = int64(len(logMagic))
+		return db.file.Sync()
+	}
+
+	r := bufio.NewReader(io.NewSectionReader(db.file, 0, info.Size()))
+	magic := make([]byte, len(logMagic))
+	if _, err := io.ReadFull(r, magic); err != nil || string(magic) != logMagic {
+		return fmt.Errorf("kv: %s is not a key-value store log", db.path)
+	}
+	offset := int64(len(logMagic))
+
+	header := make([]byte, 8)
+	for {
+		if _, err := io.ReadFull(r, header); err != nil {
+			break
+		}
+		length := binary.LittleEndian.Uint32(header[0:4])
+		sum := binary.LittleEndian.Uint32(header[4:8])
+		if int64(length) > info.Size()-offset {
+			break
+		}
+		payload := make([]byte, length)
+		if _, err := io.ReadFull(r, payload); err != nil {
+			break
+		}
+		if crc32.Checksum(payload, castagnoli) != sum || !db.applyPayload(payload) {
+			break
+		}
+		offset += 8 + int64(length)
+	}
+
+	db.size = offset
+	if offset < info.Size() {
+		if err := db.file.Truncate(offset); err != nil {
+			return fmt.Errorf("kv: failed to drop torn log record: %w", err)
+		}
+	}
+	return nil
+}
+
+// applyPayload applies a record's operations, or returns false if it does
+// not decode (nothing is applied then).
+func (db *DB) applyPayload(payload []byte) bool {
+	type op struct {
+		key   string
+		value []byte
+		put   bool
+	}
+	var ops []op
+	for len(payload) > 0 {
+		kind := payload[0]
+		payload = payload[1:]
+
+		n, size := binary.Uvarint(payload)
+		if size <= 0 || uint64(len(payload)-size) < n {
+			return false
+		}
+		key := string(payload[size : size+int(n)])
+		payload = payload[size+int(n):]
+
+		switch kind {
+		case opDelete:
+			ops = append(ops, op{key: key})
+		case opPut:
+			n, size := binary.Uvarint(payload)
+			if size <= 0 || uint64(len(payload)-size) < n {
+				return false
+			}
+			value := append([]byte(nil), payload[size:size+int(n)]...)
+			payload = payload[size+int(n):]
+			ops = append(ops, op{key: key, value: value, put: true})
+		default:
+			return false
+		}
+	}
+
+	for _, o := range ops {
+		if o.put {
+			db.index.put(o.key, o.value)
+		} else {
+			db.index.delete(o.key)
+		}
+	}
+	return true
+}
+
+func (db *DB) needsCompaction() bool {
+	return db.file != nil && db.size > compactMinBytes && db.size > compactRatio*db.index.bytes
+}
+
+// compact rewrites the log with only the live entries, then swaps it in
+// with a rename, so a crash leaves either the old or the new log.
+func (db *DB) compact() error {
+	tmpPath := db.path + ".compact"
+	tmp, err := os.OpenFile(tmpPath, os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0o644)
+	if err != nil {
+		return fmt.Errorf("kv: failed to compact: %w", err)
+	}
+	fail := func(err error) error {
+		tmp.Close()
+		os.Remove(tmpPath)
+		return fmt.Errorf("kv: failed to compact: %w", err)
+	}
+
+	w := bufio.NewWriter(tmp)
+	size := int64(len(logMagic))
+	w.WriteString(logMagic)
+
+	var payload []byte
+	flush := func() {
+		header := make([]byte, 8)
+		binary.LittleEndian.PutUint32(header[0:4], uint32(len(payload)))
+		binary.LittleEndian.PutUint32(header[4:8], crc32.Checksum(payload, castagnoli))
+		w.Write(header)
+		w.Write(payload)
+		size += 8 + int64(len(payload))
+		payload = payload[:0]
+	}
+	for n := db.index.seek(""); n != nil; n = n.next[0] {
+		value := n.value
+		payload = appendOp(payload, n.key, &value)
+		if len(payload) >= 1<<20 {
+			flush()
+		}
+	}
+	if len(payload) > 0 {
+		flush()
+	}
+
+	if err := w.Flush(); err != nil {
+		return fail(err)
+	}
+	if err := tmp.Sync(); err != nil {
+		return fail(err)
+	}
+	// The old log's lock goes when it is closed
+	if err := lockFile(tmp); err != nil {
+		return fail(err)
+	}
+	if err := os.Rename(tmpPath, db.path); err != nil {
+		return fail(err)
+	}
+	if dir, err := os.Open(filepath.Dir(db.path)); err == nil {
+		dir.Sync()
+		dir.Close()
+	}
+
+	db.file.Close()
+	db.file = tmp
+	db.size = size
+	return nil
+}
+
+// =============================================================================
+// TRANSACTIONS
+// =============================================================================
+
+// Tx reads the database as of its start plus its own writes. A Tx is only
+// valid inside the View or Update function it was passed to. Returned
+// values must not be modified.
+type Tx struct {
+	db     *DB
+	writes map[string]*[]byte // nil value = delete; nil map = read-only
+}
+
+// Get returns the value stored under key.
+func (tx *Tx) Get(key string) ([]byte, bool) {
+	if v, ok := tx.writes[key]; ok {
+		if v == nil {
+			return nil, false
+		}
+		return *v, true
+	}
+	return tx.db.index.get(key)
+}
+
+// Put stores value under key.
+func (tx *Tx) Put(key string, value []byte) {
+	if tx.writes == nil {
+		panic("kv: Put in a read-only transaction")
+	}
+	v := append([]byte(nil), value...)
+	tx.writes[key] = &v
+}
+
+// Delete removes key.
+func (tx *Tx) Delete(key string) {
+	if tx.writes == nil {
+		panic("kv: Delete in a read-only transaction")
+	}
+	tx.writes[key] = nil
+}
+
+// Scan calls fn for each key in [start, end) in ascending order, until fn
+// returns false. An empty end means no upper bound. Writes made while
+// scanning are not seen by the scan.
+func (tx *Tx) Scan(start, end string, fn func(key string, value []byte) bool) {
+	pending := tx.pendingKeys(start, end)
+	n := tx.db.index.seek(start)
+
+	for {
+		if n != nil && end != "" && n.key >= end {
+			n = nil
+		}
+		if n == nil && len(pending) == 0 {
+			return
+		}
+
+		var key string
+		var value []byte
+		switch {
+		case len(pending) > 0 && (n == nil || pending[0] <= n.key):
+			key = pending[0]
+			pending = pending[1:]
+			if n != nil && n.key == key {
+				n = n.next[0] // Overwritten by this transaction
+			}
+			v := tx.writes[key]
+			if v == nil {
+				continue // Deleted by this transaction
+			}
+			value = *v
+		default:
+			key, value = n.key, n.value
+			n = n.next[0]
+		}
+
+		if !fn(key, value) {
+			return
+		}
+	}
+}
+
+// ScanPrefix calls fn for each key starting with prefix, in order.
+func (tx *Tx) ScanPrefix(prefix string, fn func(key string, value []byte) bool) {
+	tx.Scan(prefix, prefixEnd(prefix), fn)
+}
+
+// pendingKeys returns this transaction's written keys in [start, end),
+// sorted.
+func (tx *Tx) pendingKeys(start, end string) []string {
+	var keys []string
+	for k := range tx.writes {
+		if k >= start && (end == "" || k < end) {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// prefixEnd returns the smallest key greater than every key with prefix,
+// or "" if there is none.
+func prefixEnd(prefix string) string {
+	b := []byte(prefix)
+	for i := len(b) - 1; i >= 0; i-- {
+		if b[i] < 0xff {
+			b[i]++
+			return string(b[:i+1])
+		}
+	}
+	return ""
+}
+
+// =============================================================================
+// SKIPLIST INDEX
+// =============================================================================
+
+const (
+	maxLevel    = 24
+	levelFactor = 4 // 1 in levelFactor nodes is promoted to the next level
+)
+
+// skiplist is the in-memory sorted index of every live key.
+type skiplist struct {
+	head  *node
+	level int
+	bytes int64 // Approximate size of the live data, for compaction
+}
+
+type node struct {
+	key   string
+	value []byte
+	next  []*node
+}
+
+func newSkiplist() *skiplist {
+	return &skiplist{head: &node{next: make([]*node, maxLevel)}, level: 1}
+}
+
+// findGE returns the first node with key >= key, filling prev with the
+// last node before it on every level.
+func (l *skiplist) findGE(key string, prev []*node) *node {
+	x := l.head
+	for i := l.level - 1; i >= 0; i-- {
+		for x.next[i] != nil && x.next[i].key < key {
+			x = x.next[i]
+		}
+		if prev != nil {
+			prev[i] = x
+		}
+	}
+	return x.next[0]
+}
+
+func (l *skiplist) seek(key string) *node {
+	return l.findGE(key, nil)
+}
+
+func (l *skiplist) get(key string) ([]byte, bool) {
+	if n := l.findGE(key, nil); n != nil && n.key == key {
+		return n.value, true
+	}
+	return nil, false
+}
+
+func (l *skiplist) put(key string, value []byte) {
+	prev := make([]*node, maxLevel)
+	if n := l.findGE(key, prev); n != nil && n.key == key {
+		l.bytes += int64(len(value) - len(n.value))
+		n.value = value
+		return
+	}
+
+	level := 1
+	for level < maxLevel && rand.IntN(levelFactor) == 0 {
+		level++
+	}
+	if level > l.level {
+		for i := l.level; i < level; i++ {
+			prev[i] = l.head
+		}
+		l.level = level
+	}
+
+	n := &node{key: key, value: value, next: make([]*node, level)}
+	for i := 0; i < level; i++ {
+		n.next[i] = prev[i].next[i]
+		prev[i].next[i] = n
+	}
+	l.bytes += int64(len(key) + len(value) + 8)
+}
+
+func (l *skiplist) delete(key string) {
+	prev := make([]*node, maxLevel)
+	n := l.findGE(key, prev)
+	if n == nil || n.key != key {
+		return
+	}
+	for i := 0; i < len(n.next); i++ {
+		prev[i].next[i] = n.next[i]
+	}
+	for l.level > 1 && l.head.next[l.level-1] == nil {
+		l.level--
+	}
+	l.bytes -= int64(len(key) + len(n.value) + 8)
+}
@@ -0,0 +1,242 @@
+package kv
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"testing"
+)
+
+func openTestDB(t *testing.T, path string) *DB {
+	t.Helper()
+	db, err := Open(path)
+	if err != nil {
+		t.Fatalf("Failed to open %s: %v", path, err)
+	}
+	return db
+}
+
+func putTestKey(t *testing.T, db *DB, key string, value []byte) {
+	t.Helper()
+	if err := db.Update(func(tx *Tx) error {
+		tx.Put(key, value)
+		return nil
+	}); err != nil {
+		t.Fatalf("Failed to put %s: %v", key, err)
+	}
+}
+
+func getTestKey(t *testing.T, db *DB, key string) ([]byte, bool) {
+	t.Helper()
+	var value []byte
+	var ok bool
+	db.View(func(tx *Tx) error {
+		value, ok = tx.Get(key)
+		return nil
+	})
+	return value, ok
+}
+
+func fileSize(t *testing.T, path string) int64 {
+	t.Helper()
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatalf("Failed to stat %s: %v", path, err)
+	}
+	return info.Size()
+}
+
+func TestDB_TornOrCorruptFinalRecordIsDropped(t *testing.T) {
+	// GIVEN: A log of three updates whose last record is cut short, or
+	//        has a flipped payload byte
+	// WHEN: Reopening it, then writing and reopening again
+	// THEN: The first two updates survive, the third is gone, the log is
+	//       truncated after the second, and the next write is readable
+
+	for name, damage := range map[string]func(t *testing.T, path string, end int64){
+		"torn": func(t *testing.T, path string, end int64) {
+			if err := os.Truncate(path, end-3); err != nil {
+				t.Fatalf("Failed to truncate: %v", err)
+			}
+		},
+		"corrupt": func(t *testing.T, path string, end int64) {
+			f, err := os.OpenFile(path, os.O_RDWR, 0)
+			if err != nil {
+				t.Fatalf("Failed to open log: %v", err)
+			}
+			defer f.Close()
+			b := make([]byte, 1)
+			f.ReadAt(b, end-1)
+			b[0] ^= 0xff
+			if _, err := f.WriteAt(b, end-1); err != nil {
+				t.Fatalf("Failed to corrupt log: %v", err)
+			}
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			path := t.TempDir() + "/db.kv"
+			db := openTestDB(t, path)
+			putTestKey(t, db, "a", []byte("1"))
+			putTestKey(t, db, "b", []byte("2"))
+			intact := fileSize(t, path)
+			putTestKey(t, db, "c", []byte("3"))
+			db.Close()
+
+			damage(t, path, fileSize(t, path))
+
+			db = openTestDB(t, path)
+			for key, want := range map[string]string{"a": "1", "b": "2"} {
+				if v, ok := getTestKey(t, db, key); !ok || string(v) != want {
+					t.Errorf("%s: got %q (%v), want %q", key, v, ok, want)
+				}
+			}
+			if _, ok := getTestKey(t, db, "c"); ok {
+				t.Error("The damaged update should be dropped")
+			}
+			if size := fileSize(t, path); size != intact {
+				t.Errorf("Expected the log truncated to %d bytes, got %d", intact, size)
+			}
+
+			putTestKey(t, db, "d", []byte("4"))
+			db.Close()
+			db = openTestDB(t, path)
+			defer db.Close()
+			if v, ok := getTestKey(t, db, "d"); !ok || string(v) != "4" {
+				t.Errorf("Write after recovery: got %q (%v)", v, ok)
+			}
+		})
+	}
+}
+
+func TestDB_CompactionShrinksLogAndKeepsWrites(t *testing.T) {
+	// GIVEN: A key overwritten until the log passes compactMinBytes
+	// WHEN: The update that crosses it commits, then more keys are written
+	//       and the database is reopened
+	// THEN: The log is rewritten to about the live data, no temporary
+	//       file is left, and the writes after compaction go to the new
+	//       log and survive the reopen
+
+	path := t.TempDir() + "/db.kv"
+	db := openTestDB(t, path)
+
+	value := bytes.Repeat([]byte("x"), 64<<10)
+	largest := int64(0)
+	for i := 0; ; i++ {
+		if i > 2*compactMinBytes/len(value) {
+			t.Fatalf("No compaction after %d overwrites, log is %d bytes", i, fileSize(t, path))
+		}
+		value[0] = byte(i)
+		putTestKey(t, db, "hot", value)
+		size := fileSize(t, path)
+		if size < largest {
+			break
+		}
+		largest = size
+	}
+	// largest is before the crossing update, which compacted on commit
+	if largest+int64(len(value)) <= compactMinBytes {
+		t.Errorf("Compacted at %d bytes, below compactMinBytes", largest)
+	}
+	if size := fileSize(t, path); size > 2*int64(len(value)) {
+		t.Errorf("Expected the log rewritten to the live data, got %d bytes", size)
+	}
+	if _, err := os.Stat(path + ".compact"); !os.IsNotExist(err) {
+		t.Errorf("Compaction left its temporary file: %v", err)
+	}
+
+	for i := 0; i < 10; i++ {
+		putTestKey(t, db, fmt.Sprintf("after-%d", i), []byte{byte(i)})
+	}
+	if db.size != fileSize(t, path) {
+		t.Errorf("Writes went elsewhere: %d bytes tracked, %d in %s", db.size, fileSize(t, path), path)
+	}
+	db.Close()
+
+	db = openTestDB(t, path)
+	defer db.Close()
+	if v, ok := getTestKey(t, db, "hot"); !ok || !bytes.Equal(v, value) {
+		t.Errorf("hot: got %d bytes (%v), want the last value", len(v), ok)
+	}
+	for i := 0; i < 10; i++ {
+		if v, ok := getTestKey(t, db, fmt.Sprintf("after-%d", i)); !ok || !bytes.Equal(v, []byte{byte(i)}) {
+			t.Errorf("after-%d: got %v (%v)", i, v, ok)
+		}
+	}
+}
+
+func TestDB_CrashDuringCompactionKeepsOldLog(t *testing.T) {
+	// GIVEN: A database whose compaction crashed before the rename,
+	//        leaving a partial temporary file
+	// WHEN: Reopening it and compacting again
+	// THEN: The old log is used as is, the new compaction replaces the
+	//       leftover, and the compacted log reopens with every key
+
+	path := t.TempDir() + "/db.kv"
+	db := openTestDB(t, path)
+	for i := 0; i < 5; i++ {
+		putTestKey(t, db, "key", []byte{byte(i)})
+	}
+	putTestKey(t, db, "other", []byte("kept"))
+	db.Close()
+
+	if err := os.WriteFile(path+".compact", []byte(logMagic+"\x10\x00"), 0o644); err != nil {
+		t.Fatalf("Failed to write partial compaction: %v", err)
+	}
+
+	db = openTestDB(t, path)
+	if v, ok := getTestKey(t, db, "key"); !ok || !bytes.Equal(v, []byte{4}) {
+		t.Errorf("key: got %v (%v), want [4]", v, ok)
+	}
+
+	before := fileSize(t, path)
+	if err := db.compact(); err != nil {
+		t.Fatalf("Failed to compact: %v", err)
+	}
+	if size := fileSize(t, path); size >= before {
+		t.Errorf("Expected the log to shrink from %d bytes, got %d", before, size)
+	}
+	if _, err := os.Stat(path + ".compact"); !os.IsNotExist(err) {
+		t.Errorf("Compaction left its temporary file: %v", err)
+	}
+	putTestKey(t, db, "after", []byte("compaction"))
+	db.Close()
+
+	db = openTestDB(t, path)
+	defer db.Close()
+	for key, want := range map[string][]byte{"key": {4}, "other": []byte("kept"), "after": []byte("compaction")} {
+		if v, ok := getTestKey(t, db, key); !ok || !bytes.Equal(v, want) {
+			t.Errorf("%s: got %q (%v), want %q", key, v, ok, want)
+		}
+	}
+}
+
+func TestDB_SecondOpenOfALogFails(t *testing.T) {
+	// GIVEN: An open database
+	// WHEN: The same log is opened again, before and after a compaction,
+	//       and after the first is closed
+	// THEN: The second Open fails while the first holds the log, also on
+	//       the compacted file, and succeeds once it is closed
+
+	path := t.TempDir() + "/db.kv"
+	db := openTestDB(t, path)
+	putTestKey(t, db, "key", []byte("value"))
+
+	if other, err := Open(path); err == nil {
+		other.Close()
+		t.Fatal("Expected a second Open to fail while the log is open")
+	}
+	if err := db.compact(); err != nil {
+		t.Fatalf("Failed to compact: %v", err)
+	}
+	if other, err := Open(path); err == nil {
+		other.Close()
+		t.Fatal("Expected a second Open to fail after compaction")
+	}
+
+	db.Close()
+	db = openTestDB(t, path)
+	defer db.Close()
+	if v, ok := getTestKey(t, db, "key"); !ok || string(v) != "value" {
+		t.Errorf("key: got %q (%v)", v, ok)
+	}
+}
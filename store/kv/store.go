@@ -0,0 +1,2176 @@
+/*
+Package kv provides a pure-Go embedded key-value implementation of the
+storage interfaces.
+
+PURPOSE:
+  An alternative to store/sqlite that needs no CGO and no database server:
+  the whole store is one append-only log file, loaded into an ordered
+  in-memory index on open. Suited to single-node deployments and to
+  builds with CGO_ENABLED=0 (cmd/server -store=kv).
+
+INTERFACES IMPLEMENTED:
+  generic.Store:                 Transaction persistence
+  generic.EntityStore:           Entity-wide queries (day uniqueness)
+  generic.TxStore:               Atomic multi-append (WithTx)
+  generic.HolidayCalendar:       Company holidays
+  generic.EntityHolidayCalendar: Holidays per employee calendar
+  generic.AssignmentStore:       Policy-to-entity mappings (Assignments)
+  generic.SnapshotStore:         Balance snapshots (Snapshots)
+  Plus every record the API stores (same methods and semantics as
+  store/sqlite; records from store/record).
+
+ENGINE (db.go):
+  - Ordered index (skiplist) of every key, kept in memory
+  - Each Update is one checksummed log record, fsynced before it is
+    applied; a torn record at the end of the log is dropped on open
+  - The log is compacted (rewritten with live keys only, then renamed
+    over the old one) once it is more than twice the live data
+  - Updates are serialized, reads run concurrently
+
+KEY LAYOUT:
+  Keys are tuples of components, each escaped and terminated so that a
+  tuple's encoding is a prefix of every longer tuple starting with it,
+  and tuples sort component by component. Times are fixed-width UTC
+  (timeKey) so they sort chronologically.
+
+  t/<tenant>/transactions/<id>                        Transaction row
+  t/<tenant>/tx-policy/<entity>/<policy>/<time>/<seq>  -> id  (Load, LoadRange)
+  t/<tenant>/tx-entity/<entity>/<time>/<seq>           -> id  (LoadByEntity)
+  t/<tenant>/tx-day/<entity>/<resource>/<date>         -> id  (one day off per day)
+  t/<tenant>/tx-idempotency/<key>                      -> id
+  t/<tenant>/tx-reversal/<reference>/<id>                     (IsTransactionReversed)
+  t/<tenant>/tx-created/<inverted seq>                 -> id  (newest first)
+  t/<tenant>/assignments-by-entity/<entity>/<id>
+  t/<tenant>/assignments-by-policy/<policy>/<id>
+  t/<tenant>/snapshots/<entity>/<policy>/<start>/<end>
+  t/<tenant>/reconciliation-runs/<entity>/<policy>/<start>/<end>
+  t/<tenant>/reconciliation-batches/<id>
+  ...
+
+  Entity and entity+policy scans are single range scans, ordered by
+  effective time, with no per-row filtering. Every tenant's data lives
+  under its own t/<tenant>/ prefix. Calendar feed tokens, tenants and
+  leases are global (g/...), as in store/sqlite.
+
+DURABILITY:
+  Every committed write is on disk. There is no cross-process locking:
+  open a log file from one process only.
+
+USAGE:
+  store, err := kv.New("./data/warp.kv")
+  if err != nil {
+      log.Fatal(err)
+  }
+  defer store.Close()
+
+SEE ALSO:
+  - store/sqlite/sqlite.go: SQLite implementation (same semantics)
+  - store/record/record.go: Record types
+  - generic/store.go: Interface definitions
+*/
+package kv
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/warp/resource-engine/generic"
+	"github.com/warp/resource-engine/store/record"
+)
+
+// Compile-time checks for the generic interfaces Store provides.
+var (
+	_ generic.EntityHolidayCalendar = (*Store)(nil)
+	_ generic.EntityStore           = (*Store)(nil)
+	_ generic.HolidayCalendar       = (*Store)(nil)
+	_ generic.TxStore               = (*Store)(nil)
+)
+
+// Store implements all storage interfaces on the embedded key-value engine.
+type Store struct {
+	db *DB
+}
+
+// New opens the store logged at path, creating it if needed.
+// Use ":memory:" for a store that is not written to disk.
+func New(path string) (*Store, error) {
+	db, err := Open(path)
+	if err != nil {
+		return nil, err
+	}
+	return &Store{db: db}, nil
+}
+
+// Close closes the log file.
+func (s *Store) Close() error {
+	return s.db.Close()
+}
+
+// =============================================================================
+// KEYS AND ENCODING
+// =============================================================================
+
+// Tables (key namespaces). Tenant-scoped unless noted.
+const (
+	tblTenants        = "tenants" // Global
+	tblSequence       = "sequence"
+	tblTransactions   = "transactions"
+	tblTxByPolicy     = "tx-policy"
+	tblTxByEntity     = "tx-entity"
+	tblTxDays         = "tx-day"
+	tblTxIdempotency  = "tx-idempotency"
+	tblTxReversals    = "tx-reversal"
+	tblTxCreated      = "tx-created"
+	tblPolicies       = "policies"
+	tblEmployees      = "employees"
+	tblAssignments    = "assignments"
+	tblAssignByEntity = "assignments-by-entity"
+	tblAssignByPolicy = "assignments-by-policy"
+	tblSnapshots      = "snapshots"
+	tblPayRates       = "pay-rates"
+	tblFeeds          = "calendar-feeds" // Global, by token
+	tblFeedsByOwner   = "calendar-feeds-by-owner"
+	tblHolidays       = "holidays"
+	tblHolidayUnique  = "holidays-unique"
+	tblCalendars      = "holiday-calendars"
+	tblEmpCalendars   = "employee-holiday-calendars"
+	tblRequests       = "requests"
+	tblRequestsEntity = "requests-by-entity"
+	tblRequestsQueue  = "requests-pending"
+	tblRuns           = "reconciliation-runs"
+	tblBatches        = "reconciliation-batches"
+	tblOutbox         = "outbox-events"
+	tblOutboxQueue    = "outbox-undispatched"
+	tblEndpoints      = "webhook-endpoints"
+	tblDeliveries     = "webhook-deliveries"
+	tblDeliveryEvents = "webhook-deliveries-by-event"
+	tblDeliveriesDue  = "webhook-deliveries-due"
+	tblIdempotency    = "idempotency-keys"
+	tblIdemCreated    = "idempotency-keys-by-created" // Global, for expiry
+	tblLeases         = "leases"                      // Global
+)
+
+// resetTables are the tables Reset clears, as in store/sqlite.
+var resetTables = []string{
+	tblTransactions, tblTxByPolicy, tblTxByEntity, tblTxDays, tblTxIdempotency, tblTxReversals, tblTxCreated,
+	tblOutbox, tblOutboxQueue, tblDeliveries, tblDeliveryEvents, tblDeliveriesDue,
+	tblSnapshots, tblAssignments, tblAssignByEntity, tblAssignByPolicy, tblPayRates,
+	tblEmpCalendars, tblEmployees, tblPolicies,
+}
+
+// key encodes a tuple. Each component has NUL escaped as 00 FF and ends
+// with 00 01, so no component's encoding is a prefix of another's.
+func key(parts ...string) string {
+	var b strings.Builder
+	for _, p := range parts {
+		b.WriteString(strings.ReplaceAll(p, "\x00", "\x00\xff"))
+		b.WriteString("\x00\x01")
+	}
+	return b.String()
+}
+
+// tkey encodes a key in a tenant's table.
+func tkey(tenant, table string, parts ...string) string {
+	return key("t", tenant, table) + key(parts...)
+}
+
+// gkey encodes a key in a global table.
+func gkey(table string, parts ...string) string {
+	return key("g", table) + key(parts...)
+}
+
+// timeKey formats a time so that keys sort chronologically.
+func timeKey(t time.Time) string {
+	return t.UTC().Format("2006-01-02T15:04:05.000000000Z")
+}
+
+// tenantOf returns the tenant every key in this file is scoped to.
+func tenantOf(ctx context.Context) string {
+	return string(generic.TenantFrom(ctx))
+}
+
+func getJSON(tx *Tx, k string, v any) (bool, error) {
+	data, ok := tx.Get(k)
+	if !ok {
+		return false, nil
+	}
+	if err := json.Unmarshal(data, v); err != nil {
+		return false, fmt.Errorf("kv: corrupt value: %w", err)
+	}
+	return true, nil
+}
+
+func putJSON(tx *Tx, k string, v any) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	tx.Put(k, data)
+	return nil
+}
+
+// scanJSON decodes every value under prefix, in key order.
+func scanJSON[T any](tx *Tx, prefix string) ([]T, error) {
+	var rows []T
+	var err error
+	tx.ScanPrefix(prefix, func(_ string, data []byte) bool {
+		var row T
+		if err = json.Unmarshal(data, &row); err != nil {
+			err = fmt.Errorf("kv: corrupt value: %w", err)
+			return false
+		}
+		rows = append(rows, row)
+		return true
+	})
+	return rows, err
+}
+
+// scanKeys returns the keys under prefix.
+func scanKeys(tx *Tx, prefix string) []string {
+	var keys []string
+	tx.ScanPrefix(prefix, func(k string, _ []byte) bool {
+		keys = append(keys, k)
+		return true
+	})
+	return keys
+}
+
+// =============================================================================
+// TENANTS
+// =============================================================================
+
+// SaveTenant creates or renames a tenant.
+func (s *Store) SaveTenant(ctx context.Context, t record.Tenant) error {
+	return s.db.Update(func(tx *Tx) error {
+		k := gkey(tblTenants, string(t.ID))
+		var existing record.Tenant
+		if ok, err := getJSON(tx, k, &existing); err != nil {
+			return err
+		} else if ok {
+			t.CreatedAt = existing.CreatedAt
+		} else {
+			t.CreatedAt = now()
+		}
+		return putJSON(tx, k, t)
+	})
+}
+
+// GetTenant returns a tenant by ID, or nil.
+func (s *Store) GetTenant(ctx context.Context, id generic.TenantID) (*record.Tenant, error) {
+	var t record.Tenant
+	var found bool
+	err := s.db.View(func(tx *Tx) (err error) {
+		found, err = getJSON(tx, gkey(tblTenants, string(id)), &t)
+		return err
+	})
+	if err != nil || !found {
+		return nil, err
+	}
+	return &t, nil
+}
+
+// ListTenants returns all tenants, not including the default tenant.
+func (s *Store) ListTenants(ctx context.Context) ([]record.Tenant, error) {
+	var tenants []record.Tenant
+	err := s.db.View(func(tx *Tx) (err error) {
+		tenants, err = scanJSON[record.Tenant](tx, gkey(tblTenants))
+		return err
+	})
+	return tenants, err
+}
+
+// now returns the current time at the precision store/sqlite keeps.
+func now() time.Time {
+	return time.Now().UTC().Truncate(time.Second)
+}
+
+// =============================================================================
+// TRANSACTION STORE (generic.Store interface)
+// =============================================================================
+
+// txRow is a stored ledger transaction.
+type txRow struct {
+	ID             string            `json:"id"`
+	EntityID       string            `json:"entity_id"`
+	PolicyID       string            `json:"policy_id"`
+	ResourceType   string            `json:"resource_type"`
+	EffectiveAt    time.Time         `json:"effective_at"`
+	Delta          string            `json:"delta"`
+	Unit           string            `json:"unit"`
+	Type           string            `json:"type"`
+	ReferenceID    string            `json:"reference_id,omitempty"`
+	Reason         string            `json:"reason,omitempty"`
+	IdempotencyKey string            `json:"idempotency_key,omitempty"`
+	Metadata       map[string]string `json:"metadata,omitempty"`
+	CreatedBy      string            `json:"created_by,omitempty"`
+	CreatedByType  string            `json:"created_by_type,omitempty"`
+	CreatedAt      time.Time         `json:"created_at"`
+}
+
+func (r txRow) transaction() generic.Transaction {
+	return generic.Transaction{
+		ID:             generic.TransactionID(r.ID),
+		EntityID:       generic.EntityID(r.EntityID),
+		PolicyID:       generic.PolicyID(r.PolicyID),
+		ResourceType:   generic.GetOrCreateResource(r.ResourceType),
+		EffectiveAt:    generic.TimePoint{Time: r.EffectiveAt},
+		Delta:          generic.Amount{Value: generic.MustParseDecimal(r.Delta), Unit: generic.Unit(r.Unit)},
+		Type:           generic.TransactionType(r.Type),
+		ReferenceID:    r.ReferenceID,
+		Reason:         r.Reason,
+		IdempotencyKey: r.IdempotencyKey,
+		Metadata:       r.Metadata,
+		CreatedBy:      r.CreatedBy,
+		CreatedByType:  r.CreatedByType,
+		CreatedAt:      generic.TimePoint{Time: r.CreatedAt},
+	}
+}
+
+// dayKey is the calendar day (UTC) a consumption occupies.
+func dayKey(t time.Time) string {
+	return t.UTC().Format("2006-01-02")
+}
+
+func occupiesDay(t generic.TransactionType) bool {
+	return t == generic.TxConsumption || t == generic.TxPending
+}
+
+// Append adds a transaction to the ledger.
+func (s *Store) Append(ctx context.Context, tx generic.Transaction) error {
+	return s.AppendBatchWithEvent(ctx, []generic.Transaction{tx}, generic.EventTransactionsPosted, nil)
+}
+
+// AppendBatch adds multiple transactions atomically.
+func (s *Store) AppendBatch(ctx context.Context, txs []generic.Transaction) error {
+	if len(txs) == 0 {
+		return nil
+	}
+	return s.AppendBatchWithEvent(ctx, txs, generic.EventTransactionsPosted, nil)
+}
+
+// AppendBatchWithEvent adds transactions atomically together with one
+// outbox event describing them: the event exists if and only if the
+// transactions were committed. An empty batch records just the event.
+func (s *Store) AppendBatchWithEvent(ctx context.Context, txs []generic.Transaction, eventType generic.EventType, data map[string]any) error {
+	// Check for duplicate idempotency keys within the batch first
+	idempotencyKeys := make(map[string]bool)
+	for _, tx := range txs {
+		if tx.IdempotencyKey != "" {
+			if idempotencyKeys[tx.IdempotencyKey] {
+				return generic.ErrDuplicateIdempotencyKey
+			}
+			idempotencyKeys[tx.IdempotencyKey] = true
+		}
+	}
+
+	return s.db.Update(func(kvTx *Tx) error {
+		for _, tx := range txs {
+			if err := appendTx(ctx, kvTx, tx); err != nil {
+				return err
+			}
+		}
+		return insertOutboxEvent(ctx, kvTx, eventType, txs, data)
+	})
+}
+
+// appendTx writes a transaction and its index entries, enforcing the same
+// uniqueness rules as store/sqlite's unique indexes.
+func appendTx(ctx context.Context, kvTx *Tx, tx generic.Transaction) error {
+	tx.Stamp(ctx)
+	tenant := tenantOf(ctx)
+	id := string(tx.ID)
+	entity := string(tx.EntityID)
+	resource := tx.ResourceType.ResourceID()
+
+	if _, ok := kvTx.Get(tkey(tenant, tblTransactions, id)); ok {
+		return generic.ErrDuplicateIdempotencyKey
+	}
+	if tx.IdempotencyKey != "" {
+		if _, ok := kvTx.Get(tkey(tenant, tblTxIdempotency, tx.IdempotencyKey)); ok {
+			return generic.ErrDuplicateIdempotencyKey
+		}
+	}
+	day := tkey(tenant, tblTxDays, entity, resource, dayKey(tx.EffectiveAt.Time))
+	if occupiesDay(tx.Type) {
+		if _, ok := kvTx.Get(day); ok {
+			return generic.ErrDuplicateDayConsumption
+		}
+	}
+
+	seq := nextSequence(kvTx, tenant)
+	row := txRow{
+		ID:             id,
+		EntityID:       entity,
+		PolicyID:       string(tx.PolicyID),
+		ResourceType:   resource,
+		EffectiveAt:    tx.EffectiveAt.Time.Truncate(time.Second), // As store/sqlite keeps it
+		Delta:          tx.Delta.Value.String(),
+		Unit:           string(tx.Delta.Unit),
+		Type:           string(tx.Type),
+		ReferenceID:    tx.ReferenceID,
+		Reason:         tx.Reason,
+		IdempotencyKey: tx.IdempotencyKey,
+		Metadata:       tx.Metadata,
+		CreatedBy:      tx.CreatedBy,
+		CreatedByType:  tx.CreatedByType,
+		CreatedAt:      now(),
+	}
+	if err := putJSON(kvTx, tkey(tenant, tblTransactions, id), row); err != nil {
+		return fmt.Errorf("failed to append transaction: %w", err)
+	}
+
+	at := timeKey(tx.EffectiveAt.Time)
+	order := fmt.Sprintf("%016x", seq)
+	kvTx.Put(tkey(tenant, tblTxByPolicy, entity, row.PolicyID, at, order), []byte(id))
+	kvTx.Put(tkey(tenant, tblTxByEntity, entity, at, order), []byte(id))
+	kvTx.Put(tkey(tenant, tblTxCreated, fmt.Sprintf("%016x", math.MaxUint64-seq)), []byte(id))
+	if tx.IdempotencyKey != "" {
+		kvTx.Put(tkey(tenant, tblTxIdempotency, tx.IdempotencyKey), []byte(id))
+	}
+	if occupiesDay(tx.Type) {
+		kvTx.Put(day, []byte(id))
+	}
+	if tx.Type == generic.TxReversal && tx.ReferenceID != "" {
+		kvTx.Put(tkey(tenant, tblTxReversals, tx.ReferenceID, id), nil)
+	}
+	return nil
+}
+
+// nextSequence numbers a tenant's transactions in append order.
+func nextSequence(kvTx *Tx, tenant string) uint64 {
+	k := tkey(tenant, tblSequence)
+	var seq uint64
+	if data, ok := kvTx.Get(k); ok {
+		fmt.Sscanf(string(data), "%d", &seq)
+	}
+	seq++
+	kvTx.Put(k, []byte(fmt.Sprint(seq)))
+	return seq
+}
+
+// Load returns all transactions for an entity+policy.
+func (s *Store) Load(ctx context.Context, entityID generic.EntityID, policyID generic.PolicyID) ([]generic.Transaction, error) {
+	var txs []generic.Transaction
+	err := s.db.View(func(kvTx *Tx) (err error) {
+		txs, err = load(ctx, kvTx, entityID, policyID)
+		return err
+	})
+	return txs, err
+}
+
+func load(ctx context.Context, kvTx *Tx, entityID generic.EntityID, policyID generic.PolicyID) ([]generic.Transaction, error) {
+	prefix := tkey(tenantOf(ctx), tblTxByPolicy, string(entityID), string(policyID))
+	return loadIndexed(ctx, kvTx, prefix, prefixEnd(prefix))
+}
+
+// LoadRange returns transactions in a time range.
+func (s *Store) LoadRange(ctx context.Context, entityID generic.EntityID, policyID generic.PolicyID, from, to generic.TimePoint) ([]generic.Transaction, error) {
+	var txs []generic.Transaction
+	err := s.db.View(func(kvTx *Tx) (err error) {
+		txs, err = loadRange(ctx, kvTx, entityID, policyID, from, to)
+		return err
+	})
+	return txs, err
+}
+
+func loadRange(ctx context.Context, kvTx *Tx, entityID generic.EntityID, policyID generic.PolicyID, from, to generic.TimePoint) ([]generic.Transaction, error) {
+	tenant := tenantOf(ctx)
+	start := tkey(tenant, tblTxByPolicy, string(entityID), string(policyID), timeKey(from.Time))
+	end := prefixEnd(tkey(tenant, tblTxByPolicy, string(entityID), string(policyID), timeKey(to.Time)))
+	return loadIndexed(ctx, kvTx, start, end)
+}
+
+// LoadRangeKnownAt returns transactions in a time range that were created
+// at or before knownAt.
+func (s *Store) LoadRangeKnownAt(ctx context.Context, entityID generic.EntityID, policyID generic.PolicyID, from, to generic.TimePoint, knownAt time.Time) ([]generic.Transaction, error) {
+	var txs []generic.Transaction
+	err := s.db.View(func(kvTx *Tx) (err error) {
+		txs, err = loadRange(ctx, kvTx, entityID, policyID, from, to)
+		return err
+	})
+	return generic.KnownBy(txs, knownAt), err
+}
+
+// loadIndexed loads the transactions an index range points to, in index
+// order.
+func loadIndexed(ctx context.Context, kvTx *Tx, start, end string) ([]generic.Transaction, error) {
+	tenant := tenantOf(ctx)
+	var txs []generic.Transaction
+	var err error
+	kvTx.Scan(start, end, func(_ string, id []byte) bool {
+		var row txRow
+		var ok bool
+		if ok, err = getJSON(kvTx, tkey(tenant, tblTransactions, string(id)), &row); err != nil {
+			return false
+		}
+		if ok {
+			txs = append(txs, row.transaction())
+		}
+		return true
+	})
+	return txs, err
+}
+
+// Exists checks if an idempotency key exists.
+func (s *Store) Exists(ctx context.Context, idempotencyKey string) (bool, error) {
+	var found bool
+	err := s.db.View(func(kvTx *Tx) error {
+		_, found = kvTx.Get(tkey(tenantOf(ctx), tblTxIdempotency, idempotencyKey))
+		return nil
+	})
+	return found, err
+}
+
+// =============================================================================
+// TRANSACTIONAL STORE (generic.TxStore interface)
+// =============================================================================
+
+// WithTx executes a function within one atomic update: everything fn
+// appends is committed together, or not at all if fn returns an error.
+func (s *Store) WithTx(ctx context.Context, fn func(store generic.Store) error) error {
+	return s.db.Update(func(kvTx *Tx) error {
+		return fn(&txStore{tx: kvTx})
+	})
+}
+
+// txStore is the generic.Store handed to WithTx functions. Reads see the
+// transaction's own appends.
+type txStore struct {
+	tx *Tx
+}
+
+func (ts *txStore) Append(ctx context.Context, tx generic.Transaction) error {
+	return ts.AppendBatch(ctx, []generic.Transaction{tx})
+}
+
+func (ts *txStore) AppendBatch(ctx context.Context, txs []generic.Transaction) error {
+	for _, tx := range txs {
+		if err := appendTx(ctx, ts.tx, tx); err != nil {
+			return err
+		}
+	}
+	if len(txs) == 0 {
+		return nil
+	}
+	return insertOutboxEvent(ctx, ts.tx, generic.EventTransactionsPosted, txs, nil)
+}
+
+func (ts *txStore) Load(ctx context.Context, entityID generic.EntityID, policyID generic.PolicyID) ([]generic.Transaction, error) {
+	return load(ctx, ts.tx, entityID, policyID)
+}
+
+func (ts *txStore) LoadRange(ctx context.Context, entityID generic.EntityID, policyID generic.PolicyID, from, to generic.TimePoint) ([]generic.Transaction, error) {
+	return loadRange(ctx, ts.tx, entityID, policyID, from, to)
+}
+
+func (ts *txStore) LoadRangeKnownAt(ctx context.Context, entityID generic.EntityID, policyID generic.PolicyID, from, to generic.TimePoint, knownAt time.Time) ([]generic.Transaction, error) {
+	txs, err := loadRange(ctx, ts.tx, entityID, policyID, from, to)
+	return generic.KnownBy(txs, knownAt), err
+}
+
+func (ts *txStore) Exists(ctx context.Context, idempotencyKey string) (bool, error) {
+	_, found := ts.tx.Get(tkey(tenantOf(ctx), tblTxIdempotency, idempotencyKey))
+	return found, nil
+}
+
+// =============================================================================
+// LEDGER QUERIES
+// =============================================================================
+
+// Reset clears the tenant's data (for testing/demo). Other tenants are
+// untouched.
+func (s *Store) Reset(ctx context.Context) error {
+	tenant := tenantOf(ctx)
+	return s.db.Update(func(kvTx *Tx) error {
+		for _, table := range resetTables {
+			for _, k := range scanKeys(kvTx, tkey(tenant, table)) {
+				kvTx.Delete(k)
+			}
+		}
+		feeds, err := scanJSON[record.CalendarFeed](kvTx, tkey(tenant, tblFeedsByOwner))
+		if err != nil {
+			return err
+		}
+		for _, f := range feeds {
+			kvTx.Delete(gkey(tblFeeds, f.Token))
+			kvTx.Delete(tkey(tenant, tblFeedsByOwner, f.OwnerID, f.Token))
+		}
+		keys, err := scanJSON[record.IdempotencyRecord](kvTx, tkey(tenant, tblIdempotency))
+		if err != nil {
+			return err
+		}
+		for _, rec := range keys {
+			deleteIdempotencyKey(kvTx, tenant, rec)
+		}
+		return nil
+	})
+}
+
+// GetAllTransactions returns the most recently appended transactions
+// (for admin view), newest first.
+func (s *Store) GetAllTransactions(ctx context.Context, limit int) ([]generic.Transaction, error) {
+	var txs []generic.Transaction
+	err := s.db.View(func(kvTx *Tx) error {
+		prefix := tkey(tenantOf(ctx), tblTxCreated)
+		var ids []string
+		kvTx.ScanPrefix(prefix, func(_ string, id []byte) bool {
+			ids = append(ids, string(id))
+			return len(ids) < limit
+		})
+		for _, id := range ids {
+			tx, err := getTransaction(ctx, kvTx, id)
+			if err != nil {
+				return err
+			}
+			if tx != nil {
+				txs = append(txs, *tx)
+			}
+		}
+		return nil
+	})
+	return txs, err
+}
+
+// GetTransaction returns a specific transaction by ID.
+func (s *Store) GetTransaction(ctx context.Context, id string) (*generic.Transaction, error) {
+	var tx *generic.Transaction
+	err := s.db.View(func(kvTx *Tx) (err error) {
+		tx, err = getTransaction(ctx, kvTx, id)
+		return err
+	})
+	return tx, err
+}
+
+func getTransaction(ctx context.Context, kvTx *Tx, id string) (*generic.Transaction, error) {
+	var row txRow
+	ok, err := getJSON(kvTx, tkey(tenantOf(ctx), tblTransactions, id), &row)
+	if err != nil || !ok {
+		return nil, err
+	}
+	tx := row.transaction()
+	return &tx, nil
+}
+
+// IsTransactionReversed checks if a transaction has already been reversed.
+func (s *Store) IsTransactionReversed(ctx context.Context, txID string) (bool, error) {
+	var reversed bool
+	err := s.db.View(func(kvTx *Tx) error {
+		kvTx.ScanPrefix(tkey(tenantOf(ctx), tblTxReversals, txID), func(string, []byte) bool {
+			reversed = true
+			return false
+		})
+		return nil
+	})
+	return reversed, err
+}
+
+// =============================================================================
+// ENTITY-WIDE QUERIES (generic.EntityStore interface)
+// =============================================================================
+
+// LoadByEntity returns all transactions for an entity across ALL policies.
+// This is needed for time-off uniqueness validation.
+func (s *Store) LoadByEntity(ctx context.Context, entityID generic.EntityID, from, to generic.TimePoint) ([]generic.Transaction, error) {
+	var txs []generic.Transaction
+	err := s.db.View(func(kvTx *Tx) (err error) {
+		txs, err = loadByEntity(ctx, kvTx, entityID, from, to)
+		return err
+	})
+	return txs, err
+}
+
+func loadByEntity(ctx context.Context, kvTx *Tx, entityID generic.EntityID, from, to generic.TimePoint) ([]generic.Transaction, error) {
+	tenant := tenantOf(ctx)
+	start := tkey(tenant, tblTxByEntity, string(entityID), timeKey(from.Time))
+	end := prefixEnd(tkey(tenant, tblTxByEntity, string(entityID), timeKey(to.Time)))
+	return loadIndexed(ctx, kvTx, start, end)
+}
+
+// LoadByEntityAndResourceType returns transactions for an entity filtered by resource type.
+func (s *Store) LoadByEntityAndResourceType(ctx context.Context, entityID generic.EntityID, resourceType generic.ResourceType, from, to generic.TimePoint) ([]generic.Transaction, error) {
+	txs, err := s.LoadByEntity(ctx, entityID, from, to)
+	if err != nil {
+		return nil, err
+	}
+	var result []generic.Transaction
+	for _, tx := range txs {
+		if tx.ResourceType.ResourceID() == resourceType.ResourceID() {
+			result = append(result, tx)
+		}
+	}
+	return result, nil
+}
+
+// GetConsumedDays returns all days (UTC dates) that have consumption or
+// pending transactions.
+func (s *Store) GetConsumedDays(ctx context.Context, entityID generic.EntityID, resourceType generic.ResourceType, from, to generic.TimePoint) ([]generic.TimePoint, error) {
+	txs, err := s.LoadByEntityAndResourceType(ctx, entityID, resourceType, from, to)
+	if err != nil {
+		return nil, err
+	}
+
+	var days []generic.TimePoint
+	last := ""
+	for _, tx := range txs {
+		day := dayKey(tx.EffectiveAt.Time)
+		if !occupiesDay(tx.Type) || day == last {
+			continue
+		}
+		last = day
+		t, _ := time.Parse("2006-01-02", day)
+		days = append(days, generic.TimePoint{Time: t})
+	}
+	return days, nil
+}
+
+// IsDayConsumed checks if a specific day already has a consumption transaction.
+// Returns the existing transaction ID if found.
+func (s *Store) IsDayConsumed(ctx context.Context, entityID generic.EntityID, resourceType generic.ResourceType, day generic.TimePoint) (bool, generic.TransactionID, error) {
+	var id []byte
+	var found bool
+	err := s.db.View(func(kvTx *Tx) error {
+		id, found = kvTx.Get(tkey(tenantOf(ctx), tblTxDays, string(entityID), resourceType.ResourceID(), dayKey(day.Time)))
+		return nil
+	})
+	if err != nil || !found {
+		return false, "", err
+	}
+	return true, generic.TransactionID(id), nil
+}
+
+// =============================================================================
+// POLICY STORE
+// =============================================================================
+
+// SavePolicy saves a policy record. Saving an existing policy bumps its
+// version.
+func (s *Store) SavePolicy(ctx context.Context, policy record.PolicyRecord) error {
+	return s.db.Update(func(kvTx *Tx) error {
+		k := tkey(tenantOf(ctx), tblPolicies, policy.ID)
+		policy.UpdatedAt = now()
+		var existing record.PolicyRecord
+		if ok, err := getJSON(kvTx, k, &existing); err != nil {
+			return err
+		} else if ok {
+			policy.Version = existing.Version + 1
+			policy.CreatedAt = existing.CreatedAt
+		} else {
+			policy.CreatedAt = policy.UpdatedAt
+		}
+		return putJSON(kvTx, k, policy)
+	})
+}
+
+// GetPolicy retrieves a policy by ID.
+func (s *Store) GetPolicy(ctx context.Context, id string) (*record.PolicyRecord, error) {
+	return getRecord[record.PolicyRecord](s, tkey(tenantOf(ctx), tblPolicies, id))
+}
+
+// ListPolicies returns all policies, by name.
+func (s *Store) ListPolicies(ctx context.Context) ([]record.PolicyRecord, error) {
+	policies, err := listRecords[record.PolicyRecord](s, tkey(tenantOf(ctx), tblPolicies))
+	sort.SliceStable(policies, func(i, j int) bool { return policies[i].Name < policies[j].Name })
+	return policies, err
+}
+
+// DeletePolicy removes a policy.
+func (s *Store) DeletePolicy(ctx context.Context, id string) error {
+	return s.db.Update(func(kvTx *Tx) error {
+		kvTx.Delete(tkey(tenantOf(ctx), tblPolicies, id))
+		return nil
+	})
+}
+
+// getRecord reads one record, or nil.
+func getRecord[T any](s *Store, k string) (*T, error) {
+	var v T
+	var found bool
+	err := s.db.View(func(kvTx *Tx) (err error) {
+		found, err = getJSON(kvTx, k, &v)
+		return err
+	})
+	if err != nil || !found {
+		return nil, err
+	}
+	return &v, nil
+}
+
+// listRecords reads every record under prefix, in key order.
+func listRecords[T any](s *Store, prefix string) ([]T, error) {
+	var rows []T
+	err := s.db.View(func(kvTx *Tx) (err error) {
+		rows, err = scanJSON[T](kvTx, prefix)
+		return err
+	})
+	return rows, err
+}
+
+// =============================================================================
+// EMPLOYEE STORE
+// =============================================================================
+
+// SaveEmployee saves an employee.
+func (s *Store) SaveEmployee(ctx context.Context, emp record.Employee) error {
+	return s.db.Update(func(kvTx *Tx) error {
+		k := tkey(tenantOf(ctx), tblEmployees, emp.ID)
+		var existing record.Employee
+		if ok, err := getJSON(kvTx, k, &existing); err != nil {
+			return err
+		} else if ok {
+			emp.CreatedAt = existing.CreatedAt
+		} else {
+			emp.CreatedAt = now()
+		}
+		return putJSON(kvTx, k, emp)
+	})
+}
+
+// GetEmployee retrieves an employee by ID.
+func (s *Store) GetEmployee(ctx context.Context, id string) (*record.Employee, error) {
+	return getRecord[record.Employee](s, tkey(tenantOf(ctx), tblEmployees, id))
+}
+
+// ListEmployees returns all employees, by name.
+func (s *Store) ListEmployees(ctx context.Context) ([]record.Employee, error) {
+	employees, err := listRecords[record.Employee](s, tkey(tenantOf(ctx), tblEmployees))
+	sort.SliceStable(employees, func(i, j int) bool { return employees[i].Name < employees[j].Name })
+	return employees, err
+}
+
+// DeleteEmployee removes an employee.
+func (s *Store) DeleteEmployee(ctx context.Context, id string) error {
+	return s.db.Update(func(kvTx *Tx) error {
+		kvTx.Delete(tkey(tenantOf(ctx), tblEmployees, id))
+		return nil
+	})
+}
+
+// =============================================================================
+// ASSIGNMENT STORE
+// =============================================================================
+
+// SaveAssignment saves a policy assignment. An existing assignment keeps
+// its entity and policy; dates, priority and approval config are updated.
+func (s *Store) SaveAssignment(ctx context.Context, a record.AssignmentRecord) error {
+	tenant := tenantOf(ctx)
+	return s.db.Update(func(kvTx *Tx) error {
+		k := tkey(tenant, tblAssignments, a.ID)
+		var existing record.AssignmentRecord
+		if ok, err := getJSON(kvTx, k, &existing); err != nil {
+			return err
+		} else if ok {
+			a.EntityID = existing.EntityID
+			a.PolicyID = existing.PolicyID
+			a.CreatedAt = existing.CreatedAt
+		} else {
+			a.CreatedAt = now()
+		}
+		kvTx.Put(tkey(tenant, tblAssignByEntity, a.EntityID, a.ID), nil)
+		kvTx.Put(tkey(tenant, tblAssignByPolicy, a.PolicyID, a.ID), nil)
+		return putJSON(kvTx, k, a)
+	})
+}
+
+// GetAssignmentsByEntity returns all assignments for an entity, by
+// consumption priority.
+func (s *Store) GetAssignmentsByEntity(ctx context.Context, entityID string) ([]record.AssignmentRecord, error) {
+	assignments, err := s.assignmentsIndexed(ctx, tkey(tenantOf(ctx), tblAssignByEntity, entityID))
+	sort.SliceStable(assignments, func(i, j int) bool {
+		return assignments[i].ConsumptionPriority < assignments[j].ConsumptionPriority
+	})
+	return assignments, err
+}
+
+// GetAssignmentsByPolicy returns all assignments for a policy, by entity.
+func (s *Store) GetAssignmentsByPolicy(ctx context.Context, policyID string) ([]record.AssignmentRecord, error) {
+	assignments, err := s.assignmentsIndexed(ctx, tkey(tenantOf(ctx), tblAssignByPolicy, policyID))
+	sort.SliceStable(assignments, func(i, j int) bool { return assignments[i].EntityID < assignments[j].EntityID })
+	return assignments, err
+}
+
+// assignmentsIndexed loads the assignments listed under an index prefix;
+// the assignment ID is the last key component.
+func (s *Store) assignmentsIndexed(ctx context.Context, prefix string) ([]record.AssignmentRecord, error) {
+	tenant := tenantOf(ctx)
+	var assignments []record.AssignmentRecord
+	err := s.db.View(func(kvTx *Tx) error {
+		for _, k := range scanKeys(kvTx, prefix) {
+			id := lastComponent(k)
+			var a record.AssignmentRecord
+			if ok, err := getJSON(kvTx, tkey(tenant, tblAssignments, id), &a); err != nil {
+				return err
+			} else if ok {
+				assignments = append(assignments, a)
+			}
+		}
+		return nil
+	})
+	return assignments, err
+}
+
+// DeleteAssignment removes an assignment.
+func (s *Store) DeleteAssignment(ctx context.Context, id string) error {
+	tenant := tenantOf(ctx)
+	return s.db.Update(func(kvTx *Tx) error {
+		k := tkey(tenant, tblAssignments, id)
+		var a record.AssignmentRecord
+		if ok, err := getJSON(kvTx, k, &a); err != nil || !ok {
+			return err
+		}
+		kvTx.Delete(k)
+		kvTx.Delete(tkey(tenant, tblAssignByEntity, a.EntityID, id))
+		kvTx.Delete(tkey(tenant, tblAssignByPolicy, a.PolicyID, id))
+		return nil
+	})
+}
+
+// Assignments returns the assignments as a generic.AssignmentStore,
+// resolving each assignment's policy with policies.
+func (s *Store) Assignments(policies record.PolicyResolver) generic.AssignmentStore {
+	return record.NewAssignmentStore(s, policies)
+}
+
+// lastComponent decodes the last component of a key.
+func lastComponent(k string) string {
+	k = strings.TrimSuffix(k, "\x00\x01")
+	if i := strings.LastIndex(k, "\x00\x01"); i >= 0 {
+		k = k[i+2:]
+	}
+	return strings.ReplaceAll(k, "\x00\xff", "\x00")
+}
+
+// =============================================================================
+// SNAPSHOT STORE
+// =============================================================================
+
+func snapshotKey(tenant, entityID, policyID string, periodStart, periodEnd time.Time) string {
+	return tkey(tenant, tblSnapshots, entityID, policyID, timeKey(periodStart), timeKey(periodEnd))
+}
+
+// SaveSnapshot saves a balance snapshot, replacing the one for the same
+// entity, policy and period.
+func (s *Store) SaveSnapshot(ctx context.Context, snap record.SnapshotRecord) error {
+	return s.db.Update(func(kvTx *Tx) error {
+		k := snapshotKey(tenantOf(ctx), snap.EntityID, snap.PolicyID, snap.PeriodStart, snap.PeriodEnd)
+		var existing record.SnapshotRecord
+		if ok, err := getJSON(kvTx, k, &existing); err != nil {
+			return err
+		} else if ok {
+			snap.ID = existing.ID
+		}
+		snap.CreatedAt = now()
+		return putJSON(kvTx, k, snap)
+	})
+}
+
+// GetSnapshot retrieves a snapshot for entity+policy+period.
+func (s *Store) GetSnapshot(ctx context.Context, entityID, policyID string, periodStart, periodEnd time.Time) (*record.SnapshotRecord, error) {
+	return getRecord[record.SnapshotRecord](s, snapshotKey(tenantOf(ctx), entityID, policyID, periodStart, periodEnd))
+}
+
+// GetLatestSnapshot retrieves the snapshot with the latest period end for
+// entity+policy.
+func (s *Store) GetLatestSnapshot(ctx context.Context, entityID, policyID string) (*record.SnapshotRecord, error) {
+	snaps, err := listRecords[record.SnapshotRecord](s, tkey(tenantOf(ctx), tblSnapshots, entityID, policyID))
+	if err != nil {
+		return nil, err
+	}
+	var latest *record.SnapshotRecord
+	for i := range snaps {
+		if latest == nil || !snaps[i].PeriodEnd.Before(latest.PeriodEnd) {
+			latest = &snaps[i]
+		}
+	}
+	return latest, nil
+}
+
+// Snapshots returns the snapshots as a generic.SnapshotStore.
+func (s *Store) Snapshots() generic.SnapshotStore {
+	return record.NewSnapshotStore(s)
+}
+
+// =============================================================================
+// PAY RATE STORE
+// =============================================================================
+
+// SavePayRate saves a pay rate. Saving a second rate for the same
+// employee and effective date returns record.ErrPayRateExists.
+func (s *Store) SavePayRate(ctx context.Context, r record.PayRate) error {
+	if r.HoursPerDay == 0 {
+		r.HoursPerDay = 8
+	}
+	return s.db.Update(func(kvTx *Tx) error {
+		k := tkey(tenantOf(ctx), tblPayRates, r.EntityID, timeKey(r.EffectiveFrom))
+		var existing record.PayRate
+		if ok, err := getJSON(kvTx, k, &existing); err != nil {
+			return err
+		} else if ok {
+			return record.ErrPayRateExists
+		}
+		r.CreatedAt = now()
+		return putJSON(kvTx, k, r)
+	})
+}
+
+// GetPayRates returns an employee's pay rate history, oldest first.
+func (s *Store) GetPayRates(ctx context.Context, entityID string) ([]record.PayRate, error) {
+	return listRecords[record.PayRate](s, tkey(tenantOf(ctx), tblPayRates, entityID))
+}
+
+// GetPayRateAt returns the pay rate in effect on the given date, or nil.
+func (s *Store) GetPayRateAt(ctx context.Context, entityID string, at time.Time) (*record.PayRate, error) {
+	var rate *record.PayRate
+	err := s.db.View(func(kvTx *Tx) error {
+		tenant := tenantOf(ctx)
+		start := tkey(tenant, tblPayRates, entityID)
+		end := prefixEnd(tkey(tenant, tblPayRates, entityID, timeKey(at)))
+		var last []byte
+		kvTx.Scan(start, end, func(_ string, data []byte) bool {
+			last = data
+			return true
+		})
+		if last == nil {
+			return nil
+		}
+		rate = &record.PayRate{}
+		return json.Unmarshal(last, rate)
+	})
+	return rate, err
+}
+
+// =============================================================================
+// CALENDAR FEED STORE
+// =============================================================================
+
+// SaveCalendarFeed stores a new feed token.
+func (s *Store) SaveCalendarFeed(ctx context.Context, f record.CalendarFeed) error {
+	return s.db.Update(func(kvTx *Tx) error {
+		k := gkey(tblFeeds, f.Token)
+		if _, exists := kvTx.Get(k); exists {
+			return errors.New("calendar feed token already exists")
+		}
+		f.TenantID = generic.TenantFrom(ctx)
+		f.CreatedAt = now()
+		f.RevokedAt = nil
+		if err := putJSON(kvTx, tkey(string(f.TenantID), tblFeedsByOwner, f.OwnerID, f.Token), f); err != nil {
+			return err
+		}
+		return putJSON(kvTx, k, f)
+	})
+}
+
+// GetCalendarFeed returns an active (non-revoked) feed by token, or nil.
+// Tokens are looked up across all tenants: the feed's TenantID is how a
+// feed request finds its tenant.
+func (s *Store) GetCalendarFeed(ctx context.Context, token string) (*record.CalendarFeed, error) {
+	f, err := getRecord[record.CalendarFeed](s, gkey(tblFeeds, token))
+	if err != nil || f == nil || f.RevokedAt != nil {
+		return nil, err
+	}
+	return f, nil
+}
+
+// GetCalendarFeedsByOwner returns all feeds created by an employee.
+func (s *Store) GetCalendarFeedsByOwner(ctx context.Context, ownerID string) ([]record.CalendarFeed, error) {
+	feeds, err := listRecords[record.CalendarFeed](s, tkey(tenantOf(ctx), tblFeedsByOwner, ownerID))
+	sort.SliceStable(feeds, func(i, j int) bool { return feeds[i].CreatedAt.Before(feeds[j].CreatedAt) })
+	return feeds, err
+}
+
+// RevokeCalendarFeed revokes a feed token. Returns false if the token
+// does not exist for the owner or is already revoked.
+func (s *Store) RevokeCalendarFeed(ctx context.Context, ownerID, token string) (bool, error) {
+	var revoked bool
+	err := s.db.Update(func(kvTx *Tx) error {
+		ownerKey := tkey(tenantOf(ctx), tblFeedsByOwner, ownerID, token)
+		var f record.CalendarFeed
+		if ok, err := getJSON(kvTx, ownerKey, &f); err != nil || !ok || f.RevokedAt != nil {
+			return err
+		}
+		at := now()
+		f.RevokedAt = &at
+		revoked = true
+		if err := putJSON(kvTx, ownerKey, f); err != nil {
+			return err
+		}
+		return putJSON(kvTx, gkey(tblFeeds, token), f)
+	})
+	return revoked, err
+}
+
+// =============================================================================
+// HOLIDAY CALENDAR IMPLEMENTATION
+// =============================================================================
+
+// holidayRow is a stored holiday; the rule is kept in its text form.
+type holidayRow struct {
+	ID         string `json:"id"`
+	CompanyID  string `json:"company_id"`
+	CalendarID string `json:"calendar_id"`
+	Date       string `json:"date"` // 2006-01-02
+	Name       string `json:"name"`
+	Recurring  bool   `json:"recurring"`
+	Rule       string `json:"rule,omitempty"`
+}
+
+func newHolidayRow(h generic.Holiday) holidayRow {
+	return holidayRow{
+		ID:         h.ID,
+		CompanyID:  h.CompanyID,
+		CalendarID: h.CalendarID,
+		Date:       h.Date.Time.Format("2006-01-02"),
+		Name:       h.Name,
+		Recurring:  h.Recurring || h.Rule != nil,
+		Rule:       record.HolidayRuleText(h),
+	}
+}
+
+// holiday decodes the row; rows with an unparseable rule are skipped
+// rather than failing every lookup.
+func (r holidayRow) holiday() (generic.Holiday, bool) {
+	h := generic.Holiday{
+		ID:         r.ID,
+		CompanyID:  r.CompanyID,
+		CalendarID: r.CalendarID,
+		Name:       r.Name,
+		Recurring:  r.Recurring,
+	}
+	if r.Rule != "" {
+		rule, err := generic.ParseHolidayRule(r.Rule)
+		if err != nil {
+			return h, false
+		}
+		h.Rule = &rule
+	}
+	t, _ := time.Parse("2006-01-02", r.Date)
+	h.Date = generic.TimePoint{Time: t, Granularity: generic.GranularityDay}
+	return h, true
+}
+
+func (r holidayRow) uniqueKey(tenant string) string {
+	return tkey(tenant, tblHolidayUnique, r.CompanyID, r.CalendarID, r.Date, r.Name)
+}
+
+// SaveHoliday saves a holiday. Saving an existing ID, or the same company,
+// calendar, date and name, updates the holiday.
+func (s *Store) SaveHoliday(ctx context.Context, h generic.Holiday) error {
+	tenant := tenantOf(ctx)
+	return s.db.Update(func(kvTx *Tx) error {
+		row := newHolidayRow(h)
+
+		var existing holidayRow
+		if ok, err := getJSON(kvTx, tkey(tenant, tblHolidays, row.ID), &existing); err != nil {
+			return err
+		} else if ok {
+			// Same ID: move it to the new date and name
+			updated := existing
+			updated.Date, updated.Name, updated.Recurring, updated.Rule = row.Date, row.Name, row.Recurring, row.Rule
+			if id, taken := kvTx.Get(updated.uniqueKey(tenant)); taken && string(id) != existing.ID {
+				return fmt.Errorf("holiday %s %q already exists", updated.Date, updated.Name)
+			}
+			kvTx.Delete(existing.uniqueKey(tenant))
+			return putHoliday(kvTx, tenant, updated)
+		}
+
+		if id, ok := kvTx.Get(row.uniqueKey(tenant)); ok {
+			// Same holiday under another ID: update its rule
+			if ok, err := getJSON(kvTx, tkey(tenant, tblHolidays, string(id)), &existing); err != nil || !ok {
+				return err
+			}
+			existing.Recurring, existing.Rule = row.Recurring, row.Rule
+			return putHoliday(kvTx, tenant, existing)
+		}
+		return putHoliday(kvTx, tenant, row)
+	})
+}
+
+func putHoliday(kvTx *Tx, tenant string, row holidayRow) error {
+	kvTx.Put(row.uniqueKey(tenant), []byte(row.ID))
+	return putJSON(kvTx, tkey(tenant, tblHolidays, row.ID), row)
+}
+
+// DeleteHoliday deletes a holiday by ID.
+func (s *Store) DeleteHoliday(ctx context.Context, id string) error {
+	tenant := tenantOf(ctx)
+	return s.db.Update(func(kvTx *Tx) error {
+		deleteHoliday(kvTx, tenant, id)
+		return nil
+	})
+}
+
+func deleteHoliday(kvTx *Tx, tenant, id string) {
+	k := tkey(tenant, tblHolidays, id)
+	var row holidayRow
+	if ok, _ := getJSON(kvTx, k, &row); ok {
+		kvTx.Delete(row.uniqueKey(tenant))
+	}
+	kvTx.Delete(k)
+}
+
+// GetHolidays returns all holidays for a company in a given year, with
+// recurring and rule-based holidays moved to their date in that year.
+// Includes both company-specific and global holidays, but not those of
+// named calendars (see GetEntityHolidays). generic.HolidayCalendar takes
+// no context, so this reads the default tenant; see HolidayCalendarFor.
+func (s *Store) GetHolidays(companyID string, year int) []generic.Holiday {
+	return s.holidaysInYear(context.Background(), companyID, year)
+}
+
+// IsHoliday checks if a date is a holiday for the given company, in the
+// default tenant.
+func (s *Store) IsHoliday(companyID string, date generic.TimePoint) bool {
+	return record.ContainsHolidayOn(s.GetHolidays(companyID, date.Year()), date)
+}
+
+// HolidayCalendarFor returns the generic.HolidayCalendar of a tenant.
+func (s *Store) HolidayCalendarFor(tenant generic.TenantID) generic.HolidayCalendar {
+	return tenantHolidays{store: s, ctx: generic.WithTenant(context.Background(), tenant)}
+}
+
+// tenantHolidays binds the context-free HolidayCalendar methods to a tenant.
+type tenantHolidays struct {
+	store *Store
+	ctx   context.Context
+}
+
+func (t tenantHolidays) GetHolidays(companyID string, year int) []generic.Holiday {
+	return t.store.holidaysInYear(t.ctx, companyID, year)
+}
+
+func (t tenantHolidays) IsHoliday(companyID string, date generic.TimePoint) bool {
+	return record.ContainsHolidayOn(t.GetHolidays(companyID, date.Year()), date)
+}
+
+func (s *Store) holidaysInYear(ctx context.Context, companyID string, year int) []generic.Holiday {
+	holidays, err := s.queryHolidays(ctx, func(r holidayRow) bool {
+		return (r.CompanyID == companyID || r.CompanyID == "") && r.CalendarID == "" && inYear(r, year)
+	})
+	if err != nil {
+		return nil
+	}
+	return generic.ExpandHolidays(holidays, year)
+}
+
+// inYear reports whether a stored holiday can fall in a year: recurring
+// and rule-based ones always can.
+func inYear(r holidayRow, year int) bool {
+	return r.Recurring || r.Rule != "" || strings.HasPrefix(r.Date, fmt.Sprintf("%04d-", year))
+}
+
+// GetAllHolidays returns all holidays (for admin UI). Dates are as stored;
+// use GetHolidays for the dates in a given year.
+func (s *Store) GetAllHolidays(ctx context.Context, companyID string) ([]generic.Holiday, error) {
+	holidays, err := s.queryHolidays(ctx, func(r holidayRow) bool {
+		return r.CompanyID == companyID || r.CompanyID == ""
+	})
+	sort.SliceStable(holidays, func(i, j int) bool { return holidays[i].Date.Time.Before(holidays[j].Date.Time) })
+	return holidays, err
+}
+
+// queryHolidays returns the tenant's stored holidays that match.
+func (s *Store) queryHolidays(ctx context.Context, match func(holidayRow) bool) ([]generic.Holiday, error) {
+	rows, err := listRecords[holidayRow](s, tkey(tenantOf(ctx), tblHolidays))
+	if err != nil {
+		return nil, err
+	}
+	var holidays []generic.Holiday
+	for _, r := range rows {
+		if !match(r) {
+			continue
+		}
+		if h, ok := r.holiday(); ok {
+			holidays = append(holidays, h)
+		}
+	}
+	return holidays, nil
+}
+
+// errDryRun discards a dry-run import's writes.
+var errDryRun = errors.New("dry run")
+
+// ImportHolidays bulk-loads holidays for a company atomically. Rows that
+// already exist (same company, calendar, date and name) are reported as
+// duplicates and left untouched. IDs are derived from that key, so
+// re-importing the same file is a no-op.
+func (s *Store) ImportHolidays(ctx context.Context, companyID string, holidays []generic.Holiday, opts record.HolidayImportOptions) (*record.HolidayImportResult, error) {
+	tenant := tenantOf(ctx)
+	result := &record.HolidayImportResult{Items: make([]record.HolidayImportItem, 0, len(holidays))}
+
+	err := s.db.Update(func(kvTx *Tx) error {
+		if opts.ReplaceYear != 0 {
+			rows, err := scanJSON[holidayRow](kvTx, tkey(tenant, tblHolidays))
+			if err != nil {
+				return fmt.Errorf("failed to replace holidays: %w", err)
+			}
+			year := fmt.Sprintf("%04d-", opts.ReplaceYear)
+			for _, r := range rows {
+				if r.CompanyID == companyID && r.CalendarID == opts.CalendarID && strings.HasPrefix(r.Date, year) {
+					deleteHoliday(kvTx, tenant, r.ID)
+					result.Removed++
+				}
+			}
+		}
+
+		for _, h := range holidays {
+			h.CompanyID = companyID
+			h.CalendarID = opts.CalendarID
+			date := h.Date.Time.Format("2006-01-02")
+			h.ID = record.HolidayImportID(companyID, opts.CalendarID, date, h.Name)
+
+			if opts.ReplaceYear != 0 && h.Date.Time.Year() != opts.ReplaceYear {
+				result.OutOfYear++
+				result.Items = append(result.Items, record.HolidayImportItem{Holiday: h, Status: record.HolidayImportOutOfYear})
+				continue
+			}
+
+			row := newHolidayRow(h)
+			_, idTaken := kvTx.Get(tkey(tenant, tblHolidays, row.ID))
+			_, keyTaken := kvTx.Get(row.uniqueKey(tenant))
+
+			status := record.HolidayImportCreated
+			if idTaken || keyTaken {
+				status = record.HolidayImportDuplicate
+				result.Duplicates++
+			} else {
+				if err := putHoliday(kvTx, tenant, row); err != nil {
+					return fmt.Errorf("failed to import holiday %s %q: %w", date, h.Name, err)
+				}
+				result.Created++
+			}
+			result.Items = append(result.Items, record.HolidayImportItem{Holiday: h, Status: status})
+		}
+
+		if opts.DryRun {
+			return errDryRun
+		}
+		return nil
+	})
+	if err != nil && !errors.Is(err, errDryRun) {
+		return nil, err
+	}
+	return result, nil
+}
+
+// =============================================================================
+// NAMED HOLIDAY CALENDARS (generic.EntityHolidayCalendar)
+// =============================================================================
+
+// SaveHolidayCalendar creates or renames a holiday calendar.
+func (s *Store) SaveHolidayCalendar(ctx context.Context, c record.HolidayCalendarRecord) error {
+	return s.db.Update(func(kvTx *Tx) error {
+		k := tkey(tenantOf(ctx), tblCalendars, c.ID)
+		var existing record.HolidayCalendarRecord
+		if ok, err := getJSON(kvTx, k, &existing); err != nil {
+			return err
+		} else if ok {
+			existing.Name = c.Name
+			c = existing
+		} else {
+			c.CreatedAt = now()
+		}
+		return putJSON(kvTx, k, c)
+	})
+}
+
+// GetHolidayCalendar returns a holiday calendar by ID, or nil.
+func (s *Store) GetHolidayCalendar(ctx context.Context, id string) (*record.HolidayCalendarRecord, error) {
+	return getRecord[record.HolidayCalendarRecord](s, tkey(tenantOf(ctx), tblCalendars, id))
+}
+
+// ListHolidayCalendars returns all holiday calendars, by ID.
+func (s *Store) ListHolidayCalendars(ctx context.Context) ([]record.HolidayCalendarRecord, error) {
+	return listRecords[record.HolidayCalendarRecord](s, tkey(tenantOf(ctx), tblCalendars))
+}
+
+// AssignHolidayCalendar assigns a calendar to an employee from a date.
+// Assigning twice on the same date replaces the first assignment.
+func (s *Store) AssignHolidayCalendar(ctx context.Context, a record.EmployeeHolidayCalendar) error {
+	return s.db.Update(func(kvTx *Tx) error {
+		k := tkey(tenantOf(ctx), tblEmpCalendars, a.EntityID, timeKey(a.EffectiveFrom))
+		var existing record.EmployeeHolidayCalendar
+		if ok, err := getJSON(kvTx, k, &existing); err != nil {
+			return err
+		} else if ok {
+			existing.CalendarID = a.CalendarID
+			a = existing
+		} else {
+			a.CreatedAt = now()
+		}
+		return putJSON(kvTx, k, a)
+	})
+}
+
+// GetEmployeeHolidayCalendars returns an employee's calendar history, oldest first.
+func (s *Store) GetEmployeeHolidayCalendars(ctx context.Context, entityID string) ([]record.EmployeeHolidayCalendar, error) {
+	return listRecords[record.EmployeeHolidayCalendar](s, tkey(tenantOf(ctx), tblEmpCalendars, entityID))
+}
+
+func (s *Store) loadEntityCalendars(ctx context.Context, entityID generic.EntityID) (*record.EntityCalendars, error) {
+	history, err := s.GetEmployeeHolidayCalendars(ctx, string(entityID))
+	if err != nil {
+		return nil, err
+	}
+	calendars, err := s.ListHolidayCalendars(ctx)
+	if err != nil {
+		return nil, err
+	}
+	ec := &record.EntityCalendars{History: history, Companies: make(map[string]string, len(calendars))}
+	for _, c := range calendars {
+		ec.Companies[c.ID] = c.CompanyID
+	}
+	return ec, nil
+}
+
+// IsEntityHoliday checks if a date is a holiday under the calendar
+// assigned to the entity on that date.
+func (s *Store) IsEntityHoliday(ctx context.Context, entityID generic.EntityID, date generic.TimePoint) bool {
+	return record.ContainsHolidayOn(s.GetEntityHolidays(ctx, entityID, date.Year()), date)
+}
+
+// GetEntityHolidays returns the entity's holidays in a year, each resolved
+// against the calendar in effect on its (observed) date.
+func (s *Store) GetEntityHolidays(ctx context.Context, entityID generic.EntityID, year int) []generic.Holiday {
+	ec, err := s.loadEntityCalendars(ctx, entityID)
+	if err != nil {
+		return nil
+	}
+
+	holidays, err := s.queryHolidays(ctx, func(r holidayRow) bool { return inYear(r, year) })
+	if err != nil {
+		return nil
+	}
+
+	var result []generic.Holiday
+	for _, h := range generic.ExpandHolidays(holidays, year) {
+		if ec.Applies(h) {
+			result = append(result, h)
+		}
+	}
+	return result
+}
+
+// =============================================================================
+// REQUEST STORE (for approval workflow)
+// =============================================================================
+
+// SaveRequest saves a request. Saving an existing request only updates
+// its status, approval, rejection reason and UpdatedAt.
+func (s *Store) SaveRequest(ctx context.Context, r record.Request) error {
+	tenant := tenantOf(ctx)
+	return s.db.Update(func(kvTx *Tx) error {
+		k := tkey(tenant, tblRequests, r.ID)
+		var existing record.Request
+		if ok, err := getJSON(kvTx, k, &existing); err != nil {
+			return err
+		} else if ok {
+			existing.Status = r.Status
+			existing.ApprovedBy = r.ApprovedBy
+			existing.ApprovedAt = r.ApprovedAt
+			existing.RejectionReason = r.RejectionReason
+			existing.UpdatedAt = r.UpdatedAt
+			r = existing
+		}
+
+		kvTx.Put(tkey(tenant, tblRequestsEntity, r.EntityID, r.ID), nil)
+		if r.Status == "pending" {
+			kvTx.Put(tkey(tenant, tblRequestsQueue, r.ID), nil)
+		} else {
+			kvTx.Delete(tkey(tenant, tblRequestsQueue, r.ID))
+		}
+		return putJSON(kvTx, k, r)
+	})
+}
+
+// GetRequest retrieves a request by ID.
+func (s *Store) GetRequest(ctx context.Context, id string) (*record.Request, error) {
+	return getRecord[record.Request](s, tkey(tenantOf(ctx), tblRequests, id))
+}
+
+// GetPendingRequests returns all pending requests, oldest first.
+func (s *Store) GetPendingRequests(ctx context.Context) ([]record.Request, error) {
+	requests, err := s.requestsIndexed(ctx, tkey(tenantOf(ctx), tblRequestsQueue))
+	sort.SliceStable(requests, func(i, j int) bool { return requests[i].CreatedAt.Before(requests[j].CreatedAt) })
+	return requests, err
+}
+
+// GetRequestsByEntity returns all requests for an entity, newest first.
+func (s *Store) GetRequestsByEntity(ctx context.Context, entityID string) ([]record.Request, error) {
+	requests, err := s.requestsIndexed(ctx, tkey(tenantOf(ctx), tblRequestsEntity, entityID))
+	sort.SliceStable(requests, func(i, j int) bool { return requests[i].CreatedAt.After(requests[j].CreatedAt) })
+	return requests, err
+}
+
+// requestsIndexed loads the requests listed under an index prefix; the
+// request ID is the last key component.
+func (s *Store) requestsIndexed(ctx context.Context, prefix string) ([]record.Request, error) {
+	tenant := tenantOf(ctx)
+	var requests []record.Request
+	err := s.db.View(func(kvTx *Tx) error {
+		for _, k := range scanKeys(kvTx, prefix) {
+			var r record.Request
+			if ok, err := getJSON(kvTx, tkey(tenant, tblRequests, lastComponent(k)), &r); err != nil {
+				return err
+			} else if ok {
+				requests = append(requests, r)
+			}
+		}
+		return nil
+	})
+	return requests, err
+}
+
+// =============================================================================
+// RECONCILIATION RUNS STORE
+// =============================================================================
+
+// SaveReconciliationRun saves a reconciliation run. There is one run per
+// entity, policy and period; saving it again updates its outcome.
+func (s *Store) SaveReconciliationRun(ctx context.Context, r record.ReconciliationRun) error {
+	return s.db.Update(func(kvTx *Tx) error {
+		k := tkey(tenantOf(ctx), tblRuns, r.EntityID, r.PolicyID, timeKey(r.PeriodStart), timeKey(r.PeriodEnd))
+		var existing record.ReconciliationRun
+		if ok, err := getJSON(kvTx, k, &existing); err != nil {
+			return err
+		} else if ok {
+			r.ID = existing.ID
+			r.CreatedAt = existing.CreatedAt
+		}
+		return putJSON(kvTx, k, r)
+	})
+}
+
+// GetReconciliationRuns returns reconciliation runs, newest first,
+// optionally filtered by status.
+func (s *Store) GetReconciliationRuns(ctx context.Context, status string) ([]record.ReconciliationRun, error) {
+	all, err := listRecords[record.ReconciliationRun](s, tkey(tenantOf(ctx), tblRuns))
+	if err != nil {
+		return nil, err
+	}
+	var runs []record.ReconciliationRun
+	for _, r := range all {
+		if status == "" || r.Status == status {
+			runs = append(runs, r)
+		}
+	}
+	sort.SliceStable(runs, func(i, j int) bool { return runs[i].CreatedAt.After(runs[j].CreatedAt) })
+	return runs, nil
+}
+
+// GetReconciliationRun returns a reconciliation run by ID, or nil.
+func (s *Store) GetReconciliationRun(ctx context.Context, id string) (*record.ReconciliationRun, error) {
+	all, err := listRecords[record.ReconciliationRun](s, tkey(tenantOf(ctx), tblRuns))
+	if err != nil {
+		return nil, err
+	}
+	for _, r := range all {
+		if r.ID == id {
+			return &r, nil
+		}
+	}
+	return nil, nil
+}
+
+// GetReconciliationRunForPeriod returns the run of an entity, policy and
+// period, or nil. There is at most one.
+func (s *Store) GetReconciliationRunForPeriod(ctx context.Context, entityID, policyID string, periodStart, periodEnd time.Time) (*record.ReconciliationRun, error) {
+	var run record.ReconciliationRun
+	var found bool
+	err := s.db.View(func(kvTx *Tx) error {
+		var err error
+		found, err = getJSON(kvTx, tkey(tenantOf(ctx), tblRuns, entityID, policyID, timeKey(periodStart), timeKey(periodEnd)), &run)
+		return err
+	})
+	if err != nil || !found {
+		return nil, err
+	}
+	return &run, nil
+}
+
+// GetReconciliationRunsFor returns an entity's runs of a policy, newest
+// first.
+func (s *Store) GetReconciliationRunsFor(ctx context.Context, entityID, policyID string) ([]record.ReconciliationRun, error) {
+	runs, err := listRecords[record.ReconciliationRun](s, tkey(tenantOf(ctx), tblRuns, entityID, policyID))
+	if err != nil {
+		return nil, err
+	}
+	sort.SliceStable(runs, func(i, j int) bool { return runs[i].CreatedAt.After(runs[j].CreatedAt) })
+	return runs, nil
+}
+
+// GetReconciliationRunsByBatch returns the runs (items) of a batch,
+// newest first.
+func (s *Store) GetReconciliationRunsByBatch(ctx context.Context, batchID string) ([]record.ReconciliationRun, error) {
+	all, err := s.GetReconciliationRuns(ctx, "")
+	if err != nil {
+		return nil, err
+	}
+	var runs []record.ReconciliationRun
+	for _, r := range all {
+		if r.BatchID == batchID {
+			runs = append(runs, r)
+		}
+	}
+	return runs, nil
+}
+
+// SaveReconciliationBatch creates or updates a reconciliation batch.
+func (s *Store) SaveReconciliationBatch(ctx context.Context, b record.ReconciliationBatch) error {
+	return s.db.Update(func(kvTx *Tx) error {
+		k := tkey(tenantOf(ctx), tblBatches, b.ID)
+		var existing record.ReconciliationBatch
+		if ok, err := getJSON(kvTx, k, &existing); err != nil {
+			return err
+		} else if ok {
+			b.Trigger = existing.Trigger
+			b.AsOf = existing.AsOf
+			b.CreatedAt = existing.CreatedAt
+		}
+		return putJSON(kvTx, k, b)
+	})
+}
+
+// GetReconciliationBatch returns a reconciliation batch by ID, or nil.
+func (s *Store) GetReconciliationBatch(ctx context.Context, id string) (*record.ReconciliationBatch, error) {
+	var b record.ReconciliationBatch
+	var found bool
+	err := s.db.View(func(kvTx *Tx) (err error) {
+		found, err = getJSON(kvTx, tkey(tenantOf(ctx), tblBatches, id), &b)
+		return err
+	})
+	if err != nil || !found {
+		return nil, err
+	}
+	return &b, nil
+}
+
+// ListReconciliationBatches returns the tenant's batches, newest first.
+func (s *Store) ListReconciliationBatches(ctx context.Context) ([]record.ReconciliationBatch, error) {
+	batches, err := listRecords[record.ReconciliationBatch](s, tkey(tenantOf(ctx), tblBatches))
+	if err != nil {
+		return nil, err
+	}
+	sort.SliceStable(batches, func(i, j int) bool {
+		if !batches[i].CreatedAt.Equal(batches[j].CreatedAt) {
+			return batches[i].CreatedAt.After(batches[j].CreatedAt)
+		}
+		return batches[i].ID > batches[j].ID
+	})
+	return batches, nil
+}
+
+// IsReconciliationComplete checks if a reconciliation has already been done.
+func (s *Store) IsReconciliationComplete(ctx context.Context, entityID, policyID string, periodEnd time.Time) (bool, error) {
+	runs, err := listRecords[record.ReconciliationRun](s, tkey(tenantOf(ctx), tblRuns, entityID, policyID))
+	if err != nil {
+		return false, err
+	}
+	for _, r := range runs {
+		if r.PeriodEnd.Equal(periodEnd) && r.Status == "completed" {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// =============================================================================
+// OUTBOX AND WEBHOOK STORE
+// =============================================================================
+
+// insertOutboxEvent records the event for a batch of transactions.
+func insertOutboxEvent(ctx context.Context, kvTx *Tx, eventType generic.EventType, txs []generic.Transaction, data map[string]any) error {
+	event, err := record.NewOutboxEvent(ctx, eventType, txs, data)
+	if err != nil {
+		return err
+	}
+	tenant := tenantOf(ctx)
+	kvTx.Put(tkey(tenant, tblOutboxQueue, event.ID), nil)
+	if err := putJSON(kvTx, tkey(tenant, tblOutbox, event.ID), event); err != nil {
+		return fmt.Errorf("failed to record outbox event: %w", err)
+	}
+	return nil
+}
+
+// ListOutboxEvents returns the most recent events, newest first.
+func (s *Store) ListOutboxEvents(ctx context.Context, limit int) ([]record.OutboxEvent, error) {
+	// Event IDs sort in creation order
+	events, err := listRecords[record.OutboxEvent](s, tkey(tenantOf(ctx), tblOutbox))
+	if err != nil {
+		return nil, err
+	}
+	var newest []record.OutboxEvent
+	for i := len(events) - 1; i >= 0 && len(newest) < limit; i-- {
+		newest = append(newest, events[i])
+	}
+	return newest, nil
+}
+
+// DispatchOutbox fans undispatched events out to one pending delivery per
+// active endpoint that accepts the event type, and marks them dispatched.
+// Events with no matching endpoint are marked dispatched too. Returns the
+// number of events dispatched.
+func (s *Store) DispatchOutbox(ctx context.Context, limit int) (int, error) {
+	tenant := tenantOf(ctx)
+	dispatched := 0
+	err := s.db.Update(func(kvTx *Tx) error {
+		endpoints, err := scanJSON[record.WebhookEndpoint](kvTx, tkey(tenant, tblEndpoints))
+		if err != nil {
+			return err
+		}
+
+		var eventIDs []string
+		kvTx.ScanPrefix(tkey(tenant, tblOutboxQueue), func(k string, _ []byte) bool {
+			eventIDs = append(eventIDs, lastComponent(k))
+			return len(eventIDs) < limit
+		})
+
+		at := now()
+		for _, id := range eventIDs {
+			var event record.OutboxEvent
+			if ok, err := getJSON(kvTx, tkey(tenant, tblOutbox, id), &event); err != nil {
+				return err
+			} else if !ok {
+				continue
+			}
+			for _, endpoint := range endpoints {
+				if !endpoint.Active || !endpoint.Accepts(event.Type) {
+					continue
+				}
+				if _, exists := kvTx.Get(tkey(tenant, tblDeliveryEvents, endpoint.ID, event.ID)); exists {
+					continue
+				}
+				d := record.WebhookDelivery{
+					ID:            record.NewEventID("dlv"),
+					EndpointID:    endpoint.ID,
+					EventID:       event.ID,
+					EventType:     event.Type,
+					Status:        record.DeliveryPending,
+					NextAttemptAt: &at,
+					CreatedAt:     at,
+				}
+				if err := putDelivery(kvTx, tenant, nil, d); err != nil {
+					return fmt.Errorf("failed to create delivery: %w", err)
+				}
+			}
+			event.DispatchedAt = &at
+			if err := putJSON(kvTx, tkey(tenant, tblOutbox, id), event); err != nil {
+				return err
+			}
+			kvTx.Delete(tkey(tenant, tblOutboxQueue, id))
+			dispatched++
+		}
+		return nil
+	})
+	return dispatched, err
+}
+
+// SaveWebhookEndpoint creates or updates a webhook endpoint.
+func (s *Store) SaveWebhookEndpoint(ctx context.Context, e record.WebhookEndpoint) error {
+	return s.db.Update(func(kvTx *Tx) error {
+		k := tkey(tenantOf(ctx), tblEndpoints, e.ID)
+		var existing record.WebhookEndpoint
+		if ok, err := getJSON(kvTx, k, &existing); err != nil {
+			return err
+		} else if ok {
+			e.CreatedAt = existing.CreatedAt
+		} else if e.CreatedAt.IsZero() {
+			e.CreatedAt = now()
+		}
+		e.CreatedAt = e.CreatedAt.UTC().Truncate(time.Second)
+		return putJSON(kvTx, k, e)
+	})
+}
+
+// GetWebhookEndpoint returns an endpoint by ID, or nil.
+func (s *Store) GetWebhookEndpoint(ctx context.Context, id string) (*record.WebhookEndpoint, error) {
+	return getRecord[record.WebhookEndpoint](s, tkey(tenantOf(ctx), tblEndpoints, id))
+}
+
+// ListWebhookEndpoints returns all endpoints, active or not, oldest first.
+func (s *Store) ListWebhookEndpoints(ctx context.Context) ([]record.WebhookEndpoint, error) {
+	endpoints, err := listRecords[record.WebhookEndpoint](s, tkey(tenantOf(ctx), tblEndpoints))
+	sort.SliceStable(endpoints, func(i, j int) bool { return endpoints[i].CreatedAt.Before(endpoints[j].CreatedAt) })
+	return endpoints, err
+}
+
+// putDelivery writes a delivery and keeps its indexes in step with old,
+// the delivery as stored before (nil if new).
+func putDelivery(kvTx *Tx, tenant string, old *record.WebhookDelivery, d record.WebhookDelivery) error {
+	if old != nil && old.Status == record.DeliveryPending && old.NextAttemptAt != nil {
+		kvTx.Delete(tkey(tenant, tblDeliveriesDue, timeKey(*old.NextAttemptAt), old.ID))
+	}
+	if d.Status == record.DeliveryPending && d.NextAttemptAt != nil {
+		kvTx.Put(tkey(tenant, tblDeliveriesDue, timeKey(*d.NextAttemptAt), d.ID), nil)
+	}
+	kvTx.Put(tkey(tenant, tblDeliveryEvents, d.EndpointID, d.EventID), []byte(d.ID))
+
+	d.Payload = "" // Read from the event
+	return putJSON(kvTx, tkey(tenant, tblDeliveries, d.ID), d)
+}
+
+// DueWebhookDeliveries returns pending deliveries whose next attempt is
+// due, oldest first, with their event payloads.
+func (s *Store) DueWebhookDeliveries(ctx context.Context, now time.Time, limit int) ([]record.WebhookDelivery, error) {
+	tenant := tenantOf(ctx)
+	var deliveries []record.WebhookDelivery
+	err := s.db.View(func(kvTx *Tx) error {
+		start := tkey(tenant, tblDeliveriesDue)
+		end := prefixEnd(tkey(tenant, tblDeliveriesDue, timeKey(now)))
+		var ids []string
+		kvTx.Scan(start, end, func(k string, _ []byte) bool {
+			ids = append(ids, lastComponent(k))
+			return len(ids) < limit
+		})
+
+		for _, id := range ids {
+			var d record.WebhookDelivery
+			if ok, err := getJSON(kvTx, tkey(tenant, tblDeliveries, id), &d); err != nil {
+				return err
+			} else if !ok {
+				continue
+			}
+			var event record.OutboxEvent
+			if ok, err := getJSON(kvTx, tkey(tenant, tblOutbox, d.EventID), &event); err != nil {
+				return err
+			} else if !ok {
+				continue
+			}
+			d.Payload = event.Payload
+			deliveries = append(deliveries, d)
+		}
+		return nil
+	})
+	return deliveries, err
+}
+
+// ListWebhookDeliveries returns an endpoint's deliveries, newest first,
+// optionally filtered by status.
+func (s *Store) ListWebhookDeliveries(ctx context.Context, endpointID, status string, limit int) ([]record.WebhookDelivery, error) {
+	tenant := tenantOf(ctx)
+	var deliveries []record.WebhookDelivery
+	err := s.db.View(func(kvTx *Tx) error {
+		var err error
+		kvTx.ScanPrefix(tkey(tenant, tblDeliveryEvents, endpointID), func(_ string, id []byte) bool {
+			var d record.WebhookDelivery
+			var ok bool
+			if ok, err = getJSON(kvTx, tkey(tenant, tblDeliveries, string(id)), &d); err != nil {
+				return false
+			}
+			if ok && (status == "" || d.Status == status) {
+				deliveries = append(deliveries, d)
+			}
+			return true
+		})
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	sort.SliceStable(deliveries, func(i, j int) bool {
+		if !deliveries[i].CreatedAt.Equal(deliveries[j].CreatedAt) {
+			return deliveries[i].CreatedAt.After(deliveries[j].CreatedAt)
+		}
+		return deliveries[i].ID > deliveries[j].ID
+	})
+	if len(deliveries) > limit {
+		deliveries = deliveries[:limit]
+	}
+	return deliveries, nil
+}
+
+// GetWebhookDelivery returns a delivery by ID, or nil.
+func (s *Store) GetWebhookDelivery(ctx context.Context, id string) (*record.WebhookDelivery, error) {
+	return getRecord[record.WebhookDelivery](s, tkey(tenantOf(ctx), tblDeliveries, id))
+}
+
+// SaveWebhookDelivery records the outcome of a delivery attempt.
+func (s *Store) SaveWebhookDelivery(ctx context.Context, d record.WebhookDelivery) error {
+	tenant := tenantOf(ctx)
+	return s.db.Update(func(kvTx *Tx) error {
+		var existing record.WebhookDelivery
+		if ok, err := getJSON(kvTx, tkey(tenant, tblDeliveries, d.ID), &existing); err != nil || !ok {
+			return err
+		}
+		updated := existing
+		updated.Status = d.Status
+		updated.Attempts = d.Attempts
+		updated.NextAttemptAt = truncated(d.NextAttemptAt)
+		updated.LastStatusCode = d.LastStatusCode
+		updated.LastError = d.LastError
+		updated.DeliveredAt = truncated(d.DeliveredAt)
+		return putDelivery(kvTx, tenant, &existing, updated)
+	})
+}
+
+// truncated returns t at the precision store/sqlite keeps.
+func truncated(t *time.Time) *time.Time {
+	if t == nil {
+		return nil
+	}
+	u := t.UTC().Truncate(time.Second)
+	return &u
+}
+
+// ReplayWebhookDeliveries queues deliveries to an endpoint again, with
+// fresh attempts. Without since, every dead delivery is requeued. With
+// since, every event the endpoint accepts from that time on is queued,
+// including events from before the endpoint existed. Returns the number
+// of deliveries queued.
+func (s *Store) ReplayWebhookDeliveries(ctx context.Context, endpointID string, since *time.Time) (int, error) {
+	tenant := tenantOf(ctx)
+	queued := 0
+	err := s.db.Update(func(kvTx *Tx) error {
+		at := now()
+		requeue := func(d record.WebhookDelivery) error {
+			old := d
+			d.Status = record.DeliveryPending
+			d.Attempts = 0
+			d.NextAttemptAt = &at
+			d.LastError = ""
+			queued++
+			return putDelivery(kvTx, tenant, &old, d)
+		}
+
+		if since == nil {
+			var dead []record.WebhookDelivery
+			for _, k := range scanKeys(kvTx, tkey(tenant, tblDeliveryEvents, endpointID)) {
+				id, _ := kvTx.Get(k)
+				var d record.WebhookDelivery
+				if ok, err := getJSON(kvTx, tkey(tenant, tblDeliveries, string(id)), &d); err != nil {
+					return err
+				} else if ok && d.Status == record.DeliveryDead {
+					dead = append(dead, d)
+				}
+			}
+			for _, d := range dead {
+				if err := requeue(d); err != nil {
+					return err
+				}
+			}
+			return nil
+		}
+
+		var endpoint record.WebhookEndpoint
+		if ok, err := getJSON(kvTx, tkey(tenant, tblEndpoints, endpointID), &endpoint); err != nil || !ok {
+			return err
+		}
+		events, err := scanJSON[record.OutboxEvent](kvTx, tkey(tenant, tblOutbox))
+		if err != nil {
+			return err
+		}
+		for _, event := range events {
+			if event.CreatedAt.Before(since.UTC().Truncate(time.Second)) || !endpoint.Accepts(event.Type) {
+				continue
+			}
+			if id, ok := kvTx.Get(tkey(tenant, tblDeliveryEvents, endpointID, event.ID)); ok {
+				var d record.WebhookDelivery
+				if ok, err := getJSON(kvTx, tkey(tenant, tblDeliveries, string(id)), &d); err != nil {
+					return err
+				} else if ok {
+					if err := requeue(d); err != nil {
+						return fmt.Errorf("failed to queue delivery: %w", err)
+					}
+					continue
+				}
+			}
+			if err := requeue(record.WebhookDelivery{
+				ID:         record.NewEventID("dlv"),
+				EndpointID: endpointID,
+				EventID:    event.ID,
+				EventType:  event.Type,
+				CreatedAt:  at,
+			}); err != nil {
+				return fmt.Errorf("failed to queue delivery: %w", err)
+			}
+		}
+		return nil
+	})
+	return queued, err
+}
+
+// RequeueWebhookDelivery queues one delivery again with fresh attempts,
+// whatever its status. Returns false if it does not exist.
+func (s *Store) RequeueWebhookDelivery(ctx context.Context, id string) (bool, error) {
+	tenant := tenantOf(ctx)
+	var found bool
+	err := s.db.Update(func(kvTx *Tx) error {
+		var d record.WebhookDelivery
+		ok, err := getJSON(kvTx, tkey(tenant, tblDeliveries, id), &d)
+		if err != nil || !ok {
+			return err
+		}
+		found = true
+		updated := d
+		at := now()
+		updated.Status = record.DeliveryPending
+		updated.Attempts = 0
+		updated.NextAttemptAt = &at
+		updated.LastError = ""
+		return putDelivery(kvTx, tenant, &d, updated)
+	})
+	return found, err
+}
+
+// =============================================================================
+// IDEMPOTENCY KEY STORE
+// =============================================================================
+
+// ReserveIdempotencyKey claims a key for a request about to run. If the
+// key was already claimed within ttl, nothing is written and the existing
+// record is returned (in progress or completed). Older records are
+// purged first, so an expired key starts over.
+func (s *Store) ReserveIdempotencyKey(ctx context.Context, rec record.IdempotencyRecord, ttl time.Duration) (*record.IdempotencyRecord, error) {
+	tenant := tenantOf(ctx)
+	var existing *record.IdempotencyRecord
+	err := s.db.Update(func(kvTx *Tx) error {
+		at := now()
+
+		// Expired keys of every tenant, oldest first
+		var expired []string
+		kvTx.Scan(gkey(tblIdemCreated), gkey(tblIdemCreated, timeKey(at.Add(-ttl))), func(k string, _ []byte) bool {
+			expired = append(expired, k)
+			return true
+		})
+		for _, k := range expired {
+			parts := components(k)
+			if len(parts) != 6 {
+				kvTx.Delete(k)
+				continue
+			}
+			kvTx.Delete(k)
+			kvTx.Delete(tkey(parts[3], tblIdempotency, parts[4], parts[5]))
+		}
+
+		var found record.IdempotencyRecord
+		if ok, err := getJSON(kvTx, tkey(tenant, tblIdempotency, rec.Actor, rec.Key), &found); err != nil {
+			return err
+		} else if ok {
+			existing = &found
+			return nil
+		}
+
+		rec.StatusCode = 0
+		rec.ContentType = ""
+		rec.Body = nil
+		rec.CreatedAt = at
+		rec.CompletedAt = nil
+		kvTx.Put(gkey(tblIdemCreated, timeKey(at), tenant, rec.Actor, rec.Key), nil)
+		if err := putJSON(kvTx, tkey(tenant, tblIdempotency, rec.Actor, rec.Key), rec); err != nil {
+			return fmt.Errorf("failed to reserve idempotency key: %w", err)
+		}
+		return nil
+	})
+	return existing, err
+}
+
+// components decodes every component of a key.
+func components(k string) []string {
+	parts := strings.Split(strings.TrimSuffix(k, "\x00\x01"), "\x00\x01")
+	for i, p := range parts {
+		parts[i] = strings.ReplaceAll(p, "\x00\xff", "\x00")
+	}
+	return parts
+}
+
+// CompleteIdempotencyKey stores the response of a reserved key.
+func (s *Store) CompleteIdempotencyKey(ctx context.Context, rec record.IdempotencyRecord) error {
+	tenant := tenantOf(ctx)
+	return s.db.Update(func(kvTx *Tx) error {
+		k := tkey(tenant, tblIdempotency, rec.Actor, rec.Key)
+		var existing record.IdempotencyRecord
+		if ok, err := getJSON(kvTx, k, &existing); err != nil || !ok {
+			return err
+		}
+		at := now()
+		existing.StatusCode = rec.StatusCode
+		existing.ContentType = rec.ContentType
+		existing.Body = rec.Body
+		existing.CompletedAt = &at
+		return putJSON(kvTx, k, existing)
+	})
+}
+
+// ReleaseIdempotencyKey forgets a reserved key, so the request can be
+// retried (e.g. after a server error).
+func (s *Store) ReleaseIdempotencyKey(ctx context.Context, actor, key string) error {
+	tenant := tenantOf(ctx)
+	return s.db.Update(func(kvTx *Tx) error {
+		var rec record.IdempotencyRecord
+		if ok, err := getJSON(kvTx, tkey(tenant, tblIdempotency, actor, key), &rec); err != nil || !ok {
+			return err
+		}
+		deleteIdempotencyKey(kvTx, tenant, rec)
+		return nil
+	})
+}
+
+func deleteIdempotencyKey(kvTx *Tx, tenant string, rec record.IdempotencyRecord) {
+	kvTx.Delete(gkey(tblIdemCreated, timeKey(rec.CreatedAt), tenant, rec.Actor, rec.Key))
+	kvTx.Delete(tkey(tenant, tblIdempotency, rec.Actor, rec.Key))
+}
+
+// =============================================================================
+// LEASES
+// =============================================================================
+
+// AcquireLease takes the named lease for holder, or renews it if holder
+// already has it, and reports whether holder now holds it. A lease held
+// by someone else is only taken once it has expired.
+func (s *Store) AcquireLease(ctx context.Context, name, holder string, ttl time.Duration) (bool, error) {
+	var held bool
+	err := s.db.Update(func(kvTx *Tx) error {
+		at := now()
+		k := gkey(tblLeases, name)
+		lease := record.Lease{Name: name, Holder: holder, AcquiredAt: at}
+		var existing record.Lease
+		if ok, err := getJSON(kvTx, k, &existing); err != nil {
+			return err
+		} else if ok {
+			if !existing.Available(holder, at) {
+				return nil
+			}
+			if existing.Holder == holder {
+				lease.AcquiredAt = existing.AcquiredAt
+			}
+		}
+		lease.RenewedAt = at
+		lease.ExpiresAt = at.Add(ttl)
+		held = true
+		return putJSON(kvTx, k, lease)
+	})
+	return held, err
+}
+
+// ReleaseLease gives up the named lease if holder has it, so another
+// process can take it without waiting for it to expire.
+func (s *Store) ReleaseLease(ctx context.Context, name, holder string) error {
+	return s.db.Update(func(kvTx *Tx) error {
+		k := gkey(tblLeases, name)
+		var existing record.Lease
+		if ok, err := getJSON(kvTx, k, &existing); err != nil || !ok || existing.Holder != holder {
+			return err
+		}
+		kvTx.Delete(k)
+		return nil
+	})
+}
+
+// GetLease returns the named lease, or nil if nobody has taken it.
+func (s *Store) GetLease(ctx context.Context, name string) (*record.Lease, error) {
+	var l record.Lease
+	var found bool
+	err := s.db.View(func(kvTx *Tx) (err error) {
+		found, err = getJSON(kvTx, gkey(tblLeases, name), &l)
+		return err
+	})
+	if err != nil || !found {
+		return nil, err
+	}
+	return &l, nil
+}
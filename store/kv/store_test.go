@@ -0,0 +1,20 @@
+package kv_test
+
+import (
+	"testing"
+
+	"github.com/warp/resource-engine/generic"
+	"github.com/warp/resource-engine/generic/store/storetest"
+	"github.com/warp/resource-engine/store/kv"
+)
+
+func TestStore_Conformance(t *testing.T) {
+	storetest.Run(t, func(t *testing.T) generic.Store {
+		s, err := kv.New(t.TempDir() + "/storetest.kv")
+		if err != nil {
+			t.Fatalf("Failed to create store: %v", err)
+		}
+		t.Cleanup(func() { s.Close() })
+		return s
+	})
+}
@@ -0,0 +1,248 @@
+/*
+adapters.go - generic.AssignmentStore and generic.SnapshotStore over records
+
+PURPOSE:
+  Backends persist assignments and snapshots as records with JSON columns
+  (AssignmentRecord.ApprovalConfigJSON, SnapshotRecord.BalanceJSON). The
+  generic services (RequestService, ResourceBalanceCalculator,
+  PeriodManager) want generic.PolicyAssignment and generic.Snapshot. These
+  adapters convert between the two, so the services run directly on any
+  backend.
+
+  Both interfaces have a Save method, so one store type cannot implement
+  both; backends return the adapters from Assignments and Snapshots.
+
+POLICIES:
+  An assignment record only holds a policy ID. GetByEntity resolves it
+  with a PolicyResolver (the API passes its policy cache) and skips
+  assignments whose policy is unknown.
+
+JSON FORMATS:
+  ApprovalConfigJSON:  {"requires_approval":true,"auto_approve_up_to":1}
+                       "" when the assignment needs no approval
+  BalanceJSON:         {"balance":{...},"taken_at":"...","reason":"..."}
+
+SEE ALSO:
+  - generic/assignment.go: AssignmentStore
+  - generic/snapshot.go: SnapshotStore, PeriodManager
+*/
+package record
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/shopspring/decimal"
+	"github.com/warp/resource-engine/generic"
+)
+
+// PolicyResolver looks up a policy by ID, reporting whether it exists.
+type PolicyResolver func(ctx context.Context, id generic.PolicyID) (*generic.Policy, bool)
+
+// =============================================================================
+// ASSIGNMENT ADAPTER
+// =============================================================================
+
+// AssignmentRecords is the part of a backend the assignment adapter uses.
+type AssignmentRecords interface {
+	SaveAssignment(ctx context.Context, a AssignmentRecord) error
+	GetAssignmentsByEntity(ctx context.Context, entityID string) ([]AssignmentRecord, error)
+}
+
+// NewAssignmentStore exposes assignment records as a generic.AssignmentStore.
+func NewAssignmentStore(records AssignmentRecords, policies PolicyResolver) generic.AssignmentStore {
+	return &assignmentStore{records: records, policies: policies}
+}
+
+type assignmentStore struct {
+	records  AssignmentRecords
+	policies PolicyResolver
+}
+
+// approvalConfigJSON is the stored form of generic.ApprovalConfig.
+type approvalConfigJSON struct {
+	RequiresApproval bool             `json:"requires_approval"`
+	AutoApproveUpTo  *decimal.Decimal `json:"auto_approve_up_to,omitempty"`
+	ApproverRoles    []string         `json:"approver_roles,omitempty"`
+}
+
+func (as *assignmentStore) Save(ctx context.Context, a generic.PolicyAssignment) error {
+	rec := AssignmentRecord{
+		ID:                  a.ID,
+		EntityID:            string(a.EntityID),
+		PolicyID:            string(a.PolicyID),
+		EffectiveFrom:       a.EffectiveFrom.Time,
+		ConsumptionPriority: a.ConsumptionPriority,
+	}
+	if a.EffectiveTo != nil {
+		t := a.EffectiveTo.Time
+		rec.EffectiveTo = &t
+	}
+
+	ac := a.ApprovalConfig
+	if ac.RequiresApproval || ac.AutoApproveUpTo != nil || len(ac.ApproverRoles) > 0 {
+		stored := approvalConfigJSON{RequiresApproval: ac.RequiresApproval, ApproverRoles: ac.ApproverRoles}
+		if ac.AutoApproveUpTo != nil {
+			stored.AutoApproveUpTo = &ac.AutoApproveUpTo.Value
+		}
+		b, err := json.Marshal(stored)
+		if err != nil {
+			return err
+		}
+		rec.ApprovalConfigJSON = string(b)
+	}
+
+	return as.records.SaveAssignment(ctx, rec)
+}
+
+func (as *assignmentStore) GetByEntity(ctx context.Context, entityID generic.EntityID) ([]generic.PolicyAssignment, error) {
+	records, err := as.records.GetAssignmentsByEntity(ctx, string(entityID))
+	if err != nil {
+		return nil, err
+	}
+
+	var assignments []generic.PolicyAssignment
+	for _, rec := range records {
+		policy, ok := as.policies(ctx, generic.PolicyID(rec.PolicyID))
+		if !ok {
+			continue
+		}
+		a := generic.PolicyAssignment{
+			ID:                  rec.ID,
+			EntityID:            entityID,
+			PolicyID:            policy.ID,
+			Policy:              *policy,
+			EffectiveFrom:       generic.TimePoint{Time: rec.EffectiveFrom},
+			ConsumptionPriority: rec.ConsumptionPriority,
+		}
+		if rec.EffectiveTo != nil {
+			a.EffectiveTo = &generic.TimePoint{Time: *rec.EffectiveTo}
+		}
+		if rec.ApprovalConfigJSON != "" {
+			var stored approvalConfigJSON
+			if err := json.Unmarshal([]byte(rec.ApprovalConfigJSON), &stored); err != nil {
+				return nil, fmt.Errorf("assignment %s: approval config: %w", rec.ID, err)
+			}
+			a.ApprovalConfig = generic.ApprovalConfig{
+				RequiresApproval: stored.RequiresApproval,
+				ApproverRoles:    stored.ApproverRoles,
+			}
+			if stored.AutoApproveUpTo != nil {
+				a.ApprovalConfig.AutoApproveUpTo = &generic.Amount{Value: *stored.AutoApproveUpTo, Unit: policy.Unit}
+			}
+		}
+		assignments = append(assignments, a)
+	}
+	return assignments, nil
+}
+
+func (as *assignmentStore) GetByEntityAndResource(ctx context.Context, entityID generic.EntityID, resourceType generic.ResourceType) ([]generic.PolicyAssignment, error) {
+	all, err := as.GetByEntity(ctx, entityID)
+	if err != nil {
+		return nil, err
+	}
+
+	var filtered []generic.PolicyAssignment
+	for _, a := range all {
+		if a.Policy.ResourceType.ResourceID() == resourceType.ResourceID() {
+			filtered = append(filtered, a)
+		}
+	}
+	return filtered, nil
+}
+
+func (as *assignmentStore) GetActive(ctx context.Context, entityID generic.EntityID, at generic.TimePoint) ([]generic.PolicyAssignment, error) {
+	all, err := as.GetByEntity(ctx, entityID)
+	if err != nil {
+		return nil, err
+	}
+
+	var active []generic.PolicyAssignment
+	for _, a := range all {
+		if a.IsActive(at) {
+			active = append(active, a)
+		}
+	}
+	return active, nil
+}
+
+// =============================================================================
+// SNAPSHOT ADAPTER
+// =============================================================================
+
+// SnapshotRecords is the part of a backend the snapshot adapter uses.
+type SnapshotRecords interface {
+	SaveSnapshot(ctx context.Context, snap SnapshotRecord) error
+	GetSnapshot(ctx context.Context, entityID, policyID string, periodStart, periodEnd time.Time) (*SnapshotRecord, error)
+	GetLatestSnapshot(ctx context.Context, entityID, policyID string) (*SnapshotRecord, error)
+}
+
+// NewSnapshotStore exposes snapshot records as a generic.SnapshotStore.
+func NewSnapshotStore(records SnapshotRecords) generic.SnapshotStore {
+	return &snapshotStore{records: records}
+}
+
+type snapshotStore struct {
+	records SnapshotRecords
+}
+
+// snapshotJSON is the stored form of a snapshot's BalanceJSON.
+type snapshotJSON struct {
+	Balance generic.Balance        `json:"balance"`
+	TakenAt time.Time              `json:"taken_at"`
+	Reason  generic.SnapshotReason `json:"reason"`
+}
+
+func (ss *snapshotStore) Save(ctx context.Context, snap generic.Snapshot) error {
+	b, err := json.Marshal(snapshotJSON{Balance: snap.Balance, TakenAt: snap.TakenAt.Time, Reason: snap.Reason})
+	if err != nil {
+		return err
+	}
+	return ss.records.SaveSnapshot(ctx, SnapshotRecord{
+		ID:          snap.ID,
+		EntityID:    string(snap.EntityID),
+		PolicyID:    string(snap.PolicyID),
+		PeriodStart: snap.Period.Start.Time,
+		PeriodEnd:   snap.Period.End.Time,
+		BalanceJSON: string(b),
+	})
+}
+
+func (ss *snapshotStore) Get(ctx context.Context, entityID generic.EntityID, policyID generic.PolicyID, period generic.Period) (*generic.Snapshot, error) {
+	rec, err := ss.records.GetSnapshot(ctx, string(entityID), string(policyID), period.Start.Time, period.End.Time)
+	if err != nil || rec == nil {
+		return nil, err
+	}
+	return snapshotFromRecord(*rec)
+}
+
+func (ss *snapshotStore) GetLatest(ctx context.Context, entityID generic.EntityID, policyID generic.PolicyID) (*generic.Snapshot, error) {
+	rec, err := ss.records.GetLatestSnapshot(ctx, string(entityID), string(policyID))
+	if err != nil || rec == nil {
+		return nil, err
+	}
+	return snapshotFromRecord(*rec)
+}
+
+func snapshotFromRecord(rec SnapshotRecord) (*generic.Snapshot, error) {
+	var stored snapshotJSON
+	if err := json.Unmarshal([]byte(rec.BalanceJSON), &stored); err != nil {
+		return nil, fmt.Errorf("snapshot %s: balance: %w", rec.ID, err)
+	}
+	period := generic.Period{
+		Start: generic.TimePoint{Time: rec.PeriodStart},
+		End:   generic.TimePoint{Time: rec.PeriodEnd},
+	}
+	stored.Balance.Period = period
+	return &generic.Snapshot{
+		ID:       rec.ID,
+		EntityID: generic.EntityID(rec.EntityID),
+		PolicyID: generic.PolicyID(rec.PolicyID),
+		Period:   period,
+		TakenAt:  generic.TimePoint{Time: stored.TakenAt},
+		Balance:  stored.Balance,
+		Reason:   stored.Reason,
+	}, nil
+}
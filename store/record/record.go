@@ -0,0 +1,513 @@
+/*
+Package record defines the rows every storage backend persists.
+
+PURPOSE:
+  The API stores more than the generic ledger: policies, employees,
+  assignments, requests, holidays, webhooks and so on. These record types
+  are shared by all backends (store/sqlite, store/kv), so the API can run
+  on either without converting between them. The rules both backends must
+  apply identically (outbox payloads, holiday import IDs, which calendar's
+  holidays apply to an employee) live here too.
+
+RECORDS:
+  Tenant                   Company hosted on the server
+  PolicyRecord             Policy with its JSON config (versioned)
+  Employee                 Entity record (team, manager, hire date)
+  AssignmentRecord         Entity-to-policy link (effective-dated)
+  SnapshotRecord           Cached period balance
+  PayRate                  Effective-dated pay rate (liability reporting)
+  CalendarFeed             Token for an iCalendar subscription
+  HolidayCalendarRecord    Named holiday calendar (one per office)
+  EmployeeHolidayCalendar  Effective-dated calendar per employee
+  Request                  Time-off request (approval workflow)
+  ReconciliationRun        Period-end rollover for one entity and policy
+  ReconciliationBatch      Rollover of every entity, by a worker pool
+  OutboxEvent              Ledger event, written with the ledger rows
+  WebhookEndpoint          Registered webhook URL and its event filter
+  WebhookDelivery          One event sent to one endpoint
+  IdempotencyRecord        Stored response for an Idempotency-Key header
+  Lease                    Named lock with an expiry (scheduler leader)
+
+SEE ALSO:
+  - store/sqlite/sqlite.go: SQLite backend
+  - store/kv/store.go: Embedded key-value backend
+*/
+package record
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/shopspring/decimal"
+	"github.com/warp/resource-engine/generic"
+)
+
+// =============================================================================
+// TENANTS, POLICIES, EMPLOYEES
+// =============================================================================
+
+// Tenant is a company hosted on the server. The default tenant ("") is
+// implicit and has no record.
+type Tenant struct {
+	ID        generic.TenantID
+	Name      string
+	CreatedAt time.Time
+}
+
+// PolicyRecord is a stored policy with its JSON config.
+type PolicyRecord struct {
+	ID           string
+	Name         string
+	ResourceType string
+	ConfigJSON   string
+	Version      int
+	CreatedAt    time.Time
+	UpdatedAt    time.Time
+}
+
+// Employee represents an employee record.
+type Employee struct {
+	ID        string
+	Name      string
+	Email     string
+	Team      string
+	ManagerID string // Employee ID of direct manager, "" if none
+	HireDate  time.Time
+	CreatedAt time.Time
+}
+
+// AssignmentRecord is a stored policy assignment.
+type AssignmentRecord struct {
+	ID                  string
+	EntityID            string
+	PolicyID            string
+	EffectiveFrom       time.Time
+	EffectiveTo         *time.Time
+	ConsumptionPriority int
+	ApprovalConfigJSON  string
+	CreatedAt           time.Time
+}
+
+// SnapshotRecord is a stored balance snapshot.
+type SnapshotRecord struct {
+	ID          string
+	EntityID    string
+	PolicyID    string
+	PeriodStart time.Time
+	PeriodEnd   time.Time
+	BalanceJSON string
+	CreatedAt   time.Time
+}
+
+// PayRate is an effective-dated pay rate for an employee.
+// A rate applies from EffectiveFrom until the next rate's EffectiveFrom.
+// Rates are append-only, so a past liability report keeps its values:
+// a correction is a new rate with a later EffectiveFrom.
+type PayRate struct {
+	ID            string
+	EntityID      string
+	Rate          decimal.Decimal // Money per Unit
+	Currency      string          // ISO 4217, e.g. "USD"
+	Unit          generic.Unit    // days or hours
+	HoursPerDay   float64         // For converting between days and hours
+	EffectiveFrom time.Time
+	CreatedAt     time.Time
+}
+
+// ErrPayRateExists is returned when an employee already has a rate with
+// the same EffectiveFrom.
+var ErrPayRateExists = errors.New("pay rate already exists for this effective date")
+
+// CalendarFeed is a token granting read access to an iCalendar feed.
+// The token is the only credential, so it must be unguessable.
+type CalendarFeed struct {
+	Token     string
+	TenantID  generic.TenantID // Set on read; the owner's tenant
+	OwnerID   string           // Employee who created the feed
+	Scope     string           // self, team, reports
+	CreatedAt time.Time
+	RevokedAt *time.Time
+}
+
+// =============================================================================
+// HOLIDAYS
+// =============================================================================
+
+// Holiday import outcomes, per row.
+const (
+	HolidayImportCreated   = "created"
+	HolidayImportDuplicate = "duplicate"   // Already stored, or repeated in the file
+	HolidayImportOutOfYear = "out_of_year" // Outside ReplaceYear
+)
+
+// HolidayImportOptions controls ImportHolidays.
+type HolidayImportOptions struct {
+	// CalendarID loads the file into a named calendar. Empty means
+	// company-wide holidays.
+	CalendarID string
+	// ReplaceYear, if non-zero, deletes the calendar's holidays dated in that
+	// year before inserting, and rejects rows dated in any other year.
+	ReplaceYear int
+	// DryRun runs the import inside a transaction and rolls it back, so the
+	// counts are exactly what a real import would produce.
+	DryRun bool
+}
+
+// HolidayImportItem is the outcome for one parsed holiday.
+type HolidayImportItem struct {
+	Holiday generic.Holiday
+	Status  string
+}
+
+// HolidayImportResult summarizes an import.
+type HolidayImportResult struct {
+	Created    int
+	Duplicates int
+	OutOfYear  int
+	Removed    int // Rows deleted by ReplaceYear
+	Items      []HolidayImportItem
+}
+
+// HolidayCalendarRecord is a named holiday calendar, typically one per
+// office or location.
+type HolidayCalendarRecord struct {
+	ID        string // e.g. "us", "uk", "fr-paris"
+	CompanyID string
+	Name      string
+	CreatedAt time.Time
+}
+
+// EmployeeHolidayCalendar assigns a calendar to an employee from
+// EffectiveFrom until the next assignment's EffectiveFrom.
+type EmployeeHolidayCalendar struct {
+	ID            string
+	EntityID      string
+	CalendarID    string
+	EffectiveFrom time.Time
+	CreatedAt     time.Time
+}
+
+// HolidayImportID derives a stable ID from a holiday's unique key
+// (company, calendar, date and name), so re-importing a file is a no-op.
+func HolidayImportID(companyID, calendarID, date, name string) string {
+	sum := sha256.Sum256([]byte(companyID + "|" + calendarID + "|" + date + "|" + name))
+	return "holiday-" + hex.EncodeToString(sum[:6])
+}
+
+// HolidayRuleText returns the stored form of a holiday's rule ("" if none).
+func HolidayRuleText(h generic.Holiday) string {
+	if h.Rule == nil {
+		return ""
+	}
+	return h.Rule.String()
+}
+
+// ContainsHolidayOn reports whether any holiday falls on date.
+func ContainsHolidayOn(holidays []generic.Holiday, date generic.TimePoint) bool {
+	day := date.Time.Format("2006-01-02")
+	for _, h := range holidays {
+		if h.Date.Time.Format("2006-01-02") == day {
+			return true
+		}
+	}
+	return false
+}
+
+// EntityCalendars resolves which calendar applies to an entity on a date.
+type EntityCalendars struct {
+	History   []EmployeeHolidayCalendar // Oldest first
+	Companies map[string]string         // Calendar ID -> company ID
+}
+
+// Applies reports whether a holiday (on its resolved date) applies to the
+// entity: global holidays always do; company-wide and calendar holidays
+// only while the entity is assigned to a calendar of that company.
+func (ec *EntityCalendars) Applies(h generic.Holiday) bool {
+	if h.CompanyID == "" && h.CalendarID == "" {
+		return true
+	}
+
+	calendarID := ""
+	for _, a := range ec.History {
+		if a.EffectiveFrom.After(h.Date.Time) {
+			break
+		}
+		calendarID = a.CalendarID
+	}
+	if calendarID == "" {
+		return false
+	}
+
+	company, ok := ec.Companies[calendarID]
+	if !ok || h.CompanyID != company {
+		return false
+	}
+	return h.CalendarID == "" || h.CalendarID == calendarID
+}
+
+// =============================================================================
+// REQUESTS AND RECONCILIATION RUNS
+// =============================================================================
+
+// Request represents a time-off request in storage.
+type Request struct {
+	ID               string
+	EntityID         string
+	ResourceType     string
+	EffectiveAt      time.Time
+	Amount           float64
+	Unit             string
+	Status           string // pending, approved, rejected, cancelled
+	RequiresApproval bool
+	ApprovedBy       string
+	ApprovedAt       *time.Time
+	RejectionReason  string
+	Reason           string
+	DistributionJSON string
+	CreatedAt        time.Time
+	UpdatedAt        time.Time
+}
+
+// ReconciliationRun represents a scheduled/completed reconciliation.
+type ReconciliationRun struct {
+	ID          string
+	BatchID     string // ReconciliationBatch that ran it ("" before batches)
+	PolicyID    string
+	EntityID    string
+	PeriodStart time.Time
+	PeriodEnd   time.Time
+	Status      string // pending, running, completed, failed, rolled_back
+	CarriedOver float64
+	Expired     float64
+	Error       string
+	StartedAt   *time.Time
+	CompletedAt *time.Time
+	CreatedAt   time.Time
+
+	RolledBackAt *time.Time // When its transactions were reversed
+
+	// Recomputations after backdated transactions (api/rereconcile.go)
+	Corrections   int        // How many times the completed period was corrected
+	CorrectedAt   *time.Time // Latest correction
+	CorrectedBy   string     // Backdated transaction that caused the latest correction
+	CorrectedFrom string     // Run of the earlier period whose correction cascaded here ("" for the first)
+}
+
+// Reconciliation batch statuses.
+const (
+	BatchRunning    = "running"     // Workers are processing entities
+	BatchCompleted  = "completed"   // Every entity processed (some may have failed)
+	BatchCancelled  = "cancelled"   // Stopped early; resume continues after Checkpoint
+	BatchRolledBack = "rolled_back" // Every completed item rolled back
+)
+
+// ReconciliationBatch is one reconciliation of every entity with ended,
+// unreconciled periods, run by a pool of workers. Its items are the
+// ReconciliationRuns with its ID. Entities are processed in ID order;
+// every entity up to Checkpoint is done, so a resumed batch starts after it.
+type ReconciliationBatch struct {
+	ID          string
+	Trigger     string    // scheduler or api
+	Status      string    // running, completed, cancelled
+	AsOf        time.Time // Periods ending before this day are reconciled
+	Workers     int
+	Total       int // Entities with work when the batch was planned
+	Processed   int // Entities done
+	Reconciled  int // Periods reconciled
+	Failed      int // Periods (or entities) that failed
+	CarriedOver float64
+	Expired     float64
+	Checkpoint  string // Last entity ID such that every entity up to it is done
+	Error       string // Last failure, for the summary
+	CreatedAt   time.Time
+	UpdatedAt   time.Time
+	CompletedAt *time.Time
+}
+
+// =============================================================================
+// OUTBOX AND WEBHOOKS
+// =============================================================================
+
+// Webhook delivery statuses.
+const (
+	DeliveryPending   = "pending"   // Waiting for its next attempt
+	DeliveryDelivered = "delivered" // Endpoint answered 2xx
+	DeliveryDead      = "dead"      // Out of attempts (dead letter); replay to retry
+)
+
+// OutboxEvent is a ledger event recorded with the transactions it describes.
+type OutboxEvent struct {
+	ID           string
+	Type         generic.EventType
+	Payload      string // JSON body sent to webhooks
+	CreatedAt    time.Time
+	DispatchedAt *time.Time // Set once deliveries were created
+}
+
+// WebhookEndpoint is a URL that receives ledger events.
+type WebhookEndpoint struct {
+	ID          string
+	URL         string
+	Secret      string              // HMAC-SHA256 key for signatures
+	EventTypes  []generic.EventType // Empty = all event types
+	Description string
+	Active      bool
+	CreatedAt   time.Time
+}
+
+// Accepts reports whether the endpoint subscribes to an event type.
+func (e WebhookEndpoint) Accepts(eventType generic.EventType) bool {
+	if len(e.EventTypes) == 0 {
+		return true
+	}
+	for _, t := range e.EventTypes {
+		if t == eventType {
+			return true
+		}
+	}
+	return false
+}
+
+// WebhookDelivery is one event sent (or to be sent) to one endpoint.
+type WebhookDelivery struct {
+	ID             string
+	EndpointID     string
+	EventID        string
+	EventType      generic.EventType
+	Status         string // DeliveryPending, DeliveryDelivered, DeliveryDead
+	Attempts       int
+	NextAttemptAt  *time.Time
+	LastStatusCode int
+	LastError      string
+	DeliveredAt    *time.Time
+	CreatedAt      time.Time
+
+	Payload string // Event body; set by DueWebhookDeliveries
+}
+
+// eventPayload is the JSON body of an outbox event.
+type eventPayload struct {
+	ID           string             `json:"id"`
+	Type         generic.EventType  `json:"type"`
+	TenantID     string             `json:"tenant_id,omitempty"`
+	OccurredAt   string             `json:"occurred_at"`
+	Actor        *eventActor        `json:"actor,omitempty"`
+	Data         map[string]any     `json:"data,omitempty"`
+	Transactions []eventTransaction `json:"transactions"`
+}
+
+type eventActor struct {
+	ID   string `json:"id"`
+	Type string `json:"type"`
+}
+
+type eventTransaction struct {
+	ID           string `json:"id"`
+	EntityID     string `json:"entity_id"`
+	PolicyID     string `json:"policy_id"`
+	ResourceType string `json:"resource_type"`
+	EffectiveAt  string `json:"effective_at"`
+	Delta        string `json:"delta"`
+	Unit         string `json:"unit"`
+	Type         string `json:"type"`
+	ReferenceID  string `json:"reference_id,omitempty"`
+	Reason       string `json:"reason,omitempty"`
+}
+
+// NewEventID returns a unique ID that sorts in creation order.
+func NewEventID(prefix string) string {
+	b := make([]byte, 4)
+	rand.Read(b)
+	return fmt.Sprintf("%s-%020d-%s", prefix, time.Now().UnixNano(), hex.EncodeToString(b))
+}
+
+// NewOutboxEvent builds the event for a batch of transactions, with the
+// context's tenant and actor. The backend stores it in the same write as
+// the transactions.
+func NewOutboxEvent(ctx context.Context, eventType generic.EventType, txs []generic.Transaction, data map[string]any) (OutboxEvent, error) {
+	now := time.Now().UTC()
+	payload := eventPayload{
+		ID:           NewEventID("evt"),
+		Type:         eventType,
+		TenantID:     string(generic.TenantFrom(ctx)),
+		OccurredAt:   now.Format(time.RFC3339),
+		Data:         data,
+		Transactions: make([]eventTransaction, len(txs)),
+	}
+	if actor, ok := generic.ActorFrom(ctx); ok {
+		payload.Actor = &eventActor{ID: actor.ID, Type: actor.Type}
+	}
+	for i, tx := range txs {
+		payload.Transactions[i] = eventTransaction{
+			ID:           string(tx.ID),
+			EntityID:     string(tx.EntityID),
+			PolicyID:     string(tx.PolicyID),
+			ResourceType: tx.ResourceType.ResourceID(),
+			EffectiveAt:  tx.EffectiveAt.Time.Format("2006-01-02"),
+			Delta:        tx.Delta.Value.String(),
+			Unit:         string(tx.Delta.Unit),
+			Type:         string(tx.Type),
+			ReferenceID:  tx.ReferenceID,
+			Reason:       tx.Reason,
+		}
+	}
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return OutboxEvent{}, fmt.Errorf("failed to encode event: %w", err)
+	}
+
+	createdAt, _ := time.Parse(time.RFC3339, payload.OccurredAt)
+	return OutboxEvent{
+		ID:        payload.ID,
+		Type:      eventType,
+		Payload:   string(body),
+		CreatedAt: createdAt,
+	}, nil
+}
+
+// =============================================================================
+// IDEMPOTENCY KEYS
+// =============================================================================
+
+// IdempotencyRecord is the stored outcome of a request sent with an
+// Idempotency-Key header. StatusCode is 0 while the request is in progress.
+type IdempotencyRecord struct {
+	Actor       string // Caller the key belongs to ("" without authentication)
+	Key         string
+	Method      string
+	Path        string
+	RequestHash string // Fingerprint of method, path and body
+	StatusCode  int
+	ContentType string
+	Body        []byte
+	CreatedAt   time.Time
+	CompletedAt *time.Time
+}
+
+// =============================================================================
+// LEASES
+// =============================================================================
+
+// Lease is a named lock with an expiry, held by one process at a time
+// (e.g. the reconciliation scheduler's leader lease). The holder renews it
+// before it expires; if the holder dies, another process takes it over
+// once it has expired. Leases are global, not per tenant.
+type Lease struct {
+	Name       string
+	Holder     string // Process holding the lease
+	AcquiredAt time.Time
+	RenewedAt  time.Time // Last heartbeat
+	ExpiresAt  time.Time
+}
+
+// Available reports whether holder may take or renew the lease at now:
+// it already holds it, or the lease has expired.
+func (l Lease) Available(holder string, now time.Time) bool {
+	return l.Holder == holder || !now.Before(l.ExpiresAt)
+}
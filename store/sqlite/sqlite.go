@@ -8,8 +8,11 @@ PURPOSE:
 
 INTERFACES IMPLEMENTED:
   generic.Store:           Transaction persistence
-  generic.AssignmentStore: Policy-to-entity mappings
-  generic.SnapshotStore:   Balance snapshots
+  generic.AssignmentStore: Policy-to-entity mappings (Assignments)
+  generic.SnapshotStore:   Balance snapshots (Snapshots)
+
+  AssignmentStore and SnapshotStore both have a Save method, so Store
+  returns them as adapters over its records (store/record/adapters.go).
 
 APPEND-ONLY ENFORCEMENT:
   The Store enforces append-only semantics:
@@ -18,14 +21,49 @@ APPEND-ONLY ENFORCEMENT:
   - Corrections via reversal transactions only
 
 KEY TABLES:
+  tenants:            Companies hosted on the server
   transactions:       Immutable ledger of all balance changes
   policies:           Policy definitions (versioned)
   policy_assignments: Entity-to-policy links
-  employees:          Entity records
+  employees:          Entity records (with team for reporting)
   balance_snapshots:  Cached balance calculations
+  pay_rates:          Effective-dated pay rates (liability reporting)
+  calendar_feeds:     Tokens for iCalendar subscriptions
+  holidays:           Holidays, global, per company or per named calendar
+  holiday_calendars:  Named calendars per office (us, uk, fr-paris)
+  employee_holiday_calendars: Effective-dated calendar per employee
+  outbox_events:      Ledger events, written with the ledger rows
+  webhook_endpoints:  Registered webhook URLs and their event filters
+  webhook_deliveries: One per event and endpoint (retries, dead letters)
+  reconciliation_batches: Batch rollovers (items in reconciliation_runs)
+  idempotency_keys:   Stored responses for Idempotency-Key headers
+  leases:             Named locks with expiry (scheduler leader)
+
+TENANCY:
+  Every table except tenants and leases has a tenant_id column, and every query
+  filters by the tenant of its context (generic.TenantFrom). Primary and
+  unique keys start with tenant_id, so two tenants can use the same IDs,
+  idempotency keys and days off without colliding. Calendar feed tokens
+  are the exception: they are globally unique and looked up across
+  tenants, since the token is how a feed request finds its tenant.
+
+  The generic.HolidayCalendar methods take no context and read the
+  default tenant; HolidayCalendarFor binds them to another tenant.
+
+AUDIT:
+  Appended transactions record who wrote them (created_by,
+  created_by_type), taken from the context's actor (generic.ActorFrom)
+  unless the transaction already names one.
+
+OUTBOX:
+  Every ledger append also inserts one outbox_events row in the same SQL
+  transaction (AppendBatchWithEvent; Append and AppendBatch record
+  transactions.posted). DispatchOutbox fans undispatched events out to a
+  webhook_deliveries row per matching endpoint; the API's webhook
+  dispatcher sends them and records retries and dead letters.
 
 INDEXES:
-  Critical indexes for performance:
+  Critical indexes for performance (all prefixed with tenant_id):
   - idx_transactions_entity_policy_date: Balance calculation (hot path)
   - idx_transactions_entity_resource_date: Day uniqueness checks
   - idx_unique_day_consumption: Enforces no duplicate day-off
@@ -52,13 +90,19 @@ USAGE:
   ledger := generic.NewLedger(store)
 
 MIGRATION:
-  Schema is auto-migrated on New(). For production, use a proper
-  migration tool (golang-migrate, goose) with versioned migrations.
+  Schema is auto-migrated on New(). Tables from before multi-tenancy are
+  rebuilt with tenant-scoped keys and their rows moved to the default
+  tenant. Columns added later are applied with addColumnIfMissing so
+  existing databases keep working. For production,
+  use a proper migration tool (golang-migrate, goose) with versioned
+  migrations.
 
 SEE ALSO:
   - generic/store.go: Interface definitions
   - generic/ledger.go: Higher-level ledger using Store
   - generic/store/memory.go: In-memory implementation for testing
+  - store/kv/store.go: Embedded key-value implementation (no CGO)
+  - store/record/record.go: Record types shared with store/kv
 */
 package sqlite
 
@@ -67,11 +111,64 @@ import (
 	"database/sql"
 	"encoding/json"
 	"fmt"
+	"strings"
 	"sync"
 	"time"
 
 	_ "github.com/mattn/go-sqlite3"
+	"github.com/shopspring/decimal"
 	"github.com/warp/resource-engine/generic"
+	"github.com/warp/resource-engine/store/record"
+)
+
+// Compile-time checks for the generic interfaces Store provides.
+var (
+	_ generic.EntityHolidayCalendar = (*Store)(nil)
+	_ generic.EntityStore           = (*Store)(nil)
+	_ generic.HolidayCalendar       = (*Store)(nil)
+	_ generic.TxStore               = (*Store)(nil)
+)
+
+// Record types are shared with the other backends (store/record).
+type (
+	Tenant                  = record.Tenant
+	PolicyRecord            = record.PolicyRecord
+	Employee                = record.Employee
+	AssignmentRecord        = record.AssignmentRecord
+	SnapshotRecord          = record.SnapshotRecord
+	PayRate                 = record.PayRate
+	CalendarFeed            = record.CalendarFeed
+	HolidayImportOptions    = record.HolidayImportOptions
+	HolidayImportItem       = record.HolidayImportItem
+	HolidayImportResult     = record.HolidayImportResult
+	HolidayCalendarRecord   = record.HolidayCalendarRecord
+	EmployeeHolidayCalendar = record.EmployeeHolidayCalendar
+	Request                 = record.Request
+	ReconciliationRun       = record.ReconciliationRun
+	ReconciliationBatch     = record.ReconciliationBatch
+	OutboxEvent             = record.OutboxEvent
+	WebhookEndpoint         = record.WebhookEndpoint
+	WebhookDelivery         = record.WebhookDelivery
+	IdempotencyRecord       = record.IdempotencyRecord
+	Lease                   = record.Lease
+	PolicyResolver          = record.PolicyResolver
+)
+
+// Holiday import outcomes, webhook delivery and reconciliation batch
+// statuses.
+const (
+	HolidayImportCreated   = record.HolidayImportCreated
+	HolidayImportDuplicate = record.HolidayImportDuplicate
+	HolidayImportOutOfYear = record.HolidayImportOutOfYear
+
+	DeliveryPending   = record.DeliveryPending
+	DeliveryDelivered = record.DeliveryDelivered
+	DeliveryDead      = record.DeliveryDead
+
+	BatchRunning    = record.BatchRunning
+	BatchCompleted  = record.BatchCompleted
+	BatchCancelled  = record.BatchCancelled
+	BatchRolledBack = record.BatchRolledBack
 )
 
 // Store implements all storage interfaces using SQLite.
@@ -87,6 +184,10 @@ func New(dbPath string) (*Store, error) {
 	if err != nil {
 		return nil, fmt.Errorf("failed to open database: %w", err)
 	}
+	if dbPath == ":memory:" {
+		// Every connection to :memory: is a separate, empty database
+		db.SetMaxOpenConns(1)
+	}
 
 	store := &Store{db: db}
 	if err := store.migrate(); err != nil {
@@ -104,10 +205,39 @@ func (s *Store) Close() error {
 
 // migrate creates the database schema.
 func (s *Store) migrate() error {
+	// Tables created before multi-tenancy have no tenant_id and single-column
+	// keys. CREATE TABLE IF NOT EXISTS would leave them as they are, so they
+	// are moved aside, recreated from the schema below and copied back
+	// into the default tenant.
+	legacy, err := s.tablesWithoutColumn("tenant_id")
+	if err != nil {
+		return err
+	}
+
+	sqlTx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer sqlTx.Rollback()
+
+	for _, table := range legacy {
+		if err := renameLegacyTable(sqlTx, table); err != nil {
+			return err
+		}
+	}
+
 	schema := `
+	-- Tenants (one company each; tenant '' is the default and is implicit)
+	CREATE TABLE IF NOT EXISTS tenants (
+		id TEXT PRIMARY KEY,
+		name TEXT NOT NULL,
+		created_at TEXT NOT NULL
+	);
+
 	-- Transactions (append-only ledger)
 	CREATE TABLE IF NOT EXISTS transactions (
-		id TEXT PRIMARY KEY,
+		tenant_id TEXT NOT NULL DEFAULT '',
+		id TEXT NOT NULL,
 		entity_id TEXT NOT NULL,
 		policy_id TEXT NOT NULL,
 		resource_type TEXT NOT NULL,
@@ -117,119 +247,161 @@ func (s *Store) migrate() error {
 		tx_type TEXT NOT NULL,
 		reference_id TEXT,
 		reason TEXT,
-		idempotency_key TEXT UNIQUE,
+		idempotency_key TEXT,
 		metadata_json TEXT,
-		created_at TEXT NOT NULL
+		created_by TEXT,              -- Actor who wrote the transaction
+		created_by_type TEXT,         -- employee, manager, admin, system
+		created_at TEXT NOT NULL,
+		PRIMARY KEY (tenant_id, id)
 	);
 
 	CREATE INDEX IF NOT EXISTS idx_transactions_entity_policy 
-		ON transactions(entity_id, policy_id);
+		ON transactions(tenant_id, entity_id, policy_id);
 	CREATE INDEX IF NOT EXISTS idx_transactions_effective_at 
-		ON transactions(effective_at);
-	CREATE INDEX IF NOT EXISTS idx_transactions_idempotency 
-		ON transactions(idempotency_key) WHERE idempotency_key IS NOT NULL;
+		ON transactions(tenant_id, effective_at);
+	CREATE UNIQUE INDEX IF NOT EXISTS idx_transactions_idempotency 
+		ON transactions(tenant_id, idempotency_key) WHERE idempotency_key IS NOT NULL;
 
 	-- CRITICAL: Enforce day uniqueness for time-off consumption
 	-- An entity cannot have two consumption/pending transactions on the same day
 	-- for the same resource type (e.g., can't take PTO twice on March 10)
 	CREATE UNIQUE INDEX IF NOT EXISTS idx_unique_day_consumption 
-		ON transactions(entity_id, resource_type, DATE(effective_at))
+		ON transactions(tenant_id, entity_id, resource_type, DATE(effective_at))
 		WHERE tx_type IN ('consumption', 'pending');
 
 	-- For entity-wide queries (day uniqueness validation)
 	CREATE INDEX IF NOT EXISTS idx_transactions_entity_resource_date 
-		ON transactions(entity_id, resource_type, effective_at);
+		ON transactions(tenant_id, entity_id, resource_type, effective_at);
 
 	-- For request tracking
 	CREATE INDEX IF NOT EXISTS idx_transactions_reference 
-		ON transactions(reference_id) WHERE reference_id IS NOT NULL;
+		ON transactions(tenant_id, reference_id) WHERE reference_id IS NOT NULL;
 	
 	-- Composite index for period-based balance queries (hot path)
 	CREATE INDEX IF NOT EXISTS idx_transactions_entity_policy_date
-		ON transactions(entity_id, policy_id, effective_at DESC);
+		ON transactions(tenant_id, entity_id, policy_id, effective_at DESC);
 	
 	-- For transaction type filtering
 	CREATE INDEX IF NOT EXISTS idx_transactions_type
-		ON transactions(tx_type);
+		ON transactions(tenant_id, tx_type);
 
 	-- Policies
 	CREATE TABLE IF NOT EXISTS policies (
-		id TEXT PRIMARY KEY,
+		tenant_id TEXT NOT NULL DEFAULT '',
+		id TEXT NOT NULL,
 		name TEXT NOT NULL,
 		resource_type TEXT NOT NULL,
 		config_json TEXT NOT NULL,
 		version INTEGER DEFAULT 1,
 		created_at TEXT NOT NULL,
-		updated_at TEXT NOT NULL
+		updated_at TEXT NOT NULL,
+		PRIMARY KEY (tenant_id, id)
 	);
 
 	-- Policy Assignments
 	CREATE TABLE IF NOT EXISTS policy_assignments (
-		id TEXT PRIMARY KEY,
+		tenant_id TEXT NOT NULL DEFAULT '',
+		id TEXT NOT NULL,
 		entity_id TEXT NOT NULL,
 		policy_id TEXT NOT NULL,
 		effective_from TEXT NOT NULL,
 		effective_to TEXT,
 		consumption_priority INTEGER DEFAULT 1,
 		approval_config_json TEXT,
-		created_at TEXT NOT NULL
+		created_at TEXT NOT NULL,
+		PRIMARY KEY (tenant_id, id)
 	);
 
 	CREATE INDEX IF NOT EXISTS idx_assignments_entity 
-		ON policy_assignments(entity_id);
+		ON policy_assignments(tenant_id, entity_id);
 	CREATE INDEX IF NOT EXISTS idx_assignments_policy 
-		ON policy_assignments(policy_id);
+		ON policy_assignments(tenant_id, policy_id);
 	
 	-- Composite index for active assignment lookups
 	CREATE INDEX IF NOT EXISTS idx_assignments_entity_active
-		ON policy_assignments(entity_id, effective_from, effective_to);
+		ON policy_assignments(tenant_id, entity_id, effective_from, effective_to);
 	
 	-- Index for resource type filtering
 	CREATE INDEX IF NOT EXISTS idx_policies_resource_type
-		ON policies(resource_type);
+		ON policies(tenant_id, resource_type);
 
 	-- Employees (entities)
 	CREATE TABLE IF NOT EXISTS employees (
-		id TEXT PRIMARY KEY,
+		tenant_id TEXT NOT NULL DEFAULT '',
+		id TEXT NOT NULL,
 		name TEXT NOT NULL,
 		email TEXT,
+		team TEXT NOT NULL DEFAULT '',
+		manager_id TEXT NOT NULL DEFAULT '',
 		hire_date TEXT NOT NULL,
-		created_at TEXT NOT NULL
+		created_at TEXT NOT NULL,
+		PRIMARY KEY (tenant_id, id)
 	);
 
 	-- Snapshots (for period-end balances)
 	CREATE TABLE IF NOT EXISTS snapshots (
-		id TEXT PRIMARY KEY,
+		tenant_id TEXT NOT NULL DEFAULT '',
+		id TEXT NOT NULL,
 		entity_id TEXT NOT NULL,
 		policy_id TEXT NOT NULL,
 		period_start TEXT NOT NULL,
 		period_end TEXT NOT NULL,
 		balance_json TEXT NOT NULL,
 		created_at TEXT NOT NULL,
-		UNIQUE(entity_id, policy_id, period_start, period_end)
+		PRIMARY KEY (tenant_id, id),
+		UNIQUE(tenant_id, entity_id, policy_id, period_start, period_end)
 	);
 
 	CREATE INDEX IF NOT EXISTS idx_snapshots_entity_policy 
-		ON snapshots(entity_id, policy_id);
+		ON snapshots(tenant_id, entity_id, policy_id);
 
 	-- Holidays (company-specific and global)
 	CREATE TABLE IF NOT EXISTS holidays (
-		id TEXT PRIMARY KEY,
+		tenant_id TEXT NOT NULL DEFAULT '',
+		id TEXT NOT NULL,
 		company_id TEXT NOT NULL DEFAULT '',
+		calendar_id TEXT NOT NULL DEFAULT '',  -- '' = applies to every calendar
 		date TEXT NOT NULL,
 		name TEXT NOT NULL,
 		recurring BOOLEAN DEFAULT FALSE,
-		created_at TEXT NOT NULL
+		rule TEXT NOT NULL DEFAULT '',  -- e.g. "4th thu nov", "easter-2", "7-4 observed"
+		created_at TEXT NOT NULL,
+		PRIMARY KEY (tenant_id, id)
 	);
 
 	CREATE INDEX IF NOT EXISTS idx_holidays_company_date 
-		ON holidays(company_id, date);
+		ON holidays(tenant_id, company_id, date);
 	CREATE UNIQUE INDEX IF NOT EXISTS idx_holidays_unique
-		ON holidays(company_id, date, name);
+		ON holidays(tenant_id, company_id, calendar_id, date, name);
+
+	-- Named holiday calendars (one per office/location)
+	CREATE TABLE IF NOT EXISTS holiday_calendars (
+		tenant_id TEXT NOT NULL DEFAULT '',
+		id TEXT NOT NULL,             -- e.g. "us", "uk", "fr-paris"
+		company_id TEXT NOT NULL DEFAULT '',
+		name TEXT NOT NULL,
+		created_at TEXT NOT NULL,
+		PRIMARY KEY (tenant_id, id)
+	);
+
+	-- Employee holiday calendars (effective-dated, for relocations)
+	CREATE TABLE IF NOT EXISTS employee_holiday_calendars (
+		tenant_id TEXT NOT NULL DEFAULT '',
+		id TEXT NOT NULL,
+		entity_id TEXT NOT NULL,
+		calendar_id TEXT NOT NULL,
+		effective_from TEXT NOT NULL,
+		created_at TEXT NOT NULL,
+		PRIMARY KEY (tenant_id, id)
+	);
+
+	CREATE UNIQUE INDEX IF NOT EXISTS idx_employee_holiday_calendars_unique
+		ON employee_holiday_calendars(tenant_id, entity_id, effective_from);
 
 	-- Time-off Requests (for approval workflow)
 	CREATE TABLE IF NOT EXISTS requests (
-		id TEXT PRIMARY KEY,
+		tenant_id TEXT NOT NULL DEFAULT '',
+		id TEXT NOT NULL,
 		entity_id TEXT NOT NULL,
 		resource_type TEXT NOT NULL,
 		effective_at TEXT NOT NULL,
@@ -243,17 +415,19 @@ func (s *Store) migrate() error {
 		reason TEXT,
 		distribution_json TEXT,
 		created_at TEXT NOT NULL,
-		updated_at TEXT NOT NULL
+		updated_at TEXT NOT NULL,
+		PRIMARY KEY (tenant_id, id)
 	);
 
 	CREATE INDEX IF NOT EXISTS idx_requests_entity 
-		ON requests(entity_id);
+		ON requests(tenant_id, entity_id);
 	CREATE INDEX IF NOT EXISTS idx_requests_status 
-		ON requests(status);
+		ON requests(tenant_id, status);
 
 	-- Reconciliation Runs (for scheduled reconciliation)
 	CREATE TABLE IF NOT EXISTS reconciliation_runs (
-		id TEXT PRIMARY KEY,
+		tenant_id TEXT NOT NULL DEFAULT '',
+		id TEXT NOT NULL,
 		policy_id TEXT NOT NULL,
 		entity_id TEXT NOT NULL,
 		period_start TEXT NOT NULL,
@@ -264,46 +438,417 @@ func (s *Store) migrate() error {
 		error TEXT,
 		started_at TEXT,
 		completed_at TEXT,
-		created_at TEXT NOT NULL
+		created_at TEXT NOT NULL,
+		PRIMARY KEY (tenant_id, id)
 	);
 
 	CREATE INDEX IF NOT EXISTS idx_reconciliation_runs_policy 
-		ON reconciliation_runs(policy_id);
+		ON reconciliation_runs(tenant_id, policy_id);
 	CREATE INDEX IF NOT EXISTS idx_reconciliation_runs_status 
-		ON reconciliation_runs(status);
+		ON reconciliation_runs(tenant_id, status);
 	CREATE UNIQUE INDEX IF NOT EXISTS idx_reconciliation_runs_unique
-		ON reconciliation_runs(entity_id, policy_id, period_start, period_end);
+		ON reconciliation_runs(tenant_id, entity_id, policy_id, period_start, period_end);
+
+	-- Reconciliation batches (one per scheduler check or API run; the
+	-- runs above are their items, linked by batch_id)
+	CREATE TABLE IF NOT EXISTS reconciliation_batches (
+		tenant_id TEXT NOT NULL DEFAULT '',
+		id TEXT NOT NULL,
+		trigger_source TEXT NOT NULL,
+		status TEXT NOT NULL,
+		as_of TEXT NOT NULL,
+		workers INTEGER NOT NULL DEFAULT 1,
+		total INTEGER NOT NULL DEFAULT 0,
+		processed INTEGER NOT NULL DEFAULT 0,
+		reconciled INTEGER NOT NULL DEFAULT 0,
+		failed INTEGER NOT NULL DEFAULT 0,
+		carried_over REAL NOT NULL DEFAULT 0,
+		expired REAL NOT NULL DEFAULT 0,
+		checkpoint TEXT NOT NULL DEFAULT '',
+		error TEXT NOT NULL DEFAULT '',
+		created_at TEXT NOT NULL,
+		updated_at TEXT NOT NULL,
+		completed_at TEXT,
+		PRIMARY KEY (tenant_id, id)
+	);
+
+	-- Pay Rates (effective-dated, for liability reporting)
+	CREATE TABLE IF NOT EXISTS pay_rates (
+		tenant_id TEXT NOT NULL DEFAULT '',
+		id TEXT NOT NULL,
+		entity_id TEXT NOT NULL,
+		rate TEXT NOT NULL,           -- Decimal as string, per Unit
+		currency TEXT NOT NULL,
+		unit TEXT NOT NULL,           -- days or hours
+		hours_per_day REAL NOT NULL DEFAULT 8,
+		effective_from TEXT NOT NULL,
+		created_at TEXT NOT NULL,
+		PRIMARY KEY (tenant_id, id)
+	);
+
+	CREATE UNIQUE INDEX IF NOT EXISTS idx_pay_rates_unique
+		ON pay_rates(tenant_id, entity_id, effective_from);
+
+	-- Calendar Feeds (unguessable tokens for iCalendar subscriptions)
+	-- The token is globally unique: feed URLs carry no tenant, the token
+	-- resolves it.
+	CREATE TABLE IF NOT EXISTS calendar_feeds (
+		token TEXT PRIMARY KEY,
+		tenant_id TEXT NOT NULL DEFAULT '',
+		owner_id TEXT NOT NULL,
+		scope TEXT NOT NULL,          -- self, team, reports
+		created_at TEXT NOT NULL,
+		revoked_at TEXT
+	);
+
+	CREATE INDEX IF NOT EXISTS idx_calendar_feeds_owner
+		ON calendar_feeds(tenant_id, owner_id);
+
+	-- Outbox (one event per ledger append, written in the same SQL
+	-- transaction as the transactions it describes)
+	CREATE TABLE IF NOT EXISTS outbox_events (
+		tenant_id TEXT NOT NULL DEFAULT '',
+		id TEXT NOT NULL,
+		event_type TEXT NOT NULL,
+		payload_json TEXT NOT NULL,   -- Webhook body
+		created_at TEXT NOT NULL,
+		dispatched_at TEXT,           -- Set once deliveries were created
+		PRIMARY KEY (tenant_id, id)
+	);
+
+	CREATE INDEX IF NOT EXISTS idx_outbox_events_undispatched
+		ON outbox_events(tenant_id, dispatched_at, created_at);
+
+	-- Webhook endpoints (registered by admins)
+	CREATE TABLE IF NOT EXISTS webhook_endpoints (
+		tenant_id TEXT NOT NULL DEFAULT '',
+		id TEXT NOT NULL,
+		url TEXT NOT NULL,
+		secret TEXT NOT NULL,         -- HMAC key for X-Webhook-Signature
+		event_types TEXT NOT NULL DEFAULT '', -- Comma-separated; empty = all
+		description TEXT,
+		active INTEGER NOT NULL DEFAULT 1,
+		created_at TEXT NOT NULL,
+		PRIMARY KEY (tenant_id, id)
+	);
+
+	-- Webhook deliveries (one per event and endpoint)
+	CREATE TABLE IF NOT EXISTS webhook_deliveries (
+		tenant_id TEXT NOT NULL DEFAULT '',
+		id TEXT NOT NULL,
+		endpoint_id TEXT NOT NULL,
+		event_id TEXT NOT NULL,
+		event_type TEXT NOT NULL,
+		status TEXT NOT NULL,         -- pending, delivered, dead
+		attempts INTEGER NOT NULL DEFAULT 0,
+		next_attempt_at TEXT,
+		last_status_code INTEGER,
+		last_error TEXT,
+		delivered_at TEXT,
+		created_at TEXT NOT NULL,
+		PRIMARY KEY (tenant_id, id)
+	);
+
+	CREATE UNIQUE INDEX IF NOT EXISTS idx_webhook_deliveries_event
+		ON webhook_deliveries(tenant_id, endpoint_id, event_id);
+	CREATE INDEX IF NOT EXISTS idx_webhook_deliveries_due
+		ON webhook_deliveries(tenant_id, status, next_attempt_at);
+
+	-- Responses stored per Idempotency-Key header (scoped to the caller)
+	CREATE TABLE IF NOT EXISTS idempotency_keys (
+		tenant_id TEXT NOT NULL DEFAULT '',
+		actor TEXT NOT NULL,
+		key TEXT NOT NULL,
+		method TEXT NOT NULL,
+		path TEXT NOT NULL,
+		request_hash TEXT NOT NULL,
+		status_code INTEGER,          -- NULL while the request is in progress
+		content_type TEXT,
+		response_body BLOB,
+		created_at TEXT NOT NULL,
+		completed_at TEXT,
+		PRIMARY KEY (tenant_id, actor, key)
+	);
+
+	CREATE INDEX IF NOT EXISTS idx_idempotency_keys_created
+		ON idempotency_keys(created_at);
+
+	-- Leases (global; one holder per name until it expires)
+	CREATE TABLE IF NOT EXISTS leases (
+		name TEXT PRIMARY KEY,
+		holder TEXT NOT NULL,
+		acquired_at TEXT NOT NULL,
+		renewed_at TEXT NOT NULL,
+		expires_at TEXT NOT NULL
+	);
 	`
 
-	_, err := s.db.Exec(schema)
+	if _, err := sqlTx.Exec(schema); err != nil {
+		return err
+	}
+
+	for _, table := range legacy {
+		if err := copyLegacyTable(sqlTx, table); err != nil {
+			return err
+		}
+	}
+	if err := sqlTx.Commit(); err != nil {
+		return err
+	}
+
+	// Audit columns (added after multi-tenancy)
+	if err := s.addColumnIfMissing("transactions", "created_by", "TEXT"); err != nil {
+		return err
+	}
+	if err := s.addColumnIfMissing("transactions", "created_by_type", "TEXT"); err != nil {
+		return err
+	}
+
+	// Batch of each reconciliation run (added with reconciliation batches)
+	if err := s.addColumnIfMissing("reconciliation_runs", "batch_id", "TEXT NOT NULL DEFAULT ''"); err != nil {
+		return err
+	}
+
+	// Rollback time of each reconciliation run (added with rollbacks)
+	if err := s.addColumnIfMissing("reconciliation_runs", "rolled_back_at", "TEXT"); err != nil {
+		return err
+	}
+
+	// Corrections after backdated transactions (added with re-reconciliation)
+	if err := s.addColumnIfMissing("reconciliation_runs", "corrections", "INTEGER NOT NULL DEFAULT 0"); err != nil {
+		return err
+	}
+	if err := s.addColumnIfMissing("reconciliation_runs", "corrected_at", "TEXT"); err != nil {
+		return err
+	}
+	if err := s.addColumnIfMissing("reconciliation_runs", "corrected_by", "TEXT NOT NULL DEFAULT ''"); err != nil {
+		return err
+	}
+	return s.addColumnIfMissing("reconciliation_runs", "corrected_from", "TEXT NOT NULL DEFAULT ''")
+}
+
+// tablesWithoutColumn returns the existing tables that lack a column.
+func (s *Store) tablesWithoutColumn(column string) ([]string, error) {
+	rows, err := s.db.Query("SELECT name FROM sqlite_master WHERE type = 'table' AND name NOT LIKE 'sqlite_%'")
+	if err != nil {
+		return nil, err
+	}
+	var tables []string
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		tables = append(tables, name)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	var missing []string
+	for _, table := range tables {
+		if table == "tenants" {
+			continue
+		}
+		columns, err := tableColumns(s.db, table)
+		if err != nil {
+			return nil, err
+		}
+		if !containsString(columns, column) {
+			missing = append(missing, table)
+		}
+	}
+	return missing, nil
+}
+
+// renameLegacyTable moves a table aside as legacy_<table> and drops its
+// named indexes, so the schema can recreate both under their own names.
+func renameLegacyTable(sqlTx *sql.Tx, table string) error {
+	if _, err := sqlTx.Exec("ALTER TABLE " + table + " RENAME TO legacy_" + table); err != nil {
+		return err
+	}
+	rows, err := sqlTx.Query("SELECT name FROM sqlite_master WHERE type = 'index' AND tbl_name = ? AND sql IS NOT NULL", "legacy_"+table)
+	if err != nil {
+		return err
+	}
+	var indexes []string
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			rows.Close()
+			return err
+		}
+		indexes = append(indexes, name)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+	for _, index := range indexes {
+		if _, err := sqlTx.Exec("DROP INDEX " + index); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// copyLegacyTable copies the columns a legacy table shares with its
+// recreated table into it (tenant_id takes its default, the default
+// tenant) and drops the legacy table.
+func copyLegacyTable(sqlTx *sql.Tx, table string) error {
+	legacyColumns, err := tableColumns(sqlTx, "legacy_"+table)
+	if err != nil {
+		return err
+	}
+	columns, err := tableColumns(sqlTx, table)
+	if err != nil {
+		return err
+	}
+	var shared []string
+	for _, c := range legacyColumns {
+		if containsString(columns, c) {
+			shared = append(shared, c)
+		}
+	}
+	if len(shared) > 0 {
+		list := strings.Join(shared, ", ")
+		if _, err := sqlTx.Exec("INSERT INTO " + table + " (" + list + ") SELECT " + list + " FROM legacy_" + table); err != nil {
+			return fmt.Errorf("failed to migrate %s: %w", table, err)
+		}
+	}
+	_, err = sqlTx.Exec("DROP TABLE legacy_" + table)
+	return err
+}
+
+// tableColumns returns a table's column names.
+func tableColumns(db interface {
+	Query(query string, args ...any) (*sql.Rows, error)
+}, table string) ([]string, error) {
+	rows, err := db.Query("PRAGMA table_info(" + table + ")")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var columns []string
+	for rows.Next() {
+		var (
+			cid        int
+			name, typ  string
+			notNull    int
+			defaultVal sql.NullString
+			pk         int
+		)
+		if err := rows.Scan(&cid, &name, &typ, &notNull, &defaultVal, &pk); err != nil {
+			return nil, err
+		}
+		columns = append(columns, name)
+	}
+	return columns, rows.Err()
+}
+
+// addColumnIfMissing adds a column to an existing table unless present.
+func (s *Store) addColumnIfMissing(table, column, definition string) error {
+	columns, err := tableColumns(s.db, table)
+	if err != nil {
+		return err
+	}
+	if containsString(columns, column) {
+		return nil
+	}
+
+	_, err = s.db.Exec("ALTER TABLE " + table + " ADD COLUMN " + column + " " + definition)
 	return err
 }
 
 // =============================================================================
-// TRANSACTION STORE (generic.Store interface)
+// TENANTS
 // =============================================================================
 
-// Append adds a transaction to the ledger.
-func (s *Store) Append(ctx context.Context, tx generic.Transaction) error {
+// tenantOf returns the tenant every query in this file is scoped to.
+func tenantOf(ctx context.Context) string {
+	return string(generic.TenantFrom(ctx))
+}
+
+// SaveTenant creates or renames a tenant.
+func (s *Store) SaveTenant(ctx context.Context, t Tenant) error {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
-	return s.appendTx(ctx, s.db, tx)
+	_, err := s.db.ExecContext(ctx, `
+		INSERT INTO tenants (id, name, created_at) VALUES (?, ?, ?)
+		ON CONFLICT(id) DO UPDATE SET name = excluded.name
+	`, t.ID, t.Name, time.Now().UTC().Format(time.RFC3339))
+	return err
+}
+
+// GetTenant returns a tenant by ID, or nil.
+func (s *Store) GetTenant(ctx context.Context, id generic.TenantID) (*Tenant, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	tenants, err := s.queryTenants(ctx, "SELECT id, name, created_at FROM tenants WHERE id = ?", id)
+	if err != nil || len(tenants) == 0 {
+		return nil, err
+	}
+	return &tenants[0], nil
+}
+
+// ListTenants returns all tenants, not including the default tenant.
+func (s *Store) ListTenants(ctx context.Context) ([]Tenant, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return s.queryTenants(ctx, "SELECT id, name, created_at FROM tenants ORDER BY id")
+}
+
+func (s *Store) queryTenants(ctx context.Context, query string, args ...any) ([]Tenant, error) {
+	rows, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var tenants []Tenant
+	for rows.Next() {
+		var t Tenant
+		var createdAt string
+		if err := rows.Scan(&t.ID, &t.Name, &createdAt); err != nil {
+			return nil, err
+		}
+		t.CreatedAt, _ = time.Parse(time.RFC3339, createdAt)
+		tenants = append(tenants, t)
+	}
+	return tenants, rows.Err()
+}
+
+// =============================================================================
+// TRANSACTION STORE (generic.Store interface)
+// =============================================================================
+
+// Append adds a transaction to the ledger.
+func (s *Store) Append(ctx context.Context, tx generic.Transaction) error {
+	return s.AppendBatchWithEvent(ctx, []generic.Transaction{tx}, generic.EventTransactionsPosted, nil)
 }
 
 func (s *Store) appendTx(ctx context.Context, db interface {
 	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
 }, tx generic.Transaction) error {
+	tx.Stamp(ctx)
 	metadataJSON, _ := json.Marshal(tx.Metadata)
 
 	query := `
 		INSERT INTO transactions 
-		(id, entity_id, policy_id, resource_type, effective_at, delta_value, delta_unit, 
-		 tx_type, reference_id, reason, idempotency_key, metadata_json, created_at)
-		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+		(tenant_id, id, entity_id, policy_id, resource_type, effective_at, delta_value, delta_unit, 
+		 tx_type, reference_id, reason, idempotency_key, metadata_json,
+		 created_by, created_by_type, created_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
 	`
 
 	_, err := db.ExecContext(ctx, query,
+		tenantOf(ctx),
 		tx.ID,
 		tx.EntityID,
 		tx.PolicyID,
@@ -316,6 +861,8 @@ func (s *Store) appendTx(ctx context.Context, db interface {
 		tx.Reason,
 		nullString(tx.IdempotencyKey),
 		string(metadataJSON),
+		nullString(tx.CreatedBy),
+		nullString(tx.CreatedByType),
 		time.Now().UTC().Format(time.RFC3339),
 	)
 
@@ -335,6 +882,18 @@ func (s *Store) appendTx(ctx context.Context, db interface {
 
 // AppendBatch adds multiple transactions atomically.
 func (s *Store) AppendBatch(ctx context.Context, txs []generic.Transaction) error {
+	if len(txs) == 0 {
+		return nil
+	}
+	return s.AppendBatchWithEvent(ctx, txs, generic.EventTransactionsPosted, nil)
+}
+
+// AppendBatchWithEvent adds transactions atomically and records one outbox
+// event describing them in the same SQL transaction: the event exists if
+// and only if the transactions were committed. data carries event details
+// the transactions do not (request ID, approver). An empty batch records
+// just the event.
+func (s *Store) AppendBatchWithEvent(ctx context.Context, txs []generic.Transaction, eventType generic.EventType, data map[string]any) error {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
@@ -360,6 +919,9 @@ func (s *Store) AppendBatch(ctx context.Context, txs []generic.Transaction) erro
 			return err
 		}
 	}
+	if err := insertOutboxEvent(ctx, sqlTx, eventType, txs, data); err != nil {
+		return err
+	}
 
 	return sqlTx.Commit()
 }
@@ -368,52 +930,100 @@ func (s *Store) AppendBatch(ctx context.Context, txs []generic.Transaction) erro
 func (s *Store) Load(ctx context.Context, entityID generic.EntityID, policyID generic.PolicyID) ([]generic.Transaction, error) {
 	s.mu.RLock()
 	defer s.mu.RUnlock()
+	return loadTransactions(ctx, s.db, entityID, policyID)
+}
+
+// LoadRange returns transactions in a time range.
+func (s *Store) LoadRange(ctx context.Context, entityID generic.EntityID, policyID generic.PolicyID, from, to generic.TimePoint) ([]generic.Transaction, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return loadTransactionRange(ctx, s.db, entityID, policyID, from, to)
+}
+
+// LoadRangeKnownAt returns transactions in a time range that were created
+// at or before knownAt.
+func (s *Store) LoadRangeKnownAt(ctx context.Context, entityID generic.EntityID, policyID generic.PolicyID, from, to generic.TimePoint, knownAt time.Time) ([]generic.Transaction, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return loadTransactionRangeKnownAt(ctx, s.db, entityID, policyID, from, to, knownAt)
+}
+
+// Exists checks if an idempotency key exists.
+func (s *Store) Exists(ctx context.Context, idempotencyKey string) (bool, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return idempotencyKeyExists(ctx, s.db, idempotencyKey)
+}
 
+// queryer is a connection or an open transaction (WithTx).
+type queryer interface {
+	QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row
+}
+
+func loadTransactions(ctx context.Context, db queryer, entityID generic.EntityID, policyID generic.PolicyID) ([]generic.Transaction, error) {
 	query := `
 		SELECT id, entity_id, policy_id, resource_type, effective_at, delta_value, delta_unit,
-		       tx_type, reference_id, reason, idempotency_key, metadata_json, created_at
+		       tx_type, reference_id, reason, idempotency_key, metadata_json,
+		       created_by, created_by_type, created_at
 		FROM transactions
-		WHERE entity_id = ? AND policy_id = ?
+		WHERE tenant_id = ? AND entity_id = ? AND policy_id = ?
 		ORDER BY effective_at ASC, created_at ASC
 	`
 
-	return s.queryTransactions(ctx, query, entityID, policyID)
+	return queryTransactionsOn(ctx, db, query, tenantOf(ctx), entityID, policyID)
 }
 
-// LoadRange returns transactions in a time range.
-func (s *Store) LoadRange(ctx context.Context, entityID generic.EntityID, policyID generic.PolicyID, from, to generic.TimePoint) ([]generic.Transaction, error) {
-	s.mu.RLock()
-	defer s.mu.RUnlock()
-
+func loadTransactionRange(ctx context.Context, db queryer, entityID generic.EntityID, policyID generic.PolicyID, from, to generic.TimePoint) ([]generic.Transaction, error) {
 	query := `
 		SELECT id, entity_id, policy_id, resource_type, effective_at, delta_value, delta_unit,
-		       tx_type, reference_id, reason, idempotency_key, metadata_json, created_at
+		       tx_type, reference_id, reason, idempotency_key, metadata_json,
+		       created_by, created_by_type, created_at
 		FROM transactions
-		WHERE entity_id = ? AND policy_id = ? 
+		WHERE tenant_id = ? AND entity_id = ? AND policy_id = ? 
 		  AND effective_at >= ? AND effective_at <= ?
 		ORDER BY effective_at ASC, created_at ASC
 	`
 
-	return s.queryTransactions(ctx, query, entityID, policyID,
+	return queryTransactionsOn(ctx, db, query, tenantOf(ctx), entityID, policyID,
 		from.Time.Format(time.RFC3339), to.Time.Format(time.RFC3339))
 }
 
-// Exists checks if an idempotency key exists.
-func (s *Store) Exists(ctx context.Context, idempotencyKey string) (bool, error) {
-	s.mu.RLock()
-	defer s.mu.RUnlock()
-
-	var count int
-	err := s.db.QueryRowContext(ctx,
-		"SELECT COUNT(*) FROM transactions WHERE idempotency_key = ?",
-		idempotencyKey,
-	).Scan(&count)
-
+// loadTransactionRangeKnownAt compares created_at as text: both sides are
+// RFC3339 in UTC at second precision, which sorts chronologically.
+func loadTransactionRangeKnownAt(ctx context.Context, db queryer, entityID generic.EntityID, policyID generic.PolicyID, from, to generic.TimePoint, knownAt time.Time) ([]generic.Transaction, error) {
+	query := `
+		SELECT id, entity_id, policy_id, resource_type, effective_at, delta_value, delta_unit,
+		       tx_type, reference_id, reason, idempotency_key, metadata_json,
+		       created_by, created_by_type, created_at
+		FROM transactions
+		WHERE tenant_id = ? AND entity_id = ? AND policy_id = ?
+		  AND effective_at >= ? AND effective_at <= ?
+		  AND created_at <= ?
+		ORDER BY effective_at ASC, created_at ASC
+	`
+
+	return queryTransactionsOn(ctx, db, query, tenantOf(ctx), entityID, policyID,
+		from.Time.Format(time.RFC3339), to.Time.Format(time.RFC3339),
+		knownAt.UTC().Format(time.RFC3339))
+}
+
+func idempotencyKeyExists(ctx context.Context, db queryer, idempotencyKey string) (bool, error) {
+	var count int
+	err := db.QueryRowContext(ctx,
+		"SELECT COUNT(*) FROM transactions WHERE tenant_id = ? AND idempotency_key = ?",
+		tenantOf(ctx), idempotencyKey,
+	).Scan(&count)
+
 	return count > 0, err
 }
 
 func (s *Store) queryTransactions(ctx context.Context, query string, args ...any) ([]generic.Transaction, error) {
-	rows, err := s.db.QueryContext(ctx, query, args...)
+	return queryTransactionsOn(ctx, s.db, query, args...)
+}
+
+func queryTransactionsOn(ctx context.Context, db queryer, query string, args ...any) ([]generic.Transaction, error) {
+	rows, err := db.QueryContext(ctx, query, args...)
 	if err != nil {
 		return nil, fmt.Errorf("failed to query transactions: %w", err)
 	}
@@ -442,13 +1052,16 @@ func scanTransaction(rows *sql.Rows) (generic.Transaction, error) {
 		reason            sql.NullString
 		idempotencyKey    sql.NullString
 		metadataJSON      sql.NullString
+		createdBy         sql.NullString
+		createdByType     sql.NullString
 		createdAt         string
 	)
 
 	err := rows.Scan(
 		&tx.ID, &tx.EntityID, &tx.PolicyID, &resourceTypeID,
 		&effectiveAt, &deltaValue, &deltaUnit, &tx.Type,
-		&referenceID, &reason, &idempotencyKey, &metadataJSON, &createdAt,
+		&referenceID, &reason, &idempotencyKey, &metadataJSON,
+		&createdBy, &createdByType, &createdAt,
 	)
 	if err != nil {
 		return tx, fmt.Errorf("failed to scan transaction: %w", err)
@@ -462,6 +1075,10 @@ func scanTransaction(rows *sql.Rows) (generic.Transaction, error) {
 	tx.ReferenceID = referenceID.String
 	tx.Reason = reason.String
 	tx.IdempotencyKey = idempotencyKey.String
+	tx.CreatedBy = createdBy.String
+	tx.CreatedByType = createdByType.String
+	created, _ := time.Parse(time.RFC3339, createdAt)
+	tx.CreatedAt = generic.TimePoint{Time: created}
 
 	if metadataJSON.Valid && metadataJSON.String != "" {
 		json.Unmarshal([]byte(metadataJSON.String), &tx.Metadata)
@@ -499,7 +1116,7 @@ type txStore struct {
 }
 
 func (ts *txStore) Append(ctx context.Context, tx generic.Transaction) error {
-	return ts.parent.appendTx(ctx, ts.tx, tx)
+	return ts.AppendBatch(ctx, []generic.Transaction{tx})
 }
 
 func (ts *txStore) AppendBatch(ctx context.Context, txs []generic.Transaction) error {
@@ -508,45 +1125,44 @@ func (ts *txStore) AppendBatch(ctx context.Context, txs []generic.Transaction) e
 			return err
 		}
 	}
-	return nil
+	if len(txs) == 0 {
+		return nil
+	}
+	return insertOutboxEvent(ctx, ts.tx, generic.EventTransactionsPosted, txs, nil)
 }
 
+// Reads go through the open transaction: they see its own appends, and
+// WithTx already holds the store lock.
+
 func (ts *txStore) Load(ctx context.Context, entityID generic.EntityID, policyID generic.PolicyID) ([]generic.Transaction, error) {
-	return ts.parent.Load(ctx, entityID, policyID)
+	return loadTransactions(ctx, ts.tx, entityID, policyID)
 }
 
 func (ts *txStore) LoadRange(ctx context.Context, entityID generic.EntityID, policyID generic.PolicyID, from, to generic.TimePoint) ([]generic.Transaction, error) {
-	return ts.parent.LoadRange(ctx, entityID, policyID, from, to)
+	return loadTransactionRange(ctx, ts.tx, entityID, policyID, from, to)
+}
+
+func (ts *txStore) LoadRangeKnownAt(ctx context.Context, entityID generic.EntityID, policyID generic.PolicyID, from, to generic.TimePoint, knownAt time.Time) ([]generic.Transaction, error) {
+	return loadTransactionRangeKnownAt(ctx, ts.tx, entityID, policyID, from, to, knownAt)
 }
 
 func (ts *txStore) Exists(ctx context.Context, idempotencyKey string) (bool, error) {
-	return ts.parent.Exists(ctx, idempotencyKey)
+	return idempotencyKeyExists(ctx, ts.tx, idempotencyKey)
 }
 
 // =============================================================================
 // POLICY STORE
 // =============================================================================
 
-// PolicyRecord is a stored policy with its JSON config.
-type PolicyRecord struct {
-	ID           string
-	Name         string
-	ResourceType string
-	ConfigJSON   string
-	Version      int
-	CreatedAt    time.Time
-	UpdatedAt    time.Time
-}
-
 // SavePolicy saves a policy record.
 func (s *Store) SavePolicy(ctx context.Context, policy PolicyRecord) error {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
 	query := `
-		INSERT INTO policies (id, name, resource_type, config_json, version, created_at, updated_at)
-		VALUES (?, ?, ?, ?, ?, ?, ?)
-		ON CONFLICT(id) DO UPDATE SET
+		INSERT INTO policies (tenant_id, id, name, resource_type, config_json, version, created_at, updated_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?)
+		ON CONFLICT(tenant_id, id) DO UPDATE SET
 			name = excluded.name,
 			resource_type = excluded.resource_type,
 			config_json = excluded.config_json,
@@ -556,7 +1172,7 @@ func (s *Store) SavePolicy(ctx context.Context, policy PolicyRecord) error {
 
 	now := time.Now().UTC().Format(time.RFC3339)
 	_, err := s.db.ExecContext(ctx, query,
-		policy.ID, policy.Name, policy.ResourceType, policy.ConfigJSON,
+		tenantOf(ctx), policy.ID, policy.Name, policy.ResourceType, policy.ConfigJSON,
 		policy.Version, now, now,
 	)
 	return err
@@ -571,8 +1187,8 @@ func (s *Store) GetPolicy(ctx context.Context, id string) (*PolicyRecord, error)
 	var createdAt, updatedAt string
 
 	err := s.db.QueryRowContext(ctx,
-		"SELECT id, name, resource_type, config_json, version, created_at, updated_at FROM policies WHERE id = ?",
-		id,
+		"SELECT id, name, resource_type, config_json, version, created_at, updated_at FROM policies WHERE tenant_id = ? AND id = ?",
+		tenantOf(ctx), id,
 	).Scan(&p.ID, &p.Name, &p.ResourceType, &p.ConfigJSON, &p.Version, &createdAt, &updatedAt)
 
 	if err == sql.ErrNoRows {
@@ -593,7 +1209,8 @@ func (s *Store) ListPolicies(ctx context.Context) ([]PolicyRecord, error) {
 	defer s.mu.RUnlock()
 
 	rows, err := s.db.QueryContext(ctx,
-		"SELECT id, name, resource_type, config_json, version, created_at, updated_at FROM policies ORDER BY name",
+		"SELECT id, name, resource_type, config_json, version, created_at, updated_at FROM policies WHERE tenant_id = ? ORDER BY name",
+		tenantOf(ctx),
 	)
 	if err != nil {
 		return nil, err
@@ -619,7 +1236,7 @@ func (s *Store) DeletePolicy(ctx context.Context, id string) error {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
-	_, err := s.db.ExecContext(ctx, "DELETE FROM policies WHERE id = ?", id)
+	_, err := s.db.ExecContext(ctx, "DELETE FROM policies WHERE tenant_id = ? AND id = ?", tenantOf(ctx), id)
 	return err
 }
 
@@ -627,31 +1244,24 @@ func (s *Store) DeletePolicy(ctx context.Context, id string) error {
 // EMPLOYEE STORE
 // =============================================================================
 
-// Employee represents an employee record.
-type Employee struct {
-	ID        string
-	Name      string
-	Email     string
-	HireDate  time.Time
-	CreatedAt time.Time
-}
-
 // SaveEmployee saves an employee.
 func (s *Store) SaveEmployee(ctx context.Context, emp Employee) error {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
 	query := `
-		INSERT INTO employees (id, name, email, hire_date, created_at)
-		VALUES (?, ?, ?, ?, ?)
-		ON CONFLICT(id) DO UPDATE SET
+		INSERT INTO employees (tenant_id, id, name, email, team, manager_id, hire_date, created_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?)
+		ON CONFLICT(tenant_id, id) DO UPDATE SET
 			name = excluded.name,
 			email = excluded.email,
+			team = excluded.team,
+			manager_id = excluded.manager_id,
 			hire_date = excluded.hire_date
 	`
 
 	_, err := s.db.ExecContext(ctx, query,
-		emp.ID, emp.Name, emp.Email,
+		tenantOf(ctx), emp.ID, emp.Name, emp.Email, emp.Team, emp.ManagerID,
 		emp.HireDate.Format(time.RFC3339),
 		time.Now().UTC().Format(time.RFC3339),
 	)
@@ -667,9 +1277,9 @@ func (s *Store) GetEmployee(ctx context.Context, id string) (*Employee, error) {
 	var hireDate, createdAt string
 
 	err := s.db.QueryRowContext(ctx,
-		"SELECT id, name, email, hire_date, created_at FROM employees WHERE id = ?",
-		id,
-	).Scan(&emp.ID, &emp.Name, &emp.Email, &hireDate, &createdAt)
+		"SELECT id, name, email, team, manager_id, hire_date, created_at FROM employees WHERE tenant_id = ? AND id = ?",
+		tenantOf(ctx), id,
+	).Scan(&emp.ID, &emp.Name, &emp.Email, &emp.Team, &emp.ManagerID, &hireDate, &createdAt)
 
 	if err == sql.ErrNoRows {
 		return nil, nil
@@ -689,7 +1299,8 @@ func (s *Store) ListEmployees(ctx context.Context) ([]Employee, error) {
 	defer s.mu.RUnlock()
 
 	rows, err := s.db.QueryContext(ctx,
-		"SELECT id, name, email, hire_date, created_at FROM employees ORDER BY name",
+		"SELECT id, name, email, team, manager_id, hire_date, created_at FROM employees WHERE tenant_id = ? ORDER BY name",
+		tenantOf(ctx),
 	)
 	if err != nil {
 		return nil, err
@@ -700,7 +1311,7 @@ func (s *Store) ListEmployees(ctx context.Context) ([]Employee, error) {
 	for rows.Next() {
 		var emp Employee
 		var hireDate, createdAt string
-		if err := rows.Scan(&emp.ID, &emp.Name, &emp.Email, &hireDate, &createdAt); err != nil {
+		if err := rows.Scan(&emp.ID, &emp.Name, &emp.Email, &emp.Team, &emp.ManagerID, &hireDate, &createdAt); err != nil {
 			return nil, err
 		}
 		emp.HireDate, _ = time.Parse(time.RFC3339, hireDate)
@@ -715,7 +1326,7 @@ func (s *Store) DeleteEmployee(ctx context.Context, id string) error {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
-	_, err := s.db.ExecContext(ctx, "DELETE FROM employees WHERE id = ?", id)
+	_, err := s.db.ExecContext(ctx, "DELETE FROM employees WHERE tenant_id = ? AND id = ?", tenantOf(ctx), id)
 	return err
 }
 
@@ -723,18 +1334,6 @@ func (s *Store) DeleteEmployee(ctx context.Context, id string) error {
 // ASSIGNMENT STORE (generic.AssignmentStore interface)
 // =============================================================================
 
-// AssignmentRecord is a stored policy assignment.
-type AssignmentRecord struct {
-	ID                 string
-	EntityID           string
-	PolicyID           string
-	EffectiveFrom      time.Time
-	EffectiveTo        *time.Time
-	ConsumptionPriority int
-	ApprovalConfigJSON string
-	CreatedAt          time.Time
-}
-
 // SaveAssignment saves a policy assignment.
 func (s *Store) SaveAssignment(ctx context.Context, a AssignmentRecord) error {
 	s.mu.Lock()
@@ -748,9 +1347,9 @@ func (s *Store) SaveAssignment(ctx context.Context, a AssignmentRecord) error {
 
 	query := `
 		INSERT INTO policy_assignments 
-		(id, entity_id, policy_id, effective_from, effective_to, consumption_priority, approval_config_json, created_at)
-		VALUES (?, ?, ?, ?, ?, ?, ?, ?)
-		ON CONFLICT(id) DO UPDATE SET
+		(tenant_id, id, entity_id, policy_id, effective_from, effective_to, consumption_priority, approval_config_json, created_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)
+		ON CONFLICT(tenant_id, id) DO UPDATE SET
 			effective_from = excluded.effective_from,
 			effective_to = excluded.effective_to,
 			consumption_priority = excluded.consumption_priority,
@@ -758,7 +1357,7 @@ func (s *Store) SaveAssignment(ctx context.Context, a AssignmentRecord) error {
 	`
 
 	_, err := s.db.ExecContext(ctx, query,
-		a.ID, a.EntityID, a.PolicyID,
+		tenantOf(ctx), a.ID, a.EntityID, a.PolicyID,
 		a.EffectiveFrom.Format(time.RFC3339),
 		effectiveTo,
 		a.ConsumptionPriority,
@@ -777,11 +1376,11 @@ func (s *Store) GetAssignmentsByEntity(ctx context.Context, entityID string) ([]
 		SELECT id, entity_id, policy_id, effective_from, effective_to, 
 		       consumption_priority, approval_config_json, created_at
 		FROM policy_assignments
-		WHERE entity_id = ?
+		WHERE tenant_id = ? AND entity_id = ?
 		ORDER BY consumption_priority ASC
 	`
 
-	return s.queryAssignments(ctx, query, entityID)
+	return s.queryAssignments(ctx, query, tenantOf(ctx), entityID)
 }
 
 // GetAssignmentsByPolicy returns all assignments for a policy.
@@ -793,11 +1392,11 @@ func (s *Store) GetAssignmentsByPolicy(ctx context.Context, policyID string) ([]
 		SELECT id, entity_id, policy_id, effective_from, effective_to, 
 		       consumption_priority, approval_config_json, created_at
 		FROM policy_assignments
-		WHERE policy_id = ?
+		WHERE tenant_id = ? AND policy_id = ?
 		ORDER BY entity_id
 	`
 
-	return s.queryAssignments(ctx, query, policyID)
+	return s.queryAssignments(ctx, query, tenantOf(ctx), policyID)
 }
 
 func (s *Store) queryAssignments(ctx context.Context, query string, args ...any) ([]AssignmentRecord, error) {
@@ -836,40 +1435,35 @@ func (s *Store) DeleteAssignment(ctx context.Context, id string) error {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
-	_, err := s.db.ExecContext(ctx, "DELETE FROM policy_assignments WHERE id = ?", id)
+	_, err := s.db.ExecContext(ctx, "DELETE FROM policy_assignments WHERE tenant_id = ? AND id = ?", tenantOf(ctx), id)
 	return err
 }
 
+// Assignments returns the assignments as a generic.AssignmentStore,
+// resolving each assignment's policy with policies.
+func (s *Store) Assignments(policies PolicyResolver) generic.AssignmentStore {
+	return record.NewAssignmentStore(s, policies)
+}
+
 // =============================================================================
 // SNAPSHOT STORE
 // =============================================================================
 
-// SnapshotRecord is a stored balance snapshot.
-type SnapshotRecord struct {
-	ID          string
-	EntityID    string
-	PolicyID    string
-	PeriodStart time.Time
-	PeriodEnd   time.Time
-	BalanceJSON string
-	CreatedAt   time.Time
-}
-
 // SaveSnapshot saves a balance snapshot.
 func (s *Store) SaveSnapshot(ctx context.Context, snap SnapshotRecord) error {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
 	query := `
-		INSERT INTO snapshots (id, entity_id, policy_id, period_start, period_end, balance_json, created_at)
-		VALUES (?, ?, ?, ?, ?, ?, ?)
-		ON CONFLICT(entity_id, policy_id, period_start, period_end) DO UPDATE SET
+		INSERT INTO snapshots (tenant_id, id, entity_id, policy_id, period_start, period_end, balance_json, created_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?)
+		ON CONFLICT(tenant_id, entity_id, policy_id, period_start, period_end) DO UPDATE SET
 			balance_json = excluded.balance_json,
 			created_at = excluded.created_at
 	`
 
 	_, err := s.db.ExecContext(ctx, query,
-		snap.ID, snap.EntityID, snap.PolicyID,
+		tenantOf(ctx), snap.ID, snap.EntityID, snap.PolicyID,
 		snap.PeriodStart.Format(time.RFC3339),
 		snap.PeriodEnd.Format(time.RFC3339),
 		snap.BalanceJSON,
@@ -888,8 +1482,37 @@ func (s *Store) GetSnapshot(ctx context.Context, entityID, policyID string, peri
 
 	err := s.db.QueryRowContext(ctx,
 		`SELECT id, entity_id, policy_id, period_start, period_end, balance_json, created_at 
-		 FROM snapshots WHERE entity_id = ? AND policy_id = ? AND period_start = ? AND period_end = ?`,
-		entityID, policyID, periodStart.Format(time.RFC3339), periodEnd.Format(time.RFC3339),
+		 FROM snapshots WHERE tenant_id = ? AND entity_id = ? AND policy_id = ? AND period_start = ? AND period_end = ?`,
+		tenantOf(ctx), entityID, policyID, periodStart.Format(time.RFC3339), periodEnd.Format(time.RFC3339),
+	).Scan(&snap.ID, &snap.EntityID, &snap.PolicyID, &start, &end, &snap.BalanceJSON, &createdAt)
+
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	snap.PeriodStart, _ = time.Parse(time.RFC3339, start)
+	snap.PeriodEnd, _ = time.Parse(time.RFC3339, end)
+	snap.CreatedAt, _ = time.Parse(time.RFC3339, createdAt)
+	return &snap, nil
+}
+
+// GetLatestSnapshot retrieves the snapshot with the latest period end for
+// entity+policy.
+func (s *Store) GetLatestSnapshot(ctx context.Context, entityID, policyID string) (*SnapshotRecord, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var snap SnapshotRecord
+	var start, end, createdAt string
+
+	err := s.db.QueryRowContext(ctx,
+		`SELECT id, entity_id, policy_id, period_start, period_end, balance_json, created_at 
+		 FROM snapshots WHERE tenant_id = ? AND entity_id = ? AND policy_id = ?
+		 ORDER BY period_end DESC, period_start DESC LIMIT 1`,
+		tenantOf(ctx), entityID, policyID,
 	).Scan(&snap.ID, &snap.EntityID, &snap.PolicyID, &start, &end, &snap.BalanceJSON, &createdAt)
 
 	if err == sql.ErrNoRows {
@@ -905,18 +1528,190 @@ func (s *Store) GetSnapshot(ctx context.Context, entityID, policyID string, peri
 	return &snap, nil
 }
 
+// Snapshots returns the snapshots as a generic.SnapshotStore.
+func (s *Store) Snapshots() generic.SnapshotStore {
+	return record.NewSnapshotStore(s)
+}
+
+// =============================================================================
+// PAY RATE STORE
+// =============================================================================
+
+// SavePayRate saves a pay rate. Saving a second rate for the same
+// employee and effective date returns ErrPayRateExists.
+func (s *Store) SavePayRate(ctx context.Context, r PayRate) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if r.HoursPerDay == 0 {
+		r.HoursPerDay = 8
+	}
+
+	query := `
+		INSERT INTO pay_rates (tenant_id, id, entity_id, rate, currency, unit, hours_per_day, effective_from, created_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)
+	`
+
+	_, err := s.db.ExecContext(ctx, query,
+		tenantOf(ctx), r.ID, r.EntityID, r.Rate.String(), r.Currency, string(r.Unit), r.HoursPerDay,
+		r.EffectiveFrom.Format(time.RFC3339),
+		time.Now().UTC().Format(time.RFC3339),
+	)
+	if isUniqueConstraintError(err) {
+		return record.ErrPayRateExists
+	}
+	return err
+}
+
+// GetPayRates returns an employee's pay rate history, oldest first.
+func (s *Store) GetPayRates(ctx context.Context, entityID string) ([]PayRate, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return s.queryPayRates(ctx, `
+		SELECT id, entity_id, rate, currency, unit, hours_per_day, effective_from, created_at
+		FROM pay_rates WHERE tenant_id = ? AND entity_id = ? ORDER BY effective_from
+	`, tenantOf(ctx), entityID)
+}
+
+// GetPayRateAt returns the pay rate in effect on the given date, or nil.
+func (s *Store) GetPayRateAt(ctx context.Context, entityID string, at time.Time) (*PayRate, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	rates, err := s.queryPayRates(ctx, `
+		SELECT id, entity_id, rate, currency, unit, hours_per_day, effective_from, created_at
+		FROM pay_rates WHERE tenant_id = ? AND entity_id = ? AND effective_from <= ?
+		ORDER BY effective_from DESC LIMIT 1
+	`, tenantOf(ctx), entityID, at.Format(time.RFC3339))
+	if err != nil || len(rates) == 0 {
+		return nil, err
+	}
+	return &rates[0], nil
+}
+
+func (s *Store) queryPayRates(ctx context.Context, query string, args ...any) ([]PayRate, error) {
+	rows, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var rates []PayRate
+	for rows.Next() {
+		var r PayRate
+		var rate, unit, effectiveFrom, createdAt string
+		if err := rows.Scan(&r.ID, &r.EntityID, &rate, &r.Currency, &unit, &r.HoursPerDay, &effectiveFrom, &createdAt); err != nil {
+			return nil, err
+		}
+		r.Rate, _ = decimal.NewFromString(rate)
+		r.Unit = generic.Unit(unit)
+		r.EffectiveFrom, _ = time.Parse(time.RFC3339, effectiveFrom)
+		r.CreatedAt, _ = time.Parse(time.RFC3339, createdAt)
+		rates = append(rates, r)
+	}
+	return rates, rows.Err()
+}
+
+// =============================================================================
+// CALENDAR FEED STORE
+// =============================================================================
+
+// SaveCalendarFeed stores a new feed token.
+func (s *Store) SaveCalendarFeed(ctx context.Context, f CalendarFeed) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	_, err := s.db.ExecContext(ctx, `
+		INSERT INTO calendar_feeds (token, tenant_id, owner_id, scope, created_at)
+		VALUES (?, ?, ?, ?, ?)
+	`, f.Token, tenantOf(ctx), f.OwnerID, f.Scope, time.Now().UTC().Format(time.RFC3339))
+	return err
+}
+
+// GetCalendarFeed returns an active (non-revoked) feed by token, or nil.
+// Tokens are looked up across all tenants: the feed's TenantID is how a
+// feed request finds its tenant.
+func (s *Store) GetCalendarFeed(ctx context.Context, token string) (*CalendarFeed, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	feeds, err := s.queryCalendarFeeds(ctx, `
+		SELECT token, tenant_id, owner_id, scope, created_at, revoked_at
+		FROM calendar_feeds WHERE token = ? AND revoked_at IS NULL
+	`, token)
+	if err != nil || len(feeds) == 0 {
+		return nil, err
+	}
+	return &feeds[0], nil
+}
+
+// GetCalendarFeedsByOwner returns all feeds created by an employee.
+func (s *Store) GetCalendarFeedsByOwner(ctx context.Context, ownerID string) ([]CalendarFeed, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return s.queryCalendarFeeds(ctx, `
+		SELECT token, tenant_id, owner_id, scope, created_at, revoked_at
+		FROM calendar_feeds WHERE tenant_id = ? AND owner_id = ? ORDER BY created_at
+	`, tenantOf(ctx), ownerID)
+}
+
+// RevokeCalendarFeed revokes a feed token. Returns false if the token
+// does not exist for the owner or is already revoked.
+func (s *Store) RevokeCalendarFeed(ctx context.Context, ownerID, token string) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	result, err := s.db.ExecContext(ctx, `
+		UPDATE calendar_feeds SET revoked_at = ?
+		WHERE token = ? AND tenant_id = ? AND owner_id = ? AND revoked_at IS NULL
+	`, time.Now().UTC().Format(time.RFC3339), token, tenantOf(ctx), ownerID)
+	if err != nil {
+		return false, err
+	}
+	n, err := result.RowsAffected()
+	return n > 0, err
+}
+
+func (s *Store) queryCalendarFeeds(ctx context.Context, query string, args ...any) ([]CalendarFeed, error) {
+	rows, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var feeds []CalendarFeed
+	for rows.Next() {
+		var f CalendarFeed
+		var createdAt string
+		var revokedAt sql.NullString
+		if err := rows.Scan(&f.Token, &f.TenantID, &f.OwnerID, &f.Scope, &createdAt, &revokedAt); err != nil {
+			return nil, err
+		}
+		f.CreatedAt, _ = time.Parse(time.RFC3339, createdAt)
+		if revokedAt.Valid {
+			t, _ := time.Parse(time.RFC3339, revokedAt.String)
+			f.RevokedAt = &t
+		}
+		feeds = append(feeds, f)
+	}
+	return feeds, rows.Err()
+}
+
 // =============================================================================
 // UTILITIES
 // =============================================================================
 
-// Reset clears all data (for testing/demo).
+// Reset clears the tenant's data (for testing/demo). Other tenants are
+// untouched.
 func (s *Store) Reset(ctx context.Context) error {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
-	tables := []string{"transactions", "snapshots", "policy_assignments", "employees", "policies"}
+	tables := []string{"transactions", "outbox_events", "webhook_deliveries", "idempotency_keys", "snapshots", "policy_assignments", "pay_rates", "calendar_feeds", "employee_holiday_calendars", "employees", "policies"}
 	for _, table := range tables {
-		if _, err := s.db.ExecContext(ctx, "DELETE FROM "+table); err != nil {
+		if _, err := s.db.ExecContext(ctx, "DELETE FROM "+table+" WHERE tenant_id = ?", tenantOf(ctx)); err != nil {
 			return err
 		}
 	}
@@ -930,13 +1725,15 @@ func (s *Store) GetAllTransactions(ctx context.Context, limit int) ([]generic.Tr
 
 	query := `
 		SELECT id, entity_id, policy_id, resource_type, effective_at, delta_value, delta_unit,
-		       tx_type, reference_id, reason, idempotency_key, metadata_json, created_at
+		       tx_type, reference_id, reason, idempotency_key, metadata_json,
+		       created_by, created_by_type, created_at
 		FROM transactions
+		WHERE tenant_id = ?
 		ORDER BY created_at DESC
 		LIMIT ?
 	`
 
-	return s.queryTransactions(ctx, query, limit)
+	return s.queryTransactions(ctx, query, tenantOf(ctx), limit)
 }
 
 // GetTransaction returns a specific transaction by ID.
@@ -946,12 +1743,13 @@ func (s *Store) GetTransaction(ctx context.Context, id string) (*generic.Transac
 
 	query := `
 		SELECT id, entity_id, policy_id, resource_type, effective_at, delta_value, delta_unit,
-		       tx_type, reference_id, reason, idempotency_key, metadata_json, created_at
+		       tx_type, reference_id, reason, idempotency_key, metadata_json,
+		       created_by, created_by_type, created_at
 		FROM transactions
-		WHERE id = ?
+		WHERE tenant_id = ? AND id = ?
 	`
 
-	txs, err := s.queryTransactions(ctx, query, id)
+	txs, err := s.queryTransactions(ctx, query, tenantOf(ctx), id)
 	if err != nil {
 		return nil, err
 	}
@@ -968,11 +1766,11 @@ func (s *Store) IsTransactionReversed(ctx context.Context, txID string) (bool, e
 
 	query := `
 		SELECT COUNT(*) FROM transactions
-		WHERE reference_id = ? AND tx_type = 'reversal'
+		WHERE tenant_id = ? AND reference_id = ? AND tx_type = 'reversal'
 	`
 
 	var count int
-	err := s.db.QueryRowContext(ctx, query, txID).Scan(&count)
+	err := s.db.QueryRowContext(ctx, query, tenantOf(ctx), txID).Scan(&count)
 	if err != nil {
 		return false, err
 	}
@@ -991,14 +1789,15 @@ func (s *Store) LoadByEntity(ctx context.Context, entityID generic.EntityID, fro
 
 	query := `
 		SELECT id, entity_id, policy_id, resource_type, effective_at, delta_value, delta_unit,
-		       tx_type, reference_id, reason, idempotency_key, metadata_json, created_at
+		       tx_type, reference_id, reason, idempotency_key, metadata_json,
+		       created_by, created_by_type, created_at
 		FROM transactions
-		WHERE entity_id = ? 
+		WHERE tenant_id = ? AND entity_id = ? 
 		  AND effective_at >= ? AND effective_at <= ?
 		ORDER BY effective_at ASC, created_at ASC
 	`
 
-	return s.queryTransactions(ctx, query, entityID,
+	return s.queryTransactions(ctx, query, tenantOf(ctx), entityID,
 		from.Time.Format(time.RFC3339), to.Time.Format(time.RFC3339))
 }
 
@@ -1010,40 +1809,41 @@ func (s *Store) LoadByEntityAndResourceType(ctx context.Context, entityID generi
 
 	query := `
 		SELECT id, entity_id, policy_id, resource_type, effective_at, delta_value, delta_unit,
-		       tx_type, reference_id, reason, idempotency_key, metadata_json, created_at
+		       tx_type, reference_id, reason, idempotency_key, metadata_json,
+		       created_by, created_by_type, created_at
 		FROM transactions
-		WHERE entity_id = ? AND resource_type = ?
+		WHERE tenant_id = ? AND entity_id = ? AND resource_type = ?
 		  AND effective_at >= ? AND effective_at <= ?
 		ORDER BY effective_at ASC, created_at ASC
 	`
 
-	return s.queryTransactions(ctx, query, entityID, resourceType.ResourceID(),
+	return s.queryTransactions(ctx, query, tenantOf(ctx), entityID, resourceType.ResourceID(),
 		from.Time.Format(time.RFC3339), to.Time.Format(time.RFC3339))
 }
 
 // GetConsumedDays returns all days that have consumption/pending transactions.
 // This is the most efficient way to check "what days is this person off?".
-func (s *Store) GetConsumedDays(ctx context.Context, entityID generic.EntityID, resourceType generic.ResourceType, from, to generic.TimePoint) ([]time.Time, error) {
+func (s *Store) GetConsumedDays(ctx context.Context, entityID generic.EntityID, resourceType generic.ResourceType, from, to generic.TimePoint) ([]generic.TimePoint, error) {
 	s.mu.RLock()
 	defer s.mu.RUnlock()
 
 	query := `
 		SELECT DISTINCT DATE(effective_at) as day
 		FROM transactions
-		WHERE entity_id = ? AND resource_type = ?
+		WHERE tenant_id = ? AND entity_id = ? AND resource_type = ?
 		  AND tx_type IN ('consumption', 'pending')
 		  AND effective_at >= ? AND effective_at <= ?
 		ORDER BY day ASC
 	`
 
-	rows, err := s.db.QueryContext(ctx, query, entityID, resourceType.ResourceID(),
+	rows, err := s.db.QueryContext(ctx, query, tenantOf(ctx), entityID, resourceType.ResourceID(),
 		from.Time.Format(time.RFC3339), to.Time.Format(time.RFC3339))
 	if err != nil {
 		return nil, err
 	}
 	defer rows.Close()
 
-	var days []time.Time
+	var days []generic.TimePoint
 	for rows.Next() {
 		var dayStr string
 		if err := rows.Scan(&dayStr); err != nil {
@@ -1051,7 +1851,7 @@ func (s *Store) GetConsumedDays(ctx context.Context, entityID generic.EntityID,
 		}
 		// Parse date (SQLite DATE() returns YYYY-MM-DD)
 		day, _ := time.Parse("2006-01-02", dayStr)
-		days = append(days, day)
+		days = append(days, generic.TimePoint{Time: day})
 	}
 	return days, rows.Err()
 }
@@ -1068,14 +1868,14 @@ func (s *Store) IsDayConsumed(ctx context.Context, entityID generic.EntityID, re
 
 	query := `
 		SELECT id FROM transactions
-		WHERE entity_id = ? AND resource_type = ?
+		WHERE tenant_id = ? AND entity_id = ? AND resource_type = ?
 		  AND tx_type IN ('consumption', 'pending')
 		  AND effective_at >= ? AND effective_at < ?
 		LIMIT 1
 	`
 
 	var txID generic.TransactionID
-	err := s.db.QueryRowContext(ctx, query, entityID, resourceType.ResourceID(),
+	err := s.db.QueryRowContext(ctx, query, tenantOf(ctx), entityID, resourceType.ResourceID(),
 		dayStart.Format(time.RFC3339), dayEnd.Format(time.RFC3339)).Scan(&txID)
 
 	if err == sql.ErrNoRows {
@@ -1092,24 +1892,34 @@ func (s *Store) IsDayConsumed(ctx context.Context, entityID generic.EntityID, re
 // HOLIDAY CALENDAR IMPLEMENTATION
 // =============================================================================
 
-// SaveHoliday saves a holiday to the database.
+// SaveHoliday saves a holiday to the database. Saving an existing ID, or
+// the same company, calendar, date and name, updates the holiday.
 func (s *Store) SaveHoliday(ctx context.Context, h generic.Holiday) error {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
 	query := `
-		INSERT INTO holidays (id, company_id, date, name, recurring, created_at)
-		VALUES (?, ?, ?, ?, ?, ?)
-		ON CONFLICT(company_id, date, name) DO UPDATE SET
-			recurring = excluded.recurring
+		INSERT INTO holidays (tenant_id, id, company_id, calendar_id, date, name, recurring, rule, created_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)
+		ON CONFLICT(tenant_id, id) DO UPDATE SET
+			date = excluded.date,
+			name = excluded.name,
+			recurring = excluded.recurring,
+			rule = excluded.rule
+		ON CONFLICT(tenant_id, company_id, calendar_id, date, name) DO UPDATE SET
+			recurring = excluded.recurring,
+			rule = excluded.rule
 	`
 
 	_, err := s.db.ExecContext(ctx, query,
+		tenantOf(ctx),
 		h.ID,
 		h.CompanyID,
+		h.CalendarID,
 		h.Date.Time.Format("2006-01-02"),
 		h.Name,
-		h.Recurring,
+		h.Recurring || h.Rule != nil,
+		record.HolidayRuleText(h),
 		time.Now().Format(time.RFC3339),
 	)
 	return err
@@ -1120,94 +1930,80 @@ func (s *Store) DeleteHoliday(ctx context.Context, id string) error {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
-	_, err := s.db.ExecContext(ctx, "DELETE FROM holidays WHERE id = ?", id)
+	_, err := s.db.ExecContext(ctx, "DELETE FROM holidays WHERE tenant_id = ? AND id = ?", tenantOf(ctx), id)
 	return err
 }
 
-// GetHolidays returns all holidays for a company in a given year.
-// Includes both company-specific and global holidays.
+// GetHolidays returns all holidays for a company in a given year, with
+// recurring and rule-based holidays moved to their date in that year.
+// Includes both company-specific and global holidays, but not those of
+// named calendars (see GetEntityHolidays). generic.HolidayCalendar takes
+// no context, so this reads the default tenant; see HolidayCalendarFor.
 func (s *Store) GetHolidays(companyID string, year int) []generic.Holiday {
-	s.mu.RLock()
-	defer s.mu.RUnlock()
-
-	// Query for company-specific and global holidays
-	query := `
-		SELECT id, company_id, date, name, recurring
-		FROM holidays
-		WHERE (company_id = ? OR company_id = '')
-		  AND (
-			(recurring = FALSE AND strftime('%Y', date) = ?)
-			OR (recurring = TRUE AND strftime('%m-%d', date) BETWEEN '01-01' AND '12-31')
-		  )
-		ORDER BY date ASC
-	`
+	return s.holidaysInYear(context.Background(), companyID, year)
+}
 
-	rows, err := s.db.Query(query, companyID, fmt.Sprintf("%d", year))
-	if err != nil {
-		return nil
-	}
-	defer rows.Close()
+// IsHoliday checks if a date is a holiday for the given company, in the
+// default tenant.
+func (s *Store) IsHoliday(companyID string, date generic.TimePoint) bool {
+	return record.ContainsHolidayOn(s.GetHolidays(companyID, date.Year()), date)
+}
 
-	var holidays []generic.Holiday
-	for rows.Next() {
-		var h generic.Holiday
-		var dateStr string
-		if err := rows.Scan(&h.ID, &h.CompanyID, &dateStr, &h.Name, &h.Recurring); err != nil {
-			continue
-		}
+// HolidayCalendarFor returns the generic.HolidayCalendar of a tenant.
+func (s *Store) HolidayCalendarFor(tenant generic.TenantID) generic.HolidayCalendar {
+	return tenantHolidays{store: s, ctx: generic.WithTenant(context.Background(), tenant)}
+}
 
-		// Parse date
-		t, _ := time.Parse("2006-01-02", dateStr)
-		// If recurring, adjust year
-		if h.Recurring {
-			t = time.Date(year, t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
-		}
-		h.Date = generic.TimePoint{Time: t, Granularity: generic.GranularityDay}
+// tenantHolidays binds the context-free HolidayCalendar methods to a tenant.
+type tenantHolidays struct {
+	store *Store
+	ctx   context.Context
+}
 
-		holidays = append(holidays, h)
-	}
+func (t tenantHolidays) GetHolidays(companyID string, year int) []generic.Holiday {
+	return t.store.holidaysInYear(t.ctx, companyID, year)
+}
 
-	return holidays
+func (t tenantHolidays) IsHoliday(companyID string, date generic.TimePoint) bool {
+	return record.ContainsHolidayOn(t.GetHolidays(companyID, date.Year()), date)
 }
 
-// IsHoliday checks if a date is a holiday for the given company.
-func (s *Store) IsHoliday(companyID string, date generic.TimePoint) bool {
+func (s *Store) holidaysInYear(ctx context.Context, companyID string, year int) []generic.Holiday {
 	s.mu.RLock()
 	defer s.mu.RUnlock()
 
-	dateStr := date.Time.Format("2006-01-02")
-	monthDay := date.Time.Format("01-02")
-
-	query := `
-		SELECT COUNT(*) FROM holidays
-		WHERE (company_id = ? OR company_id = '')
-		  AND (
-			(recurring = FALSE AND date = ?)
-			OR (recurring = TRUE AND strftime('%m-%d', date) = ?)
-		  )
-	`
-
-	var count int
-	err := s.db.QueryRow(query, companyID, dateStr, monthDay).Scan(&count)
+	holidays, err := s.queryHolidays(ctx, `
+		SELECT id, company_id, calendar_id, date, name, recurring, rule
+		FROM holidays
+		WHERE tenant_id = ?
+		  AND (company_id = ? OR company_id = '')
+		  AND calendar_id = ''
+		  AND (recurring = TRUE OR rule != '' OR strftime('%Y', date) = ?)
+	`, tenantOf(ctx), companyID, fmt.Sprintf("%04d", year))
 	if err != nil {
-		return false
+		return nil
 	}
-	return count > 0
+	return generic.ExpandHolidays(holidays, year)
 }
 
-// GetAllHolidays returns all holidays (for admin UI).
+// GetAllHolidays returns all holidays (for admin UI). Dates are as stored;
+// use GetHolidays for the dates in a given year.
 func (s *Store) GetAllHolidays(ctx context.Context, companyID string) ([]generic.Holiday, error) {
 	s.mu.RLock()
 	defer s.mu.RUnlock()
 
-	query := `
-		SELECT id, company_id, date, name, recurring
+	return s.queryHolidays(ctx, `
+		SELECT id, company_id, calendar_id, date, name, recurring, rule
 		FROM holidays
-		WHERE company_id = ? OR company_id = ''
+		WHERE tenant_id = ? AND (company_id = ? OR company_id = '')
 		ORDER BY date ASC
-	`
+	`, tenantOf(ctx), companyID)
+}
 
-	rows, err := s.db.QueryContext(ctx, query, companyID)
+// queryHolidays scans holiday rows as stored. Rows with an unparseable
+// rule are skipped rather than failing every lookup.
+func (s *Store) queryHolidays(ctx context.Context, query string, args ...any) ([]generic.Holiday, error) {
+	rows, err := s.db.QueryContext(ctx, query, args...)
 	if err != nil {
 		return nil, err
 	}
@@ -1216,89 +2012,313 @@ func (s *Store) GetAllHolidays(ctx context.Context, companyID string) ([]generic
 	var holidays []generic.Holiday
 	for rows.Next() {
 		var h generic.Holiday
-		var dateStr string
-		if err := rows.Scan(&h.ID, &h.CompanyID, &dateStr, &h.Name, &h.Recurring); err != nil {
+		var dateStr, rule string
+		if err := rows.Scan(&h.ID, &h.CompanyID, &h.CalendarID, &dateStr, &h.Name, &h.Recurring, &rule); err != nil {
 			return nil, err
 		}
+		if rule != "" {
+			r, err := generic.ParseHolidayRule(rule)
+			if err != nil {
+				continue
+			}
+			h.Rule = &r
+		}
 		t, _ := time.Parse("2006-01-02", dateStr)
 		h.Date = generic.TimePoint{Time: t, Granularity: generic.GranularityDay}
 		holidays = append(holidays, h)
 	}
-
 	return holidays, rows.Err()
 }
 
-// =============================================================================
-// REQUEST STORE (for approval workflow)
-// =============================================================================
+// ImportHolidays bulk-loads holidays for a company in one transaction.
+// Rows that already exist (same company, calendar, date and name -
+// idx_holidays_unique) are reported as duplicates and left untouched. IDs
+// are derived from the unique key, so re-importing the same file is a no-op.
+func (s *Store) ImportHolidays(ctx context.Context, companyID string, holidays []generic.Holiday, opts HolidayImportOptions) (*HolidayImportResult, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	sqlTx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer sqlTx.Rollback()
+
+	result := &HolidayImportResult{Items: make([]HolidayImportItem, 0, len(holidays))}
+
+	if opts.ReplaceYear != 0 {
+		res, err := sqlTx.ExecContext(ctx,
+			"DELETE FROM holidays WHERE tenant_id = ? AND company_id = ? AND calendar_id = ? AND strftime('%Y', date) = ?",
+			tenantOf(ctx), companyID, opts.CalendarID, fmt.Sprintf("%04d", opts.ReplaceYear))
+		if err != nil {
+			return nil, fmt.Errorf("failed to replace holidays: %w", err)
+		}
+		removed, _ := res.RowsAffected()
+		result.Removed = int(removed)
+	}
 
-// Request represents a time-off request in storage.
-type Request struct {
-	ID               string
-	EntityID         string
-	ResourceType     string
-	EffectiveAt      time.Time
-	Amount           float64
-	Unit             string
-	Status           string // pending, approved, rejected, cancelled
-	RequiresApproval bool
-	ApprovedBy       string
-	ApprovedAt       *time.Time
-	RejectionReason  string
-	Reason           string
-	DistributionJSON string
-	CreatedAt        time.Time
-	UpdatedAt        time.Time
+	now := time.Now().Format(time.RFC3339)
+	for _, h := range holidays {
+		h.CompanyID = companyID
+		h.CalendarID = opts.CalendarID
+		date := h.Date.Time.Format("2006-01-02")
+		h.ID = record.HolidayImportID(companyID, opts.CalendarID, date, h.Name)
+
+		if opts.ReplaceYear != 0 && h.Date.Time.Year() != opts.ReplaceYear {
+			result.OutOfYear++
+			result.Items = append(result.Items, HolidayImportItem{Holiday: h, Status: HolidayImportOutOfYear})
+			continue
+		}
+
+		res, err := sqlTx.ExecContext(ctx, `
+			INSERT INTO holidays (tenant_id, id, company_id, calendar_id, date, name, recurring, rule, created_at)
+			VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)
+			ON CONFLICT DO NOTHING
+		`, tenantOf(ctx), h.ID, companyID, opts.CalendarID, date, h.Name, h.Recurring || h.Rule != nil, record.HolidayRuleText(h), now)
+		if err != nil {
+			return nil, fmt.Errorf("failed to import holiday %s %q: %w", date, h.Name, err)
+		}
+
+		status := HolidayImportCreated
+		if n, _ := res.RowsAffected(); n == 0 {
+			status = HolidayImportDuplicate
+			result.Duplicates++
+		} else {
+			result.Created++
+		}
+		result.Items = append(result.Items, HolidayImportItem{Holiday: h, Status: status})
+	}
+
+	if opts.DryRun {
+		return result, nil
+	}
+	if err := sqlTx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit holiday import: %w", err)
+	}
+	return result, nil
 }
 
-// SaveRequest saves a request to the database.
-func (s *Store) SaveRequest(ctx context.Context, r Request) error {
+// =============================================================================
+// NAMED HOLIDAY CALENDARS (generic.EntityHolidayCalendar)
+// =============================================================================
+
+// SaveHolidayCalendar creates or renames a holiday calendar.
+func (s *Store) SaveHolidayCalendar(ctx context.Context, c HolidayCalendarRecord) error {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
 	query := `
-		INSERT INTO requests (id, entity_id, resource_type, effective_at, amount, unit, status,
-			requires_approval, approved_by, approved_at, rejection_reason, reason, 
-			distribution_json, created_at, updated_at)
-		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
-		ON CONFLICT(id) DO UPDATE SET
-			status = excluded.status,
-			approved_by = excluded.approved_by,
-			approved_at = excluded.approved_at,
-			rejection_reason = excluded.rejection_reason,
-			updated_at = excluded.updated_at
+		INSERT INTO holiday_calendars (tenant_id, id, company_id, name, created_at)
+		VALUES (?, ?, ?, ?, ?)
+		ON CONFLICT(tenant_id, id) DO UPDATE SET
+			name = excluded.name
 	`
 
-	var approvedAt *string
-	if r.ApprovedAt != nil {
-		s := r.ApprovedAt.Format(time.RFC3339)
-		approvedAt = &s
-	}
-
-	_, err := s.db.ExecContext(ctx, query,
-		r.ID, r.EntityID, r.ResourceType, r.EffectiveAt.Format(time.RFC3339),
-		r.Amount, r.Unit, r.Status, r.RequiresApproval, r.ApprovedBy,
-		approvedAt, r.RejectionReason, r.Reason, r.DistributionJSON,
-		r.CreatedAt.Format(time.RFC3339), r.UpdatedAt.Format(time.RFC3339),
-	)
+	_, err := s.db.ExecContext(ctx, query, tenantOf(ctx), c.ID, c.CompanyID, c.Name, time.Now().UTC().Format(time.RFC3339))
 	return err
 }
 
-// GetRequest retrieves a request by ID.
-func (s *Store) GetRequest(ctx context.Context, id string) (*Request, error) {
+// GetHolidayCalendar returns a holiday calendar by ID, or nil.
+func (s *Store) GetHolidayCalendar(ctx context.Context, id string) (*HolidayCalendarRecord, error) {
 	s.mu.RLock()
 	defer s.mu.RUnlock()
 
-	query := `
-		SELECT id, entity_id, resource_type, effective_at, amount, unit, status,
-			requires_approval, approved_by, approved_at, rejection_reason, reason,
-			distribution_json, created_at, updated_at
-		FROM requests WHERE id = ?
-	`
+	calendars, err := s.queryHolidayCalendars(ctx, `
+		SELECT id, company_id, name, created_at FROM holiday_calendars WHERE tenant_id = ? AND id = ?
+	`, tenantOf(ctx), id)
+	if err != nil || len(calendars) == 0 {
+		return nil, err
+	}
+	return &calendars[0], nil
+}
 
-	var r Request
-	var effectiveAt, approvedAt, createdAt, updatedAt sql.NullString
-	err := s.db.QueryRowContext(ctx, query, id).Scan(
+// ListHolidayCalendars returns all holiday calendars.
+func (s *Store) ListHolidayCalendars(ctx context.Context) ([]HolidayCalendarRecord, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return s.queryHolidayCalendars(ctx, `
+		SELECT id, company_id, name, created_at FROM holiday_calendars WHERE tenant_id = ? ORDER BY id
+	`, tenantOf(ctx))
+}
+
+func (s *Store) queryHolidayCalendars(ctx context.Context, query string, args ...any) ([]HolidayCalendarRecord, error) {
+	rows, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var calendars []HolidayCalendarRecord
+	for rows.Next() {
+		var c HolidayCalendarRecord
+		var createdAt string
+		if err := rows.Scan(&c.ID, &c.CompanyID, &c.Name, &createdAt); err != nil {
+			return nil, err
+		}
+		c.CreatedAt, _ = time.Parse(time.RFC3339, createdAt)
+		calendars = append(calendars, c)
+	}
+	return calendars, rows.Err()
+}
+
+// AssignHolidayCalendar assigns a calendar to an employee from a date.
+// Assigning twice on the same date replaces the first assignment.
+func (s *Store) AssignHolidayCalendar(ctx context.Context, a EmployeeHolidayCalendar) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	query := `
+		INSERT INTO employee_holiday_calendars (tenant_id, id, entity_id, calendar_id, effective_from, created_at)
+		VALUES (?, ?, ?, ?, ?, ?)
+		ON CONFLICT(tenant_id, entity_id, effective_from) DO UPDATE SET
+			calendar_id = excluded.calendar_id
+	`
+
+	_, err := s.db.ExecContext(ctx, query,
+		tenantOf(ctx), a.ID, a.EntityID, a.CalendarID,
+		a.EffectiveFrom.Format(time.RFC3339),
+		time.Now().UTC().Format(time.RFC3339),
+	)
+	return err
+}
+
+// GetEmployeeHolidayCalendars returns an employee's calendar history, oldest first.
+func (s *Store) GetEmployeeHolidayCalendars(ctx context.Context, entityID string) ([]EmployeeHolidayCalendar, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return s.employeeHolidayCalendars(ctx, entityID)
+}
+
+func (s *Store) employeeHolidayCalendars(ctx context.Context, entityID string) ([]EmployeeHolidayCalendar, error) {
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT id, entity_id, calendar_id, effective_from, created_at
+		FROM employee_holiday_calendars WHERE tenant_id = ? AND entity_id = ? ORDER BY effective_from
+	`, tenantOf(ctx), entityID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var history []EmployeeHolidayCalendar
+	for rows.Next() {
+		var a EmployeeHolidayCalendar
+		var effectiveFrom, createdAt string
+		if err := rows.Scan(&a.ID, &a.EntityID, &a.CalendarID, &effectiveFrom, &createdAt); err != nil {
+			return nil, err
+		}
+		a.EffectiveFrom, _ = time.Parse(time.RFC3339, effectiveFrom)
+		a.CreatedAt, _ = time.Parse(time.RFC3339, createdAt)
+		history = append(history, a)
+	}
+	return history, rows.Err()
+}
+
+func (s *Store) loadEntityCalendars(ctx context.Context, entityID generic.EntityID) (*record.EntityCalendars, error) {
+	history, err := s.employeeHolidayCalendars(ctx, string(entityID))
+	if err != nil {
+		return nil, err
+	}
+	calendars, err := s.queryHolidayCalendars(ctx,
+		"SELECT id, company_id, name, created_at FROM holiday_calendars WHERE tenant_id = ?", tenantOf(ctx))
+	if err != nil {
+		return nil, err
+	}
+	ec := &record.EntityCalendars{History: history, Companies: make(map[string]string, len(calendars))}
+	for _, c := range calendars {
+		ec.Companies[c.ID] = c.CompanyID
+	}
+	return ec, nil
+}
+
+// IsEntityHoliday checks if a date is a holiday under the calendar
+// assigned to the entity on that date.
+func (s *Store) IsEntityHoliday(ctx context.Context, entityID generic.EntityID, date generic.TimePoint) bool {
+	return record.ContainsHolidayOn(s.GetEntityHolidays(ctx, entityID, date.Year()), date)
+}
+
+// GetEntityHolidays returns the entity's holidays in a year, each resolved
+// against the calendar in effect on its (observed) date.
+func (s *Store) GetEntityHolidays(ctx context.Context, entityID generic.EntityID, year int) []generic.Holiday {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	ec, err := s.loadEntityCalendars(ctx, entityID)
+	if err != nil {
+		return nil
+	}
+
+	holidays, err := s.queryHolidays(ctx, `
+		SELECT id, company_id, calendar_id, date, name, recurring, rule
+		FROM holidays
+		WHERE tenant_id = ? AND (recurring = TRUE OR rule != '' OR strftime('%Y', date) = ?)
+	`, tenantOf(ctx), fmt.Sprintf("%04d", year))
+	if err != nil {
+		return nil
+	}
+
+	var result []generic.Holiday
+	for _, h := range generic.ExpandHolidays(holidays, year) {
+		if ec.Applies(h) {
+			result = append(result, h)
+		}
+	}
+	return result
+}
+
+// =============================================================================
+// REQUEST STORE (for approval workflow)
+// =============================================================================
+
+// SaveRequest saves a request to the database.
+func (s *Store) SaveRequest(ctx context.Context, r Request) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	query := `
+		INSERT INTO requests (tenant_id, id, entity_id, resource_type, effective_at, amount, unit, status,
+			requires_approval, approved_by, approved_at, rejection_reason, reason, 
+			distribution_json, created_at, updated_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+		ON CONFLICT(tenant_id, id) DO UPDATE SET
+			status = excluded.status,
+			approved_by = excluded.approved_by,
+			approved_at = excluded.approved_at,
+			rejection_reason = excluded.rejection_reason,
+			updated_at = excluded.updated_at
+	`
+
+	var approvedAt *string
+	if r.ApprovedAt != nil {
+		s := r.ApprovedAt.Format(time.RFC3339)
+		approvedAt = &s
+	}
+
+	_, err := s.db.ExecContext(ctx, query,
+		tenantOf(ctx), r.ID, r.EntityID, r.ResourceType, r.EffectiveAt.Format(time.RFC3339),
+		r.Amount, r.Unit, r.Status, r.RequiresApproval, r.ApprovedBy,
+		approvedAt, r.RejectionReason, r.Reason, r.DistributionJSON,
+		r.CreatedAt.Format(time.RFC3339), r.UpdatedAt.Format(time.RFC3339),
+	)
+	return err
+}
+
+// GetRequest retrieves a request by ID.
+func (s *Store) GetRequest(ctx context.Context, id string) (*Request, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	query := `
+		SELECT id, entity_id, resource_type, effective_at, amount, unit, status,
+			requires_approval, approved_by, approved_at, rejection_reason, reason,
+			distribution_json, created_at, updated_at
+		FROM requests WHERE tenant_id = ? AND id = ?
+	`
+
+	var r Request
+	var effectiveAt, approvedAt, createdAt, updatedAt sql.NullString
+	err := s.db.QueryRowContext(ctx, query, tenantOf(ctx), id).Scan(
 		&r.ID, &r.EntityID, &r.ResourceType, &effectiveAt, &r.Amount, &r.Unit,
 		&r.Status, &r.RequiresApproval, &r.ApprovedBy, &approvedAt,
 		&r.RejectionReason, &r.Reason, &r.DistributionJSON, &createdAt, &updatedAt,
@@ -1331,11 +2351,11 @@ func (s *Store) GetPendingRequests(ctx context.Context) ([]Request, error) {
 			requires_approval, approved_by, approved_at, rejection_reason, reason,
 			distribution_json, created_at, updated_at
 		FROM requests
-		WHERE status = 'pending'
+		WHERE tenant_id = ? AND status = 'pending'
 		ORDER BY created_at ASC
 	`
 
-	return s.queryRequests(ctx, query)
+	return s.queryRequests(ctx, query, tenantOf(ctx))
 }
 
 // GetRequestsByEntity returns all requests for an entity.
@@ -1348,11 +2368,11 @@ func (s *Store) GetRequestsByEntity(ctx context.Context, entityID string) ([]Req
 			requires_approval, approved_by, approved_at, rejection_reason, reason,
 			distribution_json, created_at, updated_at
 		FROM requests
-		WHERE entity_id = ?
+		WHERE tenant_id = ? AND entity_id = ?
 		ORDER BY created_at DESC
 	`
 
-	return s.queryRequests(ctx, query, entityID)
+	return s.queryRequests(ctx, query, tenantOf(ctx), entityID)
 }
 
 func (s *Store) queryRequests(ctx context.Context, query string, args ...any) ([]Request, error) {
@@ -1392,41 +2412,32 @@ func (s *Store) queryRequests(ctx context.Context, query string, args ...any) ([
 // RECONCILIATION RUNS STORE
 // =============================================================================
 
-// ReconciliationRun represents a scheduled/completed reconciliation.
-type ReconciliationRun struct {
-	ID          string
-	PolicyID    string
-	EntityID    string
-	PeriodStart time.Time
-	PeriodEnd   time.Time
-	Status      string // pending, running, completed, failed
-	CarriedOver float64
-	Expired     float64
-	Error       string
-	StartedAt   *time.Time
-	CompletedAt *time.Time
-	CreatedAt   time.Time
-}
-
 // SaveReconciliationRun saves a reconciliation run.
 func (s *Store) SaveReconciliationRun(ctx context.Context, r ReconciliationRun) error {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
 	query := `
-		INSERT INTO reconciliation_runs (id, policy_id, entity_id, period_start, period_end,
-			status, carried_over, expired, error, started_at, completed_at, created_at)
-		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
-		ON CONFLICT(entity_id, policy_id, period_start, period_end) DO UPDATE SET
+		INSERT INTO reconciliation_runs (tenant_id, id, batch_id, policy_id, entity_id, period_start, period_end,
+			status, carried_over, expired, error, started_at, completed_at, created_at, rolled_back_at,
+			corrections, corrected_at, corrected_by, corrected_from)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+		ON CONFLICT(tenant_id, entity_id, policy_id, period_start, period_end) DO UPDATE SET
+			batch_id = excluded.batch_id,
 			status = excluded.status,
 			carried_over = excluded.carried_over,
 			expired = excluded.expired,
 			error = excluded.error,
 			started_at = excluded.started_at,
-			completed_at = excluded.completed_at
+			completed_at = excluded.completed_at,
+			rolled_back_at = excluded.rolled_back_at,
+			corrections = excluded.corrections,
+			corrected_at = excluded.corrected_at,
+			corrected_by = excluded.corrected_by,
+			corrected_from = excluded.corrected_from
 	`
 
-	var startedAt, completedAt *string
+	var startedAt, completedAt, rolledBackAt, correctedAt *string
 	if r.StartedAt != nil {
 		s := r.StartedAt.Format(time.RFC3339)
 		startedAt = &s
@@ -1435,12 +2446,21 @@ func (s *Store) SaveReconciliationRun(ctx context.Context, r ReconciliationRun)
 		s := r.CompletedAt.Format(time.RFC3339)
 		completedAt = &s
 	}
+	if r.RolledBackAt != nil {
+		s := r.RolledBackAt.Format(time.RFC3339)
+		rolledBackAt = &s
+	}
+	if r.CorrectedAt != nil {
+		s := r.CorrectedAt.Format(time.RFC3339)
+		correctedAt = &s
+	}
 
 	_, err := s.db.ExecContext(ctx, query,
-		r.ID, r.PolicyID, r.EntityID,
+		tenantOf(ctx), r.ID, r.BatchID, r.PolicyID, r.EntityID,
 		r.PeriodStart.Format(time.RFC3339), r.PeriodEnd.Format(time.RFC3339),
 		r.Status, r.CarriedOver, r.Expired, r.Error,
-		startedAt, completedAt, r.CreatedAt.Format(time.RFC3339),
+		startedAt, completedAt, r.CreatedAt.Format(time.RFC3339), rolledBackAt,
+		r.Corrections, correctedAt, r.CorrectedBy, r.CorrectedFrom,
 	)
 	return err
 }
@@ -1450,28 +2470,67 @@ func (s *Store) GetReconciliationRuns(ctx context.Context, status string) ([]Rec
 	s.mu.RLock()
 	defer s.mu.RUnlock()
 
-	var query string
-	var args []any
-
 	if status != "" {
-		query = `
-			SELECT id, policy_id, entity_id, period_start, period_end, status,
-				carried_over, expired, error, started_at, completed_at, created_at
-			FROM reconciliation_runs
-			WHERE status = ?
-			ORDER BY created_at DESC
-		`
-		args = []any{status}
-	} else {
-		query = `
-			SELECT id, policy_id, entity_id, period_start, period_end, status,
-				carried_over, expired, error, started_at, completed_at, created_at
-			FROM reconciliation_runs
-			ORDER BY created_at DESC
-		`
+		return s.queryReconciliationRuns(ctx, "status = ?", status)
 	}
+	return s.queryReconciliationRuns(ctx, "1 = 1")
+}
 
-	rows, err := s.db.QueryContext(ctx, query, args...)
+// GetReconciliationRun returns a reconciliation run by ID, or nil.
+func (s *Store) GetReconciliationRun(ctx context.Context, id string) (*ReconciliationRun, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	runs, err := s.queryReconciliationRuns(ctx, "id = ?", id)
+	if err != nil || len(runs) == 0 {
+		return nil, err
+	}
+	return &runs[0], nil
+}
+
+// GetReconciliationRunForPeriod returns the run of an entity, policy and
+// period, or nil. There is at most one.
+func (s *Store) GetReconciliationRunForPeriod(ctx context.Context, entityID, policyID string, periodStart, periodEnd time.Time) (*ReconciliationRun, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	runs, err := s.queryReconciliationRuns(ctx, "entity_id = ? AND policy_id = ? AND period_start = ? AND period_end = ?",
+		entityID, policyID, periodStart.Format(time.RFC3339), periodEnd.Format(time.RFC3339))
+	if err != nil || len(runs) == 0 {
+		return nil, err
+	}
+	return &runs[0], nil
+}
+
+// GetReconciliationRunsFor returns an entity's runs of a policy, newest
+// first.
+func (s *Store) GetReconciliationRunsFor(ctx context.Context, entityID, policyID string) ([]ReconciliationRun, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return s.queryReconciliationRuns(ctx, "entity_id = ? AND policy_id = ?", entityID, policyID)
+}
+
+// GetReconciliationRunsByBatch returns the runs (items) of a batch.
+func (s *Store) GetReconciliationRunsByBatch(ctx context.Context, batchID string) ([]ReconciliationRun, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return s.queryReconciliationRuns(ctx, "batch_id = ?", batchID)
+}
+
+// queryReconciliationRuns returns the tenant's runs matching where,
+// newest first.
+func (s *Store) queryReconciliationRuns(ctx context.Context, where string, args ...any) ([]ReconciliationRun, error) {
+	query := `
+		SELECT id, batch_id, policy_id, entity_id, period_start, period_end, status,
+			carried_over, expired, error, started_at, completed_at, created_at, rolled_back_at,
+			corrections, corrected_at, corrected_by, corrected_from
+		FROM reconciliation_runs
+		WHERE tenant_id = ? AND ` + where + `
+		ORDER BY created_at DESC
+	`
+	rows, err := s.db.QueryContext(ctx, query, append([]any{tenantOf(ctx)}, args...)...)
 	if err != nil {
 		return nil, err
 	}
@@ -1480,10 +2539,11 @@ func (s *Store) GetReconciliationRuns(ctx context.Context, status string) ([]Rec
 	var runs []ReconciliationRun
 	for rows.Next() {
 		var r ReconciliationRun
-		var periodStart, periodEnd, startedAt, completedAt, createdAt sql.NullString
+		var periodStart, periodEnd, runError, startedAt, completedAt, createdAt, rolledBackAt, correctedAt sql.NullString
 		if err := rows.Scan(
-			&r.ID, &r.PolicyID, &r.EntityID, &periodStart, &periodEnd, &r.Status,
-			&r.CarriedOver, &r.Expired, &r.Error, &startedAt, &completedAt, &createdAt,
+			&r.ID, &r.BatchID, &r.PolicyID, &r.EntityID, &periodStart, &periodEnd, &r.Status,
+			&r.CarriedOver, &r.Expired, &runError, &startedAt, &completedAt, &createdAt, &rolledBackAt,
+			&r.Corrections, &correctedAt, &r.CorrectedBy, &r.CorrectedFrom,
 		); err != nil {
 			return nil, err
 		}
@@ -1491,14 +2551,11 @@ func (s *Store) GetReconciliationRuns(ctx context.Context, status string) ([]Rec
 		r.PeriodStart, _ = time.Parse(time.RFC3339, periodStart.String)
 		r.PeriodEnd, _ = time.Parse(time.RFC3339, periodEnd.String)
 		r.CreatedAt, _ = time.Parse(time.RFC3339, createdAt.String)
-		if startedAt.Valid {
-			t, _ := time.Parse(time.RFC3339, startedAt.String)
-			r.StartedAt = &t
-		}
-		if completedAt.Valid {
-			t, _ := time.Parse(time.RFC3339, completedAt.String)
-			r.CompletedAt = &t
-		}
+		r.Error = runError.String
+		r.StartedAt = parseNullTime(startedAt)
+		r.CompletedAt = parseNullTime(completedAt)
+		r.RolledBackAt = parseNullTime(rolledBackAt)
+		r.CorrectedAt = parseNullTime(correctedAt)
 
 		runs = append(runs, r)
 	}
@@ -1506,6 +2563,92 @@ func (s *Store) GetReconciliationRuns(ctx context.Context, status string) ([]Rec
 	return runs, rows.Err()
 }
 
+// SaveReconciliationBatch creates or updates a reconciliation batch.
+func (s *Store) SaveReconciliationBatch(ctx context.Context, b ReconciliationBatch) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var completedAt *string
+	if b.CompletedAt != nil {
+		s := b.CompletedAt.Format(time.RFC3339)
+		completedAt = &s
+	}
+	_, err := s.db.ExecContext(ctx, `
+		INSERT INTO reconciliation_batches (tenant_id, id, trigger_source, status, as_of, workers,
+			total, processed, reconciled, failed, carried_over, expired, checkpoint, error,
+			created_at, updated_at, completed_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+		ON CONFLICT(tenant_id, id) DO UPDATE SET
+			status = excluded.status,
+			workers = excluded.workers,
+			total = excluded.total,
+			processed = excluded.processed,
+			reconciled = excluded.reconciled,
+			failed = excluded.failed,
+			carried_over = excluded.carried_over,
+			expired = excluded.expired,
+			checkpoint = excluded.checkpoint,
+			error = excluded.error,
+			updated_at = excluded.updated_at,
+			completed_at = excluded.completed_at
+	`, tenantOf(ctx), b.ID, b.Trigger, b.Status, b.AsOf.Format(time.RFC3339), b.Workers,
+		b.Total, b.Processed, b.Reconciled, b.Failed, b.CarriedOver, b.Expired, b.Checkpoint, b.Error,
+		b.CreatedAt.Format(time.RFC3339), b.UpdatedAt.Format(time.RFC3339), completedAt)
+	return err
+}
+
+// GetReconciliationBatch returns a reconciliation batch by ID, or nil.
+func (s *Store) GetReconciliationBatch(ctx context.Context, id string) (*ReconciliationBatch, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	batches, err := s.queryReconciliationBatches(ctx, "id = ?", id)
+	if err != nil || len(batches) == 0 {
+		return nil, err
+	}
+	return &batches[0], nil
+}
+
+// ListReconciliationBatches returns the tenant's batches, newest first.
+func (s *Store) ListReconciliationBatches(ctx context.Context) ([]ReconciliationBatch, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return s.queryReconciliationBatches(ctx, "1 = 1")
+}
+
+func (s *Store) queryReconciliationBatches(ctx context.Context, where string, args ...any) ([]ReconciliationBatch, error) {
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT id, trigger_source, status, as_of, workers, total, processed, reconciled, failed,
+			carried_over, expired, checkpoint, error, created_at, updated_at, completed_at
+		FROM reconciliation_batches
+		WHERE tenant_id = ? AND `+where+`
+		ORDER BY created_at DESC, id DESC
+	`, append([]any{tenantOf(ctx)}, args...)...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var batches []ReconciliationBatch
+	for rows.Next() {
+		var b ReconciliationBatch
+		var asOf, createdAt, updatedAt string
+		var completedAt sql.NullString
+		if err := rows.Scan(&b.ID, &b.Trigger, &b.Status, &asOf, &b.Workers, &b.Total, &b.Processed,
+			&b.Reconciled, &b.Failed, &b.CarriedOver, &b.Expired, &b.Checkpoint, &b.Error,
+			&createdAt, &updatedAt, &completedAt); err != nil {
+			return nil, err
+		}
+		b.AsOf, _ = time.Parse(time.RFC3339, asOf)
+		b.CreatedAt, _ = time.Parse(time.RFC3339, createdAt)
+		b.UpdatedAt, _ = time.Parse(time.RFC3339, updatedAt)
+		b.CompletedAt = parseNullTime(completedAt)
+		batches = append(batches, b)
+	}
+	return batches, rows.Err()
+}
+
 // IsReconciliationComplete checks if a reconciliation has already been done.
 func (s *Store) IsReconciliationComplete(ctx context.Context, entityID, policyID string, periodEnd time.Time) (bool, error) {
 	s.mu.RLock()
@@ -1513,17 +2656,558 @@ func (s *Store) IsReconciliationComplete(ctx context.Context, entityID, policyID
 
 	query := `
 		SELECT COUNT(*) FROM reconciliation_runs
-		WHERE entity_id = ? AND policy_id = ? AND period_end = ? AND status = 'completed'
+		WHERE tenant_id = ? AND entity_id = ? AND policy_id = ? AND period_end = ? AND status = 'completed'
 	`
 
 	var count int
-	err := s.db.QueryRowContext(ctx, query, entityID, policyID, periodEnd.Format(time.RFC3339)).Scan(&count)
+	err := s.db.QueryRowContext(ctx, query, tenantOf(ctx), entityID, policyID, periodEnd.Format(time.RFC3339)).Scan(&count)
 	if err != nil {
 		return false, err
 	}
 	return count > 0, nil
 }
 
+// =============================================================================
+// OUTBOX AND WEBHOOK STORE
+// =============================================================================
+
+// insertOutboxEvent records the event for a batch of transactions.
+func insertOutboxEvent(ctx context.Context, db interface {
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+}, eventType generic.EventType, txs []generic.Transaction, data map[string]any) error {
+	event, err := record.NewOutboxEvent(ctx, eventType, txs, data)
+	if err != nil {
+		return err
+	}
+
+	_, err = db.ExecContext(ctx, `
+		INSERT INTO outbox_events (tenant_id, id, event_type, payload_json, created_at)
+		VALUES (?, ?, ?, ?, ?)
+	`, tenantOf(ctx), event.ID, eventType, event.Payload, event.CreatedAt.Format(time.RFC3339))
+	if err != nil {
+		return fmt.Errorf("failed to record outbox event: %w", err)
+	}
+	return nil
+}
+
+// ListOutboxEvents returns the most recent events, newest first.
+func (s *Store) ListOutboxEvents(ctx context.Context, limit int) ([]OutboxEvent, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT id, event_type, payload_json, created_at, dispatched_at
+		FROM outbox_events WHERE tenant_id = ?
+		ORDER BY created_at DESC, id DESC LIMIT ?
+	`, tenantOf(ctx), limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var events []OutboxEvent
+	for rows.Next() {
+		var e OutboxEvent
+		var createdAt string
+		var dispatchedAt sql.NullString
+		if err := rows.Scan(&e.ID, &e.Type, &e.Payload, &createdAt, &dispatchedAt); err != nil {
+			return nil, err
+		}
+		e.CreatedAt, _ = time.Parse(time.RFC3339, createdAt)
+		e.DispatchedAt = parseNullTime(dispatchedAt)
+		events = append(events, e)
+	}
+	return events, rows.Err()
+}
+
+// DispatchOutbox fans undispatched events out to one pending delivery per
+// active endpoint that accepts the event type, and marks them dispatched.
+// Events with no matching endpoint are marked dispatched too. Returns the
+// number of events dispatched.
+func (s *Store) DispatchOutbox(ctx context.Context, limit int) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	tenant := tenantOf(ctx)
+	endpoints, err := s.queryWebhookEndpoints(ctx, `
+		SELECT id, url, secret, event_types, description, active, created_at
+		FROM webhook_endpoints WHERE tenant_id = ? AND active = 1
+	`, tenant)
+	if err != nil {
+		return 0, err
+	}
+
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT id, event_type FROM outbox_events
+		WHERE tenant_id = ? AND dispatched_at IS NULL
+		ORDER BY created_at, id LIMIT ?
+	`, tenant, limit)
+	if err != nil {
+		return 0, err
+	}
+	type pendingEvent struct {
+		id        string
+		eventType generic.EventType
+	}
+	var events []pendingEvent
+	for rows.Next() {
+		var e pendingEvent
+		if err := rows.Scan(&e.id, &e.eventType); err != nil {
+			rows.Close()
+			return 0, err
+		}
+		events = append(events, e)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, err
+	}
+	if len(events) == 0 {
+		return 0, nil
+	}
+
+	sqlTx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer sqlTx.Rollback()
+
+	now := time.Now().UTC().Format(time.RFC3339)
+	for _, e := range events {
+		for _, endpoint := range endpoints {
+			if !endpoint.Accepts(e.eventType) {
+				continue
+			}
+			if _, err := sqlTx.ExecContext(ctx, `
+				INSERT INTO webhook_deliveries
+				(tenant_id, id, endpoint_id, event_id, event_type, status, attempts, next_attempt_at, created_at)
+				VALUES (?, ?, ?, ?, ?, ?, 0, ?, ?)
+				ON CONFLICT(tenant_id, endpoint_id, event_id) DO NOTHING
+			`, tenant, record.NewEventID("dlv"), endpoint.ID, e.id, e.eventType, DeliveryPending, now, now); err != nil {
+				return 0, fmt.Errorf("failed to create delivery: %w", err)
+			}
+		}
+		if _, err := sqlTx.ExecContext(ctx,
+			"UPDATE outbox_events SET dispatched_at = ? WHERE tenant_id = ? AND id = ?",
+			now, tenant, e.id); err != nil {
+			return 0, err
+		}
+	}
+	return len(events), sqlTx.Commit()
+}
+
+// SaveWebhookEndpoint creates or updates a webhook endpoint.
+func (s *Store) SaveWebhookEndpoint(ctx context.Context, e WebhookEndpoint) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if e.CreatedAt.IsZero() {
+		e.CreatedAt = time.Now()
+	}
+	types := make([]string, len(e.EventTypes))
+	for i, t := range e.EventTypes {
+		types[i] = string(t)
+	}
+
+	_, err := s.db.ExecContext(ctx, `
+		INSERT INTO webhook_endpoints (tenant_id, id, url, secret, event_types, description, active, created_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?)
+		ON CONFLICT(tenant_id, id) DO UPDATE SET
+			url = excluded.url,
+			secret = excluded.secret,
+			event_types = excluded.event_types,
+			description = excluded.description,
+			active = excluded.active
+	`, tenantOf(ctx), e.ID, e.URL, e.Secret, strings.Join(types, ","), e.Description, e.Active,
+		e.CreatedAt.UTC().Format(time.RFC3339))
+	return err
+}
+
+// GetWebhookEndpoint returns an endpoint by ID, or nil.
+func (s *Store) GetWebhookEndpoint(ctx context.Context, id string) (*WebhookEndpoint, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	endpoints, err := s.queryWebhookEndpoints(ctx, `
+		SELECT id, url, secret, event_types, description, active, created_at
+		FROM webhook_endpoints WHERE tenant_id = ? AND id = ?
+	`, tenantOf(ctx), id)
+	if err != nil || len(endpoints) == 0 {
+		return nil, err
+	}
+	return &endpoints[0], nil
+}
+
+// ListWebhookEndpoints returns all endpoints, active or not.
+func (s *Store) ListWebhookEndpoints(ctx context.Context) ([]WebhookEndpoint, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return s.queryWebhookEndpoints(ctx, `
+		SELECT id, url, secret, event_types, description, active, created_at
+		FROM webhook_endpoints WHERE tenant_id = ? ORDER BY created_at, id
+	`, tenantOf(ctx))
+}
+
+func (s *Store) queryWebhookEndpoints(ctx context.Context, query string, args ...any) ([]WebhookEndpoint, error) {
+	rows, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var endpoints []WebhookEndpoint
+	for rows.Next() {
+		var e WebhookEndpoint
+		var types, createdAt string
+		var description sql.NullString
+		if err := rows.Scan(&e.ID, &e.URL, &e.Secret, &types, &description, &e.Active, &createdAt); err != nil {
+			return nil, err
+		}
+		for _, t := range strings.Split(types, ",") {
+			if t != "" {
+				e.EventTypes = append(e.EventTypes, generic.EventType(t))
+			}
+		}
+		e.Description = description.String
+		e.CreatedAt, _ = time.Parse(time.RFC3339, createdAt)
+		endpoints = append(endpoints, e)
+	}
+	return endpoints, rows.Err()
+}
+
+// DueWebhookDeliveries returns pending deliveries whose next attempt is
+// due, oldest first, with their event payloads.
+func (s *Store) DueWebhookDeliveries(ctx context.Context, now time.Time, limit int) ([]WebhookDelivery, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return s.queryWebhookDeliveries(ctx, `
+		SELECT d.id, d.endpoint_id, d.event_id, d.event_type, d.status, d.attempts,
+		       d.next_attempt_at, d.last_status_code, d.last_error, d.delivered_at, d.created_at,
+		       e.payload_json
+		FROM webhook_deliveries d
+		JOIN outbox_events e ON e.tenant_id = d.tenant_id AND e.id = d.event_id
+		WHERE d.tenant_id = ? AND d.status = ? AND d.next_attempt_at <= ?
+		ORDER BY d.next_attempt_at, d.id LIMIT ?
+	`, tenantOf(ctx), DeliveryPending, now.UTC().Format(time.RFC3339), limit)
+}
+
+// ListWebhookDeliveries returns an endpoint's deliveries, newest first,
+// optionally filtered by status.
+func (s *Store) ListWebhookDeliveries(ctx context.Context, endpointID, status string, limit int) ([]WebhookDelivery, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return s.queryWebhookDeliveries(ctx, `
+		SELECT id, endpoint_id, event_id, event_type, status, attempts,
+		       next_attempt_at, last_status_code, last_error, delivered_at, created_at, ''
+		FROM webhook_deliveries
+		WHERE tenant_id = ? AND endpoint_id = ? AND (? = '' OR status = ?)
+		ORDER BY created_at DESC, id DESC LIMIT ?
+	`, tenantOf(ctx), endpointID, status, status, limit)
+}
+
+// GetWebhookDelivery returns a delivery by ID, or nil.
+func (s *Store) GetWebhookDelivery(ctx context.Context, id string) (*WebhookDelivery, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	deliveries, err := s.queryWebhookDeliveries(ctx, `
+		SELECT id, endpoint_id, event_id, event_type, status, attempts,
+		       next_attempt_at, last_status_code, last_error, delivered_at, created_at, ''
+		FROM webhook_deliveries WHERE tenant_id = ? AND id = ?
+	`, tenantOf(ctx), id)
+	if err != nil || len(deliveries) == 0 {
+		return nil, err
+	}
+	return &deliveries[0], nil
+}
+
+// SaveWebhookDelivery records the outcome of a delivery attempt.
+func (s *Store) SaveWebhookDelivery(ctx context.Context, d WebhookDelivery) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	_, err := s.db.ExecContext(ctx, `
+		UPDATE webhook_deliveries SET
+			status = ?, attempts = ?, next_attempt_at = ?, last_status_code = ?,
+			last_error = ?, delivered_at = ?
+		WHERE tenant_id = ? AND id = ?
+	`, d.Status, d.Attempts, formatNullTime(d.NextAttemptAt), d.LastStatusCode,
+		nullString(d.LastError), formatNullTime(d.DeliveredAt), tenantOf(ctx), d.ID)
+	return err
+}
+
+// ReplayWebhookDeliveries queues deliveries to an endpoint again, with
+// fresh attempts. Without since, every dead delivery is requeued. With
+// since, every event the endpoint accepts from that time on is queued,
+// including events from before the endpoint existed. Returns the number
+// of deliveries queued.
+func (s *Store) ReplayWebhookDeliveries(ctx context.Context, endpointID string, since *time.Time) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	tenant := tenantOf(ctx)
+	now := time.Now().UTC().Format(time.RFC3339)
+
+	if since == nil {
+		result, err := s.db.ExecContext(ctx, `
+			UPDATE webhook_deliveries SET status = ?, attempts = 0, next_attempt_at = ?, last_error = NULL
+			WHERE tenant_id = ? AND endpoint_id = ? AND status = ?
+		`, DeliveryPending, now, tenant, endpointID, DeliveryDead)
+		if err != nil {
+			return 0, err
+		}
+		n, err := result.RowsAffected()
+		return int(n), err
+	}
+
+	endpoints, err := s.queryWebhookEndpoints(ctx, `
+		SELECT id, url, secret, event_types, description, active, created_at
+		FROM webhook_endpoints WHERE tenant_id = ? AND id = ?
+	`, tenant, endpointID)
+	if err != nil || len(endpoints) == 0 {
+		return 0, err
+	}
+
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT id, event_type FROM outbox_events
+		WHERE tenant_id = ? AND created_at >= ? ORDER BY created_at, id
+	`, tenant, since.UTC().Format(time.RFC3339))
+	if err != nil {
+		return 0, err
+	}
+	var eventIDs, eventTypes []string
+	for rows.Next() {
+		var id, eventType string
+		if err := rows.Scan(&id, &eventType); err != nil {
+			rows.Close()
+			return 0, err
+		}
+		if endpoints[0].Accepts(generic.EventType(eventType)) {
+			eventIDs = append(eventIDs, id)
+			eventTypes = append(eventTypes, eventType)
+		}
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, err
+	}
+
+	sqlTx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer sqlTx.Rollback()
+
+	for i, eventID := range eventIDs {
+		if _, err := sqlTx.ExecContext(ctx, `
+			INSERT INTO webhook_deliveries
+			(tenant_id, id, endpoint_id, event_id, event_type, status, attempts, next_attempt_at, created_at)
+			VALUES (?, ?, ?, ?, ?, ?, 0, ?, ?)
+			ON CONFLICT(tenant_id, endpoint_id, event_id) DO UPDATE SET
+				status = excluded.status, attempts = 0,
+				next_attempt_at = excluded.next_attempt_at, last_error = NULL
+		`, tenant, record.NewEventID("dlv"), endpointID, eventID, eventTypes[i], DeliveryPending, now, now); err != nil {
+			return 0, fmt.Errorf("failed to queue delivery: %w", err)
+		}
+	}
+	return len(eventIDs), sqlTx.Commit()
+}
+
+// RequeueWebhookDelivery queues one delivery again with fresh attempts,
+// whatever its status. Returns false if it does not exist.
+func (s *Store) RequeueWebhookDelivery(ctx context.Context, id string) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	result, err := s.db.ExecContext(ctx, `
+		UPDATE webhook_deliveries SET status = ?, attempts = 0, next_attempt_at = ?, last_error = NULL
+		WHERE tenant_id = ? AND id = ?
+	`, DeliveryPending, time.Now().UTC().Format(time.RFC3339), tenantOf(ctx), id)
+	if err != nil {
+		return false, err
+	}
+	n, err := result.RowsAffected()
+	return n > 0, err
+}
+
+func (s *Store) queryWebhookDeliveries(ctx context.Context, query string, args ...any) ([]WebhookDelivery, error) {
+	rows, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var deliveries []WebhookDelivery
+	for rows.Next() {
+		var d WebhookDelivery
+		var nextAttemptAt, lastError, deliveredAt sql.NullString
+		var lastStatusCode sql.NullInt64
+		var createdAt string
+		if err := rows.Scan(&d.ID, &d.EndpointID, &d.EventID, &d.EventType, &d.Status, &d.Attempts,
+			&nextAttemptAt, &lastStatusCode, &lastError, &deliveredAt, &createdAt, &d.Payload); err != nil {
+			return nil, err
+		}
+		d.NextAttemptAt = parseNullTime(nextAttemptAt)
+		d.LastStatusCode = int(lastStatusCode.Int64)
+		d.LastError = lastError.String
+		d.DeliveredAt = parseNullTime(deliveredAt)
+		d.CreatedAt, _ = time.Parse(time.RFC3339, createdAt)
+		deliveries = append(deliveries, d)
+	}
+	return deliveries, rows.Err()
+}
+
+// =============================================================================
+// IDEMPOTENCY KEY STORE
+// =============================================================================
+
+// ReserveIdempotencyKey claims a key for a request about to run. If the
+// key was already claimed within ttl, nothing is written and the existing
+// record is returned (in progress or completed). Older records are
+// purged first, so an expired key starts over.
+func (s *Store) ReserveIdempotencyKey(ctx context.Context, rec IdempotencyRecord, ttl time.Duration) (*IdempotencyRecord, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	tenant := tenantOf(ctx)
+	now := time.Now().UTC()
+	if _, err := s.db.ExecContext(ctx,
+		"DELETE FROM idempotency_keys WHERE created_at < ?",
+		now.Add(-ttl).Format(time.RFC3339)); err != nil {
+		return nil, fmt.Errorf("failed to purge idempotency keys: %w", err)
+	}
+
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT actor, key, method, path, request_hash, status_code, content_type,
+		       response_body, created_at, completed_at
+		FROM idempotency_keys WHERE tenant_id = ? AND actor = ? AND key = ?
+	`, tenant, rec.Actor, rec.Key)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	if rows.Next() {
+		var existing IdempotencyRecord
+		var statusCode sql.NullInt64
+		var contentType, completedAt sql.NullString
+		var createdAt string
+		if err := rows.Scan(&existing.Actor, &existing.Key, &existing.Method, &existing.Path,
+			&existing.RequestHash, &statusCode, &contentType, &existing.Body, &createdAt, &completedAt); err != nil {
+			return nil, err
+		}
+		existing.StatusCode = int(statusCode.Int64)
+		existing.ContentType = contentType.String
+		existing.CreatedAt, _ = time.Parse(time.RFC3339, createdAt)
+		existing.CompletedAt = parseNullTime(completedAt)
+		return &existing, nil
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	rows.Close()
+
+	_, err = s.db.ExecContext(ctx, `
+		INSERT INTO idempotency_keys (tenant_id, actor, key, method, path, request_hash, created_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?)
+	`, tenant, rec.Actor, rec.Key, rec.Method, rec.Path, rec.RequestHash, now.Format(time.RFC3339))
+	if err != nil {
+		return nil, fmt.Errorf("failed to reserve idempotency key: %w", err)
+	}
+	return nil, nil
+}
+
+// CompleteIdempotencyKey stores the response of a reserved key.
+func (s *Store) CompleteIdempotencyKey(ctx context.Context, rec IdempotencyRecord) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	_, err := s.db.ExecContext(ctx, `
+		UPDATE idempotency_keys SET status_code = ?, content_type = ?, response_body = ?, completed_at = ?
+		WHERE tenant_id = ? AND actor = ? AND key = ?
+	`, rec.StatusCode, rec.ContentType, rec.Body, time.Now().UTC().Format(time.RFC3339),
+		tenantOf(ctx), rec.Actor, rec.Key)
+	return err
+}
+
+// ReleaseIdempotencyKey forgets a reserved key, so the request can be
+// retried (e.g. after a server error).
+func (s *Store) ReleaseIdempotencyKey(ctx context.Context, actor, key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	_, err := s.db.ExecContext(ctx,
+		"DELETE FROM idempotency_keys WHERE tenant_id = ? AND actor = ? AND key = ?",
+		tenantOf(ctx), actor, key)
+	return err
+}
+
+// =============================================================================
+// LEASES
+// =============================================================================
+
+// AcquireLease takes the named lease for holder, or renews it if holder
+// already has it, and reports whether holder now holds it. A lease held
+// by someone else is only taken once it has expired. The upsert is one
+// statement, so processes sharing the database file cannot both win.
+func (s *Store) AcquireLease(ctx context.Context, name, holder string, ttl time.Duration) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now().UTC()
+	res, err := s.db.ExecContext(ctx, `
+		INSERT INTO leases (name, holder, acquired_at, renewed_at, expires_at) VALUES (?, ?, ?, ?, ?)
+		ON CONFLICT(name) DO UPDATE SET
+			acquired_at = CASE WHEN leases.holder = excluded.holder THEN leases.acquired_at ELSE excluded.acquired_at END,
+			holder = excluded.holder,
+			renewed_at = excluded.renewed_at,
+			expires_at = excluded.expires_at
+		WHERE leases.holder = excluded.holder OR leases.expires_at <= excluded.renewed_at
+	`, name, holder, now.Format(time.RFC3339), now.Format(time.RFC3339), now.Add(ttl).Format(time.RFC3339))
+	if err != nil {
+		return false, fmt.Errorf("failed to acquire lease: %w", err)
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return n > 0, nil
+}
+
+// ReleaseLease gives up the named lease if holder has it, so another
+// process can take it without waiting for it to expire.
+func (s *Store) ReleaseLease(ctx context.Context, name, holder string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	_, err := s.db.ExecContext(ctx, "DELETE FROM leases WHERE name = ? AND holder = ?", name, holder)
+	return err
+}
+
+// GetLease returns the named lease, or nil if nobody has taken it.
+func (s *Store) GetLease(ctx context.Context, name string) (*Lease, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var l Lease
+	var acquiredAt, renewedAt, expiresAt string
+	err := s.db.QueryRowContext(ctx,
+		"SELECT name, holder, acquired_at, renewed_at, expires_at FROM leases WHERE name = ?", name,
+	).Scan(&l.Name, &l.Holder, &acquiredAt, &renewedAt, &expiresAt)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	l.AcquiredAt, _ = time.Parse(time.RFC3339, acquiredAt)
+	l.RenewedAt, _ = time.Parse(time.RFC3339, renewedAt)
+	l.ExpiresAt, _ = time.Parse(time.RFC3339, expiresAt)
+	return &l, nil
+}
+
 // Helper functions
 
 func nullString(s string) sql.NullString {
@@ -1533,6 +3217,24 @@ func nullString(s string) sql.NullString {
 	return sql.NullString{String: s, Valid: true}
 }
 
+func parseNullTime(s sql.NullString) *time.Time {
+	if !s.Valid || s.String == "" {
+		return nil
+	}
+	t, err := time.Parse(time.RFC3339, s.String)
+	if err != nil {
+		return nil
+	}
+	return &t
+}
+
+func formatNullTime(t *time.Time) sql.NullString {
+	if t == nil {
+		return sql.NullString{}
+	}
+	return sql.NullString{String: t.UTC().Format(time.RFC3339), Valid: true}
+}
+
 func parseAmount(value, unit string) generic.Amount {
 	return generic.Amount{
 		Value: generic.MustParseDecimal(value),
@@ -1549,6 +3251,15 @@ func isDayUniquenessError(err error) bool {
 	return err != nil && contains(err.Error(), "idx_unique_day_consumption")
 }
 
+func containsString(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
+
 func contains(s, substr string) bool {
 	return len(s) >= len(substr) && (s == substr || len(s) > 0 && containsAt(s, substr, 0))
 }
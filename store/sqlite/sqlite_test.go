@@ -0,0 +1,166 @@
+package sqlite_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/warp/resource-engine/generic"
+	"github.com/warp/resource-engine/generic/store/storetest"
+	"github.com/warp/resource-engine/store/sqlite"
+)
+
+func TestStore_Conformance(t *testing.T) {
+	storetest.Run(t, func(t *testing.T) generic.Store {
+		s, err := sqlite.New(":memory:")
+		if err != nil {
+			t.Fatalf("Failed to create store: %v", err)
+		}
+		t.Cleanup(func() { s.Close() })
+		return s
+	})
+}
+
+func TestStore_GenericServicesRunOnAdapters(t *testing.T) {
+	// GIVEN: Standard (priority 1, 10 days, approval above 1 day) and
+	//        carryover (priority 2, 3 days) assignments saved through Assignments()
+	// WHEN: RequestService books 3 days, a further 9 days are distributed,
+	//       and PeriodManager closes the year
+	// THEN: The request waits for approval, the 9 days split 7 + 2, and the
+	//       year-end snapshot reads back through Snapshots()
+	s, err := sqlite.New(":memory:")
+	if err != nil {
+		t.Fatalf("Failed to create store: %v", err)
+	}
+	defer s.Close()
+	ctx := context.Background()
+
+	pto := generic.StringResource{ID: "pto", Domain: "test"}
+	yearly := generic.PeriodConfig{Type: generic.PeriodCalendarYear}
+	days := func(n float64) generic.Amount { return generic.NewAmount(n, generic.UnitDays) }
+	maxCarryover := days(5)
+	policies := map[generic.PolicyID]*generic.Policy{
+		"carryover": {ID: "carryover", ResourceType: pto, Unit: generic.UnitDays, PeriodConfig: yearly},
+		"standard": {ID: "standard", ResourceType: pto, Unit: generic.UnitDays, PeriodConfig: yearly,
+			ReconciliationRules: []generic.ReconciliationRule{{
+				Trigger: generic.ReconciliationTrigger{Type: generic.TriggerPeriodEnd},
+				Actions: []generic.ReconciliationAction{{Type: generic.ActionCarryover, Config: generic.ActionConfig{MaxCarryover: &maxCarryover}}},
+			}}},
+	}
+	assignments := s.Assignments(func(_ context.Context, id generic.PolicyID) (*generic.Policy, bool) {
+		p, ok := policies[id]
+		return p, ok
+	})
+
+	jan1 := generic.NewTimePoint(2025, time.January, 1)
+	mar10 := generic.NewTimePoint(2025, time.March, 10)
+	autoApprove := days(1)
+	for i, a := range []generic.PolicyAssignment{
+		{ID: "a-standard", PolicyID: "standard", ConsumptionPriority: 1,
+			ApprovalConfig: generic.ApprovalConfig{RequiresApproval: true, AutoApproveUpTo: &autoApprove}},
+		{ID: "a-carryover", PolicyID: "carryover", ConsumptionPriority: 2},
+	} {
+		a.EntityID = "emp-1"
+		a.EffectiveFrom = jan1
+		if err := assignments.Save(ctx, a); err != nil {
+			t.Fatalf("Save assignment %d: %v", i, err)
+		}
+	}
+	// An assignment whose policy is unknown is skipped
+	if err := s.SaveAssignment(ctx, sqlite.AssignmentRecord{ID: "a-gone", EntityID: "emp-1", PolicyID: "gone", EffectiveFrom: jan1.Time}); err != nil {
+		t.Fatalf("SaveAssignment: %v", err)
+	}
+
+	ledger := generic.NewLedger(s)
+	for _, g := range []struct {
+		policy generic.PolicyID
+		amount float64
+	}{{"standard", 10}, {"carryover", 3}} {
+		err := ledger.Append(ctx, generic.Transaction{
+			ID: generic.TransactionID("grant-" + g.policy), EntityID: "emp-1", PolicyID: g.policy, ResourceType: pto,
+			EffectiveAt: jan1, Delta: days(g.amount), Type: generic.TxGrant,
+			IdempotencyKey: "grant-" + string(g.policy),
+		})
+		if err != nil {
+			t.Fatalf("Grant %s: %v", g.policy, err)
+		}
+	}
+
+	active, err := assignments.GetActive(ctx, "emp-1", mar10)
+	if err != nil {
+		t.Fatalf("GetActive: %v", err)
+	}
+	if len(active) != 2 {
+		t.Fatalf("Expected 2 active assignments, got %d", len(active))
+	}
+	ac := active[0].ApprovalConfig
+	if !ac.RequiresApproval || ac.AutoApproveUpTo == nil || !ac.AutoApproveUpTo.Value.Equal(autoApprove.Value) {
+		t.Errorf("Approval config did not round-trip: %+v", ac)
+	}
+
+	calculator := &generic.ResourceBalanceCalculator{Ledger: ledger, AssignmentStore: assignments}
+	service := &generic.RequestService{
+		Ledger:          ledger,
+		AssignmentStore: assignments,
+		BalanceCalc:     calculator,
+		Distributor:     &generic.ConsumptionDistributor{},
+	}
+	req, err := service.CreateRequest(ctx, "emp-1", pto, mar10, days(3), "trip")
+	if err != nil {
+		t.Fatalf("CreateRequest: %v", err)
+	}
+	if !req.RequiresApproval || req.Status != generic.RequestPending {
+		t.Errorf("Expected a pending request needing approval, got %s (approval %v)", req.Status, req.RequiresApproval)
+	}
+
+	rb, err := calculator.Calculate(ctx, "emp-1", pto, mar10)
+	if err != nil {
+		t.Fatalf("Calculate: %v", err)
+	}
+	if !rb.TotalAvailable.Value.Equal(days(10).Value) {
+		t.Errorf("Available = %v, want 10 (13 granted - 3 pending)", rb.TotalAvailable.Value)
+	}
+	split := service.Distributor.Distribute(rb, days(9), false)
+	drawn := map[generic.PolicyID]float64{}
+	for _, alloc := range split.Allocations {
+		drawn[alloc.PolicyID] = alloc.Amount.Value.InexactFloat64()
+	}
+	if !split.IsSatisfiable || drawn["standard"] != 7 || drawn["carryover"] != 2 {
+		t.Errorf("Expected 7 from standard and 2 from carryover, got %v", drawn)
+	}
+
+	manager := &generic.PeriodManager{
+		Ledger:        ledger,
+		SnapshotStore: s.Snapshots(),
+		Reconciler:    &generic.ReconciliationEngine{},
+	}
+	year := yearly.PeriodFor(jan1)
+	closed, err := manager.ClosePeriod(ctx, generic.ClosePeriodInput{
+		EntityID: "emp-1",
+		PolicyID: "standard",
+		Policy:   *policies["standard"],
+		Period:   year,
+		Reason:   generic.SnapshotPeriodEnd,
+	})
+	if err != nil {
+		t.Fatalf("ClosePeriod: %v", err)
+	}
+
+	latest, err := s.Snapshots().GetLatest(ctx, "emp-1", "standard")
+	if err != nil {
+		t.Fatalf("GetLatest: %v", err)
+	}
+	if latest == nil {
+		t.Fatal("Expected the year-end snapshot")
+	}
+	if latest.ID != closed.Snapshot.ID || latest.Reason != generic.SnapshotPeriodEnd {
+		t.Errorf("Snapshot = %s (%s), want %s (period_end)", latest.ID, latest.Reason, closed.Snapshot.ID)
+	}
+	if !latest.Balance.TotalEntitlement.Value.Equal(days(10).Value) || !latest.Balance.Pending.Value.Equal(days(3).Value) {
+		t.Errorf("Snapshot balance = %v entitled, %v pending; want 10 and 3",
+			latest.Balance.TotalEntitlement.Value, latest.Balance.Pending.Value)
+	}
+	if !latest.Period.End.Time.Equal(year.End.Time) {
+		t.Errorf("Snapshot period end = %v, want %v", latest.Period.End.Time, year.End.Time)
+	}
+}
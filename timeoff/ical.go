@@ -0,0 +1,274 @@
+/*
+ical.go - iCalendar (RFC 5545) export of days off
+
+PURPOSE:
+  People live in their calendar apps. This file turns the per-day view from
+  TimeOffLedger.GetDaysOff into all-day calendar events that Google
+  Calendar, Outlook and Apple Calendar can subscribe to.
+
+MERGING:
+  The ledger stores one transaction per day. Calendars want one event per
+  absence, so consecutive days with the same resource type and status are
+  merged into a single span:
+
+    Mar 10 pto approved ─┐
+    Mar 11 pto approved  ├─> Mar 10-12 pto approved (1 event)
+    Mar 12 pto approved ─┘
+    Mar 13 pto pending   ──> Mar 13 pto pending (separate: status differs)
+
+  Canceled days are dropped, so a reversal splits or removes its event the
+  next time the feed is fetched.
+
+STATUS:
+  Approved days are STATUS:CONFIRMED, pending days STATUS:TENTATIVE with
+  "(pending)" in the summary. If the same day shows up as both (a pending
+  day whose approval consumption was just written), approved wins.
+
+UIDS:
+  UIDs are derived from entity, resource, start date and status, so they
+  are stable across fetches while the span is unchanged. When a span
+  changes, the old UID disappears from the feed and clients drop it.
+
+EXAMPLE:
+  days, _ := ledger.GetDaysOff(ctx, "emp-123", from, to)
+  cal := timeoff.Calendar{Name: "Alice - Time Off"}
+  for _, span := range timeoff.MergeDaysOff(days) {
+      cal.Events = append(cal.Events, span.Event("emp-123", "Time off", ""))
+  }
+  cal.WriteICS(w, time.Now())
+
+SEE ALSO:
+  - ledger.go: GetDaysOff, DayOffStatus
+  - api/calendar.go: Token-protected feed endpoints
+*/
+package timeoff
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/warp/resource-engine/generic"
+)
+
+// =============================================================================
+// DAY-OFF SPANS
+// =============================================================================
+
+// DayOffSpan is a run of consecutive days off with the same resource type
+// and status.
+type DayOffSpan struct {
+	ResourceType generic.ResourceType
+	Status       DayOffStatus
+	Start        time.Time // First day off
+	End          time.Time // Last day off (inclusive)
+	Days         int
+	RequestIDs   []string
+	Reasons      []string
+}
+
+// MergeDaysOff collapses days off into spans of consecutive days.
+// Canceled days are dropped. Result is sorted by start date.
+func MergeDaysOff(daysOff []DayOff) []DayOffSpan {
+	// 1. One entry per (day, resource type); approved beats pending
+	type dayKey struct {
+		date     string
+		resource string
+	}
+	byDay := make(map[dayKey]DayOff)
+	for _, d := range daysOff {
+		if d.Status == DayOffCanceled || d.ResourceType == nil {
+			continue
+		}
+		k := dayKey{d.Date.Format("2006-01-02"), d.ResourceType.ResourceID()}
+		if existing, ok := byDay[k]; ok && existing.Status == DayOffApproved {
+			continue
+		}
+		byDay[k] = d
+	}
+
+	days := make([]DayOff, 0, len(byDay))
+	for _, d := range byDay {
+		days = append(days, d)
+	}
+	sort.Slice(days, func(i, j int) bool {
+		ri, rj := days[i].ResourceType.ResourceID(), days[j].ResourceType.ResourceID()
+		if ri != rj {
+			return ri < rj
+		}
+		return days[i].Date.Before(days[j].Date)
+	})
+
+	// 2. Merge consecutive days per resource type and status
+	var spans []DayOffSpan
+	for _, d := range days {
+		date := dateOnly(d.Date)
+		if n := len(spans); n > 0 {
+			last := &spans[n-1]
+			if last.ResourceType.ResourceID() == d.ResourceType.ResourceID() &&
+				last.Status == d.Status &&
+				last.End.AddDate(0, 0, 1).Equal(date) {
+				last.End = date
+				last.Days++
+				last.RequestIDs = appendUnique(last.RequestIDs, d.RequestID)
+				last.Reasons = appendUnique(last.Reasons, d.Reason)
+				continue
+			}
+		}
+		spans = append(spans, DayOffSpan{
+			ResourceType: d.ResourceType,
+			Status:       d.Status,
+			Start:        date,
+			End:          date,
+			Days:         1,
+			RequestIDs:   appendUnique(nil, d.RequestID),
+			Reasons:      appendUnique(nil, d.Reason),
+		})
+	}
+
+	sort.SliceStable(spans, func(i, j int) bool {
+		return spans[i].Start.Before(spans[j].Start)
+	})
+	return spans
+}
+
+// Event converts a span into an all-day calendar event.
+// label prefixes the summary (e.g. employee name in team feeds);
+// description is optional free text.
+func (s DayOffSpan) Event(entityID generic.EntityID, label, description string) CalendarEvent {
+	summary := fmt.Sprintf("%s: %s", label, resourceLabel(s.ResourceType))
+	if s.Status == DayOffPending {
+		summary += " (pending)"
+	}
+	return CalendarEvent{
+		UID: fmt.Sprintf("%s-%s-%s-%s@resource-engine",
+			entityID, s.ResourceType.ResourceID(), s.Start.Format("20060102"), s.Status),
+		Start:       s.Start,
+		End:         s.End,
+		Summary:     summary,
+		Description: description,
+		Category:    s.ResourceType.ResourceID(),
+		Tentative:   s.Status == DayOffPending,
+	}
+}
+
+// =============================================================================
+// ICALENDAR OUTPUT
+// =============================================================================
+
+// Calendar is an iCalendar document of all-day events.
+type Calendar struct {
+	Name   string
+	Events []CalendarEvent
+}
+
+// CalendarEvent is a single all-day VEVENT.
+type CalendarEvent struct {
+	UID         string
+	Start       time.Time // First day
+	End         time.Time // Last day (inclusive); written as exclusive DTEND
+	Summary     string
+	Description string
+	Category    string
+	Tentative   bool
+}
+
+// WriteICS writes the calendar in RFC 5545 format. stamp is used for
+// DTSTAMP on every event (the time the feed was generated).
+func (c Calendar) WriteICS(w io.Writer, stamp time.Time) error {
+	var b strings.Builder
+	line := func(s string) { writeFolded(&b, s) }
+
+	line("BEGIN:VCALENDAR")
+	line("VERSION:2.0")
+	line("PRODID:-//Warp//Resource Engine//EN")
+	line("CALSCALE:GREGORIAN")
+	line("METHOD:PUBLISH")
+	if c.Name != "" {
+		line("X-WR-CALNAME:" + escapeText(c.Name))
+	}
+
+	dtstamp := stamp.UTC().Format("20060102T150405Z")
+	for _, e := range c.Events {
+		status := "CONFIRMED"
+		if e.Tentative {
+			status = "TENTATIVE"
+		}
+		line("BEGIN:VEVENT")
+		line("UID:" + e.UID)
+		line("DTSTAMP:" + dtstamp)
+		line("DTSTART;VALUE=DATE:" + e.Start.Format("20060102"))
+		line("DTEND;VALUE=DATE:" + dateOnly(e.End).AddDate(0, 0, 1).Format("20060102"))
+		line("SUMMARY:" + escapeText(e.Summary))
+		if e.Description != "" {
+			line("DESCRIPTION:" + escapeText(e.Description))
+		}
+		if e.Category != "" {
+			line("CATEGORIES:" + escapeText(e.Category))
+		}
+		line("STATUS:" + status)
+		line("TRANSP:OPAQUE")
+		line("END:VEVENT")
+	}
+	line("END:VCALENDAR")
+
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// writeFolded writes a content line, folding at 75 octets (RFC 5545 3.1)
+// without splitting UTF-8 sequences.
+func writeFolded(b *strings.Builder, s string) {
+	const limit = 75
+	width := 0
+	for _, r := range s {
+		n := len(string(r))
+		if width+n > limit {
+			b.WriteString("\r\n ")
+			width = 1
+		}
+		b.WriteRune(r)
+		width += n
+	}
+	b.WriteString("\r\n")
+}
+
+// escapeText escapes a TEXT value (RFC 5545 3.3.11).
+func escapeText(s string) string {
+	return strings.NewReplacer(
+		`\`, `\\`,
+		`;`, `\;`,
+		`,`, `\,`,
+		"\r\n", `\n`,
+		"\n", `\n`,
+	).Replace(s)
+}
+
+func resourceLabel(rt generic.ResourceType) string {
+	id := strings.ReplaceAll(rt.ResourceID(), "_", " ")
+	switch rt.ResourceID() {
+	case "":
+		return "Time off"
+	case string(ResourcePTO):
+		return "PTO"
+	}
+	return strings.ToUpper(id[:1]) + id[1:]
+}
+
+func dateOnly(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+}
+
+func appendUnique(list []string, s string) []string {
+	if s == "" {
+		return list
+	}
+	for _, existing := range list {
+		if existing == s {
+			return list
+		}
+	}
+	return append(list, s)
+}
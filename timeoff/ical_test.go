@@ -0,0 +1,145 @@
+package timeoff_test
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/warp/resource-engine/generic"
+	"github.com/warp/resource-engine/timeoff"
+)
+
+func day(month time.Month, d int) time.Time {
+	return time.Date(2025, month, d, 0, 0, 0, 0, time.UTC)
+}
+
+// =============================================================================
+// SPAN MERGING
+// =============================================================================
+
+func TestMergeDaysOff_ConsecutiveDaysBecomeOneSpan(t *testing.T) {
+	// GIVEN: Mar 10-12 approved PTO, Mar 13 pending PTO, Mar 12 sick
+	// WHEN: Merging
+	// THEN: One approved PTO span, one pending PTO span, one sick span
+
+	days := []timeoff.DayOff{
+		{Date: day(time.March, 10), ResourceType: timeoff.ResourcePTO, Status: timeoff.DayOffApproved},
+		{Date: day(time.March, 11), ResourceType: timeoff.ResourcePTO, Status: timeoff.DayOffApproved},
+		{Date: day(time.March, 12), ResourceType: timeoff.ResourcePTO, Status: timeoff.DayOffApproved},
+		{Date: day(time.March, 13), ResourceType: timeoff.ResourcePTO, Status: timeoff.DayOffPending},
+		{Date: day(time.March, 20), ResourceType: timeoff.ResourceSick, Status: timeoff.DayOffApproved},
+	}
+
+	spans := timeoff.MergeDaysOff(days)
+	require.Len(t, spans, 3)
+
+	assert.Equal(t, day(time.March, 10), spans[0].Start)
+	assert.Equal(t, day(time.March, 12), spans[0].End)
+	assert.Equal(t, 3, spans[0].Days)
+	assert.Equal(t, timeoff.DayOffApproved, spans[0].Status)
+
+	assert.Equal(t, day(time.March, 13), spans[1].Start)
+	assert.Equal(t, timeoff.DayOffPending, spans[1].Status)
+
+	assert.Equal(t, timeoff.ResourceSick, spans[2].ResourceType)
+}
+
+func TestMergeDaysOff_CanceledDaySplitsSpan(t *testing.T) {
+	days := []timeoff.DayOff{
+		{Date: day(time.March, 10), ResourceType: timeoff.ResourcePTO, Status: timeoff.DayOffApproved},
+		{Date: day(time.March, 11), ResourceType: timeoff.ResourcePTO, Status: timeoff.DayOffCanceled},
+		{Date: day(time.March, 12), ResourceType: timeoff.ResourcePTO, Status: timeoff.DayOffApproved},
+	}
+
+	spans := timeoff.MergeDaysOff(days)
+	require.Len(t, spans, 2)
+	assert.Equal(t, day(time.March, 10), spans[0].End)
+	assert.Equal(t, day(time.March, 12), spans[1].Start)
+}
+
+func TestMergeDaysOff_ApprovedWinsOverPendingSameDay(t *testing.T) {
+	days := []timeoff.DayOff{
+		{Date: day(time.March, 10), ResourceType: timeoff.ResourcePTO, Status: timeoff.DayOffPending},
+		{Date: day(time.March, 10), ResourceType: timeoff.ResourcePTO, Status: timeoff.DayOffApproved},
+	}
+
+	spans := timeoff.MergeDaysOff(days)
+	require.Len(t, spans, 1)
+	assert.Equal(t, timeoff.DayOffApproved, spans[0].Status)
+}
+
+// =============================================================================
+// ICS OUTPUT
+// =============================================================================
+
+func TestCalendar_WriteICS_AllDayEventsWithExclusiveEnd(t *testing.T) {
+	span := timeoff.DayOffSpan{
+		ResourceType: timeoff.ResourcePTO,
+		Status:       timeoff.DayOffPending,
+		Start:        day(time.March, 10),
+		End:          day(time.March, 12),
+		Days:         3,
+	}
+	cal := timeoff.Calendar{
+		Name:   "Alice - Time Off",
+		Events: []timeoff.CalendarEvent{span.Event("emp-1", "Time off", "Beach, sun; rest")},
+	}
+
+	var b strings.Builder
+	require.NoError(t, cal.WriteICS(&b, time.Date(2025, 3, 1, 12, 0, 0, 0, time.UTC)))
+	ics := b.String()
+
+	assert.True(t, strings.HasPrefix(ics, "BEGIN:VCALENDAR\r\nVERSION:2.0\r\n"))
+	assert.True(t, strings.HasSuffix(ics, "END:VCALENDAR\r\n"))
+	assert.Contains(t, ics, "DTSTART;VALUE=DATE:20250310\r\n")
+	assert.Contains(t, ics, "DTEND;VALUE=DATE:20250313\r\n")
+	assert.Contains(t, ics, "DTSTAMP:20250301T120000Z\r\n")
+	assert.Contains(t, ics, "SUMMARY:Time off: PTO (pending)\r\n")
+	assert.Contains(t, ics, "STATUS:TENTATIVE\r\n")
+	assert.Contains(t, ics, `DESCRIPTION:Beach\, sun\; rest`)
+	assert.Contains(t, ics, "UID:emp-1-pto-20250310-pending@resource-engine\r\n")
+
+	for _, line := range strings.Split(ics, "\r\n") {
+		assert.LessOrEqual(t, len(line), 75, "line not folded: %q", line)
+	}
+}
+
+// =============================================================================
+// REVERSALS IN GetDaysOff
+// =============================================================================
+
+func TestTimeOffLedger_GetDaysOff_RequestReversalCancelsPendingDay(t *testing.T) {
+	// GIVEN: A pending day whose request was rejected (reversal references
+	//        the request ID, not the transaction ID)
+	// WHEN: Listing days off
+	// THEN: The day is reported as canceled
+
+	ledger, store := newTestTimeOffLedger(t)
+	ctx := context.Background()
+
+	pending := ptoTx("emp-1", "pto", day(time.March, 10), "req-1-0-0")
+	pending.Type = generic.TxPending
+	pending.ReferenceID = "req-1"
+	require.NoError(t, store.Append(ctx, pending))
+
+	require.NoError(t, store.Append(ctx, generic.Transaction{
+		ID:             "req-1-reject-rev-0",
+		EntityID:       "emp-1",
+		PolicyID:       "pto",
+		ResourceType:   timeoff.ResourcePTO,
+		EffectiveAt:    pending.EffectiveAt,
+		Delta:          pending.Delta.Neg(),
+		Type:           generic.TxReversal,
+		ReferenceID:    "req-1",
+		IdempotencyKey: "req-1-reject-rev-0",
+	}))
+
+	days, err := ledger.GetDaysOff(ctx, "emp-1", day(time.March, 1), day(time.March, 31))
+	require.NoError(t, err)
+	require.Len(t, days, 1)
+	assert.Equal(t, timeoff.DayOffCanceled, days[0].Status)
+	assert.Empty(t, timeoff.MergeDaysOff(days))
+}
@@ -348,11 +348,15 @@ func (l *TimeOffLedger) getAllTransactionsForEntity(ctx context.Context, entityI
 func (l *TimeOffLedger) transactionsToDaysOff(txs []generic.Transaction) []DayOff {
 	var daysOff []DayOff
 
-	// Track reversals
+	// Track reversals. Cancellations reference the transaction ID; approval
+	// and rejection reverse pending days by request ID, so those are matched
+	// on (request, policy, day) instead.
 	reversals := make(map[string]bool)
+	requestReversals := make(map[string]bool)
 	for _, tx := range txs {
 		if tx.Type == generic.TxReversal && tx.ReferenceID != "" {
 			reversals[tx.ReferenceID] = true
+			requestReversals[pendingReversalKey(tx)] = true
 		}
 	}
 
@@ -371,6 +375,9 @@ func (l *TimeOffLedger) transactionsToDaysOff(txs []generic.Transaction) []DayOf
 		if reversals[string(tx.ID)] {
 			status = DayOffCanceled
 		}
+		if tx.Type == generic.TxPending && tx.ReferenceID != "" && requestReversals[pendingReversalKey(tx)] {
+			status = DayOffCanceled
+		}
 
 		daysOff = append(daysOff, DayOff{
 			Date:         tx.EffectiveAt.Time,
@@ -390,6 +397,11 @@ func (l *TimeOffLedger) transactionsToDaysOff(txs []generic.Transaction) []DayOf
 	return daysOff
 }
 
+// pendingReversalKey identifies a pending day by request, policy and date.
+func pendingReversalKey(tx generic.Transaction) string {
+	return tx.ReferenceID + "|" + string(tx.PolicyID) + "|" + tx.EffectiveAt.Time.Format("2006-01-02")
+}
+
 // =============================================================================
 // ERROR TYPES
 // =============================================================================
@@ -2,7 +2,11 @@
 // It uses the generic engine with time-off specific policies and accrual schedules.
 package timeoff
 
-import "github.com/warp/resource-engine/generic"
+import (
+	"context"
+
+	"github.com/warp/resource-engine/generic"
+)
 
 // =============================================================================
 // TIME-OFF RESOURCE TYPE
@@ -91,3 +95,15 @@ func (r *TimeOffRequest) FilterWorkdays() {
 	}
 	r.Days = workdays
 }
+
+// FilterWorkdaysFor removes weekends and the entity's holidays, resolved
+// per day from the calendar assigned to the entity on that day.
+func (r *TimeOffRequest) FilterWorkdaysFor(ctx context.Context, calendar generic.EntityHolidayCalendar) {
+	var workdays []generic.TimePoint
+	for _, day := range r.Days {
+		if day.IsWorkdayFor(ctx, calendar, r.EntityID) {
+			workdays = append(workdays, day)
+		}
+	}
+	r.Days = workdays
+}
@@ -68,6 +68,7 @@ export interface Balance {
   total_pending: number;
   policies: PolicyBalance[];
   as_of: string;
+  as_known_at?: string; // Set when bookings recorded after it were left out
 }
 
 export interface PolicyBalance {
@@ -125,6 +126,7 @@ export interface RolloverResult {
   carried_over: number;
   expired: number;
   transactions: Transaction[];
+  error?: string; // posting failed; nothing was posted
 }
 
 export interface Scenario {
@@ -163,15 +165,135 @@ export const getEmployee = (id: string) => fetchJSON<Employee>(`/employees/${id}
 export const createEmployee = (data: Omit<Employee, 'created_at'>) =>
   fetchJSON<Employee>('/employees', { method: 'POST', body: JSON.stringify(data) });
 
-// Balance
-export const getBalance = (employeeId: string, resourceType = 'pto') =>
-  fetchJSON<Balance>(`/employees/${employeeId}/balance?resource_type=${resourceType}`);
+// Balance. asKnownAt (YYYY-MM-DD or RFC3339) shows it as the system knew it then
+export const getBalance = (employeeId: string, resourceType = 'pto', asOf = '', asKnownAt = '') =>
+  fetchJSON<Balance>(
+    `/employees/${employeeId}/balance?resource_type=${resourceType}&as_of=${asOf}&as_known_at=${encodeURIComponent(asKnownAt)}`
+  );
+
+export interface BalancePoint {
+  date: string;
+  available: number;
+  accrued: number;
+  consumed: number;
+  pending: number;
+  policies: { policy_id: string; available: number; accrued: number; consumed: number; pending: number }[];
+}
+
+export interface BalanceHistory {
+  entity_id: string;
+  resource_type: string;
+  from: string;
+  to: string;
+  interval: 'day' | 'week' | 'month';
+  as_known_at?: string;
+  points: BalancePoint[];
+}
+
+// Points count transactions effective on or before their date
+export const getBalanceHistory = (
+  employeeId: string,
+  params: {
+    from?: string;
+    to?: string;
+    interval?: 'day' | 'week' | 'month';
+    resource_type?: string;
+    policy_id?: string;
+    as_known_at?: string;
+  } = {}
+) => {
+  const query = new URLSearchParams(Object.entries(params).filter(([, v]) => v) as [string, string][]);
+  return fetchJSON<BalanceHistory>(`/employees/${employeeId}/balance/history?${query}`);
+};
+
+export interface PolicyBalanceExplanation {
+  policy_id: string;
+  policy_name: string;
+  period_start: string;
+  period_end: string;
+  unit: string;
+  consumption_mode: string;
+  rule: string;
+  available: number;
+  accrued_to_date: number;
+  total_entitlement: number; // schedule only; adjustments are separate
+  consumed: number;
+  pending: number;
+  adjustments: number;
+  granted: number;
+  accrued_basis: 'schedule' | 'grants';
+  accruals: { date: string; amount: number; reason: string; to_date: boolean }[];
+  transactions: {
+    id: string;
+    type: string;
+    effective_at: string;
+    delta: number;
+    component: 'accrued' | 'consumed' | 'pending' | 'adjustments';
+    effect: number;
+    reference_id?: string;
+    reason?: string;
+  }[];
+  constraints: { name: string; limit?: number; value: number; passed: boolean; detail: string }[];
+}
+
+export interface BalanceExplanation {
+  entity_id: string;
+  resource_type: string;
+  total_available: number;
+  as_of: string;
+  as_known_at?: string;
+  policies: PolicyBalanceExplanation[];
+}
+
+// Itemized derivation of getBalance's figures
+export const explainBalance = (employeeId: string, resourceType = 'pto', asOf = '', asKnownAt = '') =>
+  fetchJSON<BalanceExplanation>(
+    `/employees/${employeeId}/balance/explain?resource_type=${resourceType}&as_of=${asOf}&as_known_at=${encodeURIComponent(asKnownAt)}`
+  );
+
+export interface BalanceProjection {
+  entity_id: string;
+  resource_type: string;
+  amount: number;
+  from: string;
+  until: string;
+  expected_rate?: number;
+  reached: boolean;
+  unlimited?: boolean;
+  date?: string; // first day amount is available
+  available: number;
+  best: number;
+  best_date?: string;
+  policies: {
+    policy_id: string;
+    policy_name: string;
+    available: number;
+    carried_in: number;
+    expired: number;
+    expected: number;
+    capped: boolean;
+    assumed_rate: boolean;
+  }[];
+}
+
+// First day `amount` is available; expected_rate is per month, for accruals that cannot be predicted
+export const projectBalance = (
+  employeeId: string,
+  amount: number,
+  params: { resource_type?: string; from?: string; until?: string; expected_rate?: number } = {}
+) => {
+  const query = new URLSearchParams({ amount: String(amount) });
+  Object.entries(params).forEach(([k, v]) => v !== undefined && v !== '' && query.set(k, String(v)));
+  return fetchJSON<BalanceProjection>(`/employees/${employeeId}/balance/projection?${query}`);
+};
 
 // Transactions
-export const getTransactions = (employeeId: string, policyId?: string) => {
-  let url = `/employees/${employeeId}/transactions`;
-  if (policyId) url += `?policy_id=${policyId}`;
-  return fetchJSON<Transaction[]>(url);
+export const getTransactions = (employeeId: string, policyId?: string, asKnownAt?: string) => {
+  const query = new URLSearchParams();
+  if (policyId) query.set('policy_id', policyId);
+  if (asKnownAt) query.set('as_known_at', asKnownAt);
+  const qs = query.toString();
+  return fetchJSON<Transaction[]>(`/employees/${employeeId}/transactions${qs ? `?${qs}` : ''}`);
 };
 
 export interface CancelResponse {
@@ -207,7 +329,14 @@ export const createPolicy = (config: PolicyConfig) =>
 // Admin
 export const triggerRollover = (data: { entity_id?: string; policy_id?: string; period_end: string }) =>
   fetchJSON<RolloverResult[]>('/admin/rollover', { method: 'POST', body: JSON.stringify(data) });
-export const createAdjustment = (data: { entity_id: string; policy_id: string; delta: number; reason: string }) =>
+// effective_at (YYYY-MM-DD, default today) in a reconciled period corrects its rollover
+export const createAdjustment = (data: {
+  entity_id: string;
+  policy_id: string;
+  delta: number;
+  reason: string;
+  effective_at?: string;
+}) =>
   fetchJSON<Transaction>('/admin/adjustments', { method: 'POST', body: JSON.stringify(data) });
 
 // Scenarios
@@ -288,6 +417,7 @@ export const rejectRequest = (id: string, reason: string, rejecterId = 'admin')
 
 export interface ReconciliationRun {
   id: string;
+  batch_id?: string;
   policy_id: string;
   entity_id: string;
   period_start: string;
@@ -297,13 +427,159 @@ export interface ReconciliationRun {
   expired: number;
   error?: string;
   completed_at?: string;
+  rolled_back_at?: string;
+  corrections?: number; // Recomputed after backdated transactions
+  corrected_at?: string;
+  corrected_by?: string; // Backdated transaction
+  corrected_from?: string; // Item of the earlier period that cascaded here
 }
 
+// Per-period reconciliations, whichever batch ran them
 export const getReconciliationRuns = (status = '') =>
-  fetchJSON<{ runs: ReconciliationRun[] }>(`/reconciliation/runs?status=${status}`);
+  fetchJSON<{ items: ReconciliationRun[] }>(`/reconciliation/items?status=${status}`)
+    .then((data) => ({ runs: data.items }));
+
+export interface ReconciliationBatch {
+  id: string;
+  trigger: string;
+  status: 'running' | 'completed' | 'cancelled';
+  as_of: string;
+  workers: number;
+  total: number;
+  processed: number;
+  reconciled: number;
+  failed: number;
+  carried_over: number;
+  expired: number;
+  checkpoint?: string;
+  error?: string;
+  created_at: string;
+  updated_at: string;
+  completed_at?: string;
+}
+
+export const getReconciliationBatches = () =>
+  fetchJSON<{ runs: ReconciliationBatch[] }>('/reconciliation/runs');
+
+export const startReconciliationBatch = (data: { workers?: number; as_of?: string } = {}) =>
+  fetchJSON<ReconciliationBatch>('/reconciliation/runs', {
+    method: 'POST',
+    body: JSON.stringify(data),
+  });
+
+export const cancelReconciliationBatch = (id: string) =>
+  fetchJSON<ReconciliationBatch>(`/reconciliation/runs/${id}/cancel`, { method: 'POST' });
+
+export const resumeReconciliationBatch = (id: string) =>
+  fetchJSON<ReconciliationBatch>(`/reconciliation/runs/${id}/resume`, { method: 'POST' });
+
+// Rollbacks post reversing transactions; items stay listed as rolled_back
+export const rollbackReconciliationBatch = (id: string) =>
+  fetchJSON<{
+    batch: ReconciliationBatch;
+    rolled_back: ReconciliationRun[];
+    errors: { item_id: string; error: string }[];
+  }>(`/reconciliation/runs/${id}/rollback`, { method: 'POST' });
+
+export const rollbackReconciliationRun = (id: string) =>
+  fetchJSON<ReconciliationRun>(`/reconciliation/items/${id}/rollback`, { method: 'POST' });
 
 export const triggerReconciliation = (data: { entity_id?: string; policy_id?: string; period_end: string }) =>
   fetchJSON<RolloverResult[]>('/reconciliation/process', {
     method: 'POST',
     body: JSON.stringify(data),
   });
+
+export interface ReconciliationPreviewLine {
+  entity_id: string;
+  policy_id: string;
+  unit: string;
+  period_start: string;
+  period_end: string;
+  balance: number;
+  carryover_cap?: number;
+  max_balance?: number;
+  carried_over: number;
+  expired: number;
+  prorated: number;
+  transactions: Transaction[];
+  error?: string;
+}
+
+export interface ReconciliationPreview {
+  as_of?: string;
+  period_end?: string;
+  lines: ReconciliationPreviewLine[];
+  totals: {
+    unit: string;
+    entities: number;
+    lines: number;
+    failed: number;
+    carried_over: number;
+    expired: number;
+    prorated: number;
+  }[];
+}
+
+// Dry runs: what the scheduler (as of a day) or a rollover would post
+export const previewReconciliation = (asOf = '') =>
+  fetchJSON<ReconciliationPreview>(`/reconciliation/preview?as_of=${asOf}`);
+
+export const previewRollover = (data: { entity_id?: string; policy_id?: string; period_end: string }) =>
+  fetchJSON<ReconciliationPreview>('/admin/rollover', {
+    method: 'POST',
+    body: JSON.stringify({ ...data, dry_run: true }),
+  });
+
+// =============================================================================
+// LIVE EVENTS
+// =============================================================================
+
+export type LiveEventType =
+  | 'request.submitted'
+  | 'request.approved'
+  | 'request.rejected'
+  | 'transaction.appended'
+  | 'reconciliation.completed';
+
+export interface LiveEvent {
+  id: number;
+  type: LiveEventType;
+  tenant_id: string;
+  entity_id?: string;
+  occurred_at: string;
+  data?: Record<string, unknown>;
+}
+
+const LIVE_EVENT_TYPES: LiveEventType[] = [
+  'request.submitted',
+  'request.approved',
+  'request.rejected',
+  'transaction.appended',
+  'reconciliation.completed',
+];
+
+// Subscribes to /api/events; returns a function that closes the stream.
+// EventSource reconnects on its own; onReconnect lets callers reload state
+// they may have missed while disconnected.
+export function subscribeEvents(
+  filter: { entity_id?: string; manager_id?: string },
+  onEvent: (event: LiveEvent) => void,
+  onReconnect?: () => void,
+): () => void {
+  const params = new URLSearchParams();
+  if (filter.entity_id) params.set('entity_id', filter.entity_id);
+  if (filter.manager_id) params.set('manager_id', filter.manager_id);
+  const query = params.toString();
+
+  const source = new EventSource(`${API_BASE}/events${query ? `?${query}` : ''}`);
+  let opened = false;
+  source.onopen = () => {
+    if (opened) onReconnect?.();
+    opened = true;
+  };
+  for (const type of LIVE_EVENT_TYPES) {
+    source.addEventListener(type, (e) => onEvent(JSON.parse((e as MessageEvent).data)));
+  }
+  return () => source.close();
+}
@@ -1,7 +1,7 @@
-import { useState } from 'react';
+import { useState, useEffect } from 'react';
 import { useQuery, useMutation, useQueryClient } from '@tanstack/react-query';
 import { CheckCircle, XCircle, Clock, User, Calendar } from 'lucide-react';
-import { getPendingRequests, approveRequest, rejectRequest } from '../api/client';
+import { getPendingRequests, approveRequest, rejectRequest, subscribeEvents } from '../api/client';
 
 const styles = {
   container: {
@@ -195,9 +195,17 @@ export function ApprovalQueue() {
   const { data, isLoading } = useQuery({
     queryKey: ['pendingRequests'],
     queryFn: getPendingRequests,
-    refetchInterval: 30000, // Refresh every 30 seconds
+    refetchInterval: 30000, // Fallback if the live stream is down
   });
 
+  // New submissions and decisions made elsewhere show up immediately
+  useEffect(() => {
+    const refresh = () => queryClient.invalidateQueries({ queryKey: ['pendingRequests'] });
+    return subscribeEvents({}, (event) => {
+      if (event.type.startsWith('request.')) refresh();
+    }, refresh);
+  }, [queryClient]);
+
   const approveMutation = useMutation({
     mutationFn: (id: string) => approveRequest(id),
     onSuccess: () => {
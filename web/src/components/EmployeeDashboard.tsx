@@ -2,7 +2,7 @@ import { useState, useMemo, useEffect } from 'react';
 import { useQuery, useMutation, useQueryClient } from '@tanstack/react-query';
 import { useParams } from 'react-router-dom';
 import { Calendar, Clock, AlertCircle, Check, User, Info } from 'lucide-react';
-import { getEmployees, getBalance, getTransactions, submitRequest, getCurrentScenario, getAssignments, getPolicies } from '../api/client';
+import { getEmployees, getBalance, getTransactions, submitRequest, getCurrentScenario, getAssignments, getPolicies, subscribeEvents } from '../api/client';
 import type { Balance, Transaction } from '../api/client';
 import { format, parseISO } from 'date-fns';
 import { DateRangePicker } from './DateRangePicker';
@@ -135,6 +135,19 @@ export function EmployeeDashboard() {
     // Note: Don't reset to empty - keep 'pto' as default so balance query runs
   }, [availableResourceTypes, selectedResourceType]);
 
+  // Refresh balance and history when this employee's ledger changes,
+  // whoever changed it (approvals, cancellations, rollovers)
+  useEffect(() => {
+    if (!selectedEmployee) return;
+    const refresh = () => {
+      queryClient.invalidateQueries({ queryKey: ['balance', selectedEmployee] });
+      queryClient.invalidateQueries({ queryKey: ['transactions', selectedEmployee] });
+    };
+    return subscribeEvents({ entity_id: selectedEmployee }, (event) => {
+      if (event.type === 'transaction.appended' || event.type === 'reconciliation.completed') refresh();
+    }, refresh);
+  }, [selectedEmployee, queryClient]);
+
   // Check if current scenario is rewards and redirect
   useEffect(() => {
     if (currentScenario?.category === 'rewards') {